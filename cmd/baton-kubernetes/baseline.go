@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/conductorone/baton-kubernetes/pkg/connector"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+// ErrConfigDrift is returned when the effective configuration differs from the --baseline-config file.
+var ErrConfigDrift = errors.New("configuration drifted from the baseline")
+
+// writeBaseline writes the baseline of the configuration the options result in to path.
+func writeBaseline(path string, opts []connector.ConnectorOption) error {
+	baseline, err := connector.NewConfigBaseline(opts...)
+	if err != nil {
+		return fmt.Errorf("failed to compute config baseline: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := baseline.Write(&buf); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("failed to write --%s file: %w", flagBaselineConfig, err)
+	}
+	return nil
+}
+
+// checkBaseline compares the configuration the options result in with the baseline file at path, logging each
+// drifted option. Drift, or a missing baseline, fails one-shot runs with ErrConfigDrift, while the service keeps
+// running with a warning so that a flag change doesn't take down syncs.
+func checkBaseline(ctx context.Context, path string, opts []connector.ConnectorOption, serviceMode bool) error {
+	l := ctxzap.Extract(ctx)
+
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		if serviceMode {
+			l.Warn("config baseline doesn't exist, create it with --"+flagWriteBaseline, zap.String("path", path))
+			return nil
+		}
+		return fmt.Errorf("%w: --%s file %s does not exist, create it with --%s", ErrConfigDrift, flagBaselineConfig, path, flagWriteBaseline)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open --%s file: %w", flagBaselineConfig, err)
+	}
+	defer f.Close()
+
+	pinned, err := connector.ReadConfigBaseline(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	current, err := connector.NewConfigBaseline(opts...)
+	if err != nil {
+		return fmt.Errorf("failed to compute config baseline: %w", err)
+	}
+	drift, err := current.Drift(pinned)
+	if err != nil {
+		return err
+	}
+	if len(drift) == 0 {
+		l.Debug("configuration matches the baseline", zap.String("path", path))
+		return nil
+	}
+
+	for _, d := range drift {
+		l.Warn("configuration drifted from the baseline",
+			zap.String("option", d.Option),
+			zap.String("baseline", d.Baseline),
+			zap.String("current", d.Current))
+	}
+	if serviceMode {
+		return nil
+	}
+	return fmt.Errorf("%w: %d options differ from %s, first %s", ErrConfigDrift, len(drift), path, drift[0])
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/conductorone/baton-kubernetes/pkg/connector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckBaseline(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	pinned := []connector.ConnectorOption{connector.WithSyncResources([]string{"namespace", "role"})}
+
+	t.Run("missing", func(t *testing.T) {
+		err := checkBaseline(ctx, path, pinned, false)
+		assert.ErrorIs(t, err, ErrConfigDrift)
+		assert.ErrorContains(t, err, "does not exist")
+		assert.Equal(t, exitCodeConfigDrift, exitCode(err))
+
+		assert.NoError(t, checkBaseline(ctx, path, pinned, true))
+	})
+
+	require.NoError(t, writeBaseline(path, pinned))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	t.Run("unchanged", func(t *testing.T) {
+		assert.NoError(t, checkBaseline(ctx, path, pinned, false))
+	})
+
+	t.Run("changed", func(t *testing.T) {
+		changed := append(pinned, connector.WithWildcardResources(false))
+		err := checkBaseline(ctx, path, changed, false)
+		assert.ErrorIs(t, err, ErrConfigDrift)
+		assert.ErrorContains(t, err, "disableWildcardResources: baseline false, current true")
+
+		// The service keeps running on drift
+		assert.NoError(t, checkBaseline(ctx, path, changed, true))
+	})
+}
@@ -2,8 +2,9 @@ package main
 
 import (
 	"fmt"
-	"os"
+	"strings"
 
+	"github.com/conductorone/baton-kubernetes/pkg/connector"
 	"github.com/conductorone/baton-sdk/pkg/field"
 	"github.com/spf13/viper"
 
@@ -36,11 +37,63 @@ const (
 	flagCacheDir           = "cache-dir"
 	flagDisableCompression = "disable-compression"
 	flagKubeconfig         = "kubeconfig"
+
+	// Connector options.
+	flagLabelTags                 = "label-tags"
+	flagRedactAnnotationKeys      = "redact-annotation-keys"
+	flagSkipMissingNamedResources = "skip-missing-named-resources"
+	flagIncludeSystemSubjects     = "include-system-subjects"
+	flagSkipSystemClusterRoles    = "skip-system-cluster-roles"
+	flagSeparateSystemUsers       = "separate-system-users"
+	flagNamespaceEntSelector      = "namespace-entitlement-selector"
+	flagDropUnselectedNSGrants    = "drop-unselected-namespace-grants"
+	flagCompactClusterRoleEnts    = "compact-cluster-role-entitlements"
+	flagNamespaceWildcards        = "namespace-wildcards"
+	flagNoWildcardResources       = "no-wildcard-resources"
+	flagVerifyCoverage            = "verify-coverage"
+	flagAllowEmptySync            = "allow-empty-sync"
+	flagAllowPartialSync          = "allow-partial-sync"
+	flagExpandSAGroups            = "expand-service-account-groups"
+	flagSkipDefaultSAs            = "skip-default-service-accounts"
+	flagMountGrants               = "mount-grants"
+	flagRedactNames               = "redact-names"
+	flagRedactNamesKey            = "redact-names-key"
+	flagRedactPreservePrefixes    = "redact-preserve-prefixes"
+	flagRemoteTokenSecret         = "remote-token-secret"
+	flagPersistBindingsCache      = "persist-bindings-cache"
+	flagKeepCachesAcrossSyncs     = "keep-caches-across-syncs"
+	flagPageSizes                 = "page-sizes"
+	flagVerbFilter                = "verb-filter"
+	flagPodSampleRate             = "pod-sample-rate"
+	flagGrantsPageSize            = "grants-page-size"
+	flagProfileRulesLimit         = "profile-rules-limit"
+	flagListRetries               = "list-retries"
+	flagSkipGrantPreCheck         = "skip-grant-pre-check"
+	flagSecretSensitivity         = "secret-sensitivity"
+	flagClusterAdminsReport       = "cluster-admins-report"
+	flagRoleGrantableBy           = "role-grantable-by"
+	flagGroupMembershipFile       = "group-membership-file"
+	flagRancherProjects           = "rancher-projects"
+	flagInheritAggregatedBindings = "inherit-aggregated-bindings"
+	flagAcceptClusterChange       = "accept-cluster-change"
+
+	// One-shot commands.
+	flagExplainPrincipal = "explain-principal"
+	flagSmokeTest        = "smoke-test"
+	flagSelfCheck        = "self-check"
+	flagOutput           = "output"
+
+	// Configuration drift detection.
+	flagBaselineConfig = "baseline-config"
+	flagWriteBaseline  = "write-baseline"
+
+	// From the baton SDK, set when the connector runs as a service.
+	flagClientID = "client-id"
 )
 
 var (
-	kubeconfigField  = field.StringField(flagKubeconfig, field.WithDescription("Path to the kubeconfig file to use for CLI requests."))
-	cacheDirField    = field.StringField(flagCacheDir, field.WithDescription("Default cache directory"))
+	kubeconfigField  = field.StringField(flagKubeconfig, field.WithDescription("Path to the kubeconfig file to use for CLI requests. ~ and environment variables are expanded."))
+	cacheDirField    = field.StringField(flagCacheDir, field.WithDescription("Default cache directory. ~ and environment variables are expanded."))
 	certFileField    = field.StringField(flagCertFile, field.WithDescription("Path to a client certificate file for TLS"), field.WithRequired(false))
 	keyFileField     = field.StringField(flagKeyFile, field.WithDescription("Path to a client key file for TLS"), field.WithRequired(false))
 	bearerTokenField = field.StringField(flagBearerToken, field.WithDescription("Bearer token for authentication to the API server"), field.WithRequired(false))
@@ -56,7 +109,7 @@ var (
 	authInfoNameField  = field.StringField(flagAuthInfoName, field.WithDescription("The name of the kubeconfig user to use"), field.WithRequired(false))
 	namespaceField     = field.StringField(flagNamespace, field.WithDescription("If present, the namespace scope for this CLI request"), field.WithRequired(false))
 	contextField       = field.StringField(flagContext, field.WithDescription("The name of the kubeconfig context to use"), field.WithRequired(false))
-	apiServerField     = field.StringField(flagAPIServer, field.WithDescription("The address and port of the Kubernetes API server"), field.WithRequired(false))
+	apiServerField     = field.StringField(flagAPIServer, field.WithDescription("The address and port of the Kubernetes API server, e.g. https://api.example.com:6443 or https://[fd00::1]:6443"), field.WithRequired(false))
 	tlsServerNameField = field.StringField(flagTLSServerName,
 		field.WithDescription("Server name to use for server certificate validation. If it is not provided, the hostname used to contact the server is used"), field.WithRequired(false))
 	insecureField = field.BoolField(flagInsecure,
@@ -70,6 +123,147 @@ var (
 				" A value of zero means don't timeout requests."),
 		field.WithDefaultValue("0"))
 	disableCompressionField = field.BoolField(flagDisableCompression, field.WithDescription("If true, opt-out of response compression for all requests to the server"), field.WithDefaultValue(false))
+	labelTagsField          = field.StringSliceField(flagLabelTags,
+		field.WithDescription("Label keys whose values are exposed as resource tags (e.g. team)"), field.WithRequired(false))
+	redactAnnotationKeysField = field.StringSliceField(flagRedactAnnotationKeys,
+		field.WithDescription("Annotation keys left out of resource profiles, in addition to the last-applied configuration left out of secret profiles"),
+		field.WithRequired(false))
+	skipMissingNamedResourcesField = field.BoolField(flagSkipMissingNamedResources,
+		field.WithDescription("If true, skip grants from rules with resourceNames on objects that don't exist in the cluster"), field.WithDefaultValue(false))
+	includeSystemSubjectsField = field.BoolField(flagIncludeSystemSubjects,
+		field.WithDescription("If true, grant roles to system users and groups such as system:masters"), field.WithDefaultValue(false))
+	skipSystemClusterRolesField = field.BoolField(flagSkipSystemClusterRoles,
+		field.WithDescription("If true, skip the system: cluster roles that Kubernetes components are bound to"), field.WithDefaultValue(false))
+	separateSystemUsersField = field.BoolField(flagSeparateSystemUsers,
+		field.WithDescription("If true, sync users with system: names, such as system:kube-scheduler, as kube_system_user rather than kube_user resources"),
+		field.WithDefaultValue(false))
+	namespaceEntSelectorField = field.StringField(flagNamespaceEntSelector,
+		field.WithDescription("Label selector (e.g. tier=prod) limiting the namespaces cluster roles get per-namespace entitlements in. "+
+			"Bindings in other namespaces are granted a catch-all other_namespaces:member entitlement"),
+		field.WithRequired(false))
+	dropUnselectedNSGrantsField = field.BoolField(flagDropUnselectedNSGrants,
+		field.WithDescription("If true, drop the grants from bindings in namespaces not matching --namespace-entitlement-selector instead of granting other_namespaces:member"),
+		field.WithDefaultValue(false))
+	compactClusterRoleEntsField = field.BoolField(flagCompactClusterRoleEnts,
+		field.WithDescription("If true, give each cluster role a single namespaced_bindings:member entitlement instead of one per namespace, "+
+			"recording the namespaces of the role bindings in the grants"),
+		field.WithDefaultValue(false))
+	noWildcardResourcesField = field.BoolField(flagNoWildcardResources,
+		field.WithDescription("If true, don't sync the \"All\" wildcard resources of the resource types, "+
+			"nor the grants of role rules covering every resource of a type"),
+		field.WithDefaultValue(false))
+	namespaceWildcardsField = field.BoolField(flagNamespaceWildcards,
+		field.WithDescription("If true, sync a wildcard resource per namespace for namespaced resource types, "+
+			"and grant the rules of roles on the wildcard of their namespace instead of the cluster-wide one"),
+		field.WithDefaultValue(false))
+	expandSAGroupsField = field.BoolField(flagExpandSAGroups,
+		field.WithDescription("If true, grant the roles of the system:serviceaccounts groups to every service account in them"),
+		field.WithDefaultValue(false))
+	skipDefaultSAsField = field.BoolField(flagSkipDefaultSAs,
+		field.WithDescription("If true, don't sync the default service account of a namespace unless it has secrets or image pull secrets, "+
+			"or a binding names it"),
+		field.WithDefaultValue(false))
+	skipGrantPreCheckField = field.BoolField(flagSkipGrantPreCheck,
+		field.WithDescription("If true, don't verify the connector may create a binding, including under the RBAC escalation rules, before provisioning it"),
+		field.WithDefaultValue(false))
+	acceptClusterChangeField = field.BoolField(flagAcceptClusterChange,
+		field.WithDescription("If true, sync a different cluster than the one fingerprinted in --cache-dir by previous runs, recording the new cluster"),
+		field.WithDefaultValue(false))
+	secretSensitivityField = field.BoolField(flagSecretSensitivity,
+		field.WithDescription("If true, record a sensitivityTier (critical, high or normal) in the profile of secrets, derived from the ingresses, "+
+			"pods and webhook configurations referencing them"),
+		field.WithDefaultValue(false))
+	clusterAdminsReportField = field.BoolField(flagClusterAdminsReport,
+		field.WithDescription("If true, sync a cluster-admins report resource whose profile lists every principal bound cluster-wide to cluster-admin, "+
+			"to a cluster role granting every verb on every resource, or to a cluster role able to bind, escalate or impersonate"),
+		field.WithDefaultValue(false))
+	roleGrantableByField = field.BoolField(flagRoleGrantableBy,
+		field.WithDescription("If true, list in the profile of each role the principals able to grant it: those bound to create or update "+
+			"role bindings in its namespace and to bind or escalate it. Computed once per sync from every role and binding"),
+		field.WithDefaultValue(false))
+	inheritAggregatedBindingsField = field.BoolField(flagInheritAggregatedBindings,
+		field.WithDescription("If true, grant the cluster roles contributing to an aggregated cluster role, like admin, to the subjects of its bindings "+
+			"in the same namespaces, recording the aggregate. Adds a grant per contributing cluster role to each binding of an aggregate"),
+		field.WithDefaultValue(false))
+	rancherProjectsField = field.BoolField(flagRancherProjects,
+		field.WithDescription("If true, sync the Rancher projects namespaces are grouped into by their field.cattle.io/projectId label, "+
+			"granting project membership to their namespaces and to the subjects of the project role template bindings"),
+		field.WithDefaultValue(false))
+	groupMembershipFileField = field.StringField(flagGroupMembershipFile,
+		field.WithDescription("Path to a YAML or JSON file mapping group names to lists of usernames, from which kube_group members are synced. "+
+			"Re-read on each sync"), field.WithRequired(false))
+	mountGrantsField = field.BoolField(flagMountGrants,
+		field.WithDescription("If true, grant get on secrets and configmaps to the service accounts of the pods mounting them through volumes or environment variables, "+
+			"and link configmaps to the workloads whose pod template consumes them"),
+		field.WithDefaultValue(false))
+	allowEmptySyncField = field.BoolField(flagAllowEmptySync,
+		field.WithDescription("If true, don't fail syncs that find no namespaces or no roles, e.g. for genuinely empty clusters"),
+		field.WithDefaultValue(false))
+	allowPartialSyncField = field.BoolField(flagAllowPartialSync,
+		field.WithDescription("If true, pass validation with a warning when the connector can't list some of the synced resources, rather than failing"),
+		field.WithDefaultValue(false))
+	verifyCoverageField = field.BoolField(flagVerifyCoverage,
+		field.WithDescription("If true, verify the connector can list secrets, roles and rolebindings in every synced namespace, failing the sync as partial if not"),
+		field.WithDefaultValue(false))
+	redactNamesField = field.BoolField(flagRedactNames,
+		field.WithDescription("If true, deterministically pseudonymize resource names in the sync output. Redacted syncs can't be used for provisioning"), field.WithDefaultValue(false))
+	redactNamesKeyField = field.StringField(flagRedactNamesKey,
+		field.WithDescription("Key used to pseudonymize names when --redact-names is set"), field.WithRequired(false), field.WithIsSecret(true))
+	redactPreservePrefixesField = field.StringSliceField(flagRedactPreservePrefixes,
+		field.WithDescription("Name prefixes left unredacted when --redact-names is set (e.g. kube-,system:)"), field.WithRequired(false))
+	remoteTokenSecretField = field.StringField(flagRemoteTokenSecret,
+		field.WithDescription("Secret in the local cluster, as namespace/name[:key], holding the bearer token for the cluster at --server. "+
+			"Read with the in-cluster config, and re-read when the token is rejected"),
+		field.WithRequired(false))
+	persistBindingsCacheField = field.BoolField(flagPersistBindingsCache,
+		field.WithDescription("If true, persist the role bindings and cluster role bindings in --cache-dir and reuse them after a restart when none changed"),
+		field.WithDefaultValue(false))
+	keepCachesAcrossSyncsField = field.BoolField(flagKeepCachesAcrossSyncs,
+		field.WithDescription("If true, keep the bindings and namespaces loaded by the first sync for the lifetime of the connector instead of "+
+			"loading them again at the start of each sync. Bindings and namespaces created or deleted since aren't seen until a restart"),
+		field.WithDefaultValue(false))
+	pageSizesField = field.StringSliceField(flagPageSizes,
+		field.WithDescription("Page sizes of the listings of resource types, as <resource type>=<size> (e.g. pod=2000,secret=100). "+
+			"Other resource types are listed 500 objects at a time"),
+		field.WithRequired(false))
+	verbFilterField = field.StringSliceField(flagVerbFilter,
+		field.WithDescription("Verb entitlements to sync, as <resource type>=<verb> repeated for each allowed verb, with * as the resource type "+
+			"for the types without their own (e.g. *=get,*=create,*=update,*=delete,secret=get). Other verbs are neither synced nor granted"),
+		field.WithRequired(false))
+	podSampleRateField = field.StringField(flagPodSampleRate,
+		field.WithDescription("Fraction of the pods to sync (e.g. 0.1), picked by the hash of their UID so repeated syncs keep the same pods. "+
+			"The wildcard pod is annotated with the sample rate"),
+		field.WithRequired(false))
+	grantsPageSizeField = field.IntField(flagGrantsPageSize,
+		field.WithDescription("Number of grants returned per page of the role and cluster role grants"),
+		field.WithDefaultValue(connector.GrantsPageSize))
+	profileRulesLimitField = field.IntField(flagProfileRulesLimit,
+		field.WithDescription("Maximum number of rules listed in the profile of a role or cluster role, larger rule sets are truncated and flagged"),
+		field.WithDefaultValue(connector.ProfileRulesLimit))
+	listRetriesField = field.IntField(flagListRetries,
+		field.WithDescription("Number of times a list call failing with a transient API server error, such as a timeout or throttling, is retried"),
+		field.WithDefaultValue(connector.DefaultListRetries))
+	explainPrincipalField = field.StringField(flagExplainPrincipal,
+		field.WithDescription("Print the roles, bindings and permissions of a principal, e.g. service_account:payments/deployer, and exit"),
+		field.WithRequired(false))
+	smokeTestField = field.BoolField(flagSmokeTest,
+		field.WithDescription("Check the connection, permissions, pagination and grant computation against the cluster, with the configured "+
+			"kubeconfig, proxy and TLS settings, print the timing of each step and exit"),
+		field.WithDefaultValue(false))
+	selfCheckField = field.BoolField(flagSelfCheck,
+		field.WithDescription("Run a full sync with the configured options, check every resource, entitlement and grant against "+
+			"the invariants of the baton SDK, print the violations and exit"),
+		field.WithDefaultValue(false))
+	outputField = field.StringField(flagOutput,
+		field.WithDescription("Format of the output of --smoke-test and --self-check, text or json"),
+		field.WithDefaultValue(outputText))
+	baselineConfigField = field.StringField(flagBaselineConfig,
+		field.WithDescription("Path to a baseline of the connector configuration to compare the effective one with at startup. "+
+			"Differences fail one-shot runs and are logged as warnings by the service"),
+		field.WithRequired(false))
+	writeBaselineField = field.BoolField(flagWriteBaseline,
+		field.WithDescription("If true, write the effective connector configuration to --baseline-config and exit"),
+		field.WithDefaultValue(false))
 )
 
 func getConfigurationFields() []field.SchemaField {
@@ -94,6 +288,49 @@ func getConfigurationFields() []field.SchemaField {
 		caFileField,
 		timeoutField,
 		disableCompressionField,
+		labelTagsField,
+		redactAnnotationKeysField,
+		skipMissingNamedResourcesField,
+		includeSystemSubjectsField,
+		skipSystemClusterRolesField,
+		separateSystemUsersField,
+		namespaceEntSelectorField,
+		dropUnselectedNSGrantsField,
+		compactClusterRoleEntsField,
+		namespaceWildcardsField,
+		noWildcardResourcesField,
+		expandSAGroupsField,
+		skipDefaultSAsField,
+		mountGrantsField,
+		secretSensitivityField,
+		clusterAdminsReportField,
+		roleGrantableByField,
+		inheritAggregatedBindingsField,
+		rancherProjectsField,
+		groupMembershipFileField,
+		verifyCoverageField,
+		allowEmptySyncField,
+		allowPartialSyncField,
+		redactNamesField,
+		redactNamesKeyField,
+		redactPreservePrefixesField,
+		remoteTokenSecretField,
+		persistBindingsCacheField,
+		keepCachesAcrossSyncsField,
+		pageSizesField,
+		verbFilterField,
+		podSampleRateField,
+		grantsPageSizeField,
+		profileRulesLimitField,
+		listRetriesField,
+		skipGrantPreCheckField,
+		acceptClusterChangeField,
+		explainPrincipalField,
+		smokeTestField,
+		selfCheckField,
+		outputField,
+		baselineConfigField,
+		writeBaselineField,
 	}
 }
 
@@ -126,6 +363,22 @@ func getFieldRelationships() []field.SchemaFieldRelationship {
 		field.FieldsMutuallyExclusive(certFileField, impersonateField),
 		field.FieldsMutuallyExclusive(keyFileField, impersonateField),
 
+		// Remote Token Secret vs. other credentials
+		field.FieldsMutuallyExclusive(remoteTokenSecretField, bearerTokenField),
+		field.FieldsMutuallyExclusive(remoteTokenSecretField, usernameField),
+		field.FieldsMutuallyExclusive(remoteTokenSecretField, certFileField),
+
+		// Namespace wildcards are wildcard resources too
+		field.FieldsMutuallyExclusive(noWildcardResourcesField, namespaceWildcardsField),
+
+		// One-shot commands
+		field.FieldsMutuallyExclusive(explainPrincipalField, smokeTestField),
+		field.FieldsMutuallyExclusive(writeBaselineField, explainPrincipalField),
+		field.FieldsMutuallyExclusive(writeBaselineField, smokeTestField),
+		field.FieldsMutuallyExclusive(selfCheckField, explainPrincipalField),
+		field.FieldsMutuallyExclusive(selfCheckField, smokeTestField),
+		field.FieldsMutuallyExclusive(selfCheckField, writeBaselineField),
+
 		// --- Required Together ---
 
 		// Username and Password must be provided together
@@ -133,6 +386,23 @@ func getFieldRelationships() []field.SchemaFieldRelationship {
 
 		// Client Certificate and Key must be provided together
 		field.FieldsRequiredTogether(certFileField, keyFileField),
+
+		// --- Dependencies ---
+
+		// The remote token secret authenticates to the cluster at --server
+		field.FieldsDependentOn([]field.SchemaField{remoteTokenSecretField}, []field.SchemaField{apiServerField}),
+
+		// Dropping the grants of unselected namespaces needs a namespace entitlement selector
+		field.FieldsDependentOn([]field.SchemaField{dropUnselectedNSGrantsField}, []field.SchemaField{namespaceEntSelectorField}),
+
+		// The bindings cache is persisted in the cache directory
+		field.FieldsDependentOn([]field.SchemaField{persistBindingsCacheField}, []field.SchemaField{cacheDirField}),
+
+		// The cluster fingerprint is recorded in the cache directory
+		field.FieldsDependentOn([]field.SchemaField{acceptClusterChangeField}, []field.SchemaField{cacheDirField}),
+
+		// The baseline is written to the baseline file
+		field.FieldsDependentOn([]field.SchemaField{writeBaselineField}, []field.SchemaField{baselineConfigField}),
 	}
 }
 
@@ -147,22 +417,51 @@ func GetConfig(v *viper.Viper) (*clioptions.ConfigFlags, error) {
 	// rather than just using the default value from NewConfigFlags.
 	// viper.IsSet() helps here.
 	if v.IsSet(flagKubeconfig) {
-		kubeconfigPath := v.GetString(flagKubeconfig)
-		// Check if the kubeconfig file exists
+		kubeconfigPath, err := normalizePath(v.GetString(flagKubeconfig))
+		if err != nil {
+			return nil, fmt.Errorf("invalid --%s: %w", flagKubeconfig, err)
+		}
+		// Check if the kubeconfig file exists and is readable
 		if kubeconfigPath != "" {
-			_, err := os.Stat(kubeconfigPath)
-			if err != nil {
-				if os.IsNotExist(err) {
-					return nil, fmt.Errorf("specified kubeconfig file does not exist: %s", kubeconfigPath)
-				}
-				return nil, fmt.Errorf("error accessing kubeconfig file: %w", err)
+			if err := checkFile(flagKubeconfig, kubeconfigPath); err != nil {
+				return nil, err
 			}
 		}
 		opt.KubeConfig = pointer.To(kubeconfigPath)
 	}
 
 	if v.IsSet(flagCacheDir) {
-		opt.CacheDir = pointer.To(v.GetString(flagCacheDir))
+		cacheDir, err := normalizePath(v.GetString(flagCacheDir))
+		if err != nil {
+			return nil, fmt.Errorf("invalid --%s: %w", flagCacheDir, err)
+		}
+		if cacheDir != "" {
+			if err := checkDir(flagCacheDir, cacheDir); err != nil {
+				return nil, err
+			}
+		}
+		opt.CacheDir = pointer.To(cacheDir)
+	}
+
+	if v.IsSet(flagBaselineConfig) {
+		if _, err := normalizePath(v.GetString(flagBaselineConfig)); err != nil {
+			return nil, fmt.Errorf("invalid --%s: %w", flagBaselineConfig, err)
+		}
+	}
+
+	if v.IsSet(flagGroupMembershipFile) {
+		path, err := normalizePath(v.GetString(flagGroupMembershipFile))
+		if err != nil {
+			return nil, fmt.Errorf("invalid --%s: %w", flagGroupMembershipFile, err)
+		}
+		if path != "" {
+			if err := checkFile(flagGroupMembershipFile, path); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if _, err := parseVerbFilter(v.GetStringSlice(flagVerbFilter)); err != nil {
+		return nil, fmt.Errorf("invalid --%s: %w", flagVerbFilter, err)
 	}
 	if v.IsSet(flagCertFile) {
 		opt.CertFile = pointer.To(v.GetString(flagCertFile))
@@ -202,11 +501,24 @@ func GetConfig(v *viper.Viper) (*clioptions.ConfigFlags, error) {
 	if v.IsSet(flagContext) {
 		opt.Context = pointer.To(v.GetString(flagContext))
 	}
-	if v.IsSet(flagAPIServer) {
-		opt.APIServer = pointer.To(v.GetString(flagAPIServer))
+	if server := v.GetString(flagAPIServer); v.IsSet(flagAPIServer) && server != "" {
+		validated, err := validateAPIServer(server)
+		if err != nil {
+			return nil, err
+		}
+		opt.APIServer = pointer.To(validated)
 	}
-	if v.IsSet(flagTLSServerName) {
-		opt.TLSServerName = pointer.To(v.GetString(flagTLSServerName))
+	if v.IsSet(flagOutput) {
+		if err := validateOutput(v.GetString(flagOutput)); err != nil {
+			return nil, err
+		}
+	}
+	if serverName := v.GetString(flagTLSServerName); v.IsSet(flagTLSServerName) && serverName != "" {
+		normalized, err := normalizeTLSServerName(serverName)
+		if err != nil {
+			return nil, err
+		}
+		opt.TLSServerName = pointer.To(normalized)
 	}
 	if v.IsSet(flagInsecure) {
 		opt.Insecure = pointer.To(v.GetBool(flagInsecure))
@@ -215,7 +527,11 @@ func GetConfig(v *viper.Viper) (*clioptions.ConfigFlags, error) {
 		opt.CAFile = pointer.To(v.GetString(flagCAFile))
 	}
 	if v.IsSet(flagTimeout) {
-		opt.Timeout = pointer.To(v.GetString(flagTimeout))
+		timeout, err := parseRequestTimeout(v.GetString(flagTimeout))
+		if err != nil {
+			return nil, err
+		}
+		opt.Timeout = pointer.To(timeout.String())
 	}
 	if v.IsSet(flagDisableCompression) {
 		opt.DisableCompression = pointer.To(v.GetBool(flagDisableCompression))
@@ -223,3 +539,133 @@ func GetConfig(v *viper.Viper) (*clioptions.ConfigFlags, error) {
 
 	return opt, nil
 }
+
+// getConnectorOptions returns the connector options configured by the user.
+func getConnectorOptions(v *viper.Viper) []connector.ConnectorOption {
+	var opts []connector.ConnectorOption
+
+	if v.IsSet(flagLabelTags) {
+		opts = append(opts, connector.WithLabelTags(v.GetStringSlice(flagLabelTags)))
+	}
+	if v.IsSet(flagRedactAnnotationKeys) {
+		opts = append(opts, connector.WithRedactAnnotationKeys(v.GetStringSlice(flagRedactAnnotationKeys)))
+	}
+	if v.GetBool(flagSkipMissingNamedResources) {
+		opts = append(opts, connector.WithSkipMissingNamedResources(true))
+	}
+	if v.GetBool(flagIncludeSystemSubjects) {
+		opts = append(opts, connector.WithIncludeSystemSubjects(true))
+	}
+	if v.GetBool(flagSkipSystemClusterRoles) {
+		opts = append(opts, connector.WithSkipSystemClusterRoles(true))
+	}
+	if v.GetBool(flagSeparateSystemUsers) {
+		opts = append(opts, connector.WithSeparateSystemUsers(true))
+	}
+	if selector := v.GetString(flagNamespaceEntSelector); selector != "" {
+		opts = append(opts, connector.WithNamespaceEntitlementSelector(selector))
+	}
+	if v.GetBool(flagDropUnselectedNSGrants) {
+		opts = append(opts, connector.WithDropUnselectedNamespaceGrants(true))
+	}
+	if v.GetBool(flagCompactClusterRoleEnts) {
+		opts = append(opts, connector.WithCompactClusterRoleEntitlements(true))
+	}
+	if v.GetBool(flagNoWildcardResources) {
+		opts = append(opts, connector.WithWildcardResources(false))
+	}
+	if v.GetBool(flagNamespaceWildcards) {
+		opts = append(opts, connector.WithNamespaceWildcards(true))
+	}
+	if v.GetBool(flagExpandSAGroups) {
+		opts = append(opts, connector.WithExpandServiceAccountGroups(true))
+	}
+	if v.GetBool(flagSkipDefaultSAs) {
+		opts = append(opts, connector.WithSkipDefaultServiceAccounts(true))
+	}
+	if v.GetBool(flagMountGrants) {
+		opts = append(opts, connector.WithMountGrants(true))
+	}
+	if v.GetBool(flagSkipGrantPreCheck) {
+		opts = append(opts, connector.WithSkipGrantPreCheck(true))
+	}
+	if v.GetBool(flagSecretSensitivity) {
+		opts = append(opts, connector.WithSecretSensitivity(true))
+	}
+	if v.GetBool(flagClusterAdminsReport) {
+		opts = append(opts, connector.WithClusterAdminsReport(true))
+	}
+	if v.GetBool(flagRoleGrantableBy) {
+		opts = append(opts, connector.WithRoleGrantableBy(true))
+	}
+	if v.GetBool(flagInheritAggregatedBindings) {
+		opts = append(opts, connector.WithInheritAggregatedBindings(true))
+	}
+	if v.GetBool(flagRancherProjects) {
+		opts = append(opts, connector.WithRancherProjects(true))
+	}
+	if path := normalizedPath(v, flagGroupMembershipFile); path != "" {
+		opts = append(opts, connector.WithGroupMembershipFile(path))
+	}
+	if dir := normalizedPath(v, flagCacheDir); dir != "" {
+		opts = append(opts, connector.WithClusterFingerprintDir(dir))
+	}
+	if v.GetBool(flagAcceptClusterChange) {
+		opts = append(opts, connector.WithAcceptClusterChange(true))
+	}
+	if v.GetBool(flagAllowEmptySync) {
+		opts = append(opts, connector.WithAllowEmptySync(true))
+	}
+	if v.GetBool(flagVerifyCoverage) {
+		opts = append(opts, connector.WithVerifyCoverage(true))
+	}
+	if v.GetBool(flagAllowPartialSync) {
+		opts = append(opts, connector.WithAllowPartialSync(true))
+	}
+	if pageSizes := v.GetStringSlice(flagPageSizes); len(pageSizes) > 0 {
+		opts = append(opts, connector.WithPageSizes(pageSizes))
+	}
+	if filter, err := parseVerbFilter(v.GetStringSlice(flagVerbFilter)); err == nil && len(filter) > 0 {
+		opts = append(opts, connector.WithVerbFilter(filter))
+	}
+	if v.IsSet(flagPodSampleRate) {
+		opts = append(opts, connector.WithPodSampleRate(v.GetFloat64(flagPodSampleRate)))
+	}
+	if v.IsSet(flagGrantsPageSize) {
+		opts = append(opts, connector.WithGrantsPageSize(v.GetInt(flagGrantsPageSize)))
+	}
+	if v.IsSet(flagProfileRulesLimit) {
+		opts = append(opts, connector.WithProfileRulesLimit(v.GetInt(flagProfileRulesLimit)))
+	}
+	if v.IsSet(flagListRetries) {
+		opts = append(opts, connector.WithListRetries(v.GetInt(flagListRetries)))
+	}
+	if v.GetBool(flagPersistBindingsCache) {
+		opts = append(opts, connector.WithBindingsCacheDir(normalizedPath(v, flagCacheDir)))
+	}
+	if v.GetBool(flagKeepCachesAcrossSyncs) {
+		opts = append(opts, connector.WithKeepCachesAcrossSyncs(true))
+	}
+	if ref := v.GetString(flagRemoteTokenSecret); ref != "" {
+		opts = append(opts, connector.WithRemoteTokenSecret(ref))
+	}
+	if v.GetBool(flagRedactNames) {
+		opts = append(opts, connector.WithRedactNames(v.GetString(flagRedactNamesKey), v.GetStringSlice(flagRedactPreservePrefixes)))
+	}
+
+	return opts
+}
+
+// parseVerbFilter parses the <resource type>=<verb> entries of --verb-filter into the allowed verbs of each
+// resource type. The resource types and verbs are validated by connector.WithVerbFilter.
+func parseVerbFilter(entries []string) (map[string][]string, error) {
+	filter := make(map[string][]string)
+	for _, entry := range entries {
+		resourceTypeID, verb, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || resourceTypeID == "" || verb == "" {
+			return nil, fmt.Errorf("invalid verb filter entry %q, expected <resource type>=<verb>", entry)
+		}
+		filter[resourceTypeID] = append(filter[resourceTypeID], verb)
+	}
+	return filter, nil
+}
@@ -1,11 +1,15 @@
 package main
 
 import (
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/conductorone/baton-sdk/pkg/field"
 	"github.com/conductorone/baton-sdk/pkg/test"
 	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestConfigs(t *testing.T) {
@@ -15,7 +19,96 @@ func TestConfigs(t *testing.T) {
 	)
 
 	testCases := []test.TestCase{
-		// Add test cases here.
+		{
+			Configs: map[string]string{flagAPIServer: "https://api.example.com:6443"},
+			IsValid: true,
+			Message: "host name server",
+		},
+		{
+			Configs: map[string]string{flagAPIServer: "https://[fd00::1]:6443"},
+			IsValid: true,
+			Message: "bracketed IPv6 server",
+		},
+		{
+			Configs: map[string]string{flagAPIServer: "https://[fd00::1]:6443", flagTLSServerName: "[fd00::1]"},
+			IsValid: true,
+			Message: "bracketed IPv6 TLS server name",
+		},
+		{
+			Configs: map[string]string{flagAPIServer: "fd00::1:6443"},
+			IsValid: false,
+			Message: "unbracketed IPv6 server",
+		},
+		{
+			Configs: map[string]string{flagAPIServer: "https://api.example.com:99999"},
+			IsValid: false,
+			Message: "server port out of range",
+		},
+		{
+			Configs: map[string]string{flagAPIServer: "https://[fd00::1]:6443", flagTLSServerName: "[fd00::1]:6443"},
+			IsValid: false,
+			Message: "TLS server name with port",
+		},
+		{
+			Configs: map[string]string{flagPersistBindingsCache: "true", flagCacheDir: "/var/cache/baton"},
+			IsValid: true,
+			Message: "persisted bindings cache",
+		},
+		{
+			Configs: map[string]string{flagPersistBindingsCache: "true"},
+			IsValid: false,
+			Message: "persisted bindings cache without cache directory",
+		},
+		{
+			Configs: map[string]string{flagSmokeTest: "true", flagOutput: "json"},
+			IsValid: true,
+			Message: "smoke test with JSON output",
+		},
+		{
+			Configs: map[string]string{flagSmokeTest: "true", flagOutput: "yaml"},
+			IsValid: false,
+			Message: "smoke test with unknown output",
+		},
+		{
+			Configs: map[string]string{flagSmokeTest: "true", flagExplainPrincipal: "kube_user:alice"},
+			IsValid: false,
+			Message: "smoke test and explain principal",
+		},
+		{
+			Configs: map[string]string{flagTimeout: "30s"},
+			IsValid: true,
+			Message: "request timeout",
+		},
+		{
+			Configs: map[string]string{flagTimeout: "0"},
+			IsValid: true,
+			Message: "no request timeout",
+		},
+		{
+			Configs: map[string]string{flagTimeout: "30"},
+			IsValid: false,
+			Message: "request timeout without a unit",
+		},
+		{
+			Configs: map[string]string{flagTimeout: "soon"},
+			IsValid: false,
+			Message: "request timeout that isn't a duration",
+		},
+		{
+			Configs: map[string]string{flagTimeout: "-5s"},
+			IsValid: false,
+			Message: "negative request timeout",
+		},
+		{
+			Configs: map[string]string{flagVerbFilter: "*=get,*=update,secret=get"},
+			IsValid: true,
+			Message: "verb filter",
+		},
+		{
+			Configs: map[string]string{flagVerbFilter: "secret"},
+			IsValid: false,
+			Message: "verb filter entry without a verb",
+		},
 	}
 
 	test.ExerciseTestCases(t, configurationSchema, func(v *viper.Viper) error {
@@ -23,3 +116,29 @@ func TestConfigs(t *testing.T) {
 		return err
 	}, testCases)
 }
+
+// TestGetConfig_RequestTimeout tests that the request timeout ends up in the REST config of the connector.
+func TestGetConfig_RequestTimeout(t *testing.T) {
+	t.Setenv("KUBECONFIG", filepath.Join(t.TempDir(), "missing"))
+
+	tests := map[string]time.Duration{
+		"30s":   30 * time.Second,
+		"1m30s": 90 * time.Second,
+		"0":     0,
+	}
+	for value, want := range tests {
+		v := viper.New()
+		v.Set(flagAPIServer, "https://api.example.com:6443")
+		v.Set(flagTimeout, value)
+		opt, err := GetConfig(v)
+		require.NoError(t, err, value)
+		restConfig, err := opt.ToRESTConfig()
+		require.NoError(t, err, value)
+		assert.Equal(t, want, restConfig.Timeout, value)
+	}
+
+	v := viper.New()
+	v.Set(flagTimeout, "30")
+	_, err := GetConfig(v)
+	assert.ErrorContains(t, err, "missing a time unit, e.g. 30s")
+}
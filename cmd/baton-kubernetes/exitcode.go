@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/conductorone/baton-kubernetes/pkg/connector"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Exit codes of one-shot runs, so that schedulers like Kubernetes CronJobs can tell failures apart.
+const (
+	exitCodeOK                = 0
+	exitCodeFailure           = 1
+	exitCodeUnauthorized      = 2
+	exitCodePermissionDenied  = 3
+	exitCodePartialSync       = 4
+	exitCodeConfigDrift       = 5
+	exitCodeContractViolation = 6
+)
+
+// exitCode returns the exit code for the error a run failed with. The connector's typed errors are matched
+// directly, or by their gRPC code once they have crossed the connector service boundary.
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return exitCodeOK
+	case errors.Is(err, connector.ErrUnauthorized):
+		return exitCodeUnauthorized
+	case errors.Is(err, connector.ErrForbidden), errors.Is(err, connector.ErrEmptySync):
+		return exitCodePermissionDenied
+	case errors.Is(err, connector.ErrPartialSync):
+		return exitCodePartialSync
+	case errors.Is(err, ErrConfigDrift):
+		return exitCodeConfigDrift
+	case errors.Is(err, connector.ErrContractViolation):
+		return exitCodeContractViolation
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		return exitCodeFailure
+	}
+	switch st.Code() {
+	case codes.Unauthenticated:
+		return exitCodeUnauthorized
+	case codes.PermissionDenied:
+		return exitCodePermissionDenied
+	case codes.DataLoss:
+		return exitCodePartialSync
+	default:
+		return exitCodeFailure
+	}
+}
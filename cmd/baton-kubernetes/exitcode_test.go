@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/conductorone/baton-kubernetes/pkg/connector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// overConnectorService simulates an error returned by the connector service to the sync running in the
+// parent process, which only keeps its gRPC status.
+func overConnectorService(err error) error {
+	return fmt.Errorf("sync failed: %w", status.Convert(fmt.Errorf("error: listing resources failed: %w", err)).Err())
+}
+
+func TestExitCode(t *testing.T) {
+	testCases := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "success", err: nil, want: exitCodeOK},
+		{name: "generic failure", err: errors.New("boom"), want: exitCodeFailure},
+		{name: "unauthorized", err: fmt.Errorf("%w: token expired", connector.ErrUnauthorized), want: exitCodeUnauthorized},
+		{name: "forbidden", err: fmt.Errorf("%w: can't list pods", connector.ErrForbidden), want: exitCodePermissionDenied},
+		{name: "empty sync", err: fmt.Errorf("%w: no namespaces", connector.ErrEmptySync), want: exitCodePermissionDenied},
+		{name: "partial sync", err: fmt.Errorf("%w: namespaces restricted", connector.ErrPartialSync), want: exitCodePartialSync},
+		{name: "config drift", err: fmt.Errorf("%w: 1 options differ", ErrConfigDrift), want: exitCodeConfigDrift},
+		{name: "contract violation", err: fmt.Errorf("%w: 1 violations", connector.ErrContractViolation), want: exitCodeContractViolation},
+		{name: "remote generic failure", err: overConnectorService(errors.New("boom")), want: exitCodeFailure},
+		{name: "remote unauthorized", err: overConnectorService(connector.ErrUnauthorized), want: exitCodeUnauthorized},
+		{name: "remote forbidden", err: overConnectorService(connector.ErrForbidden), want: exitCodePermissionDenied},
+		{name: "remote empty sync", err: overConnectorService(connector.ErrEmptySync), want: exitCodePermissionDenied},
+		{name: "remote partial sync", err: overConnectorService(connector.ErrPartialSync), want: exitCodePartialSync},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, exitCode(tc.err))
+		})
+	}
+}
+
+// runMainEnv makes the test binary run the command instead of the tests, with the arguments it's given.
+const runMainEnv = "BATON_KUBERNETES_TEST_RUN_MAIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(runMainEnv) != "" {
+		os.Args = append([]string{"baton-kubernetes"}, strings.Fields(os.Getenv(runMainEnv))...)
+		main()
+		os.Exit(exitCodeOK)
+	}
+	os.Exit(m.Run())
+}
+
+// runCommand runs the command with the arguments in a subprocess and returns its exit code.
+func runCommand(t *testing.T, args ...string) int {
+	t.Helper()
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), runMainEnv+"="+strings.Join(args, " "))
+	cmd.Dir = t.TempDir()
+	out, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	require.NoError(t, err, string(out))
+	return exitCodeOK
+}
+
+// statusAPIServerKubeconfig starts an API server answering every request with the HTTP status code and returns
+// the path of a kubeconfig for it.
+func statusAPIServerKubeconfig(t *testing.T, code int) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(metav1.Status{
+			TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+			Status:   metav1.StatusFailure,
+			Code:     int32(code),
+			Reason:   metav1.StatusReason(http.StatusText(code)),
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	path := filepath.Join(t.TempDir(), "kubeconfig")
+	kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: %s
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+current-context: test
+users:
+- name: test
+  user:
+    token: test
+`, server.URL)
+	require.NoError(t, os.WriteFile(path, []byte(kubeconfig), 0o600))
+	return path
+}
+
+// TestExitCode_Command tests the exit code of the command when the cluster rejects its credentials, when it
+// isn't allowed to read the cluster, and when it fails otherwise.
+func TestExitCode_Command(t *testing.T) {
+	unauthorized := statusAPIServerKubeconfig(t, http.StatusUnauthorized)
+	assert.Equal(t, exitCodeUnauthorized, runCommand(t, "--kubeconfig", unauthorized, "--smoke-test"))
+
+	forbidden := statusAPIServerKubeconfig(t, http.StatusForbidden)
+	assert.Equal(t, exitCodePermissionDenied, runCommand(t, "--kubeconfig", forbidden, "--smoke-test"))
+
+	assert.Equal(t, exitCodeFailure, runCommand(t, "--kubeconfig", forbidden, "--smoke-test", "--output", "yaml"))
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/conductorone/baton-kubernetes/pkg/connector"
+)
+
+// explainPrincipal writes the roles, bindings and permissions of the principal given as <type>:<id>.
+func explainPrincipal(ctx context.Context, k *connector.Kubernetes, principal string, w io.Writer) error {
+	id, err := connector.ParsePrincipal(principal)
+	if err != nil {
+		return fmt.Errorf("invalid --%s: %w", flagExplainPrincipal, err)
+	}
+	explanation, err := k.ExplainPrincipal(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to explain %s: %w", principal, err)
+	}
+	return explanation.Write(w)
+}
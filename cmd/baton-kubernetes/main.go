@@ -3,14 +3,17 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/conductorone/baton-kubernetes/pkg/connector"
 	"github.com/conductorone/baton-sdk/pkg/config"
 	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
 	"github.com/conductorone/baton-sdk/pkg/field"
+	"github.com/conductorone/baton-sdk/pkg/logging"
 	"github.com/conductorone/baton-sdk/pkg/types"
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
 )
@@ -20,35 +23,113 @@ var version = "dev"
 func main() {
 	ctx := context.Background()
 
-	_, cmd, err := config.DefineConfiguration(
-		ctx,
-		"baton-kubernetes",
-		getConnector,
-		field.Configuration{
-			Fields:      getConfigurationFields(),
-			Constraints: getFieldRelationships(),
-		},
-	)
+	schema := field.Configuration{
+		Fields:      getConfigurationFields(),
+		Constraints: getFieldRelationships(),
+	}
+	v, cmd, err := config.DefineConfiguration(ctx, "baton-kubernetes", getConnector, schema)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err.Error())
 		os.Exit(1)
 	}
 
 	cmd.Version = version
+	withOneShotModes(ctx, cmd, v, schema)
 
 	err = cmd.Execute()
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err.Error())
-		os.Exit(1)
+		os.Exit(exitCode(err))
+	}
+}
+
+// withOneShotModes runs the one-shot mode the flags select instead of the SDK runner of the main command:
+// writing the baseline, explaining a principal, the smoke test or the self-check. Their errors are returned by the
+// command like those of the runner.
+func withOneShotModes(ctx context.Context, cmd *cobra.Command, v *viper.Viper, schema field.Configuration) {
+	runConnector := cmd.RunE
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if err := v.BindPFlags(cmd.Flags()); err != nil {
+			return err
+		}
+		if !oneShotMode(v) {
+			return runConnector(cmd, args)
+		}
+
+		runCtx, err := logging.Init(ctx,
+			logging.WithLogFormat(v.GetString("log-format")),
+			logging.WithLogLevel(v.GetString("log-level")))
+		if err != nil {
+			return err
+		}
+		if err := field.Validate(schema, v); err != nil {
+			return err
+		}
+		return runOneShotMode(runCtx, v, os.Stdout)
+	}
+}
+
+// oneShotMode reports whether the flags select a one-shot mode.
+func oneShotMode(v *viper.Viper) bool {
+	return (v.GetBool(flagWriteBaseline) && normalizedPath(v, flagBaselineConfig) != "") ||
+		v.GetString(flagExplainPrincipal) != "" ||
+		v.GetBool(flagSmokeTest) ||
+		v.GetBool(flagSelfCheck)
+}
+
+// runOneShotMode runs the one-shot mode the flags select, writing its output to w.
+func runOneShotMode(ctx context.Context, v *viper.Viper, w io.Writer) error {
+	opts := getConnectorOptions(v)
+
+	// Writing the baseline doesn't connect to the cluster
+	if path := normalizedPath(v, flagBaselineConfig); path != "" && v.GetBool(flagWriteBaseline) {
+		return writeBaseline(path, opts)
+	}
+
+	cb, err := newConnector(ctx, v, opts)
+	if err != nil {
+		return err
+	}
+	switch {
+	case v.GetString(flagExplainPrincipal) != "":
+		return explainPrincipal(ctx, cb, v.GetString(flagExplainPrincipal), w)
+	case v.GetBool(flagSmokeTest):
+		return smokeTest(ctx, cb, v.GetString(flagOutput), w)
+	default:
+		return selfCheck(ctx, cb, v.GetString(flagOutput), w)
 	}
 }
 
 func getConnector(ctx context.Context, v *viper.Viper) (types.ConnectorServer, error) {
+	l := ctxzap.Extract(ctx)
+	cb, err := newConnector(ctx, v, getConnectorOptions(v))
+	if err != nil {
+		return nil, err
+	}
+
+	connector, err := connectorbuilder.NewConnector(ctx, cb)
+	if err != nil {
+		l.Error("error creating connector", zap.Error(err))
+		return nil, err
+	}
+	return connector, nil
+}
+
+// newConnector creates the connector for the configured cluster with the options, once the configuration has
+// been compared with the baseline.
+func newConnector(ctx context.Context, v *viper.Viper, opts []connector.ConnectorOption) (*connector.Kubernetes, error) {
 	l := ctxzap.Extract(ctx)
 	opt, err := GetConfig(v)
 	if err != nil {
 		return nil, err
 	}
+
+	// The configuration is compared with the baseline before connecting
+	if path := normalizedPath(v, flagBaselineConfig); path != "" {
+		if err := checkBaseline(ctx, path, opts, v.GetString(flagClientID) != ""); err != nil {
+			return nil, err
+		}
+	}
 	restConfig, err := opt.ToRESTConfig()
 	if err != nil {
 		l.Error("error creating rest config", zap.Error(err))
@@ -61,15 +142,10 @@ func getConnector(ctx context.Context, v *viper.Viper) (types.ConnectorServer, e
 		return nil, fmt.Errorf("failed to create Kubernetes REST config: unexpectedly got nil config")
 	}
 
-	cb, err := connector.New(ctx, restConfig)
+	cb, err := connector.New(ctx, restConfig, opts...)
 	if err != nil {
 		l.Error("error creating connector", zap.Error(err))
 		return nil, err
 	}
-	connector, err := connectorbuilder.NewConnector(ctx, cb)
-	if err != nil {
-		l.Error("error creating connector", zap.Error(err))
-		return nil, err
-	}
-	return connector, nil
+	return cb, nil
 }
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// envVarRef matches the $NAME, ${NAME} and %NAME% environment variable references in paths.
+var envVarRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}|\$([A-Za-z_][A-Za-z0-9_]*)|%([A-Za-z_][A-Za-z0-9_()]*)%`)
+
+// normalizePath cleans up a path given in a flag, environment variable or config file: it strips surrounding
+// whitespace and quotes left by shells and env files, expands a leading ~ to the home directory and $NAME,
+// ${NAME} and %NAME% environment variables, and cleans the result with the separators of the OS, so that
+// C:/Users/me/.kube/config is read as C:\Users\me\.kube\config on Windows. References to unset variables are
+// kept as is, as are other $ and % characters, like those of Windows administrative shares.
+func normalizePath(path string) (string, error) {
+	path = strings.TrimSpace(path)
+	if len(path) >= 2 && (path[0] == '"' || path[0] == '\'') && path[len(path)-1] == path[0] {
+		path = strings.TrimSpace(path[1 : len(path)-1])
+	}
+	if path == "" {
+		return "", nil
+	}
+
+	path = envVarRef.ReplaceAllStringFunc(path, func(ref string) string {
+		match := envVarRef.FindStringSubmatch(ref)
+		name := match[1] + match[2] + match[3]
+		if value, ok := os.LookupEnv(name); ok {
+			return value
+		}
+		return ref
+	})
+
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, "~"+string(filepath.Separator)) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to expand ~ in %q: %w", path, err)
+		}
+		path = filepath.Join(home, path[1:])
+	}
+
+	return filepath.Clean(filepath.FromSlash(path)), nil
+}
+
+// checkFile checks that the file given in a flag can be read, telling a missing file from one the connector
+// isn't allowed to read.
+func checkFile(flag, path string) error {
+	info, err := os.Stat(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return fmt.Errorf("--%s file %s does not exist", flag, path)
+	case errors.Is(err, fs.ErrPermission):
+		return fmt.Errorf("--%s file %s is not accessible, permission denied", flag, path)
+	case err != nil:
+		return fmt.Errorf("error accessing --%s file %s: %w", flag, path, err)
+	case info.IsDir():
+		return fmt.Errorf("--%s %s is a directory, not a file", flag, path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrPermission) {
+			return fmt.Errorf("--%s file %s is not readable, permission denied", flag, path)
+		}
+		return fmt.Errorf("error opening --%s file %s: %w", flag, path, err)
+	}
+	return f.Close()
+}
+
+// checkDir checks that the directory given in a flag is usable: it may not exist yet, as it's created when
+// first written, but mustn't be a file or inaccessible.
+func checkDir(flag, path string) error {
+	info, err := os.Stat(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil
+	case errors.Is(err, fs.ErrPermission):
+		return fmt.Errorf("--%s directory %s is not accessible, permission denied", flag, path)
+	case err != nil:
+		return fmt.Errorf("error accessing --%s directory %s: %w", flag, path, err)
+	case !info.IsDir():
+		return fmt.Errorf("--%s %s is a file, not a directory", flag, path)
+	}
+	return nil
+}
+
+// normalizedPath returns the normalized path of a flag. GetConfig has already rejected paths that can't be
+// normalized, so the raw value is only returned when it wasn't called.
+func normalizedPath(v *viper.Viper, flag string) string {
+	path, err := normalizePath(v.GetString(flag))
+	if err != nil {
+		return v.GetString(flag)
+	}
+	return path
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizePath(t *testing.T) {
+	home := t.TempDir()
+	// os.UserHomeDir reads HOME on Unix and USERPROFILE on Windows
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("KUBE_DIR", filepath.Join(home, "kube"))
+	t.Setenv("KUBE_DIR_UNSET", "")
+	require.NoError(t, os.Unsetenv("KUBE_DIR_UNSET"))
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{name: "empty", path: "  ", want: ""},
+		{name: "plain", path: "/etc/kube/config", want: filepath.Join(string(filepath.Separator), "etc", "kube", "config")},
+		{name: "quoted", path: ` "/etc/kube/config" `, want: filepath.Join(string(filepath.Separator), "etc", "kube", "config")},
+		{name: "home", path: "~", want: home},
+		{name: "home relative", path: "~/.kube/config", want: filepath.Join(home, ".kube", "config")},
+		{name: "dollar variable", path: "$KUBE_DIR/config", want: filepath.Join(home, "kube", "config")},
+		{name: "braced variable", path: "${KUBE_DIR}/config", want: filepath.Join(home, "kube", "config")},
+		{name: "percent variable", path: "%KUBE_DIR%/config", want: filepath.Join(home, "kube", "config")},
+		{name: "unset variable", path: "$KUBE_DIR_UNSET/config", want: filepath.Join("$KUBE_DIR_UNSET", "config")},
+		{name: "unclean", path: "kube//./cache/../config", want: filepath.Join("kube", "config")},
+		{name: "user home not expanded", path: "~alice/config", want: filepath.Join("~alice", "config")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizePath(tt.path)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCheckFile(t *testing.T) {
+	dir := t.TempDir()
+	kubeconfig := filepath.Join(dir, "config")
+	require.NoError(t, os.WriteFile(kubeconfig, []byte("apiVersion: v1\n"), 0o600))
+
+	assert.NoError(t, checkFile(flagKubeconfig, kubeconfig))
+	assert.ErrorContains(t, checkFile(flagKubeconfig, filepath.Join(dir, "missing")), "does not exist")
+	assert.ErrorContains(t, checkFile(flagKubeconfig, dir), "is a directory")
+
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		t.Skip("file modes don't restrict access on Windows or for root")
+	}
+	require.NoError(t, os.Chmod(kubeconfig, 0o200))
+	assert.ErrorContains(t, checkFile(flagKubeconfig, kubeconfig), "permission denied")
+}
+
+func TestCheckDir(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	require.NoError(t, os.WriteFile(file, nil, 0o600))
+
+	assert.NoError(t, checkDir(flagCacheDir, dir))
+	assert.NoError(t, checkDir(flagCacheDir, filepath.Join(dir, "missing")))
+	assert.ErrorContains(t, checkDir(flagCacheDir, file), "is a file")
+}
+
+// TestGetConfig_Paths tests that the kubeconfig and cache directory are normalized before being used.
+func TestGetConfig_Paths(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	require.NoError(t, os.Mkdir(filepath.Join(home, ".kube"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(home, ".kube", "config"), []byte("apiVersion: v1\n"), 0o600))
+
+	v := viper.New()
+	v.Set(flagKubeconfig, "~/.kube/config")
+	v.Set(flagCacheDir, "~/.cache/baton")
+	cfg, err := GetConfig(v)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(home, ".kube", "config"), *cfg.KubeConfig)
+	assert.Equal(t, filepath.Join(home, ".cache", "baton"), *cfg.CacheDir)
+	assert.Equal(t, filepath.Join(home, ".cache", "baton"), normalizedPath(v, flagCacheDir))
+
+	v.Set(flagKubeconfig, "~/.kube/missing")
+	_, err = GetConfig(v)
+	assert.ErrorContains(t, err, "does not exist")
+}
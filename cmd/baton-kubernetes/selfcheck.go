@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/conductorone/baton-kubernetes/pkg/connector"
+)
+
+// selfCheck runs a full sync of the connector, checks its output against the invariants of the baton SDK and
+// writes the report in the output format. It returns an error wrapping connector.ErrContractViolation if any
+// resource, entitlement or grant violates them.
+func selfCheck(ctx context.Context, k *connector.Kubernetes, output string, w io.Writer) error {
+	if err := validateOutput(output); err != nil {
+		return err
+	}
+	report, err := k.SelfCheck(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to run self-check: %w", err)
+	}
+	if output == outputJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	} else {
+		err = report.Write(w)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write self-check report: %w", err)
+	}
+	return report.Err()
+}
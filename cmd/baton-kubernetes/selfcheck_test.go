@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/conductorone/baton-kubernetes/pkg/connector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSelfCheck(t *testing.T) {
+	_, client := smokeTestConnector(t)
+	_, err := client.CoreV1().Namespaces().Create(context.Background(),
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "payments"}}, metav1.CreateOptions{})
+	require.NoError(t, err)
+	// The roles are listed as the children of the namespace, after the cluster roles the empty sync guard checks
+	_, err = client.RbacV1().ClusterRoles().Create(context.Background(),
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "view"}}, metav1.CreateOptions{})
+	require.NoError(t, err)
+	k, err := connector.NewForClient(client)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, selfCheck(context.Background(), k, outputText, &out))
+	assert.Contains(t, out.String(), "No contract violations")
+
+	out.Reset()
+	require.NoError(t, selfCheck(context.Background(), k, outputJSON, &out))
+	var report struct {
+		Resources  int               `json:"resources"`
+		Grants     int               `json:"grants"`
+		Violations []json.RawMessage `json:"violations"`
+	}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &report))
+	assert.NotZero(t, report.Resources)
+	assert.NotZero(t, report.Grants)
+	assert.Empty(t, report.Violations)
+
+	assert.ErrorContains(t, selfCheck(context.Background(), k, "yaml", &out), "invalid --output")
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// validateAPIServer checks the --server value, a URL or host[:port] as accepted by kubectl, reporting
+// mistakes such as unbracketed IPv6 literals before client-go turns them into an opaque connection error.
+// The value is returned trimmed but otherwise as given, since client-go handles bracketed IPv6 hosts.
+func validateAPIServer(server string) (string, error) {
+	server = strings.TrimSpace(server)
+
+	// client-go defaults the scheme of bare host[:port] values to https
+	raw := server
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		if strings.Count(server, ":") > 1 && !strings.Contains(server, "[") {
+			return "", fmt.Errorf("invalid --%s %q: IPv6 addresses must be enclosed in brackets, e.g. https://[fd00::1]:6443", flagAPIServer, server)
+		}
+		return "", fmt.Errorf("invalid --%s %q: %w", flagAPIServer, server, err)
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return "", fmt.Errorf("invalid --%s %q: unsupported scheme %q, use https", flagAPIServer, server, u.Scheme)
+	}
+	if u.Host == "" || u.Hostname() == "" {
+		return "", fmt.Errorf("invalid --%s %q: missing host", flagAPIServer, server)
+	}
+	if u.User != nil {
+		return "", fmt.Errorf("invalid --%s %q: credentials in the URL aren't supported, use --%s or --%s", flagAPIServer, server, flagBearerToken, flagUsername)
+	}
+
+	// An unbracketed IPv6 literal parses with its last group taken as the port. Bracketed literals are checked
+	// by url.Parse.
+	if !strings.HasPrefix(u.Host, "[") && strings.Count(u.Host, ":") > 1 {
+		return "", fmt.Errorf("invalid --%s %q: IPv6 addresses must be enclosed in brackets, e.g. https://[fd00::1]:6443", flagAPIServer, server)
+	}
+
+	if port := u.Port(); port != "" {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return "", fmt.Errorf("invalid --%s %q: port %q is out of range", flagAPIServer, server, port)
+		}
+	} else if strings.HasSuffix(u.Host, ":") {
+		return "", fmt.Errorf("invalid --%s %q: empty port", flagAPIServer, server)
+	}
+
+	return server, nil
+}
+
+// normalizeTLSServerName returns the --tls-server-name value as Go's TLS stack expects it: a host name or an
+// IP address without brackets, as IPv6 addresses are often copied from bracketed server URLs.
+func normalizeTLSServerName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if strings.HasPrefix(name, "[") {
+		host, rest, ok := strings.Cut(name[1:], "]")
+		if !ok || rest != "" {
+			return "", fmt.Errorf("invalid --%s %q: expected a host name or IP address without a port", flagTLSServerName, name)
+		}
+		if ip := net.ParseIP(host); ip == nil || ip.To4() != nil {
+			return "", fmt.Errorf("invalid --%s %q: %q isn't an IPv6 address", flagTLSServerName, name, host)
+		}
+		return host, nil
+	}
+	if strings.Contains(name, "/") || (strings.Contains(name, ":") && net.ParseIP(name) == nil) {
+		return "", fmt.Errorf("invalid --%s %q: expected a host name or IP address without a scheme or port", flagTLSServerName, name)
+	}
+	return name, nil
+}
+
+// parseRequestTimeout parses the --request-timeout value as a duration with a unit, or "0" for no timeout.
+// client-go would take a bare number as seconds, which is rarely what a typo like "30" meant.
+func parseRequestTimeout(timeout string) (time.Duration, error) {
+	timeout = strings.TrimSpace(timeout)
+	if timeout == "0" {
+		return 0, nil
+	}
+	if _, err := strconv.ParseInt(timeout, 10, 64); err == nil {
+		return 0, fmt.Errorf("invalid --%s %q: missing a time unit, e.g. %ss, or 0 for no timeout", flagTimeout, timeout, timeout)
+	}
+	d, err := time.ParseDuration(timeout)
+	if err != nil {
+		return 0, fmt.Errorf("invalid --%s %q: expected a duration such as 30s or 2m, or 0 for no timeout", flagTimeout, timeout)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("invalid --%s %q: the timeout can't be negative", flagTimeout, timeout)
+	}
+	return d, nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateAPIServer(t *testing.T) {
+	valid := []string{
+		"https://api.example.com:6443",
+		"api.example.com:6443",
+		"api.example.com",
+		"https://10.0.0.1:6443",
+		"https://[fd00::1]:6443",
+		"https://[fd00::1]",
+		"[fd00::1]:6443",
+		"https://[fe80::1%25eth0]:6443",
+		"https://[::1]:6443/k8s/clusters/c-abc",
+		"http://localhost:8080",
+	}
+	for _, server := range valid {
+		got, err := validateAPIServer(server)
+		require.NoError(t, err, server)
+		assert.Equal(t, server, got)
+	}
+
+	got, err := validateAPIServer("  https://[fd00::1]:6443 \n")
+	require.NoError(t, err)
+	assert.Equal(t, "https://[fd00::1]:6443", got)
+
+	invalid := map[string]string{
+		"fd00::1":                   "must be enclosed in brackets",
+		"fd00::1:6443":              "must be enclosed in brackets",
+		"https://fd00::1:6443":      "must be enclosed in brackets",
+		"https://[fd00::zz]:6443":   "invalid host",
+		"https://[10.0.0.1]:6443":   "invalid IP-literal",
+		"https://[fd00::1:6443":     "invalid --server",
+		"https://[fd00::1]:70000":   "out of range",
+		"https://[fd00::1]:":        "empty port",
+		"https://api.example.com:0": "out of range",
+		"ftp://api.example.com":     "unsupported scheme",
+		"https://":                  "missing host",
+		"https://admin:pw@api:6443": "credentials in the URL",
+	}
+	for server, message := range invalid {
+		_, err := validateAPIServer(server)
+		require.ErrorContains(t, err, message, server)
+	}
+}
+
+func TestNormalizeTLSServerName(t *testing.T) {
+	for name, want := range map[string]string{
+		"api.example.com": "api.example.com",
+		"10.0.0.1":        "10.0.0.1",
+		"fd00::1":         "fd00::1",
+		"[fd00::1]":       "fd00::1",
+	} {
+		got, err := normalizeTLSServerName(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, want, got, name)
+	}
+
+	for _, name := range []string{"[fd00::1]:6443", "[fd00::zz]", "[fd00::1", "api.example.com:6443", "https://api.example.com"} {
+		_, err := normalizeTLSServerName(name)
+		assert.Error(t, err, name)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/conductorone/baton-kubernetes/pkg/connector"
+)
+
+// Formats of the output of one-shot commands.
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// validateOutput checks the --output value.
+func validateOutput(output string) error {
+	switch output {
+	case outputText, outputJSON:
+		return nil
+	default:
+		return fmt.Errorf("invalid --%s %q: must be %s or %s", flagOutput, output, outputText, outputJSON)
+	}
+}
+
+// smokeTest runs the smoke test of the connector and writes its report in the output format. It returns the
+// error of the first failed step, so that the exit code tells authentication from permission failures.
+func smokeTest(ctx context.Context, k *connector.Kubernetes, output string, w io.Writer) error {
+	if err := validateOutput(output); err != nil {
+		return err
+	}
+	report := k.SmokeTest(ctx)
+	if err := writeSmokeTestReport(report, output, w); err != nil {
+		return fmt.Errorf("failed to write smoke test report: %w", err)
+	}
+	return report.Err()
+}
+
+// writeSmokeTestReport writes the smoke test report as text or JSON.
+func writeSmokeTestReport(report *connector.SmokeTestReport, output string, w io.Writer) error {
+	if output != outputJSON {
+		return report.Write(w)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/conductorone/baton-kubernetes/pkg/connector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// smokeTestConnector returns a connector to a fake cluster with a bound role, allowing the connector every
+// access.
+func smokeTestConnector(t *testing.T) (*connector.Kubernetes, *fake.Clientset) {
+	t.Helper()
+	client := fake.NewSimpleClientset(
+		&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "reader"}},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "reader"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: connector.RBACAPIGroup, Kind: connector.RoleRefKindRole, Name: "reader"},
+			Subjects:   []rbacv1.Subject{{Kind: connector.SubjectKindUser, APIGroup: connector.RBACAPIGroup, Name: "alice"}},
+		},
+	)
+	client.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview).DeepCopy()
+		review.Status.Allowed = true
+		return true, review, nil
+	})
+	k, err := connector.NewForClient(client)
+	require.NoError(t, err)
+	return k, client
+}
+
+func TestSmokeTest_JSON(t *testing.T) {
+	k, _ := smokeTestConnector(t)
+
+	var out bytes.Buffer
+	require.NoError(t, smokeTest(context.Background(), k, outputJSON, &out))
+
+	var report struct {
+		OK    bool `json:"ok"`
+		Steps []struct {
+			Name       string  `json:"name"`
+			DurationMS float64 `json:"durationMs"`
+			Items      int     `json:"items"`
+			Failure    string  `json:"failure"`
+		} `json:"steps"`
+	}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &report))
+	assert.True(t, report.OK)
+	require.Len(t, report.Steps, 6)
+	assert.Equal(t, connector.SmokeTestStepValidate, report.Steps[0].Name)
+	assert.Equal(t, connector.SmokeTestStepGrants, report.Steps[5].Name)
+	assert.Equal(t, 1, report.Steps[5].Items)
+}
+
+func TestSmokeTest_Text(t *testing.T) {
+	k, _ := smokeTestConnector(t)
+
+	var out bytes.Buffer
+	require.NoError(t, smokeTest(context.Background(), k, outputText, &out))
+	assert.Contains(t, out.String(), connector.SmokeTestStepRoleBindings)
+	assert.Contains(t, out.String(), "Smoke test passed")
+}
+
+// TestSmokeTest_ExitCode tests that a failed step is reported and fails with the exit code of its failure.
+func TestSmokeTest_ExitCode(t *testing.T) {
+	k, client := smokeTestConnector(t)
+	client.PrependReactor("list", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, k8serrors.NewUnauthorized("token expired")
+	})
+
+	var out bytes.Buffer
+	err := smokeTest(context.Background(), k, outputJSON, &out)
+	require.Error(t, err)
+	assert.Equal(t, exitCodeUnauthorized, exitCode(err))
+	assert.Contains(t, out.String(), `"failure": "unauthorized"`)
+}
+
+func TestSmokeTest_InvalidOutput(t *testing.T) {
+	k, _ := smokeTestConnector(t)
+
+	var out bytes.Buffer
+	err := smokeTest(context.Background(), k, "yaml", &out)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid --output")
+	assert.Empty(t, out.String())
+}
@@ -0,0 +1,240 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	"google.golang.org/protobuf/types/known/structpb"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Keys of the profile of aggregated ClusterRoles.
+const (
+	ProfileEffectiveRules  = "effectiveRules"
+	ProfileAggregatedFrom  = "aggregatedFrom"
+	ProfileRuleAPIGroups   = "apiGroups"
+	ProfileRuleResources   = "resources"
+	ProfileRuleVerbs       = "verbs"
+	ProfileRuleNames       = "resourceNames"
+	ProfileRuleNonResource = "nonResourceURLs"
+)
+
+// Grant metadata keys of the permission grants expanded from the rules of an aggregated ClusterRole.
+const (
+	// GrantMetadataAggregateRole is the aggregated ClusterRole the grant was expanded from.
+	GrantMetadataAggregateRole = "aggregateRole"
+	// GrantMetadataContributingRoles lists the ClusterRoles whose rules, aggregated, confer the grant.
+	GrantMetadataContributingRoles = "contributingRoles"
+)
+
+// clusterRoleAggregation is what an aggregated ClusterRole resolves to: the ClusterRoles its selectors
+// match, and the union of their rules.
+type clusterRoleAggregation struct {
+	from  []string
+	rules []rbacv1.PolicyRule
+	// ruleFrom are the names of the ClusterRoles contributing each rule, by policyRuleKey.
+	ruleFrom map[string][]string
+}
+
+// resolveAggregation returns the ClusterRoles the aggregation rule of a ClusterRole selects among the given
+// ones, sorted by name, and their merged rules without duplicates, as the aggregation controller computes
+// them. It returns nil for ClusterRoles without an aggregation rule.
+func resolveAggregation(clusterRole *rbacv1.ClusterRole, clusterRoles []rbacv1.ClusterRole) (*clusterRoleAggregation, error) {
+	if clusterRole.AggregationRule == nil {
+		return nil, nil
+	}
+
+	selectors := make([]labels.Selector, 0, len(clusterRole.AggregationRule.ClusterRoleSelectors))
+	for i := range clusterRole.AggregationRule.ClusterRoleSelectors {
+		selector, err := metav1.LabelSelectorAsSelector(&clusterRole.AggregationRule.ClusterRoleSelectors[i])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse aggregation rule selector of cluster role %s: %w", clusterRole.Name, err)
+		}
+		selectors = append(selectors, selector)
+	}
+
+	var contributors []*rbacv1.ClusterRole
+	for i := range clusterRoles {
+		candidate := &clusterRoles[i]
+		if candidate.Name == clusterRole.Name {
+			continue
+		}
+		set := labels.Set(candidate.Labels)
+		if slices.ContainsFunc(selectors, func(selector labels.Selector) bool { return selector.Matches(set) }) {
+			contributors = append(contributors, candidate)
+		}
+	}
+	sort.Slice(contributors, func(i, j int) bool { return contributors[i].Name < contributors[j].Name })
+
+	rv := &clusterRoleAggregation{
+		from:     make([]string, 0, len(contributors)),
+		ruleFrom: make(map[string][]string),
+	}
+	for _, contributor := range contributors {
+		rv.from = append(rv.from, contributor.Name)
+		for _, rule := range contributor.Rules {
+			key := policyRuleKey(rule)
+			if _, seen := rv.ruleFrom[key]; !seen {
+				rv.rules = append(rv.rules, rule)
+			}
+			if !slices.Contains(rv.ruleFrom[key], contributor.Name) {
+				rv.ruleFrom[key] = append(rv.ruleFrom[key], contributor.Name)
+			}
+		}
+	}
+	return rv, nil
+}
+
+// aggregatingClusterRoles returns the names of the aggregated ClusterRoles among the given ones that the
+// ClusterRole contributes its rules to, directly or through other aggregated ClusterRoles, like view through
+// edit to admin, sorted by name.
+func aggregatingClusterRoles(clusterRole *rbacv1.ClusterRole, clusterRoles []rbacv1.ClusterRole) ([]string, error) {
+	found := map[string]bool{clusterRole.Name: true}
+	var rv []string
+	pending := []*rbacv1.ClusterRole{clusterRole}
+	for len(pending) > 0 {
+		contributor := pending[0]
+		pending = pending[1:]
+		set := labels.Set(contributor.Labels)
+		for i := range clusterRoles {
+			candidate := &clusterRoles[i]
+			if candidate.AggregationRule == nil || found[candidate.Name] {
+				continue
+			}
+			for j := range candidate.AggregationRule.ClusterRoleSelectors {
+				selector, err := metav1.LabelSelectorAsSelector(&candidate.AggregationRule.ClusterRoleSelectors[j])
+				if err != nil {
+					return nil, fmt.Errorf("failed to parse aggregation rule selector of cluster role %s: %w", candidate.Name, err)
+				}
+				if selector.Matches(set) {
+					found[candidate.Name] = true
+					rv = append(rv, candidate.Name)
+					pending = append(pending, candidate)
+					break
+				}
+			}
+		}
+	}
+	sort.Strings(rv)
+	return rv, nil
+}
+
+// expandAggregatedRules is expandPolicyRules for an aggregated ClusterRole, recording in the metadata of each
+// grant the ClusterRole and the contributing ClusterRoles whose rules confer it, so that reviewers can trace a
+// permission of an aggregate back to the role it comes from. Rules no contributor has any more, until the
+// aggregation controller catches up, list no contributing roles.
+func expandAggregatedRules(
+	ctx context.Context,
+	client kubernetes.Interface,
+	principal *v2.Resource,
+	scope ruleScope,
+	rules []rbacv1.PolicyRule,
+	aggregation *clusterRoleAggregation,
+	opts ConnectorOpts,
+) ([]*v2.Grant, error) {
+	var rv []*v2.Grant
+	contributors := make(map[string][]string)
+	for _, rule := range rules {
+		grants, err := expandPolicyRules(ctx, client, principal, scope, []rbacv1.PolicyRule{rule}, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, g := range grants {
+			if _, seen := contributors[g.Id]; !seen {
+				rv = append(rv, g)
+			}
+			contributors[g.Id] = append(contributors[g.Id], aggregation.ruleFrom[policyRuleKey(rule)]...)
+		}
+	}
+
+	for _, g := range rv {
+		if err := withAggregation(principal.Id.Resource, uniqueSorted(contributors[g.Id]))(g); err != nil {
+			return nil, fmt.Errorf("failed to record aggregation in grant metadata: %w", err)
+		}
+	}
+	return rv, nil
+}
+
+// withAggregation records the aggregated ClusterRole a grant was expanded from and the ClusterRoles contributing
+// it in the grant metadata, merging them into the metadata set by earlier options.
+func withAggregation(aggregate string, contributors []string) grant.GrantOption {
+	return func(g *v2.Grant) error {
+		metadata := &v2.GrantMetadata{}
+		annos := annotations.Annotations(g.Annotations)
+		if _, err := annos.Pick(metadata); err != nil {
+			return err
+		}
+		if metadata.Metadata == nil {
+			metadata.Metadata = &structpb.Struct{}
+		}
+		if metadata.Metadata.Fields == nil {
+			metadata.Metadata.Fields = make(map[string]*structpb.Value)
+		}
+		list, err := structpb.NewList(stringsToInterfaces(contributors))
+		if err != nil {
+			return err
+		}
+		metadata.Metadata.Fields[GrantMetadataAggregateRole] = structpb.NewStringValue(aggregate)
+		metadata.Metadata.Fields[GrantMetadataContributingRoles] = structpb.NewListValue(list)
+		annos.Update(metadata)
+		g.Annotations = annos
+		return nil
+	}
+}
+
+// policyRuleKey returns a key identifying a rule by its contents.
+func policyRuleKey(rule rbacv1.PolicyRule) string {
+	return strings.Join([]string{
+		strings.Join(rule.APIGroups, ","),
+		strings.Join(rule.Resources, ","),
+		strings.Join(rule.Verbs, ","),
+		strings.Join(rule.ResourceNames, ","),
+		strings.Join(rule.NonResourceURLs, ","),
+	}, "|")
+}
+
+// addAggregationProfile records the resolved aggregation of a ClusterRole in its profile: the names of the
+// contributing ClusterRoles, and the effective rules as apiGroups/resources/verbs, with the resource names or
+// non-resource URLs of the rules limited to them.
+func addAggregationProfile(profile map[string]interface{}, aggregation *clusterRoleAggregation) {
+	if aggregation == nil {
+		return
+	}
+
+	profile[ProfileAggregatedFrom] = stringsToInterfaces(aggregation.from)
+
+	rules := make([]interface{}, 0, len(aggregation.rules))
+	for _, rule := range aggregation.rules {
+		entry := map[string]interface{}{
+			ProfileRuleAPIGroups: stringsToInterfaces(rule.APIGroups),
+			ProfileRuleResources: stringsToInterfaces(rule.Resources),
+			ProfileRuleVerbs:     stringsToInterfaces(rule.Verbs),
+		}
+		if len(rule.ResourceNames) > 0 {
+			entry[ProfileRuleNames] = stringsToInterfaces(rule.ResourceNames)
+		}
+		if len(rule.NonResourceURLs) > 0 {
+			entry[ProfileRuleNonResource] = stringsToInterfaces(rule.NonResourceURLs)
+		}
+		rules = append(rules, entry)
+	}
+	profile[ProfileEffectiveRules] = rules
+}
+
+// stringsToInterfaces converts a string slice to the []interface{} profiles are built from.
+func stringsToInterfaces(values []string) []interface{} {
+	rv := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		rv = append(rv, v)
+	}
+	return rv
+}
@@ -0,0 +1,238 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	rbacv1 "k8s.io/api/rbac/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// TestClusterRoleBuilderList_Aggregation tests that the profile of an aggregated ClusterRole lists the
+// ClusterRoles its selectors match and their merged rules.
+func TestClusterRoleBuilderList_Aggregation(t *testing.T) {
+	ctx := context.Background()
+	aggregateTo := func(name string) map[string]string {
+		return map[string]string{"rbac.example.com/aggregate-to-" + name: "true"}
+	}
+	client := fake.NewSimpleClientset(
+		&rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{Name: "monitoring"},
+			AggregationRule: &rbacv1.AggregationRule{ClusterRoleSelectors: []metav1.LabelSelector{
+				{MatchLabels: aggregateTo("monitoring")},
+			}},
+		},
+		&rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{Name: "monitoring-pods", Labels: aggregateTo("monitoring")},
+			Rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "list"}},
+				{APIGroups: []string{""}, Resources: []string{"configmaps"}, ResourceNames: []string{"dashboards"}, Verbs: []string{"get"}},
+			},
+		},
+		&rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{Name: "monitoring-metrics", Labels: aggregateTo("monitoring")},
+			Rules: []rbacv1.PolicyRule{
+				// Also granted by monitoring-pods, merged once
+				{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "list"}},
+				{NonResourceURLs: []string{"/metrics"}, Verbs: []string{"get"}},
+			},
+		},
+		&rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{Name: "unrelated", Labels: aggregateTo("admin")},
+			Rules:      []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"*"}}},
+		},
+	)
+	builder := newClusterRoleBuilder(client, newMockClusterRoleBindingProvider(), ConnectorOpts{DisableWildcardResources: true}, newSyncStats())
+
+	resources, _, _, err := builder.List(ctx, nil, &pagination.Token{})
+	require.NoError(t, err)
+	profiles := make(map[string]map[string]interface{})
+	for _, resource := range resources {
+		roleTrait, err := rs.GetRoleTrait(resource)
+		require.NoError(t, err)
+		profiles[resource.Id.Resource] = roleTrait.Profile.AsMap()
+	}
+
+	require.Contains(t, profiles, "monitoring")
+	profile := profiles["monitoring"]
+	assert.Equal(t, []interface{}{"monitoring-metrics", "monitoring-pods"}, profile[ProfileAggregatedFrom])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{
+			ProfileRuleAPIGroups: []interface{}{""},
+			ProfileRuleResources: []interface{}{"pods"},
+			ProfileRuleVerbs:     []interface{}{"get", "list"},
+		},
+		map[string]interface{}{
+			ProfileRuleAPIGroups:   []interface{}{},
+			ProfileRuleResources:   []interface{}{},
+			ProfileRuleVerbs:       []interface{}{"get"},
+			ProfileRuleNonResource: []interface{}{"/metrics"},
+		},
+		map[string]interface{}{
+			ProfileRuleAPIGroups: []interface{}{""},
+			ProfileRuleResources: []interface{}{"configmaps"},
+			ProfileRuleVerbs:     []interface{}{"get"},
+			ProfileRuleNames:     []interface{}{"dashboards"},
+		},
+	}, profile[ProfileEffectiveRules])
+
+	// ClusterRoles without an aggregation rule have no effective rules
+	assert.NotContains(t, profiles["monitoring-pods"], ProfileEffectiveRules)
+	assert.NotContains(t, profiles["monitoring-pods"], ProfileAggregatedFrom)
+}
+
+// TestClusterRoleBuilder_ClusterRolesCache tests that the ClusterRoles are listed once for every aggregated
+// ClusterRole of a sync, retrying transient errors, and listed again once the cache is reset.
+func TestClusterRoleBuilder_ClusterRolesCache(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "view"}},
+	)
+	calls := failListing(client, "clusterroles", k8serrors.NewInternalError(errors.New("etcd leader changed")))
+	builder := newClusterRoleBuilder(client, newMockClusterRoleBindingProvider(), ConnectorOpts{ListRetries: 1}, newSyncStats())
+
+	first, err := builder.cacheClusterRoles(ctx)
+	require.NoError(t, err)
+	require.Len(t, first, 1)
+	assert.Equal(t, 2, *calls)
+
+	_, err = client.RbacV1().ClusterRoles().Create(ctx, &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "edit"}}, metav1.CreateOptions{})
+	require.NoError(t, err)
+	cached, err := builder.cacheClusterRoles(ctx)
+	require.NoError(t, err)
+	assert.Len(t, cached, 1)
+
+	assert.Equal(t, 2, *calls)
+
+	// Refreshed once reset by the next sync
+	builder.clusterRoles.reset()
+	refreshed, err := builder.cacheClusterRoles(ctx)
+	require.NoError(t, err)
+	assert.Len(t, refreshed, 2)
+}
+
+// newAggregationTestBuilder returns a cluster role builder for an aggregated admin ClusterRole bound in the
+// team-a namespace, and a contributor granting read on secrets to it.
+func newAggregationTestBuilder(opts ConnectorOpts) *clusterRoleBuilder {
+	aggregateTo := map[string]string{"rbac.example.com/aggregate-to-admin": "true"}
+	secretsRead := rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}}
+	client := fake.NewSimpleClientset(
+		&rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{Name: "admin"},
+			AggregationRule: &rbacv1.AggregationRule{ClusterRoleSelectors: []metav1.LabelSelector{
+				{MatchLabels: aggregateTo},
+			}},
+			Rules: []rbacv1.PolicyRule{secretsRead},
+		},
+		&rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{Name: "secrets-reader", Labels: aggregateTo},
+			Rules:      []rbacv1.PolicyRule{secretsRead},
+		},
+	)
+	provider := newMockClusterRoleBindingProvider()
+	provider.roleBindings["admin"] = []rbacv1.RoleBinding{{
+		ObjectMeta: metav1.ObjectMeta{Name: "team-a-admins", Namespace: "team-a", ResourceVersion: "7"},
+		RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindClusterRole, Name: "admin"},
+		Subjects:   []rbacv1.Subject{{Kind: SubjectKindGroup, APIGroup: RBACAPIGroup, Name: "team-a-admins"}},
+	}}
+	return newClusterRoleBuilder(client, provider, opts, newSyncStats())
+}
+
+// TestClusterRoleBuilderGrants_AggregatedRules tests that the permission grants of an aggregated ClusterRole
+// record the aggregate and the ClusterRoles contributing them.
+func TestClusterRoleBuilderGrants_AggregatedRules(t *testing.T) {
+	ctx := context.Background()
+	builder := newAggregationTestBuilder(ConnectorOpts{})
+	admin := &v2.Resource{Id: &v2.ResourceId{ResourceType: ResourceTypeClusterRole.Id, Resource: "admin"}, DisplayName: "admin"}
+
+	grants, _, _, err := builder.Grants(ctx, admin, &pagination.Token{})
+	require.NoError(t, err)
+
+	var secretGrant *v2.Grant
+	for _, g := range grants {
+		if g.Entitlement.Id == "secret:*:get" {
+			secretGrant = g
+		}
+	}
+	require.NotNil(t, secretGrant)
+	metadata := &v2.GrantMetadata{}
+	annos := annotations.Annotations(secretGrant.Annotations)
+	ok, err := annos.Pick(metadata)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, map[string]interface{}{
+		GrantMetadataAggregateRole:     "admin",
+		GrantMetadataContributingRoles: []interface{}{"secrets-reader"},
+	}, metadata.Metadata.AsMap())
+}
+
+// TestClusterRoleBuilderGrants_InheritAggregatedBindings tests that a contributing ClusterRole is granted to the
+// subjects of the aggregate's bindings, in their namespaces, only when enabled.
+func TestClusterRoleBuilderGrants_InheritAggregatedBindings(t *testing.T) {
+	ctx := context.Background()
+	contributor := &v2.Resource{Id: &v2.ResourceId{ResourceType: ResourceTypeClusterRole.Id, Resource: "secrets-reader"}, DisplayName: "secrets-reader"}
+	memberships := func(grants []*v2.Grant) []*v2.Grant {
+		var rv []*v2.Grant
+		for _, g := range grants {
+			if g.Principal.Id.ResourceType != ResourceTypeClusterRole.Id {
+				rv = append(rv, g)
+			}
+		}
+		return rv
+	}
+
+	grants, _, _, err := newAggregationTestBuilder(ConnectorOpts{}).Grants(ctx, contributor, &pagination.Token{})
+	require.NoError(t, err)
+	assert.Empty(t, memberships(grants))
+
+	builder := newAggregationTestBuilder(ConnectorOpts{InheritAggregatedBindings: true})
+	grants, _, _, err = builder.Grants(ctx, contributor, &pagination.Token{})
+	require.NoError(t, err)
+	inherited := memberships(grants)
+	require.Len(t, inherited, 1)
+	assert.Equal(t, "cluster_role:secrets-reader:team-a:member", inherited[0].Entitlement.Id)
+	assert.Equal(t, "team-a-admins", inherited[0].Principal.Id.Resource)
+
+	ref, ok, err := bindingRefFromGrant(inherited[0])
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "admin", ref.viaAggregate)
+	assert.Equal(t, "team-a-admins", ref.name)
+
+	_, err = builder.Revoke(ctx, inherited[0])
+	assert.ErrorContains(t, err, "inherited from aggregated cluster role admin")
+}
+
+func TestAggregatingClusterRoles(t *testing.T) {
+	aggregated := func(name, selects string, labels map[string]string) rbacv1.ClusterRole {
+		return rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+			AggregationRule: &rbacv1.AggregationRule{ClusterRoleSelectors: []metav1.LabelSelector{
+				{MatchLabels: map[string]string{selects: "true"}},
+			}},
+		}
+	}
+	clusterRoles := []rbacv1.ClusterRole{
+		aggregated("admin", "aggregate-to-admin", nil),
+		aggregated("edit", "aggregate-to-edit", map[string]string{"aggregate-to-admin": "true"}),
+		aggregated("view", "aggregate-to-view", map[string]string{"aggregate-to-edit": "true"}),
+		aggregated("monitoring", "aggregate-to-monitoring", nil),
+	}
+	contributor := &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{
+		Name:   "crd-viewer",
+		Labels: map[string]string{"aggregate-to-view": "true"},
+	}}
+
+	aggregates, err := aggregatingClusterRoles(contributor, clusterRoles)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"admin", "edit", "view"}, aggregates)
+}
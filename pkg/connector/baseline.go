@@ -0,0 +1,211 @@
+package connector
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// configBaselineVersion is bumped whenever fields of ConfigBaseline change meaning, so that baselines written
+// by other versions are reported as drifted.
+const configBaselineVersion = 1
+
+// ConfigBaseline is the operating configuration of the connector, as pinned by platform teams to detect flag
+// changes in a deployment: the synced resource types, the filters, the wildcard settings and the other options
+// changing what a sync produces. Lists are sorted, so that the same configuration always serializes the same.
+// Secrets, local paths and the extension points set from code aren't part of it.
+type ConfigBaseline struct {
+	Version int `json:"version"`
+
+	SyncResources                  []string            `json:"syncResources"`
+	LabelTags                      []string            `json:"labelTags"`
+	RedactAnnotationKeys           []string            `json:"redactAnnotationKeys"`
+	SkipMissingNamedResources      bool                `json:"skipMissingNamedResources"`
+	IncludeSystemSubjects          bool                `json:"includeSystemSubjects"`
+	SkipSystemClusterRoles         bool                `json:"skipSystemClusterRoles"`
+	SeparateSystemUsers            bool                `json:"separateSystemUsers"`
+	ExpandServiceAccountGroups     bool                `json:"expandServiceAccountGroups"`
+	SkipDefaultServiceAccounts     bool                `json:"skipDefaultServiceAccounts"`
+	AllowEmptySync                 bool                `json:"allowEmptySync"`
+	VerifyCoverage                 bool                `json:"verifyCoverage"`
+	AllowPartialSync               bool                `json:"allowPartialSync"`
+	Redact                         bool                `json:"redact"`
+	RedactPreservePrefixes         []string            `json:"redactPreservePrefixes"`
+	RemoteTokenSecret              string              `json:"remoteTokenSecret"`
+	NamespaceEntitlementSelector   string              `json:"namespaceEntitlementSelector"`
+	DropUnselectedNamespaceGrants  bool                `json:"dropUnselectedNamespaceGrants"`
+	CompactClusterRoleEntitlements bool                `json:"compactClusterRoleEntitlements"`
+	DisableWildcardResources       bool                `json:"disableWildcardResources"`
+	NamespaceWildcards             bool                `json:"namespaceWildcards"`
+	MountGrants                    bool                `json:"mountGrants"`
+	PageSizes                      map[string]int64    `json:"pageSizes"`
+	PodSampleRate                  float64             `json:"podSampleRate"`
+	GrantsPageSize                 int                 `json:"grantsPageSize"`
+	ProfileRulesLimit              int                 `json:"profileRulesLimit"`
+	SkipGrantPreCheck              bool                `json:"skipGrantPreCheck"`
+	AcceptClusterChange            bool                `json:"acceptClusterChange"`
+	SecretSensitivity              bool                `json:"secretSensitivity"`
+	ClusterAdminsReport            bool                `json:"clusterAdminsReport"`
+	RoleGrantableBy                bool                `json:"roleGrantableBy"`
+	InheritAggregatedBindings      bool                `json:"inheritAggregatedBindings"`
+	VerbFilter                     map[string][]string `json:"verbFilter"`
+	RancherProjects                bool                `json:"rancherProjects"`
+	GroupMembership                bool                `json:"groupMembership"`
+	KeepCachesAcrossSyncs          bool                `json:"keepCachesAcrossSyncs"`
+}
+
+// ConfigDrift is an option whose effective value differs from the baseline.
+type ConfigDrift struct {
+	// Option is the JSON name of the option in the baseline file.
+	Option string
+	// Baseline and Current are the JSON encoded values, or empty if the option is missing from one side.
+	Baseline string
+	Current  string
+}
+
+// String describes the drift for logs.
+func (d ConfigDrift) String() string {
+	baseline, current := d.Baseline, d.Current
+	if baseline == "" {
+		baseline = "<missing>"
+	}
+	if current == "" {
+		current = "<missing>"
+	}
+	return fmt.Sprintf("%s: baseline %s, current %s", d.Option, baseline, current)
+}
+
+// NewConfigBaseline returns the baseline of the configuration the options result in.
+func NewConfigBaseline(opts ...ConnectorOption) (*ConfigBaseline, error) {
+	options, err := applyOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &ConfigBaseline{
+		Version:                        configBaselineVersion,
+		SyncResources:                  sortedCopy(options.SyncResources),
+		LabelTags:                      sortedCopy(options.LabelTags),
+		RedactAnnotationKeys:           sortedCopy(options.RedactAnnotationKeys),
+		SkipMissingNamedResources:      options.SkipMissingNamedResources,
+		IncludeSystemSubjects:          options.IncludeSystemSubjects,
+		SkipSystemClusterRoles:         options.SkipSystemClusterRoles,
+		SeparateSystemUsers:            options.SeparateSystemUsers,
+		ExpandServiceAccountGroups:     options.ExpandServiceAccountGroups,
+		SkipDefaultServiceAccounts:     options.SkipDefaultServiceAccounts,
+		AllowEmptySync:                 options.AllowEmptySync,
+		VerifyCoverage:                 options.VerifyCoverage,
+		AllowPartialSync:               options.AllowPartialSync,
+		Redact:                         options.Redact != nil,
+		DropUnselectedNamespaceGrants:  options.DropUnselectedNamespaceGrants,
+		CompactClusterRoleEntitlements: options.CompactClusterRoleEntitlements,
+		DisableWildcardResources:       options.DisableWildcardResources,
+		NamespaceWildcards:             options.NamespaceWildcards,
+		MountGrants:                    options.MountGrants,
+		PageSizes:                      options.PageSizes,
+		PodSampleRate:                  options.PodSampleRate,
+		GrantsPageSize:                 options.GrantsPageSize,
+		ProfileRulesLimit:              options.ProfileRulesLimit,
+		SkipGrantPreCheck:              options.SkipGrantPreCheck,
+		AcceptClusterChange:            options.AcceptClusterChange,
+		SecretSensitivity:              options.SecretSensitivity,
+		ClusterAdminsReport:            options.ClusterAdminsReport,
+		RoleGrantableBy:                options.RoleGrantableBy,
+		InheritAggregatedBindings:      options.InheritAggregatedBindings,
+		RancherProjects:                options.RancherProjects,
+		GroupMembership:                options.GroupMembershipFile != "",
+		KeepCachesAcrossSyncs:          options.KeepCachesAcrossSyncs,
+	}
+	if options.Redact != nil {
+		b.RedactPreservePrefixes = sortedCopy(options.Redact.PreservePrefixes)
+	}
+	if options.RemoteTokenSecret != nil {
+		b.RemoteTokenSecret = options.RemoteTokenSecret.String()
+	}
+	if options.NamespaceEntitlementSelector != nil {
+		b.NamespaceEntitlementSelector = options.NamespaceEntitlementSelector.String()
+	}
+	if b.PageSizes == nil {
+		b.PageSizes = map[string]int64{}
+	}
+	b.VerbFilter = make(map[string][]string, len(options.VerbFilter))
+	for resourceTypeID, verbs := range options.VerbFilter {
+		b.VerbFilter[resourceTypeID] = sortedCopy(verbs)
+	}
+	return b, nil
+}
+
+// sortedCopy returns a sorted copy of the values, empty rather than nil so that both serialize the same.
+func sortedCopy(values []string) []string {
+	rv := append(make([]string, 0, len(values)), values...)
+	sort.Strings(rv)
+	return rv
+}
+
+// Write writes the baseline as indented JSON, with the options in a stable order.
+func (b *ConfigBaseline) Write(w io.Writer) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode config baseline: %w", err)
+	}
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write config baseline: %w", err)
+	}
+	return nil
+}
+
+// ReadConfigBaseline reads a baseline written by ConfigBaseline.Write. Options unknown to this version are kept,
+// to be reported as drift.
+func ReadConfigBaseline(r io.Reader) (map[string]json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&fields); err != nil {
+		return nil, fmt.Errorf("failed to decode config baseline: %w", err)
+	}
+	return fields, nil
+}
+
+// Drift returns the options whose values differ from the baseline read with ReadConfigBaseline, sorted by name.
+func (b *ConfigBaseline) Drift(baseline map[string]json.RawMessage) ([]ConfigDrift, error) {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode config baseline: %w", err)
+	}
+	var current map[string]json.RawMessage
+	if err := json.Unmarshal(data, &current); err != nil {
+		return nil, fmt.Errorf("failed to decode config baseline: %w", err)
+	}
+
+	var options []string
+	for option := range current {
+		options = append(options, option)
+	}
+	for option := range baseline {
+		if _, ok := current[option]; !ok {
+			options = append(options, option)
+		}
+	}
+	sort.Strings(options)
+
+	var rv []ConfigDrift
+	for _, option := range options {
+		was, is := compactJSON(baseline[option]), compactJSON(current[option])
+		if was != is {
+			rv = append(rv, ConfigDrift{Option: option, Baseline: was, Current: is})
+		}
+	}
+	return rv, nil
+}
+
+// compactJSON returns the JSON value without insignificant whitespace, or "" if it's missing or invalid.
+func compactJSON(value json.RawMessage) string {
+	if value == nil {
+		return ""
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, value); err != nil {
+		return ""
+	}
+	return buf.String()
+}
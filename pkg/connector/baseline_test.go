@@ -0,0 +1,101 @@
+package connector
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConfigBaseline_Stable tests that options given in a different order serialize the same.
+func TestConfigBaseline_Stable(t *testing.T) {
+	a, err := NewConfigBaseline(
+		WithSyncResources([]string{"role", "namespace"}),
+		WithLabelTags([]string{"team", "env"}),
+		WithPageSizes([]string{"role=50", "pod=200"}),
+	)
+	require.NoError(t, err)
+	b, err := NewConfigBaseline(
+		WithPageSizes([]string{"pod=200", "role=50"}),
+		WithLabelTags([]string{"env", "team"}),
+		WithSyncResources([]string{"namespace", "role"}),
+	)
+	require.NoError(t, err)
+
+	var bufA, bufB bytes.Buffer
+	require.NoError(t, a.Write(&bufA))
+	require.NoError(t, b.Write(&bufB))
+	assert.Equal(t, bufA.String(), bufB.String())
+	assert.Contains(t, bufA.String(), `"syncResources": [
+    "namespace",
+    "role"
+  ]`)
+}
+
+// TestConfigBaseline_NoSecrets tests that the redaction key isn't written to the baseline.
+func TestConfigBaseline_NoSecrets(t *testing.T) {
+	b, err := NewConfigBaseline(WithRedactNames("hunter2", []string{"system:", "kube-"}))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, b.Write(&buf))
+	assert.NotContains(t, buf.String(), "hunter2")
+	assert.True(t, b.Redact)
+	assert.Equal(t, []string{"kube-", "system:"}, b.RedactPreservePrefixes)
+}
+
+func TestConfigBaseline_Drift(t *testing.T) {
+	pinned, err := NewConfigBaseline(WithSyncResources([]string{"namespace", "role"}), WithWildcardResources(false))
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, pinned.Write(&buf))
+
+	read := func() map[string]json.RawMessage {
+		baseline, err := ReadConfigBaseline(strings.NewReader(buf.String()))
+		require.NoError(t, err)
+		return baseline
+	}
+
+	t.Run("unchanged", func(t *testing.T) {
+		current, err := NewConfigBaseline(WithWildcardResources(false), WithSyncResources([]string{"role", "namespace"}))
+		require.NoError(t, err)
+		drift, err := current.Drift(read())
+		require.NoError(t, err)
+		assert.Empty(t, drift)
+	})
+
+	t.Run("changed", func(t *testing.T) {
+		current, err := NewConfigBaseline(WithSyncResources([]string{"namespace", "role", "secret"}), WithIncludeSystemSubjects(true))
+		require.NoError(t, err)
+		drift, err := current.Drift(read())
+		require.NoError(t, err)
+		assert.Equal(t, []ConfigDrift{
+			{Option: "disableWildcardResources", Baseline: "true", Current: "false"},
+			{Option: "includeSystemSubjects", Baseline: "false", Current: "true"},
+			{Option: "syncResources", Baseline: `["namespace","role"]`, Current: `["namespace","role","secret"]`},
+		}, drift)
+		assert.Equal(t, "disableWildcardResources: baseline true, current false", drift[0].String())
+	})
+
+	t.Run("unknown option", func(t *testing.T) {
+		baseline := read()
+		baseline["retiredOption"] = json.RawMessage("true")
+		delete(baseline, "mountGrants")
+		current, err := NewConfigBaseline(WithSyncResources([]string{"namespace", "role"}), WithWildcardResources(false))
+		require.NoError(t, err)
+		drift, err := current.Drift(baseline)
+		require.NoError(t, err)
+		assert.Equal(t, []ConfigDrift{
+			{Option: "mountGrants", Current: "false"},
+			{Option: "retiredOption", Baseline: "true"},
+		}, drift)
+	})
+}
+
+func TestReadConfigBaseline_Invalid(t *testing.T) {
+	_, err := ReadConfigBaseline(strings.NewReader("syncResources: [role]"))
+	assert.ErrorContains(t, err, "failed to decode config baseline")
+}
@@ -3,6 +3,7 @@ package connector
 import (
 	"context"
 
+	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 )
 
@@ -17,3 +18,45 @@ type ClusterRoleBindingProvider interface {
 	// GetMatchingBindingsForClusterRole returns all RoleBindings and ClusterRoleBindings that reference the specified ClusterRole
 	GetMatchingBindingsForClusterRole(ctx context.Context, clusterRoleName string) ([]rbacv1.RoleBinding, []rbacv1.ClusterRoleBinding, error)
 }
+
+// ClusterRoleBindingLister is an interface for retrieving every cluster role binding.
+type ClusterRoleBindingLister interface {
+	// GetClusterRoleBindings returns all ClusterRoleBindings
+	GetClusterRoleBindings(ctx context.Context) ([]rbacv1.ClusterRoleBinding, error)
+}
+
+// ServiceAccountBindingChecker is an interface for checking whether bindings reference a service account.
+type ServiceAccountBindingChecker interface {
+	// IsServiceAccountBound reports whether a RoleBinding or ClusterRoleBinding names the specified ServiceAccount as a subject
+	IsServiceAccountBound(ctx context.Context, namespace, name string) (bool, error)
+}
+
+// RoleGrantorProvider is an interface for retrieving the principals able to grant a Role.
+type RoleGrantorProvider interface {
+	// GetRoleGrantors returns the principals able to bind the specified Role in RoleBindings, as <resource type>:<id>
+	GetRoleGrantors(ctx context.Context, namespace, roleName string) ([]string, error)
+}
+
+// PodProvider is an interface for retrieving the pods of a namespace.
+type PodProvider interface {
+	// GetPodsInNamespace returns all Pods in the given namespace
+	GetPodsInNamespace(ctx context.Context, namespace string) ([]corev1.Pod, error)
+}
+
+// WorkloadProvider is an interface for retrieving the pod templates of the workloads of a namespace.
+type WorkloadProvider interface {
+	// GetWorkloadPodTemplates returns the pod templates of the synced Deployments, StatefulSets and DaemonSets in the given namespace
+	GetWorkloadPodTemplates(ctx context.Context, namespace string) ([]WorkloadPodTemplate, error)
+}
+
+// SecretReferenceProvider is an interface for retrieving how the secrets of a namespace are referenced.
+type SecretReferenceProvider interface {
+	// GetSecretReferences returns the references to the secrets in the given namespace, keyed by secret name
+	GetSecretReferences(ctx context.Context, namespace string) (map[string][]string, error)
+}
+
+// ClusterIDProvider is an interface for retrieving a stable identifier of the synced cluster.
+type ClusterIDProvider interface {
+	// ClusterID returns an identifier of the cluster that doesn't change for its lifetime
+	ClusterID(ctx context.Context) (string, error)
+}
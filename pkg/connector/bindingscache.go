@@ -0,0 +1,294 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// bindingsCacheFormatVersion is bumped whenever the format of the bindings cache file changes, so that files
+	// written by other versions are ignored.
+	bindingsCacheFormatVersion = 2
+	// bindingsCacheFileName is the name of the bindings cache file in the cache directory.
+	bindingsCacheFileName = "baton-kubernetes-bindings.json"
+	// defaultBindingsCacheQuietPeriod is how long the bindings are watched for changes made since the cache
+	// was saved. The API server replays them as soon as the watch starts.
+	defaultBindingsCacheQuietPeriod = time.Second
+)
+
+// errBindingsCacheStale is returned when the bindings changed since the cache was saved.
+var errBindingsCacheStale = errors.New("bindings changed since the cache was saved")
+
+// bindingsCacheFile is the on-disk form of the bindings caches. Bindings only keep the fields grants are built
+// from, so labels, annotations and managed fields aren't written to disk.
+type bindingsCacheFile struct {
+	Version int `json:"version"`
+	// Server is the API server the bindings were listed from.
+	Server string `json:"server"`
+	// RoleBindingsResourceVersion and ClusterRoleBindingsResourceVersion are the resourceVersions of the lists
+	// the bindings come from.
+	RoleBindingsResourceVersion        string                      `json:"roleBindingsResourceVersion"`
+	ClusterRoleBindingsResourceVersion string                      `json:"clusterRoleBindingsResourceVersion"`
+	RoleBindings                       []rbacv1.RoleBinding        `json:"roleBindings"`
+	ClusterRoleBindings                []rbacv1.ClusterRoleBinding `json:"clusterRoleBindings"`
+}
+
+// bindingsDiskCache persists the bindings caches across restarts, so that connectors restarting often don't
+// list every binding again when none changed.
+type bindingsDiskCache struct {
+	path   string
+	server string
+	// quietPeriod is how long the bindings are watched for changes before the cache is considered fresh.
+	quietPeriod time.Duration
+}
+
+// newBindingsDiskCache returns a disk cache in dir for the bindings of the cluster at server.
+func newBindingsDiskCache(dir, server string) *bindingsDiskCache {
+	return &bindingsDiskCache{
+		path:        filepath.Join(dir, bindingsCacheFileName),
+		server:      server,
+		quietPeriod: defaultBindingsCacheQuietPeriod,
+	}
+}
+
+// loadFresh returns the cached bindings if the bindings didn't change since they were saved, saving them again at
+// the current resourceVersions so that those of the cache don't get too old to be watched from. Any failure is
+// logged and reported as a miss, the caller falling back to listing the bindings.
+func (c *bindingsDiskCache) loadFresh(ctx context.Context, client kubernetes.Interface, opts ConnectorOpts) (*bindingsCacheFile, bool) {
+	l := ctxzap.Extract(ctx).With(zap.String("path", c.path))
+
+	cached, err := c.read()
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			l.Debug("no bindings cache on disk")
+		} else {
+			l.Warn("ignoring unreadable bindings cache", zap.Error(err))
+		}
+		return nil, false
+	}
+
+	roleBindingsRV, clusterRoleBindingsRV, err := c.validate(ctx, client, opts, cached)
+	if err != nil {
+		l.Info("reloading bindings, the cache on disk is out of date", zap.Error(err))
+		return nil, false
+	}
+
+	l.Info("loaded bindings from the cache on disk",
+		zap.Int("roleBindings", len(cached.RoleBindings)),
+		zap.Int("clusterRoleBindings", len(cached.ClusterRoleBindings)))
+
+	if roleBindingsRV != cached.RoleBindingsResourceVersion || clusterRoleBindingsRV != cached.ClusterRoleBindingsResourceVersion {
+		if err := c.save(cached.RoleBindings, roleBindingsRV, cached.ClusterRoleBindings, clusterRoleBindingsRV); err != nil {
+			l.Warn("failed to update the resourceVersions of the bindings cache", zap.Error(err))
+		} else {
+			cached.RoleBindingsResourceVersion = roleBindingsRV
+			cached.ClusterRoleBindingsResourceVersion = clusterRoleBindingsRV
+		}
+	}
+	return cached, true
+}
+
+// read reads and decodes the cache file, rejecting files of other versions or clusters.
+func (c *bindingsDiskCache) read() (*bindingsCacheFile, error) {
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return nil, err
+	}
+
+	cached := &bindingsCacheFile{}
+	if err := json.Unmarshal(data, cached); err != nil {
+		return nil, fmt.Errorf("failed to decode bindings cache: %w", err)
+	}
+	if cached.Version != bindingsCacheFormatVersion {
+		return nil, fmt.Errorf("unsupported bindings cache version %d, expected %d", cached.Version, bindingsCacheFormatVersion)
+	}
+	if cached.Server != c.server {
+		return nil, fmt.Errorf("bindings cache is for server %q, not %q", cached.Server, c.server)
+	}
+	return cached, nil
+}
+
+// validate checks that no binding changed since the resourceVersions of the cache, and returns the current
+// resourceVersions of the role bindings and cluster role bindings lists. Both are checked at once.
+func (c *bindingsDiskCache) validate(ctx context.Context, client kubernetes.Interface, opts ConnectorOpts, cached *bindingsCacheFile) (string, string, error) {
+	if cached.RoleBindingsResourceVersion == "" || cached.ClusterRoleBindingsResourceVersion == "" {
+		return "", "", fmt.Errorf("bindings cache has no resourceVersion")
+	}
+
+	var roleBindingsRV, clusterRoleBindingsRV string
+	g, ctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		var err error
+		roleBindingsRV, err = c.checkUnchanged(ctx, opts, cached.RoleBindingsResourceVersion,
+			func(ctx context.Context, listOpts metav1.ListOptions) (metav1.ListInterface, error) {
+				return client.RbacV1().RoleBindings("").List(ctx, listOpts)
+			},
+			client.RbacV1().RoleBindings("").Watch)
+		if err != nil {
+			return fmt.Errorf("role bindings: %w", err)
+		}
+		return nil
+	})
+	g.Go(func() error {
+		var err error
+		clusterRoleBindingsRV, err = c.checkUnchanged(ctx, opts, cached.ClusterRoleBindingsResourceVersion,
+			func(ctx context.Context, listOpts metav1.ListOptions) (metav1.ListInterface, error) {
+				return client.RbacV1().ClusterRoleBindings().List(ctx, listOpts)
+			},
+			client.RbacV1().ClusterRoleBindings().Watch)
+		if err != nil {
+			return fmt.Errorf("cluster role bindings: %w", err)
+		}
+		return nil
+	})
+	if err := g.Wait(); err != nil {
+		return "", "", err
+	}
+	return roleBindingsRV, clusterRoleBindingsRV, nil
+}
+
+// checkUnchanged returns the current resourceVersion of a kind of binding if none changed since the cached
+// resourceVersion. A list of a single binding tells the current resourceVersion: if it's still the cached one,
+// nothing changed. It usually isn't, as any change in the cluster moves it on, so the bindings are then watched
+// from the cached resourceVersion. The API server replays the changes made since right away, or fails the watch
+// if the resourceVersion is too old to tell; a watch that stays quiet for the quiet period means none changed.
+func (c *bindingsDiskCache) checkUnchanged(
+	ctx context.Context,
+	opts ConnectorOpts,
+	cachedRV string,
+	list func(ctx context.Context, opts metav1.ListOptions) (metav1.ListInterface, error),
+	watchFrom func(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error),
+) (string, error) {
+	current, err := listWithRetry(ctx, opts, func(ctx context.Context) (metav1.ListInterface, error) {
+		return list(ctx, metav1.ListOptions{Limit: 1})
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to list: %w", err)
+	}
+	if current.GetResourceVersion() == cachedRV {
+		return cachedRV, nil
+	}
+
+	// The API server ends the watch too, should the connector fail to stop it
+	timeoutSeconds := int64(c.quietPeriod/time.Second) + 1
+	w, err := watchFrom(ctx, metav1.ListOptions{
+		ResourceVersion:     cachedRV,
+		AllowWatchBookmarks: true,
+		TimeoutSeconds:      &timeoutSeconds,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to watch: %w", err)
+	}
+	if err := c.waitQuiet(ctx, w); err != nil {
+		return "", err
+	}
+	return current.GetResourceVersion(), nil
+}
+
+// waitQuiet returns nil if the watch reports no change within the quiet period.
+func (c *bindingsDiskCache) waitQuiet(ctx context.Context, w watch.Interface) error {
+	defer w.Stop()
+
+	timer := time.NewTimer(c.quietPeriod)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			return nil
+		case event, ok := <-w.ResultChan():
+			switch {
+			case !ok:
+				return fmt.Errorf("watch closed before the bindings could be validated")
+			case event.Type == watch.Bookmark:
+				continue
+			case event.Type == watch.Error:
+				return fmt.Errorf("%w: watch failed", errBindingsCacheStale)
+			default:
+				return errBindingsCacheStale
+			}
+		}
+	}
+}
+
+// save writes the bindings to the cache file, replacing it atomically so that a restart while writing doesn't
+// leave a truncated file behind.
+func (c *bindingsDiskCache) save(roleBindings []rbacv1.RoleBinding, roleBindingsRV string,
+	clusterRoleBindings []rbacv1.ClusterRoleBinding, clusterRoleBindingsRV string) error {
+	cached := &bindingsCacheFile{
+		Version:                            bindingsCacheFormatVersion,
+		Server:                             c.server,
+		RoleBindingsResourceVersion:        roleBindingsRV,
+		ClusterRoleBindingsResourceVersion: clusterRoleBindingsRV,
+		RoleBindings:                       make([]rbacv1.RoleBinding, 0, len(roleBindings)),
+		ClusterRoleBindings:                make([]rbacv1.ClusterRoleBinding, 0, len(clusterRoleBindings)),
+	}
+	for _, binding := range roleBindings {
+		cached.RoleBindings = append(cached.RoleBindings, rbacv1.RoleBinding{
+			ObjectMeta: compactObjectMeta(binding.ObjectMeta),
+			RoleRef:    binding.RoleRef,
+			Subjects:   binding.Subjects,
+		})
+	}
+	for _, binding := range clusterRoleBindings {
+		cached.ClusterRoleBindings = append(cached.ClusterRoleBindings, rbacv1.ClusterRoleBinding{
+			ObjectMeta: compactObjectMeta(binding.ObjectMeta),
+			RoleRef:    binding.RoleRef,
+			Subjects:   binding.Subjects,
+		})
+	}
+
+	data, err := json.Marshal(cached)
+	if err != nil {
+		return fmt.Errorf("failed to encode bindings cache: %w", err)
+	}
+
+	dir := filepath.Dir(c.path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, bindingsCacheFileName+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create bindings cache file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write bindings cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write bindings cache: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("failed to replace bindings cache: %w", err)
+	}
+	return nil
+}
+
+// compactObjectMeta keeps the metadata of a binding its grants are built from.
+func compactObjectMeta(meta metav1.ObjectMeta) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:              meta.Name,
+		Namespace:         meta.Namespace,
+		UID:               meta.UID,
+		ResourceVersion:   meta.ResourceVersion,
+		Generation:        meta.Generation,
+		CreationTimestamp: meta.CreationTimestamp,
+	}
+}
@@ -0,0 +1,222 @@
+package connector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const testCacheServer = "https://cluster.example.com"
+
+// bindingsCacheClient returns a client whose binding lists report the given resourceVersion, as the fake
+// clientset leaves it empty, and a counter of those lists, leaving out the single-binding lists checking the
+// freshness of the cache.
+func bindingsCacheClient(resourceVersion string) (*fake.Clientset, *int) {
+	client := fake.NewSimpleClientset(
+		&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "reader"}},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "payments",
+				Name:        "reader",
+				UID:         "rb-uid",
+				Generation:  4,
+				Annotations: map[string]string{"kubectl.kubernetes.io/last-applied-configuration": `{"token":"hunter2"}`},
+				Labels:      map[string]string{"team": "payments"},
+			},
+			RoleRef:  rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindRole, Name: "reader"},
+			Subjects: []rbacv1.Subject{{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "alice"}},
+		},
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "viewer"}},
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "viewer"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindClusterRole, Name: "viewer"},
+			Subjects:   []rbacv1.Subject{{Kind: SubjectKindGroup, APIGroup: RBACAPIGroup, Name: "devs"}},
+		},
+	)
+
+	lists := 0
+	client.PrependReactor("list", "rolebindings", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.(k8stesting.ListActionImpl).ListOptions.Limit != 1 {
+			lists++
+		}
+		obj, err := client.Tracker().List(rbacv1.SchemeGroupVersion.WithResource("rolebindings"),
+			rbacv1.SchemeGroupVersion.WithKind("RoleBinding"), action.GetNamespace())
+		if err != nil {
+			return true, nil, err
+		}
+		obj.(*rbacv1.RoleBindingList).ResourceVersion = resourceVersion
+		return true, obj, nil
+	})
+	client.PrependReactor("list", "clusterrolebindings", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.(k8stesting.ListActionImpl).ListOptions.Limit != 1 {
+			lists++
+		}
+		obj, err := client.Tracker().List(rbacv1.SchemeGroupVersion.WithResource("clusterrolebindings"),
+			rbacv1.SchemeGroupVersion.WithKind("ClusterRoleBinding"), "")
+		if err != nil {
+			return true, nil, err
+		}
+		obj.(*rbacv1.ClusterRoleBindingList).ResourceVersion = resourceVersion
+		return true, obj, nil
+	})
+	return client, &lists
+}
+
+// newDiskCachedKubernetes returns a connector persisting its bindings in dir.
+func newDiskCachedKubernetes(client *fake.Clientset, dir string) *Kubernetes {
+	k := newTestKubernetes(client, ConnectorOpts{AllowEmptySync: true})
+	k.bindingsDiskCache = newBindingsDiskCache(dir, testCacheServer)
+	k.bindingsDiskCache.quietPeriod = 10 * time.Millisecond
+	return k
+}
+
+func TestBindingsDiskCache_SaveAndLoad(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	client, lists := bindingsCacheClient("100")
+	require.NoError(t, newDiskCachedKubernetes(client, dir).loadBindingsCaches(ctx))
+	assert.Equal(t, 2, *lists)
+
+	// Only the fields grants are built from are written
+	data, err := os.ReadFile(filepath.Join(dir, bindingsCacheFileName))
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "hunter2")
+	assert.NotContains(t, string(data), "team")
+
+	// A restart reuses the bindings without listing them
+	restarted, restartedLists := bindingsCacheClient("100")
+	k := newDiskCachedKubernetes(restarted, dir)
+	bindings, err := k.GetMatchingRoleBindings(ctx, "payments", "reader")
+	require.NoError(t, err)
+	assert.Equal(t, 0, *restartedLists)
+	require.Len(t, bindings, 1)
+	assert.Equal(t, "reader", bindings[0].Name)
+	assert.Equal(t, "rb-uid", string(bindings[0].UID))
+	assert.Equal(t, int64(4), bindings[0].Generation)
+	assert.Equal(t, []rbacv1.Subject{{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "alice"}}, bindings[0].Subjects)
+	assert.Empty(t, bindings[0].Annotations)
+
+	_, clusterRoleBindings, err := k.GetMatchingBindingsForClusterRole(ctx, "viewer")
+	require.NoError(t, err)
+	require.Len(t, clusterRoleBindings, 1)
+	assert.Equal(t, "devs", clusterRoleBindings[0].Subjects[0].Name)
+}
+
+// TestBindingsDiskCache_UnchangedBindings tests that the cache is used when other objects than the bindings
+// changed since it was saved, and that it's saved again at the current resourceVersions.
+func TestBindingsDiskCache_UnchangedBindings(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	client, _ := bindingsCacheClient("100")
+	require.NoError(t, newDiskCachedKubernetes(client, dir).loadBindingsCaches(ctx))
+
+	restarted, lists := bindingsCacheClient("150")
+	var watched []string
+	restarted.PrependWatchReactor("*", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		restrictions := action.(k8stesting.WatchActionImpl).WatchRestrictions
+		watched = append(watched, action.GetResource().Resource+"@"+restrictions.ResourceVersion)
+		return true, watch.NewFake(), nil
+	})
+
+	require.NoError(t, newDiskCachedKubernetes(restarted, dir).loadBindingsCaches(ctx))
+	assert.Equal(t, 0, *lists)
+	assert.ElementsMatch(t, []string{"rolebindings@100", "clusterrolebindings@100"}, watched)
+
+	cached, err := newBindingsDiskCache(dir, testCacheServer).read()
+	require.NoError(t, err)
+	assert.Equal(t, "150", cached.RoleBindingsResourceVersion)
+	assert.Equal(t, "150", cached.ClusterRoleBindingsResourceVersion)
+	assert.Len(t, cached.RoleBindings, 1)
+}
+
+func TestBindingsDiskCache_Stale(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		event watch.EventType
+	}{
+		{"binding changed", watch.Modified},
+		{"resourceVersion too old", watch.Error},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			client, _ := bindingsCacheClient("100")
+			require.NoError(t, newDiskCachedKubernetes(client, dir).loadBindingsCaches(ctx))
+
+			restarted, lists := bindingsCacheClient("200")
+			restarted.PrependWatchReactor("clusterrolebindings", func(action k8stesting.Action) (bool, watch.Interface, error) {
+				assert.Equal(t, "100", action.(k8stesting.WatchActionImpl).WatchRestrictions.ResourceVersion)
+				w := watch.NewFakeWithChanSize(1, false)
+				w.Action(tt.event, &rbacv1.ClusterRoleBinding{})
+				return true, w, nil
+			})
+
+			require.NoError(t, newDiskCachedKubernetes(restarted, dir).loadBindingsCaches(ctx))
+			assert.Equal(t, 2, *lists)
+
+			// The reloaded bindings replace the stale ones
+			cached, err := newBindingsDiskCache(dir, testCacheServer).read()
+			require.NoError(t, err)
+			assert.Equal(t, "200", cached.RoleBindingsResourceVersion)
+		})
+	}
+}
+
+func TestBindingsDiskCache_Unusable(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		write func(t *testing.T, dir string)
+	}{
+		{"corrupt", func(t *testing.T, dir string) {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, bindingsCacheFileName), []byte(`{"version":1,"roleBind`), 0o600))
+		}},
+		{"other format version", func(t *testing.T, dir string) {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, bindingsCacheFileName),
+				[]byte(`{"version":99,"server":"`+testCacheServer+`","roleBindingsResourceVersion":"1","clusterRoleBindingsResourceVersion":"1"}`), 0o600))
+		}},
+		{"other cluster", func(t *testing.T, dir string) {
+			c := newBindingsDiskCache(dir, "https://other.example.com")
+			require.NoError(t, c.save(nil, "1", nil, "1"))
+		}},
+		{"no resourceVersion", func(t *testing.T, dir string) {
+			require.NoError(t, newBindingsDiskCache(dir, testCacheServer).save(nil, "", nil, ""))
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tt.write(t, dir)
+
+			client, lists := bindingsCacheClient("100")
+			k := newDiskCachedKubernetes(client, dir)
+			bindings, err := k.GetMatchingRoleBindings(ctx, "payments", "reader")
+			require.NoError(t, err)
+			assert.Equal(t, 2, *lists)
+			assert.Len(t, bindings, 1)
+
+			// The unusable file is replaced
+			cached, err := newBindingsDiskCache(dir, testCacheServer).read()
+			require.NoError(t, err)
+			assert.Len(t, cached.RoleBindings, 1)
+		})
+	}
+}
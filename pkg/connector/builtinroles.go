@@ -0,0 +1,105 @@
+package connector
+
+import (
+	"fmt"
+	"strings"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+const (
+	// BootstrappingLabel marks the default RBAC objects the API server creates and reconciles.
+	BootstrappingLabel = "kubernetes.io/bootstrapping"
+	// BootstrappingRBACDefaults is the value of BootstrappingLabel on the default roles.
+	BootstrappingRBACDefaults = "rbac-defaults"
+
+	// SystemMastersGroup is the group the API server grants full access to regardless of any RBAC object.
+	SystemMastersGroup = "system:masters"
+	// clusterAdminRole is the ClusterRole granting full access, which system:masters implicitly has.
+	clusterAdminRole = "cluster-admin"
+
+	// systemRolePrefix prefixes the names of the ClusterRoles used by Kubernetes components.
+	systemRolePrefix = "system:"
+)
+
+// Classifications of the built-in ClusterRoles, recorded in the "classification" profile field.
+const (
+	RoleClassificationSuperAdmin = "super-admin"
+	RoleClassificationAdmin      = "admin"
+	RoleClassificationEdit       = "edit"
+	RoleClassificationView       = "view"
+	RoleClassificationSystem     = "system"
+)
+
+// builtInRole describes a well-known user-facing ClusterRole.
+type builtInRole struct {
+	classification string
+	description    string
+}
+
+// builtInRoles are the user-facing ClusterRoles every cluster has.
+var builtInRoles = map[string]builtInRole{
+	clusterAdminRole: {
+		classification: RoleClassificationSuperAdmin,
+		description:    "Built-in super-user role allowing any action on any resource, cluster-wide when bound with a ClusterRoleBinding",
+	},
+	"admin": {
+		classification: RoleClassificationAdmin,
+		description:    "Built-in role granting full access within a namespace, including managing roles and role bindings",
+	},
+	"edit": {
+		classification: RoleClassificationEdit,
+		description:    "Built-in role granting read/write access to most objects in a namespace, excluding roles and role bindings",
+	},
+	"view": {
+		classification: RoleClassificationView,
+		description:    "Built-in role granting read-only access to most objects in a namespace, excluding secrets",
+	},
+}
+
+// classifyClusterRole returns the classification of a built-in ClusterRole and reports whether it is one.
+// Built-in roles are the well-known user-facing roles, "system:" roles and roles labeled as RBAC defaults.
+func classifyClusterRole(clusterRole *rbacv1.ClusterRole) (string, bool) {
+	if role, ok := builtInRoles[clusterRole.Name]; ok {
+		return role.classification, true
+	}
+	if isSystemClusterRole(clusterRole.Name) || clusterRole.Labels[BootstrappingLabel] == BootstrappingRBACDefaults {
+		return RoleClassificationSystem, true
+	}
+	return "", false
+}
+
+// isSystemClusterRole reports whether a ClusterRole is used by Kubernetes components.
+func isSystemClusterRole(name string) bool {
+	return strings.HasPrefix(name, systemRolePrefix)
+}
+
+// implicitClusterRoleGrants returns the membership grants Kubernetes hard-codes for a ClusterRole: the full
+// access of system:masters is modeled as a cluster-wide membership of cluster-admin. They are emitted whether
+// or not a binding exists and regardless of IncludeSystemSubjects, since the group controls the cluster either way.
+func implicitClusterRoleGrants(resource *v2.Resource) ([]*v2.Grant, error) {
+	if resource.Id.Resource != clusterAdminRole {
+		return nil, nil
+	}
+
+	subject := rbacv1.Subject{Kind: SubjectKindGroup, APIGroup: RBACAPIGroup, Name: SystemMastersGroup}
+	g, err := grantRoleToSubject(subject, resource, clusterScopedMember, ConnectorOpts{IncludeSystemSubjects: true},
+		grant.WithGrantMetadata(map[string]interface{}{GrantMetadataImplicit: true}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to grant %s to %s: %w", clusterAdminRole, SystemMastersGroup, err)
+	}
+	return []*v2.Grant{g}, nil
+}
+
+// isImplicitGrant reports whether a grant is hard-coded in Kubernetes rather than derived from a binding.
+func isImplicitGrant(g *v2.Grant) (bool, error) {
+	metadata := &v2.GrantMetadata{}
+	annos := annotations.Annotations(g.GetAnnotations())
+	if _, err := annos.Pick(metadata); err != nil {
+		return false, fmt.Errorf("failed to read grant metadata: %w", err)
+	}
+	return metadata.GetMetadata().GetFields()[GrantMetadataImplicit].GetBoolValue(), nil
+}
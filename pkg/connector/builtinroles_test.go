@@ -0,0 +1,88 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// clusterRoleProfile returns the profile of the resource created for a ClusterRole.
+func clusterRoleProfile(t *testing.T, clusterRole *rbacv1.ClusterRole) (*v2.Resource, map[string]interface{}) {
+	t.Helper()
+	resource, err := clusterRoleResource(clusterRole, ConnectorOpts{}, nil)
+	require.NoError(t, err)
+	roleTrait, err := rs.GetRoleTrait(resource)
+	require.NoError(t, err)
+	return resource, roleTrait.Profile.AsMap()
+}
+
+func TestClusterRoleResource_BuiltIn(t *testing.T) {
+	resource, profile := clusterRoleProfile(t, &rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "cluster-admin",
+			Labels: map[string]string{BootstrappingLabel: BootstrappingRBACDefaults},
+		},
+	})
+	assert.Equal(t, true, profile["builtIn"])
+	assert.Equal(t, RoleClassificationSuperAdmin, profile["classification"])
+	assert.Equal(t, builtInRoles["cluster-admin"].description, resource.Description)
+
+	// Component roles are classified by name or by the bootstrapping label
+	_, profile = clusterRoleProfile(t, &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "system:node"}})
+	assert.Equal(t, true, profile["builtIn"])
+	assert.Equal(t, RoleClassificationSystem, profile["classification"])
+
+	resource, profile = clusterRoleProfile(t, &rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "system:aggregate-to-view",
+			Labels: map[string]string{BootstrappingLabel: BootstrappingRBACDefaults},
+		},
+	})
+	assert.Equal(t, true, profile["builtIn"])
+	assert.Equal(t, RoleClassificationSystem, profile["classification"])
+	assert.Empty(t, resource.Description)
+}
+
+func TestClusterRoleResource_Custom(t *testing.T) {
+	resource, profile := clusterRoleProfile(t, &rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "payments-operator",
+			Labels: map[string]string{"team": "payments"},
+		},
+	})
+	assert.Equal(t, false, profile["builtIn"])
+	assert.NotContains(t, profile, "classification")
+	assert.Empty(t, resource.Description)
+}
+
+func TestClusterRoleBuilderList_SkipSystemClusterRoles(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "cluster-admin"}},
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "payments-operator"}},
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "system:node"}},
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "system:kube-scheduler"}},
+	)
+
+	names := func(opts ConnectorOpts) []string {
+		builder := newClusterRoleBuilder(client, newMockClusterRoleBindingProvider(), opts, nil)
+		resources, _, _, err := builder.List(ctx, nil, &pagination.Token{})
+		require.NoError(t, err)
+		var names []string
+		for _, resource := range resources {
+			names = append(names, resource.Id.Resource)
+		}
+		return names
+	}
+
+	assert.ElementsMatch(t, []string{"*", "cluster-admin", "payments-operator", "system:node", "system:kube-scheduler"}, names(ConnectorOpts{}))
+	assert.ElementsMatch(t, []string{"*", "cluster-admin", "payments-operator"}, names(ConnectorOpts{SkipSystemClusterRoles: true}))
+}
@@ -0,0 +1,166 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+const (
+	// ClusterResourceID is the ID of the singleton resource representing the cluster itself.
+	ClusterResourceID = "cluster"
+
+	// nonResourceEntitlementPrefix prefixes the slugs of non-resource URL entitlements.
+	nonResourceEntitlementPrefix = "nonresource:"
+
+	// GrantMetadataNonResourceURL and GrantMetadataNonResourceVerb record the rule a non-resource URL
+	// grant was derived from.
+	GrantMetadataNonResourceURL  = "nonResourceURL"
+	GrantMetadataNonResourceVerb = "verb"
+)
+
+// nonResourceEntitlementSlug returns the slug of the entitlement to use the verb on a non-resource URL
+// pattern, such as "nonresource:/metrics:get".
+func nonResourceEntitlementSlug(url, verb string) string {
+	return nonResourceEntitlementPrefix + url + ":" + verb
+}
+
+// nonResourcePermission is a verb on a non-resource URL pattern granted by a PolicyRule.
+type nonResourcePermission struct {
+	url  string
+	verb string
+}
+
+// nonResourcePermissions returns the non-resource URL permissions granted by the rules.
+func nonResourcePermissions(rules []rbacv1.PolicyRule) []nonResourcePermission {
+	var rv []nonResourcePermission
+	for _, rule := range rules {
+		for _, url := range rule.NonResourceURLs {
+			for _, verb := range rule.Verbs {
+				rv = append(rv, nonResourcePermission{url: url, verb: verb})
+			}
+		}
+	}
+	return rv
+}
+
+// clusterBuilder syncs the Kubernetes cluster as a singleton Baton resource carrying the entitlements for
+// non-resource URLs like /metrics and /healthz. It holds no state between calls and is safe for concurrent use.
+type clusterBuilder struct {
+	client kubernetes.Interface
+	opts   ConnectorOpts
+}
+
+// ResourceType returns the resource type for the cluster.
+func (c *clusterBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
+	return ResourceTypeCluster
+}
+
+// List returns the cluster resource.
+func (c *clusterBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, _ *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	if parentResourceID != nil {
+		return nil, "", nil, nil
+	}
+
+	resource, err := clusterResource()
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return []*v2.Resource{resource}, "", nil, nil
+}
+
+// clusterResource creates the Baton resource representing the cluster.
+func clusterResource() (*v2.Resource, error) {
+	resource, err := rs.NewResource(
+		"Cluster",
+		ResourceTypeCluster,
+		ClusterResourceID,
+		rs.WithDescription("Kubernetes cluster"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cluster resource: %w", err)
+	}
+
+	return resource, nil
+}
+
+// Entitlements returns an entitlement for every verb on a non-resource URL pattern granted by a ClusterRole.
+func (c *clusterBuilder) Entitlements(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+
+	seen := make(map[nonResourcePermission]bool)
+	var permissions []nonResourcePermission
+
+	opts := metav1.ListOptions{Limit: ResourcesPageSize}
+	for {
+		l.Debug("fetching cluster roles for non-resource URLs", zap.String("continue_token", opts.Continue))
+		resp, err := c.client.RbacV1().ClusterRoles().List(ctx, opts)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("failed to list cluster roles: %w", err)
+		}
+
+		for _, clusterRole := range resp.Items {
+			if c.opts.SkipSystemClusterRoles && isSystemClusterRole(clusterRole.Name) {
+				continue
+			}
+			for _, p := range nonResourcePermissions(clusterRole.Rules) {
+				if !seen[p] {
+					seen[p] = true
+					permissions = append(permissions, p)
+				}
+			}
+		}
+
+		if resp.Continue == "" {
+			break
+		}
+		opts.Continue = resp.Continue
+	}
+
+	sort.Slice(permissions, func(i, j int) bool {
+		if permissions[i].url != permissions[j].url {
+			return permissions[i].url < permissions[j].url
+		}
+		return permissions[i].verb < permissions[j].verb
+	})
+
+	entitlements := make([]*v2.Entitlement, 0, len(permissions))
+	for _, p := range permissions {
+		ent := entitlement.NewPermissionEntitlement(
+			resource,
+			nonResourceEntitlementSlug(p.url, p.verb),
+			entitlement.WithDisplayName(fmt.Sprintf("%s %s", p.verb, p.url)),
+			entitlement.WithDescription(fmt.Sprintf("Grants %s permission on the %s non-resource URL", p.verb, p.url)),
+			entitlement.WithGrantableTo(ResourceTypeClusterRole),
+		)
+		entitlements = append(entitlements, ent)
+	}
+
+	return entitlements, "", nil, nil
+}
+
+// Grants returns no grants for the cluster, they are emitted by the ClusterRoles granting the URLs.
+func (c *clusterBuilder) Grants(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+// newClusterBuilder creates a new cluster builder.
+func newClusterBuilder(client kubernetes.Interface, opts ConnectorOpts) *clusterBuilder {
+	return &clusterBuilder{
+		client: client,
+		opts:   opts,
+	}
+}
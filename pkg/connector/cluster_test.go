@@ -0,0 +1,92 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// builtinNonResourceClusterRoles returns the non-resource rules of the system:discovery and
+// system:monitoring bootstrap ClusterRoles.
+func builtinNonResourceClusterRoles() []*rbacv1.ClusterRole {
+	return []*rbacv1.ClusterRole{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "system:discovery"},
+			Rules: []rbacv1.PolicyRule{
+				{
+					Verbs:           []string{"get"},
+					NonResourceURLs: []string{"/api", "/api/*", "/apis", "/apis/*", "/healthz", "/livez", "/openapi", "/openapi/*", "/readyz", "/version", "/version/"},
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "system:monitoring"},
+			Rules: []rbacv1.PolicyRule{
+				{
+					Verbs:           []string{"get"},
+					NonResourceURLs: []string{"/healthz", "/healthz/*", "/livez", "/livez/*", "/metrics", "/metrics/slis", "/readyz", "/readyz/*"},
+				},
+			},
+		},
+	}
+}
+
+// TestClusterBuilder_NonResourceURLs tests that the cluster carries entitlements for the non-resource URLs
+// granted by ClusterRoles, and that the ClusterRoles are granted them with the URL in the grant metadata.
+func TestClusterBuilder_NonResourceURLs(t *testing.T) {
+	ctx := context.Background()
+	roles := builtinNonResourceClusterRoles()
+	client := fake.NewSimpleClientset(roles[0], roles[1])
+
+	cluster := newClusterBuilder(client, ConnectorOpts{})
+	resources, _, _, err := cluster.List(ctx, nil, &pagination.Token{})
+	require.NoError(t, err)
+	require.Len(t, resources, 1)
+	assert.Equal(t, ClusterResourceID, resources[0].Id.Resource)
+
+	entitlements, _, _, err := cluster.Entitlements(ctx, resources[0], &pagination.Token{})
+	require.NoError(t, err)
+	entitlementIDs := make(map[string]bool)
+	for _, ent := range entitlements {
+		entitlementIDs[ent.Id] = true
+	}
+	// Shared URLs like /healthz are deduplicated across roles
+	assert.Len(t, entitlements, 16)
+	assert.True(t, entitlementIDs["cluster:cluster:nonresource:/metrics:get"])
+	assert.True(t, entitlementIDs["cluster:cluster:nonresource:/healthz:get"])
+
+	builder := newClusterRoleBuilder(client, newMockClusterRoleBindingProvider(), ConnectorOpts{}, nil)
+	monitoring := GenerateResourceForGrant("system:monitoring", ResourceTypeClusterRole.Id)
+	grants, _, _, err := builder.Grants(ctx, monitoring, &pagination.Token{})
+	require.NoError(t, err)
+	require.Len(t, grants, 8)
+
+	for _, g := range grants {
+		// Every grant must reference an entitlement of the cluster
+		assert.True(t, entitlementIDs[g.Entitlement.Id], g.Entitlement.Id)
+		assert.Equal(t, "system:monitoring", g.Principal.Id.Resource)
+
+		metadata := &v2.GrantMetadata{}
+		annos := annotations.Annotations(g.Annotations)
+		ok, err := annos.Pick(metadata)
+		require.NoError(t, err)
+		require.True(t, ok)
+		url := metadata.Metadata.AsMap()[GrantMetadataNonResourceURL]
+		assert.Equal(t, "cluster:cluster:"+nonResourceEntitlementSlug(url.(string), "get"), g.Entitlement.Id)
+		assert.Equal(t, "get", metadata.Metadata.AsMap()[GrantMetadataNonResourceVerb])
+	}
+
+	// Without the cluster resource type no non-resource grants are emitted
+	builder = newClusterRoleBuilder(client, newMockClusterRoleBindingProvider(), ConnectorOpts{SyncResources: []string{ResourceTypeClusterRole.Id}}, nil)
+	grants, _, _, err = builder.Grants(ctx, monitoring, &pagination.Token{})
+	require.NoError(t, err)
+	assert.Empty(t, grants)
+}
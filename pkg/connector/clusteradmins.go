@@ -0,0 +1,307 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// ClusterAdminsReportID is the ID of the report listing the admin-equivalent principals.
+	ClusterAdminsReportID = "cluster-admins"
+	// clusterAdminsReportLimit is the maximum number of principals listed in the report.
+	clusterAdminsReportLimit = 1000
+
+	// Keys of the report profile.
+	ReportProfilePrincipals     = "principals"
+	ReportProfilePrincipalCount = "principalCount"
+	ReportProfileTruncated      = "truncated"
+	ReportProfilePrincipalType  = "principalType"
+	ReportProfilePrincipal      = "principal"
+	ReportProfileReasons        = "reasons"
+	ReportProfileReason         = "reason"
+	ReportProfileClusterRole    = "clusterRole"
+	ReportProfileBinding        = "binding"
+)
+
+// Reasons a principal is admin-equivalent, recorded in the report.
+const (
+	// AdminReasonClusterAdmin is a ClusterRoleBinding to cluster-admin.
+	AdminReasonClusterAdmin = "cluster-admin"
+	// AdminReasonWildcardRole is a ClusterRoleBinding to a ClusterRole granting every verb on every resource.
+	AdminReasonWildcardRole = "wildcard-role"
+	// AdminReasonEscalation is a ClusterRoleBinding to a ClusterRole able to bind or escalate ClusterRoles, or
+	// to impersonate users, groups or service accounts.
+	AdminReasonEscalation = "escalation"
+	// AdminReasonSystemMasters is the full access Kubernetes hard-codes for the system:masters group.
+	AdminReasonSystemMasters = "system-masters"
+)
+
+// escalationRule is a permission letting a subject grant itself any other permission.
+type escalationRule struct {
+	apiGroup  string
+	resources []string
+	verbs     []string
+}
+
+// escalationRules are the permissions that make a subject bound cluster-wide admin-equivalent.
+var escalationRules = []escalationRule{
+	{apiGroup: RBACAPIGroup, resources: []string{"clusterroles"}, verbs: roleEscalationVerbs},
+	{apiGroup: "", resources: []string{"users", "groups", "serviceaccounts"}, verbs: []string{"impersonate"}},
+}
+
+// adminEquivalence returns the reason the subjects of a ClusterRoleBinding to the ClusterRole are
+// admin-equivalent, and reports whether they are. Rules limited to resourceNames don't count.
+func adminEquivalence(clusterRole *rbacv1.ClusterRole) (string, bool) {
+	if clusterRole.Name == clusterAdminRole {
+		return AdminReasonClusterAdmin, true
+	}
+
+	for _, rule := range clusterRole.Rules {
+		if len(rule.ResourceNames) == 0 &&
+			slices.Contains(rule.APIGroups, rbacv1.APIGroupAll) &&
+			slices.Contains(rule.Resources, rbacv1.ResourceAll) &&
+			slices.Contains(rule.Verbs, rbacv1.VerbAll) {
+			return AdminReasonWildcardRole, true
+		}
+	}
+
+	for _, rule := range clusterRole.Rules {
+		if len(rule.ResourceNames) > 0 {
+			continue
+		}
+		for _, escalation := range escalationRules {
+			if ruleCoversAny(rule, escalation) {
+				return AdminReasonEscalation, true
+			}
+		}
+	}
+	return "", false
+}
+
+// ruleCoversAny reports whether a rule grants any of the verbs of the escalation on any of its resources.
+func ruleCoversAny(rule rbacv1.PolicyRule, escalation escalationRule) bool {
+	if !slices.Contains(rule.APIGroups, rbacv1.APIGroupAll) && !slices.Contains(rule.APIGroups, escalation.apiGroup) {
+		return false
+	}
+	if !grantsAnyVerb(rule.Verbs, escalation.verbs) {
+		return false
+	}
+	for _, resource := range rule.Resources {
+		if resource == rbacv1.ResourceAll || slices.Contains(escalation.resources, resource) {
+			return true
+		}
+	}
+	return false
+}
+
+// adminReason is why a principal is admin-equivalent.
+type adminReason struct {
+	reason      string
+	clusterRole string
+	binding     string
+}
+
+// clusterAdmin is an admin-equivalent principal and the reasons it is.
+type clusterAdmin struct {
+	principal *v2.ResourceId
+	reasons   []adminReason
+}
+
+// clusterAdminsReportBuilder syncs the cluster-admins report, computed from the ClusterRoles and the bindings
+// cache the role builders share. It holds no state between calls and is safe for concurrent use.
+type clusterAdminsReportBuilder struct {
+	client          kubernetes.Interface
+	bindingProvider ClusterRoleBindingLister
+	opts            ConnectorOpts
+	// limit is the maximum number of principals listed in the report.
+	limit int
+}
+
+// ResourceType returns the resource type for reports.
+func (b *clusterAdminsReportBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
+	return ResourceTypeReport
+}
+
+// List returns the cluster-admins report.
+func (b *clusterAdminsReportBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, _ *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	if parentResourceID != nil {
+		return nil, "", nil, nil
+	}
+
+	admins, err := b.clusterAdmins(ctx)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	resource, err := b.reportResource(admins)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return []*v2.Resource{resource}, "", nil, nil
+}
+
+// clusterAdmins returns the admin-equivalent principals sorted by resource type and ID.
+func (b *clusterAdminsReportBuilder) clusterAdmins(ctx context.Context) ([]clusterAdmin, error) {
+	l := ctxzap.Extract(ctx)
+
+	reasons := make(map[string]string)
+	err := listPages(b.opts.pageSize(ResourceTypeClusterRole.Id), func(opts metav1.ListOptions) (string, error) {
+		resp, err := b.client.RbacV1().ClusterRoles().List(ctx, opts)
+		if err != nil {
+			return "", fmt.Errorf("failed to list cluster roles: %w", err)
+		}
+		for i := range resp.Items {
+			if reason, ok := adminEquivalence(&resp.Items[i]); ok {
+				reasons[resp.Items[i].Name] = reason
+			}
+		}
+		return resp.Continue, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	bindings, err := b.bindingProvider.GetClusterRoleBindings(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cluster role bindings: %w", err)
+	}
+
+	byPrincipal := make(map[string]*clusterAdmin)
+	add := func(subject rbacv1.Subject, reason adminReason, opts ConnectorOpts) {
+		principal, err := subjectPrincipalID(subject, opts)
+		if err != nil {
+			l.Debug("subject kind not supported", zap.String("subject kind", subject.Kind))
+			return
+		}
+		key := resourceIDKey(principal)
+		admin, ok := byPrincipal[key]
+		if !ok {
+			admin = &clusterAdmin{principal: principal}
+			byPrincipal[key] = admin
+		}
+		admin.reasons = append(admin.reasons, reason)
+	}
+
+	// system:masters controls the cluster whether or not system subjects are included, as in its implicit grant
+	masters := rbacv1.Subject{Kind: SubjectKindGroup, APIGroup: RBACAPIGroup, Name: SystemMastersGroup}
+	add(masters, adminReason{reason: AdminReasonSystemMasters}, ConnectorOpts{IncludeSystemSubjects: true})
+
+	for _, binding := range bindings {
+		if binding.RoleRef.Kind != RoleRefKindClusterRole {
+			continue
+		}
+		reason, ok := reasons[binding.RoleRef.Name]
+		if !ok {
+			continue
+		}
+		for _, subject := range binding.Subjects {
+			add(subject, adminReason{reason: reason, clusterRole: binding.RoleRef.Name, binding: binding.Name}, b.opts)
+		}
+	}
+
+	rv := make([]clusterAdmin, 0, len(byPrincipal))
+	for _, admin := range byPrincipal {
+		sort.Slice(admin.reasons, func(i, j int) bool {
+			if admin.reasons[i].clusterRole != admin.reasons[j].clusterRole {
+				return admin.reasons[i].clusterRole < admin.reasons[j].clusterRole
+			}
+			return admin.reasons[i].binding < admin.reasons[j].binding
+		})
+		rv = append(rv, *admin)
+	}
+	sort.Slice(rv, func(i, j int) bool {
+		return resourceIDKey(rv[i].principal) < resourceIDKey(rv[j].principal)
+	})
+	return rv, nil
+}
+
+// subjectPrincipalID returns the ID of the resource a binding subject is granted as, honoring the connector
+// options on system subjects like the grants of the role builders.
+func subjectPrincipalID(subject rbacv1.Subject, opts ConnectorOpts) (*v2.ResourceId, error) {
+	g, err := grantRoleToSubject(subject, &v2.Resource{Id: &v2.ResourceId{}}, "", opts)
+	if err != nil {
+		return nil, err
+	}
+	return g.GetPrincipal().GetId(), nil
+}
+
+// reportResource creates the report resource, listing up to the limit of principals in its profile.
+func (b *clusterAdminsReportBuilder) reportResource(admins []clusterAdmin) (*v2.Resource, error) {
+	limit := b.limit
+	if limit <= 0 {
+		limit = clusterAdminsReportLimit
+	}
+	listed := admins
+	if len(listed) > limit {
+		listed = listed[:limit]
+	}
+
+	principals := make([]interface{}, 0, len(listed))
+	for _, admin := range listed {
+		reasons := make([]interface{}, 0, len(admin.reasons))
+		for _, r := range admin.reasons {
+			reason := map[string]interface{}{ReportProfileReason: r.reason}
+			if r.clusterRole != "" {
+				reason[ReportProfileClusterRole] = r.clusterRole
+				reason[ReportProfileBinding] = r.binding
+			}
+			reasons = append(reasons, reason)
+		}
+		principals = append(principals, map[string]interface{}{
+			ReportProfilePrincipalType: admin.principal.GetResourceType(),
+			ReportProfilePrincipal:     admin.principal.GetResource(),
+			ReportProfileReasons:       reasons,
+		})
+	}
+
+	profile := map[string]interface{}{
+		ReportProfilePrincipals:     principals,
+		ReportProfilePrincipalCount: len(admins),
+		ReportProfileTruncated:      len(admins) > len(listed),
+	}
+
+	resource, err := rs.NewAppResource(
+		"Effective cluster admins",
+		ResourceTypeReport,
+		ClusterAdminsReportID,
+		[]rs.AppTraitOption{rs.WithAppProfile(profile)},
+		rs.WithDescription("Principals bound cluster-wide to cluster-admin, to cluster roles granting every verb on every resource, "+
+			"or to cluster roles able to escalate to them"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cluster admins report resource: %w", err)
+	}
+	return resource, nil
+}
+
+// Entitlements returns no entitlements, the report only has a profile.
+func (b *clusterAdminsReportBuilder) Entitlements(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+// Grants returns no grants, the report only has a profile.
+func (b *clusterAdminsReportBuilder) Grants(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+// newClusterAdminsReportBuilder creates a new cluster admins report builder.
+func newClusterAdminsReportBuilder(client kubernetes.Interface, bindingProvider ClusterRoleBindingLister, opts ConnectorOpts) *clusterAdminsReportBuilder {
+	return &clusterAdminsReportBuilder{
+		client:          client,
+		bindingProvider: bindingProvider,
+		opts:            opts,
+		limit:           clusterAdminsReportLimit,
+	}
+}
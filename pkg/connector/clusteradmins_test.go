@@ -0,0 +1,161 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// clusterAdminsTestClient returns a cluster with direct, group-mediated and escalation-based admins, along
+// with subjects that aren't admin-equivalent.
+func clusterAdminsTestClient() *fake.Clientset {
+	clusterRoleBinding := func(name, role string, subjects ...rbacv1.Subject) *rbacv1.ClusterRoleBinding {
+		return &rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindClusterRole, Name: role},
+			Subjects:   subjects,
+		}
+	}
+	user := func(name string) rbacv1.Subject {
+		return rbacv1.Subject{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: name}
+	}
+
+	return fake.NewSimpleClientset(
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "cluster-admin"}, Rules: []rbacv1.PolicyRule{
+			{Verbs: []string{"*"}, APIGroups: []string{"*"}, Resources: []string{"*"}},
+		}},
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "superuser"}, Rules: []rbacv1.PolicyRule{
+			{Verbs: []string{"*"}, APIGroups: []string{"*"}, Resources: []string{"*"}},
+		}},
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "rbac-manager"}, Rules: []rbacv1.PolicyRule{
+			{Verbs: []string{"bind", "get"}, APIGroups: []string{RBACAPIGroup}, Resources: []string{"clusterroles"}},
+		}},
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "impersonator"}, Rules: []rbacv1.PolicyRule{
+			{Verbs: []string{"impersonate"}, APIGroups: []string{""}, Resources: []string{"users"}},
+		}},
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "viewer"}, Rules: []rbacv1.PolicyRule{
+			{Verbs: []string{"get", "list"}, APIGroups: []string{"*"}, Resources: []string{"*"}},
+			// Limited to one role, so not an escalation path
+			{Verbs: []string{"escalate"}, APIGroups: []string{RBACAPIGroup}, Resources: []string{"clusterroles"}, ResourceNames: []string{"viewer"}},
+		}},
+		clusterRoleBinding("alice-admin", "cluster-admin", user("alice")),
+		clusterRoleBinding("alice-superuser", "superuser", user("alice")),
+		clusterRoleBinding("platform", "superuser", rbacv1.Subject{Kind: SubjectKindGroup, APIGroup: RBACAPIGroup, Name: "platform-admins"}),
+		clusterRoleBinding("ci", "rbac-manager", rbacv1.Subject{Kind: SubjectKindServiceAccount, Name: "deployer", Namespace: "ci"}),
+		clusterRoleBinding("support", "impersonator", user("mallory")),
+		clusterRoleBinding("viewers", "viewer", user("bob")),
+		clusterRoleBinding("nodes", "superuser", user("system:node:worker-1")),
+		// A RoleBinding to cluster-admin only grants it within its namespace
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "carol-admin", Namespace: "payments"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindClusterRole, Name: "cluster-admin"},
+			Subjects:   []rbacv1.Subject{user("carol")},
+		},
+	)
+}
+
+// reportProfile returns the profile of a report resource.
+func reportProfile(t *testing.T, resource *v2.Resource) map[string]interface{} {
+	t.Helper()
+	appTrait := &v2.AppTrait{}
+	annos := annotations.Annotations(resource.Annotations)
+	ok, err := annos.Pick(appTrait)
+	require.NoError(t, err)
+	require.True(t, ok)
+	return appTrait.Profile.AsMap()
+}
+
+// TestClusterAdminsReport tests that the report lists the principals bound cluster-wide to cluster-admin,
+// wildcard or escalation-capable cluster roles, directly or through a group, and why.
+func TestClusterAdminsReport(t *testing.T) {
+	ctx := context.Background()
+	client := clusterAdminsTestClient()
+	builder := newClusterAdminsReportBuilder(client, newTestKubernetes(client, ConnectorOpts{}), ConnectorOpts{})
+
+	resources, next, _, err := builder.List(ctx, nil, &pagination.Token{})
+	require.NoError(t, err)
+	assert.Empty(t, next)
+	require.Len(t, resources, 1)
+	assert.Equal(t, ClusterAdminsReportID, resources[0].Id.Resource)
+	assert.Equal(t, ResourceTypeReport.Id, resources[0].Id.ResourceType)
+
+	profile := reportProfile(t, resources[0])
+	assert.Equal(t, float64(5), profile[ReportProfilePrincipalCount])
+	assert.Equal(t, false, profile[ReportProfileTruncated])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{
+			ReportProfilePrincipalType: ResourceTypeKubeGroup.Id,
+			ReportProfilePrincipal:     "platform-admins",
+			ReportProfileReasons: []interface{}{
+				map[string]interface{}{ReportProfileReason: AdminReasonWildcardRole, ReportProfileClusterRole: "superuser", ReportProfileBinding: "platform"},
+			},
+		},
+		map[string]interface{}{
+			ReportProfilePrincipalType: ResourceTypeKubeGroup.Id,
+			ReportProfilePrincipal:     SystemMastersGroup,
+			ReportProfileReasons: []interface{}{
+				map[string]interface{}{ReportProfileReason: AdminReasonSystemMasters},
+			},
+		},
+		map[string]interface{}{
+			ReportProfilePrincipalType: ResourceTypeKubeUser.Id,
+			ReportProfilePrincipal:     "alice",
+			ReportProfileReasons: []interface{}{
+				map[string]interface{}{ReportProfileReason: AdminReasonClusterAdmin, ReportProfileClusterRole: "cluster-admin", ReportProfileBinding: "alice-admin"},
+				map[string]interface{}{ReportProfileReason: AdminReasonWildcardRole, ReportProfileClusterRole: "superuser", ReportProfileBinding: "alice-superuser"},
+			},
+		},
+		map[string]interface{}{
+			ReportProfilePrincipalType: ResourceTypeKubeUser.Id,
+			ReportProfilePrincipal:     "mallory",
+			ReportProfileReasons: []interface{}{
+				map[string]interface{}{ReportProfileReason: AdminReasonEscalation, ReportProfileClusterRole: "impersonator", ReportProfileBinding: "support"},
+			},
+		},
+		map[string]interface{}{
+			ReportProfilePrincipalType: ResourceTypeServiceAccount.Id,
+			ReportProfilePrincipal:     "ci/deployer",
+			ReportProfileReasons: []interface{}{
+				map[string]interface{}{ReportProfileReason: AdminReasonEscalation, ReportProfileClusterRole: "rbac-manager", ReportProfileBinding: "ci"},
+			},
+		},
+	}, profile[ReportProfilePrincipals])
+}
+
+// TestClusterAdminsReport_Options tests that the report follows the options on system subjects and is capped.
+func TestClusterAdminsReport_Options(t *testing.T) {
+	ctx := context.Background()
+	client := clusterAdminsTestClient()
+
+	principals := func(profile map[string]interface{}) []string {
+		var rv []string
+		for _, p := range profile[ReportProfilePrincipals].([]interface{}) {
+			entry := p.(map[string]interface{})
+			rv = append(rv, entry[ReportProfilePrincipalType].(string)+":"+entry[ReportProfilePrincipal].(string))
+		}
+		return rv
+	}
+
+	opts := ConnectorOpts{IncludeSystemSubjects: true, SeparateSystemUsers: true}
+	builder := newClusterAdminsReportBuilder(client, newTestKubernetes(client, opts), opts)
+	resources, _, _, err := builder.List(ctx, nil, &pagination.Token{})
+	require.NoError(t, err)
+	assert.Contains(t, principals(reportProfile(t, resources[0])), "kube_system_user:system:node:worker-1")
+
+	builder = newClusterAdminsReportBuilder(client, newTestKubernetes(client, ConnectorOpts{}), ConnectorOpts{})
+	builder.limit = 2
+	resources, _, _, err = builder.List(ctx, nil, &pagination.Token{})
+	require.NoError(t, err)
+	profile := reportProfile(t, resources[0])
+	assert.Equal(t, []string{"kube_group:platform-admins", "kube_group:system:masters"}, principals(profile))
+	assert.Equal(t, float64(5), profile[ReportProfilePrincipalCount])
+	assert.Equal(t, true, profile[ReportProfileTruncated])
+}
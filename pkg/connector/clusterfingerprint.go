@@ -0,0 +1,137 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+// clusterFingerprintFileName is the name of the file the fingerprint of the synced cluster is recorded in.
+const clusterFingerprintFileName = "baton-kubernetes-cluster.json"
+
+// clusterFingerprint identifies the cluster a connector syncs.
+type clusterFingerprint struct {
+	// ClusterID is the UID of the kube-system namespace.
+	ClusterID string `json:"clusterId"`
+	// Server is the host of the API server, its hostname and port.
+	Server string `json:"server"`
+}
+
+// String formats the fingerprint for error messages.
+func (f clusterFingerprint) String() string {
+	return fmt.Sprintf("cluster %s at %s", f.ClusterID, f.Server)
+}
+
+// clusterFingerprint returns the fingerprint of the cluster the connector is configured for.
+func (k *Kubernetes) clusterFingerprint(ctx context.Context) (clusterFingerprint, error) {
+	clusterID, err := k.ClusterID(ctx)
+	if err != nil {
+		return clusterFingerprint{}, err
+	}
+	var server string
+	if k.config != nil {
+		server = apiServerHost(k.config.Host)
+	}
+	return clusterFingerprint{ClusterID: clusterID, Server: server}, nil
+}
+
+// apiServerHost returns the hostname and port of the API server at the server URL of a kubeconfig, which may
+// have no scheme, so that the scheme, the path or the case of the URL don't change the fingerprint. URLs that
+// don't parse are kept as they are.
+func apiServerHost(server string) string {
+	withScheme := server
+	if !strings.Contains(server, "://") {
+		withScheme = "https://" + server
+	}
+	u, err := url.Parse(withScheme)
+	if err != nil || u.Host == "" {
+		return server
+	}
+	return strings.ToLower(u.Host)
+}
+
+// checkClusterFingerprint compares the fingerprint of the cluster with the one recorded by previous runs, so
+// that a connector pointed at another cluster doesn't merge the data of both. A changed fingerprint fails with
+// ErrClusterChanged unless the change is accepted, in which case the new fingerprint is recorded.
+func (k *Kubernetes) checkClusterFingerprint(ctx context.Context) error {
+	if k.opts.ClusterFingerprintDir == "" {
+		return nil
+	}
+	l := ctxzap.Extract(ctx)
+	path := filepath.Join(k.opts.ClusterFingerprintDir, clusterFingerprintFileName)
+
+	current, err := k.clusterFingerprint(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fingerprint the cluster: %w", err)
+	}
+
+	recorded, err := readClusterFingerprint(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		l.Info("recording the cluster fingerprint", zap.String("path", path), zap.Stringer("fingerprint", current))
+		return writeClusterFingerprint(path, current)
+	case err != nil:
+		return err
+	case recorded == current:
+		return nil
+	case !k.opts.AcceptClusterChange:
+		return fmt.Errorf("%w: previous runs synced %s, the connector is now configured for %s", ErrClusterChanged, recorded, current)
+	}
+
+	l.Warn("the synced cluster changed, recording the new cluster fingerprint",
+		zap.Stringer("previous", recorded), zap.Stringer("current", current))
+	return writeClusterFingerprint(path, current)
+}
+
+// readClusterFingerprint reads a recorded cluster fingerprint.
+func readClusterFingerprint(path string) (clusterFingerprint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return clusterFingerprint{}, err
+	}
+	var fingerprint clusterFingerprint
+	if err := json.Unmarshal(data, &fingerprint); err != nil {
+		return clusterFingerprint{}, fmt.Errorf("failed to decode cluster fingerprint %s: %w", path, err)
+	}
+	// Fingerprints recorded before only the host was recorded hold the full server URL
+	fingerprint.Server = apiServerHost(fingerprint.Server)
+	return fingerprint, nil
+}
+
+// writeClusterFingerprint records a cluster fingerprint, replacing the file atomically.
+func writeClusterFingerprint(path string, fingerprint clusterFingerprint) error {
+	data, err := json.Marshal(fingerprint)
+	if err != nil {
+		return fmt.Errorf("failed to encode cluster fingerprint: %w", err)
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, clusterFingerprintFileName+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create cluster fingerprint file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cluster fingerprint: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cluster fingerprint: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace cluster fingerprint: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package connector
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+// newFingerprintedKubernetes returns a connector for a fake cluster with the given kube-system UID and API
+// server, recording its fingerprint in dir.
+func newFingerprintedKubernetes(uid types.UID, server, dir string, accept bool) *Kubernetes {
+	client := fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: metav1.NamespaceSystem, UID: uid}})
+	reviewAccess(client, allowAccessExcept())
+	k := newTestKubernetes(client, ConnectorOpts{ClusterFingerprintDir: dir, AcceptClusterChange: accept})
+	k.config = &rest.Config{Host: server}
+	return k
+}
+
+// TestValidate_ClusterFingerprint tests that validation records the fingerprint of the cluster, and fails once
+// the connector is pointed at another cluster unless the change is accepted.
+func TestValidate_ClusterFingerprint(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// The first run records the staging cluster, and later runs against it pass
+	_, err := newFingerprintedKubernetes("staging-uid", "https://staging.example.com", dir, false).Validate(ctx)
+	require.NoError(t, err)
+	_, err = newFingerprintedKubernetes("staging-uid", "https://staging.example.com", dir, false).Validate(ctx)
+	require.NoError(t, err)
+
+	// Pointing the connector at production is refused
+	_, err = newFingerprintedKubernetes("production-uid", "https://production.example.com", dir, false).Validate(ctx)
+	require.ErrorIs(t, err, ErrClusterChanged)
+	assert.Contains(t, err.Error(), "staging-uid")
+	assert.Contains(t, err.Error(), "production-uid")
+
+	// Another cluster behind the same API server host is refused too
+	_, err = newFingerprintedKubernetes("rebuilt-uid", "https://staging.example.com", dir, false).Validate(ctx)
+	require.ErrorIs(t, err, ErrClusterChanged)
+
+	// Accepting the change records production, which later runs then expect
+	_, err = newFingerprintedKubernetes("production-uid", "https://production.example.com", dir, true).Validate(ctx)
+	require.NoError(t, err)
+	_, err = newFingerprintedKubernetes("production-uid", "https://production.example.com", dir, false).Validate(ctx)
+	require.NoError(t, err)
+	_, err = newFingerprintedKubernetes("staging-uid", "https://staging.example.com", dir, false).Validate(ctx)
+	require.ErrorIs(t, err, ErrClusterChanged)
+}
+
+// TestValidate_ClusterFingerprintEquivalentServer tests that the same cluster is recognized behind a server URL
+// spelled differently, and that fingerprints recorded with the full server URL are still recognized.
+func TestValidate_ClusterFingerprintEquivalentServer(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	_, err := newFingerprintedKubernetes("staging-uid", "https://staging.example.com:6443", dir, false).Validate(ctx)
+	require.NoError(t, err)
+	recorded, err := readClusterFingerprint(filepath.Join(dir, clusterFingerprintFileName))
+	require.NoError(t, err)
+	assert.Equal(t, "staging.example.com:6443", recorded.Server)
+
+	for _, server := range []string{
+		"https://staging.example.com:6443/",
+		"http://staging.example.com:6443",
+		"HTTPS://Staging.Example.com:6443",
+		"staging.example.com:6443",
+	} {
+		_, err = newFingerprintedKubernetes("staging-uid", server, dir, false).Validate(ctx)
+		assert.NoError(t, err, server)
+	}
+
+	// Another port is another API server
+	_, err = newFingerprintedKubernetes("staging-uid", "https://staging.example.com:8443", dir, false).Validate(ctx)
+	require.ErrorIs(t, err, ErrClusterChanged)
+
+	legacy := t.TempDir()
+	require.NoError(t, writeClusterFingerprint(filepath.Join(legacy, clusterFingerprintFileName),
+		clusterFingerprint{ClusterID: "staging-uid", Server: "https://staging.example.com:6443/"}))
+	_, err = newFingerprintedKubernetes("staging-uid", "https://staging.example.com:6443", legacy, false).Validate(ctx)
+	require.NoError(t, err)
+}
+
+// TestValidate_ClusterFingerprintDisabled tests that nothing is recorded without a fingerprint directory.
+func TestValidate_ClusterFingerprintDisabled(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := newFingerprintedKubernetes("staging-uid", "https://staging.example.com", "", false).Validate(ctx)
+	require.NoError(t, err)
+	_, err = newFingerprintedKubernetes("production-uid", "https://production.example.com", "", false).Validate(ctx)
+	require.NoError(t, err)
+}
@@ -0,0 +1,60 @@
+package connector
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterID returns an identifier of the synced cluster, the UID of its kube-system namespace. The namespace is
+// created with the cluster and can't be deleted, so its UID is stable for the lifetime of the cluster. It is
+// fetched once and cached.
+func (k *Kubernetes) ClusterID(ctx context.Context) (string, error) {
+	k.clusterIDMutex.Lock()
+	defer k.clusterIDMutex.Unlock()
+
+	if k.clusterID != "" {
+		return k.clusterID, nil
+	}
+
+	ns, err := k.client.CoreV1().Namespaces().Get(ctx, metav1.NamespaceSystem, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get the %s namespace: %w", metav1.NamespaceSystem, err)
+	}
+	if ns.UID == "" {
+		return "", fmt.Errorf("the %s namespace has no UID", metav1.NamespaceSystem)
+	}
+	k.clusterID = string(ns.UID)
+	return k.clusterID, nil
+}
+
+// subjectClusterID returns the cluster ID the external IDs of users and groups are derived from, or "" if there
+// is no provider or the cluster ID can't be read, in which case the subjects are synced without external IDs.
+func subjectClusterID(ctx context.Context, provider ClusterIDProvider) string {
+	if provider == nil {
+		return ""
+	}
+	clusterID, err := provider.ClusterID(ctx)
+	if err != nil {
+		ctxzap.Extract(ctx).Warn("syncing users and groups without external IDs, the cluster ID is unavailable", zap.Error(err))
+		return ""
+	}
+	return clusterID
+}
+
+// subjectExternalID returns the external ID of a user or group, which Kubernetes assigns no UID. It is a hash of
+// the cluster ID, the subject kind and the name, so it is stable across syncs and distinct across clusters.
+func subjectExternalID(clusterID, kind, name string) *v2.ExternalId {
+	h := sha256.New()
+	for _, part := range []string{clusterID, kind, name} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return &v2.ExternalId{Id: hex.EncodeToString(h.Sum(nil))}
+}
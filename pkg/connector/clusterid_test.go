@@ -0,0 +1,98 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// clusterIDClient returns a client of a cluster whose kube-system namespace has the given UID, with a binding of a
+// user and a group, and a counter of the reads of the namespace.
+func clusterIDClient(kubeSystemUID string) (*fake.Clientset, *int) {
+	objects := []runtime.Object{
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "viewers"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindClusterRole, Name: "view"},
+			Subjects: []rbacv1.Subject{
+				{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "alice"},
+				{Kind: SubjectKindGroup, APIGroup: RBACAPIGroup, Name: "devs"},
+			},
+		},
+	}
+	if kubeSystemUID != "" {
+		objects = append(objects, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: metav1.NamespaceSystem, UID: types.UID(kubeSystemUID)}})
+	}
+	client := fake.NewSimpleClientset(objects...)
+	gets := 0
+	client.PrependReactor("get", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		gets++
+		return false, nil, nil
+	})
+	return client, &gets
+}
+
+// syncedExternalIDs returns the external IDs of the users and groups synced by a connector, keyed by resource ID.
+func syncedExternalIDs(ctx context.Context, t *testing.T, k *Kubernetes) map[string]string {
+	rv := make(map[string]string)
+	for _, syncer := range []connectorbuilder.ResourceSyncer{
+		newKubeUserBuilder(k.client, k, k.opts),
+		newKubeGroupBuilder(k.client, k, k.opts),
+	} {
+		for _, resource := range listResources(ctx, t, syncer) {
+			if resource.Id.Resource == "*" {
+				continue
+			}
+			rv[resourceIDKey(resource.Id)] = resource.GetExternalId().GetId()
+		}
+	}
+	return rv
+}
+
+func TestSubjectExternalIDs(t *testing.T) {
+	ctx := context.Background()
+
+	client, gets := clusterIDClient("cluster-a-uid")
+	first := syncedExternalIDs(ctx, t, newTestKubernetes(client, ConnectorOpts{}))
+	assert.Equal(t, 1, *gets, "the cluster ID is read once per connector")
+	assert.NotEmpty(t, first["kube_user:alice"])
+	assert.NotEmpty(t, first["kube_group:devs"])
+	assert.NotEqual(t, first["kube_user:alice"], first["kube_group:devs"])
+	assert.Equal(t, subjectExternalID("cluster-a-uid", SubjectKindUser, "alice").Id, first["kube_user:alice"])
+
+	// Stable across syncs of the same cluster
+	second := syncedExternalIDs(ctx, t, newTestKubernetes(client, ConnectorOpts{}))
+	assert.Equal(t, first, second)
+
+	// Distinct across clusters
+	other, _ := clusterIDClient("cluster-b-uid")
+	otherIDs := syncedExternalIDs(ctx, t, newTestKubernetes(other, ConnectorOpts{}))
+	assert.NotEqual(t, first["kube_user:alice"], otherIDs["kube_user:alice"])
+	assert.NotEqual(t, first["kube_group:devs"], otherIDs["kube_group:devs"])
+}
+
+func TestSubjectExternalIDs_NoClusterID(t *testing.T) {
+	ctx := context.Background()
+
+	// Without access to kube-system the subjects are still synced, without external IDs
+	client, _ := clusterIDClient("")
+	k := newTestKubernetes(client, ConnectorOpts{})
+	_, err := k.ClusterID(ctx)
+	require.Error(t, err)
+
+	ids := syncedExternalIDs(ctx, t, k)
+	assert.Contains(t, ids, "kube_user:alice")
+	assert.Contains(t, ids, "kube_group:devs")
+	for id, externalID := range ids {
+		assert.Empty(t, externalID, id)
+	}
+}
@@ -3,10 +3,12 @@ package connector
 import (
 	"context"
 	"fmt"
+	"math"
+	"strings"
 	"sync"
-	"time"
 
 	rbacv1 "k8s.io/api/rbac/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 
@@ -14,22 +16,55 @@ import (
 	"github.com/conductorone/baton-sdk/pkg/annotations"
 	"github.com/conductorone/baton-sdk/pkg/pagination"
 	"github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
 	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
 	"go.uber.org/zap"
 )
 
-const namespaceCacheTTL = 5 * time.Minute
 const clusterScopedMember = "all:member"
 
-// clusterRoleBuilder syncs Kubernetes ClusterRoles as Baton resources.
+// otherNamespacesMember is the catch-all entitlement of the bindings in namespaces not matching the namespace
+// entitlement selector. The underscore keeps it from being the "<namespace>:member" entitlement of a namespace.
+const otherNamespacesMember = "other_namespaces:member"
+
+// namespacedMember is the single entitlement of the bindings in every namespace with compact ClusterRole
+// entitlements. The namespace of each binding is recorded in the binding metadata of the grants. Like
+// otherNamespacesMember, it has an underscore no namespace name can have.
+const namespacedMember = "namespaced_bindings:member"
+
+// namespaceEntitlementsPageSize is the number of namespaces whose ClusterRole membership entitlements are
+// returned per page.
+const namespaceEntitlementsPageSize = 500
+
+// clusterRoleBuilder syncs Kubernetes ClusterRoles as Baton resources. It's safe for concurrent use: the
+// namespaces and ClusterRoles caches are read through cacheNamespaces and cacheClusterRoles, which return
+// snapshots taken under their mutexes, and the bindings come from the connector's locked caches.
+//
+// The namespaces are snapshotted once per sync, so that every ClusterRole of a sync gets the same namespace
+// entitlements. The connector shares its snapshot and ClusterRoles cache with the builder and resets them when
+// a new sync starts.
 type clusterRoleBuilder struct {
 	client          kubernetes.Interface
 	bindingProvider ClusterRoleBindingProvider
-	// Cached namespaces
-	cachedNamespaces []string
-	nsMutex          sync.Mutex
-	nsCacheExpiry    time.Time
+	opts            ConnectorOpts
+	stats           *syncStats
+	saGroups        *serviceAccountGroupExpander
+	progress        *progressReporter
+	// Namespaces of the sync, a snapshot owned by the builder if the connector doesn't share its own
+	namespaces *namespaceSnapshot
+	nsMutex    sync.Mutex
+	// ClusterRoles aggregation rules are resolved against, a cache owned by the builder if the connector doesn't
+	// share its own
+	clusterRoles *clusterRoleCache
+}
+
+// clusterRoleNamespaces are the namespaces ClusterRoles can be bound in, and the ones matching the namespace
+// entitlement selector if one is configured. The cache replaces them rather than modifying them when it's
+// refreshed, so they can be read without holding the lock.
+type clusterRoleNamespaces struct {
+	names    []string
+	selected map[string]bool
 }
 
 // ResourceType returns the resource type for ClusterRole.
@@ -50,22 +85,41 @@ func (c *clusterRoleBuilder) List(ctx context.Context, parentResourceID *v2.Reso
 		return nil, "", nil, fmt.Errorf("failed to parse page token: %w", err)
 	}
 
+	// Add wildcard resource first, but only on the first page (when page token is empty)
+	if bag.PageToken() == "" && !c.opts.DisableWildcardResources {
+		wildcardResource, err := generateWildcardResource(ResourceTypeClusterRole, "")
+		if err != nil {
+			l.Error("failed to create wildcard resource for cluster roles", zap.Error(err))
+		} else {
+			rv = append(rv, wildcardResource)
+		}
+	}
+
 	// Set up list options with pagination
 	opts := metav1.ListOptions{
-		Limit:    ResourcesPageSize,
+		Limit:    c.opts.pageSize(ResourceTypeClusterRole.Id),
 		Continue: bag.PageToken(),
 	}
 
 	// Fetch cluster roles from the Kubernetes API
 	l.Debug("fetching cluster roles", zap.String("continue_token", opts.Continue))
-	resp, err := c.client.RbacV1().ClusterRoles().List(ctx, opts)
+	resp, err := listWithRetry(ctx, c.opts, func(ctx context.Context) (*rbacv1.ClusterRoleList, error) {
+		return c.client.RbacV1().ClusterRoles().List(ctx, opts)
+	})
 	if err != nil {
 		return nil, "", nil, fmt.Errorf("failed to list cluster roles: %w", err)
 	}
 
 	// Process each cluster role into a Baton resource
 	for _, clusterRole := range resp.Items {
-		resource, err := clusterRoleResource(&clusterRole)
+		if c.opts.SkipSystemClusterRoles && isSystemClusterRole(clusterRole.Name) {
+			continue
+		}
+		aggregation, err := c.aggregation(ctx, &clusterRole)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		resource, err := clusterRoleResource(&clusterRole, c.opts, aggregation)
 		if err != nil {
 			l.Error("failed to create cluster role resource",
 				zap.String("name", clusterRole.Name),
@@ -84,15 +138,31 @@ func (c *clusterRoleBuilder) List(ctx context.Context, parentResourceID *v2.Reso
 	return rv, nextPageToken, nil, nil
 }
 
-// clusterRoleResource creates a Baton resource from a Kubernetes ClusterRole.
-func clusterRoleResource(clusterRole *rbacv1.ClusterRole) (*v2.Resource, error) {
+// aggregation resolves the aggregation rule of a ClusterRole against the cached ClusterRoles. It returns nil
+// for ClusterRoles without an aggregation rule.
+func (c *clusterRoleBuilder) aggregation(ctx context.Context, clusterRole *rbacv1.ClusterRole) (*clusterRoleAggregation, error) {
+	if clusterRole.AggregationRule == nil {
+		return nil, nil
+	}
+	clusterRoles, err := c.cacheClusterRoles(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cache cluster roles: %w", err)
+	}
+	return resolveAggregation(clusterRole, clusterRoles)
+}
+
+// clusterRoleResource creates a Baton resource from a Kubernetes ClusterRole. The resolved aggregation, if
+// any, adds the effective rules and contributing ClusterRoles of an aggregated ClusterRole to the profile.
+func clusterRoleResource(clusterRole *rbacv1.ClusterRole, opts ConnectorOpts, aggregation *clusterRoleAggregation) (*v2.Resource, error) {
 	// Prepare profile with standard metadata
 	profile := map[string]interface{}{
 		"name":              clusterRole.Name,
 		"uid":               string(clusterRole.UID),
 		"creationTimestamp": clusterRole.CreationTimestamp.String(),
+		"apiVersion":        RBACAPIGroupV1,
+		"resourceVersion":   clusterRole.ResourceVersion,
 		"labels":            StringMapToAnyMap(clusterRole.Labels),
-		"annotations":       StringMapToAnyMap(clusterRole.Annotations),
+		"annotations":       profileAnnotations(clusterRole.Annotations, opts, false),
 	}
 
 	// Add aggregation rule if present
@@ -103,6 +173,28 @@ func clusterRoleResource(clusterRole *rbacv1.ClusterRole) (*v2.Resource, error)
 		}
 		profile["aggregationRule"] = agRule
 	}
+	addAggregationProfile(profile, aggregation)
+	if err := addRulesProfile(profile, clusterRole.Rules, opts.profileRulesLimit()); err != nil {
+		return nil, err
+	}
+	addLabelTags(profile, clusterRole.Labels, opts)
+	repairProfile(profile)
+
+	// Tell the Kubernetes built-in roles apart from the customer-created ones
+	var resourceOpts []rs.ResourceOption
+	classification, builtIn := classifyClusterRole(clusterRole)
+	profile["builtIn"] = builtIn
+	if builtIn {
+		profile["classification"] = classification
+	}
+	if role, ok := builtInRoles[clusterRole.Name]; ok {
+		resourceOpts = append(resourceOpts, rs.WithDescription(role.description))
+	}
+
+	// Correlate the cluster role across renames by its UID
+	if len(clusterRole.UID) > 0 {
+		resourceOpts = append(resourceOpts, rs.WithExternalID(&v2.ExternalId{Id: string(clusterRole.UID)}))
+	}
 
 	// Create resource as a role - pass the name directly as the raw ID
 	resource, err := rs.NewRoleResource(
@@ -110,6 +202,7 @@ func clusterRoleResource(clusterRole *rbacv1.ClusterRole) (*v2.Resource, error)
 		ResourceTypeClusterRole,
 		clusterRole.Name, // Pass the name directly as the object ID
 		[]rs.RoleTraitOption{rs.WithRoleProfile(profile)},
+		resourceOpts...,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create cluster role resource: %w", err)
@@ -118,54 +211,120 @@ func clusterRoleResource(clusterRole *rbacv1.ClusterRole) (*v2.Resource, error)
 	return resource, nil
 }
 
-// Entitlements returns entitlements for ClusterRole resources.
-func (c *clusterRoleBuilder) Entitlements(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
-	var entitlements []*v2.Entitlement
+// Entitlements returns entitlements for ClusterRole resources. The wildcard cluster role only has the escalate
+// and bind entitlements, as it can't be bound.
+func (c *clusterRoleBuilder) Entitlements(ctx context.Context, resource *v2.Resource, pToken *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	if resource.Id.Resource == "*" {
+		return roleEscalationEntitlements(resource), "", nil, nil
+	}
 
-	// Create the 'all:member' entitlement for the cluster role for cluster level (all namespaces)
-	memberEnt := entitlement.NewAssignmentEntitlement(
-		resource,
-		clusterScopedMember,
-		entitlement.WithDisplayName(fmt.Sprintf("%s Cluster Role Member", resource.DisplayName)),
-		entitlement.WithDescription(fmt.Sprintf("Grants membership to the %s cluster role", resource.DisplayName)),
-		entitlement.WithGrantableTo(
-			ResourceTypeKubeUser,
-			ResourceTypeKubeGroup,
-			ResourceTypeServiceAccount,
-		),
-	)
-	entitlements = append(entitlements, memberEnt)
+	if c.opts.CompactClusterRoleEntitlements {
+		return c.compactEntitlements(resource), "", nil, nil
+	}
 
 	// Each ClusterRole can be granted in a RoleBinding, thus binding it to a namespace.
-	// Create entitlements for each namespace.
-	err := c.cacheNamespaces(ctx)
+	// Create entitlements for each namespace, a page of namespaces at a time.
+	cached, err := c.cacheNamespaces(ctx)
 	if err != nil {
 		return nil, "", nil, fmt.Errorf("failed to cache namespaces: %w", err)
 	}
+	var namespaces []string
+	for _, ns := range cached.names {
+		if cached.selected == nil || cached.selected[ns] {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	pageNamespaces, nextPageToken, err := paginateItems(namespaces, pToken, namespaceEntitlementsPageSize)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	var entitlements []*v2.Entitlement
 
-	for _, ns := range c.cachedNamespaces {
+	// The entitlements not tied to a namespace come with the first page
+	if pToken == nil || pToken.Token == "" {
+		// Create the 'all:member' entitlement for the cluster role for cluster level (all namespaces)
+		memberEnt := entitlement.NewAssignmentEntitlement(
+			resource,
+			clusterScopedMember,
+			entitlement.WithDisplayName(fmt.Sprintf("%s Cluster Role Member", resource.DisplayName)),
+			entitlement.WithDescription(fmt.Sprintf("Grants membership to the %s cluster role", resource.DisplayName)),
+			entitlement.WithGrantableTo(memberGrantableTo(c.opts)...),
+		)
+		entitlements = append(entitlements, memberEnt)
+		entitlements = append(entitlements, roleEscalationEntitlements(resource)...)
+
+		// Bindings in the namespaces not matching the selector are granted a single catch-all entitlement
+		if cached.selected != nil && !c.opts.DropUnselectedNamespaceGrants {
+			otherEnt := entitlement.NewAssignmentEntitlement(
+				resource,
+				otherNamespacesMember,
+				entitlement.WithDisplayName(fmt.Sprintf("\"%s\" Cluster Role Member in other namespaces", resource.DisplayName)),
+				entitlement.WithDescription(fmt.Sprintf("Membership of the \"%s\" cluster role in namespaces without their own entitlement", resource.DisplayName)),
+				entitlement.WithGrantableTo(memberGrantableTo(c.opts)...),
+			)
+			entitlements = append(entitlements, otherEnt)
+		}
+	}
+
+	for _, ns := range pageNamespaces {
 		entitlementName := fmt.Sprintf("%s:%s", ns, "member")
 		nsEnt := entitlement.NewAssignmentEntitlement(
 			resource,
 			entitlementName,
 			entitlement.WithDisplayName(fmt.Sprintf("\"%s\" Cluster Role Member in \"%s\" namespace", resource.DisplayName, ns)),
 			entitlement.WithDescription(fmt.Sprintf("Grants membership to the \"%s\" cluster role in namespace \"%s\"", resource.DisplayName, ns)),
-			entitlement.WithGrantableTo(
-				ResourceTypeKubeUser,
-				ResourceTypeKubeGroup,
-				ResourceTypeServiceAccount,
-			),
+			entitlement.WithGrantableTo(memberGrantableTo(c.opts)...),
 		)
 		entitlements = append(entitlements, nsEnt)
 	}
 
-	return entitlements, "", nil, nil
+	return entitlements, nextPageToken, nil, nil
+}
+
+// compactEntitlements returns the entitlements of a ClusterRole with compact entitlements, the cluster-wide
+// membership and a single membership for the bindings in every namespace.
+func (c *clusterRoleBuilder) compactEntitlements(resource *v2.Resource) []*v2.Entitlement {
+	entitlements := []*v2.Entitlement{
+		entitlement.NewAssignmentEntitlement(
+			resource,
+			clusterScopedMember,
+			entitlement.WithDisplayName(fmt.Sprintf("%s Cluster Role Member", resource.DisplayName)),
+			entitlement.WithDescription(fmt.Sprintf("Grants membership to the %s cluster role", resource.DisplayName)),
+			entitlement.WithGrantableTo(memberGrantableTo(c.opts)...),
+		),
+		entitlement.NewAssignmentEntitlement(
+			resource,
+			namespacedMember,
+			entitlement.WithDisplayName(fmt.Sprintf("\"%s\" Cluster Role Member in namespaces", resource.DisplayName)),
+			entitlement.WithDescription(fmt.Sprintf("Membership of the \"%s\" cluster role through RoleBindings, in the namespaces recorded in the grants", resource.DisplayName)),
+			entitlement.WithGrantableTo(memberGrantableTo(c.opts)...),
+		),
+	}
+	return append(entitlements, roleEscalationEntitlements(resource)...)
+}
+
+// namespaceEntitlement returns the membership entitlement granted by a binding of the ClusterRole in the
+// namespace, and false if the grants from bindings in the namespace are dropped. The cached namespaces are only
+// needed with a namespace entitlement selector.
+func (c *clusterRoleBuilder) namespaceEntitlement(cached clusterRoleNamespaces, namespace string) (string, bool) {
+	selected := cached.selected == nil || cached.selected[namespace]
+	switch {
+	case !selected && c.opts.DropUnselectedNamespaceGrants:
+		return "", false
+	case c.opts.CompactClusterRoleEntitlements:
+		return namespacedMember, true
+	case selected:
+		return fmt.Sprintf("%s:%s", namespace, "member"), true
+	default:
+		return otherNamespacesMember, true
+	}
 }
 
-// Grants returns permission grants for ClusterRole resources.
-func (c *clusterRoleBuilder) Grants(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+// Grants returns membership grants from the bindings of a ClusterRole, a page of bindings at a time, then the
+// membership grants it inherits and permission grants from the ClusterRole to the resources covered by its rules.
+func (c *clusterRoleBuilder) Grants(ctx context.Context, resource *v2.Resource, pToken *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
 	l := ctxzap.Extract(ctx)
-	var rv []*v2.Grant
 
 	// Extract cluster role name from resource
 	if resource.Id == nil || resource.Id.Resource == "" {
@@ -173,89 +332,590 @@ func (c *clusterRoleBuilder) Grants(ctx context.Context, resource *v2.Resource,
 	}
 	name := resource.Id.Resource
 
-	// Get matching role bindings and cluster role bindings from the binding provider
-	matchingRoleBindings, matchingClusterBindings, err := c.bindingProvider.GetMatchingBindingsForClusterRole(ctx, name)
+	// The wildcard cluster role has no bindings or rules
+	if name == "*" {
+		return nil, "", nil, nil
+	}
+
+	start, err := parseRoleGrantsPage(pToken)
 	if err != nil {
-		return nil, "", nil, fmt.Errorf("failed to get matching bindings: %w", err)
+		return nil, "", nil, err
 	}
 
-	// If there are no bindings, there are no grants
-	if len(matchingRoleBindings) == 0 && len(matchingClusterBindings) == 0 {
-		l.Debug("no bindings found for cluster role", zap.String("name", name))
-		return nil, "", nil, nil
+	// The cluster role may have been deleted since it was listed
+	if start == (roleGrantsPosition{phase: roleGrantsPhaseBindings}) {
+		if _, ok, err := c.getClusterRole(ctx, name); err != nil || !ok {
+			return nil, "", nil, err
+		}
 	}
 
-	// Process each matching cluster binding
-	for _, binding := range matchingClusterBindings {
-		// Process each subject in the binding
-		for _, subject := range binding.Subjects {
-			subjectGrant, err := GrantRoleToSubject(subject, resource, clusterScopedMember)
+	bindings, boundNamespaces, err := c.grantBindings(ctx, name)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	memberships := newBindingMemberships(bindings, c.opts)
+
+	var rv []*v2.Grant
+	if start.phase == roleGrantsPhaseBindings {
+		if len(bindings) == 0 {
+			l.Debug("no bindings found for cluster role", zap.String("name", name))
+		}
+		var next roleGrantsPosition
+		rv, next, err = bindingGrantsPage(ctx, resource, bindings, memberships, start, c.opts.grantsPageSize(), c.saGroups, c.opts)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		if next.phase == roleGrantsPhaseBindings || len(rv) >= c.opts.grantsPageSize() {
+			nextPageToken, err := roleGrantsPageToken(next)
 			if err != nil {
-				l.Debug("subject type not supported", zap.String("subject kind", subject.Kind))
-				continue
+				return nil, "", nil, err
 			}
-			rv = append(rv, subjectGrant)
+			return rv, nextPageToken, nil, nil
+		}
+	}
+
+	// The rules are expanded once, on the page the bindings end on
+	clusterRole, ok, err := c.getClusterRole(ctx, name)
+	if err != nil || !ok {
+		return rv, "", nil, err
+	}
+	var phaseGrants []*v2.Grant
+
+	// Contributing cluster roles are members wherever the aggregated cluster roles they contribute to are
+	if c.opts.InheritAggregatedBindings {
+		inherited, err := c.inheritedGrants(ctx, resource, clusterRole, memberships)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		phaseGrants = append(phaseGrants, inherited...)
+	}
+
+	// Expand the cluster role's rules into grants on the resources they cover, tracing the rules of aggregated
+	// cluster roles back to the cluster roles contributing them
+	aggregation, err := c.aggregation(ctx, clusterRole)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	var ruleGrants []*v2.Grant
+	if aggregation != nil {
+		ruleGrants, err = expandAggregatedRules(ctx, c.client, resource, clusterRoleRuleScope(boundNamespaces), clusterRole.Rules, aggregation, c.opts)
+	} else {
+		ruleGrants, err = expandPolicyRules(ctx, c.client, resource, clusterRoleRuleScope(boundNamespaces), clusterRole.Rules, c.opts)
+	}
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to expand cluster role rules: %w", err)
+	}
+	phaseGrants = append(phaseGrants, ruleGrants...)
+
+	// Grants hard-coded in Kubernetes come last, so that the grants from bindings granting the same take precedence
+	if !memberships.grantedDirectly(clusterScopedMember, rbacv1.Subject{Kind: SubjectKindGroup, Name: SystemMastersGroup}) {
+		implicitGrants, err := implicitClusterRoleGrants(resource)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		phaseGrants = append(phaseGrants, implicitGrants...)
+	}
+
+	return append(rv, uniqueGrants(phaseGrants)...), "", nil, nil
+}
+
+// getClusterRole fetches the live ClusterRole, reporting false if it was deleted since it was listed.
+func (c *clusterRoleBuilder) getClusterRole(ctx context.Context, name string) (*rbacv1.ClusterRole, bool, error) {
+	clusterRole, err := c.client.RbacV1().ClusterRoles().Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			ctxzap.Extract(ctx).Info("cluster role no longer exists, skipping grants", zap.String("name", name))
+			c.stats.Inc(StatGrantsObjectNotFound)
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to get cluster role: %w", err)
+	}
+	return clusterRole, true, nil
+}
+
+// grantBindings returns the bindings of a ClusterRole in the order their grants are paginated, the cluster role
+// bindings then the role bindings, with the entitlement each grants, and the namespaces the cluster role is bound in.
+// Role bindings in namespaces not matching the namespace entitlement selector are dropped.
+func (c *clusterRoleBuilder) grantBindings(ctx context.Context, name string) ([]roleGrantBinding, []string, error) {
+	l := ctxzap.Extract(ctx)
+
+	// Get matching role bindings and cluster role bindings from the binding provider
+	matchingRoleBindings, matchingClusterBindings, err := c.bindingProvider.GetMatchingBindingsForClusterRole(ctx, name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get matching bindings: %w", err)
+	}
+	sortRoleBindings(matchingRoleBindings)
+	sortClusterRoleBindings(matchingClusterBindings)
+
+	// The entitlements of role bindings depend on the namespaces matching the namespace entitlement selector, and
+	// named namespaced resources are resolved in every namespace the cluster role is bound in
+	var cached clusterRoleNamespaces
+	if len(matchingClusterBindings) > 0 || (c.opts.NamespaceEntitlementSelector != nil && len(matchingRoleBindings) > 0) {
+		if cached, err = c.cacheNamespaces(ctx); err != nil {
+			return nil, nil, fmt.Errorf("failed to cache namespaces: %w", err)
 		}
 	}
 
-	// Process each matching role binding
+	bindings := make([]roleGrantBinding, 0, len(matchingClusterBindings)+len(matchingRoleBindings))
+	var boundNamespaces []string
+	if len(matchingClusterBindings) > 0 {
+		boundNamespaces = append(boundNamespaces, cached.names...)
+	}
+	for _, binding := range matchingClusterBindings {
+		bindings = append(bindings, roleGrantBinding{
+			kind:     BindingKindClusterRoleBinding,
+			meta:     binding.ObjectMeta,
+			subjects: binding.Subjects,
+			entName:  clusterScopedMember,
+		})
+	}
 	for _, binding := range matchingRoleBindings {
-		namespace := binding.Namespace
-		// Process each subject in the binding
-		for _, subject := range binding.Subjects {
-			entName := fmt.Sprintf("%s:%s", namespace, "member")
-			subjectGrant, err := GrantRoleToSubject(subject, resource, entName)
+		boundNamespaces = append(boundNamespaces, binding.Namespace)
+		entName, ok := c.namespaceEntitlement(cached, binding.Namespace)
+		if !ok {
+			l.Debug("dropping grants from binding in namespace not matching the entitlement selector",
+				zap.String("namespace", binding.Namespace), zap.String("binding", binding.Name))
+			continue
+		}
+		bindings = append(bindings, roleGrantBinding{
+			kind:     BindingKindRoleBinding,
+			meta:     binding.ObjectMeta,
+			subjects: binding.Subjects,
+			entName:  entName,
+		})
+	}
+	return bindings, boundNamespaces, nil
+}
+
+// inheritedGrants returns the membership grants a ClusterRole inherits from the aggregated ClusterRoles it
+// contributes to: the entitlement matching each of their bindings, granted to the subjects of the binding and
+// recording the binding and the aggregate in the grant metadata. Subjects the bindings of the ClusterRole itself
+// grant the same entitlement, directly or through a service account group, are skipped.
+func (c *clusterRoleBuilder) inheritedGrants(
+	ctx context.Context,
+	resource *v2.Resource,
+	clusterRole *rbacv1.ClusterRole,
+	memberships bindingMemberships,
+) ([]*v2.Grant, error) {
+	l := ctxzap.Extract(ctx)
+
+	clusterRoles, err := c.cacheClusterRoles(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cache cluster roles: %w", err)
+	}
+	aggregates, err := aggregatingClusterRoles(clusterRole, clusterRoles)
+	if err != nil {
+		return nil, err
+	}
+
+	var rv []*v2.Grant
+	grantSubjects := func(subjects []rbacv1.Subject, entName, bindingKind, aggregate string, meta metav1.ObjectMeta) {
+		metadata := bindingGrantMetadata(bindingKind, meta)
+		metadata[GrantMetadataViaAggregate] = aggregate
+		for _, subject := range subjects {
+			if memberships.grantedDirectly(entName, subject) {
+				continue
+			}
+			if sa := normalizeSubject(subject); c.saGroups != nil && sa.Kind == SubjectKindServiceAccount &&
+				memberships.grantedToGroupBefore(entName, sa.Namespace, math.MaxInt) {
+				continue
+			}
+			subjectGrant, err := grantRoleToSubject(subject, resource, entName, c.opts, grant.WithGrantMetadata(metadata))
 			if err != nil {
-				l.Debug("subject kind not supported", zap.String("subject kind", subject.Kind))
+				logSkippedSubject(l, subject, err)
 				continue
 			}
 			rv = append(rv, subjectGrant)
 		}
 	}
 
-	return rv, "", nil, nil
+	var cached clusterRoleNamespaces
+	for _, aggregate := range aggregates {
+		roleBindings, clusterBindings, err := c.bindingProvider.GetMatchingBindingsForClusterRole(ctx, aggregate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get matching bindings of aggregated cluster role %s: %w", aggregate, err)
+		}
+		sortRoleBindings(roleBindings)
+		sortClusterRoleBindings(clusterBindings)
+
+		for _, binding := range clusterBindings {
+			grantSubjects(binding.Subjects, clusterScopedMember, BindingKindClusterRoleBinding, aggregate, binding.ObjectMeta)
+		}
+		if c.opts.NamespaceEntitlementSelector != nil && len(roleBindings) > 0 && cached.names == nil {
+			if cached, err = c.cacheNamespaces(ctx); err != nil {
+				return nil, fmt.Errorf("failed to cache namespaces: %w", err)
+			}
+		}
+		for _, binding := range roleBindings {
+			entName, ok := c.namespaceEntitlement(cached, binding.Namespace)
+			if !ok {
+				continue
+			}
+			grantSubjects(binding.Subjects, entName, BindingKindRoleBinding, aggregate, binding.ObjectMeta)
+		}
+	}
+	return rv, nil
 }
 
-// getNamespaces returns cached namespaces or fetches them if cache is expired or empty.
-func (c *clusterRoleBuilder) cacheNamespaces(ctx context.Context) error {
+// cacheNamespaces returns the namespaces of the sync, from the snapshot shared by the connector if any.
+func (c *clusterRoleBuilder) cacheNamespaces(ctx context.Context) (clusterRoleNamespaces, error) {
 	c.nsMutex.Lock()
-	defer c.nsMutex.Unlock()
+	if c.namespaces == nil {
+		c.namespaces = newNamespaceSnapshot(c.client, c.opts, c.progress)
+	}
+	namespaces := c.namespaces
+	c.nsMutex.Unlock()
+	return namespaces.get(ctx)
+}
+
+// namespaceSnapshot lists the namespaces ClusterRoles can be bound in once, and keeps them until reset. It's safe
+// for concurrent use.
+type namespaceSnapshot struct {
+	client   kubernetes.Interface
+	opts     ConnectorOpts
+	progress *progressReporter
 
-	now := time.Now()
-	if c.cachedNamespaces != nil && now.Before(c.nsCacheExpiry) {
-		// Cache is valid.
-		return nil
+	mu     sync.Mutex
+	loaded *clusterRoleNamespaces
+}
+
+// newNamespaceSnapshot creates an empty snapshot, loaded when first read.
+func newNamespaceSnapshot(client kubernetes.Interface, opts ConnectorOpts, progress *progressReporter) *namespaceSnapshot {
+	return &namespaceSnapshot{
+		client:   client,
+		opts:     opts,
+		progress: progress,
 	}
-	var (
-		names      []string
-		continueAt string
-	)
-	for {
-		opts := metav1.ListOptions{
-			Continue: continueAt,
+}
+
+// get returns the snapshot, listing the namespaces, and the ones matching the namespace entitlement selector,
+// if it's empty.
+func (s *namespaceSnapshot) get(ctx context.Context) (clusterRoleNamespaces, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.loaded != nil {
+		return *s.loaded, nil
+	}
+
+	names, err := listNamespaceNames(ctx, s.client, nil, s.progress)
+	if err != nil {
+		return clusterRoleNamespaces{}, fmt.Errorf("failed to cache namespaces list: %w", err)
+	}
+
+	var selected map[string]bool
+	if s.opts.NamespaceEntitlementSelector != nil {
+		selectedNames, err := listNamespaceNames(ctx, s.client, s.opts.NamespaceEntitlementSelector, s.progress)
+		if err != nil {
+			return clusterRoleNamespaces{}, fmt.Errorf("failed to cache selected namespaces list: %w", err)
+		}
+		selected = make(map[string]bool, len(selectedNames))
+		for _, name := range selectedNames {
+			selected[name] = true
+		}
+	}
+
+	s.loaded = &clusterRoleNamespaces{names: names, selected: selected}
+	return *s.loaded, nil
+}
+
+// reset drops the snapshot, so that the next read lists the namespaces again. Resetting a nil snapshot does
+// nothing.
+func (s *namespaceSnapshot) reset() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loaded = nil
+}
+
+// cacheClusterRoles returns the cached ClusterRoles, or fetches them if the cache is expired or empty.
+func (c *clusterRoleBuilder) cacheClusterRoles(ctx context.Context) ([]rbacv1.ClusterRole, error) {
+	return c.clusterRoles.get(ctx)
+}
+
+// clusterRoleCache lists the ClusterRoles once, and keeps them until reset at the start of the next sync, so that
+// every aggregated ClusterRole of a sync is resolved against the same ClusterRoles. It's safe for concurrent use.
+type clusterRoleCache struct {
+	client kubernetes.Interface
+	opts   ConnectorOpts
+
+	mu     sync.Mutex
+	loaded []rbacv1.ClusterRole
+}
+
+// newClusterRoleCache creates an empty cache, loaded when first read.
+func newClusterRoleCache(client kubernetes.Interface, opts ConnectorOpts) *clusterRoleCache {
+	return &clusterRoleCache{
+		client: client,
+		opts:   opts,
+	}
+}
+
+// get returns the cached ClusterRoles, listing them if the cache is empty.
+func (c *clusterRoleCache) get(ctx context.Context) ([]rbacv1.ClusterRole, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.loaded != nil {
+		return c.loaded, nil
+	}
+
+	clusterRoles := make([]rbacv1.ClusterRole, 0)
+	err := listPages(c.opts.pageSize(ResourceTypeClusterRole.Id), func(opts metav1.ListOptions) (string, error) {
+		resp, err := listWithRetry(ctx, c.opts, func(ctx context.Context) (*rbacv1.ClusterRoleList, error) {
+			return c.client.RbacV1().ClusterRoles().List(ctx, opts)
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to list cluster roles: %w", err)
+		}
+		clusterRoles = append(clusterRoles, resp.Items...)
+		return resp.Continue, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c.loaded = clusterRoles
+	return c.loaded, nil
+}
+
+// reset drops the cache, so that the next read lists the ClusterRoles again. Resetting a nil cache does nothing.
+func (c *clusterRoleCache) reset() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loaded = nil
+}
+
+// parseClusterRoleEntitlement returns the namespace a ClusterRole membership entitlement binds the role in,
+// or an empty string for the cluster-wide entitlement.
+func parseClusterRoleEntitlement(ent *v2.Entitlement) (string, error) {
+	if ent == nil || ent.Resource == nil || ent.Resource.Id == nil {
+		return "", fmt.Errorf("entitlement is missing its resource")
+	}
+
+	prefix := fmt.Sprintf("%s:%s:", ent.Resource.Id.ResourceType, ent.Resource.Id.Resource)
+	slug, ok := strings.CutPrefix(ent.Id, prefix)
+	if !ok {
+		return "", fmt.Errorf("invalid entitlement ID %q for cluster role %s", ent.Id, ent.Resource.Id.Resource)
+	}
+
+	if slug == clusterScopedMember {
+		return "", nil
+	}
+
+	namespace, ok := strings.CutSuffix(slug, ":member")
+	if !ok || namespace == "" {
+		return "", fmt.Errorf("unsupported cluster role entitlement: %s", slug)
+	}
+	return namespace, nil
+}
+
+// isOtherNamespacesEntitlement reports whether the entitlement is the catch-all entitlement of the namespaces
+// not matching the namespace entitlement selector.
+func (c *clusterRoleBuilder) isOtherNamespacesEntitlement(ent *v2.Entitlement) bool {
+	return c.opts.NamespaceEntitlementSelector != nil && strings.HasSuffix(ent.Id, ":"+otherNamespacesMember)
+}
+
+// isNamespacedEntitlement reports whether the entitlement is the single namespace membership entitlement of
+// compact ClusterRole entitlements.
+func (c *clusterRoleBuilder) isNamespacedEntitlement(ent *v2.Entitlement) bool {
+	return c.opts.CompactClusterRoleEntitlements && strings.HasSuffix(ent.Id, ":"+namespacedMember)
+}
+
+// Grant binds the ClusterRole to the principal, cluster-wide with a ClusterRoleBinding for the
+// 'all:member' entitlement or in a single namespace with a RoleBinding for namespace entitlements.
+func (c *clusterRoleBuilder) Grant(ctx context.Context, principal *v2.Resource, ent *v2.Entitlement) (annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+
+	subject, err := subjectForPrincipal(principal.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	namespace, err := parseClusterRoleEntitlement(ent)
+	if err != nil {
+		return nil, err
+	}
+	if c.isOtherNamespacesEntitlement(ent) {
+		return nil, fmt.Errorf("the %s entitlement covers several namespaces and can't be granted, grant a namespace or cluster-wide entitlement", otherNamespacesMember)
+	}
+	if c.isNamespacedEntitlement(ent) {
+		return nil, fmt.Errorf("the %s entitlement doesn't name a namespace and can't be granted with compact cluster role entitlements, grant the cluster-wide entitlement", namespacedMember)
+	}
+
+	clusterRoleName := ent.Resource.Id.Resource
+	if !c.opts.SkipGrantPreCheck {
+		roleRef := rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindClusterRole, Name: clusterRoleName}
+		if err := checkCanBind(ctx, c.client, roleRef, subject, namespace); err != nil {
+			return nil, err
+		}
+	}
+
+	var created bool
+	if namespace == "" {
+		created, err = c.bindClusterWide(ctx, clusterRoleName, subject)
+		if err != nil {
+			return nil, err
 		}
-		nsList, err := c.client.CoreV1().Namespaces().List(ctx, opts)
+	} else {
+		results, err := c.bindInNamespaces(ctx, clusterRoleName, subject, []string{namespace})
 		if err != nil {
-			return fmt.Errorf("failed to cache namespaces list: %w", err)
+			return nil, err
+		}
+		created = results[0].Created
+	}
+
+	if !created {
+		l.Info("cluster role is already bound to the principal",
+			zap.String("clusterRole", clusterRoleName),
+			zap.String("namespace", namespace),
+			zap.String("principal", principal.Id.Resource))
+		return annotations.New(&v2.GrantAlreadyExists{}), nil
+	}
+
+	return nil, nil
+}
+
+// bindInNamespaces binds the ClusterRole to the subject in each of the given namespaces, validating the
+// ClusterRole once for the whole batch. See bindRoleInNamespaces for the per-namespace behavior.
+func (c *clusterRoleBuilder) bindInNamespaces(ctx context.Context, clusterRoleName string, subject rbacv1.Subject, namespaces []string) ([]namespaceBindResult, error) {
+	_, err := c.client.RbacV1().ClusterRoles().Get(ctx, clusterRoleName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cluster role: %w", err)
+	}
+
+	roleRef := rbacv1.RoleRef{
+		APIGroup: RBACAPIGroup,
+		Kind:     RoleRefKindClusterRole,
+		Name:     clusterRoleName,
+	}
+
+	return bindRoleInNamespaces(ctx, c.client, roleRef, subject, namespaces)
+}
+
+// bindClusterWide creates a ClusterRoleBinding from the subject to the ClusterRole, treating an existing
+// equivalent binding as success. It reports whether a binding was created.
+func (c *clusterRoleBuilder) bindClusterWide(ctx context.Context, clusterRoleName string, subject rbacv1.Subject) (bool, error) {
+	roleRef := rbacv1.RoleRef{
+		APIGroup: RBACAPIGroup,
+		Kind:     RoleRefKindClusterRole,
+		Name:     clusterRoleName,
+	}
+	bindingName := managedBindingName(roleRef, subject)
+
+	binding := &rbacv1.ClusterRoleBinding{
+		ObjectMeta: managedBindingMeta(bindingName, ""),
+		RoleRef:    roleRef,
+		Subjects:   []rbacv1.Subject{subject},
+	}
+
+	_, err := c.client.RbacV1().ClusterRoleBindings().Create(ctx, binding, metav1.CreateOptions{})
+	if err == nil {
+		return true, nil
+	}
+	if !k8serrors.IsAlreadyExists(err) {
+		return false, fmt.Errorf("failed to create cluster role binding: %w", err)
+	}
+
+	current, err := c.client.RbacV1().ClusterRoleBindings().Get(ctx, bindingName, metav1.GetOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to get existing cluster role binding: %w", err)
+	}
+	if !bindingGrantsSubject(current.RoleRef, current.Subjects, roleRef, subject) {
+		return false, fmt.Errorf("cluster role binding %s exists but doesn't bind %s %s", bindingName, subject.Kind, subject.Name)
+	}
+
+	return false, nil
+}
+
+// Revoke removes the principal from the binding the grant was derived from, and from the other bindings
+// conferring the same grant, deleting each binding if no other subjects remain. Grants without binding metadata, such as ones just provisioned, are revoked from
+// the binding created by Grant. The binding must not have changed since the grant was synced.
+func (c *clusterRoleBuilder) Revoke(ctx context.Context, g *v2.Grant) (annotations.Annotations, error) {
+	implicit, err := isImplicitGrant(g)
+	if err != nil {
+		return nil, err
+	}
+	if implicit {
+		return nil, fmt.Errorf("grant of %s to %s is hard-coded in Kubernetes and can't be revoked", g.Entitlement.Resource.Id.Resource, g.Principal.Id.Resource)
+	}
+
+	subject, err := subjectForPrincipal(g.Principal.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	namespace, err := parseClusterRoleEntitlement(g.Entitlement)
+	if err != nil {
+		return nil, err
+	}
+
+	roleRef := rbacv1.RoleRef{
+		APIGroup: RBACAPIGroup,
+		Kind:     RoleRefKindClusterRole,
+		Name:     g.Entitlement.Resource.Id.Resource,
+	}
+
+	ref, ok, err := bindingRefFromGrant(g)
+	if err != nil {
+		return nil, err
+	}
+	if ref.viaGroup != "" {
+		return nil, fmt.Errorf("grant is inherited through group %s and can't be revoked for a single service account", ref.viaGroup)
+	}
+	if ref.viaAggregate != "" {
+		return nil, fmt.Errorf("grant is inherited from aggregated cluster role %s, revoke its membership instead", ref.viaAggregate)
+	}
+	additional, err := additionalBindingRefs(g)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if c.isOtherNamespacesEntitlement(g.Entitlement) {
+			return nil, fmt.Errorf("grant of the %s entitlement has no binding metadata, re-sync before revoking", otherNamespacesMember)
 		}
-		for _, ns := range nsList.Items {
-			names = append(names, ns.Name)
+		if c.isNamespacedEntitlement(g.Entitlement) {
+			return nil, fmt.Errorf("grant of the %s entitlement has no binding metadata, re-sync before revoking", namespacedMember)
 		}
-		if nsList.Continue == "" {
-			break
+		ref = bindingRef{
+			kind:      BindingKindClusterRoleBinding,
+			name:      managedBindingName(roleRef, subject),
+			namespace: namespace,
+		}
+		if namespace != "" {
+			ref.kind = BindingKindRoleBinding
 		}
-		continueAt = nsList.Continue
 	}
 
-	c.cachedNamespaces = names
-	c.nsCacheExpiry = now.Add(namespaceCacheTTL)
-	return nil
+	// Remove the subject from every binding conferring the grant, or it would keep the role
+	annos, err := revokeBindingSubject(ctx, c.client, ref, roleRef, subject)
+	if err != nil {
+		return nil, err
+	}
+	for _, other := range additional {
+		otherAnnos, err := revokeBindingSubject(ctx, c.client, other, roleRef, subject)
+		if err != nil {
+			return nil, err
+		}
+		if !otherAnnos.Contains(&v2.GrantAlreadyRevoked{}) {
+			annos = nil
+		}
+	}
+	return annos, nil
 }
 
 // newClusterRoleBuilder creates a new cluster role builder.
-func newClusterRoleBuilder(client kubernetes.Interface, bindingProvider ClusterRoleBindingProvider) *clusterRoleBuilder {
+func newClusterRoleBuilder(client kubernetes.Interface, bindingProvider ClusterRoleBindingProvider, opts ConnectorOpts, stats *syncStats) *clusterRoleBuilder {
 	return &clusterRoleBuilder{
 		client:          client,
 		bindingProvider: bindingProvider,
+		opts:            opts,
+		stats:           stats,
+		saGroups:        newServiceAccountGroupExpander(client, opts),
+		clusterRoles:    newClusterRoleCache(client, opts),
 	}
 }
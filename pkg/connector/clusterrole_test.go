@@ -0,0 +1,1110 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// mockClusterRoleBindingProvider implements the ClusterRoleBindingProvider interface for testing.
+type mockClusterRoleBindingProvider struct {
+	roleBindings        map[string][]rbacv1.RoleBinding        // key: clusterRoleName
+	clusterRoleBindings map[string][]rbacv1.ClusterRoleBinding // key: clusterRoleName
+}
+
+// GetMatchingBindingsForClusterRole returns mock bindings for testing.
+func (m *mockClusterRoleBindingProvider) GetMatchingBindingsForClusterRole(ctx context.Context, clusterRoleName string) ([]rbacv1.RoleBinding, []rbacv1.ClusterRoleBinding, error) {
+	return m.roleBindings[clusterRoleName], m.clusterRoleBindings[clusterRoleName], nil
+}
+
+// newMockClusterRoleBindingProvider creates a new mock cluster role binding provider.
+func newMockClusterRoleBindingProvider() *mockClusterRoleBindingProvider {
+	return &mockClusterRoleBindingProvider{
+		roleBindings:        make(map[string][]rbacv1.RoleBinding),
+		clusterRoleBindings: make(map[string][]rbacv1.ClusterRoleBinding),
+	}
+}
+
+// TestClusterRoleBuilderGrants_RuleExpansion tests that cluster-scoped rules produce grants on nodes and namespaces.
+func TestClusterRoleBuilderGrants_RuleExpansion(t *testing.T) {
+	clusterRole := &rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "node-operator",
+		},
+		Rules: []rbacv1.PolicyRule{
+			{
+				Verbs:     []string{"get", "list"},
+				APIGroups: []string{""},
+				Resources: []string{"nodes"},
+			},
+			{
+				Verbs:         []string{"delete"},
+				APIGroups:     []string{""},
+				Resources:     []string{"namespaces"},
+				ResourceNames: []string{"scratch"},
+			},
+			{
+				// Not synced by the connector
+				Verbs:     []string{"get"},
+				APIGroups: []string{""},
+				Resources: []string{"persistentvolumes"},
+			},
+		},
+	}
+
+	builder := &clusterRoleBuilder{
+		client:          fake.NewSimpleClientset(clusterRole),
+		bindingProvider: newMockClusterRoleBindingProvider(),
+	}
+
+	testResource := &v2.Resource{
+		Id: &v2.ResourceId{
+			ResourceType: ResourceTypeClusterRole.Id,
+			Resource:     "node-operator",
+		},
+		DisplayName: "node-operator",
+	}
+
+	grants, nextPageToken, _, err := builder.Grants(context.Background(), testResource, &pagination.Token{})
+	require.NoError(t, err)
+	assert.Empty(t, nextPageToken)
+
+	ids := grantEntitlementIDs(grants)
+	assert.ElementsMatch(t, []string{
+		"node:*:get",
+		"node:*:list",
+		"namespace:scratch:delete",
+	}, ids)
+
+	for _, g := range grants {
+		assert.Equal(t, ResourceTypeClusterRole.Id, g.Principal.Id.ResourceType)
+		assert.Equal(t, "node-operator", g.Principal.Id.Resource)
+	}
+}
+
+// TestClusterRoleBuilderGrants_KubeletAccess tests that rules on the kubelet subresources of nodes produce
+// grants on the subresource entitlements of the node wildcard rather than on get nodes.
+func TestClusterRoleBuilderGrants_KubeletAccess(t *testing.T) {
+	clusterRole := &rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "kubelet-api"},
+		Rules: []rbacv1.PolicyRule{
+			{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"nodes/proxy", "nodes/stats"}},
+		},
+	}
+
+	builder := &clusterRoleBuilder{
+		client:          fake.NewSimpleClientset(clusterRole),
+		bindingProvider: newMockClusterRoleBindingProvider(),
+	}
+	testResource := &v2.Resource{
+		Id:          &v2.ResourceId{ResourceType: ResourceTypeClusterRole.Id, Resource: "kubelet-api"},
+		DisplayName: "kubelet-api",
+	}
+
+	grants, _, _, err := builder.Grants(context.Background(), testResource, &pagination.Token{})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"node:*:proxy", "node:*:stats"}, grantEntitlementIDs(grants))
+
+	// The entitlements granted exist on the node wildcard
+	entitlements, _, _, err := (&nodeBuilder{}).Entitlements(context.Background(), GenerateResourceForGrant("*", ResourceTypeNode.Id), &pagination.Token{})
+	require.NoError(t, err)
+	var ids []string
+	for _, ent := range entitlements {
+		ids = append(ids, ent.Id)
+	}
+	assert.Contains(t, ids, "node:*:proxy")
+	assert.Contains(t, ids, "node:*:stats")
+	assert.Contains(t, ids, "node:*:log")
+}
+
+// TestClusterRoleBuilderGrants_NamespacedWildcard tests that namespaced rules on a ClusterRole target the wildcard resource.
+func TestClusterRoleBuilderGrants_NamespacedWildcard(t *testing.T) {
+	clusterRole := &rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "secret-reader",
+		},
+		Rules: []rbacv1.PolicyRule{
+			{
+				Verbs:     []string{"get"},
+				APIGroups: []string{""},
+				Resources: []string{"secrets"},
+			},
+		},
+	}
+
+	builder := &clusterRoleBuilder{
+		client:          fake.NewSimpleClientset(clusterRole),
+		bindingProvider: newMockClusterRoleBindingProvider(),
+	}
+
+	testResource := &v2.Resource{
+		Id: &v2.ResourceId{
+			ResourceType: ResourceTypeClusterRole.Id,
+			Resource:     "secret-reader",
+		},
+		DisplayName: "secret-reader",
+	}
+
+	grants, _, _, err := builder.Grants(context.Background(), testResource, &pagination.Token{})
+	require.NoError(t, err)
+	require.Len(t, grants, 1)
+	assert.Equal(t, "secret:*:get", grants[0].Entitlement.Id)
+}
+
+// TestClusterRoleBuilderGrants_ClusterRoleDeleted tests that a cluster role deleted between List and Grants
+// yields no grants and no error.
+func TestClusterRoleBuilderGrants_ClusterRoleDeleted(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(&rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "short-lived",
+		},
+	})
+
+	stats := newSyncStats()
+	builder := newClusterRoleBuilder(client, newMockClusterRoleBindingProvider(), ConnectorOpts{}, stats)
+
+	resources, _, _, err := builder.List(ctx, nil, &pagination.Token{})
+	require.NoError(t, err)
+	require.Len(t, resources, 2) // wildcard and short-lived
+
+	err = client.RbacV1().ClusterRoles().Delete(ctx, "short-lived", metav1.DeleteOptions{})
+	require.NoError(t, err)
+
+	grants, nextToken, _, err := builder.Grants(ctx, resources[1], &pagination.Token{})
+	require.NoError(t, err)
+	assert.Empty(t, grants)
+	assert.Empty(t, nextToken)
+	assert.Equal(t, int64(1), stats.Get(StatGrantsObjectNotFound))
+}
+
+// rejectMissingNamespaces makes the RoleBinding creates in namespaces that don't exist fail as they do on an
+// API server, which the fake cluster doesn't check.
+func rejectMissingNamespaces(client *fake.Clientset) {
+	client.PrependReactor("create", "rolebindings", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		namespace := action.GetNamespace()
+		if _, err := client.Tracker().Get(corev1.SchemeGroupVersion.WithResource("namespaces"), "", namespace); err != nil {
+			return true, nil, k8serrors.NewNotFound(corev1.Resource("namespaces"), namespace)
+		}
+		return false, nil, nil
+	})
+}
+
+// TestClusterRoleBindInNamespaces_PartialFailure tests that binding a cluster role across namespaces creates
+// bindings in the existing namespaces, reports the missing one and is idempotent when repeated.
+func TestClusterRoleBindInNamespaces_PartialFailure(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "view"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-c"}},
+	)
+	rejectMissingNamespaces(client)
+	builder := newClusterRoleBuilder(client, newMockClusterRoleBindingProvider(), ConnectorOpts{}, nil)
+
+	subject := rbacv1.Subject{Kind: SubjectKindGroup, APIGroup: RBACAPIGroup, Name: "team-x"}
+	namespaces := []string{"team-a", "team-b", "team-c", "team-a"}
+
+	results, err := builder.bindInNamespaces(ctx, "view", subject, namespaces)
+	require.ErrorIs(t, err, ErrNamespaceNotFound)
+	assert.Contains(t, err.Error(), "team-b")
+	require.Len(t, results, 3)
+
+	// The namespaces aren't listed, each create checks its own
+	for _, action := range client.Actions() {
+		assert.False(t, action.Matches("list", "namespaces"))
+	}
+
+	for _, result := range results {
+		if result.Namespace == "team-b" {
+			assert.ErrorIs(t, result.Err, ErrNamespaceNotFound)
+			assert.False(t, result.Created)
+			continue
+		}
+		require.NoError(t, result.Err)
+		assert.True(t, result.Created)
+
+		bindings, err := client.RbacV1().RoleBindings(result.Namespace).List(ctx, metav1.ListOptions{})
+		require.NoError(t, err)
+		require.Len(t, bindings.Items, 1)
+		assert.Equal(t, "view", bindings.Items[0].RoleRef.Name)
+		assert.Equal(t, RoleRefKindClusterRole, bindings.Items[0].RoleRef.Kind)
+		assert.Equal(t, []rbacv1.Subject{subject}, bindings.Items[0].Subjects)
+		assert.Equal(t, ManagedByValue, bindings.Items[0].Labels[ManagedByLabel])
+	}
+
+	// Repeating the batch doesn't create duplicate bindings
+	results, err = builder.bindInNamespaces(ctx, "view", subject, namespaces)
+	require.ErrorIs(t, err, ErrNamespaceNotFound)
+	for _, result := range results {
+		assert.False(t, result.Created)
+	}
+	for _, ns := range []string{"team-a", "team-c"} {
+		bindings, err := client.RbacV1().RoleBindings(ns).List(ctx, metav1.ListOptions{})
+		require.NoError(t, err)
+		assert.Len(t, bindings.Items, 1)
+	}
+}
+
+// TestClusterRoleBindInNamespaces_MissingClusterRole tests that the cluster role is validated before binding.
+func TestClusterRoleBindInNamespaces_MissingClusterRole(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}})
+	builder := newClusterRoleBuilder(client, newMockClusterRoleBindingProvider(), ConnectorOpts{}, nil)
+
+	subject := rbacv1.Subject{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "alice"}
+	_, err := builder.bindInNamespaces(ctx, "missing", subject, []string{"team-a"})
+	require.Error(t, err)
+
+	bindings, err := client.RbacV1().RoleBindings("team-a").List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, bindings.Items)
+}
+
+// TestClusterRoleBuilderGrantRevoke tests granting and revoking namespace and cluster-wide cluster role membership.
+func TestClusterRoleBuilderGrantRevoke(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "view"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}},
+	)
+	reviewAccess(client, func(authorizationv1.ResourceAttributes) bool { return true })
+	builder := newClusterRoleBuilder(client, newMockClusterRoleBindingProvider(), ConnectorOpts{}, nil)
+
+	clusterRole, err := clusterRoleResource(&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "view"}}, ConnectorOpts{}, nil)
+	require.NoError(t, err)
+	principal := GenerateResourceForGrant("team-a/deployer", ResourceTypeServiceAccount.Id)
+
+	testCases := []struct {
+		name        string
+		entitlement string
+		listed      func() int
+	}{
+		{
+			name:        "namespace",
+			entitlement: "team-a:member",
+			listed: func() int {
+				bindings, err := client.RbacV1().RoleBindings("team-a").List(ctx, metav1.ListOptions{})
+				require.NoError(t, err)
+				return len(bindings.Items)
+			},
+		},
+		{
+			name:        "cluster-wide",
+			entitlement: clusterScopedMember,
+			listed: func() int {
+				bindings, err := client.RbacV1().ClusterRoleBindings().List(ctx, metav1.ListOptions{})
+				require.NoError(t, err)
+				return len(bindings.Items)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ent := &v2.Entitlement{
+				Id:       "cluster_role:view:" + tc.entitlement,
+				Resource: clusterRole,
+			}
+
+			annos, err := builder.Grant(ctx, principal, ent)
+			require.NoError(t, err)
+			assert.Empty(t, annos)
+			assert.Equal(t, 1, tc.listed())
+
+			annos, err = builder.Grant(ctx, principal, ent)
+			require.NoError(t, err)
+			assert.True(t, annos.Contains(&v2.GrantAlreadyExists{}))
+			assert.Equal(t, 1, tc.listed())
+
+			g := &v2.Grant{Entitlement: ent, Principal: principal}
+			annos, err = builder.Revoke(ctx, g)
+			require.NoError(t, err)
+			assert.Empty(t, annos)
+			assert.Equal(t, 0, tc.listed())
+
+			annos, err = builder.Revoke(ctx, g)
+			require.NoError(t, err)
+			assert.True(t, annos.Contains(&v2.GrantAlreadyRevoked{}))
+		})
+	}
+}
+
+// TestClusterRoleBuilderGrants_ResourceNames tests that resourceNames on a ClusterRole resolve in the namespaces it is bound in.
+func TestClusterRoleBuilderGrants_ResourceNames(t *testing.T) {
+	clusterRole := &rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "token-reader",
+		},
+		Rules: []rbacv1.PolicyRule{
+			{
+				Verbs:         []string{"get"},
+				APIGroups:     []string{""},
+				Resources:     []string{"secrets"},
+				ResourceNames: []string{"api-token"},
+			},
+		},
+	}
+	roleRef := rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindClusterRole, Name: "token-reader"}
+
+	testResource := &v2.Resource{
+		Id: &v2.ResourceId{
+			ResourceType: ResourceTypeClusterRole.Id,
+			Resource:     "token-reader",
+		},
+		DisplayName: "token-reader",
+	}
+
+	testCases := []struct {
+		name                string
+		roleBindings        []rbacv1.RoleBinding
+		clusterRoleBindings []rbacv1.ClusterRoleBinding
+		expected            []string
+	}{
+		{
+			name:     "unbound",
+			expected: []string{},
+		},
+		{
+			name: "role binding",
+			roleBindings: []rbacv1.RoleBinding{
+				{ObjectMeta: metav1.ObjectMeta{Name: "rb", Namespace: "ns-a"}, RoleRef: roleRef},
+			},
+			expected: []string{"secret:ns-a/api-token:get"},
+		},
+		{
+			name: "cluster role binding",
+			clusterRoleBindings: []rbacv1.ClusterRoleBinding{
+				{ObjectMeta: metav1.ObjectMeta{Name: "crb"}, RoleRef: roleRef},
+			},
+			expected: []string{"secret:ns-a/api-token:get", "secret:ns-b/api-token:get"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			provider := newMockClusterRoleBindingProvider()
+			provider.roleBindings["token-reader"] = tc.roleBindings
+			provider.clusterRoleBindings["token-reader"] = tc.clusterRoleBindings
+
+			client := fake.NewSimpleClientset(
+				clusterRole,
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns-a"}},
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns-b"}},
+			)
+			builder := newClusterRoleBuilder(client, provider, ConnectorOpts{}, nil)
+
+			grants, _, _, err := builder.Grants(context.Background(), testResource, &pagination.Token{})
+			require.NoError(t, err)
+
+			var ruleGrantIDs []string
+			for _, g := range grants {
+				if g.Entitlement.Resource.Id.ResourceType == ResourceTypeSecret.Id {
+					ruleGrantIDs = append(ruleGrantIDs, g.Entitlement.Id)
+				}
+			}
+			assert.ElementsMatch(t, tc.expected, ruleGrantIDs)
+		})
+	}
+}
+
+// TestClusterRoleBuilderRevoke_ConcurrentModification tests that revoking a grant fails with a Conflict when
+// the binding changed since the sync, and succeeds once re-synced.
+func TestClusterRoleBuilderRevoke_ConcurrentModification(t *testing.T) {
+	ctx := context.Background()
+	alice := rbacv1.Subject{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "alice"}
+	bob := rbacv1.Subject{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "bob"}
+	carol := rbacv1.Subject{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "carol"}
+
+	binding := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "viewers", Namespace: "team-a", ResourceVersion: "1"},
+		RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindClusterRole, Name: "view"},
+		Subjects:   []rbacv1.Subject{alice, bob},
+	}
+	client := fake.NewSimpleClientset(
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "view"}},
+		binding.DeepCopy(),
+	)
+
+	provider := newMockClusterRoleBindingProvider()
+	provider.roleBindings["view"] = []rbacv1.RoleBinding{*binding}
+	builder := newClusterRoleBuilder(client, provider, ConnectorOpts{}, nil)
+
+	clusterRole, err := clusterRoleResource(&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "view"}}, ConnectorOpts{}, nil)
+	require.NoError(t, err)
+
+	aliceGrant := func() *v2.Grant {
+		grants, _, _, err := builder.Grants(ctx, clusterRole, &pagination.Token{})
+		require.NoError(t, err)
+		for _, g := range grants {
+			if g.Principal.Id.Resource == "alice" {
+				return g
+			}
+		}
+		require.FailNow(t, "grant for alice not found")
+		return nil
+	}
+
+	g := aliceGrant()
+	ref, ok, err := bindingRefFromGrant(g)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, bindingRef{kind: BindingKindRoleBinding, name: "viewers", namespace: "team-a", resourceVersion: "1"}, ref)
+
+	// Someone else modifies the binding after the sync
+	modified := binding.DeepCopy()
+	modified.ResourceVersion = "2"
+	modified.Subjects = append(modified.Subjects, carol)
+	_, err = client.RbacV1().RoleBindings("team-a").Update(ctx, modified, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	_, err = builder.Revoke(ctx, g)
+	require.Error(t, err)
+	assert.True(t, k8serrors.IsConflict(err))
+
+	current, err := client.RbacV1().RoleBindings("team-a").Get(ctx, "viewers", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []rbacv1.Subject{alice, bob, carol}, current.Subjects)
+
+	// After a re-sync the revoke removes only alice
+	provider.roleBindings["view"] = []rbacv1.RoleBinding{*modified}
+	annos, err := builder.Revoke(ctx, aliceGrant())
+	require.NoError(t, err)
+	assert.Empty(t, annos)
+
+	current, err = client.RbacV1().RoleBindings("team-a").Get(ctx, "viewers", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []rbacv1.Subject{bob, carol}, current.Subjects)
+}
+
+// TestClusterRoleBuilderRevoke_DuplicateBindings tests that a subject bound to a cluster role by two
+// ClusterRoleBindings is granted it once, and that revoking the grant removes the subject from both.
+func TestClusterRoleBuilderRevoke_DuplicateBindings(t *testing.T) {
+	ctx := context.Background()
+	alice := rbacv1.Subject{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "alice"}
+	bob := rbacv1.Subject{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "bob"}
+	roleRef := rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindClusterRole, Name: "view"}
+	bindings := []rbacv1.ClusterRoleBinding{
+		{ObjectMeta: metav1.ObjectMeta{Name: "view-old"}, RoleRef: roleRef, Subjects: []rbacv1.Subject{alice}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "view-new"}, RoleRef: roleRef, Subjects: []rbacv1.Subject{alice, bob}},
+	}
+	client := fake.NewSimpleClientset(
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "view"}},
+		bindings[0].DeepCopy(),
+		bindings[1].DeepCopy(),
+	)
+	provider := newMockClusterRoleBindingProvider()
+	provider.clusterRoleBindings["view"] = bindings
+	builder := newClusterRoleBuilder(client, provider, ConnectorOpts{}, nil)
+
+	clusterRole, err := clusterRoleResource(&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "view"}}, ConnectorOpts{}, nil)
+	require.NoError(t, err)
+	grants, _, _, err := builder.Grants(ctx, clusterRole, &pagination.Token{})
+	require.NoError(t, err)
+
+	var aliceGrants []*v2.Grant
+	for _, g := range grants {
+		if g.Principal.Id.Resource == "alice" {
+			aliceGrants = append(aliceGrants, g)
+		}
+	}
+	require.Len(t, aliceGrants, 1)
+
+	annos, err := builder.Revoke(ctx, aliceGrants[0])
+	require.NoError(t, err)
+	assert.Empty(t, annos)
+
+	_, err = client.RbacV1().ClusterRoleBindings().Get(ctx, "view-old", metav1.GetOptions{})
+	assert.True(t, k8serrors.IsNotFound(err))
+	current, err := client.RbacV1().ClusterRoleBindings().Get(ctx, "view-new", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []rbacv1.Subject{bob}, current.Subjects)
+
+	// Revoking again finds the subject in neither binding
+	annos, err = builder.Revoke(ctx, aliceGrants[0])
+	require.NoError(t, err)
+	assert.True(t, annos.Contains(&v2.GrantAlreadyRevoked{}))
+}
+
+// TestClusterRoleBuilderGrants_SystemSubjects tests that system users and groups are only granted cluster
+// roles when the connector is configured to include them.
+func TestClusterRoleBuilderGrants_SystemSubjects(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(&rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster-admin"},
+	})
+	provider := newMockClusterRoleBindingProvider()
+	provider.clusterRoleBindings["cluster-admin"] = []rbacv1.ClusterRoleBinding{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-admin"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindClusterRole, Name: "cluster-admin"},
+			Subjects: []rbacv1.Subject{
+				{Kind: SubjectKindGroup, APIGroup: RBACAPIGroup, Name: "system:masters"},
+				{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "alice"},
+			},
+		},
+	}
+	resource := GenerateResourceForGrant("cluster-admin", ResourceTypeClusterRole.Id)
+
+	principals := func(grants []*v2.Grant) []string {
+		var rv []string
+		for _, g := range grants {
+			rv = append(rv, g.Principal.Id.ResourceType+":"+g.Principal.Id.Resource)
+		}
+		return rv
+	}
+
+	// system:masters is only granted cluster-admin implicitly
+	builder := newClusterRoleBuilder(client, provider, ConnectorOpts{}, nil)
+	grants, _, _, err := builder.Grants(ctx, resource, &pagination.Token{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"kube_user:alice", "kube_group:system:masters"}, principals(grants))
+	implicit, err := isImplicitGrant(grants[1])
+	require.NoError(t, err)
+	assert.True(t, implicit)
+
+	// The grant from the binding takes precedence over the implicit one
+	builder = newClusterRoleBuilder(client, provider, ConnectorOpts{IncludeSystemSubjects: true}, nil)
+	grants, _, _, err = builder.Grants(ctx, resource, &pagination.Token{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"kube_group:system:masters", "kube_user:alice"}, principals(grants))
+	assert.Equal(t, "cluster_role:cluster-admin:"+clusterScopedMember, grants[0].Entitlement.Id)
+	implicit, err = isImplicitGrant(grants[0])
+	require.NoError(t, err)
+	assert.False(t, implicit)
+}
+
+// TestClusterRoleBuilderGrants_ImplicitSystemMasters tests that system:masters is granted cluster-admin even
+// without a binding, and that the grant can't be revoked.
+func TestClusterRoleBuilderGrants_ImplicitSystemMasters(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "cluster-admin"}},
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "view"}},
+	)
+	builder := newClusterRoleBuilder(client, newMockClusterRoleBindingProvider(), ConnectorOpts{}, nil)
+
+	grants, _, _, err := builder.Grants(ctx, GenerateResourceForGrant("cluster-admin", ResourceTypeClusterRole.Id), &pagination.Token{})
+	require.NoError(t, err)
+	require.Len(t, grants, 1)
+	g := grants[0]
+	assert.Equal(t, "cluster_role:cluster-admin:"+clusterScopedMember, g.Entitlement.Id)
+	assert.Equal(t, ResourceTypeKubeGroup.Id, g.Principal.Id.ResourceType)
+	assert.Equal(t, SystemMastersGroup, g.Principal.Id.Resource)
+
+	metadata := &v2.GrantMetadata{}
+	annos := annotations.Annotations(g.Annotations)
+	ok, err := annos.Pick(metadata)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, true, metadata.Metadata.AsMap()[GrantMetadataImplicit])
+	assert.Equal(t, SubjectKindGroup, metadata.Metadata.AsMap()[GrantMetadataSubjectKind])
+
+	// Members of the group inherit the grant
+	expandable := &v2.GrantExpandable{}
+	ok, err = annos.Pick(expandable)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	_, err = builder.Revoke(ctx, g)
+	require.ErrorContains(t, err, "can't be revoked")
+
+	// Other roles have no implicit grants
+	grants, _, _, err = builder.Grants(ctx, GenerateResourceForGrant("view", ResourceTypeClusterRole.Id), &pagination.Token{})
+	require.NoError(t, err)
+	assert.Empty(t, grants)
+}
+
+// TestClusterRoleBuilderGrants_ServiceAccountGroups tests that roles bound to the system:serviceaccounts
+// groups are granted to the service accounts in them when enabled, and can't be revoked individually.
+func TestClusterRoleBuilderGrants_ServiceAccountGroups(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "view"}},
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "prod"}},
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "deployer", Namespace: "prod"}},
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "dev"}},
+	)
+	provider := newMockClusterRoleBindingProvider()
+	provider.clusterRoleBindings["view"] = []rbacv1.ClusterRoleBinding{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "prod-sa-view"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindClusterRole, Name: "view"},
+			Subjects:   []rbacv1.Subject{{Kind: SubjectKindGroup, APIGroup: RBACAPIGroup, Name: "system:serviceaccounts:prod"}},
+		},
+	}
+	provider.roleBindings["view"] = []rbacv1.RoleBinding{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "all-sa-view", Namespace: "dev"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindClusterRole, Name: "view"},
+			Subjects:   []rbacv1.Subject{{Kind: SubjectKindGroup, APIGroup: RBACAPIGroup, Name: ServiceAccountsGroup}},
+		},
+	}
+	resource := GenerateResourceForGrant("view", ResourceTypeClusterRole.Id)
+
+	// Disabled by default
+	builder := newClusterRoleBuilder(client, provider, ConnectorOpts{}, nil)
+	grants, _, _, err := builder.Grants(ctx, resource, &pagination.Token{})
+	require.NoError(t, err)
+	assert.Empty(t, grants)
+
+	builder = newClusterRoleBuilder(client, provider, ConnectorOpts{ExpandServiceAccountGroups: true}, nil)
+	grants, _, _, err = builder.Grants(ctx, resource, &pagination.Token{})
+	require.NoError(t, err)
+
+	var ids []string
+	for _, g := range grants {
+		assert.Equal(t, ResourceTypeServiceAccount.Id, g.Principal.Id.ResourceType)
+		ids = append(ids, g.Entitlement.Id+"="+g.Principal.Id.Resource)
+	}
+	assert.ElementsMatch(t, []string{
+		"cluster_role:view:" + clusterScopedMember + "=prod/default",
+		"cluster_role:view:" + clusterScopedMember + "=prod/deployer",
+		"cluster_role:view:dev:member=dev/default",
+		"cluster_role:view:dev:member=prod/default",
+		"cluster_role:view:dev:member=prod/deployer",
+	}, ids)
+
+	ref, ok, err := bindingRefFromGrant(grants[0])
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "system:serviceaccounts:prod", ref.viaGroup)
+
+	_, err = builder.Revoke(ctx, grants[0])
+	require.Error(t, err)
+}
+
+func TestServiceAccountGroupNamespace(t *testing.T) {
+	namespace, ok := serviceAccountGroupNamespace("system:serviceaccounts")
+	assert.True(t, ok)
+	assert.Empty(t, namespace)
+
+	namespace, ok = serviceAccountGroupNamespace("system:serviceaccounts:prod")
+	assert.True(t, ok)
+	assert.Equal(t, "prod", namespace)
+
+	_, ok = serviceAccountGroupNamespace("system:serviceaccounts:")
+	assert.False(t, ok)
+	_, ok = serviceAccountGroupNamespace("system:masters")
+	assert.False(t, ok)
+}
+
+// TestClusterRoleBuilder_NamespaceEntitlementSelector tests that only the namespaces matching the selector get
+// entitlements, and that grants from bindings in other namespaces go to other_namespaces:member or are dropped.
+func TestClusterRoleBuilder_NamespaceEntitlementSelector(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "payments", Labels: map[string]string{"tier": "prod"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "staging", Labels: map[string]string{"tier": "dev"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "scratch"}},
+		// A namespace named like the catch-all entitlement has an entitlement of its own
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "other", Labels: map[string]string{"tier": "prod"}}},
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "edit"}},
+	)
+	provider := newMockClusterRoleBindingProvider()
+	roleRef := rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindClusterRole, Name: "edit"}
+	for _, ns := range []string{"payments", "staging", "scratch", "other"} {
+		provider.roleBindings["edit"] = append(provider.roleBindings["edit"], rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: "edit"},
+			RoleRef:    roleRef,
+			Subjects:   []rbacv1.Subject{{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "alice-" + ns}},
+		})
+	}
+	resource := GenerateResourceForGrant("edit", ResourceTypeClusterRole.Id)
+
+	selector, err := labels.Parse("tier=prod")
+	require.NoError(t, err)
+
+	entitlementSlugs := func(builder *clusterRoleBuilder) []string {
+		entitlements, _, _, err := builder.Entitlements(ctx, resource, &pagination.Token{})
+		require.NoError(t, err)
+		var rv []string
+		for _, ent := range entitlements {
+			rv = append(rv, ent.Slug)
+		}
+		return rv
+	}
+	grantsByPrincipal := func(builder *clusterRoleBuilder) map[string]string {
+		grants, _, _, err := builder.Grants(ctx, resource, &pagination.Token{})
+		require.NoError(t, err)
+		rv := make(map[string]string)
+		for _, g := range grants {
+			rv[g.Principal.Id.Resource] = g.Entitlement.Id
+		}
+		return rv
+	}
+
+	// Without a selector every namespace has its own entitlement
+	builder := newClusterRoleBuilder(client, provider, ConnectorOpts{}, nil)
+	assert.Subset(t, entitlementSlugs(builder), []string{"payments:member", "staging:member", "scratch:member", "other:member"})
+	assert.NotContains(t, entitlementSlugs(builder), otherNamespacesMember)
+
+	// Bindings in unselected namespaces are granted other_namespaces:member
+	builder = newClusterRoleBuilder(client, provider, ConnectorOpts{NamespaceEntitlementSelector: selector}, nil)
+	slugs := entitlementSlugs(builder)
+	assert.Contains(t, slugs, "payments:member")
+	assert.Contains(t, slugs, "other:member")
+	assert.Contains(t, slugs, otherNamespacesMember)
+	assert.NotContains(t, slugs, "staging:member")
+	assert.NotContains(t, slugs, "scratch:member")
+	assert.Equal(t, map[string]string{
+		"alice-payments": "cluster_role:edit:payments:member",
+		"alice-other":    "cluster_role:edit:other:member",
+		"alice-staging":  "cluster_role:edit:" + otherNamespacesMember,
+		"alice-scratch":  "cluster_role:edit:" + otherNamespacesMember,
+	}, grantsByPrincipal(builder))
+
+	// The entitlement of the namespace named other is granted in the namespace
+	reviewAccess(client, allowAccessExcept())
+	_, err = builder.Grant(ctx, GenerateResourceForGrant("bob", ResourceTypeKubeUser.Id), &v2.Entitlement{
+		Id:       "cluster_role:edit:other:member",
+		Resource: resource,
+	})
+	require.NoError(t, err)
+	bindings, err := client.RbacV1().RoleBindings("other").List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, bindings.Items, 1)
+
+	// The catch-all entitlement can't be granted
+	_, err = builder.Grant(ctx, GenerateResourceForGrant("bob", ResourceTypeKubeUser.Id), &v2.Entitlement{
+		Id:       "cluster_role:edit:" + otherNamespacesMember,
+		Resource: resource,
+	})
+	require.ErrorContains(t, err, "can't be granted")
+
+	// Or they are dropped
+	builder = newClusterRoleBuilder(client, provider, ConnectorOpts{NamespaceEntitlementSelector: selector, DropUnselectedNamespaceGrants: true}, nil)
+	slugs = entitlementSlugs(builder)
+	assert.Contains(t, slugs, "payments:member")
+	assert.NotContains(t, slugs, otherNamespacesMember)
+	assert.Equal(t, map[string]string{
+		"alice-payments": "cluster_role:edit:payments:member",
+		"alice-other":    "cluster_role:edit:other:member",
+	}, grantsByPrincipal(builder))
+}
+
+// TestClusterRoleBuilder_NamespaceSnapshot tests that a namespace created mid-sync doesn't change the namespace
+// entitlements of the ClusterRoles until the next sync, which every ClusterRole builder sharing the connector's
+// snapshot sees at once.
+func TestClusterRoleBuilder_NamespaceSnapshot(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "payments"}},
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "edit"}},
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "view"}},
+	)
+	k := newTestKubernetes(client, ConnectorOpts{})
+	builder := newClusterRoleBuilder(client, newMockClusterRoleBindingProvider(), ConnectorOpts{}, nil)
+	builder.namespaces = k.namespaces
+
+	entitlementSlugs := func(name string) []string {
+		entitlements, _, _, err := builder.Entitlements(ctx, GenerateResourceForGrant(name, ResourceTypeClusterRole.Id), &pagination.Token{})
+		require.NoError(t, err)
+		var rv []string
+		for _, ent := range entitlements {
+			rv = append(rv, ent.Slug)
+		}
+		return rv
+	}
+
+	before := entitlementSlugs("edit")
+	assert.Contains(t, before, "payments:member")
+
+	_, err := client.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "billing"}}, metav1.CreateOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, before, entitlementSlugs("edit"))
+	assert.NotContains(t, entitlementSlugs("view"), "billing:member")
+
+	// The next sync snapshots the namespaces again
+	k.ResetCaches()
+	assert.Contains(t, entitlementSlugs("edit"), "billing:member")
+	assert.Contains(t, entitlementSlugs("view"), "billing:member")
+}
+
+// TestClusterRoleBuilderGrants_Pagination tests that the grants of a cluster role bound thousands of times are
+// returned a page at a time, and that a subject bound by every binding is granted once across the pages.
+func TestClusterRoleBuilderGrants_Pagination(t *testing.T) {
+	ctx := context.Background()
+	const bindingCount = 5000
+	roleRef := rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindClusterRole, Name: "view"}
+	shared := rbacv1.Subject{Kind: SubjectKindGroup, APIGroup: RBACAPIGroup, Name: "auditors"}
+	bindings := make([]rbacv1.ClusterRoleBinding, 0, bindingCount)
+	for i := range bindingCount {
+		bindings = append(bindings, rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("view-%04d", i)},
+			RoleRef:    roleRef,
+			Subjects: []rbacv1.Subject{
+				{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: fmt.Sprintf("user-%04d", i)},
+				shared,
+			},
+		})
+	}
+	client := fake.NewSimpleClientset(&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "view"}})
+	provider := newMockClusterRoleBindingProvider()
+	provider.clusterRoleBindings["view"] = bindings
+	builder := newClusterRoleBuilder(client, provider, ConnectorOpts{}, nil)
+
+	clusterRole, err := clusterRoleResource(&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "view"}}, ConnectorOpts{}, nil)
+	require.NoError(t, err)
+
+	seen := make(map[string]*v2.Grant)
+	token := &pagination.Token{}
+	pages := 0
+	for {
+		page, nextPageToken, _, err := builder.Grants(ctx, clusterRole, token)
+		require.NoError(t, err)
+		pages++
+		require.LessOrEqual(t, pages, 10, "pagination doesn't terminate")
+		assert.LessOrEqual(t, len(page), GrantsPageSize)
+		for _, g := range page {
+			assert.NotContains(t, seen, g.Id, "grant returned twice")
+			seen[g.Id] = g
+		}
+		if nextPageToken == "" {
+			break
+		}
+		token = &pagination.Token{Token: nextPageToken}
+	}
+
+	assert.Equal(t, 6, pages)
+	assert.Len(t, seen, bindingCount+1)
+
+	var sharedGrant *v2.Grant
+	for _, g := range seen {
+		if g.Principal.Id.Resource == shared.Name {
+			sharedGrant = g
+		}
+	}
+	require.NotNil(t, sharedGrant)
+	additional, err := additionalBindingRefs(sharedGrant)
+	require.NoError(t, err)
+	assert.Len(t, additional, bindingCount-1)
+}
+
+// TestClusterRoleBuilderEntitlements_Pagination tests that the namespaced membership entitlements of a cluster
+// role are returned a page of namespaces at a time, with the cluster-wide entitlements on the first page, and
+// that the namespaces are listed once for all pages.
+func TestClusterRoleBuilderEntitlements_Pagination(t *testing.T) {
+	ctx := context.Background()
+	const namespaceCount = 1200
+	objects := make([]runtime.Object, 0, namespaceCount)
+	for i := range namespaceCount {
+		objects = append(objects, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("ns-%04d", i)}})
+	}
+	client := fake.NewSimpleClientset(objects...)
+	builder := newClusterRoleBuilder(client, newMockClusterRoleBindingProvider(), ConnectorOpts{}, nil)
+
+	clusterRole, err := clusterRoleResource(&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "view"}}, ConnectorOpts{}, nil)
+	require.NoError(t, err)
+
+	namespaced := make(map[string]bool)
+	var pageSizes []int
+	clusterScoped := 0
+	token := &pagination.Token{}
+	for {
+		page, nextPageToken, _, err := builder.Entitlements(ctx, clusterRole, token)
+		require.NoError(t, err)
+		require.Less(t, len(pageSizes), 10, "pagination doesn't terminate")
+
+		namespacedInPage := 0
+		for _, ent := range page {
+			if strings.HasPrefix(ent.Slug, "ns-") {
+				assert.NotContains(t, namespaced, ent.Slug, "entitlement returned twice")
+				namespaced[ent.Slug] = true
+				namespacedInPage++
+			} else {
+				clusterScoped++
+				assert.Empty(t, pageSizes, "cluster-wide entitlement %s after the first page", ent.Slug)
+			}
+		}
+		pageSizes = append(pageSizes, namespacedInPage)
+
+		if nextPageToken == "" {
+			break
+		}
+		token = &pagination.Token{Token: nextPageToken}
+	}
+
+	assert.Equal(t, []int{500, 500, 200}, pageSizes)
+	assert.Len(t, namespaced, namespaceCount)
+	assert.Equal(t, 1+len(roleEscalationEntitlements(clusterRole)), clusterScoped)
+
+	lists := 0
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "list" && action.GetResource().Resource == "namespaces" {
+			lists++
+		}
+	}
+	assert.Equal(t, 1, lists)
+}
+
+// TestClusterRoleBuilder_CompactEntitlements tests that compact entitlements cover the same subjects in the same
+// namespaces as the per-namespace entitlements, with the namespaces recorded in the grants, and that their
+// grants can be revoked but not granted.
+func TestClusterRoleBuilder_CompactEntitlements(t *testing.T) {
+	ctx := context.Background()
+	alice := rbacv1.Subject{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "alice"}
+	bob := rbacv1.Subject{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "bob"}
+	carol := rbacv1.Subject{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "carol"}
+	roleRef := rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindClusterRole, Name: "edit"}
+	roleBindings := []rbacv1.RoleBinding{
+		{ObjectMeta: metav1.ObjectMeta{Name: "edit", Namespace: "payments"}, RoleRef: roleRef, Subjects: []rbacv1.Subject{alice, bob}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "edit", Namespace: "staging"}, RoleRef: roleRef, Subjects: []rbacv1.Subject{alice}},
+	}
+	clusterRoleBindings := []rbacv1.ClusterRoleBinding{
+		{ObjectMeta: metav1.ObjectMeta{Name: "edit-everywhere"}, RoleRef: roleRef, Subjects: []rbacv1.Subject{carol}},
+	}
+	newClient := func() *fake.Clientset {
+		return fake.NewSimpleClientset(
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "payments"}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "staging"}},
+			&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "edit"}},
+			roleBindings[0].DeepCopy(),
+			roleBindings[1].DeepCopy(),
+		)
+	}
+	newProvider := func() *mockClusterRoleBindingProvider {
+		provider := newMockClusterRoleBindingProvider()
+		provider.roleBindings["edit"] = []rbacv1.RoleBinding{*roleBindings[0].DeepCopy(), *roleBindings[1].DeepCopy()}
+		provider.clusterRoleBindings["edit"] = []rbacv1.ClusterRoleBinding{*clusterRoleBindings[0].DeepCopy()}
+		return provider
+	}
+	resource, err := clusterRoleResource(&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "edit"}}, ConnectorOpts{}, nil)
+	require.NoError(t, err)
+
+	// coverage lists the principals granted the role as principal@namespace, * standing for every namespace
+	coverage := func(builder *clusterRoleBuilder) []string {
+		grants, _, _, err := builder.Grants(ctx, resource, &pagination.Token{})
+		require.NoError(t, err)
+		var rv []string
+		for _, g := range grants {
+			slug := strings.TrimPrefix(g.Entitlement.Id, "cluster_role:edit:")
+			switch {
+			case slug == clusterScopedMember:
+				rv = append(rv, g.Principal.Id.Resource+"@*")
+			case slug == namespacedMember:
+				ref, ok, err := bindingRefFromGrant(g)
+				require.NoError(t, err)
+				require.True(t, ok)
+				rv = append(rv, g.Principal.Id.Resource+"@"+ref.namespace)
+				additional, err := additionalBindingRefs(g)
+				require.NoError(t, err)
+				for _, other := range additional {
+					rv = append(rv, g.Principal.Id.Resource+"@"+other.namespace)
+				}
+			default:
+				namespace, ok := strings.CutSuffix(slug, ":member")
+				require.True(t, ok, slug)
+				rv = append(rv, g.Principal.Id.Resource+"@"+namespace)
+			}
+		}
+		return rv
+	}
+
+	full := newClusterRoleBuilder(newClient(), newProvider(), ConnectorOpts{}, nil)
+	compact := newClusterRoleBuilder(newClient(), newProvider(), ConnectorOpts{CompactClusterRoleEntitlements: true}, nil)
+
+	expected := []string{"alice@payments", "alice@staging", "bob@payments", "carol@*"}
+	assert.ElementsMatch(t, expected, coverage(full))
+	assert.ElementsMatch(t, expected, coverage(compact))
+
+	// Only the two membership entitlements are created, whatever the number of namespaces
+	entitlements, nextPageToken, _, err := compact.Entitlements(ctx, resource, &pagination.Token{})
+	require.NoError(t, err)
+	assert.Empty(t, nextPageToken)
+	var slugs []string
+	for _, ent := range entitlements {
+		slugs = append(slugs, ent.Slug)
+	}
+	assert.Subset(t, slugs, []string{clusterScopedMember, namespacedMember})
+	assert.Len(t, slugs, 2+len(roleEscalationEntitlements(resource)))
+
+	// The namespaced entitlement can't be the entitlement of a namespace
+	namespace, ok := strings.CutSuffix(namespacedMember, ":member")
+	require.True(t, ok)
+	assert.NotEmpty(t, validation.IsDNS1123Label(namespace))
+
+	// The namespaced entitlement names no namespace to bind in
+	namespacedEnt := &v2.Entitlement{Id: "cluster_role:edit:" + namespacedMember, Slug: namespacedMember, Resource: resource}
+	principal := GenerateResourceForGrant("dave", ResourceTypeKubeUser.Id)
+	_, err = compact.Grant(ctx, principal, namespacedEnt)
+	require.Error(t, err)
+
+	// Revoking removes the subject from the bindings in every namespace
+	client := newClient()
+	compact = newClusterRoleBuilder(client, newProvider(), ConnectorOpts{CompactClusterRoleEntitlements: true}, nil)
+	grants, _, _, err := compact.Grants(ctx, resource, &pagination.Token{})
+	require.NoError(t, err)
+	var aliceGrant *v2.Grant
+	for _, g := range grants {
+		if g.Principal.Id.Resource == "alice" {
+			aliceGrant = g
+		}
+	}
+	require.NotNil(t, aliceGrant)
+	_, err = compact.Revoke(ctx, aliceGrant)
+	require.NoError(t, err)
+
+	binding, err := client.RbacV1().RoleBindings("payments").Get(ctx, "edit", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []rbacv1.Subject{bob}, binding.Subjects)
+	_, err = client.RbacV1().RoleBindings("staging").Get(ctx, "edit", metav1.GetOptions{})
+	assert.True(t, k8serrors.IsNotFound(err))
+}
+
+// TestClusterRoleResource_RulesTruncated tests that the rules in the profile of a ClusterRole are capped at the
+// configured limit.
+func TestClusterRoleResource_RulesTruncated(t *testing.T) {
+	clusterRole := &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "crd-reader"}}
+	for i := 0; i < 3; i++ {
+		clusterRole.Rules = append(clusterRole.Rules, rbacv1.PolicyRule{
+			APIGroups: []string{fmt.Sprintf("group-%d.example.com", i)}, Resources: []string{"*"}, Verbs: []string{"get"},
+		})
+	}
+
+	resource, err := clusterRoleResource(clusterRole, ConnectorOpts{ProfileRulesLimit: 2}, nil)
+	require.NoError(t, err)
+	roleTrait, err := rs.GetRoleTrait(resource)
+	require.NoError(t, err)
+	profile := roleTrait.Profile.AsMap()
+	require.Len(t, profile[ProfileRules], 2)
+	assert.Equal(t, []interface{}{"group-1.example.com"}, profile[ProfileRules].([]interface{})[1].(map[string]interface{})["apiGroups"])
+	assert.Equal(t, true, profile[ProfileRulesTruncated])
+
+	resource, err = clusterRoleResource(clusterRole, ConnectorOpts{}, nil)
+	require.NoError(t, err)
+	roleTrait, err = rs.GetRoleTrait(resource)
+	require.NoError(t, err)
+	assert.Len(t, roleTrait.Profile.AsMap()[ProfileRules], 3)
+	assert.Equal(t, false, roleTrait.Profile.AsMap()[ProfileRulesTruncated])
+}
+
+// TestClusterRoleResource_ExternalID tests that cluster roles are correlated across syncs by their UID.
+func TestClusterRoleResource_ExternalID(t *testing.T) {
+	resource, err := clusterRoleResource(&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "crd-reader", UID: "cr-uid"}}, ConnectorOpts{}, nil)
+	require.NoError(t, err)
+	require.NotNil(t, resource.ExternalId)
+	assert.Equal(t, "cr-uid", resource.ExternalId.Id)
+
+	roleTrait, err := rs.GetRoleTrait(resource)
+	require.NoError(t, err)
+	assert.Equal(t, "cr-uid", roleTrait.Profile.AsMap()["uid"])
+}
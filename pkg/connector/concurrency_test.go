@@ -0,0 +1,233 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// concurrencyTestWorkers is the number of goroutines calling each syncer at once.
+const concurrencyTestWorkers = 8
+
+// concurrencyTestClient returns a fake cluster with objects of every synced type in a few namespaces, bound to
+// the same users and groups from several bindings.
+func concurrencyTestClient() *fake.Clientset {
+	var objects []runtime.Object
+	objects = append(objects,
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}},
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "viewer"}, Rules: []rbacv1.PolicyRule{
+			{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"secrets"}},
+		}},
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "viewers"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindClusterRole, Name: "viewer"},
+			Subjects:   []rbacv1.Subject{{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "alice"}},
+		},
+	)
+	for i := 0; i < 5; i++ {
+		namespace := fmt.Sprintf("team-%d", i)
+		meta := metav1.ObjectMeta{Name: "api", Namespace: namespace}
+		objects = append(objects,
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}},
+			&corev1.ServiceAccount{ObjectMeta: meta},
+			&corev1.Secret{ObjectMeta: meta},
+			&corev1.ConfigMap{ObjectMeta: meta},
+			&corev1.Service{ObjectMeta: meta},
+			&corev1.Pod{ObjectMeta: meta, Spec: corev1.PodSpec{ServiceAccountName: "api"}},
+			&appsv1.Deployment{ObjectMeta: meta},
+			&appsv1.StatefulSet{ObjectMeta: meta},
+			&appsv1.DaemonSet{ObjectMeta: meta},
+			&rbacv1.Role{ObjectMeta: meta, Rules: []rbacv1.PolicyRule{
+				{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"configmaps"}},
+			}},
+			&rbacv1.RoleBinding{
+				ObjectMeta: meta,
+				RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindRole, Name: "api"},
+				Subjects: []rbacv1.Subject{
+					{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "alice"},
+					{Kind: SubjectKindGroup, APIGroup: RBACAPIGroup, Name: "developers"},
+				},
+			},
+			&rbacv1.RoleBinding{
+				ObjectMeta: metav1.ObjectMeta{Name: "viewers", Namespace: namespace},
+				RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindClusterRole, Name: "viewer"},
+				Subjects:   []rbacv1.Subject{{Kind: SubjectKindGroup, APIGroup: RBACAPIGroup, Name: "developers"}},
+			},
+		)
+	}
+	return fake.NewSimpleClientset(objects...)
+}
+
+// syncAll lists every page of a syncer, under each namespace for service accounts, and the entitlements and
+// grants of every resource, returning the IDs of the listed resources.
+func syncAll(ctx context.Context, syncer connectorbuilder.ResourceSyncer, namespaces []string) ([]string, error) {
+	parents := []*v2.ResourceId{nil}
+	if syncer.ResourceType(ctx).Id == ResourceTypeServiceAccount.Id {
+		parents = nil
+		for _, namespace := range namespaces {
+			parents = append(parents, &v2.ResourceId{ResourceType: ResourceTypeNamespace.Id, Resource: namespace})
+		}
+	}
+
+	var ids []string
+	for _, parent := range parents {
+		pageToken := ""
+		for {
+			resources, next, _, err := syncer.List(ctx, parent, &pagination.Token{Token: pageToken})
+			if err != nil {
+				return nil, err
+			}
+			for _, resource := range resources {
+				ids = append(ids, resource.Id.Resource)
+				if err := syncResourceAccess(ctx, syncer, resource); err != nil {
+					return nil, err
+				}
+			}
+			if next == "" {
+				break
+			}
+			pageToken = next
+		}
+	}
+	return ids, nil
+}
+
+// syncResourceAccess lists every page of the entitlements and grants of a resource.
+func syncResourceAccess(ctx context.Context, syncer connectorbuilder.ResourceSyncer, resource *v2.Resource) error {
+	pageToken := ""
+	for {
+		_, next, _, err := syncer.Entitlements(ctx, resource, &pagination.Token{Token: pageToken})
+		if err != nil {
+			return err
+		}
+		if next == "" {
+			break
+		}
+		pageToken = next
+	}
+	pageToken = ""
+	for {
+		_, next, _, err := syncer.Grants(ctx, resource, &pagination.Token{Token: pageToken})
+		if err != nil {
+			return err
+		}
+		if next == "" {
+			return nil
+		}
+		pageToken = next
+	}
+}
+
+// TestResourceSyncers_Concurrent syncs every syncer from several goroutines at once. Run with -race, it checks
+// that the builders and the caches they share don't race; without, that concurrent syncs don't fail.
+func TestResourceSyncers_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	namespaces := []string{"team-0", "team-1", "team-2", "team-3", "team-4"}
+
+	for _, tc := range []struct {
+		name string
+		opts ConnectorOpts
+	}{
+		{name: "defaults", opts: ConnectorOpts{AllowEmptySync: true}},
+		{name: "all options", opts: ConnectorOpts{
+			IncludeSystemSubjects:      true,
+			SeparateSystemUsers:        true,
+			ExpandServiceAccountGroups: true,
+			MountGrants:                true,
+			NamespaceWildcards:         true,
+			GrantsPageSize:             1,
+			Redact:                     &RedactOptions{Key: []byte("secret-key")},
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			k := newTestKubernetes(concurrencyTestClient(), tc.opts)
+			syncers := k.ResourceSyncers(ctx)
+
+			var wg sync.WaitGroup
+			errs := make(chan error, len(syncers)*concurrencyTestWorkers)
+			for _, syncer := range syncers {
+				for i := 0; i < concurrencyTestWorkers; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						if _, err := syncAll(ctx, syncer, namespaces); err != nil {
+							errs <- fmt.Errorf("%s: %w", syncer.ResourceType(ctx).Id, err)
+						}
+					}()
+				}
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+// TestKubeSubjectBuilders_ConcurrentListsDeduplicate tests that users and groups bound in many bindings are
+// listed once by a sync even when its later pages are listed concurrently.
+func TestKubeSubjectBuilders_ConcurrentListsDeduplicate(t *testing.T) {
+	ctx := context.Background()
+	client := concurrencyTestClient()
+
+	for _, syncer := range []connectorbuilder.ResourceSyncer{
+		newKubeUserBuilder(client, nil, ConnectorOpts{DisableWildcardResources: true}),
+		newKubeGroupBuilder(client, nil, ConnectorOpts{DisableWildcardResources: true}),
+	} {
+		// The first page starts the sync, the pages after it are listed by all workers at once
+		resources, next, _, err := syncer.List(ctx, nil, &pagination.Token{})
+		require.NoError(t, err)
+		require.NotEmpty(t, next)
+		var ids []string
+		for _, resource := range resources {
+			ids = append(ids, resource.Id.Resource)
+		}
+
+		var (
+			wg sync.WaitGroup
+			mu sync.Mutex
+		)
+		for i := 0; i < concurrencyTestWorkers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for pageToken := next; pageToken != ""; {
+					resources, nextPage, _, err := syncer.List(ctx, nil, &pagination.Token{Token: pageToken})
+					if !assert.NoError(t, err) {
+						return
+					}
+					mu.Lock()
+					for _, resource := range resources {
+						ids = append(ids, resource.Id.Resource)
+					}
+					mu.Unlock()
+					pageToken = nextPage
+				}
+			}()
+		}
+		wg.Wait()
+
+		require.NotEmpty(t, ids)
+		counts := make(map[string]int)
+		for _, id := range ids {
+			counts[id]++
+		}
+		for id, count := range counts {
+			assert.Equal(t, 1, count, "%s %s listed more than once", syncer.ResourceType(ctx).Id, id)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package connector
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -17,9 +18,13 @@ import (
 	"go.uber.org/zap"
 )
 
-// configMapBuilder syncs Kubernetes ConfigMaps as Baton resources.
+// configMapBuilder syncs Kubernetes ConfigMaps as Baton resources. It's safe for concurrent use, the pods and
+// workloads it links configmaps to coming from the connector's locked caches.
 type configMapBuilder struct {
-	client kubernetes.Interface
+	client           kubernetes.Interface
+	podProvider      PodProvider
+	workloadProvider WorkloadProvider
+	opts             ConnectorOpts
 }
 
 // ResourceType returns the resource type for ConfigMap.
@@ -31,41 +36,36 @@ func (c *configMapBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
 func (c *configMapBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
 	l := ctxzap.Extract(ctx)
 
-	// Initialize empty resource slice
-	var rv []*v2.Resource
-
 	// Parse pagination token
 	bag, err := ParsePageToken(pToken.Token)
 	if err != nil {
 		return nil, "", nil, fmt.Errorf("failed to parse page token: %w", err)
 	}
 
-	// Add wildcard resource first, but only on the first page (when page token is empty)
-	if bag.PageToken() == "" {
-		wildcardResource, err := generateWildcardResource(ResourceTypeConfigMap)
-		if err != nil {
-			l.Error("failed to create wildcard resource for configmaps", zap.Error(err))
-		} else {
-			rv = append(rv, wildcardResource)
-		}
+	// List the namespace the SDK lists the children of, or all namespaces, after the wildcards
+	namespace, rv, done, err := startNamespacedList(ctx, c.client, c.opts, ResourceTypeConfigMap, parentResourceID, bag.PageToken(), nil)
+	if err != nil || done {
+		return rv, "", nil, err
 	}
 
 	// Set up list options with pagination
 	opts := metav1.ListOptions{
-		Limit:    ResourcesPageSize,
+		Limit:    c.opts.pageSize(ResourceTypeConfigMap.Id),
 		Continue: bag.PageToken(),
 	}
 
-	// Fetch configmaps from the Kubernetes API across all namespaces
+	// Fetch configmaps from the Kubernetes API in the namespace, or across all namespaces
 	l.Debug("fetching configmaps", zap.String("continue_token", opts.Continue))
-	resp, err := c.client.CoreV1().ConfigMaps("").List(ctx, opts)
+	resp, err := listWithRetry(ctx, c.opts, func(ctx context.Context) (*corev1.ConfigMapList, error) {
+		return c.client.CoreV1().ConfigMaps(namespace).List(ctx, opts)
+	})
 	if err != nil {
 		return nil, "", nil, fmt.Errorf("failed to list configmaps: %w", err)
 	}
 
 	// Process each configmap into a Baton resource
 	for _, cm := range resp.Items {
-		resource, err := configMapResource(&cm)
+		resource, err := configMapResource(&cm, c.opts)
 		if err != nil {
 			l.Error("failed to create configmap resource",
 				zap.String("namespace", cm.Namespace),
@@ -86,7 +86,7 @@ func (c *configMapBuilder) List(ctx context.Context, parentResourceID *v2.Resour
 }
 
 // configMapResource creates a Baton resource from a Kubernetes ConfigMap.
-func configMapResource(cm *corev1.ConfigMap) (*v2.Resource, error) {
+func configMapResource(cm *corev1.ConfigMap, opts ConnectorOpts) (*v2.Resource, error) {
 	// Get parent namespace resource ID
 	parentID, err := NamespaceResourceID(cm.Namespace)
 	if err != nil {
@@ -104,8 +104,15 @@ func configMapResource(cm *corev1.ConfigMap) (*v2.Resource, error) {
 		options = append(options, rs.WithExternalID(&v2.ExternalId{Id: string(cm.UID)}))
 	}
 
+	// Add configured label tags
+	tagOptions, err := labelTagOptions(cm.Labels, opts)
+	if err != nil {
+		return nil, err
+	}
+	options = append(options, tagOptions...)
+
 	// Create the raw ID as namespace/name
-	rawID := cm.Namespace + "/" + cm.Name
+	rawID := namespacedName(cm.Namespace, cm.Name)
 
 	// Create resource
 	resource, err := rs.NewResource(
@@ -125,32 +132,80 @@ func configMapResource(cm *corev1.ConfigMap) (*v2.Resource, error) {
 func (c *configMapBuilder) Entitlements(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
 	var entitlements []*v2.Entitlement
 
+	grantableTo := []*v2.ResourceType{ResourceTypeRole, ResourceTypeClusterRole}
+
 	// Add standard verb entitlements
-	for _, verb := range standardResourceVerbs {
+	for _, verb := range c.opts.resourceVerbs(ResourceTypeConfigMap.Id) {
+		// Pods consuming the configmap read it as their service account
+		verbGrantableTo := grantableTo
+		if c.opts.MountGrants && verb == mountGrantVerb {
+			verbGrantableTo = append(verbGrantableTo[:len(verbGrantableTo):len(verbGrantableTo)], ResourceTypeServiceAccount)
+		}
 		ent := entitlement.NewPermissionEntitlement(
 			resource,
 			verb,
 			entitlement.WithDisplayName(fmt.Sprintf("%s %s", verb, resource.DisplayName)),
 			entitlement.WithDescription(fmt.Sprintf("Grants %s permission on the %s configmap", verb, resource.DisplayName)),
-			entitlement.WithGrantableTo(
-				ResourceTypeRole,
-				ResourceTypeClusterRole,
-			),
+			entitlement.WithGrantableTo(verbGrantableTo...),
 		)
 		entitlements = append(entitlements, ent)
 	}
 
+	// Workloads consuming the configmap in their pod template
+	if c.opts.MountGrants {
+		entitlements = append(entitlements, entitlement.NewAssignmentEntitlement(
+			resource,
+			ConfigMapMountedByEntitlement,
+			entitlement.WithDisplayName(fmt.Sprintf("%s mounted by", resource.DisplayName)),
+			entitlement.WithDescription(fmt.Sprintf("Workloads whose pod template consumes the %s configmap", resource.DisplayName)),
+			entitlement.WithGrantableTo(ResourceTypeDeployment, ResourceTypeStatefulSet, ResourceTypeDaemonSet),
+		))
+	}
+
 	return entitlements, "", nil, nil
 }
 
-// Grants returns no grants for ConfigMap resources.
+// Grants returns, when mount grants are enabled, get grants to the service accounts of the pods consuming the
+// configmap, which can read it regardless of RBAC, and mounted_by grants to the workloads whose pod template
+// consumes it. Grants from roles are emitted by the role syncers.
 func (c *configMapBuilder) Grants(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
-	return nil, "", nil, nil
+	if !c.opts.MountGrants || c.podProvider == nil || isWildcardResourceID(resource.Id.Resource) {
+		return nil, "", nil, nil
+	}
+
+	namespace, name, ok := strings.Cut(resource.Id.Resource, "/")
+	if !ok {
+		return nil, "", nil, fmt.Errorf("invalid configmap resource ID format: %s", resource.Id.Resource)
+	}
+
+	pods, err := c.podProvider.GetPodsInNamespace(ctx, namespace)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to get pods: %w", err)
+	}
+
+	rv := mountGrants(resource, namespace, pods, func(pod *corev1.Pod) bool {
+		return podMountRefs(pod).configMaps[name]
+	})
+
+	if c.workloadProvider != nil {
+		templates, err := c.workloadProvider.GetWorkloadPodTemplates(ctx, namespace)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("failed to get workload pod templates: %w", err)
+		}
+		rv = append(rv, workloadMountGrants(resource, namespace, templates, func(spec *corev1.PodSpec) bool {
+			return podSpecMountRefs(spec).configMaps[name]
+		})...)
+	}
+
+	return c.opts.filterVerbGrants(resource, rv), "", nil, nil
 }
 
 // newConfigMapBuilder creates a new configmap builder.
-func newConfigMapBuilder(client kubernetes.Interface) *configMapBuilder {
+func newConfigMapBuilder(client kubernetes.Interface, podProvider PodProvider, workloadProvider WorkloadProvider, opts ConnectorOpts) *configMapBuilder {
 	return &configMapBuilder{
-		client: client,
+		client:           client,
+		podProvider:      podProvider,
+		workloadProvider: workloadProvider,
+		opts:             opts,
 	}
 }
@@ -3,16 +3,23 @@ package connector
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/annotations"
 	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
 	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 )
@@ -34,22 +41,120 @@ var (
 	ResourceTypeClusterRole    = &v2.ResourceType{Id: "cluster_role", DisplayName: "Cluster Role", Traits: []v2.ResourceType_Trait{v2.ResourceType_TRAIT_ROLE}}
 	ResourceTypeSecret         = &v2.ResourceType{Id: "secret", DisplayName: "Secret", Traits: []v2.ResourceType_Trait{v2.ResourceType_TRAIT_SECRET}}
 	ResourceTypeConfigMap      = &v2.ResourceType{Id: "configmap", DisplayName: "Config Map"}
+	ResourceTypeService        = &v2.ResourceType{Id: "service", DisplayName: "Service"}
 	ResourceTypeNode           = &v2.ResourceType{Id: "node", DisplayName: "Node"}
 	ResourceTypePod            = &v2.ResourceType{Id: "pod", DisplayName: "Pod"}
 	ResourceTypeDeployment     = &v2.ResourceType{Id: "deployment", DisplayName: "Deployment"}
 	ResourceTypeStatefulSet    = &v2.ResourceType{Id: "statefulset", DisplayName: "Stateful Set"}
 	ResourceTypeDaemonSet      = &v2.ResourceType{Id: "daemonset", DisplayName: "Daemon Set"}
+	ResourceTypeReplicaSet     = &v2.ResourceType{Id: "replicaset", DisplayName: "Replica Set"}
 	ResourceTypeKubeUser       = &v2.ResourceType{Id: "kube_user", DisplayName: "Kubernetes User", Traits: []v2.ResourceType_Trait{v2.ResourceType_TRAIT_USER}}
+	ResourceTypeKubeSystemUser = &v2.ResourceType{Id: "kube_system_user", DisplayName: "Kubernetes System User", Description: "Kubernetes component and node identities with system: names", Traits: []v2.ResourceType_Trait{v2.ResourceType_TRAIT_USER}}
 	ResourceTypeKubeGroup      = &v2.ResourceType{Id: "kube_group", DisplayName: "Kubernetes Group", Traits: []v2.ResourceType_Trait{v2.ResourceType_TRAIT_GROUP}}
 	ResourceTypeBinding        = &v2.ResourceType{Id: "binding", DisplayName: "Binding", Description: "Internal type for processing RBAC bindings"}
 	ResourceTypeUser           = &v2.ResourceType{Id: "user", DisplayName: "User", Traits: []v2.ResourceType_Trait{v2.ResourceType_TRAIT_USER}}
 	ResourceTypeGroup          = &v2.ResourceType{Id: "group", DisplayName: "Group", Traits: []v2.ResourceType_Trait{v2.ResourceType_TRAIT_GROUP}}
+	ResourceTypeCluster        = &v2.ResourceType{Id: "cluster", DisplayName: "Cluster"}
+	ResourceTypeProject        = &v2.ResourceType{Id: "project", DisplayName: "Rancher Project", Description: "Rancher projects grouping namespaces", Traits: []v2.ResourceType_Trait{v2.ResourceType_TRAIT_GROUP}}
+	ResourceTypeReport         = &v2.ResourceType{Id: "report", DisplayName: "Report", Description: "Summaries computed from the synced RBAC", Traits: []v2.ResourceType_Trait{v2.ResourceType_TRAIT_APP}}
 )
 
 // Configuration options.
 type ConnectorOpts struct {
 	SyncResources []string
 	CustomSyncer  map[string]ResourceSyncerBuilder
+	LabelTags     []string
+	// RedactAnnotationKeys are annotation keys left out of the profiles of every resource type, in addition to
+	// the sensitiveAnnotations always left out of the profiles of secrets.
+	RedactAnnotationKeys []string
+	// GrantSources contribute grants from outside RBAC, merged after the grants of the builders.
+	GrantSources []GrantSource
+	// SkipMissingNamedResources drops rule grants on resourceNames that don't exist in the cluster.
+	SkipMissingNamedResources bool
+	// IncludeSystemSubjects grants roles to system users and groups like system:masters. The implicit
+	// cluster-admin grant of system:masters is emitted either way.
+	IncludeSystemSubjects bool
+	// SkipSystemClusterRoles leaves the "system:" ClusterRoles used by Kubernetes components out of the sync.
+	SkipSystemClusterRoles bool
+	// SeparateSystemUsers syncs users with "system:" names as kube_system_user rather than kube_user resources.
+	SeparateSystemUsers bool
+	// ExpandServiceAccountGroups grants the roles of the system:serviceaccounts groups to their service accounts.
+	ExpandServiceAccountGroups bool
+	// AllowEmptySync lets syncs that find no namespaces or no roles succeed.
+	AllowEmptySync bool
+	// VerifyCoverage checks that the connector can list the key resources in every synced namespace, failing
+	// the sync with ErrPartialSync if it can't.
+	VerifyCoverage bool
+	// AllowPartialSync lets validation pass when the connector can't list some of the synced resources,
+	// returning a warning annotation naming them rather than failing with ErrForbidden.
+	AllowPartialSync bool
+	// Redact enables the privacy mode pseudonymizing names in the sync output.
+	Redact *RedactOptions
+	// RemoteTokenSecret references the Secret in the local cluster holding the bearer token for the synced
+	// cluster, read with the in-cluster config.
+	RemoteTokenSecret *SecretKeyRef
+	// NamespaceEntitlementSelector limits the per-namespace ClusterRole entitlements to the matching namespaces.
+	// Grants from bindings in other namespaces go to the catch-all other_namespaces:member entitlement.
+	NamespaceEntitlementSelector labels.Selector
+	// DropUnselectedNamespaceGrants drops the grants from bindings in namespaces not matching
+	// NamespaceEntitlementSelector instead of granting other_namespaces:member.
+	DropUnselectedNamespaceGrants bool
+	// CompactClusterRoleEntitlements replaces the per-namespace ClusterRole entitlements with a single
+	// namespaced_bindings:member entitlement, the namespaces being recorded in the grants.
+	CompactClusterRoleEntitlements bool
+	// DisableWildcardResources leaves the wildcard resources standing for all resources of a type out of the sync,
+	// along with the rule grants on them.
+	DisableWildcardResources bool
+	// NamespaceWildcards adds a wildcard resource per namespace for the namespaced resource types, which the
+	// rules of Roles without resourceNames are granted on instead of the cluster-wide wildcard.
+	NamespaceWildcards bool
+	// MountGrants grants get on secrets and configmaps to the service accounts of the pods mounting them.
+	MountGrants bool
+	// BindingsCacheDir is the directory the bindings caches are persisted in across restarts, if set.
+	BindingsCacheDir string
+	// KeepCachesAcrossSyncs keeps the bindings, namespaces, pods and secret references loaded by the first sync
+	// for the lifetime of the connector instead of loading them again in each sync.
+	KeepCachesAcrossSyncs bool
+	// PageSizes overrides the page size of the listings of resource types, keyed by resource type ID.
+	PageSizes map[string]int64
+	// PodSampleRate is the fraction of pods synced, picked deterministically, or 0 to sync every pod.
+	PodSampleRate float64
+	// GrantsPageSize is the number of grants per page of the role and cluster role grants. Zero uses the
+	// GrantsPageSize default.
+	GrantsPageSize int
+	// RequestTimeout is the deadline of each call to the API server listing resources or loading the bindings
+	// caches. Zero uses the Timeout of the REST config, and no deadline if that's zero too.
+	RequestTimeout time.Duration
+	// ListRetries is the number of times a List call failing with a transient API server error is retried.
+	// Connectors created with New or NewForClient default to DefaultListRetries.
+	ListRetries int
+	// ProfileRulesLimit is the maximum number of PolicyRules listed in the profile of a Role or ClusterRole. Zero
+	// uses the ProfileRulesLimit default.
+	ProfileRulesLimit int
+	// SkipGrantPreCheck skips verifying that the connector may create a binding before provisioning it.
+	SkipGrantPreCheck bool
+	// ClusterFingerprintDir is the directory the fingerprint of the synced cluster is recorded in, if set.
+	ClusterFingerprintDir string
+	// AcceptClusterChange records a new cluster fingerprint instead of failing when the synced cluster changed.
+	AcceptClusterChange bool
+	// SecretSensitivity computes the sensitivity tier of secrets from the ingresses, pods and webhook
+	// configurations referencing them.
+	SecretSensitivity bool
+	// ClusterAdminsReport syncs the cluster-admins report resource listing the admin-equivalent principals.
+	ClusterAdminsReport bool
+	// RoleGrantableBy adds the principals able to grant each Role to its profile.
+	RoleGrantableBy bool
+	// VerbFilter limits the standard verb entitlements of each resource type ID, or of VerbFilterDefault.
+	VerbFilter map[string][]string
+	// InheritAggregatedBindings grants ClusterRoles the memberships of the aggregated ClusterRoles they contribute to.
+	InheritAggregatedBindings bool
+	// SkipDefaultServiceAccounts leaves out the default ServiceAccounts that have no secrets, no image pull
+	// secrets and no binding naming them.
+	SkipDefaultServiceAccounts bool
+	// RancherProjects syncs the Rancher projects namespaces are grouped into, with their members.
+	RancherProjects bool
+	// GroupMembershipFile is the YAML or JSON file mapping group names to the usernames of their members, if set.
+	GroupMembershipFile string
 }
 
 // ConnectorOption is a function that configures the connector options.
@@ -72,6 +177,515 @@ func WithCustomSyncers(syncers map[string]ResourceSyncerBuilder) ConnectorOption
 	}
 }
 
+// WithGrantSources registers additional sources of grants, such as operators granting access outside RBAC.
+// The grants of each source are merged after those of the builders of the resource types it declares, and
+// dropped if they don't reference an entitlement the builder declares for the resource. See GrantSource.
+func WithGrantSources(sources ...GrantSource) ConnectorOption {
+	return func(opts *ConnectorOpts) error {
+		for _, source := range sources {
+			if source == nil {
+				return fmt.Errorf("grant source must not be nil")
+			}
+		}
+		opts.GrantSources = append(opts.GrantSources, sources...)
+		return nil
+	}
+}
+
+// WithLabelTags configures the connector to expose the values of the given label keys as resource tags.
+func WithLabelTags(keys []string) ConnectorOption {
+	return func(opts *ConnectorOpts) error {
+		for _, key := range keys {
+			if key == "" {
+				return fmt.Errorf("label tag key cannot be empty")
+			}
+		}
+		opts.LabelTags = keys
+		return nil
+	}
+}
+
+// WithRedactAnnotationKeys configures the connector to leave the given annotation keys out of resource profiles.
+func WithRedactAnnotationKeys(keys []string) ConnectorOption {
+	return func(opts *ConnectorOpts) error {
+		for _, key := range keys {
+			if key == "" {
+				return fmt.Errorf("redacted annotation key cannot be empty")
+			}
+		}
+		opts.RedactAnnotationKeys = keys
+		return nil
+	}
+}
+
+// WithSkipMissingNamedResources configures whether grants from rules with resourceNames are skipped when
+// the named object doesn't exist. By default they are emitted so the intent of the rule stays visible.
+func WithSkipMissingNamedResources(skip bool) ConnectorOption {
+	return func(opts *ConnectorOpts) error {
+		opts.SkipMissingNamedResources = skip
+		return nil
+	}
+}
+
+// WithIncludeSystemSubjects configures whether users and groups with "system:" names, such as system:masters,
+// are granted their roles. By default they are left out of the graph.
+func WithIncludeSystemSubjects(include bool) ConnectorOption {
+	return func(opts *ConnectorOpts) error {
+		opts.IncludeSystemSubjects = include
+		return nil
+	}
+}
+
+// WithSkipSystemClusterRoles configures whether ClusterRoles with "system:" names, which Kubernetes
+// components are bound to, are left out of the sync to cut noise.
+func WithSkipSystemClusterRoles(skip bool) ConnectorOption {
+	return func(opts *ConnectorOpts) error {
+		opts.SkipSystemClusterRoles = skip
+		return nil
+	}
+}
+
+// WithSeparateSystemUsers configures whether users with "system:" names, such as system:kube-scheduler or
+// system:node:<name>, are synced as the kube_system_user resource type, keeping component identities out of
+// the kube_user counts. They are flagged with the systemIdentity profile field either way.
+func WithSeparateSystemUsers(separate bool) ConnectorOption {
+	return func(opts *ConnectorOpts) error {
+		opts.SeparateSystemUsers = separate
+		return nil
+	}
+}
+
+// WithExpandServiceAccountGroups configures whether roles bound to the system:serviceaccounts and
+// system:serviceaccounts:<namespace> groups are also granted to every service account in the group. This can
+// produce many grants in large clusters.
+func WithExpandServiceAccountGroups(expand bool) ConnectorOption {
+	return func(opts *ConnectorOpts) error {
+		opts.ExpandServiceAccountGroups = expand
+		return nil
+	}
+}
+
+// WithSkipGrantPreCheck configures whether provisioning skips verifying that the connector may create the
+// binding, including under the RBAC escalation rules, before writing it. Skipping saves the access reviews at
+// the cost of raw Forbidden errors from the API server.
+func WithSkipGrantPreCheck(skip bool) ConnectorOption {
+	return func(opts *ConnectorOpts) error {
+		opts.SkipGrantPreCheck = skip
+		return nil
+	}
+}
+
+// WithSecretSensitivity configures whether secrets get a sensitivityTier profile field, critical, high or normal,
+// derived from how they are referenced. It lists the ingresses and pods of every namespace with secrets and the
+// webhook configurations of the cluster.
+func WithSecretSensitivity(enabled bool) ConnectorOption {
+	return func(opts *ConnectorOpts) error {
+		opts.SecretSensitivity = enabled
+		return nil
+	}
+}
+
+// WithClusterAdminsReport configures whether a report resource, cluster-admins, lists in its profile every
+// principal that is admin-equivalent cluster-wide: bound to cluster-admin or a ClusterRole granting every verb
+// on every resource, or able to escalate to it through bind, escalate or impersonate. The report duplicates
+// what the grants show, in one place.
+func WithClusterAdminsReport(enabled bool) ConnectorOption {
+	return func(opts *ConnectorOpts) error {
+		opts.ClusterAdminsReport = enabled
+		return nil
+	}
+}
+
+// WithInheritAggregatedBindings configures whether the ClusterRoles contributing their rules to an aggregated
+// ClusterRole, like admin, are granted the memberships of the aggregate: its subjects become members of the
+// contributing ClusterRole cluster-wide or in the namespaces the aggregate is bound in. The inherited grants
+// record the aggregate and can't be revoked. This adds a grant per contributing ClusterRole to each binding of
+// an aggregate, so it's off by default.
+func WithInheritAggregatedBindings(enabled bool) ConnectorOption {
+	return func(opts *ConnectorOpts) error {
+		opts.InheritAggregatedBindings = enabled
+		return nil
+	}
+}
+
+// WithRoleGrantableBy configures whether the profile of each Role lists, as grantableBy, the principals able to
+// give it to someone else: those bound to create or update RoleBindings in its namespace and to bind or escalate
+// it. It's computed once per sync from every Role, ClusterRole and binding.
+func WithRoleGrantableBy(enabled bool) ConnectorOption {
+	return func(opts *ConnectorOpts) error {
+		opts.RoleGrantableBy = enabled
+		return nil
+	}
+}
+
+// WithGroupMembershipFile configures a YAML or JSON file mapping Kubernetes group names to lists of usernames,
+// such as the groups of an OIDC provider, from which kube_group member grants are emitted. Kubernetes has no
+// group objects, so without it groups have no members. The file is re-read on each sync, and the users in it
+// are synced as kube_user resources even if no binding references them.
+func WithGroupMembershipFile(path string) ConnectorOption {
+	return func(opts *ConnectorOpts) error {
+		opts.GroupMembershipFile = path
+		return nil
+	}
+}
+
+// WithRancherProjects configures whether the Rancher projects the namespaces are grouped into, by their
+// field.cattle.io/projectId label, are synced as project resources whose member entitlement is granted to their
+// namespaces. When the cluster serves Rancher's ProjectRoleTemplateBindings, their subjects are granted it too.
+// Clusters without Rancher have no projects.
+func WithRancherProjects(enabled bool) ConnectorOption {
+	return func(opts *ConnectorOpts) error {
+		opts.RancherProjects = enabled
+		return nil
+	}
+}
+
+// WithAllowEmptySync configures whether a sync that finds no namespaces, or no roles and cluster roles,
+// succeeds. By default it fails, as this almost always points at missing permissions or a misconfigured filter.
+func WithAllowEmptySync(allow bool) ConnectorOption {
+	return func(opts *ConnectorOpts) error {
+		opts.AllowEmptySync = allow
+		return nil
+	}
+}
+
+// WithVerifyCoverage configures whether the connector verifies, once namespaces are synced, that it can
+// list secrets, roles and rolebindings in each of them, failing the sync with ErrPartialSync if it can't.
+func WithVerifyCoverage(verify bool) ConnectorOption {
+	return func(opts *ConnectorOpts) error {
+		opts.VerifyCoverage = verify
+		return nil
+	}
+}
+
+// WithAllowPartialSync configures whether validation passes with a warning annotation, rather than failing
+// with ErrForbidden, when the connector can't list some of the synced resources.
+func WithAllowPartialSync(allow bool) ConnectorOption {
+	return func(opts *ConnectorOpts) error {
+		opts.AllowPartialSync = allow
+		return nil
+	}
+}
+
+// WithNamespaceEntitlementSelector configures a label selector, such as tier=prod, limiting the namespaces
+// ClusterRoles get per-namespace entitlements in. It doesn't affect which namespaces are synced.
+func WithNamespaceEntitlementSelector(selector string) ConnectorOption {
+	return func(opts *ConnectorOpts) error {
+		parsed, err := labels.Parse(selector)
+		if err != nil {
+			return fmt.Errorf("invalid namespace entitlement selector %q: %w", selector, err)
+		}
+		opts.NamespaceEntitlementSelector = parsed
+		return nil
+	}
+}
+
+// WithDropUnselectedNamespaceGrants configures whether grants from bindings in namespaces not matching the
+// namespace entitlement selector are dropped rather than granted the catch-all other_namespaces:member entitlement.
+func WithDropUnselectedNamespaceGrants(drop bool) ConnectorOption {
+	return func(opts *ConnectorOpts) error {
+		opts.DropUnselectedNamespaceGrants = drop
+		return nil
+	}
+}
+
+// WithMountGrants configures whether the service accounts of pods mounting a Secret or ConfigMap, through a
+// volume, envFrom or env valueFrom, are granted get on it, as the pods can read it regardless of RBAC. The
+// Deployments, StatefulSets and DaemonSets whose pod template consumes a ConfigMap are also granted its
+// mounted_by entitlement. This lists the pods and workloads of every namespace with secrets or configmaps.
+func WithMountGrants(enabled bool) ConnectorOption {
+	return func(opts *ConnectorOpts) error {
+		opts.MountGrants = enabled
+		return nil
+	}
+}
+
+// WithCompactClusterRoleEntitlements configures whether each ClusterRole gets two membership entitlements,
+// all:member and namespaced_bindings:member, instead of one per namespace. Grants of namespaced_bindings:member
+// record the namespaces of the RoleBindings conferring them in their binding metadata. They can be revoked, but
+// namespaced_bindings:member can't be granted, since it names no namespace.
+func WithCompactClusterRoleEntitlements(enabled bool) ConnectorOption {
+	return func(opts *ConnectorOpts) error {
+		opts.CompactClusterRoleEntitlements = enabled
+		return nil
+	}
+}
+
+// WithSkipDefaultServiceAccounts configures whether the default ServiceAccount Kubernetes creates in every
+// namespace is left out of the sync, unless it has secrets or image pull secrets or a RoleBinding or
+// ClusterRoleBinding names it. Those left out hold no credentials or permissions of their own, so large clusters
+// avoid a row per namespace.
+func WithSkipDefaultServiceAccounts(skip bool) ConnectorOption {
+	return func(opts *ConnectorOpts) error {
+		opts.SkipDefaultServiceAccounts = skip
+		return nil
+	}
+}
+
+// WithNamespaceWildcards syncs a wildcard resource per namespace for each namespaced resource type, with the
+// ID <namespace>/* and the namespace as parent. The rules of Roles without resourceNames are granted on the
+// wildcard of their namespace rather than on the cluster-wide "*", which only ClusterRoles are then granted
+// on, so that access to every secret of one namespace isn't shown as access to every secret in the cluster.
+func WithNamespaceWildcards(enabled bool) ConnectorOption {
+	return func(opts *ConnectorOpts) error {
+		opts.NamespaceWildcards = enabled
+		return nil
+	}
+}
+
+// WithWildcardResources configures whether the builders sync the "All X" wildcard resources, including the
+// namespace wildcards, which they do by default. Without them, the grants of rules without resourceNames, which
+// the wildcards represent, are skipped.
+func WithWildcardResources(enabled bool) ConnectorOption {
+	return func(opts *ConnectorOpts) error {
+		opts.DisableWildcardResources = !enabled
+		return nil
+	}
+}
+
+// WithBindingsCacheDir persists the RoleBindings and ClusterRoleBindings in dir, so that a restarted connector
+// reuses them instead of listing every binding again when none changed since. Only the fields grants are built
+// from are written.
+func WithBindingsCacheDir(dir string) ConnectorOption {
+	return func(opts *ConnectorOpts) error {
+		if dir == "" {
+			return fmt.Errorf("bindings cache directory cannot be empty")
+		}
+		opts.BindingsCacheDir = dir
+		return nil
+	}
+}
+
+// WithKeepCachesAcrossSyncs configures whether the caches of the bindings and namespaces, and of the pods,
+// workloads and secret references computed from them, are kept across the syncs of a long-running connector. By
+// default they're reset when a new sync starts, so that bindings and namespaces created or deleted since the
+// previous sync are seen.
+func WithKeepCachesAcrossSyncs(enabled bool) ConnectorOption {
+	return func(opts *ConnectorOpts) error {
+		opts.KeepCachesAcrossSyncs = enabled
+		return nil
+	}
+}
+
+// WithRedactNames enables a privacy mode that deterministically pseudonymizes resource names using an HMAC
+// with the given key, leaving names starting with any of the preserved prefixes intact. Redacted syncs are
+// read-only.
+func WithRedactNames(key string, preservePrefixes []string) ConnectorOption {
+	return func(opts *ConnectorOpts) error {
+		if key == "" {
+			return fmt.Errorf("a key is required to redact names")
+		}
+		opts.Redact = &RedactOptions{
+			Key:              []byte(key),
+			PreservePrefixes: preservePrefixes,
+		}
+		return nil
+	}
+}
+
+// WithRemoteTokenSecret authenticates to the synced cluster with the token stored in the referenced
+// namespace/name[:key] Secret of the cluster the connector runs in. The token is re-read when the synced
+// cluster rejects it, so that rotated tokens are picked up.
+func WithRemoteTokenSecret(ref string) ConnectorOption {
+	return func(opts *ConnectorOpts) error {
+		secretRef, err := ParseSecretKeyRef(ref)
+		if err != nil {
+			return err
+		}
+		opts.RemoteTokenSecret = &secretRef
+		return nil
+	}
+}
+
+// WithClusterFingerprintDir records the fingerprint of the synced cluster, the UID of its kube-system namespace
+// and the API server host, in dir. Validation fails with ErrClusterChanged when a later run is configured for
+// another cluster, so that the data of two clusters isn't merged into one app.
+func WithClusterFingerprintDir(dir string) ConnectorOption {
+	return func(opts *ConnectorOpts) error {
+		if dir == "" {
+			return fmt.Errorf("cluster fingerprint directory cannot be empty")
+		}
+		opts.ClusterFingerprintDir = dir
+		return nil
+	}
+}
+
+// WithAcceptClusterChange accepts syncing another cluster than the recorded fingerprint, recording the new one.
+func WithAcceptClusterChange(accept bool) ConnectorOption {
+	return func(opts *ConnectorOpts) error {
+		opts.AcceptClusterChange = accept
+		return nil
+	}
+}
+
+// WithPodSampleRate syncs only the given fraction of the pods, e.g. 0.1 for one in ten, for clusters where the
+// full pod inventory isn't worth its cost. Pods are picked by the hash of their UID, so repeated syncs keep the
+// same pods.
+func WithPodSampleRate(rate float64) ConnectorOption {
+	return func(opts *ConnectorOpts) error {
+		if rate <= 0 || rate > 1 {
+			return fmt.Errorf("invalid pod sample rate %v, expected a fraction in (0, 1]", rate)
+		}
+		opts.PodSampleRate = rate
+		return nil
+	}
+}
+
+// WithGrantsPageSize sets the number of grants returned per page of the role and cluster role grants, which
+// bounds the responses for roles bound by thousands of subjects.
+func WithGrantsPageSize(size int) ConnectorOption {
+	return func(opts *ConnectorOpts) error {
+		if size <= 0 {
+			return fmt.Errorf("invalid grants page size %d, expected a positive integer", size)
+		}
+		opts.GrantsPageSize = size
+		return nil
+	}
+}
+
+// WithRequestTimeout sets the deadline of each call to the API server listing resources or loading the bindings
+// caches, so that a hung API server can't stall a sync. Zero uses the Timeout of the REST config.
+func WithRequestTimeout(timeout time.Duration) ConnectorOption {
+	return func(opts *ConnectorOpts) error {
+		if timeout < 0 {
+			return fmt.Errorf("invalid request timeout %s, expected a non-negative duration", timeout)
+		}
+		opts.RequestTimeout = timeout
+		return nil
+	}
+}
+
+// WithListRetries sets the number of times a List call failing with a transient API server error, such as a
+// timeout or throttling, is retried, backing off between attempts. Zero disables retries.
+func WithListRetries(retries int) ConnectorOption {
+	return func(opts *ConnectorOpts) error {
+		if retries < 0 {
+			return fmt.Errorf("invalid list retries %d, expected a non-negative integer", retries)
+		}
+		opts.ListRetries = retries
+		return nil
+	}
+}
+
+// WithProfileRulesLimit sets the maximum number of PolicyRules listed in the profile of a Role or ClusterRole.
+// Roles with more rules list the first ones and are flagged with rulesTruncated.
+func WithProfileRulesLimit(limit int) ConnectorOption {
+	return func(opts *ConnectorOpts) error {
+		if limit <= 0 {
+			return fmt.Errorf("invalid profile rules limit %d, expected a positive integer", limit)
+		}
+		opts.ProfileRulesLimit = limit
+		return nil
+	}
+}
+
+// pageSizeResourceTypes are the resource types listed a page at a time from the Kubernetes API, whose page
+// size can be overridden.
+var pageSizeResourceTypes = []*v2.ResourceType{
+	ResourceTypeNamespace,
+	ResourceTypeServiceAccount,
+	ResourceTypeRole,
+	ResourceTypeClusterRole,
+	ResourceTypeSecret,
+	ResourceTypeConfigMap,
+	ResourceTypeService,
+	ResourceTypeNode,
+	ResourceTypePod,
+	ResourceTypeDeployment,
+	ResourceTypeStatefulSet,
+	ResourceTypeDaemonSet,
+	ResourceTypeReplicaSet,
+}
+
+// WithPageSizes overrides the page size of the listings of resource types, given as <resource type>=<size>,
+// e.g. pod=2000 or secret=100. Other resource types are listed ResourcesPageSize objects at a time.
+func WithPageSizes(overrides []string) ConnectorOption {
+	return func(opts *ConnectorOpts) error {
+		sizes := make(map[string]int64, len(overrides))
+		for _, override := range overrides {
+			resourceTypeID, value, ok := strings.Cut(override, "=")
+			if !ok {
+				return fmt.Errorf("invalid page size %q, expected <resource type>=<size>", override)
+			}
+			known := false
+			var ids []string
+			for _, rt := range pageSizeResourceTypes {
+				known = known || rt.Id == resourceTypeID
+				ids = append(ids, rt.Id)
+			}
+			if !known {
+				return fmt.Errorf("invalid page size %q: unknown resource type %q, expected one of %s",
+					override, resourceTypeID, strings.Join(ids, ", "))
+			}
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || size <= 0 {
+				return fmt.Errorf("invalid page size %q: size must be a positive integer", override)
+			}
+			sizes[resourceTypeID] = size
+		}
+		opts.PageSizes = sizes
+		return nil
+	}
+}
+
+// pageSize returns the page size of the listings of a resource type.
+func (o ConnectorOpts) pageSize(resourceTypeID string) int64 {
+	if size, ok := o.PageSizes[resourceTypeID]; ok {
+		return size
+	}
+	return ResourcesPageSize
+}
+
+// requestContext returns the context of a call to the API server, bounded by RequestTimeout if set. The
+// returned cancel func must be called once the call returns.
+func (o ConnectorOpts) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if o.RequestTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, o.RequestTimeout)
+}
+
+// grantsPageSize returns the number of grants per page of the role and cluster role grants.
+func (o ConnectorOpts) grantsPageSize() int {
+	if o.GrantsPageSize > 0 {
+		return o.GrantsPageSize
+	}
+	return GrantsPageSize
+}
+
+// profileRulesLimit returns the maximum number of PolicyRules listed in the profile of a role.
+func (o ConnectorOpts) profileRulesLimit() int {
+	if o.ProfileRulesLimit > 0 {
+		return o.ProfileRulesLimit
+	}
+	return ProfileRulesLimit
+}
+
+// namespaceWildcards reports whether the builders sync a wildcard resource per namespace.
+func (o ConnectorOpts) namespaceWildcards() bool {
+	return o.NamespaceWildcards && !o.DisableWildcardResources
+}
+
+// listedAsNamespaceChildren reports whether the objects of a namespaced resource type are listed as the children
+// of the namespaces, which they are when both are synced, rather than at the top level.
+func (o ConnectorOpts) listedAsNamespaceChildren(resourceTypeID string) bool {
+	return o.syncsResourceType(ResourceTypeNamespace.Id) && o.syncsResourceType(resourceTypeID) &&
+		slices.ContainsFunc(namespaceChildResourceTypes, func(rt *v2.ResourceType) bool { return rt.Id == resourceTypeID })
+}
+
+// syncsResourceType reports whether the connector is configured to sync the given resource type.
+func (o ConnectorOpts) syncsResourceType(resourceTypeID string) bool {
+	if len(o.SyncResources) == 0 {
+		return true
+	}
+	for _, id := range o.SyncResources {
+		if id == resourceTypeID {
+			return true
+		}
+	}
+	return false
+}
+
 // Kubernetes connector struct.
 type Kubernetes struct {
 	client kubernetes.Interface
@@ -83,6 +697,59 @@ type Kubernetes struct {
 	clusterRoleBindingsCache []rbacv1.ClusterRoleBinding
 	bindingsMutex            sync.RWMutex
 	bindingsLoaded           bool
+	danglingBindings         []DanglingBinding
+	bindingsDiskCache        *bindingsDiskCache
+
+	// Identifier of the synced cluster, read once from the kube-system namespace
+	clusterID      string
+	clusterIDMutex sync.Mutex
+
+	// Shared pods cache, keyed by namespace
+	podsCache map[string][]corev1.Pod
+	podsMutex sync.Mutex
+
+	// Shared workload pod templates cache, keyed by namespace
+	workloadsCache map[string][]WorkloadPodTemplate
+	workloadsMutex sync.Mutex
+
+	// Shared secret references caches, keyed by namespace
+	secretRefsCache       map[string]map[string][]string
+	webhookCASecretsCache map[string][]string
+	secretRefsMutex       sync.Mutex
+
+	// Counters describing the sync
+	stats *syncStats
+
+	// Reports the progress of long cache loads
+	progress *progressReporter
+
+	// Pseudonymizes names when the privacy mode is enabled
+	redactor *nameRedactor
+
+	// Verifies read access to the synced namespaces when enabled
+	coverage *coverageVerifier
+
+	// Fails syncs that find nothing unless empty syncs are allowed
+	emptySyncGuard *emptySyncGuard
+
+	// Namespaces ClusterRoles can be bound in, listed once per sync
+	namespaces *namespaceSnapshot
+
+	// ClusterRoles the aggregation rules are resolved against, listed once per sync
+	clusterRoles *clusterRoleCache
+
+	// Reads the token of the synced cluster from a local Secret when configured
+	remoteToken *secretTokenSource
+
+	// Principals able to grant each Role, computed once when enabled
+	grantors      *roleGrantorIndex
+	grantorsMutex sync.Mutex
+
+	// Members of the Kubernetes groups, read from the group membership file when configured
+	groupMembership *groupMembership
+
+	// Lists custom resources, like Rancher's, when the options need it and there's a REST config
+	dynamic dynamic.Interface
 }
 
 // New creates a new Kubernetes connector.
@@ -92,14 +759,28 @@ func New(ctx context.Context, cfg *rest.Config, opts ...ConnectorOption) (*Kuber
 		return nil, fmt.Errorf("kubernetes REST config cannot be nil")
 	}
 
-	options := ConnectorOpts{}
+	options, err := applyOptions(opts)
+	if err != nil {
+		return nil, err
+	}
 
-	// Apply option functions
-	for _, opt := range opts {
-		err := opt(&options)
+	// Authenticate with the token from the local Secret instead of the configured credentials
+	var remoteToken *secretTokenSource
+	if options.RemoteTokenSecret != nil {
+		localCfg, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, fmt.Errorf("creating in-cluster config to read the remote token secret: %w", err)
+		}
+		localClient, err := kubernetes.NewForConfig(localCfg)
 		if err != nil {
-			return nil, fmt.Errorf("applying option: %w", err)
+			return nil, fmt.Errorf("creating in-cluster kubernetes client: %w", err)
 		}
+		remoteToken = newSecretTokenSource(localClient, *options.RemoteTokenSecret)
+
+		cfg = rest.CopyConfig(cfg)
+		cfg.BearerToken = ""
+		cfg.BearerTokenFile = ""
+		cfg.Wrap(remoteToken.wrapTransport)
 	}
 
 	// Create kubernetes client
@@ -108,13 +789,89 @@ func New(ctx context.Context, cfg *rest.Config, opts ...ConnectorOption) (*Kuber
 		return nil, fmt.Errorf("creating kubernetes client: %w", err)
 	}
 
-	return &Kubernetes{
+	k := newKubernetes(client, cfg, options)
+	k.remoteToken = remoteToken
+	if options.RancherProjects {
+		k.dynamic, err = dynamic.NewForConfig(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("creating dynamic kubernetes client: %w", err)
+		}
+	}
+	return k, nil
+}
+
+// NewForClient creates a new Kubernetes connector using an existing client, e.g. a fake clientset in tests.
+// Options that need a REST config, like the remote token secret, are not supported.
+func NewForClient(client kubernetes.Interface, opts ...ConnectorOption) (*Kubernetes, error) {
+	if client == nil {
+		return nil, fmt.Errorf("kubernetes client cannot be nil")
+	}
+	options, err := applyOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	if options.RemoteTokenSecret != nil {
+		return nil, fmt.Errorf("the remote token secret is not supported with an existing client")
+	}
+	return newKubernetes(client, nil, options), nil
+}
+
+// applyOptions returns the connector options set by the option functions.
+func applyOptions(opts []ConnectorOption) (ConnectorOpts, error) {
+	options := ConnectorOpts{ListRetries: DefaultListRetries}
+	for _, opt := range opts {
+		if err := opt(&options); err != nil {
+			return ConnectorOpts{}, fmt.Errorf("applying option: %w", err)
+		}
+	}
+	return options, nil
+}
+
+// newKubernetes creates the connector around a client. cfg is the REST config of the client, if any.
+func newKubernetes(client kubernetes.Interface, cfg *rest.Config, options ConnectorOpts) *Kubernetes {
+	if options.RequestTimeout == 0 && cfg != nil {
+		options.RequestTimeout = cfg.Timeout
+	}
+	k := &Kubernetes{
 		client:                   client,
 		config:                   cfg,
 		opts:                     options,
 		roleBindingsCache:        make([]rbacv1.RoleBinding, 0),
 		clusterRoleBindingsCache: make([]rbacv1.ClusterRoleBinding, 0),
-	}, nil
+		stats:                    newSyncStats(),
+		progress:                 newProgressReporter(),
+		groupMembership:          newGroupMembership(options.GroupMembershipFile),
+	}
+	if options.Redact != nil {
+		k.redactor = newNameRedactor(options.Redact)
+	}
+	if options.VerifyCoverage {
+		k.coverage = newCoverageVerifier(client, k.stats)
+	}
+	if options.BindingsCacheDir != "" {
+		host := ""
+		if cfg != nil {
+			host = cfg.Host
+		}
+		k.bindingsDiskCache = newBindingsDiskCache(options.BindingsCacheDir, host)
+	}
+	if !options.AllowEmptySync {
+		k.emptySyncGuard = newEmptySyncGuard(options, k.stats)
+	}
+	k.namespaces = newNamespaceSnapshot(client, options, k.progress)
+	k.clusterRoles = newClusterRoleCache(client, options)
+	return k
+}
+
+// SyncStats returns a snapshot of the counters collected while syncing, since the start of the current sync.
+func (k *Kubernetes) SyncStats() map[string]int64 {
+	return k.stats.Snapshot()
+}
+
+// CoverageGaps returns the synced namespaces in which the connector couldn't list all key resources. It is
+// empty unless coverage verification is enabled.
+func (k *Kubernetes) CoverageGaps() []CoverageGap {
+	return k.coverage.Gaps()
 }
 
 // ResourceSyncers returns the resource syncers for the Kubernetes connector.
@@ -122,45 +879,83 @@ func (k *Kubernetes) ResourceSyncers(ctx context.Context) []connectorbuilder.Res
 	// Map resource type IDs to their builder functions
 	builders := map[string]ResourceSyncerBuilder{
 		ResourceTypeNamespace.Id: func(i *kubernetes.Interface, k *Kubernetes) connectorbuilder.ResourceSyncer {
-			return newNamespaceBuilder(k.client)
+			return newNamespaceBuilder(k.client, k, k.opts, k.coverage)
 		},
 		ResourceTypeServiceAccount.Id: func(i *kubernetes.Interface, k *Kubernetes) connectorbuilder.ResourceSyncer {
-			return newServiceAccountBuilder(k.client)
+			builder := newServiceAccountBuilder(k.client, k.opts)
+			if k.opts.SkipDefaultServiceAccounts {
+				builder.bindings = k
+				builder.stats = k.stats
+			}
+			return builder
 		},
 		ResourceTypeRole.Id: func(i *kubernetes.Interface, k *Kubernetes) connectorbuilder.ResourceSyncer {
-			return newRoleBuilder(k.client, k)
+			builder := newRoleBuilder(k.client, k, k.opts, k.stats)
+			if k.opts.RoleGrantableBy {
+				builder.grantorProvider = k
+			}
+			return builder
 		},
 		ResourceTypeClusterRole.Id: func(i *kubernetes.Interface, k *Kubernetes) connectorbuilder.ResourceSyncer {
-			return newClusterRoleBuilder(k.client, k)
+			builder := newClusterRoleBuilder(k.client, k, k.opts, k.stats)
+			builder.progress = k.progress
+			builder.namespaces = k.namespaces
+			builder.clusterRoles = k.clusterRoles
+			return builder
 		},
 		ResourceTypeSecret.Id: func(i *kubernetes.Interface, k *Kubernetes) connectorbuilder.ResourceSyncer {
-			return newSecretBuilder(k.client)
+			return newSecretBuilder(k.client, k, k, k.opts)
 		},
 		ResourceTypeConfigMap.Id: func(i *kubernetes.Interface, k *Kubernetes) connectorbuilder.ResourceSyncer {
-			return newConfigMapBuilder(k.client)
+			return newConfigMapBuilder(k.client, k, k, k.opts)
+		},
+		ResourceTypeService.Id: func(i *kubernetes.Interface, k *Kubernetes) connectorbuilder.ResourceSyncer {
+			return newServiceBuilder(k.client, k.opts)
 		},
 		ResourceTypeNode.Id: func(i *kubernetes.Interface, k *Kubernetes) connectorbuilder.ResourceSyncer {
-			return newNodeBuilder(k.client)
+			return newNodeBuilder(k.client, k.opts)
 		},
 		ResourceTypeDeployment.Id: func(i *kubernetes.Interface, k *Kubernetes) connectorbuilder.ResourceSyncer {
-			return newDeploymentBuilder(k.client)
+			return newDeploymentBuilder(k.client, k.opts)
 		},
 		ResourceTypeStatefulSet.Id: func(i *kubernetes.Interface, k *Kubernetes) connectorbuilder.ResourceSyncer {
-			return newStatefulSetBuilder(k.client)
+			return newStatefulSetBuilder(k.client, k.opts)
 		},
 		ResourceTypeDaemonSet.Id: func(i *kubernetes.Interface, k *Kubernetes) connectorbuilder.ResourceSyncer {
-			return newDaemonSetBuilder(k.client)
+			return newDaemonSetBuilder(k.client, k.opts)
+		},
+		ResourceTypeReplicaSet.Id: func(i *kubernetes.Interface, k *Kubernetes) connectorbuilder.ResourceSyncer {
+			return newReplicaSetBuilder(k.client, k.opts)
 		},
 		ResourceTypePod.Id: func(i *kubernetes.Interface, k *Kubernetes) connectorbuilder.ResourceSyncer {
-			return newPodBuilder(k.client)
+			return newPodBuilder(k.client, k.opts)
+		},
+		ResourceTypeCluster.Id: func(i *kubernetes.Interface, k *Kubernetes) connectorbuilder.ResourceSyncer {
+			return newClusterBuilder(k.client, k.opts)
 		},
 		ResourceTypeKubeUser.Id: func(i *kubernetes.Interface, k *Kubernetes) connectorbuilder.ResourceSyncer {
-			return newKubeUserBuilder(k.client)
+			builder := newKubeUserBuilder(k.client, k, k.opts)
+			builder.membership = k.groupMembership
+			return builder
 		},
 		ResourceTypeKubeGroup.Id: func(i *kubernetes.Interface, k *Kubernetes) connectorbuilder.ResourceSyncer {
-			return newKubeGroupBuilder(k.client)
+			builder := newKubeGroupBuilder(k.client, k, k.opts)
+			builder.membership = k.groupMembership
+			return builder
 		},
 	}
+	if k.opts.RancherProjects {
+		builders[ResourceTypeProject.Id] = func(i *kubernetes.Interface, k *Kubernetes) connectorbuilder.ResourceSyncer {
+			return newProjectBuilder(k.client, k.dynamic, k.opts)
+		}
+	}
+	if k.opts.SeparateSystemUsers {
+		builders[ResourceTypeKubeSystemUser.Id] = func(i *kubernetes.Interface, k *Kubernetes) connectorbuilder.ResourceSyncer {
+			builder := newKubeSystemUserBuilder(k.client, k, k.opts)
+			builder.membership = k.groupMembership
+			return builder
+		}
+	}
 
 	var syncers []connectorbuilder.ResourceSyncer
 
@@ -173,21 +968,42 @@ func (k *Kubernetes) ResourceSyncers(ctx context.Context) []connectorbuilder.Res
 		}
 	}
 
-	// If SyncResources is empty, sync everything
+	// If SyncResources is empty, sync everything, otherwise only the requested resources
 	if len(k.opts.SyncResources) == 0 {
 		for _, builder := range builders {
 			syncers = append(syncers, builder(&k.client, k))
 		}
-		return syncers
+	} else {
+		for _, id := range k.opts.SyncResources {
+			if builder, ok := builders[id]; ok {
+				syncers = append(syncers, builder(&k.client, k))
+			}
+		}
 	}
 
-	// Otherwise, only sync the requested resources
-	for _, id := range k.opts.SyncResources {
-		if builder, ok := builders[id]; ok {
-			syncers = append(syncers, builder(&k.client, k))
-		}
+	// The report comes last, so that the SDK lists it once the other syncers loaded the bindings caches
+	if k.opts.ClusterAdminsReport {
+		syncers = append(syncers, newClusterAdminsReportBuilder(k.client, k, k.opts))
+	}
+
+	return k.wrapSyncers(ctx, syncers)
+}
+
+// wrapSyncers adds the grants of the grant sources to the syncers, applies the transforms enabled by the
+// connector options, and classifies the errors they return.
+func (k *Kubernetes) wrapSyncers(ctx context.Context, syncers []connectorbuilder.ResourceSyncer) []connectorbuilder.ResourceSyncer {
+	transforms := []syncTransform{newProfileRepairCounter(k.stats)}
+	if !k.opts.AllowEmptySync {
+		transforms = append(transforms, k.emptySyncGuard)
+	}
+	if k.redactor != nil {
+		transforms = append(transforms, k.redactor)
 	}
 
+	for i, syncer := range syncers {
+		syncer = withGrantSources(syncer, grantSourcesFor(k.opts.GrantSources, syncer.ResourceType(ctx).GetId()))
+		syncers[i] = wrapSyncer(syncer, transforms...)
+	}
 	return syncers
 }
 
@@ -201,21 +1017,37 @@ func (k *Kubernetes) Metadata(ctx context.Context) (*v2.ConnectorMetadata, error
 
 // Validate validates the connector configuration.
 func (k *Kubernetes) Validate(ctx context.Context) (annotations.Annotations, error) {
+	// The SDK validates the connector before each sync
+	k.startSync(ctx)
+
+	// Read the remote token up front, so that a missing Secret or key is reported as such
+	if k.remoteToken != nil {
+		if _, err := k.remoteToken.refresh(ctx); err != nil {
+			return nil, fmt.Errorf("validating remote token secret: %w", err)
+		}
+	}
+
 	// Try to list namespaces as a simple connectivity test
 	_, err := k.client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{Limit: 1})
 	if err != nil {
 		// Check for different types of errors to provide better messages
 		switch {
 		case k8serrors.IsUnauthorized(err):
-			return nil, fmt.Errorf("unauthorized access to Kubernetes API: %w", err)
+			return nil, fmt.Errorf("%w: %w", ErrUnauthorized, err)
 		case k8serrors.IsForbidden(err):
-			return nil, fmt.Errorf("forbidden access to Kubernetes API (check RBAC permissions): %w", err)
+			return nil, fmt.Errorf("%w: %w", ErrForbidden, err)
 		default:
 			return nil, fmt.Errorf("validating kubernetes connection: %w", err)
 		}
 	}
 
-	return nil, nil
+	// Refuse to sync another cluster than previous runs did
+	if err := k.checkClusterFingerprint(ctx); err != nil {
+		return nil, fmt.Errorf("validating cluster fingerprint: %w", err)
+	}
+
+	// Report every resource the synced types can't be listed from, rather than failing on the first mid-sync
+	return k.checkListPermissions(ctx)
 }
 
 // loadBindingsCaches ensures that both binding caches are loaded
@@ -238,11 +1070,27 @@ func (k *Kubernetes) loadBindingsCaches(ctx context.Context) error {
 	}
 
 	l := ctxzap.Extract(ctx)
+
+	// Reuse the bindings persisted by a previous run if none changed since
+	if k.bindingsDiskCache != nil {
+		if cached, ok := k.bindingsDiskCache.loadFresh(ctx, k.client, k.opts); ok {
+			roleBindings, clusterRoleBindings := normalizeBindings(ctx, cached.RoleBindings, cached.ClusterRoleBindings, k.stats)
+			k.checkDanglingBindings(ctx, roleBindings, clusterRoleBindings)
+			k.roleBindingsCache = roleBindings
+			k.clusterRoleBindingsCache = clusterRoleBindings
+			k.bindingsLoaded = true
+			return nil
+		}
+	}
+
 	l.Debug("loading role bindings and cluster role bindings caches")
 
-	// Fetch all RoleBindings across all namespaces
+	// Fetch all RoleBindings across all namespaces. The pages of a list are a snapshot at the resourceVersion
+	// of the first page.
 	var allRoleBindings []rbacv1.RoleBinding
+	var roleBindingsRV, clusterRoleBindingsRV string
 	continueToken := ""
+	load := k.progress.start(progressCacheRoleBindings)
 
 	for {
 		opts := metav1.ListOptions{
@@ -250,12 +1098,18 @@ func (k *Kubernetes) loadBindingsCaches(ctx context.Context) error {
 			Continue: continueToken,
 		}
 
-		bindings, err := k.client.RbacV1().RoleBindings("").List(ctx, opts)
+		bindings, err := listWithRetry(ctx, k.opts, func(ctx context.Context) (*rbacv1.RoleBindingList, error) {
+			return k.client.RbacV1().RoleBindings("").List(ctx, opts)
+		})
 		if err != nil {
 			return fmt.Errorf("listing role bindings: %w", err)
 		}
 
+		if continueToken == "" {
+			roleBindingsRV = bindings.ResourceVersion
+		}
 		allRoleBindings = append(allRoleBindings, bindings.Items...)
+		load.page(ctx, len(bindings.Items))
 
 		// If no continue token, we're done
 		if bindings.Continue == "" {
@@ -269,6 +1123,7 @@ func (k *Kubernetes) loadBindingsCaches(ctx context.Context) error {
 	// Fetch all ClusterRoleBindings
 	var allClusterRoleBindings []rbacv1.ClusterRoleBinding
 	continueToken = ""
+	load = k.progress.start(progressCacheClusterRoleBindings)
 
 	for {
 		opts := metav1.ListOptions{
@@ -276,12 +1131,18 @@ func (k *Kubernetes) loadBindingsCaches(ctx context.Context) error {
 			Continue: continueToken,
 		}
 
-		bindings, err := k.client.RbacV1().ClusterRoleBindings().List(ctx, opts)
+		bindings, err := listWithRetry(ctx, k.opts, func(ctx context.Context) (*rbacv1.ClusterRoleBindingList, error) {
+			return k.client.RbacV1().ClusterRoleBindings().List(ctx, opts)
+		})
 		if err != nil {
 			return fmt.Errorf("listing cluster role bindings: %w", err)
 		}
 
+		if continueToken == "" {
+			clusterRoleBindingsRV = bindings.ResourceVersion
+		}
 		allClusterRoleBindings = append(allClusterRoleBindings, bindings.Items...)
+		load.page(ctx, len(bindings.Items))
 
 		// If no continue token, we're done
 		if bindings.Continue == "" {
@@ -292,12 +1153,24 @@ func (k *Kubernetes) loadBindingsCaches(ctx context.Context) error {
 		continueToken = bindings.Continue
 	}
 
-	k.roleBindingsCache = allRoleBindings
-	k.clusterRoleBindingsCache = allClusterRoleBindings
+	// Bindings whose RoleRef the API server can't resolve grant nothing
+	roleBindings, clusterRoleBindings := normalizeBindings(ctx, allRoleBindings, allClusterRoleBindings, k.stats)
+	k.checkDanglingBindings(ctx, roleBindings, clusterRoleBindings)
+
+	k.roleBindingsCache = roleBindings
+	k.clusterRoleBindingsCache = clusterRoleBindings
 	k.bindingsLoaded = true
 	l.Debug("bindings caches loaded",
-		zap.Int("roleBindings", len(allRoleBindings)),
-		zap.Int("clusterRoleBindings", len(allClusterRoleBindings)))
+		zap.Int("roleBindings", len(roleBindings)),
+		zap.Int("clusterRoleBindings", len(clusterRoleBindings)))
+
+	// Failing to persist the bindings only costs the next run a full load
+	if k.bindingsDiskCache != nil {
+		err := k.bindingsDiskCache.save(allRoleBindings, roleBindingsRV, allClusterRoleBindings, clusterRoleBindingsRV)
+		if err != nil {
+			l.Warn("failed to persist the bindings cache", zap.Error(err))
+		}
+	}
 
 	return nil
 }
@@ -315,7 +1188,7 @@ func (k *Kubernetes) GetMatchingRoleBindings(ctx context.Context, namespace, rol
 
 	var result []rbacv1.RoleBinding
 	for _, binding := range k.roleBindingsCache {
-		if binding.Namespace == namespace && binding.RoleRef.Kind == "Role" && binding.RoleRef.Name == roleName {
+		if binding.Namespace == namespace && roleRefMatches(binding.RoleRef, RoleRefKindRole, roleName) {
 			result = append(result, binding)
 		}
 	}
@@ -323,6 +1196,19 @@ func (k *Kubernetes) GetMatchingRoleBindings(ctx context.Context, namespace, rol
 	return result, nil
 }
 
+// GetClusterRoleBindings returns all ClusterRoleBindings.
+func (k *Kubernetes) GetClusterRoleBindings(ctx context.Context) ([]rbacv1.ClusterRoleBinding, error) {
+	// Ensure bindings cache is loaded
+	if err := k.loadBindingsCaches(ctx); err != nil {
+		return nil, fmt.Errorf("failed to load bindings cache: %w", err)
+	}
+
+	k.bindingsMutex.RLock()
+	defer k.bindingsMutex.RUnlock()
+
+	return append([]rbacv1.ClusterRoleBinding(nil), k.clusterRoleBindingsCache...), nil
+}
+
 // GetMatchingBindingsForClusterRole returns all RoleBindings and ClusterRoleBindings that reference the specified ClusterRole.
 func (k *Kubernetes) GetMatchingBindingsForClusterRole(ctx context.Context, clusterRoleName string) ([]rbacv1.RoleBinding, []rbacv1.ClusterRoleBinding, error) {
 	// Ensure bindings cache is loaded
@@ -336,14 +1222,14 @@ func (k *Kubernetes) GetMatchingBindingsForClusterRole(ctx context.Context, clus
 
 	var roleBindings []rbacv1.RoleBinding
 	for _, binding := range k.roleBindingsCache {
-		if binding.RoleRef.Kind == "ClusterRole" && binding.RoleRef.Name == clusterRoleName {
+		if roleRefMatches(binding.RoleRef, RoleRefKindClusterRole, clusterRoleName) {
 			roleBindings = append(roleBindings, binding)
 		}
 	}
 
 	var clusterRoleBindings []rbacv1.ClusterRoleBinding
 	for _, binding := range k.clusterRoleBindingsCache {
-		if binding.RoleRef.Kind == "ClusterRole" && binding.RoleRef.Name == clusterRoleName {
+		if roleRefMatches(binding.RoleRef, RoleRefKindClusterRole, clusterRoleName) {
 			clusterRoleBindings = append(clusterRoleBindings, binding)
 		}
 	}
@@ -0,0 +1,294 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// ErrContractViolation is returned when the output of the syncers breaks the invariants of baton-sdk.
+var ErrContractViolation = errors.New("sync output violates the baton-sdk contract")
+
+// traitAnnotations are the annotations carrying each resource type trait.
+var traitAnnotations = []struct {
+	trait   v2.ResourceType_Trait
+	message proto.Message
+}{
+	{v2.ResourceType_TRAIT_USER, &v2.UserTrait{}},
+	{v2.ResourceType_TRAIT_GROUP, &v2.GroupTrait{}},
+	{v2.ResourceType_TRAIT_ROLE, &v2.RoleTrait{}},
+	{v2.ResourceType_TRAIT_APP, &v2.AppTrait{}},
+	{v2.ResourceType_TRAIT_SECRET, &v2.SecretTrait{}},
+}
+
+// ContractViolation is a resource, entitlement or grant breaking an invariant of baton-sdk.
+type ContractViolation struct {
+	// ResourceType is the ID of the resource type of the syncer that returned the object.
+	ResourceType string `json:"resourceType"`
+	// Object identifies the object, e.g. grant <grant ID>.
+	Object  string `json:"object"`
+	Message string `json:"message"`
+}
+
+// String describes the violation for logs and test failures.
+func (v ContractViolation) String() string {
+	return fmt.Sprintf("%s syncer: %s: %s", v.ResourceType, v.Object, v.Message)
+}
+
+// ContractReport is the outcome of ValidateSyncOutput: how many objects were checked and the violations found.
+type ContractReport struct {
+	Resources    int                 `json:"resources"`
+	Entitlements int                 `json:"entitlements"`
+	Grants       int                 `json:"grants"`
+	Violations   []ContractViolation `json:"violations,omitempty"`
+}
+
+// Err returns an ErrContractViolation error describing the first violation, or nil if there are none.
+func (r *ContractReport) Err() error {
+	if len(r.Violations) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %d violations, first %s", ErrContractViolation, len(r.Violations), r.Violations[0])
+}
+
+// Write prints the report in a human-readable form.
+func (r *ContractReport) Write(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Checked %d resources, %d entitlements and %d grants\n", r.Resources, r.Entitlements, r.Grants)
+	if len(r.Violations) == 0 {
+		b.WriteString("No contract violations\n")
+	}
+	for _, v := range r.Violations {
+		fmt.Fprintf(&b, "  %s\n", v)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// contractCheck accumulates the violations of a sync.
+type contractCheck struct {
+	report       *ContractReport
+	resourceType string
+	// entitlements are the entitlements the syncers returned, by ID, to check the principals of grants against.
+	entitlements map[string]*v2.Entitlement
+}
+
+// violation records a violation of the object.
+func (c *contractCheck) violation(object, format string, args ...interface{}) {
+	c.report.Violations = append(c.report.Violations, ContractViolation{
+		ResourceType: c.resourceType,
+		Object:       object,
+		Message:      fmt.Sprintf(format, args...),
+	})
+}
+
+// ValidateSyncOutput runs a full sync with the syncers, listing every page of their resources, of the children of
+// the resources and of the entitlements and grants of each, and checks the output against the invariants baton-sdk relies on:
+//   - resources, entitlements and grants have IDs, and principals have typed IDs
+//   - resources are of the type of their syncer and carry the trait annotations of the type, and no others
+//   - entitlements belong to the resource they're listed for
+//   - grant principals are of a type the entitlement is grantable to, for the entitlements the sync returned
+//   - annotations unmarshal into registered message types
+//
+// Errors of the syncers abort the sync and are returned, violations are collected in the report.
+func ValidateSyncOutput(ctx context.Context, syncers []connectorbuilder.ResourceSyncer) (*ContractReport, error) {
+	c := &contractCheck{
+		report:       &ContractReport{},
+		entitlements: make(map[string]*v2.Entitlement),
+	}
+
+	type syncedResource struct {
+		syncer   connectorbuilder.ResourceSyncer
+		resource *v2.Resource
+	}
+	type listing struct {
+		syncer connectorbuilder.ResourceSyncer
+		parent *v2.ResourceId
+	}
+	syncersByType := make(map[string]connectorbuilder.ResourceSyncer)
+	var listings []listing
+	for _, syncer := range syncers {
+		syncersByType[syncer.ResourceType(ctx).GetId()] = syncer
+		listings = append(listings, listing{syncer: syncer})
+	}
+
+	// The children of the resources are listed after the top-level listings, like the SDK does
+	var synced []syncedResource
+	for len(listings) > 0 {
+		syncer, parent := listings[0].syncer, listings[0].parent
+		listings = listings[1:]
+		resourceType := syncer.ResourceType(ctx)
+		c.resourceType = resourceType.GetId()
+		resources, err := listAllResources(ctx, syncer, parent)
+		if err != nil {
+			return nil, err
+		}
+		for _, resource := range resources {
+			for _, a := range resource.GetAnnotations() {
+				childType := &v2.ChildResourceType{}
+				if !a.MessageIs(childType) || a.UnmarshalTo(childType) != nil {
+					continue
+				}
+				if child, ok := syncersByType[childType.GetResourceTypeId()]; ok {
+					listings = append(listings, listing{syncer: child, parent: resource.GetId()})
+				}
+			}
+
+			c.report.Resources++
+			if !c.checkResource(resourceType, resource) {
+				continue
+			}
+			synced = append(synced, syncedResource{syncer: syncer, resource: resource})
+
+			entitlements, err := listAllEntitlements(ctx, syncer, resource)
+			if err != nil {
+				return nil, err
+			}
+			for _, ent := range entitlements {
+				c.report.Entitlements++
+				c.checkEntitlement(resource, ent)
+			}
+		}
+	}
+
+	// Grants are checked once every entitlement is known, as roles grant the entitlements of other resources
+	for _, s := range synced {
+		c.resourceType = s.syncer.ResourceType(ctx).GetId()
+		grants, err := listAllGrants(ctx, s.syncer, s.resource)
+		if err != nil {
+			return nil, err
+		}
+		for _, g := range grants {
+			c.report.Grants++
+			c.checkGrant(g)
+		}
+	}
+	return c.report, nil
+}
+
+// checkResource checks a resource returned by the syncer of the resource type, reporting whether it has an ID
+// its entitlements and grants can be listed for.
+func (c *contractCheck) checkResource(resourceType *v2.ResourceType, resource *v2.Resource) bool {
+	id := resource.GetId()
+	object := "resource " + resourceIDKey(id)
+	if id.GetResourceType() == "" || id.GetResource() == "" {
+		c.violation(object, "resource ID is missing its resource type or resource")
+		return false
+	}
+	if id.GetResourceType() != resourceType.GetId() {
+		c.violation(object, "resource of type %s returned by the %s syncer", id.GetResourceType(), resourceType.GetId())
+	}
+	if parent := resource.GetParentResourceId(); parent != nil && (parent.GetResourceType() == "" || parent.GetResource() == "") {
+		c.violation(object, "parent resource ID is missing its resource type or resource")
+	}
+
+	for _, t := range traitAnnotations {
+		declared := slices.Contains(resourceType.GetTraits(), t.trait)
+		has := slices.ContainsFunc(resource.GetAnnotations(), func(a *anypb.Any) bool { return a.MessageIs(t.message) })
+		switch {
+		case declared && !has:
+			c.violation(object, "missing the %s annotation of the declared %s trait", proto.MessageName(t.message), t.trait)
+		case !declared && has:
+			c.violation(object, "has the %s annotation of the %s trait the resource type doesn't declare", proto.MessageName(t.message), t.trait)
+		}
+	}
+	c.checkAnnotations(object, resource.GetAnnotations())
+	return true
+}
+
+// checkEntitlement checks an entitlement listed for the resource, and records it for the checks of the grants.
+func (c *contractCheck) checkEntitlement(resource *v2.Resource, ent *v2.Entitlement) {
+	object := "entitlement " + ent.GetId()
+	if ent.GetId() == "" {
+		c.violation("entitlement of "+resourceIDKey(resource.GetId()), "entitlement ID is missing")
+		return
+	}
+	if !proto.Equal(ent.GetResource().GetId(), resource.GetId()) {
+		c.violation(object, "entitlement of resource %s listed for resource %s",
+			resourceIDKey(ent.GetResource().GetId()), resourceIDKey(resource.GetId()))
+	}
+	if want := entitlement.NewEntitlementID(resource, ent.GetSlug()); ent.GetSlug() != "" && ent.GetId() != want {
+		c.violation(object, "entitlement ID doesn't match its resource and slug, expected %s", want)
+	}
+	for _, rt := range ent.GetGrantableTo() {
+		if rt.GetId() == "" {
+			c.violation(object, "grantable to a resource type without an ID")
+		}
+	}
+	c.checkAnnotations(object, ent.GetAnnotations())
+	c.entitlements[ent.GetId()] = ent
+}
+
+// checkGrant checks a grant against the entitlement it's on, when the sync returned it. Roles grant
+// permissions on named objects that may not exist, whose entitlements aren't synced.
+func (c *contractCheck) checkGrant(g *v2.Grant) {
+	object := "grant " + g.GetId()
+	if g.GetId() == "" {
+		object = fmt.Sprintf("grant of %s to %s", g.GetEntitlement().GetId(), resourceIDKey(g.GetPrincipal().GetId()))
+		c.violation(object, "grant ID is missing")
+	}
+	if g.GetEntitlement().GetId() == "" || g.GetEntitlement().GetResource().GetId() == nil {
+		c.violation(object, "grant entitlement is missing its ID or resource")
+	}
+	principal := g.GetPrincipal().GetId()
+	if principal.GetResourceType() == "" || principal.GetResource() == "" {
+		c.violation(object, "grant principal is missing its resource type or resource")
+	}
+
+	if ent, ok := c.entitlements[g.GetEntitlement().GetId()]; ok && len(ent.GetGrantableTo()) > 0 {
+		grantable := slices.ContainsFunc(ent.GetGrantableTo(), func(rt *v2.ResourceType) bool {
+			return rt.GetId() == principal.GetResourceType()
+		})
+		if !grantable {
+			var types []string
+			for _, rt := range ent.GetGrantableTo() {
+				types = append(types, rt.GetId())
+			}
+			c.violation(object, "principal of type %s, the entitlement is only grantable to %s",
+				principal.GetResourceType(), strings.Join(types, ", "))
+		}
+	}
+	c.checkAnnotations(object, g.GetAnnotations())
+}
+
+// checkAnnotations checks that the annotations unmarshal into registered message types.
+func (c *contractCheck) checkAnnotations(object string, annos []*anypb.Any) {
+	for _, a := range annos {
+		if _, err := a.UnmarshalNew(); err != nil {
+			c.violation(object, "annotation %s doesn't unmarshal: %v", a.GetTypeUrl(), err)
+		}
+	}
+}
+
+// listAllEntitlements lists every page of the entitlements of a resource.
+func listAllEntitlements(ctx context.Context, syncer connectorbuilder.ResourceSyncer, resource *v2.Resource) ([]*v2.Entitlement, error) {
+	var rv []*v2.Entitlement
+	token := &pagination.Token{}
+	for {
+		entitlements, next, _, err := syncer.Entitlements(ctx, resource, token)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list entitlements of %s: %w", resourceIDKey(resource.Id), err)
+		}
+		rv = append(rv, entitlements...)
+		if next == "" {
+			return rv, nil
+		}
+		token = &pagination.Token{Token: next}
+	}
+}
+
+// SelfCheck runs a full sync with the connector's syncers and checks the output against the invariants of
+// baton-sdk. See ValidateSyncOutput.
+func (k *Kubernetes) SelfCheck(ctx context.Context) (*ContractReport, error) {
+	return ValidateSyncOutput(ctx, k.ResourceSyncers(ctx))
+}
@@ -0,0 +1,216 @@
+package connector
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
+)
+
+// contractTestClient returns a fake cluster with an object of every synced kind, bound to users, groups,
+// service accounts and system identities through roles, cluster roles and an aggregated cluster role.
+func contractTestClient() *fake.Clientset {
+	podSpec := corev1.PodSpec{
+		ServiceAccountName: "deployer",
+		NodeName:           "node-1",
+		Volumes: []corev1.Volume{
+			{Name: "creds", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "db-creds"}}},
+			{Name: "config", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "app-config"}}}},
+		},
+		ImagePullSecrets: []corev1.LocalObjectReference{{Name: "registry"}},
+	}
+	labels := map[string]string{"app": "api"}
+	aggregateTo := map[string]string{"rbac.example.com/aggregate-to-admin": "true"}
+
+	objects := []runtime.Object{
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "payments", Labels: map[string]string{"team": "payments"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}},
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "deployer", Namespace: "payments"}, ImagePullSecrets: []corev1.LocalObjectReference{{Name: "registry"}}},
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "payments"}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "db-creds", Namespace: "payments"}, Type: corev1.SecretTypeOpaque},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "registry", Namespace: "payments"}, Type: corev1.SecretTypeDockerConfigJson},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "deployer-token", Namespace: "payments", Annotations: map[string]string{corev1.ServiceAccountNameKey: "deployer"}},
+			Type:       corev1.SecretTypeServiceAccountToken,
+		},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: "payments"}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "payments"}, Spec: corev1.ServiceSpec{Selector: labels}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "api-1", Namespace: "payments", Labels: labels}, Spec: podSpec},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "payments"},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: ptr.To(int32(1)),
+				Selector: &metav1.LabelSelector{MatchLabels: labels},
+				Template: corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: labels}, Spec: podSpec},
+			},
+		},
+		&appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "payments"}, Spec: appsv1.StatefulSetSpec{Template: corev1.PodTemplateSpec{Spec: podSpec}}},
+		&appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: "agent", Namespace: "kube-system"}, Spec: appsv1.DaemonSetSpec{Template: corev1.PodTemplateSpec{Spec: podSpec}}},
+		&appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Name: "api-1", Namespace: "payments"}, Spec: appsv1.ReplicaSetSpec{Template: corev1.PodTemplateSpec{Spec: podSpec}}},
+
+		&rbacv1.Role{
+			ObjectMeta: metav1.ObjectMeta{Name: "secret-reader", Namespace: "payments"},
+			Rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get", "list"}},
+				{APIGroups: []string{""}, Resources: []string{"configmaps"}, ResourceNames: []string{"app-config", "missing"}, Verbs: []string{"get"}},
+				{APIGroups: []string{""}, Resources: []string{"pods/exec"}, Verbs: []string{"create"}},
+				{APIGroups: []string{RBACAPIGroup}, Resources: []string{"rolebindings"}, Verbs: []string{"create"}},
+				{APIGroups: []string{RBACAPIGroup}, Resources: []string{"roles"}, Verbs: []string{"bind", "escalate"}},
+			},
+		},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "secret-readers", Namespace: "payments"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindRole, Name: "secret-reader"},
+			Subjects: []rbacv1.Subject{
+				{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "alice@example.com"},
+				{Kind: SubjectKindGroup, APIGroup: RBACAPIGroup, Name: "payments-devs"},
+				{Kind: SubjectKindServiceAccount, Namespace: "payments", Name: "deployer"},
+				{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "system:serviceaccount:payments:default"},
+				{Kind: SubjectKindGroup, APIGroup: RBACAPIGroup, Name: "system:serviceaccounts:payments"},
+			},
+		},
+		&rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{Name: "admin"},
+			AggregationRule: &rbacv1.AggregationRule{ClusterRoleSelectors: []metav1.LabelSelector{
+				{MatchLabels: aggregateTo},
+			}},
+			Rules: []rbacv1.PolicyRule{{APIGroups: []string{"apps"}, Resources: []string{"deployments", "deployments/scale"}, Verbs: []string{"*"}}},
+		},
+		&rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{Name: "deployments-admin", Labels: aggregateTo},
+			Rules:      []rbacv1.PolicyRule{{APIGroups: []string{"apps"}, Resources: []string{"deployments", "deployments/scale"}, Verbs: []string{"*"}}},
+		},
+		&rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{Name: "impersonator"},
+			Rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{""}, Resources: []string{"users", "groups", "serviceaccounts"}, Verbs: []string{"impersonate"}},
+				{APIGroups: []string{""}, Resources: []string{"nodes", "namespaces"}, Verbs: []string{"get"}},
+				{NonResourceURLs: []string{"/metrics"}, Verbs: []string{"get"}},
+			},
+		},
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "cluster-admin"}, Rules: []rbacv1.PolicyRule{
+			{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}},
+		}},
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "impersonators"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindClusterRole, Name: "impersonator"},
+			Subjects: []rbacv1.Subject{
+				{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "system:kube-controller-manager"},
+				{Kind: SubjectKindGroup, APIGroup: RBACAPIGroup, Name: "oncall"},
+			},
+		},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "payments-admins", Namespace: "payments"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindClusterRole, Name: "admin"},
+			Subjects:   []rbacv1.Subject{{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "bob@example.com"}},
+		},
+	}
+	return fake.NewSimpleClientset(objects...)
+}
+
+// TestSyncOutputContract syncs a fake cluster with the connector's syncers, under option sets changing the
+// resource types and grants emitted, and fails on any output violating the baton-sdk contract.
+func TestSyncOutputContract(t *testing.T) {
+	ctx := context.Background()
+	optionSets := map[string]ConnectorOpts{
+		"default": {},
+		"system subjects separated": {
+			IncludeSystemSubjects: true,
+			SeparateSystemUsers:   true,
+		},
+		"grants from outside rbac": {
+			IncludeSystemSubjects:      true,
+			ExpandServiceAccountGroups: true,
+			MountGrants:                true,
+			SecretSensitivity:          true,
+			InheritAggregatedBindings:  true,
+			ClusterAdminsReport:        true,
+			NamespaceWildcards:         true,
+		},
+		"compact and filtered": {
+			CompactClusterRoleEntitlements: true,
+			DisableWildcardResources:       true,
+			SkipMissingNamedResources:      true,
+			VerbFilter:                     map[string][]string{VerbFilterDefault: {"get"}},
+		},
+	}
+	for name, opts := range optionSets {
+		t.Run(name, func(t *testing.T) {
+			k := newKubernetes(contractTestClient(), nil, opts)
+			report, err := k.SelfCheck(ctx)
+			require.NoError(t, err)
+			assert.NotZero(t, report.Resources)
+			assert.NotZero(t, report.Entitlements)
+			assert.NotZero(t, report.Grants)
+			for _, v := range report.Violations {
+				t.Error(v)
+			}
+		})
+	}
+}
+
+// contractViolatingSyncer returns a user without the trait of its type, an entitlement of another resource
+// and a grant to a principal the entitlement isn't grantable to.
+type contractViolatingSyncer struct{}
+
+func (contractViolatingSyncer) ResourceType(context.Context) *v2.ResourceType {
+	return ResourceTypeKubeUser
+}
+
+func (contractViolatingSyncer) List(context.Context, *v2.ResourceId, *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	resource, err := rs.NewResource("alice", ResourceTypeKubeUser, "alice")
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return []*v2.Resource{resource}, "", nil, nil
+}
+
+func (contractViolatingSyncer) Entitlements(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	other := GenerateResourceForGrant("bob", ResourceTypeKubeUser.Id)
+	return []*v2.Entitlement{
+		entitlement.NewPermissionEntitlement(resource, "impersonate", entitlement.WithGrantableTo(ResourceTypeRole)),
+		entitlement.NewPermissionEntitlement(other, "impersonate"),
+	}, "", nil, nil
+}
+
+func (contractViolatingSyncer) Grants(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	principal := GenerateResourceForGrant("payments/deployer", ResourceTypeServiceAccount.Id)
+	return []*v2.Grant{grant.NewGrant(resource, "impersonate", principal)}, "", nil, nil
+}
+
+func TestValidateSyncOutput_Violations(t *testing.T) {
+	report, err := ValidateSyncOutput(context.Background(), []connectorbuilder.ResourceSyncer{contractViolatingSyncer{}})
+	require.NoError(t, err)
+
+	var messages []string
+	for _, v := range report.Violations {
+		messages = append(messages, v.String())
+	}
+	assert.Equal(t, []string{
+		"kube_user syncer: resource kube_user:alice: missing the c1.connector.v2.UserTrait annotation of the declared TRAIT_USER trait",
+		"kube_user syncer: entitlement kube_user:bob:impersonate: entitlement of resource kube_user:bob listed for resource kube_user:alice",
+		"kube_user syncer: entitlement kube_user:bob:impersonate: entitlement ID doesn't match its resource and slug, expected kube_user:alice:impersonate",
+		"kube_user syncer: grant kube_user:alice:impersonate:service_account:payments/deployer: principal of type service_account, the entitlement is only grantable to role",
+	}, messages)
+
+	err = report.Err()
+	assert.ErrorIs(t, err, ErrContractViolation)
+	assert.True(t, strings.HasPrefix(err.Error(), "sync output violates the baton-sdk contract: 4 violations, first kube_user syncer"))
+}
@@ -0,0 +1,188 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/time/rate"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// coverageConcurrency is the number of access reviews in flight at once.
+	coverageConcurrency = 8
+	// coverageRequestsPerSecond and coverageBurst rate limit the access reviews.
+	coverageRequestsPerSecond = 20
+	coverageBurst             = 10
+)
+
+// coverageChecks are the accesses verified in every synced namespace.
+var coverageChecks = []authorizationv1.ResourceAttributes{
+	{Verb: "list", Group: "", Resource: "secrets"},
+	{Verb: "list", Group: RBACAPIGroup, Resource: "roles"},
+	{Verb: "list", Group: RBACAPIGroup, Resource: "rolebindings"},
+}
+
+// CoverageGap is a synced namespace in which the connector lacks list permission on some of the key resources,
+// so their absence from the sync doesn't mean they don't exist.
+type CoverageGap struct {
+	Namespace string
+	// Resources are the resources the connector can't list in the namespace, e.g. "secrets" or
+	// "roles.rbac.authorization.k8s.io".
+	Resources []string
+}
+
+// coverageVerifier checks that the connector could read everything in the namespaces it synced, using
+// SelfSubjectAccessReviews. A nil *coverageVerifier is valid and verifies nothing.
+type coverageVerifier struct {
+	client  kubernetes.Interface
+	stats   *syncStats
+	limiter *rate.Limiter
+
+	mu         sync.Mutex
+	namespaces []string
+	gaps       []CoverageGap
+}
+
+// newCoverageVerifier creates a coverage verifier recording its results in the given stats.
+func newCoverageVerifier(client kubernetes.Interface, stats *syncStats) *coverageVerifier {
+	return &coverageVerifier{
+		client:  client,
+		stats:   stats,
+		limiter: rate.NewLimiter(rate.Limit(coverageRequestsPerSecond), coverageBurst),
+	}
+}
+
+// record adds synced namespaces to be verified.
+func (v *coverageVerifier) record(namespaces ...string) {
+	if v == nil {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.namespaces = append(v.namespaces, namespaces...)
+}
+
+// complete verifies the recorded namespaces once the namespace list is complete, reporting the namespaces
+// with gaps in the sync statistics and logs, and failing with ErrPartialSync if there are any.
+func (v *coverageVerifier) complete(ctx context.Context) error {
+	if v == nil {
+		return nil
+	}
+	l := ctxzap.Extract(ctx)
+
+	v.mu.Lock()
+	namespaces := v.namespaces
+	v.namespaces = nil
+	v.mu.Unlock()
+
+	gaps, err := v.verify(ctx, namespaces)
+	if err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	v.gaps = gaps
+	v.mu.Unlock()
+
+	v.stats.Add(StatCoverageNamespacesChecked, int64(len(namespaces)))
+	v.stats.Add(StatCoverageGapNamespaces, int64(len(gaps)))
+	if len(gaps) == 0 {
+		return nil
+	}
+
+	gapNamespaces := make([]string, 0, len(gaps))
+	for _, gap := range gaps {
+		l.Warn("connector can't list resources in synced namespace, sync may be incomplete",
+			zap.String("namespace", gap.Namespace),
+			zap.Strings("resources", gap.Resources))
+		gapNamespaces = append(gapNamespaces, gap.Namespace)
+	}
+
+	return fmt.Errorf("%w: connector can't list secrets, roles or rolebindings in namespaces %s",
+		ErrPartialSync, strings.Join(gapNamespaces, ", "))
+}
+
+// Gaps returns the namespaces with gaps found by the last verification.
+func (v *coverageVerifier) Gaps() []CoverageGap {
+	if v == nil {
+		return nil
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.gaps
+}
+
+// verify checks the coverage accesses in each namespace and returns the namespaces with gaps, sorted by name.
+func (v *coverageVerifier) verify(ctx context.Context, namespaces []string) ([]CoverageGap, error) {
+	var mu sync.Mutex
+	missing := make(map[string][]string)
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(coverageConcurrency)
+	for _, namespace := range namespaces {
+		for _, check := range coverageChecks {
+			g.Go(func() error {
+				allowed, err := v.allowed(ctx, namespace, check)
+				if err != nil {
+					return err
+				}
+				if !allowed {
+					mu.Lock()
+					missing[namespace] = append(missing[namespace], coverageResourceName(check))
+					mu.Unlock()
+				}
+				return nil
+			})
+		}
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	gaps := make([]CoverageGap, 0, len(missing))
+	for namespace, resources := range missing {
+		sort.Strings(resources)
+		gaps = append(gaps, CoverageGap{Namespace: namespace, Resources: resources})
+	}
+	sort.Slice(gaps, func(i, j int) bool {
+		return gaps[i].Namespace < gaps[j].Namespace
+	})
+
+	return gaps, nil
+}
+
+// allowed reviews whether the connector is allowed the access in the namespace.
+func (v *coverageVerifier) allowed(ctx context.Context, namespace string, check authorizationv1.ResourceAttributes) (bool, error) {
+	if err := v.limiter.Wait(ctx); err != nil {
+		return false, fmt.Errorf("failed to wait for access review rate limit: %w", err)
+	}
+
+	attributes := check
+	attributes.Namespace = namespace
+	review := &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &attributes,
+		},
+	}
+
+	resp, err := v.client.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to review access to %s in namespace %s: %w", coverageResourceName(check), namespace, err)
+	}
+
+	return resp.Status.Allowed, nil
+}
+
+// coverageResourceName returns the "resource.group" name of a checked resource.
+func coverageResourceName(check authorizationv1.ResourceAttributes) string {
+	return strings.TrimSuffix(check.Resource+"."+check.Group, ".")
+}
@@ -0,0 +1,105 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/conductorone/baton-kubernetes/pkg/kubetest"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// TestNamespaceBuilderList_VerifyCoverage tests that namespaces in which the connector can't list the key
+// resources are reported once the namespace list is complete, failing the sync as partial.
+func TestNamespaceBuilderList_VerifyCoverage(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "restricted"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}},
+	)
+
+	// Deny listing secrets and roles in the restricted namespace
+	reviews := 0
+	client.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview).DeepCopy()
+		attributes := review.Spec.ResourceAttributes
+		review.Status.Allowed = attributes.Namespace != "restricted" || attributes.Resource == "rolebindings"
+		reviews++
+		return true, review, nil
+	})
+
+	stats := newSyncStats()
+	coverage := newCoverageVerifier(client, stats)
+	builder := newNamespaceBuilder(client, nil, ConnectorOpts{VerifyCoverage: true}, coverage)
+
+	_, _, _, err := builder.List(ctx, nil, &pagination.Token{})
+	require.ErrorIs(t, err, ErrPartialSync)
+	assert.Contains(t, err.Error(), "restricted")
+
+	assert.Equal(t, 3*len(coverageChecks), reviews)
+	assert.Equal(t, []CoverageGap{
+		{Namespace: "restricted", Resources: []string{"roles.rbac.authorization.k8s.io", "secrets"}},
+	}, coverage.Gaps())
+	assert.Equal(t, int64(3), stats.Get(StatCoverageNamespacesChecked))
+	assert.Equal(t, int64(1), stats.Get(StatCoverageGapNamespaces))
+}
+
+// TestNamespaceBuilderList_CoverageDisabled tests that no access reviews are made unless enabled.
+func TestNamespaceBuilderList_CoverageDisabled(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
+	client.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		t.Fatal("unexpected access review")
+		return true, nil, nil
+	})
+
+	builder := newNamespaceBuilder(client, nil, ConnectorOpts{}, nil)
+	_, _, _, err := builder.List(context.Background(), nil, &pagination.Token{})
+	require.NoError(t, err)
+}
+
+// TestNamespaceBuilderList_FullCoverage tests that the sync succeeds when every namespace is covered, and that
+// coverage is verified once, after the last page of namespaces.
+func TestNamespaceBuilderList_FullCoverage(t *testing.T) {
+	ctx := context.Background()
+	var objects []runtime.Object
+	for _, name := range []string{"default", "kube-system", "payments", "staging", "tools"} {
+		objects = append(objects, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	}
+	client := fake.NewSimpleClientset(objects...)
+	kubetest.Paginate(client, []string{"namespaces"}, kubetest.WithPageSize(2))
+	client.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview).DeepCopy()
+		review.Status.Allowed = true
+		return true, review, nil
+	})
+
+	stats := newSyncStats()
+	coverage := newCoverageVerifier(client, stats)
+	builder := newNamespaceBuilder(client, nil, ConnectorOpts{VerifyCoverage: true}, coverage)
+
+	var resources []*v2.Resource
+	token := &pagination.Token{}
+	for pages := 1; ; pages++ {
+		page, nextPageToken, _, err := builder.List(ctx, nil, token)
+		require.NoError(t, err)
+		resources = append(resources, page...)
+		if nextPageToken == "" {
+			assert.Equal(t, 3, pages)
+			break
+		}
+		assert.Zero(t, stats.Get(StatCoverageNamespacesChecked), "coverage verified before the last page")
+		token = &pagination.Token{Token: nextPageToken}
+	}
+	assert.Len(t, resources, 6) // wildcard and five namespaces
+	assert.Empty(t, coverage.Gaps())
+	assert.Equal(t, int64(5), stats.Get(StatCoverageNamespacesChecked))
+}
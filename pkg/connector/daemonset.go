@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 
@@ -17,9 +18,10 @@ import (
 	"go.uber.org/zap"
 )
 
-// daemonSetBuilder syncs Kubernetes DaemonSets as Baton resources.
+// daemonSetBuilder syncs Kubernetes DaemonSets as Baton resources. It's stateless and safe for concurrent use.
 type daemonSetBuilder struct {
 	client kubernetes.Interface
+	opts   ConnectorOpts
 }
 
 // ResourceType returns the resource type for DaemonSet.
@@ -31,41 +33,36 @@ func (d *daemonSetBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
 func (d *daemonSetBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
 	l := ctxzap.Extract(ctx)
 
-	// Initialize empty resource slice
-	var rv []*v2.Resource
-
 	// Parse pagination token
 	bag, err := ParsePageToken(pToken.Token)
 	if err != nil {
 		return nil, "", nil, fmt.Errorf("failed to parse page token: %w", err)
 	}
 
-	// Add wildcard resource first, but only on the first page (when page token is empty)
-	if bag.PageToken() == "" {
-		wildcardResource, err := generateWildcardResource(ResourceTypeDaemonSet)
-		if err != nil {
-			l.Error("failed to create wildcard resource for daemonsets", zap.Error(err))
-		} else {
-			rv = append(rv, wildcardResource)
-		}
+	// List the namespace the SDK lists the children of, or all namespaces, after the wildcards
+	namespace, rv, done, err := startNamespacedList(ctx, d.client, d.opts, ResourceTypeDaemonSet, parentResourceID, bag.PageToken(), nil)
+	if err != nil || done {
+		return rv, "", nil, err
 	}
 
 	// Set up list options with pagination
 	opts := metav1.ListOptions{
-		Limit:    ResourcesPageSize,
+		Limit:    d.opts.pageSize(ResourceTypeDaemonSet.Id),
 		Continue: bag.PageToken(),
 	}
 
-	// Fetch daemonsets from the Kubernetes API across all namespaces
+	// Fetch daemonsets from the Kubernetes API in the namespace, or across all namespaces
 	l.Debug("fetching daemonsets", zap.String("continue_token", opts.Continue))
-	resp, err := d.client.AppsV1().DaemonSets("").List(ctx, opts)
+	resp, err := listWithRetry(ctx, d.opts, func(ctx context.Context) (*appsv1.DaemonSetList, error) {
+		return d.client.AppsV1().DaemonSets(namespace).List(ctx, opts)
+	})
 	if err != nil {
 		return nil, "", nil, fmt.Errorf("failed to list daemonsets: %w", err)
 	}
 
 	// Process each daemonset into a Baton resource
 	for _, daemonset := range resp.Items {
-		resource, err := daemonSetResource(&daemonset)
+		resource, err := daemonSetResource(&daemonset, d.opts)
 		if err != nil {
 			l.Error("failed to create daemonset resource",
 				zap.String("namespace", daemonset.Namespace),
@@ -86,7 +83,7 @@ func (d *daemonSetBuilder) List(ctx context.Context, parentResourceID *v2.Resour
 }
 
 // daemonSetResource creates a Baton resource from a Kubernetes DaemonSet.
-func daemonSetResource(daemonset *appsv1.DaemonSet) (*v2.Resource, error) {
+func daemonSetResource(daemonset *appsv1.DaemonSet, opts ConnectorOpts) (*v2.Resource, error) {
 	// Get parent namespace resource ID
 	parentID, err := NamespaceResourceID(daemonset.Namespace)
 	if err != nil {
@@ -104,8 +101,15 @@ func daemonSetResource(daemonset *appsv1.DaemonSet) (*v2.Resource, error) {
 		options = append(options, rs.WithExternalID(&v2.ExternalId{Id: string(daemonset.UID)}))
 	}
 
+	// Add configured label tags
+	tagOptions, err := labelTagOptions(daemonset.Labels, opts)
+	if err != nil {
+		return nil, err
+	}
+	options = append(options, tagOptions...)
+
 	// Create the raw ID as namespace/name
-	rawID := daemonset.Namespace + "/" + daemonset.Name
+	rawID := namespacedName(daemonset.Namespace, daemonset.Name)
 
 	// Create resource
 	resource, err := rs.NewResource(
@@ -126,7 +130,7 @@ func (d *daemonSetBuilder) Entitlements(ctx context.Context, resource *v2.Resour
 	var entitlements []*v2.Entitlement
 
 	// Add standard verb entitlements
-	for _, verb := range standardResourceVerbs {
+	for _, verb := range d.opts.resourceVerbs(ResourceTypeDaemonSet.Id) {
 		ent := entitlement.NewPermissionEntitlement(
 			resource,
 			verb,
@@ -143,14 +147,25 @@ func (d *daemonSetBuilder) Entitlements(ctx context.Context, resource *v2.Resour
 	return entitlements, "", nil, nil
 }
 
-// Grants returns no grants for DaemonSet resources.
+// Grants returns the runs_as grant of the service account the DaemonSet runs as.
 func (d *daemonSetBuilder) Grants(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
-	return nil, "", nil, nil
+	grants, err := workloadRunsAsGrants(ctx, resource, func(namespace, name string) (*corev1.PodSpec, error) {
+		workload, err := d.client.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return &workload.Spec.Template.Spec, nil
+	})
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return grants, "", nil, nil
 }
 
 // newDaemonSetBuilder creates a new daemonset builder.
-func newDaemonSetBuilder(client kubernetes.Interface) *daemonSetBuilder {
+func newDaemonSetBuilder(client kubernetes.Interface, opts ConnectorOpts) *daemonSetBuilder {
 	return &daemonSetBuilder{
 		client: client,
+		opts:   opts,
 	}
 }
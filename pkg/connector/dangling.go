@@ -0,0 +1,134 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DanglingBinding is a binding whose RoleRef points at a Role or ClusterRole that doesn't exist. It grants
+// nothing today, but recreating the role silently re-activates the access.
+type DanglingBinding struct {
+	// Kind is BindingKindRoleBinding or BindingKindClusterRoleBinding.
+	Kind      string
+	Namespace string
+	Name      string
+	RoleRef   rbacv1.RoleRef
+}
+
+// findDanglingBindings returns the bindings whose RoleRef points at a role missing from the cluster.
+func findDanglingBindings(ctx context.Context, client kubernetes.Interface, roleBindings []rbacv1.RoleBinding,
+	clusterRoleBindings []rbacv1.ClusterRoleBinding) ([]DanglingBinding, error) {
+	roles, err := listRoleKeys(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	clusterRoles, err := listClusterRoleNames(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	var rv []DanglingBinding
+	for _, binding := range roleBindings {
+		var exists bool
+		switch binding.RoleRef.Kind {
+		case RoleRefKindRole:
+			exists = roles[binding.Namespace+"/"+binding.RoleRef.Name]
+		case RoleRefKindClusterRole:
+			exists = clusterRoles[binding.RoleRef.Name]
+		default:
+			continue
+		}
+		if !exists {
+			rv = append(rv, DanglingBinding{
+				Kind:      BindingKindRoleBinding,
+				Namespace: binding.Namespace,
+				Name:      binding.Name,
+				RoleRef:   binding.RoleRef,
+			})
+		}
+	}
+	for _, binding := range clusterRoleBindings {
+		if binding.RoleRef.Kind == RoleRefKindClusterRole && !clusterRoles[binding.RoleRef.Name] {
+			rv = append(rv, DanglingBinding{
+				Kind:    BindingKindClusterRoleBinding,
+				Name:    binding.Name,
+				RoleRef: binding.RoleRef,
+			})
+		}
+	}
+	return rv, nil
+}
+
+// listRoleKeys returns the namespace/name keys of all Roles in the cluster.
+func listRoleKeys(ctx context.Context, client kubernetes.Interface) (map[string]bool, error) {
+	rv := make(map[string]bool)
+	opts := metav1.ListOptions{Limit: ResourcesPageSize}
+	for {
+		resp, err := client.RbacV1().Roles("").List(ctx, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list roles: %w", err)
+		}
+		for _, role := range resp.Items {
+			rv[role.Namespace+"/"+role.Name] = true
+		}
+		if resp.Continue == "" {
+			return rv, nil
+		}
+		opts.Continue = resp.Continue
+	}
+}
+
+// listClusterRoleNames returns the names of all ClusterRoles in the cluster.
+func listClusterRoleNames(ctx context.Context, client kubernetes.Interface) (map[string]bool, error) {
+	rv := make(map[string]bool)
+	opts := metav1.ListOptions{Limit: ResourcesPageSize}
+	for {
+		resp, err := client.RbacV1().ClusterRoles().List(ctx, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list cluster roles: %w", err)
+		}
+		for _, clusterRole := range resp.Items {
+			rv[clusterRole.Name] = true
+		}
+		if resp.Continue == "" {
+			return rv, nil
+		}
+		opts.Continue = resp.Continue
+	}
+}
+
+// checkDanglingBindings records and logs the loaded bindings whose RoleRef points at a missing role. Failing
+// to check doesn't fail the sync.
+func (k *Kubernetes) checkDanglingBindings(ctx context.Context, roleBindings []rbacv1.RoleBinding, clusterRoleBindings []rbacv1.ClusterRoleBinding) {
+	l := ctxzap.Extract(ctx)
+
+	dangling, err := findDanglingBindings(ctx, k.client, roleBindings, clusterRoleBindings)
+	if err != nil {
+		l.Warn("failed to check bindings for dangling role references", zap.Error(err))
+		return
+	}
+
+	for _, binding := range dangling {
+		l.Warn("binding references a role that doesn't exist, recreating the role re-activates its access",
+			zap.String("kind", binding.Kind),
+			zap.String("namespace", binding.Namespace),
+			zap.String("name", binding.Name),
+			zap.String("roleRefKind", binding.RoleRef.Kind),
+			zap.String("roleRefName", binding.RoleRef.Name))
+	}
+	k.stats.Add(StatDanglingRoleRefs, int64(len(dangling)))
+	k.danglingBindings = dangling
+}
+
+// DanglingBindings returns the bindings found referencing a Role or ClusterRole that doesn't exist.
+func (k *Kubernetes) DanglingBindings() []DanglingBinding {
+	k.bindingsMutex.RLock()
+	defer k.bindingsMutex.RUnlock()
+	return k.danglingBindings
+}
@@ -0,0 +1,91 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	rbacv1 "k8s.io/api/rbac/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestLoadBindingsCaches_DanglingRoleRefs(t *testing.T) {
+	ctx := context.Background()
+	subjects := []rbacv1.Subject{{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "alice"}}
+	client := fake.NewSimpleClientset(
+		&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "deployer"}},
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "view"}},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "deployer"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindRole, Name: "deployer"},
+			Subjects:   subjects,
+		},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "old-admin"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindRole, Name: "old-admin"},
+			Subjects:   subjects,
+		},
+		// A Role of the same name in another namespace doesn't satisfy the RoleRef
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "deployer"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindRole, Name: "deployer"},
+			Subjects:   subjects,
+		},
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "view"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindClusterRole, Name: "view"},
+			Subjects:   subjects,
+		},
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "legacy-operator"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindClusterRole, Name: "legacy-operator"},
+			Subjects:   subjects,
+		},
+	)
+
+	// Delete the role a binding references
+	require.NoError(t, client.RbacV1().ClusterRoles().Delete(ctx, "view", metav1.DeleteOptions{}))
+
+	k := newTestKubernetes(client, ConnectorOpts{})
+	require.NoError(t, k.loadBindingsCaches(ctx))
+
+	var dangling []string
+	for _, binding := range k.DanglingBindings() {
+		dangling = append(dangling, binding.Kind+":"+binding.Namespace+"/"+binding.Name+"->"+binding.RoleRef.Name)
+	}
+	assert.ElementsMatch(t, []string{
+		"RoleBinding:default/old-admin->old-admin",
+		"RoleBinding:payments/deployer->deployer",
+		"ClusterRoleBinding:/view->view",
+		"ClusterRoleBinding:/legacy-operator->legacy-operator",
+	}, dangling)
+	assert.Equal(t, int64(4), k.SyncStats()[StatDanglingRoleRefs])
+
+	// The bindings are still cached
+	assert.Len(t, k.roleBindingsCache, 3)
+	assert.Len(t, k.clusterRoleBindingsCache, 2)
+}
+
+// TestLoadBindingsCaches_DanglingCheckForbidden tests that failing to list roles doesn't fail loading the bindings.
+func TestLoadBindingsCaches_DanglingCheckForbidden(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(&rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "view"},
+		RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindClusterRole, Name: "view"},
+	})
+	client.PrependReactor("list", "roles", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, k8serrors.NewForbidden(rbacv1.Resource("roles"), "", errors.New("denied"))
+	})
+
+	k := newTestKubernetes(client, ConnectorOpts{})
+	require.NoError(t, k.loadBindingsCaches(ctx))
+	assert.Len(t, k.clusterRoleBindingsCache, 1)
+	assert.Empty(t, k.DanglingBindings())
+	assert.Zero(t, k.SyncStats()[StatDanglingRoleRefs])
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 
@@ -17,9 +18,10 @@ import (
 	"go.uber.org/zap"
 )
 
-// deploymentBuilder syncs Kubernetes Deployments as Baton resources.
+// deploymentBuilder syncs Kubernetes Deployments as Baton resources. It's stateless and safe for concurrent use.
 type deploymentBuilder struct {
 	client kubernetes.Interface
+	opts   ConnectorOpts
 }
 
 // ResourceType returns the resource type for Deployment.
@@ -31,41 +33,36 @@ func (d *deploymentBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
 func (d *deploymentBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
 	l := ctxzap.Extract(ctx)
 
-	// Initialize empty resource slice
-	var rv []*v2.Resource
-
 	// Parse pagination token
 	bag, err := ParsePageToken(pToken.Token)
 	if err != nil {
 		return nil, "", nil, fmt.Errorf("failed to parse page token: %w", err)
 	}
 
-	// Add wildcard resource first, but only on the first page (when page token is empty)
-	if bag.PageToken() == "" {
-		wildcardResource, err := generateWildcardResource(ResourceTypeDeployment)
-		if err != nil {
-			l.Error("failed to create wildcard resource for deployments", zap.Error(err))
-		} else {
-			rv = append(rv, wildcardResource)
-		}
+	// List the namespace the SDK lists the children of, or all namespaces, after the wildcards
+	namespace, rv, done, err := startNamespacedList(ctx, d.client, d.opts, ResourceTypeDeployment, parentResourceID, bag.PageToken(), nil)
+	if err != nil || done {
+		return rv, "", nil, err
 	}
 
 	// Set up list options with pagination
 	opts := metav1.ListOptions{
-		Limit:    ResourcesPageSize,
+		Limit:    d.opts.pageSize(ResourceTypeDeployment.Id),
 		Continue: bag.PageToken(),
 	}
 
-	// Fetch deployments from the Kubernetes API across all namespaces
+	// Fetch deployments from the Kubernetes API in the namespace, or across all namespaces
 	l.Debug("fetching deployments", zap.String("continue_token", opts.Continue))
-	resp, err := d.client.AppsV1().Deployments("").List(ctx, opts)
+	resp, err := listWithRetry(ctx, d.opts, func(ctx context.Context) (*appsv1.DeploymentList, error) {
+		return d.client.AppsV1().Deployments(namespace).List(ctx, opts)
+	})
 	if err != nil {
 		return nil, "", nil, fmt.Errorf("failed to list deployments: %w", err)
 	}
 
 	// Process each deployment into a Baton resource
 	for _, deployment := range resp.Items {
-		resource, err := deploymentResource(&deployment)
+		resource, err := deploymentResource(&deployment, d.opts)
 		if err != nil {
 			l.Error("failed to create deployment resource",
 				zap.String("namespace", deployment.Namespace),
@@ -86,9 +83,9 @@ func (d *deploymentBuilder) List(ctx context.Context, parentResourceID *v2.Resou
 }
 
 // deploymentResource creates a Baton resource from a Kubernetes Deployment.
-func deploymentResource(deployment *appsv1.Deployment) (*v2.Resource, error) {
+func deploymentResource(deployment *appsv1.Deployment, opts ConnectorOpts) (*v2.Resource, error) {
 	// Create resource ID for the deployment
-	resourceID := deployment.Namespace + "/" + deployment.Name
+	resourceID := namespacedName(deployment.Namespace, deployment.Name)
 
 	// Get parent namespace resource ID
 	parentID, err := NamespaceResourceID(deployment.Namespace)
@@ -107,6 +104,13 @@ func deploymentResource(deployment *appsv1.Deployment) (*v2.Resource, error) {
 		options = append(options, rs.WithExternalID(&v2.ExternalId{Id: string(deployment.UID)}))
 	}
 
+	// Add configured label tags
+	tagOptions, err := labelTagOptions(deployment.Labels, opts)
+	if err != nil {
+		return nil, err
+	}
+	options = append(options, tagOptions...)
+
 	// Create resource
 	resource, err := rs.NewResource(
 		deployment.Name,
@@ -126,7 +130,7 @@ func (d *deploymentBuilder) Entitlements(ctx context.Context, resource *v2.Resou
 	var entitlements []*v2.Entitlement
 
 	// Add standard verb entitlements
-	for _, verb := range standardResourceVerbs {
+	for _, verb := range d.opts.resourceVerbs(ResourceTypeDeployment.Id) {
 		ent := entitlement.NewPermissionEntitlement(
 			resource,
 			verb,
@@ -163,14 +167,25 @@ func (d *deploymentBuilder) Entitlements(ctx context.Context, resource *v2.Resou
 	return entitlements, "", nil, nil
 }
 
-// Grants returns no grants for Deployment resources.
+// Grants returns the runs_as grant of the service account the Deployment runs as.
 func (d *deploymentBuilder) Grants(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
-	return nil, "", nil, nil
+	grants, err := workloadRunsAsGrants(ctx, resource, func(namespace, name string) (*corev1.PodSpec, error) {
+		workload, err := d.client.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return &workload.Spec.Template.Spec, nil
+	})
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return grants, "", nil, nil
 }
 
 // newDeploymentBuilder creates a new deployment builder.
-func newDeploymentBuilder(client kubernetes.Interface) *deploymentBuilder {
+func newDeploymentBuilder(client kubernetes.Interface, opts ConnectorOpts) *deploymentBuilder {
 	return &deploymentBuilder{
 		client: client,
+		opts:   opts,
 	}
 }
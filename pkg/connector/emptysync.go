@@ -0,0 +1,122 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrEmptySync is returned when a sync finds no namespaces or no roles, which almost always means the
+// connector lacks permissions or is misconfigured rather than that the cluster is empty.
+var ErrEmptySync = status.Error(codes.PermissionDenied, "sync found no objects")
+
+// emptySyncGuard fails the sync when no namespaces, or no roles and cluster roles, are listed. Kubernetes
+// clusters always have the built-in namespaces and ClusterRoles, so none being visible points at a
+// permission or filter problem. Roles listed as the children of the namespaces aren't checked, the SDK listing
+// them after the top-level listings complete.
+type emptySyncGuard struct {
+	opts  ConnectorOpts
+	stats *syncStats
+
+	mu        sync.Mutex
+	listed    map[string]int64
+	completed map[string]bool
+}
+
+// newEmptySyncGuard creates a guard recording the listed resources in the given stats.
+func newEmptySyncGuard(opts ConnectorOpts, stats *syncStats) *emptySyncGuard {
+	return &emptySyncGuard{
+		opts:      opts,
+		stats:     stats,
+		listed:    make(map[string]int64),
+		completed: make(map[string]bool),
+	}
+}
+
+// listStarted resets the count of a resource type when its listing starts over in a new sync.
+func (g *emptySyncGuard) listStarted(_ context.Context, resourceTypeID string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.listed[resourceTypeID] = 0
+	g.completed[resourceTypeID] = false
+}
+
+// listCompleted checks the counts once every page of a resource type has been listed.
+func (g *emptySyncGuard) listCompleted(_ context.Context, resourceTypeID string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.completed[resourceTypeID] = true
+
+	switch resourceTypeID {
+	case ResourceTypeNamespace.Id:
+		if g.listed[ResourceTypeNamespace.Id] == 0 {
+			return fmt.Errorf("%w: no namespaces are visible to the connector, check its RBAC permissions "+
+				"and namespace filters, or allow empty syncs if the cluster is genuinely empty", ErrEmptySync)
+		}
+	case ResourceTypeRole.Id, ResourceTypeClusterRole.Id:
+		// Roles listed as the children of the namespaces are listed after the top-level listings, and aren't
+		// counted: the namespaces check covers them
+		var checked bool
+		var listed int64
+		for _, id := range []string{ResourceTypeRole.Id, ResourceTypeClusterRole.Id} {
+			if !g.opts.syncsResourceType(id) || g.opts.listedAsNamespaceChildren(id) {
+				continue
+			}
+			if !g.completed[id] {
+				return nil
+			}
+			checked = true
+			listed += g.listed[id]
+		}
+		if checked && listed == 0 {
+			return fmt.Errorf("%w: no roles or cluster roles are visible to the connector, check its RBAC "+
+				"permissions, or allow empty syncs if the cluster is genuinely empty", ErrEmptySync)
+		}
+	}
+
+	return nil
+}
+
+// inboundResourceID returns the ID unchanged.
+func (g *emptySyncGuard) inboundResourceID(id *v2.ResourceId) *v2.ResourceId {
+	return id
+}
+
+// inboundResource returns the resource unchanged.
+func (g *emptySyncGuard) inboundResource(resource *v2.Resource) *v2.Resource {
+	return resource
+}
+
+// outboundResource counts the listed resources, not counting wildcard resources.
+func (g *emptySyncGuard) outboundResource(resource *v2.Resource) (*v2.Resource, error) {
+	if isWildcardResourceID(resource.GetId().GetResource()) {
+		return resource, nil
+	}
+	resourceTypeID := resource.GetId().GetResourceType()
+
+	g.mu.Lock()
+	g.listed[resourceTypeID]++
+	g.mu.Unlock()
+	g.stats.Inc(StatResourcesListedPrefix + resourceTypeID)
+
+	return resource, nil
+}
+
+// outboundEntitlement returns the entitlement unchanged.
+func (g *emptySyncGuard) outboundEntitlement(ent *v2.Entitlement) (*v2.Entitlement, error) {
+	return ent, nil
+}
+
+// outboundGrant returns the grant unchanged.
+func (g *emptySyncGuard) outboundGrant(grant *v2.Grant) (*v2.Grant, error) {
+	return grant, nil
+}
+
+// readOnly reports that the guard doesn't prevent provisioning.
+func (g *emptySyncGuard) readOnly() bool {
+	return false
+}
@@ -0,0 +1,122 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// newTestKubernetes creates a connector around a fake client with the given options.
+func newTestKubernetes(client *fake.Clientset, opts ConnectorOpts) *Kubernetes {
+	k := &Kubernetes{
+		client: client,
+		opts:   opts,
+		stats:  newSyncStats(),
+	}
+	k.namespaces = newNamespaceSnapshot(client, opts, nil)
+	k.clusterRoles = newClusterRoleCache(client, opts)
+	if opts.Redact != nil {
+		k.redactor = newNameRedactor(opts.Redact)
+	}
+	if !opts.AllowEmptySync {
+		k.emptySyncGuard = newEmptySyncGuard(opts, k.stats)
+	}
+	return k
+}
+
+// listAll lists every page of a syncer.
+func listAll(ctx context.Context, syncer connectorbuilder.ResourceSyncer) error {
+	token := &pagination.Token{}
+	for {
+		_, next, _, err := syncer.List(ctx, nil, token)
+		if err != nil {
+			return err
+		}
+		if next == "" {
+			return nil
+		}
+		token = &pagination.Token{Token: next}
+	}
+}
+
+func TestEmptySyncGuard_Namespaces(t *testing.T) {
+	ctx := context.Background()
+
+	// No namespaces visible fails the sync by default
+	client := fake.NewSimpleClientset()
+	k := newTestKubernetes(client, ConnectorOpts{})
+	syncers := k.wrapSyncers(ctx, []connectorbuilder.ResourceSyncer{newNamespaceBuilder(client, nil, k.opts, nil)})
+	err := listAll(ctx, syncers[0])
+	require.ErrorIs(t, err, ErrEmptySync)
+
+	// Allowing empty syncs lets it succeed
+	k = newTestKubernetes(client, ConnectorOpts{AllowEmptySync: true})
+	syncers = k.wrapSyncers(ctx, []connectorbuilder.ResourceSyncer{newNamespaceBuilder(client, nil, k.opts, nil)})
+	require.NoError(t, listAll(ctx, syncers[0]))
+
+	// The wildcard namespace doesn't count, a real one does
+	client = fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
+	k = newTestKubernetes(client, ConnectorOpts{})
+	syncers = k.wrapSyncers(ctx, []connectorbuilder.ResourceSyncer{newNamespaceBuilder(client, nil, k.opts, nil)})
+	require.NoError(t, listAll(ctx, syncers[0]))
+	assert.Equal(t, int64(1), k.SyncStats()[StatResourcesListedPrefix+ResourceTypeNamespace.Id])
+}
+
+func TestEmptySyncGuard_Roles(t *testing.T) {
+	ctx := context.Background()
+
+	client := fake.NewSimpleClientset()
+	k := newTestKubernetes(client, ConnectorOpts{})
+	syncers := k.wrapSyncers(ctx, []connectorbuilder.ResourceSyncer{
+		newRoleBuilder(client, newMockRoleBindingProvider(), k.opts, k.stats),
+		newClusterRoleBuilder(client, newMockClusterRoleBindingProvider(), k.opts, k.stats),
+	})
+
+	// The check waits until both role types have been listed
+	require.NoError(t, listAll(ctx, syncers[0]))
+	require.ErrorIs(t, listAll(ctx, syncers[1]), ErrEmptySync)
+
+	// A single cluster role is enough
+	client = fake.NewSimpleClientset(&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "cluster-admin"}})
+	k = newTestKubernetes(client, ConnectorOpts{})
+	syncers = k.wrapSyncers(ctx, []connectorbuilder.ResourceSyncer{
+		newRoleBuilder(client, newMockRoleBindingProvider(), k.opts, k.stats),
+		newClusterRoleBuilder(client, newMockClusterRoleBindingProvider(), k.opts, k.stats),
+	})
+	require.NoError(t, listAll(ctx, syncers[0]))
+	require.NoError(t, listAll(ctx, syncers[1]))
+
+	// Listing the roles of a namespace without any doesn't count as listing the roles again
+	client = fake.NewSimpleClientset(&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "reader"}})
+	k = newTestKubernetes(client, ConnectorOpts{SyncResources: []string{ResourceTypeRole.Id}})
+	syncers = k.wrapSyncers(ctx, []connectorbuilder.ResourceSyncer{newRoleBuilder(client, newMockRoleBindingProvider(), k.opts, k.stats)})
+	require.NoError(t, listAll(ctx, syncers[0]))
+	emptyNamespace := &v2.ResourceId{ResourceType: ResourceTypeNamespace.Id, Resource: "empty"}
+	_, _, _, err := syncers[0].List(ctx, emptyNamespace, &pagination.Token{})
+	require.NoError(t, err)
+
+	// Roles listed as the children of the namespaces are left to the namespaces check
+	client = fake.NewSimpleClientset()
+	k = newTestKubernetes(client, ConnectorOpts{SyncResources: []string{ResourceTypeNamespace.Id, ResourceTypeRole.Id}})
+	syncers = k.wrapSyncers(ctx, []connectorbuilder.ResourceSyncer{newRoleBuilder(client, newMockRoleBindingProvider(), k.opts, k.stats)})
+	require.NoError(t, listAll(ctx, syncers[0]))
+
+	// Allowing empty syncs lets it succeed
+	client = fake.NewSimpleClientset()
+	k = newTestKubernetes(client, ConnectorOpts{AllowEmptySync: true})
+	syncers = k.wrapSyncers(ctx, []connectorbuilder.ResourceSyncer{
+		newRoleBuilder(client, newMockRoleBindingProvider(), k.opts, k.stats),
+		newClusterRoleBuilder(client, newMockClusterRoleBindingProvider(), k.opts, k.stats),
+	})
+	require.NoError(t, listAll(ctx, syncers[0]))
+	require.NoError(t, listAll(ctx, syncers[1]))
+}
@@ -0,0 +1,42 @@
+package connector
+
+import (
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// Typed errors the connector fails with. They carry gRPC codes so that they can still be told apart after
+// crossing the connector service boundary.
+var (
+	// ErrUnauthorized is returned when the Kubernetes API rejects the connector's credentials.
+	ErrUnauthorized = status.Error(codes.Unauthenticated, "unauthorized access to Kubernetes API")
+	// ErrForbidden is returned when the connector lacks the RBAC permissions for a request.
+	ErrForbidden = status.Error(codes.PermissionDenied, "forbidden access to Kubernetes API (check RBAC permissions)")
+	// ErrGrantNotPermitted is returned when the connector isn't allowed to create the binding a grant needs.
+	ErrGrantNotPermitted = status.Error(codes.PermissionDenied, "the connector isn't permitted to provision the grant")
+	// ErrClusterChanged is returned when the connector is configured for another cluster than previous runs synced.
+	ErrClusterChanged = status.Error(codes.FailedPrecondition, "the synced cluster changed since the previous run")
+	// ErrPartialSync is returned when the sync completed but couldn't read parts of the cluster.
+	ErrPartialSync = status.Error(codes.DataLoss, "sync is incomplete")
+)
+
+// classifyKubeError wraps authentication and authorization errors from the Kubernetes API in the matching
+// typed error, leaving other errors unchanged.
+func classifyKubeError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrUnauthorized), errors.Is(err, ErrForbidden):
+		return err
+	case k8serrors.IsUnauthorized(err):
+		return fmt.Errorf("%w: %w", ErrUnauthorized, err)
+	case k8serrors.IsForbidden(err):
+		return fmt.Errorf("%w: %w", ErrForbidden, err)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,47 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+	"github.com/stretchr/testify/require"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// TestWrapSyncers_ClassifiesKubeErrors tests that authentication and authorization failures of the
+// Kubernetes API surface as the typed errors.
+func TestWrapSyncers_ClassifiesKubeErrors(t *testing.T) {
+	testCases := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{name: "unauthorized", err: k8serrors.NewUnauthorized("token expired"), want: ErrUnauthorized},
+		{
+			name: "forbidden",
+			err:  k8serrors.NewForbidden(schema.GroupResource{Resource: "namespaces"}, "", errors.New("denied")),
+			want: ErrForbidden,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset()
+			client.PrependReactor("list", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				return true, nil, tc.err
+			})
+
+			k := newTestKubernetes(client, ConnectorOpts{})
+			syncers := k.wrapSyncers(context.Background(), []connectorbuilder.ResourceSyncer{newNamespaceBuilder(client, nil, k.opts, nil)})
+			err := listAll(context.Background(), syncers[0])
+			require.ErrorIs(t, err, tc.want)
+			require.True(t, k8serrors.ReasonForError(err) != "", "the Kubernetes error should still be wrapped")
+		})
+	}
+}
@@ -0,0 +1,308 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+)
+
+// explainablePrincipalTypes are the resource types whose role memberships can be explained.
+var explainablePrincipalTypes = []*v2.ResourceType{
+	ResourceTypeServiceAccount,
+	ResourceTypeKubeUser,
+	ResourceTypeKubeSystemUser,
+	ResourceTypeKubeGroup,
+}
+
+// PrincipalExplanation lists the roles a principal is a member of, how, and the permissions they grant.
+type PrincipalExplanation struct {
+	Principal   *v2.ResourceId
+	Memberships []ExplainedMembership
+}
+
+// ExplainedMembership is a membership of a Role or ClusterRole.
+type ExplainedMembership struct {
+	// Role is the Role or ClusterRole.
+	Role *v2.ResourceId
+	// Entitlement is the membership entitlement, e.g. member or <namespace>:member.
+	Entitlement string
+	// BindingKind, BindingNamespace and BindingName identify the binding granting the membership, if any.
+	BindingKind      string
+	BindingNamespace string
+	BindingName      string
+	// ViaGroup is the service account group the membership is inherited through, if any.
+	ViaGroup string
+	// ViaAggregate is the aggregated ClusterRole the membership is inherited from, if any.
+	ViaAggregate string
+	// Implicit is set for memberships Kubernetes hard-codes rather than derives from a binding.
+	Implicit bool
+	// Permissions are the permissions the rules of the role grant.
+	Permissions []ExplainedPermission
+}
+
+// ExplainedPermission is the set of entitlements a role grants on a resource, "*" denoting every resource of
+// the type.
+type ExplainedPermission struct {
+	Resource     *v2.ResourceId
+	Entitlements []string
+}
+
+// ParsePrincipal parses a principal given as <resource type>:<resource ID>, e.g. service_account:payments/deployer.
+// Users and groups may be given by name, e.g. kube_group:github_team://1234, or by the resource ID encoding it.
+func ParsePrincipal(principal string) (*v2.ResourceId, error) {
+	resourceType, resource, ok := strings.Cut(principal, ":")
+	if !ok || resource == "" {
+		return nil, fmt.Errorf("invalid principal %q, expected <type>:<id>, e.g. service_account:payments/deployer", principal)
+	}
+	if isSubjectResourceType(resourceType) {
+		if name, err := subjectName(resource); err == nil {
+			resource = name
+		}
+		resource = subjectResourceID(resource)
+	}
+	for _, rt := range explainablePrincipalTypes {
+		if rt.Id == resourceType {
+			return &v2.ResourceId{ResourceType: resourceType, Resource: resource}, nil
+		}
+	}
+	var types []string
+	for _, rt := range explainablePrincipalTypes {
+		types = append(types, rt.Id)
+	}
+	return nil, fmt.Errorf("invalid principal %q: unsupported type %q, expected one of %s", principal, resourceType, strings.Join(types, ", "))
+}
+
+// ExplainPrincipal syncs the Roles and ClusterRoles with the connector's syncers and returns the memberships
+// of the principal and the permissions they grant. Memberships users and groups get from identity providers
+// are unknown to the cluster and not included.
+func (k *Kubernetes) ExplainPrincipal(ctx context.Context, principal *v2.ResourceId) (*PrincipalExplanation, error) {
+	clusterRoles := newClusterRoleBuilder(k.client, k, k.opts, k.stats)
+	clusterRoles.clusterRoles = k.clusterRoles
+	clusterRoles.progress = k.progress
+	syncers := []connectorbuilder.ResourceSyncer{
+		newRoleBuilder(k.client, k, k.opts, k.stats),
+		clusterRoles,
+	}
+
+	rv := &PrincipalExplanation{Principal: principal}
+	permissions := make(map[string]map[string]map[string]bool) // role -> resource -> entitlements
+	for _, syncer := range syncers {
+		resources, err := k.listSyncedResources(ctx, syncer)
+		if err != nil {
+			return nil, err
+		}
+		for _, resource := range resources {
+			grants, err := listAllGrants(ctx, syncer, resource)
+			if err != nil {
+				return nil, err
+			}
+			for _, g := range grants {
+				if err := rv.addGrant(g, principal, permissions); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	for i := range rv.Memberships {
+		m := &rv.Memberships[i]
+		m.Permissions = explainedPermissions(permissions[resourceIDKey(m.Role)], m.namespace())
+	}
+	sort.SliceStable(rv.Memberships, func(i, j int) bool {
+		return resourceIDKey(rv.Memberships[i].Role) < resourceIDKey(rv.Memberships[j].Role)
+	})
+	return rv, nil
+}
+
+// addGrant records a membership grant of the principal, or a permission grant of a role.
+func (e *PrincipalExplanation) addGrant(g *v2.Grant, principal *v2.ResourceId, permissions map[string]map[string]map[string]bool) error {
+	grantPrincipal := g.GetPrincipal().GetId()
+	target := g.GetEntitlement().GetResource().GetId()
+	slug := grantEntitlementSlug(g)
+
+	switch grantPrincipal.GetResourceType() {
+	case ResourceTypeRole.Id, ResourceTypeClusterRole.Id:
+		roleKey := resourceIDKey(grantPrincipal)
+		if permissions[roleKey] == nil {
+			permissions[roleKey] = make(map[string]map[string]bool)
+		}
+		targetKey := resourceIDKey(target)
+		if permissions[roleKey][targetKey] == nil {
+			permissions[roleKey][targetKey] = make(map[string]bool)
+		}
+		permissions[roleKey][targetKey][slug] = true
+		return nil
+	}
+
+	if grantPrincipal.GetResourceType() != principal.ResourceType || grantPrincipal.GetResource() != principal.Resource {
+		return nil
+	}
+
+	membership := ExplainedMembership{Role: target, Entitlement: slug}
+	ref, ok, err := bindingRefFromGrant(g)
+	if err != nil {
+		return err
+	}
+	if ok {
+		membership.BindingKind = ref.kind
+		membership.BindingNamespace = ref.namespace
+		membership.BindingName = ref.name
+		membership.ViaGroup = ref.viaGroup
+		membership.ViaAggregate = ref.viaAggregate
+	}
+	implicit, err := isImplicitGrant(g)
+	if err != nil {
+		return err
+	}
+	membership.Implicit = implicit
+	e.Memberships = append(e.Memberships, membership)
+	return nil
+}
+
+// Write prints the explanation in a human-readable form.
+func (e *PrincipalExplanation) Write(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Principal %s\n", resourceIDKey(e.Principal))
+	if len(e.Memberships) == 0 {
+		b.WriteString("  No role memberships found\n")
+	}
+	for _, m := range e.Memberships {
+		fmt.Fprintf(&b, "  %s, entitlement %s\n", resourceIDKey(m.Role), m.Entitlement)
+		switch {
+		case m.Implicit:
+			b.WriteString("    implicit, hard-coded in Kubernetes\n")
+		case m.BindingKind != "" && m.BindingNamespace != "":
+			fmt.Fprintf(&b, "    via %s %s/%s\n", m.BindingKind, m.BindingNamespace, m.BindingName)
+		case m.BindingKind != "":
+			fmt.Fprintf(&b, "    via %s %s\n", m.BindingKind, m.BindingName)
+		}
+		if m.ViaGroup != "" {
+			fmt.Fprintf(&b, "    inherited through group %s\n", m.ViaGroup)
+		}
+		if m.ViaAggregate != "" {
+			fmt.Fprintf(&b, "    inherited from aggregated cluster role %s\n", m.ViaAggregate)
+		}
+		if len(m.Permissions) == 0 {
+			b.WriteString("    no permissions on synced resources\n")
+			continue
+		}
+		b.WriteString("    permissions:\n")
+		for _, p := range m.Permissions {
+			fmt.Fprintf(&b, "      %s: %s\n", resourceIDKey(p.Resource), strings.Join(p.Entitlements, ", "))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// namespace returns the namespace a ClusterRole membership from a RoleBinding is limited to, or "" if the
+// membership isn't limited to a namespace.
+func (m *ExplainedMembership) namespace() string {
+	if m.Role.GetResourceType() == ResourceTypeClusterRole.Id && m.BindingKind == BindingKindRoleBinding {
+		return m.BindingNamespace
+	}
+	return ""
+}
+
+// explainedPermissions returns the permissions of a role sorted by resource and entitlement. When namespace
+// is set, the named objects of other namespaces and cluster-scoped objects are left out, as a RoleBinding only
+// grants the rules of a ClusterRole within its namespace.
+func explainedPermissions(byResource map[string]map[string]bool, namespace string) []ExplainedPermission {
+	keys := make([]string, 0, len(byResource))
+	for key := range byResource {
+		_, resource, _ := strings.Cut(key, ":")
+		if namespace != "" && resource != "*" && !strings.HasPrefix(resource, namespace+"/") {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	rv := make([]ExplainedPermission, 0, len(keys))
+	for _, key := range keys {
+		resourceType, resource, _ := strings.Cut(key, ":")
+		entitlements := make([]string, 0, len(byResource[key]))
+		for ent := range byResource[key] {
+			entitlements = append(entitlements, ent)
+		}
+		sort.Strings(entitlements)
+		rv = append(rv, ExplainedPermission{
+			Resource:     &v2.ResourceId{ResourceType: resourceType, Resource: resource},
+			Entitlements: entitlements,
+		})
+	}
+	return rv
+}
+
+// grantEntitlementSlug returns the slug of a grant's entitlement, whose ID is <type>:<resource>:<slug>.
+func grantEntitlementSlug(g *v2.Grant) string {
+	target := g.GetEntitlement().GetResource().GetId()
+	return strings.TrimPrefix(g.GetEntitlement().GetId(), resourceIDKey(target)+":")
+}
+
+// resourceIDKey returns a resource ID as <type>:<resource>.
+func resourceIDKey(id *v2.ResourceId) string {
+	return id.GetResourceType() + ":" + id.GetResource()
+}
+
+// listSyncedResources lists every resource of a syncer, listing the children of every namespace too when the
+// syncer's objects are listed as the children of the namespaces.
+func (k *Kubernetes) listSyncedResources(ctx context.Context, syncer connectorbuilder.ResourceSyncer) ([]*v2.Resource, error) {
+	rv, err := listAllResources(ctx, syncer, nil)
+	if err != nil || !k.opts.listedAsNamespaceChildren(syncer.ResourceType(ctx).Id) {
+		return rv, err
+	}
+
+	namespaces, err := listNamespaceNames(ctx, k.client, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+	for _, namespace := range namespaces {
+		children, err := listAllResources(ctx, syncer, &v2.ResourceId{ResourceType: ResourceTypeNamespace.Id, Resource: namespace})
+		if err != nil {
+			return nil, err
+		}
+		rv = append(rv, children...)
+	}
+	return rv, nil
+}
+
+// listAllResources lists every page of a syncer, at the top level or the children of the parent.
+func listAllResources(ctx context.Context, syncer connectorbuilder.ResourceSyncer, parent *v2.ResourceId) ([]*v2.Resource, error) {
+	var rv []*v2.Resource
+	token := &pagination.Token{}
+	for {
+		resources, next, _, err := syncer.List(ctx, parent, token)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", syncer.ResourceType(ctx).Id, err)
+		}
+		rv = append(rv, resources...)
+		if next == "" {
+			return rv, nil
+		}
+		token = &pagination.Token{Token: next}
+	}
+}
+
+// listAllGrants lists every page of the grants of a resource.
+func listAllGrants(ctx context.Context, syncer connectorbuilder.ResourceSyncer, resource *v2.Resource) ([]*v2.Grant, error) {
+	var rv []*v2.Grant
+	token := &pagination.Token{}
+	for {
+		grants, next, _, err := syncer.Grants(ctx, resource, token)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list grants of %s: %w", resourceIDKey(resource.Id), err)
+		}
+		rv = append(rv, grants...)
+		if next == "" {
+			return rv, nil
+		}
+		token = &pagination.Token{Token: next}
+	}
+}
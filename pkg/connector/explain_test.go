@@ -0,0 +1,140 @@
+package connector
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// explainClient returns a client where payments/deployer holds a Role through a RoleBinding, and a ClusterRole
+// both cluster-wide and, for another ClusterRole, limited to its namespace through a RoleBinding.
+func explainClient() *fake.Clientset {
+	deployer := []rbacv1.Subject{{Kind: SubjectKindServiceAccount, Namespace: "payments", Name: "deployer"}}
+	return fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "payments"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "shop"}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "db-creds"}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "db-creds"}},
+		&rbacv1.Role{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "secret-reader"},
+			Rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{""}, Resources: []string{"secrets"}, ResourceNames: []string{"db-creds"}, Verbs: []string{"get", "watch"}},
+			},
+		},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "deployer-secrets"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindRole, Name: "secret-reader"},
+			Subjects:   deployer,
+		},
+		&rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-viewer"},
+			Rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{""}, Resources: []string{"nodes"}, Verbs: []string{"list"}},
+			},
+		},
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "deployer-nodes"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindClusterRole, Name: "node-viewer"},
+			Subjects:   deployer,
+		},
+		&rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{Name: "secret-admin"},
+			Rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{""}, Resources: []string{"secrets"}, ResourceNames: []string{"db-creds"}, Verbs: []string{"delete"}},
+			},
+		},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "deployer-secret-admin"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindClusterRole, Name: "secret-admin"},
+			Subjects:   deployer,
+		},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "other-secret-admin"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindClusterRole, Name: "secret-admin"},
+			Subjects:   []rbacv1.Subject{{Kind: SubjectKindServiceAccount, Namespace: "shop", Name: "admin"}},
+		},
+	)
+}
+
+func TestExplainPrincipal(t *testing.T) {
+	ctx := context.Background()
+	k := newTestKubernetes(explainClient(), ConnectorOpts{AllowEmptySync: true})
+
+	principal, err := ParsePrincipal("service_account:payments/deployer")
+	require.NoError(t, err)
+	explanation, err := k.ExplainPrincipal(ctx, principal)
+	require.NoError(t, err)
+
+	require.Len(t, explanation.Memberships, 3)
+	nodes, secretAdmin, secretReader := explanation.Memberships[0], explanation.Memberships[1], explanation.Memberships[2]
+
+	assert.Equal(t, "cluster_role:node-viewer", resourceIDKey(nodes.Role))
+	assert.Equal(t, BindingKindClusterRoleBinding, nodes.BindingKind)
+	assert.Equal(t, "deployer-nodes", nodes.BindingName)
+	assert.Equal(t, []ExplainedPermission{
+		{Resource: &v2.ResourceId{ResourceType: ResourceTypeNode.Id, Resource: "*"}, Entitlements: []string{"list"}},
+	}, nodes.Permissions)
+
+	// The RoleBinding limits the ClusterRole to the secrets of its namespace
+	assert.Equal(t, "cluster_role:secret-admin", resourceIDKey(secretAdmin.Role))
+	assert.Equal(t, "payments:member", secretAdmin.Entitlement)
+	assert.Equal(t, BindingKindRoleBinding, secretAdmin.BindingKind)
+	assert.Equal(t, "payments", secretAdmin.BindingNamespace)
+	assert.Equal(t, []ExplainedPermission{
+		{Resource: &v2.ResourceId{ResourceType: ResourceTypeSecret.Id, Resource: "payments/db-creds"}, Entitlements: []string{"delete"}},
+	}, secretAdmin.Permissions)
+
+	assert.Equal(t, "role:payments/secret-reader", resourceIDKey(secretReader.Role))
+	assert.Equal(t, "deployer-secrets", secretReader.BindingName)
+	assert.Equal(t, []ExplainedPermission{
+		{Resource: &v2.ResourceId{ResourceType: ResourceTypeSecret.Id, Resource: "payments/db-creds"}, Entitlements: []string{"get", "watch"}},
+	}, secretReader.Permissions)
+
+	var out strings.Builder
+	require.NoError(t, explanation.Write(&out))
+	assert.Contains(t, out.String(), "Principal service_account:payments/deployer\n")
+	assert.Contains(t, out.String(), "  role:payments/secret-reader, entitlement member\n"+
+		"    via RoleBinding payments/deployer-secrets\n"+
+		"    permissions:\n"+
+		"      secret:payments/db-creds: get, watch\n")
+}
+
+func TestExplainPrincipal_NoMemberships(t *testing.T) {
+	ctx := context.Background()
+	k := newTestKubernetes(explainClient(), ConnectorOpts{AllowEmptySync: true})
+
+	explanation, err := k.ExplainPrincipal(ctx, &v2.ResourceId{ResourceType: ResourceTypeKubeUser.Id, Resource: "nobody"})
+	require.NoError(t, err)
+	assert.Empty(t, explanation.Memberships)
+
+	var out strings.Builder
+	require.NoError(t, explanation.Write(&out))
+	assert.Equal(t, "Principal kube_user:nobody\n  No role memberships found\n", out.String())
+}
+
+func TestParsePrincipal(t *testing.T) {
+	id, err := ParsePrincipal("kube_system_user:system:kube-scheduler")
+	require.NoError(t, err)
+	assert.Equal(t, ResourceTypeKubeSystemUser.Id, id.ResourceType)
+	assert.Equal(t, "system:kube-scheduler", id.Resource)
+
+	// Users and groups are parsed by name or by encoded ID alike
+	for _, principal := range []string{"kube_group:oidc:platform/admins", "kube_group:oidc:platform%2Fadmins"} {
+		id, err = ParsePrincipal(principal)
+		require.NoError(t, err)
+		assert.Equal(t, "oidc:platform%2Fadmins", id.Resource, principal)
+	}
+
+	for _, principal := range []string{"", "deployer", "service_account:", "secret:payments/db-creds"} {
+		_, err := ParsePrincipal(principal)
+		assert.Error(t, err, principal)
+	}
+}
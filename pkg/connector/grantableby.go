@@ -0,0 +1,216 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ProfileGrantableBy is the profile key of the principals able to grant a Role, as "<resource type>:<id>".
+	ProfileGrantableBy = "grantableBy"
+	// ProfileGrantableByTruncated is set on Roles whose grantableBy list was capped.
+	ProfileGrantableByTruncated = "grantableByTruncated"
+	// roleGrantableByLimit is the maximum number of principals listed in the grantableBy field of a Role.
+	roleGrantableByLimit = 100
+)
+
+// roleBindingWriteVerbs are the verbs on rolebindings that let a subject give a role to someone else.
+var roleBindingWriteVerbs = []string{"create", "update"}
+
+// allNamespaces keys the grantors whose permissions apply in every namespace, from ClusterRoleBindings.
+const allNamespaces = ""
+
+// roleGrantorIndex records who can write RoleBindings and who can bind which Roles, per namespace, to tell
+// who can grant a Role: a principal needs both in the Role's namespace. The principals are keyed by
+// resourceIDKey.
+type roleGrantorIndex struct {
+	// bindingWriters are the principals able to create or update RoleBindings, by namespace.
+	bindingWriters map[string]map[string]bool
+	// binders are the principals able to bind or escalate Roles, by namespace and Role name or "*".
+	binders map[string]map[string]map[string]bool
+}
+
+// newRoleGrantorIndex computes the index from every Role, ClusterRole and binding. Only bindings grant these
+// permissions: the implicit access of system:masters and the escalation check letting subjects bind roles
+// whose permissions they already hold aren't accounted for.
+func newRoleGrantorIndex(roles []rbacv1.Role, clusterRoles []rbacv1.ClusterRole, rbs []rbacv1.RoleBinding,
+	crbs []rbacv1.ClusterRoleBinding, opts ConnectorOpts) *roleGrantorIndex {
+	idx := &roleGrantorIndex{
+		bindingWriters: make(map[string]map[string]bool),
+		binders:        make(map[string]map[string]map[string]bool),
+	}
+
+	roleRules := make(map[string][]rbacv1.PolicyRule, len(roles))
+	for _, role := range roles {
+		roleRules[namespacedName(role.Namespace, role.Name)] = role.Rules
+	}
+	clusterRoleRules := make(map[string][]rbacv1.PolicyRule, len(clusterRoles))
+	for _, clusterRole := range clusterRoles {
+		clusterRoleRules[clusterRole.Name] = clusterRole.Rules
+	}
+
+	for _, rb := range rbs {
+		var rules []rbacv1.PolicyRule
+		if rb.RoleRef.Kind == RoleRefKindRole {
+			rules = roleRules[namespacedName(rb.Namespace, rb.RoleRef.Name)]
+		} else {
+			rules = clusterRoleRules[rb.RoleRef.Name]
+		}
+		idx.add(rb.Namespace, rules, rb.Subjects, opts)
+	}
+	for _, crb := range crbs {
+		idx.add(allNamespaces, clusterRoleRules[crb.RoleRef.Name], crb.Subjects, opts)
+	}
+	return idx
+}
+
+// add records the permissions the rules give the subjects in a namespace, or every namespace.
+func (idx *roleGrantorIndex) add(namespace string, rules []rbacv1.PolicyRule, subjects []rbacv1.Subject, opts ConnectorOpts) {
+	var writesBindings bool
+	var boundRoles []string
+	for _, rule := range rules {
+		if !slices.Contains(rule.APIGroups, rbacv1.APIGroupAll) && !slices.Contains(rule.APIGroups, RBACAPIGroup) {
+			continue
+		}
+		if ruleCoversResource(rule, RoleBindings) && len(rule.ResourceNames) == 0 && grantsAnyVerb(rule.Verbs, roleBindingWriteVerbs) {
+			writesBindings = true
+		}
+		if ruleCoversResource(rule, "roles") && grantsAnyVerb(rule.Verbs, roleEscalationVerbs) {
+			if len(rule.ResourceNames) == 0 {
+				boundRoles = append(boundRoles, rbacv1.ResourceAll)
+			} else {
+				boundRoles = append(boundRoles, rule.ResourceNames...)
+			}
+		}
+	}
+	if !writesBindings && len(boundRoles) == 0 {
+		return
+	}
+
+	for _, subject := range subjects {
+		principal, err := subjectPrincipalID(subject, opts)
+		if err != nil {
+			continue
+		}
+		key := resourceIDKey(principal)
+		if writesBindings {
+			addToSet(idx.bindingWriters, namespace, key)
+		}
+		for _, role := range boundRoles {
+			if idx.binders[namespace] == nil {
+				idx.binders[namespace] = make(map[string]map[string]bool)
+			}
+			addToSet(idx.binders[namespace], role, key)
+		}
+	}
+}
+
+// grantors returns the sorted principals able to grant the Role with the given namespace and name.
+func (idx *roleGrantorIndex) grantors(namespace, name string) []string {
+	rv := make([]string, 0)
+	for _, ns := range []string{namespace, allNamespaces} {
+		for key := range idx.bindingWriters[ns] {
+			if idx.canBind(key, namespace, name) && !slices.Contains(rv, key) {
+				rv = append(rv, key)
+			}
+		}
+	}
+	sort.Strings(rv)
+	return rv
+}
+
+// canBind reports whether a principal can bind or escalate the Role with the given namespace and name.
+func (idx *roleGrantorIndex) canBind(key, namespace, name string) bool {
+	for _, ns := range []string{namespace, allNamespaces} {
+		for _, role := range []string{name, rbacv1.ResourceAll} {
+			if idx.binders[ns][role][key] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ruleCoversResource reports whether a rule applies to a resource, directly or through "*".
+func ruleCoversResource(rule rbacv1.PolicyRule, resource string) bool {
+	return slices.Contains(rule.Resources, rbacv1.ResourceAll) || slices.Contains(rule.Resources, resource)
+}
+
+// addToSet adds a value to the set stored under a key, creating the set if needed.
+func addToSet(sets map[string]map[string]bool, key, value string) {
+	if sets[key] == nil {
+		sets[key] = make(map[string]bool)
+	}
+	sets[key][value] = true
+}
+
+// GetRoleGrantors returns the principals able to grant the given Role, as "<resource type>:<id>". The index
+// is computed from every Role, ClusterRole and binding the first time it's needed, and kept for the sync.
+func (k *Kubernetes) GetRoleGrantors(ctx context.Context, namespace, roleName string) ([]string, error) {
+	k.grantorsMutex.Lock()
+	defer k.grantorsMutex.Unlock()
+
+	if k.grantors == nil {
+		idx, err := k.loadRoleGrantorIndex(ctx)
+		if err != nil {
+			return nil, err
+		}
+		k.grantors = idx
+	}
+	return k.grantors.grantors(namespace, roleName), nil
+}
+
+// loadRoleGrantorIndex lists the Roles and ClusterRoles and computes the grantors index with the bindings caches.
+func (k *Kubernetes) loadRoleGrantorIndex(ctx context.Context) (*roleGrantorIndex, error) {
+	if err := k.loadBindingsCaches(ctx); err != nil {
+		return nil, fmt.Errorf("failed to load bindings cache: %w", err)
+	}
+
+	var roles []rbacv1.Role
+	err := listPages(k.opts.pageSize(ResourceTypeRole.Id), func(opts metav1.ListOptions) (string, error) {
+		resp, err := k.client.RbacV1().Roles("").List(ctx, opts)
+		if err != nil {
+			return "", fmt.Errorf("failed to list roles: %w", err)
+		}
+		roles = append(roles, resp.Items...)
+		return resp.Continue, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var clusterRoles []rbacv1.ClusterRole
+	err = listPages(k.opts.pageSize(ResourceTypeClusterRole.Id), func(opts metav1.ListOptions) (string, error) {
+		resp, err := k.client.RbacV1().ClusterRoles().List(ctx, opts)
+		if err != nil {
+			return "", fmt.Errorf("failed to list cluster roles: %w", err)
+		}
+		clusterRoles = append(clusterRoles, resp.Items...)
+		return resp.Continue, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	k.bindingsMutex.RLock()
+	defer k.bindingsMutex.RUnlock()
+	return newRoleGrantorIndex(roles, clusterRoles, k.roleBindingsCache, k.clusterRoleBindingsCache, k.opts), nil
+}
+
+// addGrantableByProfile records up to roleGrantableByLimit of the principals able to grant a Role in its profile.
+func addGrantableByProfile(profile map[string]interface{}, grantableBy []string) {
+	if grantableBy == nil {
+		return
+	}
+	listed := grantableBy
+	if len(listed) > roleGrantableByLimit {
+		listed = listed[:roleGrantableByLimit]
+		profile[ProfileGrantableByTruncated] = true
+	}
+	profile[ProfileGrantableBy] = stringsToInterfaces(listed)
+}
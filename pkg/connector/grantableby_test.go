@@ -0,0 +1,111 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// grantableByTestClient returns a cluster where some service accounts can grant the payments/reader Role and
+// others hold only part of the permissions needed.
+func grantableByTestClient() *fake.Clientset {
+	sa := func(namespace, name string) rbacv1.Subject {
+		return rbacv1.Subject{Kind: SubjectKindServiceAccount, Namespace: namespace, Name: name}
+	}
+	roleBinding := func(name, kind, role string, subject rbacv1.Subject) *rbacv1.RoleBinding {
+		return &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: name},
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: kind, Name: role},
+			Subjects:   []rbacv1.Subject{subject},
+		}
+	}
+	createRoleBindings := rbacv1.PolicyRule{APIGroups: []string{RBACAPIGroup}, Resources: []string{RoleBindings}, Verbs: []string{"create"}}
+	bindReader := rbacv1.PolicyRule{APIGroups: []string{RBACAPIGroup}, Resources: []string{"roles"}, ResourceNames: []string{"reader"}, Verbs: []string{"bind"}}
+
+	return fake.NewSimpleClientset(
+		&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "reader"}},
+		&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "writer"}},
+		&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "reader-granter"},
+			Rules: []rbacv1.PolicyRule{createRoleBindings, bindReader}},
+		&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "reader-binder"},
+			Rules: []rbacv1.PolicyRule{bindReader}},
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "rolebinding-creator"},
+			Rules: []rbacv1.PolicyRule{createRoleBindings}},
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "rbac-admin"},
+			Rules: []rbacv1.PolicyRule{{APIGroups: []string{RBACAPIGroup}, Resources: []string{"*"}, Verbs: []string{"*"}}}},
+		// Can create role bindings and bind the reader role in the namespace
+		roleBinding("deployer", RoleRefKindRole, "reader-granter", sa("ci", "deployer")),
+		// Can bind the reader role, but not create role bindings
+		roleBinding("binder", RoleRefKindRole, "reader-binder", sa("ci", "binder")),
+		// Can create role bindings, but not bind the reader role
+		roleBinding("creator", RoleRefKindClusterRole, "rolebinding-creator", sa("ci", "creator")),
+		// Can do both in every namespace
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "rbac-admins"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindClusterRole, Name: "rbac-admin"},
+			Subjects:   []rbacv1.Subject{{Kind: SubjectKindGroup, APIGroup: RBACAPIGroup, Name: "rbac-admins"}},
+		},
+	)
+}
+
+// TestRoleBuilderList_GrantableBy tests that the profile of each Role lists the principals able to both create
+// role bindings in its namespace and bind it.
+func TestRoleBuilderList_GrantableBy(t *testing.T) {
+	ctx := context.Background()
+	client := grantableByTestClient()
+	k := newTestKubernetes(client, ConnectorOpts{})
+	builder := newRoleBuilder(client, k, ConnectorOpts{DisableWildcardResources: true}, nil)
+	builder.grantorProvider = k
+
+	resources, _, _, err := builder.List(ctx, namespaceID("payments"), &pagination.Token{})
+	require.NoError(t, err)
+	grantableBy := make(map[string]interface{})
+	for _, resource := range resources {
+		roleTrait, err := rs.GetRoleTrait(resource)
+		require.NoError(t, err)
+		grantableBy[resource.Id.Resource] = roleTrait.Profile.AsMap()[ProfileGrantableBy]
+	}
+
+	assert.Equal(t, []interface{}{"kube_group:rbac-admins", "service_account:ci/deployer"}, grantableBy["payments/reader"])
+	assert.Equal(t, []interface{}{"kube_group:rbac-admins"}, grantableBy["payments/writer"])
+}
+
+// TestRoleBuilderList_GrantableByDisabled tests that the grantors aren't computed unless enabled.
+func TestRoleBuilderList_GrantableByDisabled(t *testing.T) {
+	ctx := context.Background()
+	client := grantableByTestClient()
+	builder := newRoleBuilder(client, newTestKubernetes(client, ConnectorOpts{}), ConnectorOpts{DisableWildcardResources: true}, nil)
+
+	resources, _, _, err := builder.List(ctx, namespaceID("payments"), &pagination.Token{})
+	require.NoError(t, err)
+	for _, resource := range resources {
+		roleTrait, err := rs.GetRoleTrait(resource)
+		require.NoError(t, err)
+		assert.NotContains(t, roleTrait.Profile.AsMap(), ProfileGrantableBy)
+	}
+}
+
+func TestAddGrantableByProfile_Truncated(t *testing.T) {
+	grantors := make([]string, 0, roleGrantableByLimit+1)
+	for i := 0; i <= roleGrantableByLimit; i++ {
+		grantors = append(grantors, fmt.Sprintf("kube_user:user-%03d", i))
+	}
+
+	profile := map[string]interface{}{}
+	addGrantableByProfile(profile, grantors)
+	assert.Len(t, profile[ProfileGrantableBy], roleGrantableByLimit)
+	assert.Equal(t, true, profile[ProfileGrantableByTruncated])
+
+	profile = map[string]interface{}{}
+	addGrantableByProfile(profile, []string{})
+	assert.Equal(t, []interface{}{}, profile[ProfileGrantableBy])
+	assert.NotContains(t, profile, ProfileGrantableByTruncated)
+}
@@ -0,0 +1,122 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// checkCanBind verifies that the connector may create the binding of the role to the subject, in the namespace
+// or cluster-wide if namespace is empty, before Grant writes it. It fails with ErrGrantNotPermitted naming the
+// missing permission rather than letting the API server reject the write.
+//
+// Creating the binding requires create on the binding type. The RBAC escalation rules also require the
+// connector to either be allowed to bind the role, or to hold every permission the role grants. The former is
+// a single access review; the latter is checked with a server-side dry run of the binding, which runs the same
+// escalation check as the real write and whose error lists the permissions the connector doesn't hold.
+func checkCanBind(ctx context.Context, client kubernetes.Interface, roleRef rbacv1.RoleRef, subject rbacv1.Subject, namespace string) error {
+	bindingResource := "clusterrolebindings"
+	if namespace != "" {
+		bindingResource = "rolebindings"
+	}
+	allowed, err := reviewSelfAccess(ctx, client, authorizationv1.ResourceAttributes{
+		Namespace: namespace,
+		Verb:      "create",
+		Group:     RBACAPIGroup,
+		Resource:  bindingResource,
+	})
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return fmt.Errorf("%w: missing permission to create %s.%s%s", ErrGrantNotPermitted, bindingResource, RBACAPIGroup, inNamespace(namespace))
+	}
+
+	roleResource := "clusterroles"
+	if roleRef.Kind == RoleRefKindRole {
+		roleResource = "roles"
+	}
+	allowed, err = reviewSelfAccess(ctx, client, authorizationv1.ResourceAttributes{
+		Namespace: namespace,
+		Verb:      "bind",
+		Group:     RBACAPIGroup,
+		Resource:  roleResource,
+		Name:      roleRef.Name,
+	})
+	if err != nil {
+		return err
+	}
+	if allowed {
+		return nil
+	}
+
+	ctxzap.Extract(ctx).Debug("connector can't bind the role, checking it holds the role's permissions",
+		zap.String("kind", roleRef.Kind),
+		zap.String("role", roleRef.Name),
+		zap.String("namespace", namespace))
+
+	err = dryRunBinding(ctx, client, roleRef, subject, namespace)
+	switch {
+	case err == nil, k8serrors.IsAlreadyExists(err):
+		return nil
+	case k8serrors.IsForbidden(err):
+		return fmt.Errorf("%w: binding %s %s%s would escalate privileges, the connector needs bind on %s.%s %s "+
+			"or every permission of the role: %v", ErrGrantNotPermitted, roleRef.Kind, roleRef.Name, inNamespace(namespace),
+			roleResource, RBACAPIGroup, roleRef.Name, err)
+	default:
+		// Other failures, such as a missing namespace, are reported by the write itself
+		return nil
+	}
+}
+
+// reviewSelfAccess reports whether the connector is allowed the access.
+func reviewSelfAccess(ctx context.Context, client kubernetes.Interface, attributes authorizationv1.ResourceAttributes) (bool, error) {
+	review := &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &attributes,
+		},
+	}
+
+	resp, err := client.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to review access to %s %s.%s: %w", attributes.Verb, attributes.Resource, attributes.Group, err)
+	}
+	return resp.Status.Allowed, nil
+}
+
+// dryRunBinding creates the binding Grant would create in dry-run mode, so nothing is persisted.
+func dryRunBinding(ctx context.Context, client kubernetes.Interface, roleRef rbacv1.RoleRef, subject rbacv1.Subject, namespace string) error {
+	meta := managedBindingMeta(managedBindingName(roleRef, subject), namespace)
+	createOpts := metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}}
+
+	var err error
+	if namespace == "" {
+		_, err = client.RbacV1().ClusterRoleBindings().Create(ctx, &rbacv1.ClusterRoleBinding{
+			ObjectMeta: meta,
+			RoleRef:    roleRef,
+			Subjects:   []rbacv1.Subject{subject},
+		}, createOpts)
+	} else {
+		_, err = client.RbacV1().RoleBindings(namespace).Create(ctx, &rbacv1.RoleBinding{
+			ObjectMeta: meta,
+			RoleRef:    roleRef,
+			Subjects:   []rbacv1.Subject{subject},
+		}, createOpts)
+	}
+	return err
+}
+
+// inNamespace returns " in namespace <namespace>", or "" for cluster-wide access.
+func inNamespace(namespace string) string {
+	if namespace == "" {
+		return ""
+	}
+	return fmt.Sprintf(" in namespace %s", namespace)
+}
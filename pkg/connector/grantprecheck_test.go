@@ -0,0 +1,161 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// reviewAccess answers the connector's access reviews with allowed.
+func reviewAccess(client *fake.Clientset, allowed func(authorizationv1.ResourceAttributes) bool) {
+	client.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview).DeepCopy()
+		review.Status.Allowed = allowed(*review.Spec.ResourceAttributes)
+		return true, review, nil
+	})
+}
+
+// reactToDryRuns answers dry-run creates of bindings with err, or as if they succeeded if err is nil, and
+// returns a counter of them. The fake clientset would persist them otherwise.
+func reactToDryRuns(client *fake.Clientset, err error) *int {
+	dryRuns := 0
+	for _, resource := range []string{"rolebindings", "clusterrolebindings"} {
+		client.PrependReactor("create", resource, func(action k8stesting.Action) (bool, runtime.Object, error) {
+			create := action.(k8stesting.CreateActionImpl)
+			if len(create.CreateOptions.DryRun) == 0 {
+				return false, nil, nil
+			}
+			dryRuns++
+			if err != nil {
+				return true, nil, err
+			}
+			return true, create.GetObject(), nil
+		})
+	}
+	return &dryRuns
+}
+
+func TestClusterRoleBuilderGrant_PreCheck(t *testing.T) {
+	ctx := context.Background()
+	escalation := k8serrors.NewForbidden(rbacv1.Resource("rolebindings"), "baton-view", assert.AnError)
+	escalation.ErrStatus.Message = `user "baton" is attempting to grant RBAC permissions not currently held: {APIGroups:[""], Resources:["secrets"], Verbs:["get"]}`
+
+	tests := []struct {
+		name     string
+		opts     ConnectorOpts
+		allowed  func(authorizationv1.ResourceAttributes) bool
+		dryRun   error
+		wantErr  string
+		dryRuns  int
+		bindings int
+	}{
+		{
+			name:     "allowed to bind",
+			allowed:  func(authorizationv1.ResourceAttributes) bool { return true },
+			bindings: 1,
+		},
+		{
+			name: "holds the permissions of the role",
+			allowed: func(attributes authorizationv1.ResourceAttributes) bool {
+				return attributes.Verb == "create"
+			},
+			dryRuns:  1,
+			bindings: 1,
+		},
+		{
+			name:    "can't create bindings",
+			allowed: func(authorizationv1.ResourceAttributes) bool { return false },
+			wantErr: "missing permission to create rolebindings.rbac.authorization.k8s.io in namespace team-a",
+		},
+		{
+			name: "escalation denied",
+			allowed: func(attributes authorizationv1.ResourceAttributes) bool {
+				return attributes.Verb == "create"
+			},
+			dryRun:  escalation,
+			wantErr: "needs bind on clusterroles.rbac.authorization.k8s.io view or every permission of the role",
+			dryRuns: 1,
+		},
+		{
+			name:     "skipped",
+			opts:     ConnectorOpts{SkipGrantPreCheck: true},
+			allowed:  func(authorizationv1.ResourceAttributes) bool { return false },
+			bindings: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset(
+				&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "view"}},
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}},
+			)
+			reviews := 0
+			reviewAccess(client, func(attributes authorizationv1.ResourceAttributes) bool {
+				reviews++
+				assert.Equal(t, RBACAPIGroup, attributes.Group)
+				assert.Equal(t, "team-a", attributes.Namespace)
+				if attributes.Verb == "bind" {
+					assert.Equal(t, "clusterroles", attributes.Resource)
+					assert.Equal(t, "view", attributes.Name)
+				}
+				return tt.allowed(attributes)
+			})
+			dryRuns := reactToDryRuns(client, tt.dryRun)
+
+			builder := newClusterRoleBuilder(client, newMockClusterRoleBindingProvider(), tt.opts, nil)
+			clusterRole := GenerateResourceForGrant("view", ResourceTypeClusterRole.Id)
+			_, err := builder.Grant(ctx, GenerateResourceForGrant("team-a/deployer", ResourceTypeServiceAccount.Id), &v2.Entitlement{
+				Id:       "cluster_role:view:team-a:member",
+				Resource: clusterRole,
+			})
+
+			if tt.wantErr != "" {
+				require.ErrorIs(t, err, ErrGrantNotPermitted)
+				assert.Equal(t, codes.PermissionDenied, status.Code(err))
+				assert.Contains(t, err.Error(), tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			if tt.dryRun != nil {
+				assert.Contains(t, err.Error(), "secrets")
+			}
+			if tt.opts.SkipGrantPreCheck {
+				assert.Equal(t, 0, reviews)
+			}
+			assert.Equal(t, tt.dryRuns, *dryRuns)
+
+			bindings, err := client.RbacV1().RoleBindings("team-a").List(ctx, metav1.ListOptions{})
+			require.NoError(t, err)
+			assert.Len(t, bindings.Items, tt.bindings)
+		})
+	}
+}
+
+func TestCheckCanBind_ClusterWide(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset()
+	reviewAccess(client, func(attributes authorizationv1.ResourceAttributes) bool {
+		return attributes.Resource != "clusterrolebindings"
+	})
+
+	roleRef := rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindClusterRole, Name: "view"}
+	subject := rbacv1.Subject{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "alice"}
+	err := checkCanBind(ctx, client, roleRef, subject, "")
+	require.ErrorIs(t, err, ErrGrantNotPermitted)
+	assert.Contains(t, err.Error(), "missing permission to create clusterrolebindings.rbac.authorization.k8s.io")
+	assert.NotContains(t, err.Error(), "namespace")
+}
@@ -0,0 +1,277 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+// GrantSource contributes grants that don't come from RBAC, such as the access an operator managing its own
+// custom resources confers, to the resources synced by the connector.
+//
+// The contract of a source is:
+//   - ResourceTypes returns the IDs of the resource types the source has grants for. It's called once, when
+//     the syncers are created.
+//   - GrantsForResource returns a page of grants on the entitlements of the resource and the token of the next
+//     page, or "" after the last page. It's called for every resource of the declared types after the builder
+//     has returned all of its grants, starting with an empty page token.
+//   - The resource is the one the builder synced, before any redaction, and so are the grants expected back:
+//     the connector redacts them like the builder's own.
+//   - Grants must be on an entitlement the builder declares for the resource, such as "member" on a role.
+//     Grants on other resources or unknown entitlements are dropped with a warning, so that a source can't
+//     create dangling entitlements.
+//   - Grants with the ID of a grant the builder or an earlier page already returned for the resource are
+//     dropped, so sources can return grants RBAC also confers.
+//   - Errors fail the sync of the resource's grants, like the errors of the builder.
+type GrantSource interface {
+	// ResourceTypes returns the IDs of the resource types the source contributes grants to.
+	ResourceTypes() []string
+	// GrantsForResource returns a page of the grants of the source on the entitlements of the resource.
+	GrantsForResource(ctx context.Context, resource *v2.Resource, pToken *pagination.Token) ([]*v2.Grant, string, error)
+}
+
+// Page state types of the grants of a syncer with grant sources.
+const (
+	builderGrantsPage = "builder"
+	sourceGrantsPage  = "source"
+)
+
+// grantSourcesFor returns the sources contributing grants to the resource type.
+func grantSourcesFor(sources []GrantSource, resourceTypeID string) []GrantSource {
+	var rv []GrantSource
+	for _, source := range sources {
+		if slices.Contains(source.ResourceTypes(), resourceTypeID) {
+			rv = append(rv, source)
+		}
+	}
+	return rv
+}
+
+// resourceGrantsState tracks the grants of a resource across the pages of its builder and sources.
+type resourceGrantsState struct {
+	// seen are the IDs of the grants returned so far.
+	seen map[string]bool
+	// entitlements are the IDs of the entitlements the builder declares for the resource, loaded on the first
+	// page of the sources.
+	entitlements map[string]bool
+}
+
+// grantSourceSyncer merges the grants of grant sources after those of a syncer.
+type grantSourceSyncer struct {
+	connectorbuilder.ResourceSyncer
+	sources []GrantSource
+
+	mu     sync.Mutex
+	states map[string]*resourceGrantsState
+}
+
+// grantSourceProvisioner is a grantSourceSyncer that also passes provisioning through to the wrapped syncer.
+type grantSourceProvisioner struct {
+	*grantSourceSyncer
+	provisioner connectorbuilder.ResourceProvisioner
+}
+
+// withGrantSources adds the grants of the sources to a syncer, keeping provisioning when the syncer supports
+// it. The syncer is returned as is without sources, so that its page tokens don't change.
+func withGrantSources(syncer connectorbuilder.ResourceSyncer, sources []GrantSource) connectorbuilder.ResourceSyncer {
+	if len(sources) == 0 {
+		return syncer
+	}
+	s := &grantSourceSyncer{
+		ResourceSyncer: syncer,
+		sources:        sources,
+		states:         make(map[string]*resourceGrantsState),
+	}
+	if provisioner, ok := syncer.(connectorbuilder.ResourceProvisioner); ok {
+		return &grantSourceProvisioner{grantSourceSyncer: s, provisioner: provisioner}
+	}
+	return s
+}
+
+// Grants returns the pages of grants of the wrapped syncer, then those of each source in turn.
+func (s *grantSourceSyncer) Grants(ctx context.Context, resource *v2.Resource, pToken *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	bag, err := ParsePageToken(pToken.Token)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to parse page token: %w", err)
+	}
+	if bag.Current() == nil {
+		for i := len(s.sources) - 1; i >= 0; i-- {
+			bag.Push(pagination.PageState{ResourceTypeID: sourceGrantsPage, ResourceID: strconv.Itoa(i)})
+		}
+		bag.Push(pagination.PageState{ResourceTypeID: builderGrantsPage})
+		s.resetState(resource)
+	}
+
+	page := &pagination.Token{Size: pToken.Size, Token: bag.PageToken()}
+	var (
+		grants []*v2.Grant
+		next   string
+		annos  annotations.Annotations
+	)
+	switch bag.ResourceTypeID() {
+	case builderGrantsPage:
+		grants, next, annos, err = s.ResourceSyncer.Grants(ctx, resource, page)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		grants = s.unseen(resource, grants)
+	case sourceGrantsPage:
+		i, err := strconv.Atoi(bag.ResourceID())
+		if err != nil || i < 0 || i >= len(s.sources) {
+			return nil, "", nil, fmt.Errorf("invalid grant source in page token: %q", bag.ResourceID())
+		}
+		grants, next, err = s.sources[i].GrantsForResource(ctx, resource, page)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("failed to get grants from grant source %d: %w", i, err)
+		}
+		grants, err = s.validGrants(ctx, resource, grants)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		grants = s.unseen(resource, grants)
+	default:
+		return nil, "", nil, fmt.Errorf("invalid grants page token: %q", pToken.Token)
+	}
+
+	if err := bag.Next(next); err != nil {
+		return nil, "", nil, fmt.Errorf("failed to advance page token: %w", err)
+	}
+	nextPageToken, err := bag.Marshal()
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to marshal page token: %w", err)
+	}
+	if nextPageToken == "" {
+		s.clearState(resource)
+	}
+
+	return grants, nextPageToken, annos, nil
+}
+
+// Grant passes the grant through to the wrapped syncer.
+func (p *grantSourceProvisioner) Grant(ctx context.Context, principal *v2.Resource, ent *v2.Entitlement) (annotations.Annotations, error) {
+	return p.provisioner.Grant(ctx, principal, ent)
+}
+
+// Revoke passes the revoke through to the wrapped syncer. Grants from grant sources can't be revoked, as the
+// builder doesn't know them.
+func (p *grantSourceProvisioner) Revoke(ctx context.Context, g *v2.Grant) (annotations.Annotations, error) {
+	return p.provisioner.Revoke(ctx, g)
+}
+
+// resourceKey returns the key of a resource in the grants states.
+func resourceKey(resource *v2.Resource) string {
+	return resource.GetId().GetResourceType() + ":" + resource.GetId().GetResource()
+}
+
+// resetState starts tracking the grants of a resource from its first page.
+func (s *grantSourceSyncer) resetState(resource *v2.Resource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states[resourceKey(resource)] = &resourceGrantsState{seen: make(map[string]bool)}
+}
+
+// clearState stops tracking the grants of a resource after its last page.
+func (s *grantSourceSyncer) clearState(resource *v2.Resource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.states, resourceKey(resource))
+}
+
+// state returns the grants state of a resource. A sync resumed after a restart has no state for the resource
+// it was on, which then starts over without the grants of the pages returned before the restart.
+func (s *grantSourceSyncer) state(resource *v2.Resource) *resourceGrantsState {
+	key := resourceKey(resource)
+	state, ok := s.states[key]
+	if !ok {
+		state = &resourceGrantsState{seen: make(map[string]bool)}
+		s.states[key] = state
+	}
+	return state
+}
+
+// unseen drops the grants already returned for the resource and records the others.
+func (s *grantSourceSyncer) unseen(resource *v2.Resource, grants []*v2.Grant) []*v2.Grant {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := s.state(resource)
+
+	rv := grants[:0]
+	for _, g := range grants {
+		if state.seen[g.GetId()] {
+			continue
+		}
+		state.seen[g.GetId()] = true
+		rv = append(rv, g)
+	}
+	return rv
+}
+
+// validGrants drops the grants of a source that aren't on an entitlement the wrapped syncer declares for the
+// resource or have no principal.
+func (s *grantSourceSyncer) validGrants(ctx context.Context, resource *v2.Resource, grants []*v2.Grant) ([]*v2.Grant, error) {
+	l := ctxzap.Extract(ctx)
+
+	entitlements, err := s.declaredEntitlements(ctx, resource)
+	if err != nil {
+		return nil, err
+	}
+
+	var rv []*v2.Grant
+	for _, g := range grants {
+		entitlementID := g.GetEntitlement().GetId()
+		switch {
+		case g.GetPrincipal().GetId() == nil:
+			l.Warn("dropping grant source grant without principal",
+				zap.String("resource", resourceKey(resource)),
+				zap.String("entitlement", entitlementID))
+		case !entitlements[entitlementID] || resourceKey(g.GetEntitlement().GetResource()) != resourceKey(resource):
+			l.Warn("dropping grant source grant on an entitlement not declared for the resource",
+				zap.String("resource", resourceKey(resource)),
+				zap.String("entitlement", entitlementID))
+		default:
+			rv = append(rv, g)
+		}
+	}
+	return rv, nil
+}
+
+// declaredEntitlements returns the IDs of the entitlements the wrapped syncer declares for the resource,
+// listing them on the first call for the resource.
+func (s *grantSourceSyncer) declaredEntitlements(ctx context.Context, resource *v2.Resource) (map[string]bool, error) {
+	s.mu.Lock()
+	entitlements := s.state(resource).entitlements
+	s.mu.Unlock()
+	if entitlements != nil {
+		return entitlements, nil
+	}
+
+	entitlements = make(map[string]bool)
+	pageToken := ""
+	for {
+		page, next, _, err := s.ResourceSyncer.Entitlements(ctx, resource, &pagination.Token{Token: pageToken})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list entitlements to validate grant source grants: %w", err)
+		}
+		for _, ent := range page {
+			entitlements[ent.GetId()] = true
+		}
+		if next == "" {
+			break
+		}
+		pageToken = next
+	}
+
+	s.mu.Lock()
+	s.state(resource).entitlements = entitlements
+	s.mu.Unlock()
+	return entitlements, nil
+}
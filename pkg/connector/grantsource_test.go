@@ -0,0 +1,136 @@
+package connector
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// stubGrantSource returns fixed pages of grants for every resource of its resource types.
+type stubGrantSource struct {
+	resourceTypes []string
+	pages         [][]*v2.Grant
+	calls         int
+}
+
+func (s *stubGrantSource) ResourceTypes() []string {
+	return s.resourceTypes
+}
+
+func (s *stubGrantSource) GrantsForResource(_ context.Context, _ *v2.Resource, pToken *pagination.Token) ([]*v2.Grant, string, error) {
+	s.calls++
+	page := 0
+	if pToken.Token != "" {
+		var err error
+		if page, err = strconv.Atoi(pToken.Token); err != nil {
+			return nil, "", err
+		}
+	}
+	if page+1 < len(s.pages) {
+		return s.pages[page], strconv.Itoa(page + 1), nil
+	}
+	return s.pages[page], "", nil
+}
+
+// TestGrantSources_RoleGrants tests that the grants of a grant source are merged after the grants of the role
+// bindings, without duplicates or grants on entitlements the role doesn't have.
+func TestGrantSources_RoleGrants(t *testing.T) {
+	ctx := context.Background()
+	role := &rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: "deployer", Namespace: "tenants"}}
+	provider := newMockRoleBindingProvider()
+	for _, name := range []string{"alice", "bob"} {
+		provider.addMockBinding("tenants", "deployer", rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: name + "-binding", Namespace: "tenants"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindRole, Name: "deployer"},
+			Subjects:   []rbacv1.Subject{{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: name}},
+		})
+	}
+	resource, err := roleResource(role, ConnectorOpts{}, nil)
+	require.NoError(t, err)
+	other, err := roleResource(&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: "viewer", Namespace: "tenants"}}, ConnectorOpts{}, nil)
+	require.NoError(t, err)
+
+	user := func(name string) *v2.ResourceId {
+		return &v2.ResourceId{ResourceType: ResourceTypeKubeUser.Id, Resource: name}
+	}
+	source := &stubGrantSource{
+		resourceTypes: []string{ResourceTypeRole.Id},
+		pages: [][]*v2.Grant{
+			{
+				grant.NewGrant(resource, "member", user("tenant-admin")),
+				// Also granted by a RoleBinding
+				grant.NewGrant(resource, "member", user("alice")),
+				// Not an entitlement of roles
+				grant.NewGrant(resource, "owner", user("mallory")),
+				// An entitlement of another role
+				grant.NewGrant(other, "member", user("mallory")),
+			},
+			{
+				grant.NewGrant(resource, "member", &v2.ResourceId{ResourceType: ResourceTypeKubeGroup.Id, Resource: "tenant-operators"}),
+				grant.NewGrant(resource, "bind", user("tenant-admin")),
+			},
+		},
+	}
+
+	client := fake.NewSimpleClientset(role)
+	k := newTestKubernetes(client, ConnectorOpts{GrantsPageSize: 1, GrantSources: []GrantSource{source}})
+	syncers := k.wrapSyncers(ctx, []connectorbuilder.ResourceSyncer{
+		newRoleBuilder(client, provider, k.opts, nil),
+		newNamespaceBuilder(client, nil, k.opts, nil),
+	})
+
+	var ids []string
+	pageToken := ""
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 10, "grants pagination should end")
+		grants, next, _, err := syncers[0].Grants(ctx, resource, &pagination.Token{Token: pageToken})
+		require.NoError(t, err)
+		ids = append(ids, grantIDs(grants)...)
+		if next == "" {
+			break
+		}
+		pageToken = next
+	}
+
+	assert.Equal(t, []string{
+		"role:tenants/deployer:member:kube_user:alice",
+		"role:tenants/deployer:member:kube_user:bob",
+		"role:tenants/deployer:member:kube_user:tenant-admin",
+		"role:tenants/deployer:member:kube_group:tenant-operators",
+		"role:tenants/deployer:bind:kube_user:tenant-admin",
+	}, ids)
+	assert.Equal(t, 2, source.calls)
+
+	// Namespaces aren't a resource type of the source
+	_, _, _, err = syncers[1].Grants(ctx, &v2.Resource{Id: &v2.ResourceId{ResourceType: ResourceTypeNamespace.Id, Resource: "tenants"}}, &pagination.Token{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, source.calls)
+}
+
+// TestGrantSources_KeepProvisioning tests that adding grant sources to a syncer keeps its provisioning.
+func TestGrantSources_KeepProvisioning(t *testing.T) {
+	source := &stubGrantSource{resourceTypes: []string{ResourceTypeClusterRole.Id}}
+	builder := newClusterRoleBuilder(fake.NewSimpleClientset(), newMockClusterRoleBindingProvider(), ConnectorOpts{}, nil)
+
+	_, ok := withGrantSources(builder, []GrantSource{source}).(connectorbuilder.ResourceProvisioner)
+	assert.True(t, ok)
+}
+
+// grantIDs returns the IDs of the given grants.
+func grantIDs(grants []*v2.Grant) []string {
+	ids := make([]string, 0, len(grants))
+	for _, g := range grants {
+		ids = append(ids, g.Id)
+	}
+	return ids
+}
@@ -0,0 +1,154 @@
+package connector
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// groupMembership holds the members of Kubernetes groups read from a mapping file, as Kubernetes has no group
+// objects to list them from. The file is re-read at the start of each sync. A nil *groupMembership is valid
+// and has no groups.
+type groupMembership struct {
+	path string
+
+	mu      sync.RWMutex
+	members map[string][]string // group name -> sorted usernames
+	loaded  bool
+}
+
+// newGroupMembership creates the membership read from the file at path, or returns nil if path is empty.
+func newGroupMembership(path string) *groupMembership {
+	if path == "" {
+		return nil
+	}
+	return &groupMembership{path: path}
+}
+
+// parseGroupMembership parses a YAML or JSON mapping of group names to lists of usernames, like
+//
+//	platform-admins:
+//	  - alice@example.com
+//	  - bob@example.com
+//
+// The usernames of each group are deduplicated and sorted.
+func parseGroupMembership(data []byte) (map[string][]string, error) {
+	var raw map[string][]string
+	if err := yaml.UnmarshalStrict(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse group membership: %w", err)
+	}
+
+	members := make(map[string][]string, len(raw))
+	for group, usernames := range raw {
+		group = strings.TrimSpace(group)
+		if group == "" {
+			return nil, fmt.Errorf("group membership has a group with an empty name")
+		}
+		seen := make(map[string]bool, len(usernames))
+		for _, username := range usernames {
+			username = strings.TrimSpace(username)
+			if username == "" {
+				return nil, fmt.Errorf("group %s has a member with an empty name", group)
+			}
+			if !seen[username] {
+				seen[username] = true
+				members[group] = append(members[group], username)
+			}
+		}
+		if members[group] == nil {
+			members[group] = []string{}
+		}
+		sort.Strings(members[group])
+	}
+	return members, nil
+}
+
+// reload re-reads the mapping file, so that a sync sees the membership as of its start.
+func (m *groupMembership) reload() error {
+	if m == nil {
+		return nil
+	}
+	data, err := os.ReadFile(m.path)
+	if err != nil {
+		return fmt.Errorf("failed to read group membership file: %w", err)
+	}
+	members, err := parseGroupMembership(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", m.path, err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.members = members
+	m.loaded = true
+	return nil
+}
+
+// ensureLoaded reads the mapping file if it hasn't been yet, for grants computed before any List.
+func (m *groupMembership) ensureLoaded() error {
+	if m == nil {
+		return nil
+	}
+	m.mu.RLock()
+	loaded := m.loaded
+	m.mu.RUnlock()
+	if loaded {
+		return nil
+	}
+	return m.reload()
+}
+
+// groups returns the sorted names of the groups in the mapping.
+func (m *groupMembership) groups() []string {
+	if m == nil {
+		return nil
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	rv := make([]string, 0, len(m.members))
+	for group := range m.members {
+		rv = append(rv, group)
+	}
+	sort.Strings(rv)
+	return rv
+}
+
+// usernames returns the sorted usernames that are members of any group in the mapping.
+func (m *groupMembership) usernames() []string {
+	if m == nil {
+		return nil
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	seen := make(map[string]bool)
+	var rv []string
+	for _, usernames := range m.members {
+		for _, username := range usernames {
+			if !seen[username] {
+				seen[username] = true
+				rv = append(rv, username)
+			}
+		}
+	}
+	sort.Strings(rv)
+	return rv
+}
+
+// groupMembers returns the usernames of the members of a group, as User subjects.
+func (m *groupMembership) groupMembers(group string) []rbacv1.Subject {
+	if m == nil {
+		return nil
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	subjects := make([]rbacv1.Subject, 0, len(m.members[group]))
+	for _, username := range m.members[group] {
+		subjects = append(subjects, rbacv1.Subject{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: username})
+	}
+	return subjects
+}
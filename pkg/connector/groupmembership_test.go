@@ -0,0 +1,132 @@
+package connector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseGroupMembership(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    map[string][]string
+		wantErr string
+	}{
+		{
+			name: "yaml",
+			data: "platform-admins:\n  - bob@example.com\n  - alice@example.com\n  - bob@example.com\nauditors: []\n",
+			want: map[string][]string{"platform-admins": {"alice@example.com", "bob@example.com"}, "auditors": {}},
+		},
+		{
+			name: "json",
+			data: `{"oidc:developers": ["carol@example.com"]}`,
+			want: map[string][]string{"oidc:developers": {"carol@example.com"}},
+		},
+		{
+			name: "empty",
+			data: "",
+			want: map[string][]string{},
+		},
+		{
+			name:    "not a mapping",
+			data:    "- alice@example.com\n",
+			wantErr: "failed to parse group membership",
+		},
+		{
+			name:    "member not a string list",
+			data:    "platform-admins: alice@example.com\n",
+			wantErr: "failed to parse group membership",
+		},
+		{
+			name:    "empty member",
+			data:    "platform-admins: [\" \"]\n",
+			wantErr: "group platform-admins has a member with an empty name",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseGroupMembership([]byte(tt.data))
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// TestKubeGroupBuilder_MembershipGrants tests that groups get member grants to the users of the membership
+// file, which are synced as users even if nothing binds them, and that the file is re-read on each sync.
+func TestKubeGroupBuilder_MembershipGrants(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "groups.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("platform-admins:\n  - alice@example.com\n  - bob@example.com\n"), 0o600))
+
+	client := fake.NewSimpleClientset(&rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "platform-admins"},
+		RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindClusterRole, Name: "cluster-admin"},
+		Subjects:   []rbacv1.Subject{{Kind: SubjectKindGroup, APIGroup: RBACAPIGroup, Name: "platform-admins"}},
+	})
+	opts := ConnectorOpts{DisableWildcardResources: true, GroupMembershipFile: path}
+	k := newKubernetes(client, nil, opts)
+	groups := newKubeGroupBuilder(client, k, opts)
+	groups.membership = k.groupMembership
+	users := newKubeUserBuilder(client, k, opts)
+	users.membership = k.groupMembership
+
+	userResources := listResources(ctx, t, users)
+	var usernames []string
+	for _, resource := range userResources {
+		usernames = append(usernames, resource.Id.Resource)
+	}
+	assert.Equal(t, []string{"alice@example.com", "bob@example.com"}, usernames)
+
+	groupResources := listResources(ctx, t, groups)
+	var group *v2.Resource
+	for _, resource := range groupResources {
+		if resource.Id.Resource == "platform-admins" {
+			group = resource
+		}
+	}
+	require.NotNil(t, group)
+
+	members := func() []string {
+		grants, _, _, err := groups.Grants(ctx, group, &pagination.Token{})
+		require.NoError(t, err)
+		var rv []string
+		for _, g := range grants {
+			assert.Equal(t, entitlement.NewEntitlementID(group, KubeGroupMemberEntitlement), g.Entitlement.Id)
+			rv = append(rv, resourceIDKey(g.Principal.Id))
+		}
+		return rv
+	}
+	assert.Equal(t, []string{"kube_user:alice@example.com", "kube_user:bob@example.com"}, members())
+
+	// The next sync sees the updated file
+	require.NoError(t, os.WriteFile(path, []byte("platform-admins:\n  - carol@example.com\n"), 0o600))
+	listResources(ctx, t, groups)
+	assert.Equal(t, []string{"kube_user:carol@example.com"}, members())
+}
+
+// TestKubeGroupBuilder_NoMembership tests that groups have no grants without a membership file.
+func TestKubeGroupBuilder_NoMembership(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset()
+	builder := newKubeGroupBuilder(client, newTestKubernetes(client, ConnectorOpts{}), ConnectorOpts{})
+
+	grants, _, _, err := builder.Grants(ctx, GenerateResourceForGrant("platform-admins", ResourceTypeKubeGroup.Id), &pagination.Token{})
+	require.NoError(t, err)
+	assert.Empty(t, grants)
+}
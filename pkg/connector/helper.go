@@ -1,16 +1,23 @@
 package connector
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/types/entitlement"
 	"github.com/conductorone/baton-sdk/pkg/types/grant"
 	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"go.uber.org/zap"
 	"google.golang.org/protobuf/types/known/structpb"
 	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/client-go/kubernetes"
 )
 
 const (
@@ -20,6 +27,8 @@ const (
 	RBACAPIGroup              = "rbac.authorization.k8s.io"
 	RBACAPIGroupV1            = "rbac.authorization.k8s.io/v1"
 	RoleBindings              = "rolebindings"
+	RoleRefKindRole           = "Role"
+	RoleRefKindClusterRole    = "ClusterRole"
 )
 
 // StringMapToAnyMap converts a map[string]string (like Kubernetes labels and annotations)
@@ -37,6 +46,122 @@ func StringMapToAnyMap(input map[string]string) map[string]any {
 	return result
 }
 
+// sensitiveAnnotations are the annotation keys whose values can hold a full copy of the object they're set on,
+// which for secrets and configmaps includes their data. They're never copied into the profiles of those objects.
+var sensitiveAnnotations = []string{
+	"kubectl.kubernetes.io/last-applied-configuration",
+	"kapp.k14s.io/original",
+	"objectset.rio.cattle.io/applied",
+	"banzaicloud.com/last-applied",
+}
+
+// profileAnnotations converts annotations for a resource profile like StringMapToAnyMap, leaving out the
+// configured RedactAnnotationKeys and, for objects holding secret data, the sensitiveAnnotations.
+func profileAnnotations(annotations map[string]string, opts ConnectorOpts, holdsData bool) map[string]any {
+	result := StringMapToAnyMap(annotations)
+	for _, key := range opts.RedactAnnotationKeys {
+		delete(result, key)
+	}
+	if holdsData {
+		for _, key := range sensitiveAnnotations {
+			delete(result, key)
+		}
+	}
+	return result
+}
+
+// LabelTagPrefix is the profile key prefix under which configured label values are exposed as tags.
+const LabelTagPrefix = "tag."
+
+// LabelTags returns the values of the given label keys as tags keyed by LabelTagPrefix and the
+// sanitized label key, e.g. "tag.team": "payments". Keys missing from the labels are omitted.
+func LabelTags(labels map[string]string, keys []string) map[string]any {
+	tags := make(map[string]any)
+	for _, key := range keys {
+		value, ok := labels[key]
+		if !ok {
+			continue
+		}
+		tags[LabelTagPrefix+sanitizeTagKey(key)] = value
+	}
+	return tags
+}
+
+// sanitizeTagKey replaces characters that can't be used in a flat profile key, such as the "." and "/"
+// of prefixed label keys or invalid UTF-8, with underscores.
+func sanitizeTagKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+}
+
+// addLabelTags adds the configured label tags to a resource profile.
+func addLabelTags(profile map[string]any, labels map[string]string, opts ConnectorOpts) {
+	for k, v := range LabelTags(labels, opts.LabelTags) {
+		profile[k] = v
+	}
+}
+
+// labelTagOptions returns the resource options carrying the configured label tags for resource types
+// without a trait profile, where the tags are attached as a struct annotation.
+func labelTagOptions(labels map[string]string, opts ConnectorOpts) ([]rs.ResourceOption, error) {
+	tags := LabelTags(labels, opts.LabelTags)
+	if len(tags) == 0 {
+		return nil, nil
+	}
+
+	repairProfile(tags)
+	tagStruct, err := structpb.NewStruct(tags)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create label tags: %w", err)
+	}
+
+	return []rs.ResourceOption{rs.WithAnnotation(tagStruct)}, nil
+}
+
+// ProfileRulesLimit is the default maximum number of PolicyRules listed in the profile of a Role or ClusterRole.
+const ProfileRulesLimit = 100
+
+// Keys of the rules in the profiles of Roles and ClusterRoles.
+const (
+	ProfileRules          = "rules"
+	ProfileRulesTruncated = "rulesTruncated"
+)
+
+// ParsePolicyRules marshals PolicyRules to a []interface{} of maps for serialization, with the apiGroups,
+// resources, resourceNames, verbs and nonResourceURLs of each rule.
+func ParsePolicyRules(rules []rbacv1.PolicyRule) ([]interface{}, error) {
+	b, err := json.Marshal(rules)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]interface{}, 0, len(rules))
+	if err := json.Unmarshal(b, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// addRulesProfile adds up to limit of the rules of a role to its profile, flagging whether they were truncated.
+func addRulesProfile(profile map[string]interface{}, rules []rbacv1.PolicyRule, limit int) error {
+	truncated := len(rules) > limit
+	if truncated {
+		rules = rules[:limit]
+	}
+	parsed, err := ParsePolicyRules(rules)
+	if err != nil {
+		return fmt.Errorf("failed to marshal rules: %w", err)
+	}
+	profile[ProfileRules] = parsed
+	profile[ProfileRulesTruncated] = truncated
+	return nil
+}
+
 // ParseAggregationRule marshals an AggregationRule to a map[string]interface{} for serialization.
 func ParseAggregationRule(aggregationRule interface{}) (map[string]interface{}, error) {
 	b, err := json.Marshal(aggregationRule)
@@ -51,16 +176,32 @@ func ParseAggregationRule(aggregationRule interface{}) (map[string]interface{},
 }
 
 // generateWildcardResource creates a special resource that represents all resources of a specific type
-// for use with role permissions that apply to all instances of a resource type.
-func generateWildcardResource(resourceType *v2.ResourceType) (*v2.Resource, error) {
+// for use with role permissions that apply to all instances of a resource type. Given a namespace, it represents
+// all resources of the type in the namespace instead, with the ID <namespace>/* and the namespace as parent.
+func generateWildcardResource(resourceType *v2.ResourceType, namespace string) (*v2.Resource, error) {
 	// Create a resource ID with the wildcard pattern
 	resourceID := "*"
 	displayName := "All " + resourceType.DisplayName
+	uid := "wildcard-" + resourceType.Id
+	scope := ""
+	var options []rs.ResourceOption
+	if namespace != "" {
+		resourceID = namespacedName(namespace, "*")
+		displayName = fmt.Sprintf("All %s in %s", resourceType.DisplayName, namespace)
+		uid += "-" + namespace
+		scope = fmt.Sprintf(" in the %s namespace", namespace)
+
+		parentID, err := NamespaceResourceID(namespace)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create parent resource ID: %w", err)
+		}
+		options = append(options, rs.WithParentResourceID(parentID))
+	}
 
 	// Create basic profile data
 	profile := map[string]interface{}{
 		"name": displayName,
-		"uid":  "wildcard-" + resourceType.Id,
+		"uid":  uid,
 	}
 
 	// Handle different resource types differently to add appropriate traits.
@@ -81,9 +222,11 @@ func generateWildcardResource(resourceType *v2.ResourceType) (*v2.Resource, erro
 			},
 		}
 
-		options := []rs.ResourceOption{
-			rs.WithDescription("Represents all secrets in the cluster"),
+		secretScope := " in the cluster"
+		if scope != "" {
+			secretScope = scope
 		}
+		options = append(options, rs.WithDescription("Represents all secrets"+secretScope))
 
 		return rs.NewSecretResource(
 			displayName,
@@ -105,6 +248,28 @@ func generateWildcardResource(resourceType *v2.ResourceType) (*v2.Resource, erro
 			resourceType,
 			resourceID,
 			userOptions,
+			options...,
+		)
+	case ResourceTypeKubeUser.Id, ResourceTypeKubeSystemUser.Id:
+		// For users, use NewUserResource with UserTrait.
+		return rs.NewUserResource(
+			displayName,
+			resourceType,
+			resourceID,
+			[]rs.UserTraitOption{
+				rs.WithUserProfile(profile),
+				rs.WithStatus(v2.UserTrait_Status_STATUS_ENABLED),
+			},
+			options...,
+		)
+	case ResourceTypeKubeGroup.Id:
+		// For groups, use NewGroupResource with GroupTrait.
+		return rs.NewGroupResource(
+			displayName,
+			resourceType,
+			resourceID,
+			[]rs.GroupTraitOption{rs.WithGroupProfile(profile)},
+			options...,
 		)
 	case ResourceTypeRole.Id, ResourceTypeClusterRole.Id:
 		// For roles, use NewRoleResource with RoleTrait.
@@ -113,19 +278,55 @@ func generateWildcardResource(resourceType *v2.ResourceType) (*v2.Resource, erro
 			resourceType,
 			resourceID,
 			[]rs.RoleTraitOption{rs.WithRoleProfile(profile)},
+			options...,
 		)
 	default:
 		// For other resource types, use standard NewResource.
+		options = append(options, rs.WithDescription("Represents all resources of type "+resourceType.DisplayName+scope))
 		return rs.NewResource(
 			displayName,
 			resourceType,
 			resourceID,
-			rs.WithDescription("Represents all resources of type "+resourceType.DisplayName),
+			options...,
 		)
 	}
 }
 
+// isWildcardResourceID reports whether a raw resource ID is that of a wildcard resource, "*" or, with
+// namespace wildcards, "<namespace>/*". The IDs of users and groups never are, as subjectResourceID encodes
+// the "*" and "/" of their names.
+func isWildcardResourceID(id string) bool {
+	return id == "*" || strings.HasSuffix(id, "/*")
+}
+
+// namespaceWildcardResources returns the wildcard resources of a namespaced resource type in the namespace, or in
+// every namespace when it's "", for the rules of Roles covering all the resources of the type in their namespace.
+func namespaceWildcardResources(ctx context.Context, client kubernetes.Interface, resourceType *v2.ResourceType, namespace string) ([]*v2.Resource, error) {
+	namespaces := []string{namespace}
+	if namespace == "" {
+		var err error
+		namespaces, err = listNamespaceNames(ctx, client, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list namespaces: %w", err)
+		}
+	}
+	rv := make([]*v2.Resource, 0, len(namespaces))
+	for _, namespace := range namespaces {
+		resource, err := generateWildcardResource(resourceType, namespace)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create wildcard resource for %s in namespace %s: %w", resourceType.Id, namespace, err)
+		}
+		rv = append(rv, resource)
+	}
+	return rv, nil
+}
+
+// GenerateResourceForGrant returns a resource of the type with the raw ID rName, for grants on or to it. The
+// names of users and groups are encoded into the IDs of their resources, see subjectResourceID.
 func GenerateResourceForGrant(rName string, rType string) *v2.Resource {
+	if isSubjectResourceType(rType) {
+		rName = subjectResourceID(rName)
+	}
 	return &v2.Resource{
 		Id: &v2.ResourceId{
 			Resource:     rName,
@@ -134,37 +335,157 @@ func GenerateResourceForGrant(rName string, rType string) *v2.Resource {
 	}
 }
 
-func GrantRoleToSubject(subject rbacv1.Subject, resource *v2.Resource, entName string) (*v2.Grant, error) {
-	var grantOpts []grant.GrantOption
-	if subject.Kind == SubjectKindServiceAccount {
-		saName := fmt.Sprintf("%s/%s", subject.Namespace, subject.Name) // SA are always namespaced, even if they can have cluster roles bind to cluster level.
-		saResource := GenerateResourceForGrant(saName, ResourceTypeServiceAccount.Id)
-		g := grant.NewGrant(
-			resource,
-			entName,
-			saResource,
-		)
-		return g, nil
-	} else if (subject.APIGroup == RBACAPIGroup || subject.APIGroup == RBACAPIGroupV1) &&
-		!strings.Contains(subject.Name, "system:") { // Ignore System subjects
-		if subject.Kind == SubjectKindGroup {
-			groupResource := GenerateResourceForGrant(subject.Name, ResourceTypeKubeGroup.Id)
-			g := grant.NewGrant(
-				resource,
-				entName,
-				groupResource,
-			)
-			return g, nil
+// GrantRoleToSubject creates a grant of the named entitlement of the resource to the principal of a binding
+// subject, applying the given grant options. System users and groups are not supported, failing with
+// ErrSystemSubjectSkipped.
+func GrantRoleToSubject(subject rbacv1.Subject, resource *v2.Resource, entName string, grantOpts ...grant.GrantOption) (*v2.Grant, error) {
+	return grantRoleToSubject(subject, resource, entName, ConnectorOpts{}, grantOpts...)
+}
+
+// uniqueGrants drops grants with duplicate IDs, which arise when a subject is bound to a role by several
+// bindings, or both directly and through a service account group. Direct grants are kept over inherited ones,
+// and the bindings of dropped direct grants are merged into the metadata of the grant kept.
+func uniqueGrants(grants []*v2.Grant) []*v2.Grant {
+	index := make(map[string]int, len(grants))
+	duplicates := make(map[int][]*v2.Grant)
+	rv := make([]*v2.Grant, 0, len(grants))
+	for _, g := range grants {
+		i, seen := index[g.Id]
+		switch {
+		case !seen:
+			index[g.Id] = len(rv)
+			rv = append(rv, g)
+		case isInheritedGrant(rv[i]) && !isInheritedGrant(g):
+			rv[i] = g
+		default:
+			duplicates[i] = append(duplicates[i], g)
+		}
+	}
+	for i, dropped := range duplicates {
+		mergeBindingRefs(rv[i], dropped)
+	}
+	return rv
+}
+
+// sortRoleBindings sorts role bindings by namespace and name, so that their grants are paginated in the same
+// order on every page.
+func sortRoleBindings(bindings []rbacv1.RoleBinding) {
+	sort.Slice(bindings, func(i, j int) bool {
+		if bindings[i].Namespace != bindings[j].Namespace {
+			return bindings[i].Namespace < bindings[j].Namespace
 		}
-		if subject.Kind == SubjectKindUser {
-			g := grant.NewGrant(
-				resource,
-				entName,
-				GenerateResourceForGrant(subject.Name, ResourceTypeKubeUser.Id),
-				grantOpts...,
-			)
-			return g, nil
+		return bindings[i].Name < bindings[j].Name
+	})
+}
+
+// sortClusterRoleBindings sorts cluster role bindings by name, so that their grants are paginated in the same
+// order on every page.
+func sortClusterRoleBindings(bindings []rbacv1.ClusterRoleBinding) {
+	sort.Slice(bindings, func(i, j int) bool {
+		return bindings[i].Name < bindings[j].Name
+	})
+}
+
+// isInheritedGrant reports whether a grant was inherited through a service account group or from an
+// aggregated ClusterRole.
+func isInheritedGrant(g *v2.Grant) bool {
+	ref, ok, err := bindingRefFromGrant(g)
+	return err == nil && ok && (ref.viaGroup != "" || ref.viaAggregate != "")
+}
+
+// withSubjectKind records the kind of the binding subject in the grant metadata, merging it into the metadata
+// set by earlier options.
+func withSubjectKind(kind string) grant.GrantOption {
+	return func(g *v2.Grant) error {
+		metadata := &v2.GrantMetadata{}
+		annos := annotations.Annotations(g.Annotations)
+		if _, err := annos.Pick(metadata); err != nil {
+			return err
 		}
+		if metadata.Metadata == nil {
+			metadata.Metadata = &structpb.Struct{}
+		}
+		if metadata.Metadata.Fields == nil {
+			metadata.Metadata.Fields = make(map[string]*structpb.Value)
+		}
+		metadata.Metadata.Fields[GrantMetadataSubjectKind] = structpb.NewStringValue(kind)
+		annos.Update(metadata)
+		g.Annotations = annos
+		return nil
+	}
+}
+
+// ErrSystemSubjectSkipped is returned when a user or group subject with a "system:" name isn't granted because
+// the connector doesn't include system subjects.
+var ErrSystemSubjectSkipped = errors.New("system subject skipped")
+
+// isSystemSubject reports whether a user or group subject is a Kubernetes system identity, whose names are
+// reserved to the "system:" prefix.
+func isSystemSubject(name string) bool {
+	return strings.HasPrefix(name, systemUserPrefix)
+}
+
+// logSkippedSubject logs why a binding subject got no grant, given the error of grantRoleToSubject.
+func logSkippedSubject(l *zap.Logger, subject rbacv1.Subject, err error) {
+	if errors.Is(err, ErrSystemSubjectSkipped) {
+		l.Debug("skipping system subject", zap.String("subject kind", subject.Kind), zap.String("subject", subject.Name))
+		return
+	}
+	l.Debug("subject kind not supported", zap.String("subject kind", subject.Kind))
+}
+
+// isRBACSubjectAPIGroup reports whether a User or Group subject's API group is the RBAC one. The API server
+// also honors subjects that leave it empty, as some controllers and older manifests do.
+func isRBACSubjectAPIGroup(apiGroup string) bool {
+	return apiGroup == "" || apiGroup == RBACAPIGroup || apiGroup == RBACAPIGroupV1
+}
+
+// checkGrantableSubject returns the error grantRoleToSubject fails with for a subject it grants nothing, or nil.
+func checkGrantableSubject(subject rbacv1.Subject, opts ConnectorOpts) error {
+	subject = normalizeSubject(subject)
+	switch {
+	case subject.Kind == SubjectKindServiceAccount:
+		return nil
+	case isRBACSubjectAPIGroup(subject.APIGroup) && (subject.Kind == SubjectKindGroup || subject.Kind == SubjectKindUser):
+		if !opts.IncludeSystemSubjects && isSystemSubject(subject.Name) {
+			return fmt.Errorf("%w: %s %s", ErrSystemSubjectSkipped, subject.Kind, subject.Name)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported subject type")
+	}
+}
+
+// grantRoleToSubject is GrantRoleToSubject honoring the connector options on system users and groups, which are
+// only included with IncludeSystemSubjects and synced as KubeSystemUser resources with SeparateSystemUsers.
+func grantRoleToSubject(subject rbacv1.Subject, resource *v2.Resource, entName string, opts ConnectorOpts, grantOpts ...grant.GrantOption) (*v2.Grant, error) {
+	if err := checkGrantableSubject(subject, opts); err != nil {
+		return nil, err
+	}
+
+	// Service accounts bound by their user name are granted as the service account
+	subject = normalizeSubject(subject)
+	grantOpts = append(grantOpts[:len(grantOpts):len(grantOpts)], withSubjectKind(subject.Kind))
+
+	if subject.Kind == SubjectKindServiceAccount {
+		saName := namespacedName(subject.Namespace, subject.Name) // SA are always namespaced, even if they can have cluster roles bind to cluster level.
+		saResource := GenerateResourceForGrant(saName, ResourceTypeServiceAccount.Id)
+		return grant.NewGrant(resource, entName, saResource, grantOpts...), nil
+	}
+	if subject.Kind == SubjectKindGroup {
+		groupResource := GenerateResourceForGrant(subject.Name, ResourceTypeKubeGroup.Id)
+		// Members of the group inherit the grant
+		groupOpts := append([]grant.GrantOption{
+			grant.WithAnnotation(&v2.GrantExpandable{
+				EntitlementIds: []string{entitlement.NewEntitlementID(groupResource, KubeGroupMemberEntitlement)},
+			}),
+		}, grantOpts...)
+		return grant.NewGrant(resource, entName, groupResource, groupOpts...), nil
 	}
-	return nil, fmt.Errorf("unsupported subject type")
+	return grant.NewGrant(
+		resource,
+		entName,
+		GenerateResourceForGrant(subject.Name, opts.kubeUserResourceType(subject.Name).Id),
+		grantOpts...,
+	), nil
 }
@@ -1,16 +1,25 @@
 package connector
 
 import (
+	"context"
 	"fmt"
+	"strconv"
 
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
 )
 
 // ResourcesPageSize is the default page size for resource listings.
 const ResourcesPageSize = 500
 
+// GrantsPageSize is the default number of grants per page of the role and cluster role grants.
+const GrantsPageSize = 1000
+
 // ParsePageToken parses a page token into a pagination bag.
 func ParsePageToken(token string) (*pagination.Bag, error) {
 	bag := &pagination.Bag{}
@@ -21,16 +30,22 @@ func ParsePageToken(token string) (*pagination.Bag, error) {
 	return bag, nil
 }
 
-// HandleKubePagination handles Kubernetes pagination and creates a new page token.
+// HandleKubePagination returns the page token of the next page of a Kubernetes list, replacing the continue
+// token of the current page in the bag with the one of the response rather than stacking it, so that the token
+// doesn't grow with every page. It returns an empty token once the last page is listed.
 func HandleKubePagination(respMeta *metav1.ListMeta, bag *pagination.Bag) (string, error) {
-	if respMeta == nil || respMeta.Continue == "" {
-		return "", nil
+	continueToken := ""
+	if respMeta != nil {
+		continueToken = respMeta.Continue
 	}
 
-	if respMeta.Continue != "" {
-		bag.Push(pagination.PageState{
-			Token: respMeta.Continue,
-		})
+	if bag.Current() == nil {
+		if continueToken == "" {
+			return "", nil
+		}
+		bag.Push(pagination.PageState{Token: continueToken})
+	} else if err := bag.Next(continueToken); err != nil {
+		return "", fmt.Errorf("failed to advance pagination bag: %w", err)
 	}
 
 	token, err := bag.Marshal()
@@ -41,6 +56,172 @@ func HandleKubePagination(respMeta *metav1.ListMeta, bag *pagination.Bag) (strin
 	return token, nil
 }
 
+// parentNamespace returns the namespace to list the objects of a namespaced resource type in: the parent
+// namespace when the SDK lists the children of a namespace, or "" for all namespaces.
+func parentNamespace(parentResourceID *v2.ResourceId) string {
+	if parentResourceID == nil || parentResourceID.ResourceType != ResourceTypeNamespace.Id {
+		return ""
+	}
+	return parentResourceID.Resource
+}
+
+// startNamespacedList starts a page of the listing of a namespaced resource type. It returns the namespace to
+// list the objects in, "" for all namespaces, the wildcard resources leading the page, and whether the page is
+// done with them.
+//
+// Children of a namespace are listed in the namespace only. The top-level listing covers all namespaces, unless
+// the objects are listed as the children of the synced namespaces: it then only has the wildcards. The first page
+// of the top-level listing leads with the wildcard resource of the type, built by wildcard if it's set. With
+// namespace wildcards, the first page leads with the wildcard of the listed namespace, or of every namespace
+// when the objects aren't listed as children.
+func startNamespacedList(
+	ctx context.Context,
+	client kubernetes.Interface,
+	opts ConnectorOpts,
+	resourceType *v2.ResourceType,
+	parentResourceID *v2.ResourceId,
+	pageToken string,
+	wildcard func() (*v2.Resource, error),
+) (string, []*v2.Resource, bool, error) {
+	namespace := parentNamespace(parentResourceID)
+	asChildren := opts.listedAsNamespaceChildren(resourceType.Id)
+
+	// The objects listed as children of the namespaces aren't listed again at the top level
+	done := namespace == "" && asChildren
+	if pageToken != "" {
+		return namespace, nil, done, nil
+	}
+
+	var rv []*v2.Resource
+	if namespace == "" && !opts.DisableWildcardResources {
+		if wildcard == nil {
+			wildcard = func() (*v2.Resource, error) { return generateWildcardResource(resourceType, "") }
+		}
+		wildcardResource, err := wildcard()
+		if err != nil {
+			ctxzap.Extract(ctx).Error("failed to create wildcard resource",
+				zap.String("resource_type", resourceType.Id),
+				zap.Error(err))
+		} else {
+			rv = append(rv, wildcardResource)
+		}
+	}
+
+	if opts.namespaceWildcards() && (namespace != "" || !asChildren) {
+		namespaceWildcards, err := namespaceWildcardResources(ctx, client, resourceType, namespace)
+		if err != nil {
+			return "", nil, false, err
+		}
+		rv = append(rv, namespaceWildcards...)
+	}
+	return namespace, rv, done, nil
+}
+
+// pushBindingPhases pushes the phases of listing the subjects of role bindings, then of cluster role bindings,
+// on a new pagination bag, as states whose resource type ID is the phase and whose token the continue token.
+func pushBindingPhases(bag *pagination.Bag) {
+	bag.Push(pagination.PageState{ResourceTypeID: ResourceTypeClusterRoleBindings})
+	bag.Push(pagination.PageState{ResourceTypeID: ResourceTypeRoleBindings})
+}
+
+// listBindingSubjectsPage lists the page of role bindings or cluster role bindings the current state of the bag
+// points at, with its Kubernetes continue token, and returns their subjects. The bag is advanced to the next
+// page of the phase, or to the next phase once the bindings of the phase are all listed.
+func listBindingSubjectsPage(ctx context.Context, client kubernetes.Interface, bag *pagination.Bag, pageSize int64) ([]rbacv1.Subject, error) {
+	l := ctxzap.Extract(ctx)
+	opts := metav1.ListOptions{
+		Limit:    pageSize,
+		Continue: bag.PageToken(),
+	}
+
+	var (
+		subjects      []rbacv1.Subject
+		continueToken string
+	)
+	switch phase := bag.ResourceTypeID(); phase {
+	case ResourceTypeRoleBindings:
+		l.Debug("fetching role bindings for subjects", zap.String("continue_token", opts.Continue))
+		resp, err := client.RbacV1().RoleBindings("").List(ctx, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list role bindings: %w", err)
+		}
+		for _, binding := range resp.Items {
+			subjects = append(subjects, binding.Subjects...)
+		}
+		continueToken = resp.Continue
+	case ResourceTypeClusterRoleBindings:
+		l.Debug("fetching cluster role bindings for subjects", zap.String("continue_token", opts.Continue))
+		resp, err := client.RbacV1().ClusterRoleBindings().List(ctx, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list cluster role bindings: %w", err)
+		}
+		for _, binding := range resp.Items {
+			subjects = append(subjects, binding.Subjects...)
+		}
+		continueToken = resp.Continue
+	default:
+		return nil, fmt.Errorf("invalid page token: unknown binding phase %q", phase)
+	}
+
+	if err := bag.Next(continueToken); err != nil {
+		return nil, fmt.Errorf("failed to advance page token: %w", err)
+	}
+	return subjects, nil
+}
+
+// paginateItems returns the page of items a page token points at, and the token of the next page. The items
+// must be listed in the same order for every page.
+func paginateItems[T any](items []T, pToken *pagination.Token, pageSize int) ([]T, string, error) {
+	offset, err := pageOffset(pToken)
+	if err != nil {
+		return nil, "", err
+	}
+	if offset >= len(items) {
+		return nil, "", nil
+	}
+
+	end := min(offset+pageSize, len(items))
+	if end == len(items) {
+		return items[offset:end], "", nil
+	}
+	nextPageToken, err := offsetPageToken(end)
+	if err != nil {
+		return nil, "", err
+	}
+	return items[offset:end], nextPageToken, nil
+}
+
+// pageOffset returns the offset of the page a token created by offsetPageToken points at, 0 for the first page.
+func pageOffset(pToken *pagination.Token) (int, error) {
+	if pToken == nil || pToken.Token == "" {
+		return 0, nil
+	}
+	bag, err := ParsePageToken(pToken.Token)
+	if err != nil {
+		return 0, err
+	}
+	state := bag.Current()
+	if state == nil {
+		return 0, nil
+	}
+	offset, err := strconv.Atoi(state.Token)
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid page offset %q", state.Token)
+	}
+	return offset, nil
+}
+
+// offsetPageToken returns the token of the page starting at an offset.
+func offsetPageToken(offset int) (string, error) {
+	bag := &pagination.Bag{}
+	bag.Push(pagination.PageState{Token: strconv.Itoa(offset)})
+	token, err := bag.Marshal()
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal pagination bag: %w", err)
+	}
+	return token, nil
+}
+
 // formatResourceID creates a Baton resource ID for the given resource type and ID.
 func formatResourceID(resourceType *v2.ResourceType, id string) (*v2.ResourceId, error) {
 	if resourceType == nil {
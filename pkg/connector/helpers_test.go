@@ -0,0 +1,84 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/conductorone/baton-kubernetes/pkg/kubetest"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestHandleKubePagination(t *testing.T) {
+	next := func(token, continueToken string) string {
+		bag, err := ParsePageToken(token)
+		require.NoError(t, err)
+		rv, err := HandleKubePagination(&metav1.ListMeta{Continue: continueToken}, bag)
+		require.NoError(t, err)
+		return rv
+	}
+
+	first := next("", "page-2")
+	second := next(first, "page-3")
+	third := next(second, "")
+
+	bag, err := ParsePageToken(second)
+	require.NoError(t, err)
+	assert.Equal(t, "page-3", bag.PageToken())
+	require.NotNil(t, bag.Pop())
+	assert.Nil(t, bag.Current(), "stale continue tokens left in the bag")
+	assert.Len(t, second, len(first))
+	assert.Empty(t, third)
+
+	// A single page has no next page
+	assert.Empty(t, next("", ""))
+}
+
+// TestHandleKubePagination_Builder tests that a builder walking three pages sends the continue token of the
+// previous response each time, and that its page token doesn't grow.
+func TestHandleKubePagination_Builder(t *testing.T) {
+	ctx := context.Background()
+	var objects []runtime.Object
+	for i := 0; i < 6; i++ {
+		objects = append(objects, &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("node-%d", i)}})
+	}
+	client := fake.NewSimpleClientset(objects...)
+	paginator := kubetest.Paginate(client, []string{"nodes"}, kubetest.WithPageSize(2))
+	builder := newNodeBuilder(client, ConnectorOpts{DisableWildcardResources: true})
+
+	var (
+		tokens    []string
+		listed    int
+		continues []string
+	)
+	token := &pagination.Token{}
+	for {
+		resources, next, _, err := builder.List(ctx, nil, token)
+		require.NoError(t, err)
+		listed += len(resources)
+		if next == "" {
+			break
+		}
+		tokens = append(tokens, next)
+		bag, err := ParsePageToken(next)
+		require.NoError(t, err)
+		continues = append(continues, bag.PageToken())
+		token = &pagination.Token{Token: next}
+	}
+
+	assert.Equal(t, 6, listed)
+	require.Len(t, tokens, 2)
+	assert.Len(t, tokens[1], len(tokens[0]))
+
+	requests := paginator.Requests("nodes")
+	require.Len(t, requests, 3)
+	assert.Empty(t, requests[0].Continue)
+	assert.Equal(t, continues[0], requests[1].Continue)
+	assert.Equal(t, continues[1], requests[2].Continue)
+}
@@ -0,0 +1,76 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// SecretPullWithEntitlement is the entitlement of an image pull secret granted to the service accounts listing it
+// in their imagePullSecrets, whose pods pull images with its registry credentials.
+const SecretPullWithEntitlement = "pull_with"
+
+// isImagePullSecretType reports whether the kubelet can pull images with secrets of the type.
+func isImagePullSecretType(secretType corev1.SecretType) bool {
+	return secretType == corev1.SecretTypeDockerConfigJson || secretType == corev1.SecretTypeDockercfg
+}
+
+// imagePullSecretGrants returns the pull_with grants of the image pull secrets of a service account to it. The
+// secrets are in the namespace of the service account; ones that don't exist or can't be pulled with are skipped.
+func imagePullSecretGrants(ctx context.Context, client kubernetes.Interface, resource *v2.Resource) ([]*v2.Grant, error) {
+	if isWildcardResourceID(resource.Id.Resource) {
+		return nil, nil
+	}
+
+	namespace, name, ok := strings.Cut(resource.Id.Resource, "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid service account resource ID format: %s", resource.Id.Resource)
+	}
+	l := ctxzap.Extract(ctx).With(zap.String("namespace", namespace), zap.String("serviceAccount", name))
+
+	sa, err := client.CoreV1().ServiceAccounts(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			l.Info("service account no longer exists, skipping image pull secret grants")
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get service account: %w", err)
+	}
+
+	var rv []*v2.Grant
+	seen := make(map[string]bool, len(sa.ImagePullSecrets))
+	for _, ref := range sa.ImagePullSecrets {
+		if ref.Name == "" || seen[ref.Name] {
+			continue
+		}
+		seen[ref.Name] = true
+
+		secret, err := client.CoreV1().Secrets(namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+		if err != nil {
+			if k8serrors.IsNotFound(err) {
+				l.Info("image pull secret doesn't exist, skipping", zap.String("secret", ref.Name))
+				continue
+			}
+			return nil, fmt.Errorf("failed to get image pull secret %s: %w", ref.Name, err)
+		}
+		if !isImagePullSecretType(secret.Type) {
+			l.Debug("image pull secret holds no registry credentials, skipping",
+				zap.String("secret", ref.Name),
+				zap.String("type", string(secret.Type)))
+			continue
+		}
+
+		secretResource := GenerateResourceForGrant(namespace+"/"+ref.Name, ResourceTypeSecret.Id)
+		rv = append(rv, grant.NewGrant(secretResource, SecretPullWithEntitlement, resource.Id))
+	}
+	return rv, nil
+}
@@ -0,0 +1,87 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestServiceAccountBuilderGrants_ImagePullSecrets(t *testing.T) {
+	ctx := context.Background()
+	deployer := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "deployer"},
+		ImagePullSecrets: []corev1.LocalObjectReference{
+			{Name: "ghcr"},
+			{Name: "legacy-registry"},
+			{Name: "deleted"},
+		},
+	}
+	ghcr := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "ghcr"}, Type: corev1.SecretTypeDockerConfigJson}
+	client := fake.NewSimpleClientset(
+		deployer,
+		ghcr,
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "legacy-registry"}, Type: corev1.SecretTypeDockercfg},
+		// Same name in another namespace, which the service account can't use
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "deleted"}, Type: corev1.SecretTypeDockerConfigJson},
+	)
+	builder := newServiceAccountBuilder(client, ConnectorOpts{})
+
+	resource, err := serviceAccountResource(deployer, ConnectorOpts{})
+	require.NoError(t, err)
+	grants, _, _, err := builder.Grants(ctx, resource, &pagination.Token{})
+	require.NoError(t, err)
+
+	var got []string
+	for _, g := range grants {
+		got = append(got, g.Entitlement.Id+" "+g.Principal.Id.ResourceType+":"+g.Principal.Id.Resource)
+	}
+	assert.Equal(t, []string{
+		"secret:payments/ghcr:pull_with service_account:payments/deployer",
+		"secret:payments/legacy-registry:pull_with service_account:payments/deployer",
+	}, got)
+
+	// The wildcard has no image pull secrets
+	wildcard, err := generateWildcardResource(ResourceTypeServiceAccount, "")
+	require.NoError(t, err)
+	grants, _, _, err = builder.Grants(ctx, wildcard, &pagination.Token{})
+	require.NoError(t, err)
+	assert.Empty(t, grants)
+
+	// Image pull secrets offer the entitlement to service accounts
+	secrets := newSecretBuilder(client, nil, nil, ConnectorOpts{})
+	secret, err := secretResource(ghcr, nil, secrets.opts)
+	require.NoError(t, err)
+	entitlements, _, _, err := secrets.Entitlements(ctx, secret, &pagination.Token{})
+	require.NoError(t, err)
+	var pullWith []string
+	for _, ent := range entitlements {
+		if ent.Slug == SecretPullWithEntitlement {
+			for _, rt := range ent.GrantableTo {
+				pullWith = append(pullWith, rt.Id)
+			}
+		}
+	}
+	assert.Equal(t, []string{ResourceTypeServiceAccount.Id}, pullWith)
+}
+
+func TestServiceAccountResource_SecretNames(t *testing.T) {
+	resource, err := serviceAccountResource(&corev1.ServiceAccount{
+		ObjectMeta:       metav1.ObjectMeta{Namespace: "payments", Name: "deployer"},
+		Secrets:          []corev1.ObjectReference{{Name: "deployer-token"}},
+		ImagePullSecrets: []corev1.LocalObjectReference{{Name: "ghcr"}, {Name: "quay"}},
+	}, ConnectorOpts{})
+	require.NoError(t, err)
+
+	trait, err := rs.GetUserTrait(resource)
+	require.NoError(t, err)
+	fields := trait.GetProfile().GetFields()
+	assert.Equal(t, []any{"deployer-token"}, fields["secrets"].GetListValue().AsSlice())
+	assert.Equal(t, []any{"ghcr", "quay"}, fields["imagePullSecrets"].GetListValue().AsSlice())
+}
@@ -5,7 +5,7 @@ import (
 	"fmt"
 	"sync"
 
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/client-go/kubernetes"
 
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
@@ -17,12 +17,22 @@ import (
 	"go.uber.org/zap"
 )
 
-// kubeGroupBuilder syncs Kubernetes groups referenced in RBAC bindings as Baton groups.
+// KubeGroupMemberEntitlement is the entitlement of the members of a group, through which grants to the group
+// are expanded to its members.
+const KubeGroupMemberEntitlement = "member"
+
+// kubeGroupBuilder syncs Kubernetes groups referenced in RBAC bindings as Baton groups. It's safe for
+// concurrent use: groupCacheLock guards the cache of the groups already listed, which pages check and update
+// at once so that a group bound on several pages is listed once.
 type kubeGroupBuilder struct {
-	client kubernetes.Interface
-	// Cache to avoid duplicate work when extracting groups from bindings
+	client     kubernetes.Interface
+	clusterIDs ClusterIDProvider
+	opts       ConnectorOpts
+	// membership lists the groups of the group membership file and their members, if configured
+	membership *groupMembership
+	// Cache of the groups listed by the current sync, reset on its first page
 	groupCache     map[string]bool
-	groupCacheLock sync.RWMutex
+	groupCacheLock sync.Mutex
 }
 
 // ResourceType returns the resource type for KubeGroup.
@@ -35,22 +45,7 @@ func (k *kubeGroupBuilder) List(ctx context.Context, parentResourceID *v2.Resour
 	l := ctxzap.Extract(ctx)
 	var rv []*v2.Resource
 
-	// Initialize empty group cache if needed
-	k.groupCacheLock.Lock()
-	if k.groupCache == nil {
-		k.groupCache = make(map[string]bool)
-	}
-	k.groupCacheLock.Unlock()
-
-	// Always create built-in system groups
-	builtInGroups := []string{
-		"system:masters",
-		"system:authenticated",
-		"system:unauthenticated",
-	}
-	for _, groupName := range builtInGroups {
-		k.processGroup(ctx, groupName, &rv)
-	}
+	clusterID := subjectClusterID(ctx, k.clusterIDs)
 
 	// Parse pagination token
 	bag, err := ParsePageToken(pToken.Token)
@@ -58,110 +53,82 @@ func (k *kubeGroupBuilder) List(ctx context.Context, parentResourceID *v2.Resour
 		return nil, "", nil, fmt.Errorf("failed to parse page token: %w", err)
 	}
 
-	pageState := bag.PageToken()
-
-	// Phase 1: Process RoleBindings
-	if pageState == "" || pageState == ResourceTypeRoleBindings {
-		// Set up list options with pagination
-		opts := metav1.ListOptions{
-			Limit: ResourcesPageSize,
-		}
-		if pageState == ResourceTypeRoleBindings {
-			opts.Continue = bag.PageToken()
-		}
-
-		// Fetch role bindings from all namespaces
-		l.Debug("fetching role bindings for groups", zap.String("continue_token", opts.Continue))
-		resp, err := k.client.RbacV1().RoleBindings("").List(ctx, opts)
-		if err != nil {
-			return nil, "", nil, fmt.Errorf("failed to list role bindings: %w", err)
-		}
-
-		// Extract group subjects from bindings
-		for _, binding := range resp.Items {
-			for _, subject := range binding.Subjects {
-				if subject.Kind == "Group" {
-					// Process group
-					k.processGroup(ctx, subject.Name, &rv)
-				}
-			}
-		}
+	// The cache only dedupes the groups of a sync, the first page starts a new one. Otherwise a connector
+	// running as a service would consider every group already listed on the next sync.
+	k.groupCacheLock.Lock()
+	if k.groupCache == nil || bag.Current() == nil {
+		k.groupCache = make(map[string]bool)
+	}
+	k.groupCacheLock.Unlock()
 
-		if resp.Continue != "" {
-			// Still more rolebindings to process
-			bag.Push(pagination.PageState{Token: resp.Continue})
-			token, err := bag.Marshal()
+	if bag.Current() == nil {
+		// Add wildcard resource first, but only on the first page (when page token is empty)
+		if !k.opts.DisableWildcardResources {
+			wildcardResource, err := generateWildcardResource(ResourceTypeKubeGroup, "")
 			if err != nil {
-				return nil, "", nil, fmt.Errorf("failed to marshal pagination bag: %w", err)
+				l.Error("failed to create wildcard resource for groups", zap.Error(err))
+			} else {
+				rv = append(rv, wildcardResource)
 			}
-			return rv, token, nil, nil
 		}
 
-		// Prepare for phase 2
-		bag = &pagination.Bag{}
-		bag.Push(pagination.PageState{Token: "clusterrolebindings"})
-	}
-
-	// Phase 2: Process ClusterRoleBindings
-	if pageState == "clusterrolebindings" {
-		// Set up list options with pagination
-		opts := metav1.ListOptions{
-			Limit:    ResourcesPageSize,
-			Continue: bag.PageToken(),
+		// Always create built-in system groups
+		builtInGroups := []string{
+			"system:masters",
+			"system:authenticated",
+			"system:unauthenticated",
 		}
-
-		// Fetch cluster role bindings
-		l.Debug("fetching cluster role bindings for groups", zap.String("continue_token", opts.Continue))
-		resp, err := k.client.RbacV1().ClusterRoleBindings().List(ctx, opts)
-		if err != nil {
-			return nil, "", nil, fmt.Errorf("failed to list cluster role bindings: %w", err)
+		for _, groupName := range builtInGroups {
+			k.processGroup(ctx, clusterID, groupName, &rv)
 		}
 
-		// Extract group subjects from bindings
-		for _, binding := range resp.Items {
-			for _, subject := range binding.Subjects {
-				if subject.Kind == "Group" {
-					// Process group
-					k.processGroup(ctx, subject.Name, &rv)
-				}
-			}
+		// Re-read the group membership file for the sync, and create its groups even if nothing binds them
+		if err := k.membership.reload(); err != nil {
+			return nil, "", nil, err
+		}
+		for _, groupName := range k.membership.groups() {
+			k.processGroup(ctx, clusterID, groupName, &rv)
 		}
 
-		if resp.Continue != "" {
-			// Still more clusterrolebindings to process
-			bag.Push(pagination.PageState{Token: resp.Continue})
-			token, err := bag.Marshal()
-			if err != nil {
-				return nil, "", nil, fmt.Errorf("failed to marshal pagination bag: %w", err)
-			}
-			return rv, token, nil, nil
+		pushBindingPhases(bag)
+	}
+
+	// Extract group subjects from the current page of role bindings or cluster role bindings
+	subjects, err := listWithRetry(ctx, k.opts, func(ctx context.Context) ([]rbacv1.Subject, error) {
+		return listBindingSubjectsPage(ctx, k.client, bag, k.opts.pageSize(ResourceTypeKubeGroup.Id))
+	})
+	if err != nil {
+		return nil, "", nil, err
+	}
+	for _, subject := range subjects {
+		if subject.Kind == SubjectKindGroup {
+			k.processGroup(ctx, clusterID, subject.Name, &rv)
 		}
 	}
 
-	// All done, return resources without pagination token
-	return rv, "", nil, nil
+	nextPageToken, err := bag.Marshal()
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to marshal pagination bag: %w", err)
+	}
+	return rv, nextPageToken, nil, nil
 }
 
 // processGroup adds a group to the list of resources if not already processed.
-func (k *kubeGroupBuilder) processGroup(ctx context.Context, groupName string, resources *[]*v2.Resource) {
+func (k *kubeGroupBuilder) processGroup(ctx context.Context, clusterID, groupName string, resources *[]*v2.Resource) {
 	l := ctxzap.Extract(ctx)
 
-	// Check if we've already processed this group
-	k.groupCacheLock.RLock()
+	// Check and mark the group as processed at once, so that concurrent pages don't both add it
+	k.groupCacheLock.Lock()
 	processed := k.groupCache[groupName]
-	k.groupCacheLock.RUnlock()
+	k.groupCache[groupName] = true
+	k.groupCacheLock.Unlock()
 
 	if processed {
 		return
 	}
 
-	// Mark as processed
-	k.groupCacheLock.Lock()
-	k.groupCache[groupName] = true
-	k.groupCacheLock.Unlock()
-
 	// Create group resource
-	resource, err := k.kubeGroupResource(groupName)
+	resource, err := k.kubeGroupResource(clusterID, groupName)
 	if err != nil {
 		l.Error("failed to create group resource", zap.String("name", groupName), zap.Error(err))
 		return
@@ -170,8 +137,10 @@ func (k *kubeGroupBuilder) processGroup(ctx context.Context, groupName string, r
 	*resources = append(*resources, resource)
 }
 
-// kubeGroupResource creates a Baton group resource for a Kubernetes group.
-func (k *kubeGroupBuilder) kubeGroupResource(groupName string) (*v2.Resource, error) {
+// kubeGroupResource creates a Baton group resource for a Kubernetes group, whose ID encodes the group name.
+// The name is kept as is in the display name and profile, and the external ID is derived from it and
+// clusterID when clusterID is set.
+func (k *kubeGroupBuilder) kubeGroupResource(clusterID, groupName string) (*v2.Resource, error) {
 	// Create profile
 	profile := map[string]interface{}{
 		"name": groupName,
@@ -182,12 +151,18 @@ func (k *kubeGroupBuilder) kubeGroupResource(groupName string) (*v2.Resource, er
 		rs.WithGroupProfile(profile),
 	}
 
+	var options []rs.ResourceOption
+	if clusterID != "" {
+		options = append(options, rs.WithExternalID(subjectExternalID(clusterID, SubjectKindGroup, groupName)))
+	}
+
 	// Create group resource
 	resource, err := rs.NewGroupResource(
 		groupName,
 		ResourceTypeKubeGroup,
-		groupName,
+		subjectResourceID(groupName),
 		groupOptions,
+		options...,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create group resource: %w", err)
@@ -196,7 +171,8 @@ func (k *kubeGroupBuilder) kubeGroupResource(groupName string) (*v2.Resource, er
 	return resource, nil
 }
 
-// Entitlements returns entitlements for Group resources.
+// Entitlements returns entitlements for Group resources. The wildcard group only has the impersonate
+// entitlement, as it has no members of its own.
 func (k *kubeGroupBuilder) Entitlements(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
 	// Add 'impersonate' entitlement
 	impersonateEnt := entitlement.NewPermissionEntitlement(
@@ -210,18 +186,57 @@ func (k *kubeGroupBuilder) Entitlements(_ context.Context, resource *v2.Resource
 		),
 	)
 
-	return []*v2.Entitlement{impersonateEnt}, "", nil, nil
+	if resource.Id.Resource == "*" {
+		return []*v2.Entitlement{impersonateEnt}, "", nil, nil
+	}
+
+	// Add 'member' entitlement, joining membership provided by other connectors such as an IdP
+	memberEnt := entitlement.NewAssignmentEntitlement(
+		resource,
+		KubeGroupMemberEntitlement,
+		entitlement.WithDisplayName(fmt.Sprintf("%s Group Member", resource.DisplayName)),
+		entitlement.WithDescription(fmt.Sprintf("Member of the %s group", resource.DisplayName)),
+		entitlement.WithGrantableTo(
+			ResourceTypeKubeUser,
+		),
+	)
+
+	return []*v2.Entitlement{impersonateEnt, memberEnt}, "", nil, nil
 }
 
-// Grants returns no grants for Group resources.
-func (k *kubeGroupBuilder) Grants(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
-	return nil, "", nil, nil
+// Grants returns the member grants of the users listed for the group in the group membership file.
+func (k *kubeGroupBuilder) Grants(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+	if k.membership == nil || isWildcardResourceID(resource.Id.Resource) {
+		return nil, "", nil, nil
+	}
+	if err := k.membership.ensureLoaded(); err != nil {
+		return nil, "", nil, err
+	}
+
+	groupName, err := subjectName(resource.Id.Resource)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	var rv []*v2.Grant
+	for _, subject := range k.membership.groupMembers(groupName) {
+		g, err := grantRoleToSubject(subject, resource, KubeGroupMemberEntitlement, k.opts)
+		if err != nil {
+			l.Debug("skipping group member", zap.String("group", groupName), zap.String("user", subject.Name), zap.Error(err))
+			continue
+		}
+		rv = append(rv, g)
+	}
+	return rv, "", nil, nil
 }
 
 // newKubeGroupBuilder creates a new kube group builder.
-func newKubeGroupBuilder(client kubernetes.Interface) *kubeGroupBuilder {
+func newKubeGroupBuilder(client kubernetes.Interface, clusterIDs ClusterIDProvider, opts ConnectorOpts) *kubeGroupBuilder {
 	return &kubeGroupBuilder{
 		client:     client,
+		clusterIDs: clusterIDs,
+		opts:       opts,
 		groupCache: make(map[string]bool),
 	}
 }
@@ -0,0 +1,68 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/conductorone/baton-kubernetes/pkg/kubetest"
+	"github.com/stretchr/testify/assert"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// TestKubeGroupBuilderList_Pagination tests that groups only bound by cluster role bindings are listed when the
+// role bindings span several pages, and that both phases are walked to their last page.
+func TestKubeGroupBuilderList_Pagination(t *testing.T) {
+	group := func(name string) []rbacv1.Subject {
+		return []rbacv1.Subject{{Kind: SubjectKindGroup, APIGroup: RBACAPIGroup, Name: name}}
+	}
+
+	var objects []runtime.Object
+	for i := 0; i < 5; i++ {
+		objects = append(objects, &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("rb-%d", i), Namespace: "payments"},
+			Subjects:   group(fmt.Sprintf("rb-group-%d", i)),
+		})
+	}
+	for i := 0; i < 3; i++ {
+		objects = append(objects, &rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("crb-%d", i)},
+			Subjects:   group(fmt.Sprintf("crb-group-%d", i)),
+		})
+	}
+	client := fake.NewSimpleClientset(objects...)
+	paginator := kubetest.Paginate(client, []string{"rolebindings", "clusterrolebindings"}, kubetest.WithPageSize(2))
+
+	var ids []string
+	for _, resource := range listResources(context.Background(), t, newKubeGroupBuilder(client, nil, ConnectorOpts{})) {
+		ids = append(ids, resource.Id.Resource)
+	}
+	assert.Equal(t, []string{
+		"*",
+		"system:masters", "system:authenticated", "system:unauthenticated",
+		"rb-group-0", "rb-group-1", "rb-group-2", "rb-group-3", "rb-group-4",
+		"crb-group-0", "crb-group-1", "crb-group-2",
+	}, ids)
+	assert.Equal(t, 3, paginator.Pages("rolebindings"))
+	assert.Equal(t, 2, paginator.Pages("clusterrolebindings"))
+	assert.Empty(t, paginator.Requests("clusterrolebindings")[0].Continue)
+}
+
+// TestKubeGroupBuilderList_RepeatedSyncs tests that a builder kept across syncs, as in service mode, lists the
+// same groups, built-in ones included, on every sync.
+func TestKubeGroupBuilderList_RepeatedSyncs(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(&rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "crb"},
+		Subjects:   []rbacv1.Subject{{Kind: SubjectKindGroup, APIGroup: RBACAPIGroup, Name: "oncall"}},
+	})
+	builder := newKubeGroupBuilder(client, nil, ConnectorOpts{})
+
+	first := listedResourceIDs(listResources(ctx, t, builder))
+	second := listedResourceIDs(listResources(ctx, t, builder))
+	assert.Len(t, first, 5)
+	assert.Equal(t, first, second)
+}
@@ -5,7 +5,7 @@ import (
 	"fmt"
 	"sync"
 
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/client-go/kubernetes"
 
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
@@ -17,17 +17,24 @@ import (
 	"go.uber.org/zap"
 )
 
-// kubeUserBuilder syncs Kubernetes users referenced in RBAC bindings as Baton users.
+// kubeUserBuilder syncs Kubernetes users referenced in RBAC bindings as Baton users. When system users are
+// separated, one builder syncs the kube_user and another the kube_system_user resources. It's safe for
+// concurrent use, userCacheLock guarding the cache of the users already listed.
 type kubeUserBuilder struct {
-	client kubernetes.Interface
-	// Cache to avoid duplicate work when extracting users from bindings
+	client       kubernetes.Interface
+	clusterIDs   ClusterIDProvider
+	opts         ConnectorOpts
+	resourceType *v2.ResourceType
+	// membership lists the users of the group membership file, synced even if no binding references them
+	membership *groupMembership
+	// Cache of the users listed by the current sync, reset on its first page
 	userCache     map[string]bool
-	userCacheLock sync.RWMutex
+	userCacheLock sync.Mutex
 }
 
-// ResourceType returns the resource type for KubeUser.
+// ResourceType returns the resource type for KubeUser or KubeSystemUser.
 func (k *kubeUserBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
-	return ResourceTypeKubeUser
+	return k.resourceType
 }
 
 // List extracts unique users from RBAC bindings and creates Baton user resources.
@@ -35,123 +42,101 @@ func (k *kubeUserBuilder) List(ctx context.Context, parentResourceID *v2.Resourc
 	l := ctxzap.Extract(ctx)
 	var rv []*v2.Resource
 
-	// Initialize empty user cache if needed
-	k.userCacheLock.Lock()
-	if k.userCache == nil {
-		k.userCache = make(map[string]bool)
-	}
-	k.userCacheLock.Unlock()
-
 	// Parse pagination token
 	bag, err := ParsePageToken(pToken.Token)
 	if err != nil {
 		return nil, "", nil, fmt.Errorf("failed to parse page token: %w", err)
 	}
 
-	pageState := bag.PageToken()
+	// The cache only dedupes the users of a sync, the first page starts a new one. Otherwise a connector
+	// running as a service would consider every user already listed on the next sync.
+	k.userCacheLock.Lock()
+	if k.userCache == nil || bag.Current() == nil {
+		k.userCache = make(map[string]bool)
+	}
+	k.userCacheLock.Unlock()
 
-	// Phase 1: Process RoleBindings
-	if pageState == "" || pageState == "rolebindings" {
-		// Set up list options with pagination
-		opts := metav1.ListOptions{
-			Limit: ResourcesPageSize,
-		}
-		if pageState == "rolebindings" {
-			opts.Continue = bag.PageToken()
-		}
+	clusterID := subjectClusterID(ctx, k.clusterIDs)
 
-		// Fetch role bindings from all namespaces
-		l.Debug("fetching role bindings for users", zap.String("continue_token", opts.Continue))
-		resp, err := k.client.RbacV1().RoleBindings("").List(ctx, opts)
-		if err != nil {
-			return nil, "", nil, fmt.Errorf("failed to list role bindings: %w", err)
-		}
-
-		// Extract user subjects from bindings
-		for _, binding := range resp.Items {
-			for _, subject := range binding.Subjects {
-				if subject.Kind == "User" {
-					// Process user
-					k.processUser(ctx, subject.Name, &rv)
-				}
+	// Add wildcard resource first, but only on the first page (when page token is empty)
+	if bag.Current() == nil {
+		if !k.opts.DisableWildcardResources {
+			wildcardResource, err := generateWildcardResource(k.resourceType, "")
+			if err != nil {
+				l.Error("failed to create wildcard resource for users", zap.Error(err))
+			} else {
+				rv = append(rv, wildcardResource)
 			}
 		}
 
-		if resp.Continue != "" {
-			// Still more rolebindings to process
-			bag.Push(pagination.PageState{Token: resp.Continue})
-			token, err := bag.Marshal()
+		// Kubelets authenticate as system:node:<name> users, which usually aren't bound by name, so they are
+		// synced from the nodes for the nodes to be linked to them
+		if k.opts.IncludeSystemSubjects && k.opts.kubeUserResourceType(nodeUserPrefix) == k.resourceType {
+			nodeUsers, err := listNodeUsernames(ctx, k.client, k.opts)
 			if err != nil {
-				return nil, "", nil, fmt.Errorf("failed to marshal pagination bag: %w", err)
+				return nil, "", nil, err
+			}
+			for _, username := range nodeUsers {
+				k.processUser(ctx, clusterID, username, &rv)
 			}
-			return rv, token, nil, nil
 		}
 
-		// Prepare for phase 2
-		bag = &pagination.Bag{}
-		bag.Push(pagination.PageState{Token: "clusterrolebindings"})
-	}
-
-	// Phase 2: Process ClusterRoleBindings
-	if pageState == "clusterrolebindings" {
-		// Set up list options with pagination
-		opts := metav1.ListOptions{
-			Limit:    ResourcesPageSize,
-			Continue: bag.PageToken(),
+		// Members of the groups in the group membership file may not be bound to anything themselves
+		if err := k.membership.reload(); err != nil {
+			return nil, "", nil, err
 		}
-
-		// Fetch cluster role bindings
-		l.Debug("fetching cluster role bindings for users", zap.String("continue_token", opts.Continue))
-		resp, err := k.client.RbacV1().ClusterRoleBindings().List(ctx, opts)
-		if err != nil {
-			return nil, "", nil, fmt.Errorf("failed to list cluster role bindings: %w", err)
+		for _, username := range k.membership.usernames() {
+			k.processUser(ctx, clusterID, username, &rv)
 		}
 
-		// Extract user subjects from bindings
-		for _, binding := range resp.Items {
-			for _, subject := range binding.Subjects {
-				if subject.Kind == "User" {
-					// Process user
-					k.processUser(ctx, subject.Name, &rv)
-				}
-			}
-		}
+		pushBindingPhases(bag)
+	}
 
-		if resp.Continue != "" {
-			// Still more clusterrolebindings to process
-			bag.Push(pagination.PageState{Token: resp.Continue})
-			token, err := bag.Marshal()
-			if err != nil {
-				return nil, "", nil, fmt.Errorf("failed to marshal pagination bag: %w", err)
-			}
-			return rv, token, nil, nil
+	// Extract user subjects from the current page of role bindings or cluster role bindings
+	subjects, err := listWithRetry(ctx, k.opts, func(ctx context.Context) ([]rbacv1.Subject, error) {
+		return listBindingSubjectsPage(ctx, k.client, bag, k.opts.pageSize(k.resourceType.Id))
+	})
+	if err != nil {
+		return nil, "", nil, err
+	}
+	for _, subject := range subjects {
+		if subject.Kind == SubjectKindUser {
+			k.processUser(ctx, clusterID, subject.Name, &rv)
 		}
 	}
 
-	// All done, return resources without pagination token
-	return rv, "", nil, nil
+	nextPageToken, err := bag.Marshal()
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to marshal pagination bag: %w", err)
+	}
+	return rv, nextPageToken, nil, nil
 }
 
 // processUser adds a user to the list of resources if not already processed.
-func (k *kubeUserBuilder) processUser(ctx context.Context, username string, resources *[]*v2.Resource) {
+func (k *kubeUserBuilder) processUser(ctx context.Context, clusterID, username string, resources *[]*v2.Resource) {
 	l := ctxzap.Extract(ctx)
 
-	// Check if we've already processed this user
-	k.userCacheLock.RLock()
-	processed := k.userCache[username]
-	k.userCacheLock.RUnlock()
-
-	if processed {
+	// Service account user names are synced as service accounts, and users of the other user resource type
+	// by the other builder
+	if _, ok := serviceAccountForUsername(username); ok {
+		return
+	}
+	if k.opts.kubeUserResourceType(username) != k.resourceType {
 		return
 	}
 
-	// Mark as processed
+	// Check and mark the user as processed at once, so that concurrent pages don't both add it
 	k.userCacheLock.Lock()
+	processed := k.userCache[username]
 	k.userCache[username] = true
 	k.userCacheLock.Unlock()
 
+	if processed {
+		return
+	}
+
 	// Create user resource
-	resource, err := k.kubeUserResource(username)
+	resource, err := k.kubeUserResource(clusterID, username)
 	if err != nil {
 		l.Error("failed to create user resource", zap.String("name", username), zap.Error(err))
 		return
@@ -160,12 +145,20 @@ func (k *kubeUserBuilder) processUser(ctx context.Context, username string, reso
 	*resources = append(*resources, resource)
 }
 
-// kubeUserResource creates a Baton user resource for a Kubernetes user.
-func (k *kubeUserBuilder) kubeUserResource(username string) (*v2.Resource, error) {
+// kubeUserResource creates a Baton user resource for a Kubernetes user, whose ID encodes the user name. The
+// name is kept as is in the display name, profile and login, and the external ID is derived from it and
+// clusterID when clusterID is set.
+func (k *kubeUserBuilder) kubeUserResource(clusterID, username string) (*v2.Resource, error) {
 	// Create profile
 	profile := map[string]interface{}{
 		"name": username,
 	}
+	if isSystemIdentity(username) {
+		profile["systemIdentity"] = true
+	}
+	if nodeName, ok := nodeNameForUsername(username); ok {
+		profile["nodeName"] = nodeName
+	}
 
 	// Create resource with user trait options
 	userOptions := []rs.UserTraitOption{
@@ -174,12 +167,18 @@ func (k *kubeUserBuilder) kubeUserResource(username string) (*v2.Resource, error
 		rs.WithUserLogin(username),
 	}
 
+	var options []rs.ResourceOption
+	if clusterID != "" {
+		options = append(options, rs.WithExternalID(subjectExternalID(clusterID, SubjectKindUser, username)))
+	}
+
 	// Create user resource
 	resource, err := rs.NewUserResource(
 		username,
-		ResourceTypeKubeUser,
-		username,
+		k.resourceType,
+		subjectResourceID(username),
 		userOptions,
+		options...,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create user resource: %w", err)
@@ -211,9 +210,23 @@ func (k *kubeUserBuilder) Grants(_ context.Context, resource *v2.Resource, _ *pa
 }
 
 // newKubeUserBuilder creates a new kube user builder.
-func newKubeUserBuilder(client kubernetes.Interface) *kubeUserBuilder {
+func newKubeUserBuilder(client kubernetes.Interface, clusterIDs ClusterIDProvider, opts ConnectorOpts) *kubeUserBuilder {
+	return &kubeUserBuilder{
+		client:       client,
+		clusterIDs:   clusterIDs,
+		opts:         opts,
+		resourceType: ResourceTypeKubeUser,
+		userCache:    make(map[string]bool),
+	}
+}
+
+// newKubeSystemUserBuilder creates a builder syncing the users with system: names as KubeSystemUser resources.
+func newKubeSystemUserBuilder(client kubernetes.Interface, clusterIDs ClusterIDProvider, opts ConnectorOpts) *kubeUserBuilder {
 	return &kubeUserBuilder{
-		client:    client,
-		userCache: make(map[string]bool),
+		client:       client,
+		clusterIDs:   clusterIDs,
+		opts:         opts,
+		resourceType: ResourceTypeKubeSystemUser,
+		userCache:    make(map[string]bool),
 	}
 }
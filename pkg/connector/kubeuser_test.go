@@ -0,0 +1,141 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/conductorone/baton-kubernetes/pkg/kubetest"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// TestKubeUserBuilderList_Pagination tests that the users of several pages of role bindings, then of several
+// pages of cluster role bindings, are all listed, each continue token going to the list it came from.
+func TestKubeUserBuilderList_Pagination(t *testing.T) {
+	ctx := context.Background()
+	user := func(name string) []rbacv1.Subject {
+		return []rbacv1.Subject{{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: name}}
+	}
+
+	var objects []runtime.Object
+	for i := 0; i < 5; i++ {
+		objects = append(objects, &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("rb-%d", i), Namespace: "payments"},
+			Subjects:   user(fmt.Sprintf("rb-user-%d", i)),
+		})
+	}
+	for i := 0; i < 3; i++ {
+		objects = append(objects, &rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("crb-%d", i)},
+			Subjects:   user(fmt.Sprintf("crb-user-%d", i)),
+		})
+	}
+	// Users bound in both phases are listed once
+	objects = append(objects, &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "crb-3"},
+		Subjects:   user("rb-user-0"),
+	})
+	client := fake.NewSimpleClientset(objects...)
+	paginator := kubetest.Paginate(client, []string{"rolebindings", "clusterrolebindings"}, kubetest.WithPageSize(2))
+
+	builder := newKubeUserBuilder(client, nil, ConnectorOpts{})
+	var ids []string
+	pages := 0
+	token := &pagination.Token{}
+	for {
+		resources, next, _, err := builder.List(ctx, nil, token)
+		require.NoError(t, err)
+		for _, resource := range resources {
+			ids = append(ids, resource.Id.Resource)
+		}
+		pages++
+		if next == "" {
+			break
+		}
+		token = &pagination.Token{Token: next}
+	}
+
+	assert.Equal(t, []string{
+		"*",
+		"rb-user-0", "rb-user-1", "rb-user-2", "rb-user-3", "rb-user-4",
+		"crb-user-0", "crb-user-1", "crb-user-2",
+	}, ids)
+	assert.Equal(t, 3, paginator.Pages("rolebindings"))
+	assert.Equal(t, 2, paginator.Pages("clusterrolebindings"))
+	assert.Equal(t, 5, pages)
+
+	// The first page of each phase starts without a continue token
+	assert.Empty(t, paginator.Requests("rolebindings")[0].Continue)
+	assert.Empty(t, paginator.Requests("clusterrolebindings")[0].Continue)
+	assert.NotEmpty(t, paginator.Requests("clusterrolebindings")[1].Continue)
+}
+
+// TestKubeUserBuilderList_SinglePages tests that the cluster role bindings are listed when the role bindings fit
+// in a single page.
+func TestKubeUserBuilderList_SinglePages(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "rb", Namespace: "payments"},
+			Subjects:   []rbacv1.Subject{{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "alice"}},
+		},
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "crb"},
+			Subjects:   []rbacv1.Subject{{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "bob"}},
+		},
+	)
+
+	var ids []string
+	for _, resource := range listResources(context.Background(), t, newKubeUserBuilder(client, nil, ConnectorOpts{})) {
+		ids = append(ids, resource.Id.Resource)
+	}
+	assert.Equal(t, []string{"*", "alice", "bob"}, ids)
+}
+
+func TestKubeUserBuilderList_InvalidPhase(t *testing.T) {
+	bag := &pagination.Bag{}
+	bag.Push(pagination.PageState{ResourceTypeID: "secrets"})
+	token, err := bag.Marshal()
+	require.NoError(t, err)
+
+	builder := newKubeUserBuilder(fake.NewSimpleClientset(), nil, ConnectorOpts{})
+	_, _, _, err = builder.List(context.Background(), nil, &pagination.Token{Token: token})
+	assert.ErrorContains(t, err, `unknown binding phase "secrets"`)
+}
+
+// TestKubeUserBuilderList_RepeatedSyncs tests that a builder kept across syncs, as in service mode, lists the
+// same users on every sync.
+func TestKubeUserBuilderList_RepeatedSyncs(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "rb", Namespace: "payments"},
+			Subjects:   []rbacv1.Subject{{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "alice"}},
+		},
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "crb"},
+			Subjects:   []rbacv1.Subject{{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "bob"}},
+		},
+	)
+	builder := newKubeUserBuilder(client, nil, ConnectorOpts{})
+
+	first := listedResourceIDs(listResources(ctx, t, builder))
+	second := listedResourceIDs(listResources(ctx, t, builder))
+	assert.Len(t, first, 3)
+	assert.Equal(t, first, second)
+}
+
+// listedResourceIDs returns the IDs of the resources in the order they were listed.
+func listedResourceIDs(resources []*v2.Resource) []string {
+	var rv []string
+	for _, resource := range resources {
+		rv = append(rv, resourceIDKey(resource.Id))
+	}
+	return rv
+}
@@ -0,0 +1,106 @@
+package connector
+
+import (
+	"testing"
+
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestLabelTags(t *testing.T) {
+	testCases := []struct {
+		name     string
+		labels   map[string]string
+		keys     []string
+		expected map[string]any
+	}{
+		{
+			name:     "label present",
+			labels:   map[string]string{"team": "payments", "tier": "backend"},
+			keys:     []string{"team"},
+			expected: map[string]any{"tag.team": "payments"},
+		},
+		{
+			name:     "label absent",
+			labels:   map[string]string{"tier": "backend"},
+			keys:     []string{"team"},
+			expected: map[string]any{},
+		},
+		{
+			name:     "nil labels",
+			labels:   nil,
+			keys:     []string{"team"},
+			expected: map[string]any{},
+		},
+		{
+			name:     "invalid characters",
+			labels:   map[string]string{"example.com/cost-center": "cc-42", "owner\xff": "alice"},
+			keys:     []string{"example.com/cost-center", "owner\xff"},
+			expected: map[string]any{"tag.example_com_cost-center": "cc-42", "tag.owner_": "alice"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tags := LabelTags(tc.labels, tc.keys)
+			assert.Equal(t, tc.expected, tags)
+
+			// Tags must always be convertible to a profile
+			_, err := structpb.NewStruct(tags)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestRoleResource_LabelTags(t *testing.T) {
+	role := &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-role",
+			Namespace: "test-ns",
+			Labels:    map[string]string{"team": "payments"},
+		},
+	}
+
+	resource, err := roleResource(role, ConnectorOpts{LabelTags: []string{"team", "owner"}}, nil)
+	require.NoError(t, err)
+
+	roleTrait, err := rs.GetRoleTrait(resource)
+	require.NoError(t, err)
+
+	profile := roleTrait.Profile.AsMap()
+	assert.Equal(t, "payments", profile["tag.team"])
+	assert.NotContains(t, profile, "tag.owner")
+}
+
+func TestPodResource_LabelTags(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-pod",
+			Namespace: "test-ns",
+			Labels:    map[string]string{"team": "payments"},
+		},
+	}
+
+	// Without configured keys no tags are attached
+	resource, err := podResource(pod, ConnectorOpts{})
+	require.NoError(t, err)
+	tags := &structpb.Struct{}
+	annos := annotations.Annotations(resource.Annotations)
+	ok, err := annos.Pick(tags)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	resource, err = podResource(pod, ConnectorOpts{LabelTags: []string{"team"}})
+	require.NoError(t, err)
+	annos = annotations.Annotations(resource.Annotations)
+	ok, err = annos.Pick(tags)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, map[string]any{"tag.team": "payments"}, tags.AsMap())
+}
@@ -0,0 +1,186 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// GrantMetadataMountedBy lists the pods a mount grant was derived from.
+	GrantMetadataMountedBy = "mountedByPods"
+
+	// defaultServiceAccountName is the service account of pods that don't name one.
+	defaultServiceAccountName = "default"
+
+	// mountGrantVerb is the entitlement of secrets and configmaps granted to the service accounts of the pods
+	// mounting them.
+	mountGrantVerb = "get"
+)
+
+// GetPodsInNamespace returns all Pods in the namespace, listing them on first use and caching them for the
+// rest of the sync.
+func (k *Kubernetes) GetPodsInNamespace(ctx context.Context, namespace string) ([]corev1.Pod, error) {
+	k.podsMutex.Lock()
+	defer k.podsMutex.Unlock()
+
+	if pods, ok := k.podsCache[namespace]; ok {
+		return pods, nil
+	}
+
+	l := ctxzap.Extract(ctx)
+	l.Debug("loading pods cache", zap.String("namespace", namespace))
+
+	var pods []corev1.Pod
+	continueToken := ""
+	for {
+		opts := metav1.ListOptions{
+			Limit:    k.opts.pageSize(ResourceTypePod.Id),
+			Continue: continueToken,
+		}
+
+		resp, err := k.client.CoreV1().Pods(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, fmt.Errorf("listing pods in namespace %s: %w", namespace, err)
+		}
+		pods = append(pods, resp.Items...)
+
+		if resp.Continue == "" {
+			break
+		}
+		continueToken = resp.Continue
+	}
+
+	if k.podsCache == nil {
+		k.podsCache = make(map[string][]corev1.Pod)
+	}
+	k.podsCache[namespace] = pods
+	return pods, nil
+}
+
+// podMounts holds the names of the secrets and configmaps a pod can read.
+type podMounts struct {
+	secrets    map[string]bool
+	configMaps map[string]bool
+}
+
+// podMountRefs returns the secrets and configmaps a pod can read through its volumes, including projected
+// ones, and the envFrom and env valueFrom of its containers, init containers and ephemeral containers.
+// Optional references are included, as the pod reads the object once it exists. Image pull secrets are left
+// out, as they are read by the kubelet rather than the pod.
+func podMountRefs(pod *corev1.Pod) podMounts {
+	return podSpecMountRefs(&pod.Spec)
+}
+
+// podSpecMountRefs returns the secrets and configmaps the pods of a spec can read, see podMountRefs.
+func podSpecMountRefs(spec *corev1.PodSpec) podMounts {
+	refs := podMounts{
+		secrets:    make(map[string]bool),
+		configMaps: make(map[string]bool),
+	}
+	add := func(set map[string]bool, name string) {
+		if name != "" {
+			set[name] = true
+		}
+	}
+
+	for _, volume := range spec.Volumes {
+		if volume.Secret != nil {
+			add(refs.secrets, volume.Secret.SecretName)
+		}
+		if volume.ConfigMap != nil {
+			add(refs.configMaps, volume.ConfigMap.Name)
+		}
+		if volume.Projected != nil {
+			for _, source := range volume.Projected.Sources {
+				if source.Secret != nil {
+					add(refs.secrets, source.Secret.Name)
+				}
+				if source.ConfigMap != nil {
+					add(refs.configMaps, source.ConfigMap.Name)
+				}
+			}
+		}
+	}
+
+	addContainer := func(envFrom []corev1.EnvFromSource, env []corev1.EnvVar) {
+		for _, source := range envFrom {
+			if source.SecretRef != nil {
+				add(refs.secrets, source.SecretRef.Name)
+			}
+			if source.ConfigMapRef != nil {
+				add(refs.configMaps, source.ConfigMapRef.Name)
+			}
+		}
+		for _, v := range env {
+			if v.ValueFrom == nil {
+				continue
+			}
+			if v.ValueFrom.SecretKeyRef != nil {
+				add(refs.secrets, v.ValueFrom.SecretKeyRef.Name)
+			}
+			if v.ValueFrom.ConfigMapKeyRef != nil {
+				add(refs.configMaps, v.ValueFrom.ConfigMapKeyRef.Name)
+			}
+		}
+	}
+	for _, c := range spec.InitContainers {
+		addContainer(c.EnvFrom, c.Env)
+	}
+	for _, c := range spec.Containers {
+		addContainer(c.EnvFrom, c.Env)
+	}
+	for _, c := range spec.EphemeralContainers {
+		addContainer(c.EnvFrom, c.Env)
+	}
+
+	return refs
+}
+
+// mountGrants returns the get grants of a secret or configmap to the service accounts of the pods mounting
+// it, one per service account, listing the pods in the grant metadata.
+func mountGrants(resource *v2.Resource, namespace string, pods []corev1.Pod, mounts func(*corev1.Pod) bool) []*v2.Grant {
+	podsBySA := make(map[string][]string)
+	for i := range pods {
+		pod := &pods[i]
+		if !mounts(pod) {
+			continue
+		}
+		sa := podSpecServiceAccount(&pod.Spec)
+		podsBySA[sa] = append(podsBySA[sa], pod.Name)
+	}
+
+	serviceAccounts := make([]string, 0, len(podsBySA))
+	for sa := range podsBySA {
+		serviceAccounts = append(serviceAccounts, sa)
+	}
+	sort.Strings(serviceAccounts)
+
+	rv := make([]*v2.Grant, 0, len(serviceAccounts))
+	for _, sa := range serviceAccounts {
+		podNames := podsBySA[sa]
+		sort.Strings(podNames)
+		mountedBy := make([]interface{}, 0, len(podNames))
+		for _, podName := range podNames {
+			mountedBy = append(mountedBy, podName)
+		}
+
+		rv = append(rv, grant.NewGrant(
+			resource,
+			mountGrantVerb,
+			GenerateResourceForGrant(namespacedName(namespace, sa), ResourceTypeServiceAccount.Id),
+			grant.WithGrantMetadata(map[string]interface{}{
+				GrantMetadataMountedBy:   mountedBy,
+				GrantMetadataSubjectKind: SubjectKindServiceAccount,
+			}),
+		))
+	}
+	return rv
+}
@@ -0,0 +1,348 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// mountingPods returns pods in the default namespace referencing the db-creds secret in every supported way,
+// and pods that don't.
+func mountingPods() []runtime.Object {
+	ref := corev1.LocalObjectReference{Name: "db-creds"}
+	return []runtime.Object{
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "db-creds"}},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "api"},
+			Spec: corev1.PodSpec{
+				ServiceAccountName: "api",
+				Volumes: []corev1.Volume{{
+					Name:         "creds",
+					VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "db-creds"}},
+				}},
+			},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "api-canary"},
+			Spec: corev1.PodSpec{
+				ServiceAccountName: "api",
+				Volumes: []corev1.Volume{{
+					Name: "bundle",
+					VolumeSource: corev1.VolumeSource{Projected: &corev1.ProjectedVolumeSource{
+						Sources: []corev1.VolumeProjection{{Secret: &corev1.SecretProjection{LocalObjectReference: ref}}},
+					}},
+				}},
+			},
+		},
+		// Pods without a service account run as default
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "migrate"},
+			Spec: corev1.PodSpec{
+				InitContainers: []corev1.Container{{
+					Name:    "migrate",
+					EnvFrom: []corev1.EnvFromSource{{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: ref}}},
+				}},
+			},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "worker"},
+			Spec: corev1.PodSpec{
+				ServiceAccountName: "worker",
+				Containers: []corev1.Container{{
+					Name: "worker",
+					Env: []corev1.EnvVar{{
+						Name: "DB_PASSWORD",
+						ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+							LocalObjectReference: ref,
+							Key:                  "password",
+						}},
+					}},
+				}},
+			},
+		},
+		// Image pull secrets and other secrets don't count
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+			Spec: corev1.PodSpec{
+				ServiceAccountName: "web",
+				ImagePullSecrets:   []corev1.LocalObjectReference{ref},
+				Volumes: []corev1.Volume{{
+					Name:         "tls",
+					VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "web-tls"}},
+				}},
+			},
+		},
+		// Neither do pods in other namespaces
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "api"},
+			Spec: corev1.PodSpec{
+				ServiceAccountName: "api",
+				Volumes: []corev1.Volume{{
+					Name:         "creds",
+					VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "db-creds"}},
+				}},
+			},
+		},
+	}
+}
+
+// secretGrants returns the grants of a secret synced by the builder.
+func secretGrants(ctx context.Context, t *testing.T, builder *secretBuilder, namespace, name string) []*v2.Grant {
+	resource, err := secretResource(&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}, nil, builder.opts)
+	require.NoError(t, err)
+	grants, _, _, err := builder.Grants(ctx, resource, &pagination.Token{})
+	require.NoError(t, err)
+	return grants
+}
+
+func TestSecretBuilderGrants_MountGrants(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(mountingPods()...)
+	podLists := 0
+	client.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		podLists++
+		return false, nil, nil
+	})
+
+	k := newTestKubernetes(client, ConnectorOpts{MountGrants: true})
+	builder := newSecretBuilder(client, k, nil, k.opts)
+
+	grants := secretGrants(ctx, t, builder, "default", "db-creds")
+	mountedBy := make(map[string][]string)
+	for _, g := range grants {
+		assert.Equal(t, "secret:default/db-creds:get", g.Entitlement.Id)
+		assert.Equal(t, ResourceTypeServiceAccount.Id, g.Principal.Id.ResourceType)
+
+		metadata := &v2.GrantMetadata{}
+		annos := annotations.Annotations(g.Annotations)
+		ok, err := annos.Pick(metadata)
+		require.NoError(t, err)
+		require.True(t, ok)
+		var pods []string
+		for _, v := range metadata.Metadata.Fields[GrantMetadataMountedBy].GetListValue().GetValues() {
+			pods = append(pods, v.GetStringValue())
+		}
+		mountedBy[g.Principal.Id.Resource] = pods
+	}
+	assert.Equal(t, map[string][]string{
+		"default/api":     {"api", "api-canary"},
+		"default/default": {"migrate"},
+		"default/worker":  {"worker"},
+	}, mountedBy)
+
+	// The pods of the namespace are listed once
+	assert.Empty(t, secretGrants(ctx, t, builder, "default", "web-creds"))
+	assert.Equal(t, 1, podLists)
+
+	// The service accounts can be granted the get entitlement
+	resource, err := secretResource(&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "db-creds"}}, nil, k.opts)
+	require.NoError(t, err)
+	entitlements, _, _, err := builder.Entitlements(ctx, resource, &pagination.Token{})
+	require.NoError(t, err)
+	for _, ent := range entitlements {
+		var grantable []string
+		for _, rt := range ent.GrantableTo {
+			grantable = append(grantable, rt.Id)
+		}
+		if ent.Slug == mountGrantVerb {
+			assert.Contains(t, grantable, ResourceTypeServiceAccount.Id)
+		} else {
+			assert.NotContains(t, grantable, ResourceTypeServiceAccount.Id, ent.Slug)
+		}
+	}
+}
+
+func TestSecretBuilderGrants_MountGrantsDisabled(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(mountingPods()...)
+	client.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		t.Fatal("pods listed with mount grants disabled")
+		return false, nil, nil
+	})
+
+	k := newTestKubernetes(client, ConnectorOpts{})
+	assert.Empty(t, secretGrants(ctx, t, newSecretBuilder(client, k, nil, k.opts), "default", "db-creds"))
+}
+
+func TestConfigMapBuilderGrants_MountGrants(t *testing.T) {
+	ctx := context.Background()
+	optional := true
+	ref := corev1.LocalObjectReference{Name: "app-config"}
+	client := fake.NewSimpleClientset(
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "api-0"},
+			Spec: corev1.PodSpec{
+				ServiceAccountName: "api",
+				Volumes: []corev1.Volume{{
+					Name: "config",
+					VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+						LocalObjectReference: ref,
+						Optional:             &optional,
+					}},
+				}},
+			},
+		},
+		// Another replica with the same service account, consuming it twice
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "api-1"},
+			Spec: corev1.PodSpec{
+				ServiceAccountName: "api",
+				Volumes: []corev1.Volume{{
+					Name: "bundle",
+					VolumeSource: corev1.VolumeSource{Projected: &corev1.ProjectedVolumeSource{
+						Sources: []corev1.VolumeProjection{{ConfigMap: &corev1.ConfigMapProjection{LocalObjectReference: ref}}},
+					}},
+				}},
+				Containers: []corev1.Container{{
+					Name:    "api",
+					EnvFrom: []corev1.EnvFromSource{{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: ref, Optional: &optional}}},
+				}},
+			},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "worker"},
+			Spec: corev1.PodSpec{
+				ServiceAccountName: "worker",
+				Containers: []corev1.Container{{
+					Name: "worker",
+					Env: []corev1.EnvVar{{
+						Name: "LOG_LEVEL",
+						ValueFrom: &corev1.EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+							LocalObjectReference: ref,
+							Key:                  "logLevel",
+						}},
+					}},
+				}},
+			},
+		},
+		// A secret of the same name isn't the configmap
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+			Spec: corev1.PodSpec{
+				ServiceAccountName: "web",
+				Volumes: []corev1.Volume{{
+					Name:         "config",
+					VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "app-config"}},
+				}},
+			},
+		},
+	)
+
+	k := newTestKubernetes(client, ConnectorOpts{MountGrants: true})
+	builder := newConfigMapBuilder(client, k, k, k.opts)
+	resource, err := configMapResource(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app-config"}}, k.opts)
+	require.NoError(t, err)
+
+	grants, _, _, err := builder.Grants(ctx, resource, &pagination.Token{})
+	require.NoError(t, err)
+	var ids []string
+	for _, g := range grants {
+		ids = append(ids, g.Id)
+	}
+	assert.Equal(t, []string{
+		"configmap:default/app-config:get:service_account:default/api",
+		"configmap:default/app-config:get:service_account:default/worker",
+	}, ids)
+}
+
+// TestConfigMapBuilder_WorkloadMountGrants tests that the workloads whose pod template consumes a configmap,
+// through envFrom or a volume, are granted its mounted_by entitlement whether or not their pods are running.
+func TestConfigMapBuilder_WorkloadMountGrants(t *testing.T) {
+	ctx := context.Background()
+	template := func(spec corev1.PodSpec) corev1.PodTemplateSpec {
+		return corev1.PodTemplateSpec{Spec: spec}
+	}
+	client := fake.NewSimpleClientset(
+		// Environment from the configmap
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "api"},
+			Spec: appsv1.DeploymentSpec{Template: template(corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Name: "api",
+					EnvFrom: []corev1.EnvFromSource{{
+						ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "app-config"}},
+					}},
+				}},
+			})},
+		},
+		// Files from the configmap
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "db"},
+			Spec: appsv1.StatefulSetSpec{Template: template(corev1.PodSpec{
+				Volumes: []corev1.Volume{{
+					Name: "config",
+					VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "app-config"},
+					}},
+				}},
+			})},
+		},
+		// Another configmap
+		&appsv1.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "agent"},
+			Spec: appsv1.DaemonSetSpec{Template: template(corev1.PodSpec{
+				Volumes: []corev1.Volume{{
+					Name: "config",
+					VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "agent-config"},
+					}},
+				}},
+			})},
+		},
+		// The same name in another namespace
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "api"},
+			Spec: appsv1.DeploymentSpec{Template: template(corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Name: "api",
+					EnvFrom: []corev1.EnvFromSource{{
+						ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "app-config"}},
+					}},
+				}},
+			})},
+		},
+	)
+
+	k := newTestKubernetes(client, ConnectorOpts{MountGrants: true})
+	builder := newConfigMapBuilder(client, k, k, k.opts)
+	resource, err := configMapResource(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app-config"}}, k.opts)
+	require.NoError(t, err)
+
+	entitlements, _, _, err := builder.Entitlements(ctx, resource, &pagination.Token{})
+	require.NoError(t, err)
+	var slugs []string
+	for _, ent := range entitlements {
+		slugs = append(slugs, ent.Slug)
+	}
+	assert.Contains(t, slugs, ConfigMapMountedByEntitlement)
+
+	grants, _, _, err := builder.Grants(ctx, resource, &pagination.Token{})
+	require.NoError(t, err)
+	var ids []string
+	for _, g := range grants {
+		ids = append(ids, g.Id)
+	}
+	assert.Equal(t, []string{
+		"configmap:default/app-config:mounted_by:deployment:default/api",
+		"configmap:default/app-config:mounted_by:statefulset:default/db",
+	}, ids)
+
+	// Workload types that aren't synced aren't granted
+	k = newTestKubernetes(client, ConnectorOpts{MountGrants: true, SyncResources: []string{ResourceTypeConfigMap.Id, ResourceTypeDeployment.Id}})
+	builder = newConfigMapBuilder(client, k, k, k.opts)
+	grants, _, _, err = builder.Grants(ctx, resource, &pagination.Token{})
+	require.NoError(t, err)
+	require.Len(t, grants, 1)
+	assert.Equal(t, "configmap:default/app-config:mounted_by:deployment:default/api", grants[0].Id)
+}
@@ -2,6 +2,7 @@ package connector
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	corev1 "k8s.io/api/core/v1"
@@ -11,14 +12,28 @@ import (
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/annotations"
 	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
 	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
 	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
-// namespaceBuilder syncs Kubernetes Namespaces as Baton resources.
+// NamespaceMemberEntitlement is the entitlement of a namespace granted to the service accounts in it.
+const NamespaceMemberEntitlement = "member"
+
+// namespaceAccessClusterRoles are the built-in user-facing ClusterRoles whose bindings are summarized as the
+// namespace entitlement of the same name.
+var namespaceAccessClusterRoles = []string{"admin", "edit", "view"}
+
+// namespaceBuilder syncs Kubernetes Namespaces as Baton resources. It's safe for concurrent use, its only
+// state being the connector's locked bindings caches and coverage verifier.
 type namespaceBuilder struct {
-	client kubernetes.Interface
+	client          kubernetes.Interface
+	bindingProvider ClusterRoleBindingProvider
+	opts            ConnectorOpts
+	coverage        *coverageVerifier
 }
 
 // ResourceType returns the resource type for Namespace.
@@ -40,8 +55,8 @@ func (n *namespaceBuilder) List(ctx context.Context, parentResourceID *v2.Resour
 	}
 
 	// Add wildcard resource first, but only on the first page (when page token is empty)
-	if bag.PageToken() == "" {
-		wildcardResource, err := generateWildcardResource(ResourceTypeNamespace)
+	if bag.PageToken() == "" && !n.opts.DisableWildcardResources {
+		wildcardResource, err := generateWildcardResource(ResourceTypeNamespace, "")
 		if err != nil {
 			l.Error("failed to create wildcard resource for namespaces", zap.Error(err))
 		} else {
@@ -51,25 +66,29 @@ func (n *namespaceBuilder) List(ctx context.Context, parentResourceID *v2.Resour
 
 	// Set up list options with pagination
 	opts := metav1.ListOptions{
-		Limit:    ResourcesPageSize,
+		Limit:    n.opts.pageSize(ResourceTypeNamespace.Id),
 		Continue: bag.PageToken(),
 	}
 
 	// Fetch namespaces from the Kubernetes API
 	l.Debug("fetching namespaces", zap.String("continue_token", opts.Continue))
-	resp, err := n.client.CoreV1().Namespaces().List(ctx, opts)
+	resp, err := listWithRetry(ctx, n.opts, func(ctx context.Context) (*corev1.NamespaceList, error) {
+		return n.client.CoreV1().Namespaces().List(ctx, opts)
+	})
 	if err != nil {
 		return nil, "", nil, fmt.Errorf("failed to list namespaces: %w", err)
 	}
 
 	// Process each namespace into a Baton resource
 	for _, ns := range resp.Items {
-		resource, err := namespaceResource(&ns)
+		counts := namespaceObjectCounts(ctx, n.client, ns.Name, n.opts)
+		resource, err := namespaceResource(&ns, counts, n.opts)
 		if err != nil {
 			l.Error("failed to create namespace resource", zap.String("namespace", ns.Name), zap.Error(err))
 			continue
 		}
 		rv = append(rv, resource)
+		n.coverage.record(ns.Name)
 	}
 
 	// Calculate next page token
@@ -78,28 +97,78 @@ func (n *namespaceBuilder) List(ctx context.Context, parentResourceID *v2.Resour
 		return nil, "", nil, fmt.Errorf("failed to handle pagination: %w", err)
 	}
 
+	// Verify read access once every namespace has been synced. Failing to verify isn't fatal, gaps are.
+	if nextPageToken == "" {
+		if err := n.coverage.complete(ctx); err != nil {
+			if errors.Is(err, ErrPartialSync) {
+				return nil, "", nil, err
+			}
+			l.Warn("failed to verify namespace coverage", zap.Error(err))
+		}
+	}
+
 	return rv, nextPageToken, nil, nil
 }
 
-// namespaceResource creates a Baton resource from a Kubernetes Namespace.
-func namespaceResource(ns *corev1.Namespace) (*v2.Resource, error) {
+// namespaceChildResourceTypes are the namespaced resource types the SDK lists in each namespace.
+var namespaceChildResourceTypes = []*v2.ResourceType{
+	ResourceTypeServiceAccount,
+	ResourceTypeRole,
+	ResourceTypeSecret,
+	ResourceTypeConfigMap,
+	ResourceTypeService,
+	ResourceTypePod,
+	ResourceTypeDeployment,
+	ResourceTypeStatefulSet,
+	ResourceTypeDaemonSet,
+	ResourceTypeReplicaSet,
+}
+
+// namespaceResource creates a Baton resource from a Kubernetes Namespace and the counts of the objects in it.
+func namespaceResource(ns *corev1.Namespace, counts map[string]int64, opts ConnectorOpts) (*v2.Resource, error) {
 	// Prepare profile with standard metadata
 	profile := map[string]interface{}{
 		"name":              ns.Name,
 		"uid":               string(ns.UID),
 		"creationTimestamp": ns.CreationTimestamp.String(),
 		"labels":            StringMapToAnyMap(ns.Labels),
-		"annotations":       StringMapToAnyMap(ns.Annotations),
+		"annotations":       profileAnnotations(ns.Annotations, opts, false),
 	}
 
 	// Add status phase if available
 	if ns.Status.Phase != "" {
 		profile["status.phase"] = string(ns.Status.Phase)
 	}
+	for key, count := range counts {
+		profile[key] = count
+	}
+	repairProfile(profile)
+
+	// Create resource with options, the namespaced resource types synced being listed as its children
+	var options []rs.ResourceOption
+	for _, rt := range namespaceChildResourceTypes {
+		if opts.syncsResourceType(rt.Id) {
+			options = append(options, rs.WithAnnotation(&v2.ChildResourceType{ResourceTypeId: rt.Id}))
+		}
+	}
+
+	// Add configured label tags
+	tagOptions, err := labelTagOptions(ns.Labels, opts)
+	if err != nil {
+		return nil, err
+	}
+	options = append(options, tagOptions...)
+
+	// Namespaces don't have a trait, so the profile is attached as a struct annotation after the label tags
+	profileStruct, err := structpb.NewStruct(profile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create namespace profile: %w", err)
+	}
+	options = append(options, rs.WithAnnotation(profileStruct))
 
-	// Create resource with options
-	options := []rs.ResourceOption{
-		rs.WithAnnotation(&v2.ChildResourceType{ResourceTypeId: ResourceTypeServiceAccount.Id}),
+	// Add external ID if available
+	if len(ns.UID) > 0 {
+		options = append(options, rs.WithExternalID(&v2.ExternalId{Id: string(ns.UID)}))
 	}
 
 	// Pass the raw name as the object ID
@@ -116,19 +185,162 @@ func namespaceResource(ns *corev1.Namespace) (*v2.Resource, error) {
 	return resource, nil
 }
 
-// Entitlements returns no entitlements for Namespace resources.
+// Entitlements returns standard verb entitlements for Namespace resources.
 func (n *namespaceBuilder) Entitlements(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
-	return nil, "", nil, nil
+	var entitlements []*v2.Entitlement
+
+	// Add standard verb entitlements
+	for _, verb := range n.opts.resourceVerbs(ResourceTypeNamespace.Id) {
+		ent := entitlement.NewPermissionEntitlement(
+			resource,
+			verb,
+			entitlement.WithDisplayName(fmt.Sprintf("%s %s", verb, resource.DisplayName)),
+			entitlement.WithDescription(fmt.Sprintf("Grants %s permission on the %s namespace", verb, resource.DisplayName)),
+			entitlement.WithGrantableTo(
+				ResourceTypeClusterRole,
+			),
+		)
+		entitlements = append(entitlements, ent)
+	}
+
+	if resource.Id.Resource != "*" {
+		// Add an entitlement for each built-in admin, edit and view ClusterRole, granted by the bindings to it
+		for _, roleName := range namespaceAccessClusterRoles {
+			ent := entitlement.NewPermissionEntitlement(
+				resource,
+				roleName,
+				entitlement.WithDisplayName(fmt.Sprintf("%s %s", roleName, resource.DisplayName)),
+				entitlement.WithDescription(fmt.Sprintf("Bound to the %s cluster role in the %s namespace, or cluster-wide", roleName, resource.DisplayName)),
+				entitlement.WithGrantableTo(memberGrantableTo(n.opts)...),
+			)
+			entitlements = append(entitlements, ent)
+		}
+
+		// Add 'member' entitlement, granted to the service accounts in the namespace
+		memberEnt := entitlement.NewAssignmentEntitlement(
+			resource,
+			NamespaceMemberEntitlement,
+			entitlement.WithDisplayName(fmt.Sprintf("%s namespace member", resource.DisplayName)),
+			entitlement.WithDescription(fmt.Sprintf("Service accounts in the %s namespace", resource.DisplayName)),
+			entitlement.WithGrantableTo(ResourceTypeServiceAccount),
+		)
+		entitlements = append(entitlements, memberEnt)
+	}
+
+	return entitlements, "", nil, nil
+}
+
+// Grants returns the member grants of the service accounts in the Namespace, a page of service accounts at a time,
+// and on the first page the admin, edit and view grants of the subjects bound to those ClusterRoles.
+func (n *namespaceBuilder) Grants(ctx context.Context, resource *v2.Resource, pToken *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+
+	if resource.Id.Resource == "*" {
+		return nil, "", nil, nil
+	}
+
+	// Parse pagination token
+	bag, err := ParsePageToken(pToken.Token)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to parse page token: %w", err)
+	}
+
+	var rv []*v2.Grant
+	if bag.PageToken() == "" {
+		accessGrants, err := n.accessGrants(ctx, resource)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		rv = append(rv, accessGrants...)
+	}
+
+	// Set up list options with pagination
+	opts := metav1.ListOptions{
+		Limit:    n.opts.pageSize(ResourceTypeServiceAccount.Id),
+		Continue: bag.PageToken(),
+	}
+
+	// Fetch the service accounts of the namespace
+	l.Debug("fetching namespace service accounts",
+		zap.String("namespace", resource.Id.Resource),
+		zap.String("continue_token", opts.Continue))
+	resp, err := n.client.CoreV1().ServiceAccounts(resource.Id.Resource).List(ctx, opts)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to list service accounts in namespace %s: %w", resource.Id.Resource, err)
+	}
+
+	for _, sa := range resp.Items {
+		principal := &v2.ResourceId{
+			ResourceType: ResourceTypeServiceAccount.Id,
+			Resource:     namespacedName(sa.Namespace, sa.Name),
+		}
+		rv = append(rv, grant.NewGrant(resource, NamespaceMemberEntitlement, principal))
+	}
+
+	// Calculate next page token
+	nextPageToken, err := HandleKubePagination(&resp.ListMeta, bag)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to handle pagination: %w", err)
+	}
+
+	return rv, nextPageToken, nil, nil
 }
 
-// Grants returns no grants for Namespace resources.
-func (n *namespaceBuilder) Grants(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
-	return nil, "", nil, nil
+// accessGrants returns the admin, edit and view grants of the Namespace to the subjects of the RoleBindings in
+// it, and the ClusterRoleBindings, binding those ClusterRoles.
+func (n *namespaceBuilder) accessGrants(ctx context.Context, resource *v2.Resource) ([]*v2.Grant, error) {
+	l := ctxzap.Extract(ctx)
+
+	if n.bindingProvider == nil {
+		return nil, nil
+	}
+
+	var rv []*v2.Grant
+	for _, roleName := range namespaceAccessClusterRoles {
+		roleBindings, clusterRoleBindings, err := n.bindingProvider.GetMatchingBindingsForClusterRole(ctx, roleName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get bindings for cluster role %s: %w", roleName, err)
+		}
+
+		for _, binding := range roleBindings {
+			if binding.Namespace != resource.Id.Resource {
+				continue
+			}
+			for _, subject := range binding.Subjects {
+				g, err := grantRoleToSubject(subject, resource, roleName, n.opts,
+					bindingGrantOption(BindingKindRoleBinding, binding.ObjectMeta))
+				if err != nil {
+					logSkippedSubject(l, subject, err)
+					continue
+				}
+				rv = append(rv, g)
+			}
+		}
+
+		// A ClusterRoleBinding grants the role in every namespace
+		for _, binding := range clusterRoleBindings {
+			for _, subject := range binding.Subjects {
+				g, err := grantRoleToSubject(subject, resource, roleName, n.opts,
+					bindingGrantOption(BindingKindClusterRoleBinding, binding.ObjectMeta))
+				if err != nil {
+					logSkippedSubject(l, subject, err)
+					continue
+				}
+				rv = append(rv, g)
+			}
+		}
+	}
+
+	return uniqueGrants(rv), nil
 }
 
 // newNamespaceBuilder creates a new namespace builder.
-func newNamespaceBuilder(client kubernetes.Interface) *namespaceBuilder {
+func newNamespaceBuilder(client kubernetes.Interface, bindingProvider ClusterRoleBindingProvider, opts ConnectorOpts,
+	coverage *coverageVerifier) *namespaceBuilder {
 	return &namespaceBuilder{
-		client: client,
+		client:          client,
+		bindingProvider: bindingProvider,
+		opts:            opts,
+		coverage:        coverage,
 	}
 }
@@ -2,10 +2,24 @@ package connector
 
 import (
 	"context"
+	"errors"
 	"testing"
 
+	"github.com/conductorone/baton-kubernetes/pkg/kubetest"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/utils/ptr"
 )
 
 func TestNamespaceBuilderResourceType(t *testing.T) {
@@ -23,3 +37,219 @@ func TestNamespaceBuilderResourceType(t *testing.T) {
 	// Verify the result
 	assert.Equal(t, ResourceTypeNamespace, resourceType, "Expected ResourceType to return resourceTypeNamespace")
 }
+
+// TestNamespaceResource_ExternalID tests that namespaces are correlated across syncs by their UID.
+func TestNamespaceResource_ExternalID(t *testing.T) {
+	resource, err := namespaceResource(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "payments", UID: "ns-uid"}}, nil, ConnectorOpts{})
+	require.NoError(t, err)
+	require.NotNil(t, resource.ExternalId)
+	assert.Equal(t, "ns-uid", resource.ExternalId.Id)
+
+	// Objects without a UID, like those of tests, have no external ID
+	resource, err = namespaceResource(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "payments"}}, nil, ConnectorOpts{})
+	require.NoError(t, err)
+	assert.Nil(t, resource.ExternalId)
+}
+
+// TestNamespaceBuilderList_ObjectCounts tests that the namespace profile counts the pods, secrets and configmaps
+// of the synced types, from the remaining item count of the API server when it pages.
+func TestNamespaceBuilderList_ObjectCounts(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "payments"}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "api"}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "db-creds"}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "tls"}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "settings"}},
+	)
+	// Large namespaces are paged, the API server reporting the number of remaining items, or not
+	client.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, &corev1.PodList{
+			ListMeta: metav1.ListMeta{Continue: "next", RemainingItemCount: ptr.To(int64(41))},
+			Items:    []corev1.Pod{{ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "api"}}},
+		}, nil
+	})
+	client.PrependReactor("list", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, &corev1.ConfigMapList{
+			ListMeta: metav1.ListMeta{Continue: "next"},
+			Items:    []corev1.ConfigMap{{ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "settings"}}},
+		}, nil
+	})
+
+	profile := func(opts ConnectorOpts) map[string]interface{} {
+		opts.DisableWildcardResources = true
+		resources, _, _, err := newNamespaceBuilder(client, nil, opts, nil).List(ctx, nil, &pagination.Token{})
+		require.NoError(t, err)
+		require.Len(t, resources, 1)
+		return namespaceProfile(t, resources[0])
+	}
+
+	got := profile(ConnectorOpts{})
+	assert.Equal(t, "payments", got["name"])
+	assert.Equal(t, float64(42), got["podCount"])
+	assert.Equal(t, float64(2), got["secretCount"])
+	assert.NotContains(t, got, "configMapCount")
+
+	// Only the synced types are counted
+	got = profile(ConnectorOpts{SyncResources: []string{ResourceTypeNamespace.Id, ResourceTypeSecret.Id}})
+	assert.NotContains(t, got, "podCount")
+	assert.Equal(t, float64(2), got["secretCount"])
+}
+
+// TestNamespaceBuilderList_ObjectCountsRetry tests that the count lists are retried when they fail transiently.
+func TestNamespaceBuilderList_ObjectCountsRetry(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "payments"}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "db-creds"}},
+	)
+	calls := failListing(client, "secrets", k8serrors.NewInternalError(errors.New("etcd leader changed")))
+	opts := ConnectorOpts{
+		SyncResources:            []string{ResourceTypeNamespace.Id, ResourceTypeSecret.Id},
+		DisableWildcardResources: true,
+		ListRetries:              1,
+	}
+
+	resources, _, _, err := newNamespaceBuilder(client, nil, opts, nil).List(context.Background(), nil, &pagination.Token{})
+	require.NoError(t, err)
+	require.Len(t, resources, 1)
+	assert.Equal(t, 2, *calls)
+	assert.Equal(t, float64(1), namespaceProfile(t, resources[0])["secretCount"])
+}
+
+// TestNamespaceResource_LabelTags tests that the label tags of a namespace keep their own annotation, ahead of the
+// profile.
+func TestNamespaceResource_LabelTags(t *testing.T) {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "payments", Labels: map[string]string{"team": "payments"}}}
+	resource, err := namespaceResource(ns, map[string]int64{"podCount": 3}, ConnectorOpts{LabelTags: []string{"team"}})
+	require.NoError(t, err)
+
+	tags := &structpb.Struct{}
+	annos := annotations.Annotations(resource.Annotations)
+	ok, err := annos.Pick(tags)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, map[string]any{"tag.team": "payments"}, tags.AsMap())
+
+	profile := namespaceProfile(t, resource)
+	assert.Equal(t, float64(3), profile["podCount"])
+	assert.NotContains(t, profile, "tag.team")
+}
+
+// namespaceProfile returns the profile annotation of a namespace resource.
+func namespaceProfile(t *testing.T, resource *v2.Resource) map[string]interface{} {
+	t.Helper()
+	for _, a := range resource.Annotations {
+		profile := &structpb.Struct{}
+		if !a.MessageIs(profile) {
+			continue
+		}
+		require.NoError(t, a.UnmarshalTo(profile))
+		if _, ok := profile.Fields["name"]; ok {
+			return profile.AsMap()
+		}
+	}
+	require.Fail(t, "namespace profile not found")
+	return nil
+}
+
+func TestNamespaceBuilderGrants_ServiceAccountMembers(t *testing.T) {
+	ctx := context.Background()
+	var objects []runtime.Object
+	for _, name := range []string{"api", "default", "deployer", "worker"} {
+		objects = append(objects, &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: name}})
+	}
+	objects = append(objects, &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "default"}})
+	client := fake.NewSimpleClientset(objects...)
+	paginator := kubetest.Paginate(client, []string{"serviceaccounts"}, kubetest.WithPageSize(3))
+
+	builder := newNamespaceBuilder(client, nil, ConnectorOpts{}, nil)
+	resource := GenerateResourceForGrant("payments", ResourceTypeNamespace.Id)
+
+	var members []string
+	token := &pagination.Token{}
+	pages := 0
+	for {
+		grants, next, _, err := builder.Grants(ctx, resource, token)
+		require.NoError(t, err)
+		pages++
+		for _, g := range grants {
+			assert.Equal(t, "namespace:payments:"+NamespaceMemberEntitlement, g.Entitlement.Id)
+			assert.Equal(t, ResourceTypeServiceAccount.Id, g.Principal.Id.ResourceType)
+			members = append(members, g.Principal.Id.Resource)
+		}
+		if next == "" {
+			break
+		}
+		token = &pagination.Token{Token: next}
+	}
+
+	assert.Equal(t, 2, pages)
+	assert.Len(t, paginator.Requests("serviceaccounts"), 2)
+	assert.Equal(t, []string{"payments/api", "payments/default", "payments/deployer", "payments/worker"}, members)
+
+	// The wildcard namespace has no members
+	grants, _, _, err := builder.Grants(ctx, GenerateResourceForGrant("*", ResourceTypeNamespace.Id), &pagination.Token{})
+	require.NoError(t, err)
+	assert.Empty(t, grants)
+}
+
+func TestNamespaceBuilderGrants_BuiltinClusterRoles(t *testing.T) {
+	ctx := context.Background()
+	clusterRoleRef := func(name string) rbacv1.RoleRef {
+		return rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindClusterRole, Name: name}
+	}
+	client := fake.NewSimpleClientset(
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "owners"},
+			RoleRef:    clusterRoleRef("admin"),
+			Subjects: []rbacv1.Subject{
+				{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "alice"},
+				{Kind: SubjectKindServiceAccount, Namespace: "payments", Name: "ci"},
+			},
+		},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "editors"},
+			RoleRef:    clusterRoleRef("edit"),
+			Subjects:   []rbacv1.Subject{{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "bob"}},
+		},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "readers"},
+			RoleRef:    clusterRoleRef("secret-reader"),
+			Subjects:   []rbacv1.Subject{{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "carol"}},
+		},
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "viewers"},
+			RoleRef:    clusterRoleRef("view"),
+			Subjects:   []rbacv1.Subject{{Kind: SubjectKindGroup, APIGroup: RBACAPIGroup, Name: "devs"}},
+		},
+	)
+	k := newTestKubernetes(client, ConnectorOpts{})
+	builder := newNamespaceBuilder(client, k, ConnectorOpts{}, nil)
+
+	grants, next, _, err := builder.Grants(ctx, GenerateResourceForGrant("payments", ResourceTypeNamespace.Id), &pagination.Token{})
+	require.NoError(t, err)
+	assert.Empty(t, next)
+
+	var got []string
+	for _, g := range grants {
+		got = append(got, g.Entitlement.Id+" "+g.Principal.Id.ResourceType+":"+g.Principal.Id.Resource)
+	}
+	assert.ElementsMatch(t, []string{
+		"namespace:payments:admin kube_user:alice",
+		"namespace:payments:admin service_account:payments/ci",
+		"namespace:payments:view kube_group:devs",
+	}, got)
+
+	// The entitlements are only offered on actual namespaces
+	entitlements, _, _, err := builder.Entitlements(ctx, GenerateResourceForGrant("payments", ResourceTypeNamespace.Id), &pagination.Token{})
+	require.NoError(t, err)
+	var slugs []string
+	for _, ent := range entitlements {
+		slugs = append(slugs, ent.Slug)
+	}
+	assert.Subset(t, slugs, []string{"admin", "edit", "view", NamespaceMemberEntitlement})
+
+	entitlements, _, _, err = builder.Entitlements(ctx, GenerateResourceForGrant("*", ResourceTypeNamespace.Id), &pagination.Token{})
+	require.NoError(t, err)
+	assert.Len(t, entitlements, len(standardResourceVerbs))
+}
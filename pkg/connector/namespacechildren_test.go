@@ -0,0 +1,194 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// TestNamespacedBuilders_ParentScopedListing tests that the namespaced builders list the children of a
+// namespace with a namespace-scoped call, leaving the objects out of the top-level listing, and that they list
+// every namespace at the top level when namespaces aren't synced.
+func TestNamespacedBuilders_ParentScopedListing(t *testing.T) {
+	ctx := context.Background()
+	var objects []runtime.Object
+	for _, namespace := range []string{"payments", "billing"} {
+		meta := metav1.ObjectMeta{Namespace: namespace, Name: "app"}
+		objects = append(objects,
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}},
+			&corev1.Secret{ObjectMeta: meta},
+			&corev1.ConfigMap{ObjectMeta: meta},
+			&corev1.Service{ObjectMeta: meta},
+			&corev1.Pod{ObjectMeta: meta},
+			&appsv1.Deployment{ObjectMeta: meta},
+			&appsv1.StatefulSet{ObjectMeta: meta},
+			&appsv1.DaemonSet{ObjectMeta: meta},
+			&appsv1.ReplicaSet{ObjectMeta: meta},
+			&rbacv1.Role{ObjectMeta: meta},
+		)
+	}
+	client := fake.NewSimpleClientset(objects...)
+
+	for _, opts := range []ConnectorOpts{{NamespaceWildcards: true}, {NamespaceWildcards: true, SyncResources: namespaceChildTypeIDs()}} {
+		for _, builder := range namespacedBuilders(client, opts) {
+			resourceTypeID := builder.ResourceType(ctx).Id
+
+			// The top-level listing only has the cluster-wide wildcard when the objects are listed as children,
+			// and covers every namespace otherwise
+			client.ClearActions()
+			resources, _, _, err := builder.List(ctx, nil, &pagination.Token{})
+			require.NoError(t, err, resourceTypeID)
+			if opts.listedAsNamespaceChildren(resourceTypeID) {
+				assert.Equal(t, []string{"*"}, resourceIDs(resources), resourceTypeID)
+				assert.Empty(t, client.Actions(), resourceTypeID)
+			} else {
+				assert.ElementsMatch(t, []string{"*", "payments/*", "billing/*", "payments/app", "billing/app"},
+					resourceIDs(resources), resourceTypeID)
+			}
+
+			// The children of a namespace are listed in the namespace, with its wildcard
+			client.ClearActions()
+			parent := &v2.ResourceId{ResourceType: ResourceTypeNamespace.Id, Resource: "payments"}
+			resources, _, _, err = builder.List(ctx, parent, &pagination.Token{})
+			require.NoError(t, err, resourceTypeID)
+			assert.Equal(t, []string{"payments/*", "payments/app"}, resourceIDs(resources), resourceTypeID)
+			require.NotEmpty(t, client.Actions(), resourceTypeID)
+			for _, action := range client.Actions() {
+				if action.GetVerb() == "list" {
+					assert.Equal(t, "payments", action.GetNamespace(), resourceTypeID)
+				}
+			}
+		}
+	}
+}
+
+// TestNamespacedBuilders_ListedOnce tests that each object of a namespaced type is listed once in a sync, listing
+// every type at the top level, then the children of every namespace, as the SDK does.
+func TestNamespacedBuilders_ListedOnce(t *testing.T) {
+	ctx := context.Background()
+	var objects []runtime.Object
+	for _, namespace := range []string{"payments", "billing"} {
+		objects = append(objects, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}})
+		for _, name := range []string{"api", "worker"} {
+			meta := metav1.ObjectMeta{Namespace: namespace, Name: name}
+			objects = append(objects,
+				&corev1.Secret{ObjectMeta: meta},
+				&corev1.ConfigMap{ObjectMeta: meta},
+				&corev1.Service{ObjectMeta: meta},
+				&corev1.Pod{ObjectMeta: meta},
+				&appsv1.Deployment{ObjectMeta: meta},
+				&appsv1.StatefulSet{ObjectMeta: meta},
+				&appsv1.DaemonSet{ObjectMeta: meta},
+				&appsv1.ReplicaSet{ObjectMeta: meta},
+				&rbacv1.Role{ObjectMeta: meta},
+			)
+		}
+	}
+	client := fake.NewSimpleClientset(objects...)
+	opts := ConnectorOpts{NamespaceWildcards: true}
+
+	for _, builder := range namespacedBuilders(client, opts) {
+		resourceTypeID := builder.ResourceType(ctx).Id
+		listed := make(map[string]int)
+		for _, parent := range []*v2.ResourceId{
+			nil,
+			{ResourceType: ResourceTypeNamespace.Id, Resource: "payments"},
+			{ResourceType: ResourceTypeNamespace.Id, Resource: "billing"},
+		} {
+			resources, _, _, err := builder.List(ctx, parent, &pagination.Token{})
+			require.NoError(t, err, resourceTypeID)
+			for _, id := range resourceIDs(resources) {
+				listed[id]++
+			}
+		}
+		assert.Equal(t, map[string]int{
+			"*":               1,
+			"payments/*":      1,
+			"billing/*":       1,
+			"payments/api":    1,
+			"payments/worker": 1,
+			"billing/api":     1,
+			"billing/worker":  1,
+		}, listed, resourceTypeID)
+	}
+}
+
+// namespacedBuilders returns the builders of the namespaced resource types listed as children of the namespaces,
+// other than service accounts.
+func namespacedBuilders(client *fake.Clientset, opts ConnectorOpts) []connectorbuilder.ResourceSyncer {
+	return []connectorbuilder.ResourceSyncer{
+		newSecretBuilder(client, nil, nil, opts),
+		newConfigMapBuilder(client, nil, nil, opts),
+		newServiceBuilder(client, opts),
+		newPodBuilder(client, opts),
+		newDeploymentBuilder(client, opts),
+		newStatefulSetBuilder(client, opts),
+		newDaemonSetBuilder(client, opts),
+		newReplicaSetBuilder(client, opts),
+		newRoleBuilder(client, nil, opts, nil),
+	}
+}
+
+// namespaceChildTypeIDs returns the IDs of namespaceChildResourceTypes.
+func namespaceChildTypeIDs() []string {
+	var ids []string
+	for _, rt := range namespaceChildResourceTypes {
+		ids = append(ids, rt.Id)
+	}
+	return ids
+}
+
+// resourceIDs returns the IDs of the resources.
+func resourceIDs(resources []*v2.Resource) []string {
+	var ids []string
+	for _, resource := range resources {
+		ids = append(ids, resource.Id.Resource)
+	}
+	return ids
+}
+
+func TestNamespaceResource_ChildResourceTypes(t *testing.T) {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "payments"}}
+	childTypes := func(opts ConnectorOpts) []string {
+		resource, err := namespaceResource(ns, nil, opts)
+		require.NoError(t, err)
+		var ids []string
+		for _, a := range resource.Annotations {
+			childType := &v2.ChildResourceType{}
+			if a.MessageIs(childType) {
+				require.NoError(t, a.UnmarshalTo(childType))
+				ids = append(ids, childType.ResourceTypeId)
+			}
+		}
+		return ids
+	}
+
+	assert.Equal(t, []string{
+		ResourceTypeServiceAccount.Id,
+		ResourceTypeRole.Id,
+		ResourceTypeSecret.Id,
+		ResourceTypeConfigMap.Id,
+		ResourceTypeService.Id,
+		ResourceTypePod.Id,
+		ResourceTypeDeployment.Id,
+		ResourceTypeStatefulSet.Id,
+		ResourceTypeDaemonSet.Id,
+		ResourceTypeReplicaSet.Id,
+	}, childTypes(ConnectorOpts{}))
+
+	// Only the synced resource types are children, as the SDK fails listing types without a syncer
+	assert.Equal(t, []string{ResourceTypeServiceAccount.Id, ResourceTypeSecret.Id},
+		childTypes(ConnectorOpts{SyncResources: []string{ResourceTypeNamespace.Id, ResourceTypeSecret.Id, ResourceTypeServiceAccount.Id}}))
+	assert.Empty(t, childTypes(ConnectorOpts{SyncResources: []string{ResourceTypeNamespace.Id}}))
+}
@@ -0,0 +1,106 @@
+package connector
+
+import (
+	"context"
+	"sync"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// namespaceCountedType is a namespaced resource type whose objects are counted in the namespace profile.
+type namespaceCountedType struct {
+	resourceTypeID string
+	profileKey     string
+	// list lists the objects of the type in the namespace, returning the list metadata and the number of items.
+	list func(ctx context.Context, client kubernetes.Interface, namespace string, opts metav1.ListOptions) (metav1.ListMeta, int, error)
+}
+
+// namespaceCountedTypes are the resource types counted in the namespace profile, when synced.
+var namespaceCountedTypes = []namespaceCountedType{
+	{
+		resourceTypeID: ResourceTypePod.Id,
+		profileKey:     "podCount",
+		list: func(ctx context.Context, client kubernetes.Interface, namespace string, opts metav1.ListOptions) (metav1.ListMeta, int, error) {
+			resp, err := client.CoreV1().Pods(namespace).List(ctx, opts)
+			if err != nil {
+				return metav1.ListMeta{}, 0, err
+			}
+			return resp.ListMeta, len(resp.Items), nil
+		},
+	},
+	{
+		resourceTypeID: ResourceTypeSecret.Id,
+		profileKey:     "secretCount",
+		list: func(ctx context.Context, client kubernetes.Interface, namespace string, opts metav1.ListOptions) (metav1.ListMeta, int, error) {
+			resp, err := client.CoreV1().Secrets(namespace).List(ctx, opts)
+			if err != nil {
+				return metav1.ListMeta{}, 0, err
+			}
+			return resp.ListMeta, len(resp.Items), nil
+		},
+	},
+	{
+		resourceTypeID: ResourceTypeConfigMap.Id,
+		profileKey:     "configMapCount",
+		list: func(ctx context.Context, client kubernetes.Interface, namespace string, opts metav1.ListOptions) (metav1.ListMeta, int, error) {
+			resp, err := client.CoreV1().ConfigMaps(namespace).List(ctx, opts)
+			if err != nil {
+				return metav1.ListMeta{}, 0, err
+			}
+			return resp.ListMeta, len(resp.Items), nil
+		},
+	},
+}
+
+// namespaceObjectCounts returns the number of objects of the synced namespaceCountedTypes in the namespace, keyed
+// by profile key. Each count is read from the metadata of a single-item list, the API server reporting how many
+// items remain, the lists of the types running concurrently. Counts the API server doesn't report, or that can't
+// be listed, are left out: they're informative and never fail the sync.
+func namespaceObjectCounts(ctx context.Context, client kubernetes.Interface, namespace string, opts ConnectorOpts) map[string]int64 {
+	l := ctxzap.Extract(ctx)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	counts := make(map[string]int64)
+	for _, t := range namespaceCountedTypes {
+		if !opts.syncsResourceType(t.resourceTypeID) {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			page, err := listWithRetry(ctx, opts, func(ctx context.Context) (countedPage, error) {
+				meta, items, err := t.list(ctx, client, namespace, metav1.ListOptions{Limit: 1})
+				return countedPage{meta: meta, items: items}, err
+			})
+			if err != nil {
+				l.Debug("failed to count namespace objects",
+					zap.String("namespace", namespace),
+					zap.String("resource_type", t.resourceTypeID),
+					zap.Error(err))
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case page.meta.RemainingItemCount != nil:
+				counts[t.profileKey] = int64(page.items) + *page.meta.RemainingItemCount
+			case page.meta.Continue == "":
+				counts[t.profileKey] = int64(page.items)
+			}
+		}()
+	}
+	wg.Wait()
+	return counts
+}
+
+// countedPage is the first page of the objects of a namespaceCountedType: its list metadata and number of items.
+type countedPage struct {
+	meta  metav1.ListMeta
+	items int
+}
@@ -12,14 +12,30 @@ import (
 	"github.com/conductorone/baton-sdk/pkg/annotations"
 	"github.com/conductorone/baton-sdk/pkg/pagination"
 	"github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
 	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
 	"go.uber.org/zap"
 )
 
-// nodeBuilder syncs Kubernetes Nodes as Baton resources.
+// NodeOperatesEntitlement is the entitlement of a node granted to the user its kubelet authenticates as.
+const NodeOperatesEntitlement = "operates"
+
+// nodeSubresourceEntitlements are the entitlements of the nodes/proxy, nodes/stats and nodes/log subresources,
+// which give access to the kubelet API and are far more sensitive than the verbs on nodes.
+var nodeSubresourceEntitlements = []struct {
+	slug        string
+	description string
+}{
+	{slug: "proxy", description: "Grants permission to proxy requests to the kubelet of the %s node"},
+	{slug: "stats", description: "Grants permission to read the kubelet stats of the %s node"},
+	{slug: "log", description: "Grants permission to read the kubelet and system logs of the %s node"},
+}
+
+// nodeBuilder syncs Kubernetes Nodes as Baton resources. It's stateless and safe for concurrent use.
 type nodeBuilder struct {
 	client kubernetes.Interface
+	opts   ConnectorOpts
 }
 
 // ResourceType returns the resource type for Node.
@@ -41,8 +57,8 @@ func (n *nodeBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId,
 	}
 
 	// Add wildcard resource first, but only on the first page (when page token is empty)
-	if bag.PageToken() == "" {
-		wildcardResource, err := generateWildcardResource(ResourceTypeNode)
+	if bag.PageToken() == "" && !n.opts.DisableWildcardResources {
+		wildcardResource, err := generateWildcardResource(ResourceTypeNode, "")
 		if err != nil {
 			l.Error("failed to create wildcard resource for nodes", zap.Error(err))
 		} else {
@@ -52,20 +68,22 @@ func (n *nodeBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId,
 
 	// Set up list options with pagination
 	opts := metav1.ListOptions{
-		Limit:    ResourcesPageSize,
+		Limit:    n.opts.pageSize(ResourceTypeNode.Id),
 		Continue: bag.PageToken(),
 	}
 
 	// Fetch nodes from the Kubernetes API
 	l.Debug("fetching nodes", zap.String("continue_token", opts.Continue))
-	resp, err := n.client.CoreV1().Nodes().List(ctx, opts)
+	resp, err := listWithRetry(ctx, n.opts, func(ctx context.Context) (*corev1.NodeList, error) {
+		return n.client.CoreV1().Nodes().List(ctx, opts)
+	})
 	if err != nil {
 		return nil, "", nil, fmt.Errorf("failed to list nodes: %w", err)
 	}
 
 	// Process each node into a Baton resource
 	for _, node := range resp.Items {
-		resource, err := nodeResource(&node)
+		resource, err := nodeResource(&node, n.opts)
 		if err != nil {
 			l.Error("failed to create node resource",
 				zap.String("name", node.Name),
@@ -85,7 +103,7 @@ func (n *nodeBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId,
 }
 
 // nodeResource creates a Baton resource from a Kubernetes Node.
-func nodeResource(node *corev1.Node) (*v2.Resource, error) {
+func nodeResource(node *corev1.Node, opts ConnectorOpts) (*v2.Resource, error) {
 	// Create resource options with simplified description
 	options := []rs.ResourceOption{
 		rs.WithDescription("Kubernetes node"),
@@ -96,6 +114,13 @@ func nodeResource(node *corev1.Node) (*v2.Resource, error) {
 		options = append(options, rs.WithExternalID(&v2.ExternalId{Id: string(node.UID)}))
 	}
 
+	// Add configured label tags
+	tagOptions, err := labelTagOptions(node.Labels, opts)
+	if err != nil {
+		return nil, err
+	}
+	options = append(options, tagOptions...)
+
 	// Create resource
 	resource, err := rs.NewResource(
 		node.Name,
@@ -110,12 +135,12 @@ func nodeResource(node *corev1.Node) (*v2.Resource, error) {
 	return resource, nil
 }
 
-// Entitlements returns standard verb entitlements for Node resources.
+// Entitlements returns standard verb and kubelet subresource entitlements for Node resources.
 func (n *nodeBuilder) Entitlements(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
 	var entitlements []*v2.Entitlement
 
 	// Add standard verb entitlements
-	for _, verb := range standardResourceVerbs {
+	for _, verb := range n.opts.resourceVerbs(ResourceTypeNode.Id) {
 		ent := entitlement.NewPermissionEntitlement(
 			resource,
 			verb,
@@ -129,17 +154,55 @@ func (n *nodeBuilder) Entitlements(ctx context.Context, resource *v2.Resource, _
 		entitlements = append(entitlements, ent)
 	}
 
+	// Add the entitlements of the kubelet API subresources, which reach the node directly
+	for _, sub := range nodeSubresourceEntitlements {
+		ent := entitlement.NewPermissionEntitlement(
+			resource,
+			sub.slug,
+			entitlement.WithDisplayName(fmt.Sprintf("%s %s", sub.slug, resource.DisplayName)),
+			entitlement.WithDescription(fmt.Sprintf(sub.description, resource.DisplayName)),
+			entitlement.WithGrantableTo(
+				ResourceTypeRole,
+				ResourceTypeClusterRole,
+			),
+		)
+		entitlements = append(entitlements, ent)
+	}
+
+	// Add 'operates' entitlement, granted to the system:node:<name> user of the kubelet
+	if n.opts.IncludeSystemSubjects && resource.Id.Resource != "*" {
+		operatesEntitlement := entitlement.NewAssignmentEntitlement(
+			resource,
+			NodeOperatesEntitlement,
+			entitlement.WithDisplayName(fmt.Sprintf("Operates %s", resource.DisplayName)),
+			entitlement.WithDescription(fmt.Sprintf("The user the kubelet of the %s node authenticates as", resource.DisplayName)),
+			entitlement.WithGrantableTo(n.opts.kubeUserResourceType(nodeUserPrefix)),
+		)
+		entitlements = append(entitlements, operatesEntitlement)
+	}
+
 	return entitlements, "", nil, nil
 }
 
-// Grants returns no grants for Node resources.
+// Grants returns the operates grant of the Node to the user its kubelet authenticates as. Like other system
+// users, node users are only granted with IncludeSystemSubjects.
 func (n *nodeBuilder) Grants(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
-	return nil, "", nil, nil
+	if !n.opts.IncludeSystemSubjects || resource.Id.Resource == "*" {
+		return nil, "", nil, nil
+	}
+
+	username := nodeUsername(resource.Id.Resource)
+	principal := &v2.ResourceId{
+		ResourceType: n.opts.kubeUserResourceType(username).Id,
+		Resource:     subjectResourceID(username),
+	}
+	return []*v2.Grant{grant.NewGrant(resource, NodeOperatesEntitlement, principal)}, "", nil, nil
 }
 
 // newNodeBuilder creates a new node builder.
-func newNodeBuilder(client kubernetes.Interface) *nodeBuilder {
+func newNodeBuilder(client kubernetes.Interface, opts ConnectorOpts) *nodeBuilder {
 	return &nodeBuilder{
 		client: client,
+		opts:   opts,
 	}
 }
@@ -0,0 +1,55 @@
+package connector
+
+import (
+	"strings"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ownerResourceTypes maps the kinds of the apps controllers to the resource types they are synced as.
+var ownerResourceTypes = map[string]*v2.ResourceType{
+	"Deployment":  ResourceTypeDeployment,
+	"ReplicaSet":  ResourceTypeReplicaSet,
+	"StatefulSet": ResourceTypeStatefulSet,
+	"DaemonSet":   ResourceTypeDaemonSet,
+}
+
+// controllerParentID returns the ID of the synced workload controlling an object, such as the ReplicaSet of a
+// pod or the Deployment of a ReplicaSet, or the ID of the object's namespace if no synced workload controls it.
+func controllerParentID(obj metav1.Object, opts ConnectorOpts) (*v2.ResourceId, error) {
+	if owner := metav1.GetControllerOf(obj); owner != nil {
+		if resourceType, name, ok := controllerResource(owner, obj.GetLabels(), opts); ok {
+			return formatResourceID(resourceType, namespacedName(obj.GetNamespace(), name))
+		}
+	}
+	return NamespaceResourceID(obj.GetNamespace())
+}
+
+// controllerResource returns the resource type and name of the synced workload a controller reference points
+// at. When ReplicaSets aren't synced, the pods of a Deployment's ReplicaSet are parented to the Deployment, whose
+// name is the ReplicaSet's without the pod template hash suffix. Controllers of other kinds, such as Jobs,
+// aren't synced.
+func controllerResource(owner *metav1.OwnerReference, labels map[string]string, opts ConnectorOpts) (*v2.ResourceType, string, bool) {
+	gv, err := schema.ParseGroupVersion(owner.APIVersion)
+	if err != nil || gv.Group != appsv1.GroupName {
+		return nil, "", false
+	}
+	resourceType, ok := ownerResourceTypes[owner.Kind]
+	if !ok {
+		return nil, "", false
+	}
+	if opts.syncsResourceType(resourceType.Id) {
+		return resourceType, owner.Name, true
+	}
+
+	hash := labels[appsv1.DefaultDeploymentUniqueLabelKey]
+	if resourceType == ResourceTypeReplicaSet && hash != "" && opts.syncsResourceType(ResourceTypeDeployment.Id) {
+		if deployment, ok := strings.CutSuffix(owner.Name, "-"+hash); ok && deployment != "" {
+			return ResourceTypeDeployment, deployment, true
+		}
+	}
+	return nil, "", false
+}
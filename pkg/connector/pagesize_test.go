@@ -0,0 +1,95 @@
+package connector
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestWithPageSizes(t *testing.T) {
+	opts := ConnectorOpts{}
+	require.NoError(t, WithPageSizes([]string{"pod=2000", "secret=100"})(&opts))
+	assert.Equal(t, int64(2000), opts.pageSize(ResourceTypePod.Id))
+	assert.Equal(t, int64(100), opts.pageSize(ResourceTypeSecret.Id))
+	assert.Equal(t, int64(ResourcesPageSize), opts.pageSize(ResourceTypeConfigMap.Id))
+
+	for _, overrides := range [][]string{
+		{"pods=2000"},
+		{"kube_user=10"},
+		{"pod"},
+		{"pod=0"},
+		{"pod=-1"},
+		{"pod=many"},
+	} {
+		assert.Error(t, WithPageSizes(overrides)(&ConnectorOpts{}), overrides)
+	}
+}
+
+func TestBuildersListPageSize(t *testing.T) {
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	limits := make(map[string]int64)
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("list", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		limits[action.GetResource().Resource] = action.(k8stesting.ListActionImpl).ListOptions.Limit
+		return false, nil, nil
+	})
+
+	// Every type gets its own size, so that a builder consulting another type's override is caught
+	var overrides []string
+	sizes := make(map[string]int64)
+	for i, rt := range pageSizeResourceTypes {
+		sizes[rt.Id] = int64(100 + i)
+		overrides = append(overrides, rt.Id+"="+strconv.Itoa(100+i))
+	}
+	opts := ConnectorOpts{AllowEmptySync: true}
+	require.NoError(t, WithPageSizes(overrides)(&opts))
+	k := newTestKubernetes(client, opts)
+
+	// Service accounts are listed per namespace
+	parent := &v2.ResourceId{ResourceType: ResourceTypeNamespace.Id, Resource: "default"}
+
+	tests := []struct {
+		builder  connectorbuilder.ResourceSyncer
+		resource string
+	}{
+		{newNamespaceBuilder(client, nil, opts, nil), "namespaces"},
+		{newServiceAccountBuilder(client, opts), "serviceaccounts"},
+		{newRoleBuilder(client, k, opts, k.stats), "roles"},
+		{newClusterRoleBuilder(client, k, opts, k.stats), "clusterroles"},
+		{newSecretBuilder(client, k, nil, opts), "secrets"},
+		{newConfigMapBuilder(client, k, k, opts), "configmaps"},
+		{newServiceBuilder(client, opts), "services"},
+		{newNodeBuilder(client, opts), "nodes"},
+		{newPodBuilder(client, opts), "pods"},
+		{newDeploymentBuilder(client, opts), "deployments"},
+		{newStatefulSetBuilder(client, opts), "statefulsets"},
+		{newDaemonSetBuilder(client, opts), "daemonsets"},
+		{newReplicaSetBuilder(client, opts), "replicasets"},
+	}
+	require.Len(t, tests, len(pageSizeResourceTypes))
+
+	for _, tt := range tests {
+		resourceType := tt.builder.ResourceType(ctx).Id
+		t.Run(resourceType, func(t *testing.T) {
+			_, _, _, err := tt.builder.List(ctx, parent, &pagination.Token{})
+			require.NoError(t, err)
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, sizes[resourceType], limits[tt.resource])
+		})
+	}
+}
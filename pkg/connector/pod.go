@@ -3,6 +3,8 @@ package connector
 import (
 	"context"
 	"fmt"
+	"hash/fnv"
+	"math"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -15,11 +17,14 @@ import (
 	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
 	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
-// podBuilder syncs Kubernetes Pods as Baton resources.
+// podBuilder syncs Kubernetes Pods as Baton resources. It's stateless and safe for concurrent use; the pod
+// sample only depends on the pods themselves.
 type podBuilder struct {
 	client kubernetes.Interface
+	opts   ConnectorOpts
 }
 
 // ResourceType returns the resource type for Pod.
@@ -31,41 +36,39 @@ func (p *podBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
 func (p *podBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
 	l := ctxzap.Extract(ctx)
 
-	// Initialize empty resource slice
-	var rv []*v2.Resource
-
 	// Parse pagination token
 	bag, err := ParsePageToken(pToken.Token)
 	if err != nil {
 		return nil, "", nil, fmt.Errorf("failed to parse page token: %w", err)
 	}
 
-	// Add wildcard resource first, but only on the first page (when page token is empty)
-	if bag.PageToken() == "" {
-		wildcardResource, err := generateWildcardResource(ResourceTypePod)
-		if err != nil {
-			l.Error("failed to create wildcard resource for pods", zap.Error(err))
-		} else {
-			rv = append(rv, wildcardResource)
-		}
+	// List the namespace the SDK lists the children of, or all namespaces, after the wildcards
+	namespace, rv, done, err := startNamespacedList(ctx, p.client, p.opts, ResourceTypePod, parentResourceID, bag.PageToken(), p.wildcardResource)
+	if err != nil || done {
+		return rv, "", nil, err
 	}
 
 	// Set up list options with pagination
 	opts := metav1.ListOptions{
-		Limit:    ResourcesPageSize,
+		Limit:    p.opts.pageSize(ResourceTypePod.Id),
 		Continue: bag.PageToken(),
 	}
 
-	// Fetch pods from the Kubernetes API across all namespaces
+	// Fetch pods from the Kubernetes API in the namespace, or across all namespaces
 	l.Debug("fetching pods", zap.String("continue_token", opts.Continue))
-	resp, err := p.client.CoreV1().Pods("").List(ctx, opts)
+	resp, err := listWithRetry(ctx, p.opts, func(ctx context.Context) (*corev1.PodList, error) {
+		return p.client.CoreV1().Pods(namespace).List(ctx, opts)
+	})
 	if err != nil {
 		return nil, "", nil, fmt.Errorf("failed to list pods: %w", err)
 	}
 
 	// Process each pod into a Baton resource
 	for _, pod := range resp.Items {
-		resource, err := podResource(&pod)
+		if !podSampled(&pod, p.opts.PodSampleRate) {
+			continue
+		}
+		resource, err := podResource(&pod, p.opts)
 		if err != nil {
 			l.Error("failed to create pod resource",
 				zap.String("namespace", pod.Namespace),
@@ -85,10 +88,53 @@ func (p *podBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId,
 	return rv, nextPageToken, nil, nil
 }
 
-// podResource creates a Baton resource from a Kubernetes Pod.
-func podResource(pod *corev1.Pod) (*v2.Resource, error) {
-	// Get parent namespace resource ID
-	parentID, err := NamespaceResourceID(pod.Namespace)
+// wildcardResource returns the wildcard pod resource, annotated with the sample rate when only a sample of the
+// pods is synced so that consumers know the inventory is partial.
+func (p *podBuilder) wildcardResource() (*v2.Resource, error) {
+	resource, err := generateWildcardResource(ResourceTypePod, "")
+	if err != nil || !podSamplingEnabled(p.opts.PodSampleRate) {
+		return resource, err
+	}
+
+	sampling, err := structpb.NewStruct(map[string]interface{}{
+		"sampled":    true,
+		"sampleRate": p.opts.PodSampleRate,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sampling annotation: %w", err)
+	}
+	annos := annotations.Annotations(resource.Annotations)
+	annos.Append(sampling)
+	resource.Annotations = annos
+	resource.Description = fmt.Sprintf("Represents all pods in the cluster. Only a %g%% sample of the pods is synced", p.opts.PodSampleRate*100)
+	return resource, nil
+}
+
+// podSamplingEnabled reports whether a sample rate leaves pods out of the sync.
+func podSamplingEnabled(rate float64) bool {
+	return rate > 0 && rate < 1
+}
+
+// podSampled reports whether a pod is in the sample of the given rate. Pods are picked by the hash of their UID,
+// or of their namespace and name if they have none, so that repeated syncs pick the same pods.
+func podSampled(pod *corev1.Pod, rate float64) bool {
+	if !podSamplingEnabled(rate) {
+		return true
+	}
+	key := string(pod.UID)
+	if key == "" {
+		key = pod.Namespace + "/" + pod.Name
+	}
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return float64(h.Sum64()) < rate*math.MaxUint64
+}
+
+// podResource creates a Baton resource from a Kubernetes Pod, parented to the workload controlling it or to its
+// namespace.
+func podResource(pod *corev1.Pod, opts ConnectorOpts) (*v2.Resource, error) {
+	// Get the controlling workload or namespace resource ID
+	parentID, err := controllerParentID(pod, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create parent resource ID: %w", err)
 	}
@@ -104,8 +150,15 @@ func podResource(pod *corev1.Pod) (*v2.Resource, error) {
 		options = append(options, rs.WithExternalID(&v2.ExternalId{Id: string(pod.UID)}))
 	}
 
+	// Add configured label tags
+	tagOptions, err := labelTagOptions(pod.Labels, opts)
+	if err != nil {
+		return nil, err
+	}
+	options = append(options, tagOptions...)
+
 	// Create the raw ID as namespace/name
-	rawID := pod.Namespace + "/" + pod.Name
+	rawID := namespacedName(pod.Namespace, pod.Name)
 
 	// Create resource
 	resource, err := rs.NewResource(
@@ -126,7 +179,7 @@ func (p *podBuilder) Entitlements(ctx context.Context, resource *v2.Resource, _
 	var entitlements []*v2.Entitlement
 
 	// Add standard verb entitlements
-	for _, verb := range standardResourceVerbs {
+	for _, verb := range p.opts.resourceVerbs(ResourceTypePod.Id) {
 		ent := entitlement.NewPermissionEntitlement(
 			resource,
 			verb,
@@ -165,17 +218,40 @@ func (p *podBuilder) Entitlements(ctx context.Context, resource *v2.Resource, _
 	)
 	entitlements = append(entitlements, portForwardEntitlement)
 
+	logsEntitlement := entitlement.NewPermissionEntitlement(
+		resource,
+		"logs",
+		entitlement.WithDisplayName(fmt.Sprintf("logs %s", resource.DisplayName)),
+		entitlement.WithDescription(fmt.Sprintf("Grants permission to read the logs of the %s pod", resource.DisplayName)),
+		entitlement.WithGrantableTo(
+			ResourceTypeRole,
+			ResourceTypeClusterRole,
+		),
+	)
+	entitlements = append(entitlements, logsEntitlement)
+
 	return entitlements, "", nil, nil
 }
 
-// Grants returns no grants for Pod resources.
+// Grants returns the runs_as grant of the service account the Pod runs as.
 func (p *podBuilder) Grants(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
-	return nil, "", nil, nil
+	grants, err := workloadRunsAsGrants(ctx, resource, func(namespace, name string) (*corev1.PodSpec, error) {
+		pod, err := p.client.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return &pod.Spec, nil
+	})
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return grants, "", nil, nil
 }
 
 // newPodBuilder creates a new pod builder.
-func newPodBuilder(client kubernetes.Interface) *podBuilder {
+func newPodBuilder(client kubernetes.Interface, opts ConnectorOpts) *podBuilder {
 	return &podBuilder{
 		client: client,
+		opts:   opts,
 	}
 }
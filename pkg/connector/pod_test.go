@@ -0,0 +1,190 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// samplePods returns n pods with distinct UIDs.
+func samplePods(n int) []*corev1.Pod {
+	pods := make([]*corev1.Pod, 0, n)
+	for i := 0; i < n; i++ {
+		pods = append(pods, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Namespace: fmt.Sprintf("ns-%d", i%10),
+			Name:      fmt.Sprintf("pod-%d", i),
+			UID:       types.UID(fmt.Sprintf("7f3c2a1e-%04d-4d6b-9a51-%012d", i%10000, i)),
+		}})
+	}
+	return pods
+}
+
+func TestPodSampled(t *testing.T) {
+	pods := samplePods(20000)
+
+	tests := []struct {
+		rate float64
+	}{
+		{0.01},
+		{0.1},
+		{0.5},
+		{0.9},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("rate %v", tt.rate), func(t *testing.T) {
+			sampled := 0
+			for _, pod := range pods {
+				if podSampled(pod, tt.rate) {
+					sampled++
+				}
+			}
+			// Within four standard deviations of the expected binomial sample size
+			n := float64(len(pods))
+			expected := tt.rate * n
+			assert.InDelta(t, expected, float64(sampled), 4*math.Sqrt(n*tt.rate*(1-tt.rate)))
+		})
+	}
+
+	// Pods sampled at a rate are sampled at every higher rate, so raising the rate only adds pods
+	for _, pod := range pods {
+		if podSampled(pod, 0.1) {
+			assert.True(t, podSampled(pod, 0.2), pod.Name)
+		}
+	}
+
+	// Without sampling every pod is synced
+	for _, rate := range []float64{0, 1} {
+		for _, pod := range pods[:100] {
+			assert.True(t, podSampled(pod, rate))
+		}
+	}
+}
+
+func TestPodBuilderList_Sampling(t *testing.T) {
+	ctx := context.Background()
+	var objects []runtime.Object
+	for _, pod := range samplePods(400) {
+		objects = append(objects, pod)
+	}
+	client := fake.NewSimpleClientset(objects...)
+	var podNamespaces []string
+	for i := 0; i < 10; i++ {
+		podNamespaces = append(podNamespaces, fmt.Sprintf("ns-%d", i))
+	}
+
+	opts := ConnectorOpts{}
+	require.NoError(t, WithPodSampleRate(0.25)(&opts))
+
+	// Repeated syncs pick the same pods
+	var synced [2][]string
+	for i := range synced {
+		for _, resource := range listResources(ctx, t, newPodBuilder(client, opts), podNamespaces...) {
+			if resource.Id.Resource == "*" {
+				continue
+			}
+			synced[i] = append(synced[i], resource.Id.Resource)
+		}
+	}
+	assert.Equal(t, synced[0], synced[1])
+	assert.InDelta(t, 100, len(synced[0]), 30)
+
+	// The wildcard pod records the sampling
+	resources := listResources(ctx, t, newPodBuilder(client, opts), podNamespaces...)
+	require.Equal(t, "*", resources[0].Id.Resource)
+	sampling := &structpb.Struct{}
+	annos := annotations.Annotations(resources[0].Annotations)
+	ok, err := annos.Pick(sampling)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.True(t, sampling.Fields["sampled"].GetBoolValue())
+	assert.Equal(t, 0.25, sampling.Fields["sampleRate"].GetNumberValue())
+
+	// Without sampling every pod is synced and the wildcard isn't annotated
+	resources = listResources(ctx, t, newPodBuilder(client, ConnectorOpts{}), podNamespaces...)
+	assert.Len(t, resources, 401)
+	assert.Empty(t, resources[0].Annotations)
+}
+
+func TestWithPodSampleRate(t *testing.T) {
+	for _, rate := range []float64{0, -0.1, 1.5} {
+		assert.Error(t, WithPodSampleRate(rate)(&ConnectorOpts{}), rate)
+	}
+	opts := ConnectorOpts{}
+	require.NoError(t, WithPodSampleRate(1)(&opts))
+	assert.Equal(t, float64(1), opts.PodSampleRate)
+}
+
+// TestPodResource_ControllerParent tests that pods are parented to the synced workload controlling them, through
+// the ReplicaSet of a Deployment, and to their namespace otherwise.
+func TestPodResource_ControllerParent(t *testing.T) {
+	isController := true
+	controller := func(kind, name string) []metav1.OwnerReference {
+		return []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: kind, Name: name, Controller: &isController}}
+	}
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "api"}}
+	replicaSet := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+		Namespace:       "payments",
+		Name:            "api-7d9f8b6c5",
+		Labels:          map[string]string{appsv1.DefaultDeploymentUniqueLabelKey: "7d9f8b6c5"},
+		OwnerReferences: controller("Deployment", deployment.Name),
+	}}
+	deploymentPod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace:       "payments",
+		Name:            "api-7d9f8b6c5-x2x4q",
+		Labels:          map[string]string{appsv1.DefaultDeploymentUniqueLabelKey: "7d9f8b6c5"},
+		OwnerReferences: controller("ReplicaSet", replicaSet.Name),
+	}}
+	statefulSetPod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace:       "payments",
+		Name:            "db-0",
+		OwnerReferences: controller("StatefulSet", "db"),
+	}}
+	jobPod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "payments",
+		Name:      "migrate-5kq2n",
+		OwnerReferences: []metav1.OwnerReference{
+			{APIVersion: "batch/v1", Kind: "Job", Name: "migrate", Controller: &isController},
+		},
+	}}
+	barePod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "debug"}}
+
+	parent := func(pod *corev1.Pod, opts ConnectorOpts) string {
+		resource, err := podResource(pod, opts)
+		require.NoError(t, err)
+		return resourceIDKey(resource.ParentResourceId)
+	}
+
+	// The chain Deployment -> ReplicaSet -> Pod
+	rs, err := replicaSetResource(replicaSet, ConnectorOpts{})
+	require.NoError(t, err)
+	assert.Equal(t, "deployment:payments/api", resourceIDKey(rs.ParentResourceId))
+	assert.Equal(t, "replicaset:payments/api-7d9f8b6c5", parent(deploymentPod, ConnectorOpts{}))
+	assert.Equal(t, "statefulset:payments/db", parent(statefulSetPod, ConnectorOpts{}))
+
+	// Without ReplicaSets, the pods of a Deployment are parented to it
+	withoutReplicaSets := ConnectorOpts{SyncResources: []string{ResourceTypePod.Id, ResourceTypeDeployment.Id}}
+	assert.Equal(t, "deployment:payments/api", parent(deploymentPod, withoutReplicaSets))
+
+	// Owners that aren't synced, and bare pods, fall back to the namespace
+	assert.Equal(t, "namespace:payments", parent(deploymentPod, ConnectorOpts{SyncResources: []string{ResourceTypePod.Id}}))
+	assert.Equal(t, "namespace:payments", parent(statefulSetPod, withoutReplicaSets))
+	assert.Equal(t, "namespace:payments", parent(jobPod, ConnectorOpts{}))
+	assert.Equal(t, "namespace:payments", parent(barePod, ConnectorOpts{}))
+
+	bareReplicaSet, err := replicaSetResource(&appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "legacy"}}, ConnectorOpts{})
+	require.NoError(t, err)
+	assert.Equal(t, "namespace:payments", resourceIDKey(bareReplicaSet.ParentResourceId))
+}
@@ -0,0 +1,109 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// secretProfile returns the profile of the secret trait of a secret resource.
+func secretProfile(t *testing.T, resource *v2.Resource) map[string]interface{} {
+	t.Helper()
+	secretTrait := &v2.SecretTrait{}
+	annos := annotations.Annotations(resource.Annotations)
+	ok, err := annos.Pick(secretTrait)
+	require.NoError(t, err)
+	require.True(t, ok)
+	return secretTrait.Profile.AsMap()
+}
+
+// TestSecretProfile_SensitiveAnnotations tests that the annotations holding a copy of the secret data never
+// make it into the profile of the synced secrets, whatever the options.
+func TestSecretProfile_SensitiveAnnotations(t *testing.T) {
+	ctx := context.Background()
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "payments",
+			Name:      "db-creds",
+			Annotations: map[string]string{
+				lastAppliedAnnotation:             `{"apiVersion":"v1","data":{"password":"aHVudGVyMg=="},"kind":"Secret"}`,
+				"objectset.rio.cattle.io/applied": "H4sIAAAAAAAA/6pWKkotLs0pUbJSSs7PS8tMLwEAAAD//w==",
+				"owner":                           "payments-team",
+			},
+		},
+		Data: map[string][]byte{"password": []byte("hunter2")},
+	}
+
+	for _, opts := range []ConnectorOpts{{}, {RedactAnnotationKeys: []string{"owner"}}} {
+		builder := newSecretBuilder(fake.NewSimpleClientset(secret), nil, nil, opts)
+		resources, _, _, err := builder.List(ctx, namespaceID("payments"), &pagination.Token{})
+		require.NoError(t, err)
+
+		var found bool
+		for _, resource := range resources {
+			if resource.Id.Resource != "payments/db-creds" {
+				continue
+			}
+			found = true
+			profileAnnotations, _ := secretProfile(t, resource)["annotations"].(map[string]interface{})
+			assert.NotContains(t, profileAnnotations, lastAppliedAnnotation)
+			assert.NotContains(t, profileAnnotations, "objectset.rio.cattle.io/applied")
+			if len(opts.RedactAnnotationKeys) == 0 {
+				assert.Equal(t, map[string]interface{}{"owner": "payments-team"}, profileAnnotations)
+			} else {
+				assert.Empty(t, profileAnnotations)
+			}
+		}
+		assert.True(t, found)
+	}
+}
+
+// TestProfileAnnotations_RedactAnnotationKeys tests that the configured annotation keys are left out of the
+// profiles of every resource type, while the sensitive annotations are only dropped for objects holding data.
+func TestProfileAnnotations_RedactAnnotationKeys(t *testing.T) {
+	meta := metav1.ObjectMeta{
+		Namespace: "payments",
+		Name:      "deployer",
+		Annotations: map[string]string{
+			lastAppliedAnnotation:            `{"kind":"ServiceAccount"}`,
+			"internal.example.com/ticket":    "SEC-1234",
+			"iam.gke.io/gcp-service-account": "deployer@example.iam.gserviceaccount.com",
+		},
+	}
+	opts := ConnectorOpts{RedactAnnotationKeys: []string{"internal.example.com/ticket"}}
+
+	serviceAccount, err := serviceAccountResource(&corev1.ServiceAccount{ObjectMeta: meta}, opts)
+	require.NoError(t, err)
+	userTrait, err := rs.GetUserTrait(serviceAccount)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		lastAppliedAnnotation:            `{"kind":"ServiceAccount"}`,
+		"iam.gke.io/gcp-service-account": "deployer@example.iam.gserviceaccount.com",
+	}, userTrait.Profile.AsMap()["annotations"])
+
+	secret, err := secretResource(&corev1.Secret{ObjectMeta: meta}, nil, opts)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"iam.gke.io/gcp-service-account": "deployer@example.iam.gserviceaccount.com",
+	}, secretProfile(t, secret)["annotations"])
+}
+
+func TestWithRedactAnnotationKeys(t *testing.T) {
+	opts, err := applyOptions([]ConnectorOption{WithRedactAnnotationKeys([]string{"internal.example.com/ticket"})})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"internal.example.com/ticket"}, opts.RedactAnnotationKeys)
+
+	_, err = applyOptions([]ConnectorOption{WithRedactAnnotationKeys([]string{""})})
+	assert.Error(t, err)
+}
@@ -0,0 +1,172 @@
+package connector
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// ProfileRepaired is the profile key flagging resources whose profile had keys or values with invalid UTF-8,
+// such as copy-pasted label or annotation keys. Protobuf structs reject them, so they are repaired rather
+// than failing the resource.
+const ProfileRepaired = "profileRepaired"
+
+// repairProfile replaces the invalid UTF-8 in the keys and string values of a profile and of the maps and lists
+// it holds with the Unicode replacement character, so that it can be converted to a protobuf struct. Keys that
+// collide once repaired are suffixed with underscores, as each of their values is kept. A repaired profile is
+// flagged with ProfileRepaired, and repairProfile reports whether it was.
+func repairProfile(profile map[string]interface{}) bool {
+	if !repairMap(profile) {
+		return false
+	}
+	profile[ProfileRepaired] = true
+	return true
+}
+
+// repairMap repairs the keys and values of a map in place, reporting whether anything was replaced.
+func repairMap(m map[string]interface{}) bool {
+	repaired := false
+	var invalidKeys []string
+	for k, v := range m {
+		if !utf8.ValidString(k) {
+			invalidKeys = append(invalidKeys, k)
+		}
+		if v, ok := repairValue(v); ok {
+			m[k] = v
+			repaired = true
+		}
+	}
+
+	// Keys are moved in a stable order, so that colliding keys get the same suffixes on every sync
+	sort.Strings(invalidKeys)
+	for _, k := range invalidKeys {
+		key := strings.ToValidUTF8(k, string(utf8.RuneError))
+		for {
+			if _, ok := m[key]; !ok {
+				break
+			}
+			key += "_"
+		}
+		m[key] = m[k]
+		delete(m, k)
+		repaired = true
+	}
+	return repaired
+}
+
+// repairValue returns a profile value with its invalid UTF-8 replaced, and reports whether anything was.
+func repairValue(v interface{}) (interface{}, bool) {
+	switch v := v.(type) {
+	case string:
+		if utf8.ValidString(v) {
+			return v, false
+		}
+		return strings.ToValidUTF8(v, string(utf8.RuneError)), true
+	case map[string]interface{}:
+		return v, repairMap(v)
+	case []interface{}:
+		repaired := false
+		for i, item := range v {
+			if item, ok := repairValue(item); ok {
+				v[i] = item
+				repaired = true
+			}
+		}
+		return v, repaired
+	default:
+		return v, false
+	}
+}
+
+// profileRepairCounter counts the synced resources whose profile was repaired.
+type profileRepairCounter struct {
+	stats *syncStats
+}
+
+// newProfileRepairCounter creates a counter of repaired profiles recording them in the stats.
+func newProfileRepairCounter(stats *syncStats) *profileRepairCounter {
+	return &profileRepairCounter{stats: stats}
+}
+
+// inboundResourceID returns the ID unchanged.
+func (c *profileRepairCounter) inboundResourceID(id *v2.ResourceId) *v2.ResourceId {
+	return id
+}
+
+// inboundResource returns the resource unchanged.
+func (c *profileRepairCounter) inboundResource(resource *v2.Resource) *v2.Resource {
+	return resource
+}
+
+// outboundResource counts the resource if its profile was repaired.
+func (c *profileRepairCounter) outboundResource(resource *v2.Resource) (*v2.Resource, error) {
+	if resourceProfileRepaired(resource) {
+		c.stats.Inc(StatProfilesRepaired)
+	}
+	return resource, nil
+}
+
+// outboundEntitlement returns the entitlement unchanged.
+func (c *profileRepairCounter) outboundEntitlement(ent *v2.Entitlement) (*v2.Entitlement, error) {
+	return ent, nil
+}
+
+// outboundGrant returns the grant unchanged.
+func (c *profileRepairCounter) outboundGrant(g *v2.Grant) (*v2.Grant, error) {
+	return g, nil
+}
+
+// readOnly reports that the counter doesn't prevent provisioning.
+func (c *profileRepairCounter) readOnly() bool {
+	return false
+}
+
+// profileAnnotationTypes are the annotations holding a profile: the trait profiles, and the profile structs of
+// resources without a trait.
+var profileAnnotationTypes = map[protoreflect.FullName]bool{
+	(&v2.UserTrait{}).ProtoReflect().Descriptor().FullName():    true,
+	(&v2.GroupTrait{}).ProtoReflect().Descriptor().FullName():   true,
+	(&v2.RoleTrait{}).ProtoReflect().Descriptor().FullName():    true,
+	(&v2.SecretTrait{}).ProtoReflect().Descriptor().FullName():  true,
+	(&v2.AppTrait{}).ProtoReflect().Descriptor().FullName():     true,
+	(&structpb.Struct{}).ProtoReflect().Descriptor().FullName(): true,
+}
+
+// resourceProfileRepaired reports whether the profile of a resource is flagged as repaired. Only the profile
+// annotations are unmarshaled.
+func resourceProfileRepaired(resource *v2.Resource) bool {
+	for _, a := range resource.GetAnnotations() {
+		if !profileAnnotationTypes[a.MessageName()] {
+			continue
+		}
+		msg, err := a.UnmarshalNew()
+		if err != nil {
+			continue
+		}
+		var profile *structpb.Struct
+		switch m := msg.(type) {
+		case *v2.UserTrait:
+			profile = m.Profile
+		case *v2.GroupTrait:
+			profile = m.Profile
+		case *v2.RoleTrait:
+			profile = m.Profile
+		case *v2.SecretTrait:
+			profile = m.Profile
+		case *v2.AppTrait:
+			profile = m.Profile
+		case *structpb.Struct:
+			profile = m
+		default:
+			continue
+		}
+		if profile.GetFields()[ProfileRepaired].GetBoolValue() {
+			return true
+		}
+	}
+	return false
+}
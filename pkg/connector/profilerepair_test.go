@@ -0,0 +1,125 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// invalidUTF8 is a string with a byte that isn't valid UTF-8, as left by a bad copy-paste.
+const invalidUTF8 = "team\xff"
+
+func TestRepairProfile(t *testing.T) {
+	tests := []struct {
+		name     string
+		profile  map[string]interface{}
+		want     map[string]interface{}
+		repaired bool
+	}{
+		{
+			name:    "valid profile",
+			profile: map[string]interface{}{"name": "payments", "annotations": map[string]interface{}{"a.b.c.example.com/d.e": "f"}},
+			want:    map[string]interface{}{"name": "payments", "annotations": map[string]interface{}{"a.b.c.example.com/d.e": "f"}},
+		},
+		{
+			name:     "invalid value",
+			profile:  map[string]interface{}{"annotations": map[string]interface{}{"owner": invalidUTF8}},
+			want:     map[string]interface{}{"annotations": map[string]interface{}{"owner": "team�"}, ProfileRepaired: true},
+			repaired: true,
+		},
+		{
+			name:     "invalid key",
+			profile:  map[string]interface{}{"labels": map[string]interface{}{invalidUTF8: "payments"}},
+			want:     map[string]interface{}{"labels": map[string]interface{}{"team�": "payments"}, ProfileRepaired: true},
+			repaired: true,
+		},
+		{
+			name:     "colliding keys",
+			profile:  map[string]interface{}{"labels": map[string]interface{}{"team�": "a", invalidUTF8: "b"}},
+			want:     map[string]interface{}{"labels": map[string]interface{}{"team�": "a", "team�_": "b"}, ProfileRepaired: true},
+			repaired: true,
+		},
+		{
+			name:     "list item",
+			profile:  map[string]interface{}{"imagePullSecrets": []interface{}{"registry", invalidUTF8}},
+			want:     map[string]interface{}{"imagePullSecrets": []interface{}{"registry", "team�"}, ProfileRepaired: true},
+			repaired: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.repaired, repairProfile(tt.profile))
+			assert.Equal(t, tt.want, tt.profile)
+		})
+	}
+}
+
+// TestProfileRepair_Resources tests that resources with invalid UTF-8 in their labels or annotations are
+// created with a repaired profile instead of failing.
+func TestProfileRepair_Resources(t *testing.T) {
+	meta := metav1.ObjectMeta{
+		Namespace:   "payments",
+		Name:        "deployer",
+		Labels:      map[string]string{"team": invalidUTF8},
+		Annotations: map[string]string{invalidUTF8 + "/owner": "alice"},
+	}
+
+	serviceAccount, err := serviceAccountResource(&corev1.ServiceAccount{ObjectMeta: meta}, ConnectorOpts{LabelTags: []string{"team"}})
+	require.NoError(t, err)
+	userTrait, err := rs.GetUserTrait(serviceAccount)
+	require.NoError(t, err)
+	profile := userTrait.Profile.AsMap()
+	assert.Equal(t, true, profile[ProfileRepaired])
+	assert.Equal(t, "team�", profile["tag.team"])
+	assert.Equal(t, map[string]interface{}{"team�/owner": "alice"}, profile["annotations"])
+
+	secret, err := secretResource(&corev1.Secret{ObjectMeta: meta}, nil, ConnectorOpts{})
+	require.NoError(t, err)
+	secretTrait := &v2.SecretTrait{}
+	annos := annotations.Annotations(secret.Annotations)
+	ok, err := annos.Pick(secretTrait)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, true, secretTrait.Profile.AsMap()[ProfileRepaired])
+
+	role, err := roleResource(&rbacv1.Role{ObjectMeta: meta}, ConnectorOpts{}, nil)
+	require.NoError(t, err)
+	assert.True(t, resourceProfileRepaired(role))
+
+	valid, err := roleResource(&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "reader"}}, ConnectorOpts{}, nil)
+	require.NoError(t, err)
+	assert.False(t, resourceProfileRepaired(valid))
+}
+
+// TestProfileRepair_Stats tests that the synced resources whose profile was repaired are counted.
+func TestProfileRepair_Stats(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(
+		&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "reader", Annotations: map[string]string{"note": invalidUTF8}}},
+		&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "writer"}},
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "viewer", Labels: map[string]string{invalidUTF8: "true"}}},
+	)
+	k := newTestKubernetes(client, ConnectorOpts{AllowEmptySync: true})
+	syncers := k.wrapSyncers(ctx, []connectorbuilder.ResourceSyncer{
+		newRoleBuilder(client, k, k.opts, k.stats),
+		newClusterRoleBuilder(client, k, k.opts, k.stats),
+	})
+
+	var listed int
+	for _, syncer := range syncers {
+		listed += len(listResources(ctx, t, syncer, "payments"))
+	}
+	// Both roles and the cluster role, with the wildcard resources
+	assert.Equal(t, 5, listed)
+	assert.Equal(t, int64(2), k.SyncStats()[StatProfilesRepaired])
+}
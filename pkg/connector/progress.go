@@ -0,0 +1,99 @@
+package connector
+
+import (
+	"context"
+	"time"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+// progressReportInterval is the minimum time between two progress reports of the same cache load.
+const progressReportInterval = 10 * time.Second
+
+// Names of the caches whose loading progress is reported.
+const (
+	progressCacheRoleBindings        = "role_bindings"
+	progressCacheClusterRoleBindings = "cluster_role_bindings"
+	progressCacheNamespaces          = "namespaces"
+)
+
+// cacheLoadProgress is a progress report of a cache load.
+type cacheLoadProgress struct {
+	Cache   string
+	Pages   int
+	Items   int
+	Elapsed time.Duration
+}
+
+// progressReporter reports the progress of the paginated cache loads, which take minutes on huge clusters
+// and would otherwise look hung. Reports are throttled to one per interval for each load. A nil
+// *progressReporter is valid and reports nothing.
+type progressReporter struct {
+	interval time.Duration
+	now      func() time.Time
+	report   func(ctx context.Context, progress cacheLoadProgress)
+}
+
+// newProgressReporter creates a progress reporter logging at most once per progressReportInterval.
+func newProgressReporter() *progressReporter {
+	return &progressReporter{
+		interval: progressReportInterval,
+		now:      time.Now,
+		report:   logCacheLoadProgress,
+	}
+}
+
+// logCacheLoadProgress logs a progress report.
+func logCacheLoadProgress(ctx context.Context, progress cacheLoadProgress) {
+	ctxzap.Extract(ctx).Info("loading cache",
+		zap.String("cache", progress.Cache),
+		zap.Int("pages", progress.Pages),
+		zap.Int("items", progress.Items),
+		zap.Duration("elapsed", progress.Elapsed))
+}
+
+// start begins tracking the load of the named cache.
+func (r *progressReporter) start(cache string) *cacheLoad {
+	if r == nil {
+		return nil
+	}
+	now := r.now()
+	return &cacheLoad{
+		reporter:   r,
+		cache:      cache,
+		started:    now,
+		lastReport: now,
+	}
+}
+
+// cacheLoad tracks the progress of a single cache load. A nil *cacheLoad is valid and discards all updates.
+type cacheLoad struct {
+	reporter   *progressReporter
+	cache      string
+	started    time.Time
+	lastReport time.Time
+	pages      int
+	items      int
+}
+
+// page records a loaded page of items, reporting the progress if the interval elapsed since the last report.
+func (l *cacheLoad) page(ctx context.Context, items int) {
+	if l == nil {
+		return
+	}
+	l.pages++
+	l.items += items
+
+	now := l.reporter.now()
+	if now.Sub(l.lastReport) < l.reporter.interval {
+		return
+	}
+	l.lastReport = now
+	l.reporter.report(ctx, cacheLoadProgress{
+		Cache:   l.cache,
+		Pages:   l.pages,
+		Items:   l.items,
+		Elapsed: now.Sub(l.started),
+	})
+}
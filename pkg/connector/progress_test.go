@@ -0,0 +1,106 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/conductorone/baton-kubernetes/pkg/kubetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// fakeClock is a clock advanced by the test.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+// recordingProgressReporter returns a progress reporter on the clock recording its reports.
+func recordingProgressReporter(clock *fakeClock, reports *[]cacheLoadProgress) *progressReporter {
+	return &progressReporter{
+		interval: progressReportInterval,
+		now:      clock.Now,
+		report: func(ctx context.Context, progress cacheLoadProgress) {
+			*reports = append(*reports, progress)
+		},
+	}
+}
+
+// slowPages makes every list request of the resource take pageTime on the clock, paginating over the objects in
+// the client's tracker.
+func slowPages(client *fake.Clientset, resource string, pageSize int, clock *fakeClock, pageTime time.Duration) {
+	kubetest.Paginate(client, []string{resource}, kubetest.WithPageSize(pageSize))
+	client.PrependReactor("list", resource, func(action k8stesting.Action) (bool, runtime.Object, error) {
+		clock.now = clock.now.Add(pageTime)
+		return false, nil, nil
+	})
+}
+
+func TestLoadBindingsCaches_ReportsProgress(t *testing.T) {
+	ctx := context.Background()
+	clock := &fakeClock{now: time.Unix(0, 0)}
+
+	// Ten pages of role bindings taking 4s each, and a single fast page of cluster role bindings
+	var objects []runtime.Object
+	for i := 0; i < 500; i++ {
+		objects = append(objects, &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: fmt.Sprintf("rb-%03d", i)},
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindRole, Name: "viewer"},
+		})
+	}
+	client := fake.NewSimpleClientset(objects...)
+	slowPages(client, "rolebindings", 50, clock, 4*time.Second)
+
+	var reports []cacheLoadProgress
+	k := newTestKubernetes(client, ConnectorOpts{})
+	k.progress = recordingProgressReporter(clock, &reports)
+	require.NoError(t, k.loadBindingsCaches(ctx))
+	assert.Len(t, k.roleBindingsCache, 500)
+
+	// Reported at most once per 10s
+	assert.Equal(t, []cacheLoadProgress{
+		{Cache: progressCacheRoleBindings, Pages: 3, Items: 150, Elapsed: 12 * time.Second},
+		{Cache: progressCacheRoleBindings, Pages: 6, Items: 300, Elapsed: 24 * time.Second},
+		{Cache: progressCacheRoleBindings, Pages: 9, Items: 450, Elapsed: 36 * time.Second},
+	}, reports)
+}
+
+func TestListNamespaceNames_ReportsProgress(t *testing.T) {
+	ctx := context.Background()
+	clock := &fakeClock{now: time.Unix(0, 0)}
+
+	var objects []runtime.Object
+	for i := 0; i < 400; i++ {
+		objects = append(objects, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("ns-%03d", i)}})
+	}
+	client := fake.NewSimpleClientset(objects...)
+	slowPages(client, "namespaces", 100, clock, 6*time.Second)
+
+	var reports []cacheLoadProgress
+	names, err := listNamespaceNames(ctx, client, nil, recordingProgressReporter(clock, &reports))
+	require.NoError(t, err)
+	assert.Len(t, names, 400)
+	assert.Equal(t, []cacheLoadProgress{
+		{Cache: progressCacheNamespaces, Pages: 2, Items: 200, Elapsed: 12 * time.Second},
+		{Cache: progressCacheNamespaces, Pages: 4, Items: 400, Elapsed: 24 * time.Second},
+	}, reports)
+
+	// Fast loads aren't reported
+	reports = nil
+	names, err = listNamespaceNames(ctx, fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}), nil,
+		recordingProgressReporter(clock, &reports))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"default"}, names)
+	assert.Empty(t, reports)
+}
@@ -0,0 +1,556 @@
+package connector
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/structpb"
+	rbacv1 "k8s.io/api/rbac/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// ManagedByLabel marks the bindings created by the connector.
+	ManagedByLabel = "app.kubernetes.io/managed-by"
+	// ManagedByValue is the value of ManagedByLabel on bindings created by the connector.
+	ManagedByValue = "baton-kubernetes"
+
+	// managedBindingPrefix prefixes the names of bindings created by the connector.
+	managedBindingPrefix = "baton-"
+	// maxBindingRoleNameLength bounds the role name part of a managed binding name.
+	maxBindingRoleNameLength = 200
+)
+
+// Grant metadata keys describing the binding a membership grant was derived from.
+const (
+	GrantMetadataBindingKind            = "bindingKind"
+	GrantMetadataBindingAPIVersion      = "bindingApiVersion"
+	GrantMetadataBindingName            = "bindingName"
+	GrantMetadataBindingNamespace       = "bindingNamespace"
+	GrantMetadataBindingResourceVersion = "bindingResourceVersion"
+	// GrantMetadataBindingGeneration is the metadata.generation of the binding, recorded when the API server sets
+	// one, so that consumers can order the grants of successive syncs.
+	GrantMetadataBindingGeneration = "bindingGeneration"
+	GrantMetadataBindingUID        = "bindingUid"
+	GrantMetadataBindingCreated    = "bindingCreationTimestamp"
+	// GrantMetadataViaGroup is the group a service account inherits a membership grant through.
+	GrantMetadataViaGroup = "viaGroup"
+	// GrantMetadataViaAggregate is the aggregated ClusterRole a contributing ClusterRole inherits a membership
+	// grant from, the binding being one of the aggregate's.
+	GrantMetadataViaAggregate = "viaAggregate"
+	// GrantMetadataSubjectKind is the kind of the binding subject a membership grant was derived from, which
+	// tells grants to same-named users, groups and service accounts apart without parsing the principal.
+	GrantMetadataSubjectKind = "subjectKind"
+	// GrantMetadataImplicit marks membership grants Kubernetes hard-codes rather than derives from a binding.
+	GrantMetadataImplicit = "implicit"
+	// GrantMetadataAdditionalBindings lists the other bindings conferring a membership grant when several bind
+	// the subject to the same role, each with the binding kind, name, namespace, resourceVersion and generation keys.
+	GrantMetadataAdditionalBindings = "additionalBindings"
+)
+
+// Kinds of the bindings membership grants are derived from.
+const (
+	BindingKindRoleBinding        = "RoleBinding"
+	BindingKindClusterRoleBinding = "ClusterRoleBinding"
+)
+
+// ErrNamespaceNotFound is returned when binding a role in a namespace that doesn't exist.
+var ErrNamespaceNotFound = errors.New("namespace not found")
+
+// bindingRef identifies the binding a membership grant was derived from.
+type bindingRef struct {
+	kind      string
+	name      string
+	namespace string
+	// resourceVersion is the version of the binding when the grant was synced, if known.
+	resourceVersion string
+	// generation is the metadata.generation of the binding when the grant was synced, if set.
+	generation int64
+	// viaGroup is the group the grant is inherited through, if any.
+	viaGroup string
+	// viaAggregate is the aggregated ClusterRole the grant is inherited from, if any.
+	viaAggregate string
+}
+
+// bindingGrantOption records the binding a membership grant was derived from in the grant metadata, so
+// that reviewers can find the binding to remediate and revoking the grant can detect changes made to it
+// since the sync.
+func bindingGrantOption(kind string, meta metav1.ObjectMeta) grant.GrantOption {
+	return grant.WithGrantMetadata(bindingGrantMetadata(kind, meta))
+}
+
+// bindingGrantMetadata returns the grant metadata describing a binding.
+func bindingGrantMetadata(kind string, meta metav1.ObjectMeta) map[string]interface{} {
+	metadata := map[string]interface{}{
+		GrantMetadataBindingKind:            kind,
+		GrantMetadataBindingAPIVersion:      RBACAPIGroupV1,
+		GrantMetadataBindingName:            meta.Name,
+		GrantMetadataBindingResourceVersion: meta.ResourceVersion,
+	}
+	if meta.Namespace != "" {
+		metadata[GrantMetadataBindingNamespace] = meta.Namespace
+	}
+	if meta.UID != "" {
+		metadata[GrantMetadataBindingUID] = string(meta.UID)
+	}
+	if meta.Generation != 0 {
+		metadata[GrantMetadataBindingGeneration] = meta.Generation
+	}
+	if !meta.CreationTimestamp.IsZero() {
+		metadata[GrantMetadataBindingCreated] = meta.CreationTimestamp.UTC().Format(time.RFC3339)
+	}
+	return metadata
+}
+
+// bindingRefFromGrant returns the binding recorded in the metadata of a grant, if any.
+func bindingRefFromGrant(g *v2.Grant) (bindingRef, bool, error) {
+	metadata := &v2.GrantMetadata{}
+	annos := annotations.Annotations(g.GetAnnotations())
+	ok, err := annos.Pick(metadata)
+	if err != nil {
+		return bindingRef{}, false, fmt.Errorf("failed to read grant metadata: %w", err)
+	}
+	if !ok {
+		return bindingRef{}, false, nil
+	}
+
+	fields := metadata.GetMetadata().GetFields()
+	ref := bindingRef{
+		kind:            fields[GrantMetadataBindingKind].GetStringValue(),
+		name:            fields[GrantMetadataBindingName].GetStringValue(),
+		namespace:       fields[GrantMetadataBindingNamespace].GetStringValue(),
+		resourceVersion: fields[GrantMetadataBindingResourceVersion].GetStringValue(),
+		generation:      int64(fields[GrantMetadataBindingGeneration].GetNumberValue()),
+		viaGroup:        fields[GrantMetadataViaGroup].GetStringValue(),
+		viaAggregate:    fields[GrantMetadataViaAggregate].GetStringValue(),
+	}
+	if ref.kind == "" || ref.name == "" {
+		return bindingRef{}, false, nil
+	}
+	return ref, true, nil
+}
+
+// additionalBindingRefs returns the other bindings conferring a grant, recorded when duplicate grants were merged.
+func additionalBindingRefs(g *v2.Grant) ([]bindingRef, error) {
+	metadata := &v2.GrantMetadata{}
+	annos := annotations.Annotations(g.GetAnnotations())
+	if _, err := annos.Pick(metadata); err != nil {
+		return nil, fmt.Errorf("failed to read grant metadata: %w", err)
+	}
+
+	var rv []bindingRef
+	for _, value := range metadata.GetMetadata().GetFields()[GrantMetadataAdditionalBindings].GetListValue().GetValues() {
+		fields := value.GetStructValue().GetFields()
+		ref := bindingRef{
+			kind:            fields[GrantMetadataBindingKind].GetStringValue(),
+			name:            fields[GrantMetadataBindingName].GetStringValue(),
+			namespace:       fields[GrantMetadataBindingNamespace].GetStringValue(),
+			resourceVersion: fields[GrantMetadataBindingResourceVersion].GetStringValue(),
+			generation:      int64(fields[GrantMetadataBindingGeneration].GetNumberValue()),
+		}
+		if ref.kind == "" || ref.name == "" {
+			return nil, fmt.Errorf("grant metadata lists an additional binding without kind or name")
+		}
+		rv = append(rv, ref)
+	}
+	return rv, nil
+}
+
+// mergeBindingRefs records the bindings of duplicate grants in the additional bindings of the grant kept in
+// their place, so that revoking the grant removes the subject from every binding conferring it. Grants without
+// binding metadata are left unchanged.
+func mergeBindingRefs(kept *v2.Grant, duplicates []*v2.Grant) {
+	primary, ok, err := bindingRefFromGrant(kept)
+	if err != nil || !ok {
+		return
+	}
+	additional, err := additionalBindingRefs(kept)
+	if err != nil {
+		return
+	}
+	recorded := map[bindingKey]bool{primary.key(): true}
+	for _, ref := range additional {
+		recorded[ref.key()] = true
+	}
+
+	var entries []*structpb.Value
+	for _, duplicate := range duplicates {
+		ref, ok, err := bindingRefFromGrant(duplicate)
+		if err != nil || !ok || ref.viaGroup != "" || ref.viaAggregate != "" || recorded[ref.key()] {
+			continue
+		}
+		recorded[ref.key()] = true
+
+		entry := map[string]interface{}{
+			GrantMetadataBindingKind:            ref.kind,
+			GrantMetadataBindingName:            ref.name,
+			GrantMetadataBindingResourceVersion: ref.resourceVersion,
+		}
+		if ref.namespace != "" {
+			entry[GrantMetadataBindingNamespace] = ref.namespace
+		}
+		if ref.generation != 0 {
+			entry[GrantMetadataBindingGeneration] = ref.generation
+		}
+		value, err := structpb.NewStruct(entry)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, structpb.NewStructValue(value))
+	}
+	if len(entries) == 0 {
+		return
+	}
+
+	metadata := &v2.GrantMetadata{}
+	annos := annotations.Annotations(kept.Annotations)
+	if _, err := annos.Pick(metadata); err != nil {
+		return
+	}
+	fields := metadata.GetMetadata().GetFields()
+	list := fields[GrantMetadataAdditionalBindings].GetListValue()
+	if list == nil {
+		list = &structpb.ListValue{}
+	}
+	list.Values = append(list.Values, entries...)
+	fields[GrantMetadataAdditionalBindings] = structpb.NewListValue(list)
+	annos.Update(metadata)
+	kept.Annotations = annos
+}
+
+// bindingKey identifies a binding.
+type bindingKey struct {
+	kind, namespace, name string
+}
+
+// key returns the key of the binding a reference names.
+func (r bindingRef) key() bindingKey {
+	return bindingKey{kind: r.kind, namespace: r.namespace, name: r.name}
+}
+
+// checkBindingVersion returns a Conflict error if the binding changed since the grant was synced.
+func checkBindingVersion(ref bindingRef, resource string, current metav1.ObjectMeta) error {
+	if ref.resourceVersion == "" || ref.resourceVersion == current.ResourceVersion {
+		return nil
+	}
+	return k8serrors.NewConflict(
+		rbacv1.Resource(resource),
+		ref.name,
+		fmt.Errorf("binding changed since the grant was synced (resourceVersion %s, synced %s), re-sync before revoking",
+			current.ResourceVersion, ref.resourceVersion),
+	)
+}
+
+// removeSubject returns the subjects without the given subject.
+func removeSubject(subjects []rbacv1.Subject, subject rbacv1.Subject) []rbacv1.Subject {
+	var remaining []rbacv1.Subject
+	for _, s := range subjects {
+		if sameSubject(s, subject) {
+			continue
+		}
+		remaining = append(remaining, s)
+	}
+	return remaining
+}
+
+// revokeBindingSubject removes the subject from the referenced binding, deleting the binding when no
+// subjects remain. The binding's resourceVersion at sync time is used as a precondition, so a binding that
+// changed concurrently results in a Conflict error instead of overwriting the change.
+func revokeBindingSubject(ctx context.Context, client kubernetes.Interface, ref bindingRef, roleRef rbacv1.RoleRef, subject rbacv1.Subject) (annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+	alreadyRevoked := func() (annotations.Annotations, error) {
+		l.Info("binding no longer grants the role to the subject",
+			zap.String("kind", ref.kind),
+			zap.String("binding", ref.name),
+			zap.String("namespace", ref.namespace))
+		return annotations.New(&v2.GrantAlreadyRevoked{}), nil
+	}
+
+	var err error
+	switch ref.kind {
+	case BindingKindRoleBinding:
+		bindings := client.RbacV1().RoleBindings(ref.namespace)
+		current, getErr := bindings.Get(ctx, ref.name, metav1.GetOptions{})
+		if getErr != nil {
+			if k8serrors.IsNotFound(getErr) {
+				return alreadyRevoked()
+			}
+			return nil, fmt.Errorf("failed to get role binding %s: %w", ref.name, getErr)
+		}
+		if err := checkBindingVersion(ref, RoleBindings, current.ObjectMeta); err != nil {
+			return nil, err
+		}
+		if !bindingGrantsSubject(current.RoleRef, current.Subjects, roleRef, subject) {
+			return alreadyRevoked()
+		}
+
+		remaining := removeSubject(current.Subjects, subject)
+		if len(remaining) == 0 {
+			err = bindings.Delete(ctx, ref.name, metav1.DeleteOptions{
+				Preconditions: &metav1.Preconditions{ResourceVersion: &current.ResourceVersion},
+			})
+		} else {
+			current.Subjects = remaining
+			_, err = bindings.Update(ctx, current, metav1.UpdateOptions{})
+		}
+	case BindingKindClusterRoleBinding:
+		bindings := client.RbacV1().ClusterRoleBindings()
+		current, getErr := bindings.Get(ctx, ref.name, metav1.GetOptions{})
+		if getErr != nil {
+			if k8serrors.IsNotFound(getErr) {
+				return alreadyRevoked()
+			}
+			return nil, fmt.Errorf("failed to get cluster role binding %s: %w", ref.name, getErr)
+		}
+		if err := checkBindingVersion(ref, ResourceTypeClusterRoleBindings, current.ObjectMeta); err != nil {
+			return nil, err
+		}
+		if !bindingGrantsSubject(current.RoleRef, current.Subjects, roleRef, subject) {
+			return alreadyRevoked()
+		}
+
+		remaining := removeSubject(current.Subjects, subject)
+		if len(remaining) == 0 {
+			err = bindings.Delete(ctx, ref.name, metav1.DeleteOptions{
+				Preconditions: &metav1.Preconditions{ResourceVersion: &current.ResourceVersion},
+			})
+		} else {
+			current.Subjects = remaining
+			_, err = bindings.Update(ctx, current, metav1.UpdateOptions{})
+		}
+	default:
+		return nil, fmt.Errorf("unsupported binding kind: %s", ref.kind)
+	}
+
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return alreadyRevoked()
+		}
+		if k8serrors.IsConflict(err) {
+			return nil, fmt.Errorf("%s %s changed while revoking, re-sync before revoking: %w", ref.kind, ref.name, err)
+		}
+		return nil, fmt.Errorf("failed to revoke subject from %s %s: %w", ref.kind, ref.name, err)
+	}
+
+	return nil, nil
+}
+
+// subjectForPrincipal returns the RBAC subject for a Baton principal.
+func subjectForPrincipal(principal *v2.ResourceId) (rbacv1.Subject, error) {
+	if principal == nil {
+		return rbacv1.Subject{}, fmt.Errorf("principal cannot be nil")
+	}
+
+	switch principal.ResourceType {
+	case ResourceTypeServiceAccount.Id:
+		namespace, name, found := strings.Cut(principal.Resource, "/")
+		if !found || namespace == "" || name == "" {
+			return rbacv1.Subject{}, fmt.Errorf("invalid service account ID %q, expected namespace/name", principal.Resource)
+		}
+		return rbacv1.Subject{
+			Kind:      SubjectKindServiceAccount,
+			Name:      name,
+			Namespace: namespace,
+		}, nil
+	case ResourceTypeKubeUser.Id, ResourceTypeKubeSystemUser.Id:
+		name, err := subjectName(principal.Resource)
+		if err != nil {
+			return rbacv1.Subject{}, err
+		}
+		return rbacv1.Subject{
+			Kind:     SubjectKindUser,
+			APIGroup: RBACAPIGroup,
+			Name:     name,
+		}, nil
+	case ResourceTypeKubeGroup.Id:
+		name, err := subjectName(principal.Resource)
+		if err != nil {
+			return rbacv1.Subject{}, err
+		}
+		return rbacv1.Subject{
+			Kind:     SubjectKindGroup,
+			APIGroup: RBACAPIGroup,
+			Name:     name,
+		}, nil
+	default:
+		return rbacv1.Subject{}, fmt.Errorf("unsupported principal type: %s", principal.ResourceType)
+	}
+}
+
+// managedBindingName returns the deterministic name of the binding the connector creates to bind
+// a role to a subject, so that repeated grants resolve to the same object.
+func managedBindingName(roleRef rbacv1.RoleRef, subject rbacv1.Subject) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		roleRef.Kind,
+		roleRef.Name,
+		subject.Kind,
+		subject.Namespace,
+		subject.Name,
+	}, "/")))
+
+	roleName := roleRef.Name
+	if len(roleName) > maxBindingRoleNameLength {
+		roleName = roleName[:maxBindingRoleNameLength]
+	}
+
+	return managedBindingPrefix + roleName + "-" + hex.EncodeToString(sum[:])[:10]
+}
+
+// managedBindingMeta returns the object metadata for a binding created by the connector.
+func managedBindingMeta(name, namespace string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:      name,
+		Namespace: namespace,
+		Labels: map[string]string{
+			ManagedByLabel: ManagedByValue,
+		},
+	}
+}
+
+// bindingGrantsSubject reports whether a binding with the given role reference and subjects
+// binds the role to the subject.
+func bindingGrantsSubject(roleRef rbacv1.RoleRef, subjects []rbacv1.Subject, wantRoleRef rbacv1.RoleRef, subject rbacv1.Subject) bool {
+	if roleRef.Kind != wantRoleRef.Kind || roleRef.Name != wantRoleRef.Name {
+		return false
+	}
+	for _, s := range subjects {
+		if sameSubject(s, subject) {
+			return true
+		}
+	}
+	return false
+}
+
+// listNamespaceNames returns the names of the namespaces in the cluster matching the selector, or of all
+// namespaces if it is nil, reporting the progress to progress if it is set.
+func listNamespaceNames(ctx context.Context, client kubernetes.Interface, selector labels.Selector, progress *progressReporter) ([]string, error) {
+	var (
+		names      []string
+		continueAt string
+	)
+	load := progress.start(progressCacheNamespaces)
+	for {
+		opts := metav1.ListOptions{
+			Limit:    ResourcesPageSize,
+			Continue: continueAt,
+		}
+		if selector != nil {
+			opts.LabelSelector = selector.String()
+		}
+		nsList, err := client.CoreV1().Namespaces().List(ctx, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list namespaces: %w", err)
+		}
+		for _, ns := range nsList.Items {
+			names = append(names, ns.Name)
+		}
+		load.page(ctx, len(nsList.Items))
+		if nsList.Continue == "" {
+			break
+		}
+		continueAt = nsList.Continue
+	}
+	return names, nil
+}
+
+// namespaceBindResult is the outcome of binding a role in a single namespace.
+type namespaceBindResult struct {
+	Namespace string
+	// Created is false when an equivalent binding already existed.
+	Created bool
+	Err     error
+}
+
+// bindRoleInNamespaces creates a RoleBinding from the subject to the role in each of the given namespaces.
+//
+// The subject is validated once for the whole batch, then each namespace costs a single create call, which
+// fails with ErrNamespaceNotFound in a namespace that doesn't exist. Bindings are named deterministically, so
+// binding a namespace that is already bound is a no-op. A failure in one namespace doesn't stop the others; the
+// per-namespace results are returned together with an error joining every failure.
+func bindRoleInNamespaces(ctx context.Context, client kubernetes.Interface, roleRef rbacv1.RoleRef, subject rbacv1.Subject, namespaces []string) ([]namespaceBindResult, error) {
+	l := ctxzap.Extract(ctx)
+
+	namespaces = uniqueSorted(namespaces)
+	if len(namespaces) == 0 {
+		return nil, fmt.Errorf("no namespaces to bind %s %s in", roleRef.Kind, roleRef.Name)
+	}
+
+	bindingName := managedBindingName(roleRef, subject)
+	results := make([]namespaceBindResult, 0, len(namespaces))
+	var errs []error
+	for _, ns := range namespaces {
+		result := namespaceBindResult{Namespace: ns}
+		result.Created, result.Err = bindRoleInNamespace(ctx, client, bindingName, ns, roleRef, subject)
+
+		if result.Err != nil {
+			errs = append(errs, result.Err)
+		} else {
+			l.Debug("bound role in namespace",
+				zap.String("namespace", ns),
+				zap.String("binding", bindingName),
+				zap.Bool("created", result.Created))
+		}
+		results = append(results, result)
+	}
+
+	return results, errors.Join(errs...)
+}
+
+// bindRoleInNamespace creates a single RoleBinding, treating an existing equivalent binding as success.
+func bindRoleInNamespace(ctx context.Context, client kubernetes.Interface, bindingName, namespace string, roleRef rbacv1.RoleRef, subject rbacv1.Subject) (bool, error) {
+	binding := &rbacv1.RoleBinding{
+		ObjectMeta: managedBindingMeta(bindingName, namespace),
+		RoleRef:    roleRef,
+		Subjects:   []rbacv1.Subject{subject},
+	}
+
+	_, err := client.RbacV1().RoleBindings(namespace).Create(ctx, binding, metav1.CreateOptions{})
+	if err == nil {
+		return true, nil
+	}
+	if k8serrors.IsNotFound(err) {
+		return false, fmt.Errorf("namespace %q: %w", namespace, ErrNamespaceNotFound)
+	}
+	if !k8serrors.IsAlreadyExists(err) {
+		return false, fmt.Errorf("namespace %q: failed to create role binding: %w", namespace, err)
+	}
+
+	current, err := client.RbacV1().RoleBindings(namespace).Get(ctx, bindingName, metav1.GetOptions{})
+	if err != nil {
+		return false, fmt.Errorf("namespace %q: failed to get existing role binding: %w", namespace, err)
+	}
+	if !bindingGrantsSubject(current.RoleRef, current.Subjects, roleRef, subject) {
+		return false, fmt.Errorf("namespace %q: role binding %s exists but doesn't bind %s %s", namespace, bindingName, subject.Kind, subject.Name)
+	}
+
+	return false, nil
+}
+
+// uniqueSorted returns the non-empty values in sorted order without duplicates.
+func uniqueSorted(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	var rv []string
+	for _, v := range values {
+		if v == "" || seen[v] {
+			continue
+		}
+		seen[v] = true
+		rv = append(rv, v)
+	}
+	sort.Strings(rv)
+	return rv
+}
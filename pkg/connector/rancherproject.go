@@ -0,0 +1,279 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	rbacv1 "k8s.io/api/rbac/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// RancherProjectLabel is the label Rancher sets on the namespaces of a project to the project ID.
+	RancherProjectLabel = "field.cattle.io/projectId"
+	// ProjectMemberEntitlement is the entitlement of a project granted to its namespaces and to the subjects of
+	// its project role template bindings.
+	ProjectMemberEntitlement = "member"
+	// GrantMetadataRoleTemplates lists the Rancher role templates a project member grant was derived from.
+	GrantMetadataRoleTemplates = "roleTemplates"
+)
+
+// rancherPRTBResource is Rancher's ProjectRoleTemplateBinding custom resource, binding users, groups and service
+// accounts to a role template in a project.
+var rancherPRTBResource = schema.GroupVersionResource{Group: "management.cattle.io", Version: "v3", Resource: "projectroletemplatebindings"}
+
+// projectBuilder syncs the Rancher projects the namespaces are grouped into, from the project label of the
+// namespaces. On clusters without Rancher no namespace has the label and nothing is synced. It's safe for
+// concurrent use, mu guarding the project role template bindings loaded for the sync.
+type projectBuilder struct {
+	client kubernetes.Interface
+	// dynamic lists the project role template bindings, or is nil when the connector has no REST config
+	dynamic dynamic.Interface
+	opts    ConnectorOpts
+
+	mu    sync.Mutex
+	prtbs map[string][]projectBinding // project ID -> bindings
+}
+
+// projectBinding is a subject bound to a role template in a project.
+type projectBinding struct {
+	subject      rbacv1.Subject
+	roleTemplate string
+}
+
+// ResourceType returns the resource type for Rancher projects.
+func (p *projectBuilder) ResourceType(_ context.Context) *v2.ResourceType {
+	return ResourceTypeProject
+}
+
+// List groups the namespaces with the project label by project, in a single page as clusters have few projects.
+func (p *projectBuilder) List(ctx context.Context, _ *v2.ResourceId, _ *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	projects, err := p.projectNamespaces(ctx, "")
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	// The bindings are loaded again for the grants of the sync
+	p.mu.Lock()
+	p.prtbs = nil
+	p.mu.Unlock()
+
+	ids := make([]string, 0, len(projects))
+	for id := range projects {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	rv := make([]*v2.Resource, 0, len(ids))
+	for _, id := range ids {
+		resource, err := projectResource(id, projects[id])
+		if err != nil {
+			return nil, "", nil, err
+		}
+		rv = append(rv, resource)
+	}
+	return rv, "", nil, nil
+}
+
+// projectNamespaces returns the sorted names of the namespaces of each project, or of the given project only.
+func (p *projectBuilder) projectNamespaces(ctx context.Context, projectID string) (map[string][]string, error) {
+	selector := RancherProjectLabel
+	if projectID != "" {
+		selector = RancherProjectLabel + "=" + projectID
+	}
+
+	projects := make(map[string][]string)
+	err := listPages(p.opts.pageSize(ResourceTypeNamespace.Id), func(opts metav1.ListOptions) (string, error) {
+		opts.LabelSelector = selector
+		resp, err := p.client.CoreV1().Namespaces().List(ctx, opts)
+		if err != nil {
+			return "", fmt.Errorf("failed to list namespaces: %w", err)
+		}
+		for _, ns := range resp.Items {
+			if id := ns.Labels[RancherProjectLabel]; id != "" {
+				projects[id] = append(projects[id], ns.Name)
+			}
+		}
+		return resp.Continue, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, namespaces := range projects {
+		sort.Strings(namespaces)
+	}
+	return projects, nil
+}
+
+// projectResource creates a Baton group resource for a Rancher project.
+func projectResource(id string, namespaces []string) (*v2.Resource, error) {
+	profile := map[string]interface{}{
+		"projectId":  id,
+		"namespaces": stringsToInterfaces(namespaces),
+	}
+	resource, err := rs.NewGroupResource(id, ResourceTypeProject, id, []rs.GroupTraitOption{rs.WithGroupProfile(profile)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create project resource: %w", err)
+	}
+	return resource, nil
+}
+
+// Entitlements returns the member entitlement of a project.
+func (p *projectBuilder) Entitlements(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	memberEnt := entitlement.NewAssignmentEntitlement(
+		resource,
+		ProjectMemberEntitlement,
+		entitlement.WithDisplayName(fmt.Sprintf("%s Project Member", resource.DisplayName)),
+		entitlement.WithDescription(fmt.Sprintf("Namespaces in the %s Rancher project, and subjects bound to a role in it", resource.DisplayName)),
+		entitlement.WithGrantableTo(
+			ResourceTypeNamespace,
+			ResourceTypeKubeUser,
+			ResourceTypeKubeGroup,
+			ResourceTypeServiceAccount,
+		),
+	)
+	return []*v2.Entitlement{memberEnt}, "", nil, nil
+}
+
+// Grants returns the member grants of the namespaces of a project and, when Rancher's project role template
+// bindings are served, of the subjects they bind in it.
+func (p *projectBuilder) Grants(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+	projectID := resource.Id.Resource
+
+	projects, err := p.projectNamespaces(ctx, projectID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	var rv []*v2.Grant
+	for _, namespace := range projects[projectID] {
+		rv = append(rv, grant.NewGrant(resource, ProjectMemberEntitlement, &v2.ResourceId{ResourceType: ResourceTypeNamespace.Id, Resource: namespace}))
+	}
+
+	prtbs, err := p.projectRoleTemplateBindings(ctx)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	// A subject bound to several role templates is granted membership once, listing them all
+	roleTemplates := make(map[string][]string)
+	var principals []*v2.ResourceId
+	for _, binding := range prtbs[projectID] {
+		principal, err := subjectPrincipalID(binding.subject, p.opts)
+		if err != nil {
+			l.Debug("skipping project role template binding subject", zap.String("project", projectID),
+				zap.String("kind", binding.subject.Kind), zap.String("name", binding.subject.Name), zap.Error(err))
+			continue
+		}
+		key := resourceIDKey(principal)
+		if _, ok := roleTemplates[key]; !ok {
+			principals = append(principals, principal)
+		}
+		roleTemplates[key] = append(roleTemplates[key], binding.roleTemplate)
+	}
+	for _, principal := range principals {
+		templates := roleTemplates[resourceIDKey(principal)]
+		sort.Strings(templates)
+		rv = append(rv, grant.NewGrant(resource, ProjectMemberEntitlement, principal,
+			grant.WithGrantMetadata(map[string]interface{}{GrantMetadataRoleTemplates: stringsToInterfaces(templates)})))
+	}
+
+	return rv, "", nil, nil
+}
+
+// projectRoleTemplateBindings returns the project role template bindings by project ID, loaded once per sync.
+// Clusters not serving the custom resource have none.
+func (p *projectBuilder) projectRoleTemplateBindings(ctx context.Context) (map[string][]projectBinding, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.prtbs != nil {
+		return p.prtbs, nil
+	}
+
+	prtbs := make(map[string][]projectBinding)
+	if p.dynamic != nil {
+		err := listPages(ResourcesPageSize, func(opts metav1.ListOptions) (string, error) {
+			resp, err := p.dynamic.Resource(rancherPRTBResource).Namespace(metav1.NamespaceAll).List(ctx, opts)
+			if err != nil {
+				return "", err
+			}
+			for _, item := range resp.Items {
+				if projectID, binding, ok := parseProjectBinding(&item); ok {
+					prtbs[projectID] = append(prtbs[projectID], binding)
+				}
+			}
+			return resp.GetContinue(), nil
+		})
+		switch {
+		case k8serrors.IsNotFound(err):
+			ctxzap.Extract(ctx).Debug("project role template bindings aren't served, not a Rancher cluster")
+			prtbs = make(map[string][]projectBinding)
+		case err != nil:
+			return nil, fmt.Errorf("failed to list project role template bindings: %w", err)
+		}
+	}
+
+	p.prtbs = prtbs
+	return prtbs, nil
+}
+
+// parseProjectBinding returns the project ID and binding of a project role template binding. Rancher binds users
+// by user ID, groups by principal name and service accounts as <namespace>:<name>.
+func parseProjectBinding(prtb *unstructured.Unstructured) (string, projectBinding, bool) {
+	field := func(name string) string {
+		value, _, _ := unstructured.NestedString(prtb.Object, name)
+		return value
+	}
+
+	// projectName is <cluster ID>:<project ID>
+	projectName := field("projectName")
+	_, projectID, ok := strings.Cut(projectName, ":")
+	if !ok || projectID == "" {
+		return "", projectBinding{}, false
+	}
+
+	subject := rbacv1.Subject{APIGroup: RBACAPIGroup}
+	switch {
+	case field("userName") != "":
+		subject.Kind, subject.Name = SubjectKindUser, field("userName")
+	case field("groupPrincipalName") != "":
+		subject.Kind, subject.Name = SubjectKindGroup, field("groupPrincipalName")
+	case field("groupName") != "":
+		subject.Kind, subject.Name = SubjectKindGroup, field("groupName")
+	case field("serviceAccount") != "":
+		namespace, name, ok := strings.Cut(field("serviceAccount"), ":")
+		if !ok {
+			return "", projectBinding{}, false
+		}
+		subject.APIGroup = ""
+		subject.Kind, subject.Namespace, subject.Name = SubjectKindServiceAccount, namespace, name
+	default:
+		return "", projectBinding{}, false
+	}
+	return projectID, projectBinding{subject: subject, roleTemplate: field("roleTemplateName")}, true
+}
+
+// newProjectBuilder creates a new Rancher project builder.
+func newProjectBuilder(client kubernetes.Interface, dynamicClient dynamic.Interface, opts ConnectorOpts) *projectBuilder {
+	return &projectBuilder{
+		client:  client,
+		dynamic: dynamicClient,
+		opts:    opts,
+	}
+}
@@ -0,0 +1,174 @@
+package connector
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+const testPRTBList = `{
+  "apiVersion": "management.cattle.io/v3",
+  "kind": "ProjectRoleTemplateBindingList",
+  "metadata": {},
+  "items": [
+    {"apiVersion": "management.cattle.io/v3", "kind": "ProjectRoleTemplateBinding",
+     "metadata": {"name": "prtb-1", "namespace": "p-abc12"},
+     "projectName": "c-m-xyz:p-abc12", "roleTemplateName": "project-owner", "userName": "u-alice"},
+    {"apiVersion": "management.cattle.io/v3", "kind": "ProjectRoleTemplateBinding",
+     "metadata": {"name": "prtb-2", "namespace": "p-abc12"},
+     "projectName": "c-m-xyz:p-abc12", "roleTemplateName": "read-only", "userName": "u-alice"},
+    {"apiVersion": "management.cattle.io/v3", "kind": "ProjectRoleTemplateBinding",
+     "metadata": {"name": "prtb-3", "namespace": "p-abc12"},
+     "projectName": "c-m-xyz:p-abc12", "roleTemplateName": "project-member", "groupPrincipalName": "github_team://1234"},
+    {"apiVersion": "management.cattle.io/v3", "kind": "ProjectRoleTemplateBinding",
+     "metadata": {"name": "prtb-4", "namespace": "p-def34"},
+     "projectName": "c-m-xyz:p-def34", "roleTemplateName": "project-member", "userName": "u-bob"}
+  ]
+}`
+
+func newProjectTestClient() *fake.Clientset {
+	project := func(name, projectID string) *corev1.Namespace {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		if projectID != "" {
+			ns.Labels = map[string]string{RancherProjectLabel: projectID}
+		}
+		return ns
+	}
+	return fake.NewSimpleClientset(
+		project("web", "p-abc12"),
+		project("api", "p-abc12"),
+		project("monitoring", "p-def34"),
+		project("default", ""),
+	)
+}
+
+// newPRTBServer serves the project role template bindings, or 404s like clusters without Rancher when body is empty.
+func newPRTBServer(t *testing.T, body string) dynamic.Interface {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if body == "" || r.URL.Path != "/apis/management.cattle.io/v3/projectroletemplatebindings" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := dynamic.NewForConfig(&rest.Config{Host: server.URL})
+	require.NoError(t, err)
+	return client
+}
+
+func TestProjectBuilder_List(t *testing.T) {
+	ctx := context.Background()
+	b := newProjectBuilder(newProjectTestClient(), nil, ConnectorOpts{})
+
+	resources, next, _, err := b.List(ctx, nil, nil)
+	require.NoError(t, err)
+	assert.Empty(t, next)
+	require.Len(t, resources, 2)
+	assert.Equal(t, "p-abc12", resources[0].Id.Resource)
+	assert.Equal(t, ResourceTypeProject.Id, resources[0].Id.ResourceType)
+	assert.Equal(t, "p-def34", resources[1].Id.Resource)
+}
+
+func TestProjectBuilder_Grants(t *testing.T) {
+	ctx := context.Background()
+	project := &v2.Resource{Id: &v2.ResourceId{ResourceType: ResourceTypeProject.Id, Resource: "p-abc12"}, DisplayName: "p-abc12"}
+
+	principals := func(grants []*v2.Grant) []string {
+		var rv []string
+		for _, g := range grants {
+			rv = append(rv, resourceIDKey(g.Principal.Id))
+		}
+		return rv
+	}
+
+	t.Run("rancher", func(t *testing.T) {
+		b := newProjectBuilder(newProjectTestClient(), newPRTBServer(t, testPRTBList), ConnectorOpts{})
+		grants, _, _, err := b.Grants(ctx, project, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"namespace:api",
+			"namespace:web",
+			"kube_user:u-alice",
+			"kube_group:github_team:%2F%2F1234",
+		}, principals(grants))
+
+		// The subject bound to two role templates is granted membership once
+		metadata := grants[2].Annotations
+		require.Len(t, metadata, 1)
+		assert.Contains(t, string(metadata[0].Value), "project-owner")
+		assert.Contains(t, string(metadata[0].Value), "read-only")
+	})
+
+	t.Run("not rancher", func(t *testing.T) {
+		b := newProjectBuilder(newProjectTestClient(), newPRTBServer(t, ""), ConnectorOpts{})
+		grants, _, _, err := b.Grants(ctx, project, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"namespace:api", "namespace:web"}, principals(grants))
+	})
+}
+
+func TestParseProjectBinding(t *testing.T) {
+	tests := []struct {
+		name        string
+		fields      map[string]interface{}
+		wantProject string
+		wantSubject rbacv1.Subject
+		wantOK      bool
+	}{
+		{
+			name:        "user",
+			fields:      map[string]interface{}{"projectName": "c-1:p-1", "userName": "u-1", "roleTemplateName": "project-owner"},
+			wantProject: "p-1",
+			wantSubject: rbacv1.Subject{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "u-1"},
+			wantOK:      true,
+		},
+		{
+			name:        "group principal",
+			fields:      map[string]interface{}{"projectName": "c-1:p-1", "groupPrincipalName": "okta_group://admins"},
+			wantProject: "p-1",
+			wantSubject: rbacv1.Subject{Kind: SubjectKindGroup, APIGroup: RBACAPIGroup, Name: "okta_group://admins"},
+			wantOK:      true,
+		},
+		{
+			name:        "service account",
+			fields:      map[string]interface{}{"projectName": "c-1:p-1", "serviceAccount": "ci:deployer"},
+			wantProject: "p-1",
+			wantSubject: rbacv1.Subject{Kind: SubjectKindServiceAccount, Namespace: "ci", Name: "deployer"},
+			wantOK:      true,
+		},
+		{
+			name:   "malformed project name",
+			fields: map[string]interface{}{"projectName": "p-1", "userName": "u-1"},
+		},
+		{
+			name:   "no subject",
+			fields: map[string]interface{}{"projectName": "c-1:p-1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			projectID, binding, ok := parseProjectBinding(&unstructured.Unstructured{Object: tt.fields})
+			assert.Equal(t, tt.wantOK, ok)
+			if !tt.wantOK {
+				return
+			}
+			assert.Equal(t, tt.wantProject, projectID)
+			assert.Equal(t, tt.wantSubject, binding.subject)
+		})
+	}
+}
@@ -0,0 +1,364 @@
+package connector
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// redactedNamePrefix prefixes the pseudonyms of redacted names.
+const redactedNamePrefix = "redacted-"
+
+// RedactOptions configures the privacy mode that pseudonymizes names in sync output.
+type RedactOptions struct {
+	// Key is the HMAC key names are pseudonymized with. The same key yields the same pseudonyms.
+	Key []byte
+	// PreservePrefixes lists name prefixes, such as "kube-" or "system:", that are left unredacted.
+	PreservePrefixes []string
+}
+
+// redactedProfileKeepKeys are the profile keys whose values never contain names.
+var redactedProfileKeepKeys = map[string]bool{
+	"uid":                               true,
+	"creationTimestamp":                 true,
+	"apiVersion":                        true,
+	"resourceVersion":                   true,
+	"type":                              true,
+	"classification":                    true,
+	"status.phase":                      true,
+	GrantMetadataBindingKind:            true,
+	GrantMetadataBindingAPIVersion:      true,
+	GrantMetadataBindingResourceVersion: true,
+	GrantMetadataBindingUID:             true,
+	GrantMetadataBindingCreated:         true,
+	GrantMetadataNonResourceURL:         true,
+	GrantMetadataNonResourceVerb:        true,
+	GrantMetadataSubjectKind:            true,
+	GrantMetadataImplicit:               true,
+	ReportProfilePrincipalType:          true,
+	ReportProfileReason:                 true,
+	ProfileRuleAPIGroups:                true,
+	ProfileRuleResources:                true,
+	ProfileRuleVerbs:                    true,
+	ProfileRuleNonResource:              true,
+}
+
+// redactedProfileDropKeys are the profile keys removed entirely, as they hold free-form customer data.
+var redactedProfileDropKeys = map[string]bool{
+	"labels":      true,
+	"annotations": true,
+}
+
+// nameRedactor deterministically pseudonymizes the names in resources, entitlements and grants,
+// remembering the mapping so resources passed back by the SDK can be restored for the wrapped syncers.
+type nameRedactor struct {
+	key              []byte
+	preservePrefixes []string
+
+	mu         sync.RWMutex
+	pseudonyms map[string]string // original name -> pseudonym
+	originals  map[string]string // pseudonym -> original name
+}
+
+// newNameRedactor creates a redactor for the given options.
+func newNameRedactor(opts *RedactOptions) *nameRedactor {
+	return &nameRedactor{
+		key:              opts.Key,
+		preservePrefixes: opts.PreservePrefixes,
+		pseudonyms:       make(map[string]string),
+		originals:        make(map[string]string),
+	}
+}
+
+// name returns the pseudonym of a single name.
+func (r *nameRedactor) name(original string) string {
+	if original == "" || original == "*" {
+		return original
+	}
+	for _, prefix := range r.preservePrefixes {
+		if strings.HasPrefix(original, prefix) {
+			return original
+		}
+	}
+
+	r.mu.RLock()
+	pseudonym, ok := r.pseudonyms[original]
+	r.mu.RUnlock()
+	if ok {
+		return pseudonym
+	}
+
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write([]byte(original))
+	pseudonym = redactedNamePrefix + hex.EncodeToString(mac.Sum(nil))[:12]
+
+	r.mu.Lock()
+	r.pseudonyms[original] = pseudonym
+	r.originals[pseudonym] = original
+	r.mu.Unlock()
+
+	return pseudonym
+}
+
+// original returns the name a pseudonym was created for, or the value itself if it isn't a pseudonym.
+func (r *nameRedactor) original(value string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if original, ok := r.originals[value]; ok {
+		return original
+	}
+	return value
+}
+
+// resourceID pseudonymizes each "/"-separated segment of a raw resource ID.
+func (r *nameRedactor) resourceID(id string) string {
+	segments := strings.Split(id, "/")
+	for i, segment := range segments {
+		segments[i] = r.name(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// restoreResourceID reverses resourceID.
+func (r *nameRedactor) restoreResourceID(id string) string {
+	segments := strings.Split(id, "/")
+	for i, segment := range segments {
+		segments[i] = r.original(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// redactStruct redacts a profile or metadata struct.
+func (r *nameRedactor) redactStruct(s *structpb.Struct) *structpb.Struct {
+	if s == nil {
+		return nil
+	}
+	rv := &structpb.Struct{Fields: make(map[string]*structpb.Value, len(s.Fields))}
+	for k, v := range s.Fields {
+		switch {
+		case redactedProfileDropKeys[k]:
+			continue
+		case redactedProfileKeepKeys[k]:
+			rv.Fields[k] = v
+		default:
+			rv.Fields[k] = r.redactValue(v)
+		}
+	}
+	return rv
+}
+
+// redactValue pseudonymizes the strings within a struct value.
+func (r *nameRedactor) redactValue(v *structpb.Value) *structpb.Value {
+	switch kind := v.GetKind().(type) {
+	case *structpb.Value_StringValue:
+		return structpb.NewStringValue(r.name(kind.StringValue))
+	case *structpb.Value_ListValue:
+		values := make([]*structpb.Value, 0, len(kind.ListValue.GetValues()))
+		for _, item := range kind.ListValue.GetValues() {
+			values = append(values, r.redactValue(item))
+		}
+		return structpb.NewListValue(&structpb.ListValue{Values: values})
+	case *structpb.Value_StructValue:
+		return structpb.NewStructValue(r.redactStruct(kind.StructValue))
+	default:
+		return v
+	}
+}
+
+// redactAnnotations redacts the profiles of trait annotations and struct annotations.
+func (r *nameRedactor) redactAnnotations(annos []*anypb.Any) ([]*anypb.Any, error) {
+	rv := make([]*anypb.Any, 0, len(annos))
+	for _, a := range annos {
+		msg, err := a.UnmarshalNew()
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal annotation: %w", err)
+		}
+
+		switch m := msg.(type) {
+		case *v2.UserTrait:
+			m.Profile = r.redactStruct(m.Profile)
+			m.Login = r.name(m.Login)
+			m.LoginAliases = nil
+			m.Emails = nil
+			m.EmployeeIds = nil
+			m.StructuredName = nil
+		case *v2.GroupTrait:
+			m.Profile = r.redactStruct(m.Profile)
+		case *v2.RoleTrait:
+			m.Profile = r.redactStruct(m.Profile)
+		case *v2.SecretTrait:
+			m.Profile = r.redactStruct(m.Profile)
+		case *v2.AppTrait:
+			m.Profile = r.redactStruct(m.Profile)
+		case *v2.GrantMetadata:
+			m.Metadata = r.redactStruct(m.Metadata)
+		case *v2.GrantExpandable:
+			for i, id := range m.EntitlementIds {
+				m.EntitlementIds[i] = r.memberEntitlementID(id)
+			}
+		case *structpb.Struct:
+			msg = r.redactStruct(m)
+		default:
+			rv = append(rv, a)
+			continue
+		}
+
+		redacted, err := anypb.New(msg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal annotation: %w", err)
+		}
+		rv = append(rv, redacted)
+	}
+	return rv, nil
+}
+
+// inboundResourceID restores the original names of a resource ID.
+func (r *nameRedactor) inboundResourceID(id *v2.ResourceId) *v2.ResourceId {
+	if id == nil {
+		return nil
+	}
+	return &v2.ResourceId{
+		ResourceType:  id.ResourceType,
+		Resource:      r.restoreResourceID(id.Resource),
+		BatonResource: id.BatonResource,
+	}
+}
+
+// inboundResource restores the original names of a resource's IDs and display name.
+func (r *nameRedactor) inboundResource(resource *v2.Resource) *v2.Resource {
+	rv, _ := proto.Clone(resource).(*v2.Resource)
+	rv.Id = r.inboundResourceID(resource.Id)
+	rv.ParentResourceId = r.inboundResourceID(resource.ParentResourceId)
+	rv.DisplayName = r.original(resource.DisplayName)
+	return rv
+}
+
+// outboundResource pseudonymizes a resource's IDs, display name and profiles, dropping its description.
+func (r *nameRedactor) outboundResource(resource *v2.Resource) (*v2.Resource, error) {
+	if resource == nil {
+		return nil, nil
+	}
+	rv, _ := proto.Clone(resource).(*v2.Resource)
+
+	// Wildcard resources have no names to redact.
+	if rv.Id != nil && rv.Id.Resource == "*" {
+		return rv, nil
+	}
+
+	if rv.Id != nil {
+		rv.Id.Resource = r.resourceID(rv.Id.Resource)
+	}
+	if rv.ParentResourceId != nil {
+		rv.ParentResourceId.Resource = r.resourceID(rv.ParentResourceId.Resource)
+	}
+	rv.DisplayName = r.name(rv.DisplayName)
+	// Free-form descriptions are dropped, as only the names seen before them could be redacted in them
+	rv.Description = ""
+
+	annos, err := r.redactAnnotations(rv.Annotations)
+	if err != nil {
+		return nil, err
+	}
+	rv.Annotations = annos
+
+	return rv, nil
+}
+
+// slug redacts the names embedded in an entitlement slug. The fixed slugs of the connector contain none.
+func (r *nameRedactor) slug(resourceTypeID, slug string) string {
+	if resourceTypeID != ResourceTypeClusterRole.Id {
+		return slug
+	}
+	switch slug {
+	case clusterScopedMember, otherNamespacesMember, namespacedMember:
+		return slug
+	}
+	namespace, ok := strings.CutSuffix(slug, ":member")
+	if !ok {
+		return slug
+	}
+	return r.name(namespace) + ":member"
+}
+
+// memberEntitlementID redacts the resource of an entitlement ID of the form "<type>:<resource>:<slug>" where
+// the slug doesn't contain names, such as the member entitlements grants are expanded through.
+func (r *nameRedactor) memberEntitlementID(id string) string {
+	resourceType, rest, ok := strings.Cut(id, ":")
+	idx := strings.LastIndex(rest, ":")
+	if !ok || idx < 0 {
+		return r.resourceID(id)
+	}
+	return resourceType + ":" + r.resourceID(rest[:idx]) + rest[idx:]
+}
+
+// outboundEntitlement pseudonymizes an entitlement, its resource and the names in its ID. Its display name is
+// replaced with its redacted slug and its description dropped, so that no name shows up in them whatever was
+// synced before.
+func (r *nameRedactor) outboundEntitlement(ent *v2.Entitlement) (*v2.Entitlement, error) {
+	if ent == nil || ent.Resource == nil || ent.Resource.Id == nil {
+		return ent, nil
+	}
+	rv, _ := proto.Clone(ent).(*v2.Entitlement)
+
+	resourceType := ent.Resource.Id.ResourceType
+	prefix := fmt.Sprintf("%s:%s:", resourceType, ent.Resource.Id.Resource)
+	slug, ok := strings.CutPrefix(ent.Id, prefix)
+	if !ok {
+		return nil, fmt.Errorf("unexpected entitlement ID %q", ent.Id)
+	}
+
+	resource, err := r.outboundResource(ent.Resource)
+	if err != nil {
+		return nil, err
+	}
+	rv.Resource = resource
+	redactedSlug := r.slug(resourceType, slug)
+	rv.Id = entitlement.NewEntitlementID(resource, redactedSlug)
+	if rv.Slug != "" {
+		rv.Slug = r.slug(resourceType, rv.Slug)
+	}
+	rv.DisplayName = redactedSlug
+	rv.Description = ""
+
+	return rv, nil
+}
+
+// outboundGrant pseudonymizes a grant's entitlement, principal and metadata.
+func (r *nameRedactor) outboundGrant(g *v2.Grant) (*v2.Grant, error) {
+	rv, _ := proto.Clone(g).(*v2.Grant)
+
+	ent, err := r.outboundEntitlement(g.Entitlement)
+	if err != nil {
+		return nil, err
+	}
+	principal, err := r.outboundResource(g.Principal)
+	if err != nil {
+		return nil, err
+	}
+	annos, err := r.redactAnnotations(g.Annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	rv.Entitlement = ent
+	rv.Principal = principal
+	rv.Annotations = annos
+	rv.Id = grant.NewGrantID(principal, ent)
+
+	return rv, nil
+}
+
+// readOnly reports that redacted syncs can't be used for provisioning, as the IDs don't exist in the cluster.
+func (r *nameRedactor) readOnly() bool {
+	return true
+}
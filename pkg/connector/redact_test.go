@@ -0,0 +1,171 @@
+package connector
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNameRedactor_Mapping(t *testing.T) {
+	r := newNameRedactor(&RedactOptions{Key: []byte("key-1"), PreservePrefixes: []string{"kube-", "system:"}})
+	same := newNameRedactor(&RedactOptions{Key: []byte("key-1")})
+	other := newNameRedactor(&RedactOptions{Key: []byte("key-2")})
+
+	pseudonym := r.name("payments")
+	assert.NotEqual(t, "payments", pseudonym)
+	assert.True(t, strings.HasPrefix(pseudonym, redactedNamePrefix))
+	assert.Equal(t, pseudonym, r.name("payments"), "mapping must be stable")
+	assert.Equal(t, pseudonym, same.name("payments"), "same key must yield the same pseudonym")
+	assert.NotEqual(t, pseudonym, other.name("payments"), "different keys must yield different pseudonyms")
+	assert.NotEqual(t, pseudonym, r.name("billing"))
+
+	// Preserved prefixes and wildcards are left intact
+	assert.Equal(t, "kube-system", r.name("kube-system"))
+	assert.Equal(t, "system:masters", r.name("system:masters"))
+	assert.Equal(t, "*", r.name("*"))
+
+	// Resource IDs are redacted per segment and can be restored
+	id := r.resourceID("payments/api-token")
+	assert.Equal(t, pseudonym+"/"+r.name("api-token"), id)
+	assert.Equal(t, "payments/api-token", r.restoreResourceID(id))
+
+	// Only the namespaces of the ClusterRole slugs are names
+	assert.Equal(t, pseudonym+":member", r.slug(ResourceTypeClusterRole.Id, "payments:member"))
+	for _, slug := range []string{clusterScopedMember, otherNamespacesMember, namespacedMember} {
+		assert.Equal(t, slug, r.slug(ResourceTypeClusterRole.Id, slug))
+	}
+	assert.Equal(t, "get", r.slug(ResourceTypeSecret.Id, "get"))
+}
+
+// TestNameRedactor_EntitlementTexts tests that the texts of entitlements leak no name, even one that wasn't
+// seen before them.
+func TestNameRedactor_EntitlementTexts(t *testing.T) {
+	r := newNameRedactor(&RedactOptions{Key: []byte("demo")})
+	resource := &v2.Resource{
+		Id:          &v2.ResourceId{ResourceType: ResourceTypeClusterRole.Id, Resource: "billing-admin"},
+		DisplayName: "billing-admin",
+		Description: "ClusterRole billing-admin, bound in payments",
+	}
+	ent := &v2.Entitlement{
+		Id:          "cluster_role:billing-admin:payments:member",
+		Resource:    resource,
+		Slug:        "payments:member",
+		DisplayName: "billing-admin member in payments",
+		Description: "Member of billing-admin through RoleBindings in payments",
+	}
+
+	rv, err := r.outboundEntitlement(ent)
+	require.NoError(t, err)
+	slug := r.name("payments") + ":member"
+	assert.Equal(t, "cluster_role:"+r.name("billing-admin")+":"+slug, rv.Id)
+	assert.Equal(t, slug, rv.DisplayName)
+	assert.Empty(t, rv.Description)
+	assert.Empty(t, rv.Resource.Description)
+}
+
+func TestRedactingSyncer_NoRawNamesLeak(t *testing.T) {
+	ctx := context.Background()
+	rawNames := []string{"payments", "billing-admin", "billing-admins", "alice", "ci-deployer", "api-token", "billing-team"}
+
+	role := &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "billing-admin",
+			Namespace: "payments",
+			Labels:    map[string]string{"owner": "alice"},
+		},
+		Rules: []rbacv1.PolicyRule{
+			{
+				Verbs:         []string{"get"},
+				APIGroups:     []string{""},
+				Resources:     []string{"secrets"},
+				ResourceNames: []string{"api-token"},
+			},
+		},
+	}
+	provider := newMockRoleBindingProvider()
+	provider.addMockBinding("payments", "billing-admin", rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "billing-admins", Namespace: "payments", ResourceVersion: "7"},
+		RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindRole, Name: "billing-admin"},
+		Subjects: []rbacv1.Subject{
+			{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "alice"},
+			{Kind: SubjectKindServiceAccount, Name: "ci-deployer", Namespace: "payments"},
+			{Kind: SubjectKindGroup, APIGroup: RBACAPIGroup, Name: "billing-team"},
+		},
+	})
+
+	redactor := newNameRedactor(&RedactOptions{Key: []byte("demo")})
+	client := fake.NewSimpleClientset(role, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "payments"}})
+	syncer := wrapSyncer(newRoleBuilder(client, provider, ConnectorOpts{LabelTags: []string{"owner"}}, nil), redactor)
+
+	var output []proto.Message
+	resources, _, _, err := syncer.List(ctx, nil, &pagination.Token{})
+	require.NoError(t, err)
+	require.Len(t, resources, 1) // wildcard
+
+	// The SDK lists the roles as the children of the pseudonymized namespace
+	parent := &v2.ResourceId{ResourceType: ResourceTypeNamespace.Id, Resource: redactor.resourceID("payments")}
+	resources, _, _, err = syncer.List(ctx, parent, &pagination.Token{})
+	require.NoError(t, err)
+	require.Len(t, resources, 1) // billing-admin
+	output = append(output, resources[0])
+
+	entitlements, _, _, err := syncer.Entitlements(ctx, resources[0], &pagination.Token{})
+	require.NoError(t, err)
+	require.NotEmpty(t, entitlements)
+	for _, ent := range entitlements {
+		assert.Equal(t, resources[0].Id.Resource, ent.Resource.Id.Resource)
+		output = append(output, ent)
+	}
+
+	grants, _, _, err := syncer.Grants(ctx, resources[0], &pagination.Token{})
+	require.NoError(t, err)
+	require.Len(t, grants, 4)
+	for _, g := range grants {
+		output = append(output, g)
+	}
+
+	// The graph structure is preserved with consistent pseudonyms
+	roleID := redactor.resourceID("payments/billing-admin")
+	assert.Equal(t, roleID, resources[0].Id.Resource)
+	assert.Equal(t, redactor.name("payments"), resources[0].ParentResourceId.Resource)
+	grantIDs := make([]string, 0, len(grants))
+	for _, g := range grants {
+		grantIDs = append(grantIDs, g.Id)
+	}
+	assert.ElementsMatch(t, []string{
+		"role:" + roleID + ":member:kube_user:" + redactor.name("alice"),
+		"role:" + roleID + ":member:service_account:" + redactor.resourceID("payments/ci-deployer"),
+		"role:" + roleID + ":member:kube_group:" + redactor.name("billing-team"),
+		"secret:" + redactor.resourceID("payments/api-token") + ":get:role:" + roleID,
+	}, grantIDs)
+
+	for _, msg := range output {
+		b, err := protojson.Marshal(msg)
+		require.NoError(t, err)
+		for _, name := range rawNames {
+			assert.NotContains(t, string(b), name)
+		}
+	}
+}
+
+func TestWrapSyncer_RedactionIsReadOnly(t *testing.T) {
+	builder := newClusterRoleBuilder(fake.NewSimpleClientset(), newMockClusterRoleBindingProvider(), ConnectorOpts{}, nil)
+	_, ok := wrapSyncer(builder).(connectorbuilder.ResourceProvisioner)
+	assert.True(t, ok)
+
+	redactor := newNameRedactor(&RedactOptions{Key: []byte("demo")})
+	_, ok = wrapSyncer(builder, redactor).(connectorbuilder.ResourceProvisioner)
+	assert.False(t, ok)
+}
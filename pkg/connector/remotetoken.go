@@ -0,0 +1,162 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DefaultRemoteTokenSecretKey is the Secret key holding the remote cluster token when the reference doesn't
+// name one, matching service account token Secrets.
+const DefaultRemoteTokenSecretKey = "token"
+
+// SecretKeyRef references a key of a Secret in the local cluster.
+type SecretKeyRef struct {
+	Namespace string
+	Name      string
+	Key       string
+}
+
+// String returns the reference in the namespace/name:key form.
+func (r SecretKeyRef) String() string {
+	return r.Namespace + "/" + r.Name + ":" + r.Key
+}
+
+// ParseSecretKeyRef parses a namespace/name[:key] Secret reference, defaulting the key to
+// DefaultRemoteTokenSecretKey.
+func ParseSecretKeyRef(ref string) (SecretKeyRef, error) {
+	nsName, key, hasKey := strings.Cut(ref, ":")
+	namespace, name, ok := strings.Cut(nsName, "/")
+	if !ok || namespace == "" || name == "" || strings.Contains(name, "/") {
+		return SecretKeyRef{}, fmt.Errorf("invalid secret reference %q, expected namespace/name[:key]", ref)
+	}
+	if !hasKey {
+		key = DefaultRemoteTokenSecretKey
+	}
+	if key == "" {
+		return SecretKeyRef{}, fmt.Errorf("invalid secret reference %q, the key is empty", ref)
+	}
+	return SecretKeyRef{Namespace: namespace, Name: name, Key: key}, nil
+}
+
+// secretTokenSource reads the bearer token of a remote cluster from a Secret in the local cluster, caching it
+// until it is refreshed.
+type secretTokenSource struct {
+	client kubernetes.Interface
+	ref    SecretKeyRef
+
+	mu    sync.Mutex
+	token string
+}
+
+// newSecretTokenSource creates a token source reading the referenced Secret with the local cluster client.
+func newSecretTokenSource(client kubernetes.Interface, ref SecretKeyRef) *secretTokenSource {
+	return &secretTokenSource{
+		client: client,
+		ref:    ref,
+	}
+}
+
+// Token returns the cached token, reading it from the Secret if it hasn't been read yet.
+func (s *secretTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	token := s.token
+	s.mu.Unlock()
+	if token != "" {
+		return token, nil
+	}
+	return s.refresh(ctx)
+}
+
+// refresh re-reads the token from the Secret, so that rotated tokens are picked up.
+func (s *secretTokenSource) refresh(ctx context.Context) (string, error) {
+	secret, err := s.client.CoreV1().Secrets(s.ref.Namespace).Get(ctx, s.ref.Name, metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return "", fmt.Errorf("remote token secret %s/%s does not exist", s.ref.Namespace, s.ref.Name)
+		}
+		return "", fmt.Errorf("failed to get remote token secret %s/%s: %w", s.ref.Namespace, s.ref.Name, err)
+	}
+
+	value, ok := secret.Data[s.ref.Key]
+	if !ok {
+		return "", fmt.Errorf("remote token secret %s/%s has no key %q", s.ref.Namespace, s.ref.Name, s.ref.Key)
+	}
+	token := strings.TrimSpace(string(value))
+	if token == "" {
+		return "", fmt.Errorf("remote token secret %s/%s has an empty key %q", s.ref.Namespace, s.ref.Name, s.ref.Key)
+	}
+
+	s.mu.Lock()
+	s.token = token
+	s.mu.Unlock()
+	return token, nil
+}
+
+// secretTokenRoundTripper authenticates requests to the remote cluster with the token from a Secret. When the
+// remote cluster rejects the token it re-reads the Secret and, if the token was rotated, retries once.
+type secretTokenRoundTripper struct {
+	source *secretTokenSource
+	rt     http.RoundTripper
+}
+
+// wrapTransport returns a rest.Config WrapTransport function authenticating with the token source.
+func (s *secretTokenSource) wrapTransport(rt http.RoundTripper) http.RoundTripper {
+	return &secretTokenRoundTripper{source: s, rt: rt}
+}
+
+// RoundTrip sends the request with the current token, retrying with a rotated token on 401.
+func (t *secretTokenRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	token, err := t.source.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.rt.RoundTrip(withBearerToken(req, token))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	rotated, err := t.source.refresh(ctx)
+	if err != nil {
+		ctxzap.Extract(ctx).Warn("failed to re-read remote token secret after unauthorized response", zap.Error(err))
+		return resp, nil
+	}
+	if rotated == token {
+		return resp, nil
+	}
+
+	// Only requests whose body can be replayed are retried.
+	retry := withBearerToken(req, rotated)
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return resp, nil
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			ctxzap.Extract(ctx).Warn("failed to replay request body with the rotated remote token", zap.Error(err))
+			return resp, nil
+		}
+		retry.Body = body
+	}
+
+	_ = resp.Body.Close()
+	return t.rt.RoundTrip(retry)
+}
+
+// withBearerToken returns a copy of the request authenticated with the token.
+func withBearerToken(req *http.Request, token string) *http.Request {
+	r := req.Clone(req.Context())
+	r.Header.Set("Authorization", "Bearer "+token)
+	return r
+}
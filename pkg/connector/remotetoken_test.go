@@ -0,0 +1,147 @@
+package connector
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseSecretKeyRef(t *testing.T) {
+	ref, err := ParseSecretKeyRef("baton/remote-cluster")
+	require.NoError(t, err)
+	assert.Equal(t, SecretKeyRef{Namespace: "baton", Name: "remote-cluster", Key: DefaultRemoteTokenSecretKey}, ref)
+
+	ref, err = ParseSecretKeyRef("baton/remote-cluster:bearer")
+	require.NoError(t, err)
+	assert.Equal(t, SecretKeyRef{Namespace: "baton", Name: "remote-cluster", Key: "bearer"}, ref)
+
+	for _, invalid := range []string{"", "remote-cluster", "/remote-cluster", "baton/", "baton/remote/cluster", "baton/remote-cluster:"} {
+		_, err := ParseSecretKeyRef(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+// tokenSecret returns a Secret holding a remote token under the default key.
+func tokenSecret(token string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "baton", Name: "remote-cluster"},
+		Data:       map[string][]byte{DefaultRemoteTokenSecretKey: []byte(token + "\n")},
+	}
+}
+
+// TestSecretTokenRoundTripper_Rotation tests that a rejected token is re-read from the Secret and the request
+// retried with the rotated token.
+func TestSecretTokenRoundTripper_Rotation(t *testing.T) {
+	ctx := context.Background()
+
+	// The remote cluster only accepts the current token
+	var current atomic.Value
+	current.Store("token-1")
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("Authorization") != "Bearer "+current.Load().(string) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	local := fake.NewSimpleClientset(tokenSecret("token-1"))
+	source := newSecretTokenSource(local, SecretKeyRef{Namespace: "baton", Name: "remote-cluster", Key: DefaultRemoteTokenSecretKey})
+	client := &http.Client{Transport: source.wrapTransport(http.DefaultTransport)}
+
+	get := func() int {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusOK, get())
+	assert.Equal(t, int32(1), requests.Load())
+
+	// Rotate the token in the remote cluster and the Secret
+	current.Store("token-2")
+	_, err := local.CoreV1().Secrets("baton").Update(ctx, tokenSecret("token-2"), metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, get())
+	assert.Equal(t, int32(3), requests.Load())
+
+	// The rotated token is cached
+	assert.Equal(t, http.StatusOK, get())
+	assert.Equal(t, int32(4), requests.Load())
+
+	// A revoked token that hasn't been rotated in the Secret isn't retried
+	current.Store("token-3")
+	assert.Equal(t, http.StatusUnauthorized, get())
+	assert.Equal(t, int32(5), requests.Load())
+}
+
+// TestSecretTokenRoundTripper_ReplaysBody tests that requests with a body are retried with the same body.
+func TestSecretTokenRoundTripper_ReplaysBody(t *testing.T) {
+	ctx := context.Background()
+
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if r.Header.Get("Authorization") != "Bearer token-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	local := fake.NewSimpleClientset(tokenSecret("token-1"))
+	source := newSecretTokenSource(local, SecretKeyRef{Namespace: "baton", Name: "remote-cluster", Key: DefaultRemoteTokenSecretKey})
+	_, err := source.Token(ctx)
+	require.NoError(t, err)
+	_, err = local.CoreV1().Secrets("baton").Update(ctx, tokenSecret("token-2"), metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	client := &http.Client{Transport: source.wrapTransport(http.DefaultTransport)}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL, strings.NewReader(`{"kind":"RoleBinding"}`))
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, []string{`{"kind":"RoleBinding"}`, `{"kind":"RoleBinding"}`}, bodies)
+}
+
+// TestValidate_RemoteTokenSecret tests that Validate reports a missing remote token Secret or key.
+func TestValidate_RemoteTokenSecret(t *testing.T) {
+	ctx := context.Background()
+	ref := SecretKeyRef{Namespace: "baton", Name: "remote-cluster", Key: "bearer"}
+
+	k := newTestKubernetes(newValidatedClient(), ConnectorOpts{})
+	k.remoteToken = newSecretTokenSource(fake.NewSimpleClientset(), ref)
+	_, err := k.Validate(ctx)
+	require.ErrorContains(t, err, "does not exist")
+
+	k.remoteToken = newSecretTokenSource(fake.NewSimpleClientset(tokenSecret("token-1")), ref)
+	_, err = k.Validate(ctx)
+	require.ErrorContains(t, err, `has no key "bearer"`)
+
+	ref.Key = DefaultRemoteTokenSecretKey
+	k.remoteToken = newSecretTokenSource(fake.NewSimpleClientset(tokenSecret("token-1")), ref)
+	_, err = k.Validate(ctx)
+	require.NoError(t, err)
+}
@@ -0,0 +1,172 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+// replicaSetBuilder syncs Kubernetes ReplicaSets as Baton resources. It's stateless and safe for concurrent use.
+type replicaSetBuilder struct {
+	client kubernetes.Interface
+	opts   ConnectorOpts
+}
+
+// ResourceType returns the resource type for ReplicaSet.
+func (r *replicaSetBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
+	return ResourceTypeReplicaSet
+}
+
+// List fetches all ReplicaSets from the Kubernetes API.
+func (r *replicaSetBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+
+	// Parse pagination token
+	bag, err := ParsePageToken(pToken.Token)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to parse page token: %w", err)
+	}
+
+	// List the namespace the SDK lists the children of, or all namespaces, after the wildcards
+	namespace, rv, done, err := startNamespacedList(ctx, r.client, r.opts, ResourceTypeReplicaSet, parentResourceID, bag.PageToken(), nil)
+	if err != nil || done {
+		return rv, "", nil, err
+	}
+
+	// Set up list options with pagination
+	opts := metav1.ListOptions{
+		Limit:    r.opts.pageSize(ResourceTypeReplicaSet.Id),
+		Continue: bag.PageToken(),
+	}
+
+	// Fetch replicasets from the Kubernetes API in the namespace, or across all namespaces
+	l.Debug("fetching replicasets", zap.String("continue_token", opts.Continue))
+	resp, err := listWithRetry(ctx, r.opts, func(ctx context.Context) (*appsv1.ReplicaSetList, error) {
+		return r.client.AppsV1().ReplicaSets(namespace).List(ctx, opts)
+	})
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to list replicasets: %w", err)
+	}
+
+	// Process each replicaset into a Baton resource
+	for _, replicaset := range resp.Items {
+		resource, err := replicaSetResource(&replicaset, r.opts)
+		if err != nil {
+			l.Error("failed to create replicaset resource",
+				zap.String("namespace", replicaset.Namespace),
+				zap.String("name", replicaset.Name),
+				zap.Error(err))
+			continue
+		}
+		rv = append(rv, resource)
+	}
+
+	// Calculate next page token
+	nextPageToken, err := HandleKubePagination(&resp.ListMeta, bag)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to handle pagination: %w", err)
+	}
+
+	return rv, nextPageToken, nil, nil
+}
+
+// replicaSetResource creates a Baton resource from a Kubernetes ReplicaSet, parented to the Deployment owning
+// it or to its namespace.
+func replicaSetResource(replicaset *appsv1.ReplicaSet, opts ConnectorOpts) (*v2.Resource, error) {
+	// Get the owning Deployment or namespace resource ID
+	parentID, err := controllerParentID(replicaset, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create parent resource ID: %w", err)
+	}
+
+	// Create resource options with simplified description
+	options := []rs.ResourceOption{
+		rs.WithParentResourceID(parentID),
+		rs.WithDescription(fmt.Sprintf("ReplicaSet in namespace %s", replicaset.Namespace)),
+	}
+
+	// Add external ID if available
+	if len(replicaset.UID) > 0 {
+		options = append(options, rs.WithExternalID(&v2.ExternalId{Id: string(replicaset.UID)}))
+	}
+
+	// Add configured label tags
+	tagOptions, err := labelTagOptions(replicaset.Labels, opts)
+	if err != nil {
+		return nil, err
+	}
+	options = append(options, tagOptions...)
+
+	// Create the raw ID as namespace/name
+	rawID := namespacedName(replicaset.Namespace, replicaset.Name)
+
+	// Create resource
+	resource, err := rs.NewResource(
+		replicaset.Name,
+		ResourceTypeReplicaSet,
+		rawID, // Pass the raw ID directly
+		options...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create replicaset resource: %w", err)
+	}
+
+	return resource, nil
+}
+
+// Entitlements returns standard verb entitlements for ReplicaSet resources.
+func (r *replicaSetBuilder) Entitlements(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	var entitlements []*v2.Entitlement
+
+	// Add standard verb entitlements
+	for _, verb := range r.opts.resourceVerbs(ResourceTypeReplicaSet.Id) {
+		ent := entitlement.NewPermissionEntitlement(
+			resource,
+			verb,
+			entitlement.WithDisplayName(fmt.Sprintf("%s %s", verb, resource.DisplayName)),
+			entitlement.WithDescription(fmt.Sprintf("Grants %s permission on the %s replicaset", verb, resource.DisplayName)),
+			entitlement.WithGrantableTo(
+				ResourceTypeRole,
+				ResourceTypeClusterRole,
+			),
+		)
+		entitlements = append(entitlements, ent)
+	}
+
+	return entitlements, "", nil, nil
+}
+
+// Grants returns the runs_as grant of the service account the ReplicaSet runs as.
+func (r *replicaSetBuilder) Grants(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	grants, err := workloadRunsAsGrants(ctx, resource, func(namespace, name string) (*corev1.PodSpec, error) {
+		workload, err := r.client.AppsV1().ReplicaSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return &workload.Spec.Template.Spec, nil
+	})
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return grants, "", nil, nil
+}
+
+// newReplicaSetBuilder creates a new replicaset builder.
+func newReplicaSetBuilder(client kubernetes.Interface, opts ConnectorOpts) *replicaSetBuilder {
+	return &replicaSetBuilder{
+		client: client,
+		opts:   opts,
+	}
+}
@@ -0,0 +1,75 @@
+package connector
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+// hungAPIServer starts an API server that never answers, until the request is canceled.
+func hungAPIServer(t *testing.T) *rest.Config {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+	return &rest.Config{Host: server.URL}
+}
+
+// TestRequestTimeout_HungAPIServer tests that the listings and the loading of the bindings caches give up on an
+// API server that doesn't answer once the request timeout is reached.
+func TestRequestTimeout_HungAPIServer(t *testing.T) {
+	ctx := context.Background()
+	cfg := hungAPIServer(t)
+	client, err := kubernetes.NewForConfig(cfg)
+	require.NoError(t, err)
+	opts := ConnectorOpts{RequestTimeout: 50 * time.Millisecond, DisableWildcardResources: true}
+
+	done := make(chan error, 2)
+	go func() {
+		_, _, _, err := newSecretBuilder(client, nil, nil, opts).List(ctx, namespaceID("payments"), &pagination.Token{})
+		done <- err
+	}()
+	go func() {
+		done <- newKubernetes(client, cfg, opts).loadBindingsCaches(ctx)
+	}()
+
+	for range 2 {
+		select {
+		case err := <-done:
+			require.Error(t, err)
+			assert.ErrorIs(t, err, context.DeadlineExceeded)
+		case <-time.After(10 * time.Second):
+			t.Fatal("the listing didn't time out")
+		}
+	}
+}
+
+func TestRequestTimeout_Defaults(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	k := newKubernetes(client, &rest.Config{Timeout: 30 * time.Second}, ConnectorOpts{})
+	assert.Equal(t, 30*time.Second, k.opts.RequestTimeout)
+
+	k = newKubernetes(client, &rest.Config{Timeout: 30 * time.Second}, ConnectorOpts{RequestTimeout: time.Minute})
+	assert.Equal(t, time.Minute, k.opts.RequestTimeout)
+
+	// Without a timeout, calls aren't bounded
+	k = newKubernetes(client, &rest.Config{}, ConnectorOpts{})
+	callCtx, cancel := k.opts.requestContext(context.Background())
+	defer cancel()
+	_, ok := callCtx.Deadline()
+	assert.False(t, ok)
+
+	_, err := applyOptions([]ConnectorOption{WithRequestTimeout(-time.Second)})
+	assert.Error(t, err)
+}
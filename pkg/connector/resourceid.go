@@ -0,0 +1,119 @@
+package connector
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// namespacedName returns the raw ID of a namespaced object, namespace/name.
+func namespacedName(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+// isSubjectNameByteSafe reports whether a byte of a user or group name is kept as is in the ID of its resource:
+// the unreserved URI characters, and the ":" and "@" of system: and e-mail names. The "*" of a name is encoded,
+// as it's the reserved ID of the wildcard user and group resources.
+func isSubjectNameByteSafe(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("-._~:@", c) >= 0
+}
+
+// subjectResourceID returns the raw ID of the resource of a user or group. Their names are free-form, e.g.
+// OIDC subjects like https://issuer#sub, so bytes other than isSubjectNameByteSafe ones are percent-encoded.
+// Common names keep their IDs as is, and subjectName recovers the name from the ID.
+func subjectResourceID(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if isSubjectNameByteSafe(c) {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// subjectName returns the name of the user or group whose resource has the raw ID, built by subjectResourceID.
+// The wildcard resources have no name.
+func subjectName(id string) (string, error) {
+	if id == "*" {
+		return "", fmt.Errorf("invalid user or group ID %q: the wildcard resource isn't a user or group", id)
+	}
+	name, err := url.PathUnescape(id)
+	if err != nil {
+		return "", fmt.Errorf("invalid user or group ID %q: %w", id, err)
+	}
+	return name, nil
+}
+
+// isSubjectResourceType reports whether the resources of a type are users or groups, whose IDs are built by
+// subjectResourceID.
+func isSubjectResourceType(resourceTypeID string) bool {
+	switch resourceTypeID {
+	case ResourceTypeKubeUser.Id, ResourceTypeKubeSystemUser.Id, ResourceTypeKubeGroup.Id:
+		return true
+	default:
+		return false
+	}
+}
+
+// BuildResourceID returns the Baton resource ID a full sync gives a Kubernetes object. Paths that handle
+// objects outside of List, such as deletions seen by a watch, must use it so that incremental state matches
+// full syncs. Users and groups only exist as binding subjects, so there's no object to build their IDs from.
+func BuildResourceID(obj runtime.Object) (*v2.ResourceId, error) {
+	switch o := obj.(type) {
+	case *corev1.Namespace:
+		return formatResourceID(ResourceTypeNamespace, o.Name)
+	case *corev1.Node:
+		return formatResourceID(ResourceTypeNode, o.Name)
+	case *rbacv1.ClusterRole:
+		return formatResourceID(ResourceTypeClusterRole, o.Name)
+	case *rbacv1.Role:
+		return formatResourceID(ResourceTypeRole, namespacedName(o.Namespace, o.Name))
+	case *corev1.ServiceAccount:
+		return formatResourceID(ResourceTypeServiceAccount, namespacedName(o.Namespace, o.Name))
+	case *corev1.Secret:
+		return formatResourceID(ResourceTypeSecret, namespacedName(o.Namespace, o.Name))
+	case *corev1.ConfigMap:
+		return formatResourceID(ResourceTypeConfigMap, namespacedName(o.Namespace, o.Name))
+	case *corev1.Service:
+		return formatResourceID(ResourceTypeService, namespacedName(o.Namespace, o.Name))
+	case *corev1.Pod:
+		return formatResourceID(ResourceTypePod, namespacedName(o.Namespace, o.Name))
+	case *appsv1.Deployment:
+		return formatResourceID(ResourceTypeDeployment, namespacedName(o.Namespace, o.Name))
+	case *appsv1.StatefulSet:
+		return formatResourceID(ResourceTypeStatefulSet, namespacedName(o.Namespace, o.Name))
+	case *appsv1.DaemonSet:
+		return formatResourceID(ResourceTypeDaemonSet, namespacedName(o.Namespace, o.Name))
+	case *appsv1.ReplicaSet:
+		return formatResourceID(ResourceTypeReplicaSet, namespacedName(o.Namespace, o.Name))
+	default:
+		return nil, fmt.Errorf("unsupported object type %T", obj)
+	}
+}
+
+// DeletedResourceID returns the ID to report the deletion of a Kubernetes object with. It's the ID
+// BuildResourceID returns, transformed the way the sync output is, so that a redacted sync deletes the
+// pseudonymized resource it created.
+func (k *Kubernetes) DeletedResourceID(obj runtime.Object) (*v2.ResourceId, error) {
+	id, err := BuildResourceID(obj)
+	if err != nil {
+		return nil, err
+	}
+	if k.redactor != nil {
+		id.Resource = k.redactor.resourceID(id.Resource)
+	}
+	return id, nil
+}
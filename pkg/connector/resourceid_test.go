@@ -0,0 +1,256 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// resourceIDContractCases pairs an object of every synced resource type with the builder function a full
+// sync creates its resource with.
+func resourceIDContractCases() []struct {
+	name    string
+	obj     runtime.Object
+	builder func(opts ConnectorOpts) (*v2.Resource, error)
+} {
+	meta := metav1.ObjectMeta{Name: "payments-api", Namespace: "payments", UID: "uid-1"}
+	clusterMeta := metav1.ObjectMeta{Name: "payments-api", UID: "uid-2"}
+
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "payments", UID: "uid-3"}}
+	node := &corev1.Node{ObjectMeta: clusterMeta}
+	clusterRole := &rbacv1.ClusterRole{ObjectMeta: clusterMeta}
+	role := &rbacv1.Role{ObjectMeta: meta}
+	serviceAccount := &corev1.ServiceAccount{ObjectMeta: meta}
+	secret := &corev1.Secret{ObjectMeta: meta, Type: corev1.SecretTypeOpaque}
+	configMap := &corev1.ConfigMap{ObjectMeta: meta}
+	service := &corev1.Service{ObjectMeta: meta}
+	pod := &corev1.Pod{ObjectMeta: meta}
+	deployment := &appsv1.Deployment{ObjectMeta: meta}
+	statefulSet := &appsv1.StatefulSet{ObjectMeta: meta}
+	daemonSet := &appsv1.DaemonSet{ObjectMeta: meta}
+
+	return []struct {
+		name    string
+		obj     runtime.Object
+		builder func(opts ConnectorOpts) (*v2.Resource, error)
+	}{
+		{"namespace", namespace, func(opts ConnectorOpts) (*v2.Resource, error) { return namespaceResource(namespace, nil, opts) }},
+		{"node", node, func(opts ConnectorOpts) (*v2.Resource, error) { return nodeResource(node, opts) }},
+		{"cluster role", clusterRole, func(opts ConnectorOpts) (*v2.Resource, error) { return clusterRoleResource(clusterRole, opts, nil) }},
+		{"role", role, func(opts ConnectorOpts) (*v2.Resource, error) { return roleResource(role, opts, nil) }},
+		{"service account", serviceAccount, func(opts ConnectorOpts) (*v2.Resource, error) {
+			return serviceAccountResource(serviceAccount, opts)
+		}},
+		{"secret", secret, func(opts ConnectorOpts) (*v2.Resource, error) { return secretResource(secret, nil, opts) }},
+		{"config map", configMap, func(opts ConnectorOpts) (*v2.Resource, error) { return configMapResource(configMap, opts) }},
+		{"service", service, func(opts ConnectorOpts) (*v2.Resource, error) { return serviceResource(service, nil, opts) }},
+		{"pod", pod, func(opts ConnectorOpts) (*v2.Resource, error) { return podResource(pod, opts) }},
+		{"deployment", deployment, func(opts ConnectorOpts) (*v2.Resource, error) { return deploymentResource(deployment, opts) }},
+		{"stateful set", statefulSet, func(opts ConnectorOpts) (*v2.Resource, error) {
+			return statefulSetResource(statefulSet, opts)
+		}},
+		{"daemon set", daemonSet, func(opts ConnectorOpts) (*v2.Resource, error) { return daemonSetResource(daemonSet, opts) }},
+	}
+}
+
+// TestBuildResourceID_MatchesBuilders tests that the ID built for an object is the ID its builder syncs it as.
+func TestBuildResourceID_MatchesBuilders(t *testing.T) {
+	for _, tc := range resourceIDContractCases() {
+		t.Run(tc.name, func(t *testing.T) {
+			resource, err := tc.builder(ConnectorOpts{})
+			require.NoError(t, err)
+
+			id, err := BuildResourceID(tc.obj)
+			require.NoError(t, err)
+			assert.Equal(t, resource.Id.ResourceType, id.ResourceType)
+			assert.Equal(t, resource.Id.Resource, id.Resource)
+		})
+	}
+}
+
+// TestDeletedResourceID_Redacted tests that deletions in a redacted sync carry the pseudonymized IDs the sync
+// output had.
+func TestDeletedResourceID_Redacted(t *testing.T) {
+	k := newTestKubernetes(nil, ConnectorOpts{Redact: &RedactOptions{Key: []byte("secret-key")}})
+
+	for _, tc := range resourceIDContractCases() {
+		t.Run(tc.name, func(t *testing.T) {
+			resource, err := tc.builder(k.opts)
+			require.NoError(t, err)
+			redacted, err := k.redactor.outboundResource(resource)
+			require.NoError(t, err)
+
+			id, err := k.DeletedResourceID(tc.obj)
+			require.NoError(t, err)
+			assert.Equal(t, redacted.Id.ResourceType, id.ResourceType)
+			assert.Equal(t, redacted.Id.Resource, id.Resource)
+			assert.NotContains(t, id.Resource, "payments")
+		})
+	}
+}
+
+// TestBuildResourceID_UnsupportedObject tests that objects the connector doesn't sync are rejected.
+func TestBuildResourceID_UnsupportedObject(t *testing.T) {
+	_, err := BuildResourceID(&rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "binding", Namespace: "default"}})
+	require.Error(t, err)
+}
+
+// TestSubjectResourceID_RoundTrip tests that users and groups whose names aren't valid in IDs, such as OIDC
+// subjects, get the same encoded IDs in their resources and in the grants to and on them, so that the graph
+// joins, and that the names are recovered from the IDs.
+func TestSubjectResourceID_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	const (
+		oidcUser  = "https://issuer.example.com#alice"
+		oidcGroup = "oidc:platform/admins"
+		utf8User  = "josé"
+	)
+	client := fake.NewSimpleClientset(
+		&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "reader"}},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "reader"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindRole, Name: "reader"},
+			Subjects: []rbacv1.Subject{
+				{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: oidcUser},
+				{Kind: SubjectKindGroup, APIGroup: RBACAPIGroup, Name: oidcGroup},
+				{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "alice@example.com"},
+			},
+		},
+		&rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{Name: "impersonator"},
+			Rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{""}, Resources: []string{"users"}, Verbs: []string{"impersonate"}, ResourceNames: []string{oidcUser}},
+				{APIGroups: []string{""}, Resources: []string{"groups"}, Verbs: []string{"impersonate"}, ResourceNames: []string{oidcGroup}},
+			},
+		},
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "impersonator"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindClusterRole, Name: "impersonator"},
+			Subjects:   []rbacv1.Subject{{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: utf8User}},
+		},
+	)
+	k := newTestKubernetes(client, ConnectorOpts{
+		SyncResources:  []string{ResourceTypeRole.Id, ResourceTypeClusterRole.Id, ResourceTypeKubeUser.Id, ResourceTypeKubeGroup.Id},
+		AllowEmptySync: true,
+	})
+
+	resources := make(map[string]*v2.Resource)
+	var grants []*v2.Grant
+	for _, syncer := range k.ResourceSyncers(ctx) {
+		listed, err := listAllResources(ctx, syncer, nil)
+		require.NoError(t, err)
+		for _, resource := range listed {
+			resources[resourceIDKey(resource.Id)] = resource
+			resourceGrants, err := listAllGrants(ctx, syncer, resource)
+			require.NoError(t, err)
+			grants = append(grants, resourceGrants...)
+		}
+	}
+
+	// Names are encoded into the IDs, and kept as is elsewhere
+	user := resources["kube_user:https:%2F%2Fissuer.example.com%23alice"]
+	require.NotNil(t, user)
+	assert.Equal(t, oidcUser, user.DisplayName)
+	group := resources["kube_group:oidc:platform%2Fadmins"]
+	require.NotNil(t, group)
+	assert.Equal(t, oidcGroup, group.DisplayName)
+	require.Contains(t, resources, "kube_user:jos%C3%A9")
+	require.Contains(t, resources, "kube_user:alice@example.com")
+
+	// Grants to the users and groups, and on them, join their resources
+	var principals, targets []string
+	for _, g := range grants {
+		if id := g.Principal.Id; isSubjectResourceType(id.ResourceType) {
+			assert.Contains(t, resources, resourceIDKey(id))
+			principals = append(principals, resourceIDKey(id))
+		}
+		if id := g.Entitlement.Resource.Id; isSubjectResourceType(id.ResourceType) {
+			assert.Contains(t, resources, resourceIDKey(id))
+			targets = append(targets, resourceIDKey(id))
+		}
+	}
+	assert.Subset(t, principals, []string{
+		"kube_user:https:%2F%2Fissuer.example.com%23alice",
+		"kube_group:oidc:platform%2Fadmins",
+		"kube_user:alice@example.com",
+	})
+	assert.ElementsMatch(t, []string{
+		"kube_user:https:%2F%2Fissuer.example.com%23alice",
+		"kube_group:oidc:platform%2Fadmins",
+	}, targets)
+
+	// Provisioning recovers the names from the IDs
+	subject, err := subjectForPrincipal(user.Id)
+	require.NoError(t, err)
+	assert.Equal(t, oidcUser, subject.Name)
+	subject, err = subjectForPrincipal(group.Id)
+	require.NoError(t, err)
+	assert.Equal(t, oidcGroup, subject.Name)
+	assert.Equal(t, SubjectKindGroup, subject.Kind)
+}
+
+// TestSubjectResourceID_Wildcard tests that a user named "*" doesn't take the reserved ID of the wildcard user,
+// which grants on every user target and which can't be provisioned.
+func TestSubjectResourceID_Wildcard(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(
+		&rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{Name: "impersonator"},
+			Rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{""}, Resources: []string{"users"}, Verbs: []string{"impersonate"}},
+			},
+		},
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "impersonator"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindClusterRole, Name: "impersonator"},
+			Subjects:   []rbacv1.Subject{{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "*"}},
+		},
+	)
+	k := newTestKubernetes(client, ConnectorOpts{
+		SyncResources:  []string{ResourceTypeClusterRole.Id, ResourceTypeKubeUser.Id},
+		AllowEmptySync: true,
+	})
+
+	resources := make(map[string]*v2.Resource)
+	var grants []*v2.Grant
+	for _, syncer := range k.ResourceSyncers(ctx) {
+		listed, err := listAllResources(ctx, syncer, nil)
+		require.NoError(t, err)
+		for _, resource := range listed {
+			resources[resourceIDKey(resource.Id)] = resource
+			resourceGrants, err := listAllGrants(ctx, syncer, resource)
+			require.NoError(t, err)
+			grants = append(grants, resourceGrants...)
+		}
+	}
+	require.Contains(t, resources, "kube_user:*")
+	require.Contains(t, resources, "kube_user:%2A")
+	assert.Equal(t, "*", resources["kube_user:%2A"].DisplayName)
+
+	var principals, targets []string
+	for _, g := range grants {
+		if id := g.Principal.Id; id.ResourceType == ResourceTypeKubeUser.Id {
+			principals = append(principals, resourceIDKey(id))
+		}
+		if id := g.Entitlement.Resource.Id; id.ResourceType == ResourceTypeKubeUser.Id {
+			targets = append(targets, resourceIDKey(id))
+		}
+	}
+	assert.ElementsMatch(t, []string{"kube_user:%2A"}, principals)
+	assert.ElementsMatch(t, []string{"kube_user:*"}, targets)
+
+	subject, err := subjectForPrincipal(resources["kube_user:%2A"].Id)
+	require.NoError(t, err)
+	assert.Equal(t, "*", subject.Name)
+	_, err = subjectForPrincipal(resources["kube_user:*"].Id)
+	assert.Error(t, err)
+}
@@ -0,0 +1,68 @@
+package connector
+
+import (
+	"context"
+	"time"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// DefaultListRetries is the number of times a List call failing with a transient error is retried by default.
+const DefaultListRetries = 3
+
+const (
+	// listRetryInitialDelay is the delay before the first retry of a List call, doubled for each later one.
+	listRetryInitialDelay = 500 * time.Millisecond
+	// listRetryMaxDelay caps the delay between retries, including delays suggested by the API server.
+	listRetryMaxDelay = 30 * time.Second
+)
+
+// isTransientListError reports whether a List call failed with an error a later attempt may not hit: the API
+// server or a proxy in front of it timing out, throttling, or failing internally.
+func isTransientListError(err error) bool {
+	return k8serrors.IsServerTimeout(err) ||
+		k8serrors.IsTimeout(err) ||
+		k8serrors.IsTooManyRequests(err) ||
+		k8serrors.IsInternalError(err)
+}
+
+// listWithRetry calls list with a context bounded by RequestTimeout, retrying it up to ListRetries times while
+// it fails with a transient error. Retries back off exponentially, waiting longer if the API server asks to
+// with Retry-After. It returns ctx's error as soon as ctx is done rather than issuing another call.
+func listWithRetry[T any](ctx context.Context, opts ConnectorOpts, list func(ctx context.Context) (T, error)) (T, error) {
+	var zero T
+	delay := listRetryInitialDelay
+	for attempt := 0; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return zero, err
+		}
+
+		listCtx, cancel := opts.requestContext(ctx)
+		rv, err := list(listCtx)
+		cancel()
+		if err == nil || attempt >= opts.ListRetries || !isTransientListError(err) {
+			return rv, err
+		}
+
+		wait := delay
+		if seconds, ok := k8serrors.SuggestsClientDelay(err); ok {
+			wait = max(wait, time.Duration(seconds)*time.Second)
+		}
+		wait = min(wait, listRetryMaxDelay)
+		ctxzap.Extract(ctx).Debug("retrying list after transient error",
+			zap.Int("attempt", attempt+1),
+			zap.Duration("delay", wait),
+			zap.Error(err))
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return zero, ctx.Err()
+		case <-timer.C:
+		}
+		delay = min(delay*2, listRetryMaxDelay)
+	}
+}
@@ -0,0 +1,115 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// failListing makes the lists of the resource fail with the errors, one per call, before reaching the fake
+// cluster. It returns a counter of the lists.
+func failListing(client *fake.Clientset, resource string, errs ...error) *int {
+	calls := 0
+	client.PrependReactor("list", resource, func(action k8stesting.Action) (bool, runtime.Object, error) {
+		calls++
+		if calls <= len(errs) {
+			return true, nil, errs[calls-1]
+		}
+		return false, nil, nil
+	})
+	return &calls
+}
+
+// TestLoadBindingsCaches_Retry tests that the bindings caches load when the lists fail transiently a few times.
+func TestLoadBindingsCaches_Retry(t *testing.T) {
+	client := fake.NewSimpleClientset(&rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "reader"},
+		RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindRole, Name: "reader"},
+		Subjects:   []rbacv1.Subject{{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "alice"}},
+	})
+	calls := failListing(client, "rolebindings",
+		k8serrors.NewInternalError(errors.New("etcd leader changed")),
+		k8serrors.NewServerTimeout(rbacv1.Resource("rolebindings"), "list", 0))
+	k := newKubernetes(client, nil, ConnectorOpts{ListRetries: 2})
+
+	require.NoError(t, k.loadBindingsCaches(context.Background()))
+	assert.Equal(t, 3, *calls)
+	require.Len(t, k.roleBindingsCache, 1)
+	assert.Equal(t, "reader", k.roleBindingsCache[0].Name)
+}
+
+// TestListWithRetry_GivesUp tests that lists are retried ListRetries times at most, and that errors that
+// aren't transient aren't retried.
+func TestListWithRetry_GivesUp(t *testing.T) {
+	ctx := context.Background()
+
+	client := fake.NewSimpleClientset()
+	calls := failListing(client, "pods",
+		k8serrors.NewInternalError(errors.New("boom")),
+		k8serrors.NewInternalError(errors.New("boom")))
+	_, _, _, err := newPodBuilder(client, ConnectorOpts{ListRetries: 1}).List(ctx, namespaceID("payments"), &pagination.Token{})
+	require.Error(t, err)
+	assert.True(t, k8serrors.IsInternalError(err))
+	assert.Equal(t, 2, *calls)
+
+	client = fake.NewSimpleClientset()
+	calls = failListing(client, "pods", k8serrors.NewForbidden(corev1.Resource("pods"), "", errors.New("denied")))
+	_, _, _, err = newPodBuilder(client, ConnectorOpts{ListRetries: 3}).List(ctx, namespaceID("payments"), &pagination.Token{})
+	require.Error(t, err)
+	assert.True(t, k8serrors.IsForbidden(err))
+	assert.Equal(t, 1, *calls)
+}
+
+// TestListWithRetry_RetryAfter tests that retries wait as long as the API server asks to.
+func TestListWithRetry_RetryAfter(t *testing.T) {
+	calls := 0
+	start := time.Now()
+	_, err := listWithRetry(context.Background(), ConnectorOpts{ListRetries: 1}, func(ctx context.Context) (int, error) {
+		calls++
+		if calls == 1 {
+			return 0, k8serrors.NewTooManyRequests("slow down", 1)
+		}
+		return 0, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+	assert.GreaterOrEqual(t, time.Since(start), time.Second)
+}
+
+// TestListWithRetry_Canceled tests that a canceled context stops the retries, whether it's canceled before a
+// call or during the backoff.
+func TestListWithRetry_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls := 0
+	_, err := listWithRetry(ctx, ConnectorOpts{ListRetries: 3}, func(ctx context.Context) (int, error) {
+		calls++
+		return 0, nil
+	})
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 0, calls)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	start := time.Now()
+	_, err = listWithRetry(ctx, ConnectorOpts{ListRetries: 3}, func(ctx context.Context) (int, error) {
+		calls++
+		cancel()
+		return 0, k8serrors.NewTooManyRequests("slow down", 30)
+	})
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, calls)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
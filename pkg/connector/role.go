@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	rbacv1 "k8s.io/api/rbac/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 
@@ -18,10 +19,43 @@ import (
 	"go.uber.org/zap"
 )
 
-// roleBuilder syncs Kubernetes Roles as Baton resources.
+// roleEscalationVerbs are the verbs on roles and cluster roles that let a subject grant permissions it doesn't
+// hold itself, modeled as permission entitlements on the roles.
+var roleEscalationVerbs = []string{"escalate", "bind"}
+
+// roleEscalationEntitlements returns the escalate and bind entitlements of a Role or ClusterRole resource.
+func roleEscalationEntitlements(resource *v2.Resource) []*v2.Entitlement {
+	descriptions := map[string]string{
+		"escalate": "Grants permission to give the %s role permissions the editor doesn't hold",
+		"bind":     "Grants permission to bind the %s role in bindings, without holding its permissions",
+	}
+
+	entitlements := make([]*v2.Entitlement, 0, len(roleEscalationVerbs))
+	for _, verb := range roleEscalationVerbs {
+		entitlements = append(entitlements, entitlement.NewPermissionEntitlement(
+			resource,
+			verb,
+			entitlement.WithDisplayName(fmt.Sprintf("%s %s", verb, resource.DisplayName)),
+			entitlement.WithDescription(fmt.Sprintf(descriptions[verb], resource.DisplayName)),
+			entitlement.WithGrantableTo(
+				ResourceTypeRole,
+				ResourceTypeClusterRole,
+			),
+		))
+	}
+	return entitlements
+}
+
+// roleBuilder syncs Kubernetes Roles as Baton resources. It's safe for concurrent use: the bindings come from
+// the connector's locked caches, and the stats and service account group expander lock their own state.
 type roleBuilder struct {
 	client          kubernetes.Interface
 	bindingProvider RoleBindingProvider
+	opts            ConnectorOpts
+	stats           *syncStats
+	saGroups        *serviceAccountGroupExpander
+	// grantorProvider lists who can grant each Role in its profile, when set
+	grantorProvider RoleGrantorProvider
 }
 
 // ResourceType returns the resource type for Role.
@@ -33,31 +67,43 @@ func (r *roleBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
 func (r *roleBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
 	l := ctxzap.Extract(ctx)
 
-	// Initialize empty resource slice
-	var rv []*v2.Resource
-
 	// Parse pagination token
 	bag, err := ParsePageToken(pToken.Token)
 	if err != nil {
 		return nil, "", nil, fmt.Errorf("failed to parse page token: %w", err)
 	}
 
+	// List the namespace the SDK lists the children of, or all namespaces, after the wildcards
+	namespace, rv, done, err := startNamespacedList(ctx, r.client, r.opts, ResourceTypeRole, parentResourceID, bag.PageToken(), nil)
+	if err != nil || done {
+		return rv, "", nil, err
+	}
+
 	// Set up list options with pagination
 	opts := metav1.ListOptions{
-		Limit:    ResourcesPageSize,
+		Limit:    r.opts.pageSize(ResourceTypeRole.Id),
 		Continue: bag.PageToken(),
 	}
 
-	// Fetch roles from the Kubernetes API across all namespaces
+	// Fetch roles from the Kubernetes API in the namespace, or across all namespaces
 	l.Debug("fetching roles", zap.String("continue_token", opts.Continue))
-	resp, err := r.client.RbacV1().Roles("").List(ctx, opts)
+	resp, err := listWithRetry(ctx, r.opts, func(ctx context.Context) (*rbacv1.RoleList, error) {
+		return r.client.RbacV1().Roles(namespace).List(ctx, opts)
+	})
 	if err != nil {
 		return nil, "", nil, fmt.Errorf("failed to list roles: %w", err)
 	}
 
 	// Process each role into a Baton resource
 	for _, role := range resp.Items {
-		resource, err := roleResource(&role)
+		var grantableBy []string
+		if r.grantorProvider != nil {
+			grantableBy, err = r.grantorProvider.GetRoleGrantors(ctx, role.Namespace, role.Name)
+			if err != nil {
+				return nil, "", nil, fmt.Errorf("failed to get the grantors of role %s/%s: %w", role.Namespace, role.Name, err)
+			}
+		}
+		resource, err := roleResource(&role, r.opts, grantableBy)
 		if err != nil {
 			l.Error("failed to create role resource",
 				zap.String("namespace", role.Namespace),
@@ -77,14 +123,17 @@ func (r *roleBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId,
 	return rv, nextPageToken, nil, nil
 }
 
-// roleResource creates a Baton resource from a Kubernetes Role.
-func roleResource(role *rbacv1.Role) (*v2.Resource, error) {
+// roleResource creates a Baton resource from a Kubernetes Role. The principals able to grant it, if not nil,
+// are listed in its profile.
+func roleResource(role *rbacv1.Role, opts ConnectorOpts, grantableBy []string) (*v2.Resource, error) {
 	// Prepare profile with standard metadata
 	profile := map[string]interface{}{
 		"name":              role.Name,
 		"namespace":         role.Namespace,
 		"uid":               string(role.UID),
 		"creationTimestamp": role.CreationTimestamp.String(),
+		"apiVersion":        RBACAPIGroupV1,
+		"resourceVersion":   role.ResourceVersion,
 	}
 
 	// Only add labels and annotations if they're not nil to avoid proto conversion issues
@@ -92,8 +141,14 @@ func roleResource(role *rbacv1.Role) (*v2.Resource, error) {
 		profile["labels"] = StringMapToAnyMap(role.Labels)
 	}
 	if role.Annotations != nil {
-		profile["annotations"] = StringMapToAnyMap(role.Annotations)
+		profile["annotations"] = profileAnnotations(role.Annotations, opts, false)
+	}
+	if err := addRulesProfile(profile, role.Rules, opts.profileRulesLimit()); err != nil {
+		return nil, err
 	}
+	addLabelTags(profile, role.Labels, opts)
+	addGrantableByProfile(profile, grantableBy)
+	repairProfile(profile)
 
 	// Get parent namespace resource ID
 	parentID, err := NamespaceResourceID(role.Namespace)
@@ -102,7 +157,13 @@ func roleResource(role *rbacv1.Role) (*v2.Resource, error) {
 	}
 
 	// Create the raw ID as namespace/name
-	rawID := role.Namespace + "/" + role.Name
+	rawID := namespacedName(role.Namespace, role.Name)
+
+	// Correlate the role across renames by its UID
+	resourceOpts := []rs.ResourceOption{rs.WithParentResourceID(parentID)}
+	if len(role.UID) > 0 {
+		resourceOpts = append(resourceOpts, rs.WithExternalID(&v2.ExternalId{Id: string(role.UID)}))
+	}
 
 	// Create resource as a role with parent namespace
 	resource, err := rs.NewRoleResource(
@@ -110,7 +171,7 @@ func roleResource(role *rbacv1.Role) (*v2.Resource, error) {
 		ResourceTypeRole,
 		rawID, // Pass the raw ID directly
 		[]rs.RoleTraitOption{rs.WithRoleProfile(profile)},
-		rs.WithParentResourceID(parentID),
+		resourceOpts...,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create role resource: %w", err)
@@ -119,8 +180,13 @@ func roleResource(role *rbacv1.Role) (*v2.Resource, error) {
 	return resource, nil
 }
 
-// Entitlements returns entitlements for Role resources.
+// Entitlements returns entitlements for Role resources. The wildcard role only has the escalate and bind
+// entitlements, as it can't be bound.
 func (r *roleBuilder) Entitlements(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	if isWildcardResourceID(resource.Id.Resource) {
+		return roleEscalationEntitlements(resource), "", nil, nil
+	}
+
 	var entitlements []*v2.Entitlement
 
 	// Create the 'member' entitlement for the role
@@ -129,13 +195,10 @@ func (r *roleBuilder) Entitlements(ctx context.Context, resource *v2.Resource, _
 		"member",
 		entitlement.WithDisplayName(fmt.Sprintf("%s Role Member", resource.DisplayName)),
 		entitlement.WithDescription(fmt.Sprintf("Grants membership to the %s role", resource.DisplayName)),
-		entitlement.WithGrantableTo(
-			ResourceTypeKubeUser,
-			ResourceTypeKubeGroup,
-			ResourceTypeServiceAccount,
-		),
+		entitlement.WithGrantableTo(memberGrantableTo(r.opts)...),
 	)
 	entitlements = append(entitlements, memberEnt)
+	entitlements = append(entitlements, roleEscalationEntitlements(resource)...)
 
 	return entitlements, "", nil, nil
 }
@@ -154,10 +217,15 @@ func parseRoleResourceID(resourceID *v2.ResourceId) (string, string, error) {
 	return parts[0], parts[1], nil
 }
 
-// Grants returns permission grants for Role resources.
-func (r *roleBuilder) Grants(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+// Grants returns membership grants from the bindings of a Role, a page of bindings at a time, then permission
+// grants from the Role to the resources covered by its rules.
+func (r *roleBuilder) Grants(ctx context.Context, resource *v2.Resource, pToken *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
 	l := ctxzap.Extract(ctx)
-	var rv []*v2.Grant
+
+	// The wildcard role has no bindings or rules
+	if isWildcardResourceID(resource.Id.Resource) {
+		return nil, "", nil, nil
+	}
 
 	// Parse the resource ID to get namespace and name
 	namespace, name, err := parseRoleResourceID(resource.Id)
@@ -165,38 +233,86 @@ func (r *roleBuilder) Grants(ctx context.Context, resource *v2.Resource, _ *pagi
 		return nil, "", nil, fmt.Errorf("failed to parse resource ID: %w", err)
 	}
 
+	start, err := parseRoleGrantsPage(pToken)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	// The role may have been deleted since it was listed
+	if start == (roleGrantsPosition{phase: roleGrantsPhaseBindings}) {
+		if _, ok, err := r.getRole(ctx, namespace, name); err != nil || !ok {
+			return nil, "", nil, err
+		}
+	}
+
 	// Get matching role bindings from the binding provider
 	matchingBindings, err := r.bindingProvider.GetMatchingRoleBindings(ctx, namespace, name)
 	if err != nil {
 		return nil, "", nil, fmt.Errorf("failed to get matching role bindings: %w", err)
 	}
-
-	// If there are no bindings, there are no grants
-	if len(matchingBindings) == 0 {
-		l.Debug("no role bindings found for role", zap.String("namespace", namespace), zap.String("name", name))
-		return nil, "", nil, nil
+	sortRoleBindings(matchingBindings)
+	bindings := make([]roleGrantBinding, 0, len(matchingBindings))
+	for _, binding := range matchingBindings {
+		bindings = append(bindings, roleGrantBinding{
+			kind:     BindingKindRoleBinding,
+			meta:     binding.ObjectMeta,
+			subjects: binding.Subjects,
+			entName:  "member",
+		})
 	}
 
-	// Process each matching binding
-	for _, binding := range matchingBindings {
-		// Process each subject in the binding
-		for _, subject := range binding.Subjects {
-			subjectGrant, err := GrantRoleToSubject(subject, resource, "member")
+	var rv []*v2.Grant
+	if start.phase == roleGrantsPhaseBindings {
+		if len(bindings) == 0 {
+			l.Debug("no role bindings found for role", zap.String("namespace", namespace), zap.String("name", name))
+		}
+		var next roleGrantsPosition
+		rv, next, err = bindingGrantsPage(ctx, resource, bindings, newBindingMemberships(bindings, r.opts), start, r.opts.grantsPageSize(), r.saGroups, r.opts)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		if next.phase == roleGrantsPhaseBindings || len(rv) >= r.opts.grantsPageSize() {
+			nextPageToken, err := roleGrantsPageToken(next)
 			if err != nil {
-				l.Debug("subject kind not supported", zap.String("subject kind", subject.Kind))
-				continue
+				return nil, "", nil, err
 			}
-			rv = append(rv, subjectGrant)
+			return rv, nextPageToken, nil, nil
 		}
 	}
 
-	return rv, "", nil, nil
+	// Expand the role's rules into grants on the resources they cover, once, on the page the bindings end on
+	role, ok, err := r.getRole(ctx, namespace, name)
+	if err != nil || !ok {
+		return rv, "", nil, err
+	}
+	ruleGrants, err := expandPolicyRules(ctx, r.client, resource, roleRuleScope(namespace), role.Rules, r.opts)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to expand role rules: %w", err)
+	}
+	return append(rv, ruleGrants...), "", nil, nil
+}
+
+// getRole fetches the live Role, reporting false if it was deleted since it was listed.
+func (r *roleBuilder) getRole(ctx context.Context, namespace, name string) (*rbacv1.Role, bool, error) {
+	role, err := r.client.RbacV1().Roles(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			ctxzap.Extract(ctx).Info("role no longer exists, skipping grants", zap.String("namespace", namespace), zap.String("name", name))
+			r.stats.Inc(StatGrantsObjectNotFound)
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to get role: %w", err)
+	}
+	return role, true, nil
 }
 
 // newRoleBuilder creates a new role builder.
-func newRoleBuilder(client kubernetes.Interface, bindingProvider RoleBindingProvider) *roleBuilder {
+func newRoleBuilder(client kubernetes.Interface, bindingProvider RoleBindingProvider, opts ConnectorOpts, stats *syncStats) *roleBuilder {
 	return &roleBuilder{
 		client:          client,
 		bindingProvider: bindingProvider,
+		opts:            opts,
+		stats:           stats,
+		saGroups:        newServiceAccountGroupExpander(client, opts),
 	}
 }
@@ -2,15 +2,23 @@ package connector
 
 import (
 	"context"
+	"fmt"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/conductorone/baton-kubernetes/pkg/kubetest"
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
 	"github.com/conductorone/baton-sdk/pkg/pagination"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/fake"
 )
 
@@ -38,36 +46,57 @@ func (m *mockRoleBindingProvider) addMockBinding(namespace, roleName string, bin
 	m.roleBindingsMap[key] = append(m.roleBindingsMap[key], binding)
 }
 
-// TestRoleBuilderList tests the List method.
+// TestRoleBuilderList tests that List pages through the roles of all namespaces, with the wildcard role only
+// on the first page.
 func TestRoleBuilderList(t *testing.T) {
-	// We'll focus on a simpler approach: directly testing the roleResource
-	// function conversion instead of the List method which has issues with the
-	// fake client
-
-	// Create a test role
-	role := &rbacv1.Role{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "test-role",
-			Namespace: "test-ns",
-			UID:       "test-uid",
-		},
-		Rules: []rbacv1.PolicyRule{
-			{
-				Verbs:     []string{"get"},
-				APIGroups: []string{""},
-				Resources: []string{"pods"},
+	ctx := context.Background()
+	var objects []runtime.Object
+	for i := 0; i < 5; i++ {
+		objects = append(objects, &rbacv1.Role{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("role-%d", i),
+				Namespace: fmt.Sprintf("ns-%d", i%2),
+				UID:       types.UID(fmt.Sprintf("uid-%d", i)),
 			},
-		},
+			Rules: []rbacv1.PolicyRule{
+				{
+					Verbs:     []string{"get"},
+					APIGroups: []string{""},
+					Resources: []string{"pods"},
+				},
+			},
+		})
 	}
+	client := fake.NewSimpleClientset(objects...)
+	paginator := kubetest.Paginate(client, []string{"roles"}, kubetest.WithPageSize(2))
 
-	// Call roleResource directly
-	resource, err := roleResource(role)
+	// Without namespaces synced, the roles of every namespace are listed at the top level
+	builder := newRoleBuilder(client, newMockRoleBindingProvider(), ConnectorOpts{SyncResources: []string{ResourceTypeRole.Id}}, nil)
+	var ids []string
+	token := &pagination.Token{}
+	for page := 0; ; page++ {
+		resources, next, _, err := builder.List(ctx, nil, token)
+		require.NoError(t, err)
+		for i, resource := range resources {
+			assert.Equal(t, ResourceTypeRole.Id, resource.Id.ResourceType)
+			if resource.Id.Resource == "*" {
+				assert.Zero(t, page, "wildcard listed on page %d", page)
+				assert.Zero(t, i)
+			} else {
+				// Roles are correlated across renames by their UID
+				name := resource.Id.Resource[strings.Index(resource.Id.Resource, "/")+1:]
+				assert.Equal(t, "uid-"+strings.TrimPrefix(name, "role-"), resource.ExternalId.GetId())
+			}
+			ids = append(ids, resource.Id.Resource)
+		}
+		if next == "" {
+			break
+		}
+		token = &pagination.Token{Token: next}
+	}
 
-	// Assertions
-	require.NoError(t, err)
-	assert.Equal(t, "test-role", resource.DisplayName)
-	assert.Equal(t, "test-ns/test-role", resource.Id.Resource)
-	assert.Equal(t, ResourceTypeRole.Id, resource.Id.ResourceType)
+	assert.Equal(t, []string{"*", "ns-0/role-0", "ns-0/role-2", "ns-0/role-4", "ns-1/role-1", "ns-1/role-3"}, ids)
+	assert.Equal(t, 3, paginator.Pages("roles"))
 }
 
 // TestRoleBuilderGrants_NoBindings tests that a role without bindings produces no grants.
@@ -112,7 +141,10 @@ func TestRoleBuilderGrants_NoBindings(t *testing.T) {
 
 	// Assertions
 	require.NoError(t, err)
-	assert.Empty(t, grants, "A role without bindings should produce no grants")
+	// Without bindings the only grants are the ones expanded from the role's rules
+	require.Len(t, grants, 1, "A role without bindings should produce no membership grants")
+	assert.Equal(t, ResourceTypeRole.Id, grants[0].Principal.Id.ResourceType)
+	assert.Equal(t, "pod:*:get", grants[0].Entitlement.Id)
 }
 
 // TestRoleBuilderGrants_WithBindings tests grants with role bindings.
@@ -221,3 +253,406 @@ func TestRoleBuilderGrants_WithBindings(t *testing.T) {
 	assert.Equal(t, 1, userGrants, "Should have 1 grants for user alice")
 	assert.Equal(t, 1, saGrants, "Should have 3 grants for service account system")
 }
+
+// TestRoleBuilderGrants_RoleDeleted tests that a role deleted between List and Grants yields no grants and no error.
+func TestRoleBuilderGrants_RoleDeleted(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(&rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "short-lived",
+			Namespace: "test-ns",
+		},
+	})
+
+	stats := newSyncStats()
+	builder := newRoleBuilder(client, newMockRoleBindingProvider(), ConnectorOpts{}, stats)
+
+	resources := listResources(ctx, t, builder, "test-ns")
+	require.Len(t, resources, 2) // wildcard and short-lived
+
+	err := client.RbacV1().Roles("test-ns").Delete(ctx, "short-lived", metav1.DeleteOptions{})
+	require.NoError(t, err)
+
+	grants, nextToken, _, err := builder.Grants(ctx, resources[1], &pagination.Token{})
+	require.NoError(t, err)
+	assert.Empty(t, grants)
+	assert.Empty(t, nextToken)
+	assert.Equal(t, int64(1), stats.Get(StatGrantsObjectNotFound))
+}
+
+// TestGrantRoleToSubject_GroupExpansion tests that grants to groups are expandable through the group's
+// member entitlement, while grants to users and service accounts are not.
+func TestGrantRoleToSubject_GroupExpansion(t *testing.T) {
+	resource := GenerateResourceForGrant("test-ns/test-role", ResourceTypeRole.Id)
+
+	g, err := GrantRoleToSubject(rbacv1.Subject{Kind: SubjectKindGroup, APIGroup: RBACAPIGroup, Name: "developers"}, resource, "member",
+		bindingGrantOption(BindingKindRoleBinding, metav1.ObjectMeta{Name: "dev-binding", Namespace: "test-ns"}))
+	require.NoError(t, err)
+
+	expandable := &v2.GrantExpandable{}
+	annos := annotations.Annotations(g.Annotations)
+	ok, err := annos.Pick(expandable)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, []string{"kube_group:developers:member"}, expandable.EntitlementIds)
+
+	// The binding metadata is kept alongside the expansion
+	_, ok, err = bindingRefFromGrant(g)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	for _, subject := range []rbacv1.Subject{
+		{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "alice"},
+		{Kind: SubjectKindServiceAccount, Name: "default", Namespace: "test-ns"},
+	} {
+		g, err := GrantRoleToSubject(subject, resource, "member")
+		require.NoError(t, err)
+		annos := annotations.Annotations(g.Annotations)
+		assert.False(t, annos.Contains(expandable), subject.Kind)
+	}
+}
+
+// TestGrantRoleToSubject_EmptyAPIGroup tests that user and group subjects without an API group, which the API
+// server honors, are granted like those naming the RBAC API group, while other API groups are still rejected.
+func TestGrantRoleToSubject_EmptyAPIGroup(t *testing.T) {
+	resource := GenerateResourceForGrant("test-ns/test-role", ResourceTypeRole.Id)
+
+	g, err := GrantRoleToSubject(rbacv1.Subject{Kind: SubjectKindUser, Name: "alice"}, resource, "member")
+	require.NoError(t, err)
+	assert.Equal(t, ResourceTypeKubeUser.Id, g.Principal.Id.ResourceType)
+	assert.Equal(t, "alice", g.Principal.Id.Resource)
+
+	g, err = GrantRoleToSubject(rbacv1.Subject{Kind: SubjectKindGroup, Name: "developers"}, resource, "member")
+	require.NoError(t, err)
+	assert.Equal(t, ResourceTypeKubeGroup.Id, g.Principal.Id.ResourceType)
+	assert.Equal(t, "developers", g.Principal.Id.Resource)
+
+	_, err = GrantRoleToSubject(rbacv1.Subject{Kind: SubjectKindUser, APIGroup: "example.com", Name: "alice"}, resource, "member")
+	require.Error(t, err)
+}
+
+// TestGrantRoleToSubject_SystemSubjects tests that only users and groups whose names start with "system:" are
+// skipped as system subjects, unless they're included, and that service accounts never are.
+func TestGrantRoleToSubject_SystemSubjects(t *testing.T) {
+	resource := GenerateResourceForGrant("test-ns/test-role", ResourceTypeRole.Id)
+
+	tests := []struct {
+		name          string
+		subject       rbacv1.Subject
+		includeSystem bool
+		wantPrincipal string
+		wantSkipped   bool
+	}{
+		{
+			name:        "system user",
+			subject:     rbacv1.Subject{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "system:kube-scheduler"},
+			wantSkipped: true,
+		},
+		{
+			name:          "user containing system:",
+			subject:       rbacv1.Subject{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "ops-system:alice"},
+			wantPrincipal: "kube_user:ops-system:alice",
+		},
+		{
+			name:          "included system user",
+			subject:       rbacv1.Subject{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "system:kube-scheduler"},
+			includeSystem: true,
+			wantPrincipal: "kube_user:system:kube-scheduler",
+		},
+		{
+			name:        "system group",
+			subject:     rbacv1.Subject{Kind: SubjectKindGroup, APIGroup: RBACAPIGroup, Name: "system:masters"},
+			wantSkipped: true,
+		},
+		{
+			name:          "group containing system:",
+			subject:       rbacv1.Subject{Kind: SubjectKindGroup, APIGroup: RBACAPIGroup, Name: "platform-system:admins"},
+			wantPrincipal: "kube_group:platform-system:admins",
+		},
+		{
+			name:          "included system group",
+			subject:       rbacv1.Subject{Kind: SubjectKindGroup, APIGroup: RBACAPIGroup, Name: "system:masters"},
+			includeSystem: true,
+			wantPrincipal: "kube_group:system:masters",
+		},
+		{
+			name:          "service account in a system namespace",
+			subject:       rbacv1.Subject{Kind: SubjectKindServiceAccount, Namespace: "kube-system", Name: "system-controller"},
+			wantPrincipal: "service_account:kube-system/system-controller",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := grantRoleToSubject(tt.subject, resource, "member", ConnectorOpts{IncludeSystemSubjects: tt.includeSystem})
+			if tt.wantSkipped {
+				require.ErrorIs(t, err, ErrSystemSubjectSkipped)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantPrincipal, g.Principal.Id.ResourceType+":"+g.Principal.Id.Resource)
+		})
+	}
+
+	// Unsupported subjects aren't reported as skipped system subjects
+	_, err := GrantRoleToSubject(rbacv1.Subject{Kind: SubjectKindUser, APIGroup: "example.com", Name: "system:alice"}, resource, "member")
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrSystemSubjectSkipped)
+}
+
+// TestRoleBuilderGrants_SourceBinding tests that the binding a membership grant was derived from can be
+// recovered from the grant metadata.
+func TestRoleBuilderGrants_SourceBinding(t *testing.T) {
+	created := metav1.NewTime(time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC))
+	role := &rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: "secret-reader", Namespace: "test-ns"}}
+	provider := newMockRoleBindingProvider()
+	provider.addMockBinding("test-ns", "secret-reader", rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "sa-secret-binding",
+			Namespace:         "test-ns",
+			UID:               "binding-uid",
+			ResourceVersion:   "42",
+			Generation:        3,
+			CreationTimestamp: created,
+		},
+		RoleRef:  rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindRole, Name: "secret-reader"},
+		Subjects: []rbacv1.Subject{{Kind: SubjectKindServiceAccount, Name: "reader", Namespace: "test-ns"}},
+	})
+
+	builder := newRoleBuilder(fake.NewSimpleClientset(role), provider, ConnectorOpts{}, nil)
+	resource := GenerateResourceForGrant("test-ns/secret-reader", ResourceTypeRole.Id)
+	grants, _, _, err := builder.Grants(context.Background(), resource, &pagination.Token{})
+	require.NoError(t, err)
+	require.Len(t, grants, 1)
+
+	metadata := &v2.GrantMetadata{}
+	annos := annotations.Annotations(grants[0].Annotations)
+	ok, err := annos.Pick(metadata)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, map[string]any{
+		GrantMetadataBindingKind:            BindingKindRoleBinding,
+		GrantMetadataBindingAPIVersion:      RBACAPIGroupV1,
+		GrantMetadataBindingName:            "sa-secret-binding",
+		GrantMetadataBindingNamespace:       "test-ns",
+		GrantMetadataBindingResourceVersion: "42",
+		GrantMetadataBindingGeneration:      float64(3),
+		GrantMetadataBindingUID:             "binding-uid",
+		GrantMetadataBindingCreated:         "2024-05-01T12:30:00Z",
+		GrantMetadataSubjectKind:            SubjectKindServiceAccount,
+	}, metadata.Metadata.AsMap())
+
+	ref, ok, err := bindingRefFromGrant(grants[0])
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "42", ref.resourceVersion)
+	assert.Equal(t, int64(3), ref.generation)
+}
+
+// TestRoleBuilderGrants_DuplicateBindings tests that a subject bound to a role by two bindings, as left behind
+// by Helm upgrades renaming a binding, is granted the role once with both bindings recorded.
+func TestRoleBuilderGrants_DuplicateBindings(t *testing.T) {
+	role := &rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: "deployer", Namespace: "ci"}}
+	roleRef := rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindRole, Name: "deployer"}
+	builderSA := rbacv1.Subject{Kind: SubjectKindServiceAccount, Name: "builder", Namespace: "ci"}
+	alice := rbacv1.Subject{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "alice"}
+	provider := newMockRoleBindingProvider()
+	provider.addMockBinding("ci", "deployer", rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "deployer-v1", Namespace: "ci", ResourceVersion: "1"},
+		RoleRef:    roleRef,
+		Subjects:   []rbacv1.Subject{builderSA, alice},
+	})
+	provider.addMockBinding("ci", "deployer", rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "deployer-v2", Namespace: "ci", ResourceVersion: "2", Generation: 2},
+		RoleRef:    roleRef,
+		// Listing a subject twice in a binding doesn't record the binding twice
+		Subjects: []rbacv1.Subject{builderSA, builderSA},
+	})
+
+	builder := newRoleBuilder(fake.NewSimpleClientset(role), provider, ConnectorOpts{}, nil)
+	resource := GenerateResourceForGrant("ci/deployer", ResourceTypeRole.Id)
+	grants, _, _, err := builder.Grants(context.Background(), resource, &pagination.Token{})
+	require.NoError(t, err)
+
+	byPrincipal := make(map[string]*v2.Grant)
+	for _, g := range grants {
+		key := g.Principal.Id.ResourceType + ":" + g.Principal.Id.Resource
+		require.NotContains(t, byPrincipal, key, "duplicate grant to %s", key)
+		byPrincipal[key] = g
+	}
+	require.Len(t, byPrincipal, 2)
+
+	ref, ok, err := bindingRefFromGrant(byPrincipal["service_account:ci/builder"])
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "deployer-v1", ref.name)
+	additional, err := additionalBindingRefs(byPrincipal["service_account:ci/builder"])
+	require.NoError(t, err)
+	assert.Equal(t, []bindingRef{{kind: BindingKindRoleBinding, name: "deployer-v2", namespace: "ci", resourceVersion: "2", generation: 2}}, additional)
+
+	additional, err = additionalBindingRefs(byPrincipal["kube_user:alice"])
+	require.NoError(t, err)
+	assert.Empty(t, additional)
+}
+
+// TestRoleBuilderGrants_Pagination tests that role grants are returned in pages of the configured size.
+func TestRoleBuilderGrants_Pagination(t *testing.T) {
+	ctx := context.Background()
+	role := &rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: "deployer", Namespace: "test-ns"}}
+	provider := newMockRoleBindingProvider()
+	for _, name := range []string{"carol", "alice", "bob"} {
+		provider.addMockBinding("test-ns", "deployer", rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: name + "-binding", Namespace: "test-ns"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindRole, Name: "deployer"},
+			Subjects:   []rbacv1.Subject{{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: name}},
+		})
+	}
+	builder := newRoleBuilder(fake.NewSimpleClientset(role), provider, ConnectorOpts{GrantsPageSize: 2}, nil)
+	resource, err := roleResource(role, ConnectorOpts{}, nil)
+	require.NoError(t, err)
+
+	page, nextPageToken, _, err := builder.Grants(ctx, resource, &pagination.Token{})
+	require.NoError(t, err)
+	require.NotEmpty(t, nextPageToken)
+	require.Len(t, page, 2)
+	assert.Equal(t, "alice", page[0].Principal.Id.Resource)
+	assert.Equal(t, "bob", page[1].Principal.Id.Resource)
+
+	page, nextPageToken, _, err = builder.Grants(ctx, resource, &pagination.Token{Token: nextPageToken})
+	require.NoError(t, err)
+	assert.Empty(t, nextPageToken)
+	require.Len(t, page, 1)
+	assert.Equal(t, "carol", page[0].Principal.Id.Resource)
+}
+
+// TestRoleBuilderGrants_PaginationExpandsRulesOnce tests that the pages of role grants don't repeat the work of
+// the other pages: the role is fetched on the first page and to expand its rules, which are expanded once, after
+// the bindings, looking up the named secret once.
+func TestRoleBuilderGrants_PaginationExpandsRulesOnce(t *testing.T) {
+	ctx := context.Background()
+	role := &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{Name: "reader", Namespace: "test-ns"},
+		Rules: []rbacv1.PolicyRule{
+			{APIGroups: []string{""}, Resources: []string{"secrets"}, ResourceNames: []string{"token"}, Verbs: []string{"get"}},
+		},
+	}
+	client := fake.NewSimpleClientset(role, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "token", Namespace: "test-ns"}})
+	provider := newMockRoleBindingProvider()
+	for _, name := range []string{"alice", "bob", "carol"} {
+		provider.addMockBinding("test-ns", "reader", rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: name + "-binding", Namespace: "test-ns"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindRole, Name: "reader"},
+			Subjects:   []rbacv1.Subject{{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: name}},
+		})
+	}
+	builder := newRoleBuilder(client, provider, ConnectorOpts{GrantsPageSize: 1, SkipMissingNamedResources: true}, nil)
+	resource, err := roleResource(role, ConnectorOpts{}, nil)
+	require.NoError(t, err)
+	client.ClearActions()
+
+	var pages [][]string
+	token := &pagination.Token{}
+	for {
+		page, nextPageToken, _, err := builder.Grants(ctx, resource, token)
+		require.NoError(t, err)
+		require.Less(t, len(pages), 10, "pagination doesn't terminate")
+		var ids []string
+		for _, g := range page {
+			ids = append(ids, g.Entitlement.Id+"/"+g.Principal.Id.Resource)
+		}
+		pages = append(pages, ids)
+		if nextPageToken == "" {
+			break
+		}
+		token = &pagination.Token{Token: nextPageToken}
+	}
+
+	assert.Equal(t, [][]string{
+		{"role:test-ns/reader:member/alice"},
+		{"role:test-ns/reader:member/bob"},
+		{"role:test-ns/reader:member/carol"},
+		{"secret:test-ns/token:get/test-ns/reader"},
+	}, pages)
+
+	calls := make(map[string]int)
+	for _, action := range client.Actions() {
+		calls[action.GetVerb()+" "+action.GetResource().Resource]++
+	}
+	assert.Equal(t, map[string]int{"get roles": 2, "get secrets": 1}, calls)
+}
+
+// TestRoleBuilderGrants_PaginationExpandsGroups tests that the grants a binding to a service account group is
+// expanded to are split across pages of the configured size, ahead of the bindings after it.
+func TestRoleBuilderGrants_PaginationExpandsGroups(t *testing.T) {
+	ctx := context.Background()
+	role := &rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: "reader", Namespace: "test-ns"}}
+	objects := []runtime.Object{role}
+	for i := 0; i < 5; i++ {
+		objects = append(objects, &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("sa-%d", i), Namespace: "test-ns"}})
+	}
+	provider := newMockRoleBindingProvider()
+	provider.addMockBinding("test-ns", "reader", rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "a-alice", Namespace: "test-ns"},
+		RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindRole, Name: "reader"},
+		Subjects:   []rbacv1.Subject{{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "alice"}},
+	})
+	provider.addMockBinding("test-ns", "reader", rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "b-service-accounts", Namespace: "test-ns"},
+		RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindRole, Name: "reader"},
+		Subjects:   []rbacv1.Subject{{Kind: SubjectKindGroup, APIGroup: RBACAPIGroup, Name: ServiceAccountsGroup}},
+	})
+	provider.addMockBinding("test-ns", "reader", rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "c-bob", Namespace: "test-ns"},
+		RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindRole, Name: "reader"},
+		Subjects:   []rbacv1.Subject{{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "bob"}},
+	})
+	opts := ConnectorOpts{GrantsPageSize: 2, ExpandServiceAccountGroups: true}
+	builder := newRoleBuilder(fake.NewSimpleClientset(objects...), provider, opts, nil)
+	resource, err := roleResource(role, ConnectorOpts{}, nil)
+	require.NoError(t, err)
+
+	var pages [][]string
+	token := &pagination.Token{}
+	for {
+		page, nextPageToken, _, err := builder.Grants(ctx, resource, token)
+		require.NoError(t, err)
+		require.Less(t, len(pages), 10, "pagination doesn't terminate")
+		var principals []string
+		for _, g := range page {
+			principals = append(principals, g.Principal.Id.Resource)
+		}
+		pages = append(pages, principals)
+		if nextPageToken == "" {
+			break
+		}
+		token = &pagination.Token{Token: nextPageToken}
+	}
+
+	assert.Equal(t, [][]string{
+		{"alice", "test-ns/sa-0"},
+		{"test-ns/sa-1", "test-ns/sa-2"},
+		{"test-ns/sa-3", "test-ns/sa-4"},
+		{"bob"},
+	}, pages)
+}
+
+// TestRoleResource_Rules tests that the profile of a Role lists its rules.
+func TestRoleResource_Rules(t *testing.T) {
+	role := &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{Name: "deployer", Namespace: "test-ns"},
+		Rules: []rbacv1.PolicyRule{
+			{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"get", "update"}},
+			{APIGroups: []string{""}, Resources: []string{"secrets"}, ResourceNames: []string{"registry"}, Verbs: []string{"get"}},
+		},
+	}
+	resource, err := roleResource(role, ConnectorOpts{}, nil)
+	require.NoError(t, err)
+	roleTrait, err := rs.GetRoleTrait(resource)
+	require.NoError(t, err)
+	profile := roleTrait.Profile.AsMap()
+
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"apiGroups": []interface{}{"apps"}, "resources": []interface{}{"deployments"}, "verbs": []interface{}{"get", "update"}},
+		map[string]interface{}{"apiGroups": []interface{}{""}, "resources": []interface{}{"secrets"}, "resourceNames": []interface{}{"registry"}, "verbs": []interface{}{"get"}},
+	}, profile[ProfileRules])
+	assert.Equal(t, false, profile[ProfileRulesTruncated])
+}
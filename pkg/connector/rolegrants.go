@@ -0,0 +1,246 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Phases of the grants of a Role or ClusterRole, stored as the resource type ID of the page state: the
+// membership grants of the bindings, a page of grants at a time, then the grants expanded from the rules.
+const (
+	roleGrantsPhaseBindings = "bindings"
+	roleGrantsPhaseRules    = "rules"
+)
+
+// roleGrantBinding is a binding of a Role or ClusterRole, with the entitlement it grants its subjects.
+type roleGrantBinding struct {
+	kind     string
+	meta     metav1.ObjectMeta
+	subjects []rbacv1.Subject
+	entName  string
+}
+
+// roleGrantsPosition is where a page of role grants starts: its phase and, in the bindings phase, the index of a
+// binding and the number of its grants returned by the earlier pages. A binding granting more than a page, such
+// as one to a service account group expanded to its members, is split across pages.
+type roleGrantsPosition struct {
+	phase   string
+	binding int
+	offset  int
+}
+
+// parseRoleGrantsPage returns the position of the page of role grants a token points at. The first page starts
+// the bindings phase.
+func parseRoleGrantsPage(pToken *pagination.Token) (roleGrantsPosition, error) {
+	if pToken == nil || pToken.Token == "" {
+		return roleGrantsPosition{phase: roleGrantsPhaseBindings}, nil
+	}
+	bag, err := ParsePageToken(pToken.Token)
+	if err != nil {
+		return roleGrantsPosition{}, err
+	}
+	state := bag.Current()
+	if state == nil {
+		return roleGrantsPosition{phase: roleGrantsPhaseBindings}, nil
+	}
+	switch state.ResourceTypeID {
+	case roleGrantsPhaseBindings:
+		binding, offset, _ := strings.Cut(state.Token, ":")
+		index, err := strconv.Atoi(binding)
+		if err != nil || index < 0 {
+			return roleGrantsPosition{}, fmt.Errorf("invalid page token: invalid binding index %q", state.Token)
+		}
+		rv := roleGrantsPosition{phase: roleGrantsPhaseBindings, binding: index}
+		if offset != "" {
+			rv.offset, err = strconv.Atoi(offset)
+			if err != nil || rv.offset < 0 {
+				return roleGrantsPosition{}, fmt.Errorf("invalid page token: invalid binding grant offset %q", state.Token)
+			}
+		}
+		return rv, nil
+	case roleGrantsPhaseRules:
+		return roleGrantsPosition{phase: roleGrantsPhaseRules}, nil
+	default:
+		return roleGrantsPosition{}, fmt.Errorf("invalid page token: unknown role grants phase %q", state.ResourceTypeID)
+	}
+}
+
+// roleGrantsPageToken returns the token of the page of role grants starting at a position.
+func roleGrantsPageToken(position roleGrantsPosition) (string, error) {
+	bag := &pagination.Bag{}
+	bag.Push(pagination.PageState{
+		ResourceTypeID: position.phase,
+		Token:          strconv.Itoa(position.binding) + ":" + strconv.Itoa(position.offset),
+	})
+	token, err := bag.Marshal()
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal pagination bag: %w", err)
+	}
+	return token, nil
+}
+
+// membershipKey identifies the membership grant of an entitlement to a binding subject, the way the grant ID does,
+// from the subject alone.
+func membershipKey(entName string, subject rbacv1.Subject) string {
+	subject = normalizeSubject(subject)
+	namespace := ""
+	if subject.Kind == SubjectKindServiceAccount {
+		namespace = subject.Namespace
+	}
+	return entName + "\x00" + subject.Kind + "\x00" + namespace + "\x00" + subject.Name
+}
+
+// bindingMemberships indexes the subjects of the bindings of a role the connector grants the role to by
+// membershipKey, listing the indexes of the bindings granting each in order. It's built from the subjects without building any grant, so that each page
+// of grants can be deduplicated against the bindings of the other pages.
+type bindingMemberships map[string][]int
+
+// newBindingMemberships indexes the subjects of the bindings of a role, leaving out those grantRoleToSubject
+// grants nothing.
+func newBindingMemberships(bindings []roleGrantBinding, opts ConnectorOpts) bindingMemberships {
+	rv := make(bindingMemberships)
+	for i, binding := range bindings {
+		for _, subject := range binding.subjects {
+			if checkGrantableSubject(subject, opts) != nil {
+				continue
+			}
+			key := membershipKey(binding.entName, subject)
+			if indexes := rv[key]; len(indexes) == 0 || indexes[len(indexes)-1] != i {
+				rv[key] = append(indexes, i)
+			}
+		}
+	}
+	return rv
+}
+
+// grantedDirectly reports whether a binding grants the entitlement to the subject itself.
+func (m bindingMemberships) grantedDirectly(entName string, subject rbacv1.Subject) bool {
+	return len(m[membershipKey(entName, subject)]) > 0
+}
+
+// grantedToGroupBefore reports whether a binding before the index grants the entitlement to a service account
+// group the service accounts of the namespace are members of.
+func (m bindingMemberships) grantedToGroupBefore(entName, namespace string, index int) bool {
+	for _, group := range []string{ServiceAccountsGroup, serviceAccountsGroupPrefix + namespace} {
+		indexes := m[membershipKey(entName, rbacv1.Subject{Kind: SubjectKindGroup, Name: group})]
+		if len(indexes) > 0 && indexes[0] < index {
+			return true
+		}
+	}
+	return false
+}
+
+// bindingGrantsPage returns the membership grants of the bindings of a role from the start position, at most
+// pageSize of them, and the position of the next page, the rules phase once all bindings are done. A page ends
+// before a binding whose grants would take it over pageSize, unless they don't fit in a page of their own, in
+// which case they fill this page and the next ones.
+func bindingGrantsPage(
+	ctx context.Context,
+	resource *v2.Resource,
+	bindings []roleGrantBinding,
+	memberships bindingMemberships,
+	start roleGrantsPosition,
+	pageSize int,
+	saGroups *serviceAccountGroupExpander,
+	opts ConnectorOpts,
+) ([]*v2.Grant, roleGrantsPosition, error) {
+	var rv []*v2.Grant
+	offset := start.offset
+	for i := start.binding; i < len(bindings); i++ {
+		grants, err := bindingGrants(ctx, resource, bindings, memberships, i, saGroups, opts)
+		if err != nil {
+			return nil, roleGrantsPosition{}, err
+		}
+		grants = grants[min(offset, len(grants)):]
+
+		next := roleGrantsPosition{phase: roleGrantsPhaseBindings, binding: i, offset: offset}
+		if len(rv) > 0 && len(rv)+len(grants) > pageSize && len(grants) <= pageSize {
+			return rv, next, nil
+		}
+		if room := pageSize - len(rv); len(grants) > room {
+			next.offset += room
+			return append(rv, grants[:room]...), next, nil
+		}
+		rv = append(rv, grants...)
+		offset = 0
+	}
+	return rv, roleGrantsPosition{phase: roleGrantsPhaseRules}, nil
+}
+
+// bindingGrants returns the membership grants of the binding at the index, in the same order on every page. A
+// subject bound by several bindings is granted by the first of them, recording the others in the grant. Service
+// accounts inherit the grants to their groups, unless they're bound directly or their groups were bound by an
+// earlier binding.
+func bindingGrants(
+	ctx context.Context,
+	resource *v2.Resource,
+	bindings []roleGrantBinding,
+	memberships bindingMemberships,
+	i int,
+	saGroups *serviceAccountGroupExpander,
+	opts ConnectorOpts,
+) ([]*v2.Grant, error) {
+	l := ctxzap.Extract(ctx)
+
+	binding := bindings[i]
+	var rv []*v2.Grant
+	for _, subject := range binding.subjects {
+		saGrants, err := saGroups.expand(ctx, subject, resource, binding.entName, binding.kind, binding.meta)
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand service account group: %w", err)
+		}
+		for _, g := range saGrants {
+			sa, err := serviceAccountSubject(g.Principal.Id.Resource)
+			if err != nil {
+				return nil, err
+			}
+			if memberships.grantedDirectly(binding.entName, sa) || memberships.grantedToGroupBefore(binding.entName, sa.Namespace, i) {
+				continue
+			}
+			rv = append(rv, g)
+		}
+
+		// Subjects granted by an earlier binding were granted with it
+		indexes := memberships[membershipKey(binding.entName, subject)]
+		if len(indexes) > 0 && indexes[0] != i {
+			continue
+		}
+		subjectGrant, err := grantRoleToSubject(subject, resource, binding.entName, opts,
+			bindingGrantOption(binding.kind, binding.meta))
+		if err != nil {
+			logSkippedSubject(l, subject, err)
+			continue
+		}
+
+		// The later bindings granting the subject again, on this page or the next ones, are recorded in the grant
+		var duplicates []*v2.Grant
+		for _, j := range indexes[1:] {
+			duplicate, err := grantRoleToSubject(subject, resource, binding.entName, opts,
+				bindingGrantOption(bindings[j].kind, bindings[j].meta))
+			if err != nil {
+				return nil, err
+			}
+			duplicates = append(duplicates, duplicate)
+		}
+		mergeBindingRefs(subjectGrant, duplicates)
+		rv = append(rv, subjectGrant)
+	}
+	return uniqueGrants(rv), nil
+}
+
+// serviceAccountSubject returns the binding subject of a service account from its "namespace/name" resource ID.
+func serviceAccountSubject(id string) (rbacv1.Subject, error) {
+	namespace, name, ok := strings.Cut(id, "/")
+	if !ok {
+		return rbacv1.Subject{}, fmt.Errorf("invalid service account ID: %s", id)
+	}
+	return rbacv1.Subject{Kind: SubjectKindServiceAccount, Namespace: namespace, Name: name}, nil
+}
@@ -0,0 +1,86 @@
+package connector
+
+import (
+	"context"
+	"strings"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// isRoleRefAPIGroup reports whether the apiGroup of a RoleRef is the RBAC one the API server resolves roles in.
+// Some tools leave it empty.
+func isRoleRefAPIGroup(apiGroup string) bool {
+	return apiGroup == "" || apiGroup == RBACAPIGroup
+}
+
+// normalizeRoleRefKind returns the canonical kind, Role or ClusterRole, of a RoleRef kind in any casing, and
+// reports whether it is one of them.
+func normalizeRoleRefKind(kind string) (string, bool) {
+	switch {
+	case strings.EqualFold(kind, RoleRefKindRole):
+		return RoleRefKindRole, true
+	case strings.EqualFold(kind, RoleRefKindClusterRole):
+		return RoleRefKindClusterRole, true
+	default:
+		return "", false
+	}
+}
+
+// roleRefMatches reports whether a RoleRef points at the role of the given kind and name, comparing the kind
+// case-insensitively. RoleRefs with another apiGroup never match, as the API server ignores them.
+func roleRefMatches(ref rbacv1.RoleRef, kind, name string) bool {
+	return isRoleRefAPIGroup(ref.APIGroup) && strings.EqualFold(ref.Kind, kind) && ref.Name == name
+}
+
+// roleRefSkipReason returns the counter of the reason a binding's RoleRef can't be resolved, or "" if it can.
+// ClusterRoleBindings can only reference ClusterRoles.
+func roleRefSkipReason(ref rbacv1.RoleRef, clusterScoped bool) string {
+	if !isRoleRefAPIGroup(ref.APIGroup) {
+		return StatBindingsSkippedAPIGroup
+	}
+	kind, ok := normalizeRoleRefKind(ref.Kind)
+	if !ok || (clusterScoped && kind != RoleRefKindClusterRole) {
+		return StatBindingsSkippedKind
+	}
+	return ""
+}
+
+// normalizeBindings returns the bindings whose RoleRef the API server resolves, with their RoleRef kinds in
+// canonical casing. The others grant nothing and are left out, counted and logged at debug level.
+func normalizeBindings(ctx context.Context, roleBindings []rbacv1.RoleBinding, clusterRoleBindings []rbacv1.ClusterRoleBinding,
+	stats *syncStats) ([]rbacv1.RoleBinding, []rbacv1.ClusterRoleBinding) {
+	l := ctxzap.Extract(ctx)
+
+	validRoleBindings := make([]rbacv1.RoleBinding, 0, len(roleBindings))
+	for _, binding := range roleBindings {
+		if reason := roleRefSkipReason(binding.RoleRef, false); reason != "" {
+			l.Debug("skipping role binding with an invalid role ref",
+				zap.String("namespace", binding.Namespace),
+				zap.String("name", binding.Name),
+				zap.String("kind", binding.RoleRef.Kind),
+				zap.String("apiGroup", binding.RoleRef.APIGroup))
+			stats.Inc(reason)
+			continue
+		}
+		binding.RoleRef.Kind, _ = normalizeRoleRefKind(binding.RoleRef.Kind)
+		validRoleBindings = append(validRoleBindings, binding)
+	}
+
+	validClusterRoleBindings := make([]rbacv1.ClusterRoleBinding, 0, len(clusterRoleBindings))
+	for _, binding := range clusterRoleBindings {
+		if reason := roleRefSkipReason(binding.RoleRef, true); reason != "" {
+			l.Debug("skipping cluster role binding with an invalid role ref",
+				zap.String("name", binding.Name),
+				zap.String("kind", binding.RoleRef.Kind),
+				zap.String("apiGroup", binding.RoleRef.APIGroup))
+			stats.Inc(reason)
+			continue
+		}
+		binding.RoleRef.Kind, _ = normalizeRoleRefKind(binding.RoleRef.Kind)
+		validClusterRoleBindings = append(validClusterRoleBindings, binding)
+	}
+
+	return validRoleBindings, validClusterRoleBindings
+}
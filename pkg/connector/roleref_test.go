@@ -0,0 +1,100 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRoleRefMatches(t *testing.T) {
+	tests := []struct {
+		name  string
+		ref   rbacv1.RoleRef
+		kind  string
+		match bool
+	}{
+		{name: "exact", ref: rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: "ClusterRole", Name: "viewer"}, kind: RoleRefKindClusterRole, match: true},
+		{name: "empty apiGroup", ref: rbacv1.RoleRef{Kind: "Role", Name: "viewer"}, kind: RoleRefKindRole, match: true},
+		{name: "lowercase kind", ref: rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: "clusterrole", Name: "viewer"}, kind: RoleRefKindClusterRole, match: true},
+		{name: "uppercase kind", ref: rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: "ROLE", Name: "viewer"}, kind: RoleRefKindRole, match: true},
+		{name: "other kind", ref: rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: "Role", Name: "viewer"}, kind: RoleRefKindClusterRole, match: false},
+		{name: "other name", ref: rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: "Role", Name: "Viewer"}, kind: RoleRefKindRole, match: false},
+		{name: "other apiGroup", ref: rbacv1.RoleRef{APIGroup: "example.com", Kind: "ClusterRole", Name: "viewer"}, kind: RoleRefKindClusterRole, match: false},
+		{name: "versioned apiGroup", ref: rbacv1.RoleRef{APIGroup: RBACAPIGroupV1, Kind: "ClusterRole", Name: "viewer"}, kind: RoleRefKindClusterRole, match: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.match, roleRefMatches(tt.ref, tt.kind, "viewer"))
+		})
+	}
+}
+
+func TestRoleRefSkipReason(t *testing.T) {
+	tests := []struct {
+		name          string
+		ref           rbacv1.RoleRef
+		clusterScoped bool
+		reason        string
+	}{
+		{name: "role", ref: rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: "Role"}, reason: ""},
+		{name: "cluster role from role binding", ref: rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: "clusterRole"}, reason: ""},
+		{name: "cluster role", ref: rbacv1.RoleRef{Kind: "ClusterRole"}, clusterScoped: true, reason: ""},
+		{name: "role from cluster role binding", ref: rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: "Role"}, clusterScoped: true, reason: StatBindingsSkippedKind},
+		{name: "unknown kind", ref: rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: "Group"}, reason: StatBindingsSkippedKind},
+		{name: "missing kind", ref: rbacv1.RoleRef{APIGroup: RBACAPIGroup}, reason: StatBindingsSkippedKind},
+		{name: "other apiGroup", ref: rbacv1.RoleRef{APIGroup: "example.com", Kind: "Role"}, reason: StatBindingsSkippedAPIGroup},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.reason, roleRefSkipReason(tt.ref, tt.clusterScoped))
+		})
+	}
+}
+
+// TestBindingsCaches_NormalizeRoleRefs tests that the binding caches match RoleRef kinds in any casing, and
+// leave out and count the bindings whose RoleRef the API server wouldn't resolve.
+func TestBindingsCaches_NormalizeRoleRefs(t *testing.T) {
+	ctx := context.Background()
+	roleBinding := func(name string, ref rbacv1.RoleRef) *rbacv1.RoleBinding {
+		return &rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: name}, RoleRef: ref}
+	}
+	client := fake.NewSimpleClientset(
+		roleBinding("exact", rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: "ClusterRole", Name: "viewer"}),
+		roleBinding("lowercase", rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: "clusterrole", Name: "viewer"}),
+		roleBinding("phantom", rbacv1.RoleRef{APIGroup: "example.com", Kind: "ClusterRole", Name: "viewer"}),
+		roleBinding("role", rbacv1.RoleRef{Kind: "role", Name: "deployer"}),
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "viewers"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: "CLUSTERROLE", Name: "viewer"},
+		},
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "invalid"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: "Role", Name: "viewer"},
+		},
+	)
+	k := newTestKubernetes(client, ConnectorOpts{})
+
+	roleBindings, clusterRoleBindings, err := k.GetMatchingBindingsForClusterRole(ctx, "viewer")
+	require.NoError(t, err)
+	var names []string
+	for _, binding := range roleBindings {
+		names = append(names, binding.Name)
+		assert.Equal(t, RoleRefKindClusterRole, binding.RoleRef.Kind)
+	}
+	assert.ElementsMatch(t, []string{"exact", "lowercase"}, names)
+	require.Len(t, clusterRoleBindings, 1)
+	assert.Equal(t, "viewers", clusterRoleBindings[0].Name)
+
+	roleBindings, err = k.GetMatchingRoleBindings(ctx, "payments", "deployer")
+	require.NoError(t, err)
+	require.Len(t, roleBindings, 1)
+	assert.Equal(t, "role", roleBindings[0].Name)
+
+	assert.Equal(t, int64(1), k.stats.Get(StatBindingsSkippedAPIGroup))
+	assert.Equal(t, int64(1), k.stats.Get(StatBindingsSkippedKind))
+}
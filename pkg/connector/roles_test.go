@@ -57,9 +57,13 @@ func TestRoleBuilderEntitlements(t *testing.T) {
 	require.Nil(t, ann)
 	assert.Empty(t, nextPageToken)
 
-	// Roles should have 1 entitlements: member
-	require.Len(t, entitlements, 1)
+	// Roles should have 3 entitlements: member, escalate and bind
+	require.Len(t, entitlements, 3)
 
 	assert.Contains(t, entitlements[0].Description, "membership")
 	assert.Len(t, entitlements[0].GrantableTo, 3) // KubeUser, KubeGroup, ServiceAccount
+	for i, verb := range roleEscalationVerbs {
+		assert.Equal(t, verb, entitlements[i+1].Slug)
+		assert.Len(t, entitlements[i+1].GrantableTo, 2) // Role, ClusterRole
+	}
 }
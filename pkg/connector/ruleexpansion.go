@@ -0,0 +1,512 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	rbacv1 "k8s.io/api/rbac/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ruleTarget describes the Baton resource type a Kubernetes API resource maps to.
+type ruleTarget struct {
+	resourceType *v2.ResourceType
+	namespaced   bool
+	// verbs are the verb entitlements of the resource type, standardResourceVerbs if nil.
+	verbs []string
+	// grantableInNamespace marks cluster-scoped resources that Roles can still grant access to within their
+	// namespace, like binding ClusterRoles in RoleBindings.
+	grantableInNamespace bool
+}
+
+// entitlementVerbs returns the verb entitlements of the target's resource type.
+func (t ruleTarget) entitlementVerbs() []string {
+	if t.verbs == nil {
+		return standardResourceVerbs
+	}
+	return t.verbs
+}
+
+// impersonationVerbs are the verb entitlements of the principals, which can only be impersonated.
+var impersonationVerbs = []string{"impersonate"}
+
+// ruleTargets maps the apiGroup and plural resource names used in PolicyRules
+// to the resource types synced by the connector.
+var ruleTargets = map[schema.GroupResource]ruleTarget{
+	{Group: "", Resource: "pods"}:             {resourceType: ResourceTypePod, namespaced: true},
+	{Group: "", Resource: "secrets"}:          {resourceType: ResourceTypeSecret, namespaced: true},
+	{Group: "", Resource: "configmaps"}:       {resourceType: ResourceTypeConfigMap, namespaced: true},
+	{Group: "", Resource: "services"}:         {resourceType: ResourceTypeService, namespaced: true},
+	{Group: "apps", Resource: "deployments"}:  {resourceType: ResourceTypeDeployment, namespaced: true},
+	{Group: "apps", Resource: "statefulsets"}: {resourceType: ResourceTypeStatefulSet, namespaced: true},
+	{Group: "apps", Resource: "daemonsets"}:   {resourceType: ResourceTypeDaemonSet, namespaced: true},
+	{Group: "apps", Resource: "replicasets"}:  {resourceType: ResourceTypeReplicaSet, namespaced: true},
+	{Group: "", Resource: "namespaces"}:       {resourceType: ResourceTypeNamespace, namespaced: false},
+	{Group: "", Resource: "nodes"}:            {resourceType: ResourceTypeNode, namespaced: false},
+	{Group: "", Resource: "serviceaccounts"}: {
+		resourceType: ResourceTypeServiceAccount,
+		namespaced:   true,
+		verbs:        impersonationVerbs,
+	},
+	{Group: "", Resource: "users"}: {
+		resourceType: ResourceTypeKubeUser,
+		namespaced:   false,
+		verbs:        impersonationVerbs,
+	},
+	{Group: "", Resource: "groups"}: {
+		resourceType: ResourceTypeKubeGroup,
+		namespaced:   false,
+		verbs:        impersonationVerbs,
+	},
+	{Group: RBACAPIGroup, Resource: "roles"}: {
+		resourceType: ResourceTypeRole,
+		namespaced:   true,
+		verbs:        roleEscalationVerbs,
+	},
+	{Group: RBACAPIGroup, Resource: "clusterroles"}: {
+		resourceType:         ResourceTypeClusterRole,
+		namespaced:           false,
+		verbs:                roleEscalationVerbs,
+		grantableInNamespace: true,
+	},
+}
+
+// subresourceTarget describes the entitlement a subresource in a PolicyRule maps to.
+type subresourceTarget struct {
+	entitlement string
+	// verbs are the rule verbs that grant access through the subresource.
+	verbs []string
+}
+
+// subresourceTargets maps the subresources of the synced resources to the entitlements they grant.
+var subresourceTargets = map[schema.GroupResource]map[string]subresourceTarget{
+	{Group: "", Resource: "pods"}: {
+		"exec":        {entitlement: "exec", verbs: []string{"create", "get"}},
+		"portforward": {entitlement: "portforward", verbs: []string{"create", "get"}},
+		"log":         {entitlement: "logs", verbs: []string{"get"}},
+	},
+	{Group: "apps", Resource: "deployments"}: {
+		"scale": {entitlement: "scale", verbs: []string{"update", "patch"}},
+	},
+	{Group: "apps", Resource: "statefulsets"}: {
+		"scale": {entitlement: "scale", verbs: []string{"update", "patch"}},
+	},
+	{Group: "", Resource: "nodes"}: {
+		"proxy": {entitlement: "proxy", verbs: []string{"get", "create", "update", "patch", "delete"}},
+		"stats": {entitlement: "stats", verbs: []string{"get", "create"}},
+		"log":   {entitlement: "log", verbs: []string{"get"}},
+	},
+}
+
+// subresourceEntitlements returns the entitlements granted by the verbs of a rule on a subresource of the
+// given resource, where "*" matches every subresource. It reports false if the subresource is unknown.
+func subresourceEntitlements(gr schema.GroupResource, subresource string, verbs []string) ([]string, bool) {
+	targets := subresourceTargets[gr]
+
+	var names []string
+	if subresource == rbacv1.ResourceAll {
+		for name := range targets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	} else {
+		if _, ok := targets[subresource]; !ok {
+			return nil, false
+		}
+		names = []string{subresource}
+	}
+
+	var entitlements []string
+	for _, name := range names {
+		target := targets[name]
+		if grantsAnyVerb(verbs, target.verbs) {
+			entitlements = append(entitlements, target.entitlement)
+		}
+	}
+	return entitlements, true
+}
+
+// grantsAnyVerb reports whether the rule verbs include "*" or any of the wanted verbs.
+func grantsAnyVerb(ruleVerbs, wanted []string) bool {
+	for _, verb := range ruleVerbs {
+		if verb == rbacv1.VerbAll {
+			return true
+		}
+		for _, w := range wanted {
+			if verb == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchRuleTargets returns the rule targets matching an apiGroup and resource from a PolicyRule,
+// expanding "*" wildcards. Results are sorted for deterministic output.
+func matchRuleTargets(apiGroup, resource string) []schema.GroupResource {
+	var matches []schema.GroupResource
+	for gr := range ruleTargets {
+		if apiGroup != rbacv1.APIGroupAll && apiGroup != gr.Group {
+			continue
+		}
+		if resource != rbacv1.ResourceAll && resource != gr.Resource {
+			continue
+		}
+		matches = append(matches, gr)
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].String() < matches[j].String()
+	})
+
+	return matches
+}
+
+// ruleVerbs returns the verbs of a rule that have matching verb entitlements among the known verbs.
+func ruleVerbs(rule rbacv1.PolicyRule, knownVerbs []string) []string {
+	var verbs []string
+	for _, verb := range rule.Verbs {
+		if verb == rbacv1.VerbAll {
+			return knownVerbs
+		}
+		for _, known := range knownVerbs {
+			if verb == known {
+				verbs = append(verbs, verb)
+				break
+			}
+		}
+	}
+	return verbs
+}
+
+// ruleScope describes where the rules of a role take effect.
+type ruleScope struct {
+	// namespace is the namespace of a Role and is empty for ClusterRoles.
+	namespace string
+	// namedNamespaces are the namespaces in which the resourceNames of namespaced resources are resolved.
+	namedNamespaces []string
+}
+
+// roleRuleScope returns the scope of the rules of a Role in the given namespace.
+func roleRuleScope(namespace string) ruleScope {
+	return ruleScope{
+		namespace:       namespace,
+		namedNamespaces: []string{namespace},
+	}
+}
+
+// clusterRoleRuleScope returns the scope of the rules of a ClusterRole bound in the given namespaces.
+func clusterRoleRuleScope(boundNamespaces []string) ruleScope {
+	return ruleScope{
+		namedNamespaces: uniqueSorted(boundNamespaces),
+	}
+}
+
+// ruleObject identifies an object covered by a rule. An empty name denotes every object of the type, in the
+// namespace if it's set.
+type ruleObject struct {
+	namespace string
+	name      string
+}
+
+// resourceID returns the raw ID of the Baton resource for the object.
+func (o ruleObject) resourceID() string {
+	if o.name == "" {
+		if o.namespace != "" {
+			return namespacedName(o.namespace, "*")
+		}
+		return "*"
+	}
+	if o.namespace == "" {
+		return o.name
+	}
+	return namespacedName(o.namespace, o.name)
+}
+
+// resource returns the Baton resource for the object, for grants on it. Every user or group is covered by the
+// wildcard resource, whose reserved "*" ID isn't encoded like their names.
+func (o ruleObject) resource(resourceTypeID string) *v2.Resource {
+	if o.name == "" && o.namespace == "" {
+		return &v2.Resource{Id: &v2.ResourceId{ResourceType: resourceTypeID, Resource: "*"}}
+	}
+	return GenerateResourceForGrant(o.resourceID(), resourceTypeID)
+}
+
+// ruleExpansion accumulates the grants produced by expanding the PolicyRules of a single role.
+type ruleExpansion struct {
+	client kubernetes.Interface
+	// principal is the Role or ClusterRole resource the rules belong to.
+	principal *v2.Resource
+	scope     ruleScope
+	opts      ConnectorOpts
+	seen      map[string]bool
+	grants    []*v2.Grant
+}
+
+// expandPolicyRules turns the PolicyRules of a Role or ClusterRole into permission grants from the role
+// (as principal) to the verb entitlements of the resources they cover.
+//
+// Rules without resourceNames are granted on the wildcard resource of the target type, or for the namespaced
+// types of Roles on the wildcard of their namespace when namespace wildcards are enabled. Rules with
+// resourceNames are granted on the specific named resources, in every namespace of the scope for namespaced
+// types. Named objects are granted even if they don't exist, so the intent of the rule stays visible, unless
+// the connector is configured to skip missing named resources.
+func expandPolicyRules(
+	ctx context.Context,
+	client kubernetes.Interface,
+	principal *v2.Resource,
+	scope ruleScope,
+	rules []rbacv1.PolicyRule,
+	opts ConnectorOpts,
+) ([]*v2.Grant, error) {
+	e := &ruleExpansion{
+		client:    client,
+		principal: principal,
+		scope:     scope,
+		opts:      opts,
+		seen:      make(map[string]bool),
+	}
+
+	for _, rule := range rules {
+		if err := e.expandRule(ctx, rule); err != nil {
+			return nil, err
+		}
+		e.expandNonResourceURLs(ctx, rule)
+	}
+
+	return e.grants, nil
+}
+
+// expandRule adds the grants for a single PolicyRule.
+//
+// Resources of the form "resource/subresource" grant the entitlement the subresource maps to rather than
+// the verb entitlements, and "*" covers both the resources and all of their subresources.
+func (e *ruleExpansion) expandRule(ctx context.Context, rule rbacv1.PolicyRule) error {
+	l := ctxzap.Extract(ctx)
+
+	for _, apiGroup := range rule.APIGroups {
+		for _, resource := range rule.Resources {
+			base, subresource, hasSubresource := strings.Cut(resource, "/")
+			matches := matchRuleTargets(apiGroup, base)
+			if len(matches) == 0 {
+				l.Debug("skipping rule for resource not synced by the connector",
+					zap.String("role", e.principal.Id.Resource),
+					zap.String("apiGroup", apiGroup),
+					zap.String("resource", resource))
+				continue
+			}
+
+			for _, gr := range matches {
+				var entitlementNames []string
+				if !hasSubresource {
+					entitlementNames = append(entitlementNames, ruleVerbs(rule, ruleTargets[gr].entitlementVerbs())...)
+				}
+				if hasSubresource || resource == rbacv1.ResourceAll {
+					if !hasSubresource {
+						subresource = rbacv1.ResourceAll
+					}
+					subEntitlements, known := subresourceEntitlements(gr, subresource, rule.Verbs)
+					if !known {
+						// Wildcard resources match many types, most of which don't have the subresource.
+						if base != rbacv1.ResourceAll {
+							l.Debug("skipping rule for unknown subresource",
+								zap.String("role", e.principal.Id.Resource),
+								zap.String("apiGroup", apiGroup),
+								zap.String("resource", resource))
+						}
+						continue
+					}
+					entitlementNames = append(entitlementNames, subEntitlements...)
+				}
+
+				if err := e.expandTarget(ctx, rule, ruleTargets[gr], entitlementNames); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// expandTarget adds grants for the given entitlements on the resources of a single target type covered by a rule.
+func (e *ruleExpansion) expandTarget(ctx context.Context, rule rbacv1.PolicyRule, target ruleTarget, entitlementNames []string) error {
+	l := ctxzap.Extract(ctx)
+
+	// Verbs trimmed by the verb filter have no entitlement to grant
+	entitlementNames = slices.DeleteFunc(slices.Clone(entitlementNames), func(name string) bool {
+		return !e.opts.verbAllowed(target.resourceType.Id, name)
+	})
+	if len(entitlementNames) == 0 {
+		return nil
+	}
+
+	if !e.opts.syncsResourceType(target.resourceType.Id) {
+		l.Debug("skipping rule for resource type disabled in the connector",
+			zap.String("role", e.principal.Id.Resource),
+			zap.String("resourceType", target.resourceType.Id))
+		return nil
+	}
+
+	// Roles only grant access to namespaced resources within their own namespace.
+	if e.scope.namespace != "" && !target.namespaced && !target.grantableInNamespace {
+		return nil
+	}
+
+	for _, obj := range ruleObjects(rule, target, e.scope, e.opts) {
+		if obj.name == "" && e.opts.DisableWildcardResources {
+			l.Debug("skipping rule for all resources of the type, wildcard resources are disabled",
+				zap.String("role", e.principal.Id.Resource),
+				zap.String("resourceType", target.resourceType.Id))
+			continue
+		}
+		if obj.name != "" && e.opts.SkipMissingNamedResources {
+			exists, err := namedObjectExists(ctx, e.client, target.resourceType.Id, obj)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				l.Debug("skipping rule for missing named resource",
+					zap.String("role", e.principal.Id.Resource),
+					zap.String("resourceType", target.resourceType.Id),
+					zap.String("resource", obj.resourceID()))
+				continue
+			}
+		}
+
+		for _, resourceType := range e.objectResourceTypes(target, obj) {
+			targetResource := obj.resource(resourceType.Id)
+			for _, name := range entitlementNames {
+				e.add(targetResource, name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// objectResourceTypes returns the resource types an object covered by a rule is synced as. Users are split
+// between KubeUser and KubeSystemUser when system users are separated, so rules on every user cover both.
+func (e *ruleExpansion) objectResourceTypes(target ruleTarget, obj ruleObject) []*v2.ResourceType {
+	if target.resourceType != ResourceTypeKubeUser || !e.opts.SeparateSystemUsers {
+		return []*v2.ResourceType{target.resourceType}
+	}
+	if obj.name == "" {
+		return []*v2.ResourceType{ResourceTypeKubeUser, ResourceTypeKubeSystemUser}
+	}
+	return []*v2.ResourceType{e.opts.kubeUserResourceType(obj.name)}
+}
+
+// expandNonResourceURLs adds grants from a ClusterRole to the cluster entitlements of the non-resource URLs
+// in a rule, recording the URL pattern and verb in the grant metadata.
+func (e *ruleExpansion) expandNonResourceURLs(ctx context.Context, rule rbacv1.PolicyRule) {
+	l := ctxzap.Extract(ctx)
+
+	// Only ClusterRoles can grant non-resource URLs.
+	if e.scope.namespace != "" || len(rule.NonResourceURLs) == 0 {
+		return
+	}
+
+	if !e.opts.syncsResourceType(ResourceTypeCluster.Id) {
+		l.Debug("skipping non-resource URLs, cluster resource type disabled in the connector",
+			zap.String("role", e.principal.Id.Resource))
+		return
+	}
+
+	cluster := GenerateResourceForGrant(ClusterResourceID, ResourceTypeCluster.Id)
+	for _, p := range nonResourcePermissions([]rbacv1.PolicyRule{rule}) {
+		e.add(cluster, nonResourceEntitlementSlug(p.url, p.verb), grant.WithGrantMetadata(map[string]interface{}{
+			GrantMetadataNonResourceURL:  p.url,
+			GrantMetadataNonResourceVerb: p.verb,
+		}))
+	}
+}
+
+// add records a grant from the role to the named entitlement of the target resource, skipping duplicates.
+func (e *ruleExpansion) add(target *v2.Resource, entitlementName string, grantOpts ...grant.GrantOption) {
+	g := grant.NewGrant(target, entitlementName, e.principal.Id, grantOpts...)
+	if e.seen[g.Id] {
+		return
+	}
+	e.seen[g.Id] = true
+	e.grants = append(e.grants, g)
+}
+
+// ruleObjects returns the objects a rule applies to for the given target.
+func ruleObjects(rule rbacv1.PolicyRule, target ruleTarget, scope ruleScope, opts ConnectorOpts) []ruleObject {
+	if len(rule.ResourceNames) == 0 {
+		if opts.namespaceWildcards() && target.namespaced && scope.namespace != "" {
+			return []ruleObject{{namespace: scope.namespace}}
+		}
+		return []ruleObject{{}}
+	}
+
+	var objects []ruleObject
+	for _, name := range rule.ResourceNames {
+		if !target.namespaced {
+			objects = append(objects, ruleObject{name: name})
+			continue
+		}
+		for _, namespace := range scope.namedNamespaces {
+			objects = append(objects, ruleObject{namespace: namespace, name: name})
+		}
+	}
+	return objects
+}
+
+// namedObjectExists reports whether the object of the given resource type exists in the cluster.
+func namedObjectExists(ctx context.Context, client kubernetes.Interface, resourceTypeID string, obj ruleObject) (bool, error) {
+	var err error
+	getOpts := metav1.GetOptions{}
+	switch resourceTypeID {
+	case ResourceTypePod.Id:
+		_, err = client.CoreV1().Pods(obj.namespace).Get(ctx, obj.name, getOpts)
+	case ResourceTypeSecret.Id:
+		_, err = client.CoreV1().Secrets(obj.namespace).Get(ctx, obj.name, getOpts)
+	case ResourceTypeConfigMap.Id:
+		_, err = client.CoreV1().ConfigMaps(obj.namespace).Get(ctx, obj.name, getOpts)
+	case ResourceTypeServiceAccount.Id:
+		_, err = client.CoreV1().ServiceAccounts(obj.namespace).Get(ctx, obj.name, getOpts)
+	case ResourceTypeService.Id:
+		_, err = client.CoreV1().Services(obj.namespace).Get(ctx, obj.name, getOpts)
+	case ResourceTypeDeployment.Id:
+		_, err = client.AppsV1().Deployments(obj.namespace).Get(ctx, obj.name, getOpts)
+	case ResourceTypeStatefulSet.Id:
+		_, err = client.AppsV1().StatefulSets(obj.namespace).Get(ctx, obj.name, getOpts)
+	case ResourceTypeDaemonSet.Id:
+		_, err = client.AppsV1().DaemonSets(obj.namespace).Get(ctx, obj.name, getOpts)
+	case ResourceTypeReplicaSet.Id:
+		_, err = client.AppsV1().ReplicaSets(obj.namespace).Get(ctx, obj.name, getOpts)
+	case ResourceTypeNamespace.Id:
+		_, err = client.CoreV1().Namespaces().Get(ctx, obj.name, getOpts)
+	case ResourceTypeNode.Id:
+		_, err = client.CoreV1().Nodes().Get(ctx, obj.name, getOpts)
+	case ResourceTypeRole.Id:
+		_, err = client.RbacV1().Roles(obj.namespace).Get(ctx, obj.name, getOpts)
+	case ResourceTypeClusterRole.Id:
+		_, err = client.RbacV1().ClusterRoles().Get(ctx, obj.name, getOpts)
+	case ResourceTypeKubeUser.Id, ResourceTypeKubeGroup.Id:
+		// Users and groups aren't API objects, any name can be impersonated.
+		return true, nil
+	default:
+		return false, fmt.Errorf("unsupported resource type for named resource lookup: %s", resourceTypeID)
+	}
+
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get %s %s: %w", resourceTypeID, obj.resourceID(), err)
+	}
+	return true, nil
+}
@@ -0,0 +1,501 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// grantEntitlementIDs returns the entitlement IDs of the given grants.
+func grantEntitlementIDs(grants []*v2.Grant) []string {
+	ids := make([]string, 0, len(grants))
+	for _, g := range grants {
+		ids = append(ids, g.Entitlement.Id)
+	}
+	return ids
+}
+
+// TestExpandPolicyRules_Role tests that Role rules are expanded into grants on wildcard and named resources.
+func TestExpandPolicyRules_Role(t *testing.T) {
+	principal := &v2.Resource{
+		Id: &v2.ResourceId{
+			ResourceType: ResourceTypeRole.Id,
+			Resource:     "test-ns/test-role",
+		},
+	}
+
+	rules := []rbacv1.PolicyRule{
+		{
+			Verbs:     []string{"get", "list"},
+			APIGroups: []string{""},
+			Resources: []string{"pods"},
+		},
+		{
+			Verbs:         []string{"get"},
+			APIGroups:     []string{""},
+			Resources:     []string{"secrets"},
+			ResourceNames: []string{"db-password"},
+		},
+		{
+			Verbs:     []string{"*"},
+			APIGroups: []string{"apps"},
+			Resources: []string{"deployments"},
+		},
+		{
+			// Not synced by the connector
+			Verbs:     []string{"get"},
+			APIGroups: []string{"example.com"},
+			Resources: []string{"widgets"},
+		},
+		{
+			// Cluster-scoped resources can't be granted by a Role
+			Verbs:     []string{"get"},
+			APIGroups: []string{""},
+			Resources: []string{"nodes"},
+		},
+	}
+
+	grants, err := expandPolicyRules(context.Background(), nil, principal, roleRuleScope("test-ns"), rules, ConnectorOpts{})
+	require.NoError(t, err)
+	ids := grantEntitlementIDs(grants)
+
+	assert.Contains(t, ids, "pod:*:get")
+	assert.Contains(t, ids, "pod:*:list")
+	assert.Contains(t, ids, "secret:test-ns/db-password:get")
+	assert.NotContains(t, ids, "secret:*:get")
+	for _, verb := range standardResourceVerbs {
+		assert.Contains(t, ids, "deployment:*:"+verb)
+	}
+	assert.Len(t, grants, 3+len(standardResourceVerbs))
+
+	for _, g := range grants {
+		assert.Equal(t, principal.Id.ResourceType, g.Principal.Id.ResourceType)
+		assert.Equal(t, principal.Id.Resource, g.Principal.Id.Resource)
+	}
+}
+
+// TestExpandPolicyRules_Wildcards tests that "*" apiGroups and resources match every synced type once.
+func TestExpandPolicyRules_Wildcards(t *testing.T) {
+	principal := &v2.Resource{
+		Id: &v2.ResourceId{
+			ResourceType: ResourceTypeRole.Id,
+			Resource:     "test-ns/admin",
+		},
+	}
+
+	rules := []rbacv1.PolicyRule{
+		{
+			Verbs:     []string{"get"},
+			APIGroups: []string{"*"},
+			Resources: []string{"*"},
+		},
+		{
+			// Duplicate coverage should not produce duplicate grants
+			Verbs:     []string{"get"},
+			APIGroups: []string{""},
+			Resources: []string{"secrets"},
+		},
+	}
+
+	grants, err := expandPolicyRules(context.Background(), nil, principal, roleRuleScope("test-ns"), rules, ConnectorOpts{})
+	require.NoError(t, err)
+
+	// Roles only cover namespaced resource types with a get entitlement, "*" also covers the pod subresources
+	// granted by "get"
+	namespacedTargets := 0
+	for _, target := range ruleTargets {
+		if target.namespaced && target.verbs == nil {
+			namespacedTargets++
+		}
+	}
+	assert.Len(t, grants, namespacedTargets+3)
+
+	ids := grantEntitlementIDs(grants)
+	assert.Contains(t, ids, "pod:*:exec")
+	assert.Contains(t, ids, "pod:*:portforward")
+	assert.Contains(t, ids, "pod:*:logs")
+}
+
+// TestExpandPolicyRules_SyncResources tests that resource types not synced by the connector are skipped.
+func TestExpandPolicyRules_SyncResources(t *testing.T) {
+	principal := &v2.Resource{
+		Id: &v2.ResourceId{
+			ResourceType: ResourceTypeRole.Id,
+			Resource:     "test-ns/test-role",
+		},
+	}
+
+	rules := []rbacv1.PolicyRule{
+		{
+			Verbs:     []string{"get"},
+			APIGroups: []string{""},
+			Resources: []string{"pods", "secrets"},
+		},
+	}
+
+	opts := ConnectorOpts{SyncResources: []string{ResourceTypeSecret.Id}}
+	grants, err := expandPolicyRules(context.Background(), nil, principal, roleRuleScope("test-ns"), rules, opts)
+	require.NoError(t, err)
+	require.Len(t, grants, 1)
+	assert.Equal(t, "secret:*:get", grants[0].Entitlement.Id)
+}
+
+// TestRoleBuilderGrants_RuleExpansion tests that roleBuilder.Grants emits grants from the role's rules.
+func TestRoleBuilderGrants_RuleExpansion(t *testing.T) {
+	role := &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "secret-reader",
+			Namespace: "test-ns",
+		},
+		Rules: []rbacv1.PolicyRule{
+			{
+				Verbs:         []string{"get"},
+				APIGroups:     []string{""},
+				Resources:     []string{"secrets"},
+				ResourceNames: []string{"test-secret"},
+			},
+		},
+	}
+
+	builder := &roleBuilder{
+		client:          fake.NewSimpleClientset(role),
+		bindingProvider: newMockRoleBindingProvider(),
+	}
+
+	testResource := &v2.Resource{
+		Id: &v2.ResourceId{
+			ResourceType: ResourceTypeRole.Id,
+			Resource:     "test-ns/secret-reader",
+		},
+		DisplayName: "secret-reader",
+	}
+
+	grants, _, _, err := builder.Grants(context.Background(), testResource, &pagination.Token{})
+	require.NoError(t, err)
+	require.Len(t, grants, 1)
+	assert.Equal(t, "secret:test-ns/test-secret:get", grants[0].Entitlement.Id)
+	assert.Equal(t, "test-ns/secret-reader", grants[0].Principal.Id.Resource)
+}
+
+// TestExpandPolicyRules_MissingNamedResources tests that grants on missing named objects are emitted by default
+// and skipped when configured.
+func TestExpandPolicyRules_MissingNamedResources(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "present",
+			Namespace: "test-ns",
+		},
+	})
+
+	principal := &v2.Resource{
+		Id: &v2.ResourceId{
+			ResourceType: ResourceTypeRole.Id,
+			Resource:     "test-ns/test-role",
+		},
+	}
+
+	rules := []rbacv1.PolicyRule{
+		{
+			Verbs:         []string{"get"},
+			APIGroups:     []string{""},
+			Resources:     []string{"secrets"},
+			ResourceNames: []string{"present", "absent"},
+		},
+	}
+
+	grants, err := expandPolicyRules(context.Background(), client, principal, roleRuleScope("test-ns"), rules, ConnectorOpts{})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"secret:test-ns/present:get", "secret:test-ns/absent:get"}, grantEntitlementIDs(grants))
+
+	opts := ConnectorOpts{SkipMissingNamedResources: true}
+	grants, err = expandPolicyRules(context.Background(), client, principal, roleRuleScope("test-ns"), rules, opts)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"secret:test-ns/present:get"}, grantEntitlementIDs(grants))
+}
+
+// TestExpandPolicyRules_Subresources tests that known subresources map to their entitlements.
+func TestExpandPolicyRules_Subresources(t *testing.T) {
+	principal := &v2.Resource{
+		Id: &v2.ResourceId{
+			ResourceType: ResourceTypeClusterRole.Id,
+			Resource:     "operator",
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		rule     rbacv1.PolicyRule
+		expected []string
+	}{
+		{
+			name:     "pods/exec",
+			rule:     rbacv1.PolicyRule{Verbs: []string{"create"}, APIGroups: []string{""}, Resources: []string{"pods/exec"}},
+			expected: []string{"pod:*:exec"},
+		},
+		{
+			name:     "pods/portforward",
+			rule:     rbacv1.PolicyRule{Verbs: []string{"create"}, APIGroups: []string{""}, Resources: []string{"pods/portforward"}},
+			expected: []string{"pod:*:portforward"},
+		},
+		{
+			name:     "pods/log",
+			rule:     rbacv1.PolicyRule{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"pods/log"}},
+			expected: []string{"pod:*:logs"},
+		},
+		{
+			name:     "deployments/scale",
+			rule:     rbacv1.PolicyRule{Verbs: []string{"patch"}, APIGroups: []string{"apps"}, Resources: []string{"deployments/scale"}},
+			expected: []string{"deployment:*:scale"},
+		},
+		{
+			name:     "nodes/proxy",
+			rule:     rbacv1.PolicyRule{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"nodes/proxy"}},
+			expected: []string{"node:*:proxy"},
+		},
+		{
+			name:     "nodes/stats",
+			rule:     rbacv1.PolicyRule{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"nodes/stats"}},
+			expected: []string{"node:*:stats"},
+		},
+		{
+			name:     "nodes/log",
+			rule:     rbacv1.PolicyRule{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"nodes/log"}},
+			expected: []string{"node:*:log"},
+		},
+		{
+			name:     "nodes/*",
+			rule:     rbacv1.PolicyRule{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"nodes/*"}},
+			expected: []string{"node:*:log", "node:*:proxy", "node:*:stats"},
+		},
+		{
+			name:     "pods/*",
+			rule:     rbacv1.PolicyRule{Verbs: []string{"*"}, APIGroups: []string{""}, Resources: []string{"pods/*"}},
+			expected: []string{"pod:*:exec", "pod:*:logs", "pod:*:portforward"},
+		},
+		{
+			name:     "verb not granting the subresource",
+			rule:     rbacv1.PolicyRule{Verbs: []string{"get"}, APIGroups: []string{"apps"}, Resources: []string{"deployments/scale"}},
+			expected: nil,
+		},
+		{
+			name:     "unknown subresource",
+			rule:     rbacv1.PolicyRule{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"pods/status"}},
+			expected: nil,
+		},
+		{
+			name:     "named subresource",
+			rule:     rbacv1.PolicyRule{Verbs: []string{"create"}, APIGroups: []string{""}, Resources: []string{"pods/exec"}, ResourceNames: []string{"debug"}},
+			expected: []string{"pod:test-ns/debug:exec"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			grants, err := expandPolicyRules(context.Background(), nil, principal, clusterRoleRuleScope([]string{"test-ns"}), []rbacv1.PolicyRule{tc.rule}, ConnectorOpts{})
+			require.NoError(t, err)
+			if tc.expected == nil {
+				assert.Empty(t, grants)
+				return
+			}
+			assert.ElementsMatch(t, tc.expected, grantEntitlementIDs(grants))
+		})
+	}
+}
+
+// TestExpandPolicyRules_BindSpecificClusterRole tests that a Role allowed to bind a single named ClusterRole
+// is granted bind on that ClusterRole only.
+func TestExpandPolicyRules_BindSpecificClusterRole(t *testing.T) {
+	principal := &v2.Resource{
+		Id: &v2.ResourceId{
+			ResourceType: ResourceTypeRole.Id,
+			Resource:     "test-ns/binder",
+		},
+	}
+
+	rules := []rbacv1.PolicyRule{
+		{
+			Verbs:         []string{"bind"},
+			APIGroups:     []string{RBACAPIGroup},
+			Resources:     []string{"clusterroles"},
+			ResourceNames: []string{"view"},
+		},
+		{
+			Verbs:     []string{"create", "get"},
+			APIGroups: []string{RBACAPIGroup},
+			Resources: []string{"rolebindings"},
+		},
+	}
+
+	grants, err := expandPolicyRules(context.Background(), nil, principal, roleRuleScope("test-ns"), rules, ConnectorOpts{})
+	require.NoError(t, err)
+	require.Len(t, grants, 1)
+	assert.Equal(t, "cluster_role:view:bind", grants[0].Entitlement.Id)
+	assert.Equal(t, "test-ns/binder", grants[0].Principal.Id.Resource)
+}
+
+// TestExpandPolicyRules_EscalateRoles tests that escalate and bind on roles without resourceNames are granted
+// on the wildcard roles, and that "*" verbs cover both.
+func TestExpandPolicyRules_EscalateRoles(t *testing.T) {
+	principal := &v2.Resource{
+		Id: &v2.ResourceId{
+			ResourceType: ResourceTypeClusterRole.Id,
+			Resource:     "rbac-manager",
+		},
+	}
+
+	rules := []rbacv1.PolicyRule{
+		{
+			Verbs:     []string{"escalate", "get"},
+			APIGroups: []string{RBACAPIGroup},
+			Resources: []string{"roles"},
+		},
+		{
+			Verbs:     []string{"*"},
+			APIGroups: []string{RBACAPIGroup},
+			Resources: []string{"clusterroles"},
+		},
+	}
+
+	grants, err := expandPolicyRules(context.Background(), nil, principal, clusterRoleRuleScope(nil), rules, ConnectorOpts{})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		"role:*:escalate",
+		"cluster_role:*:escalate",
+		"cluster_role:*:bind",
+	}, grantEntitlementIDs(grants))
+}
+
+// TestExpandPolicyRules_ImpersonateUser tests that a ClusterRole allowed to impersonate a single named user is
+// granted impersonate on that user only, and that the verb isn't granted on other resources.
+func TestExpandPolicyRules_ImpersonateUser(t *testing.T) {
+	principal := &v2.Resource{
+		Id: &v2.ResourceId{
+			ResourceType: ResourceTypeClusterRole.Id,
+			Resource:     "impersonate-alice",
+		},
+	}
+
+	rules := []rbacv1.PolicyRule{
+		{
+			Verbs:         []string{"impersonate"},
+			APIGroups:     []string{""},
+			Resources:     []string{"users"},
+			ResourceNames: []string{"alice@example.com"},
+		},
+		{
+			// Impersonate has no meaning on other resources
+			Verbs:     []string{"impersonate"},
+			APIGroups: []string{""},
+			Resources: []string{"secrets"},
+		},
+	}
+
+	client := fake.NewSimpleClientset()
+	opts := ConnectorOpts{SkipMissingNamedResources: true}
+	grants, err := expandPolicyRules(context.Background(), client, principal, clusterRoleRuleScope(nil), rules, opts)
+	require.NoError(t, err)
+	require.Len(t, grants, 1)
+	assert.Equal(t, "kube_user:alice@example.com:impersonate", grants[0].Entitlement.Id)
+	assert.Equal(t, "impersonate-alice", grants[0].Principal.Id.Resource)
+}
+
+// TestExpandPolicyRules_ImpersonateWildcards tests that impersonate rules without resourceNames are granted on
+// the wildcard principals, and that Roles can only impersonate service accounts in their namespace.
+func TestExpandPolicyRules_ImpersonateWildcards(t *testing.T) {
+	rules := []rbacv1.PolicyRule{
+		{
+			Verbs:     []string{"impersonate"},
+			APIGroups: []string{""},
+			Resources: []string{"users", "groups", "serviceaccounts"},
+		},
+	}
+
+	clusterRole := &v2.Resource{Id: &v2.ResourceId{ResourceType: ResourceTypeClusterRole.Id, Resource: "impersonator"}}
+	grants, err := expandPolicyRules(context.Background(), nil, clusterRole, clusterRoleRuleScope(nil), rules, ConnectorOpts{})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		"kube_user:*:impersonate",
+		"kube_group:*:impersonate",
+		"service_account:*:impersonate",
+	}, grantEntitlementIDs(grants))
+
+	role := &v2.Resource{Id: &v2.ResourceId{ResourceType: ResourceTypeRole.Id, Resource: "test-ns/impersonator"}}
+	grants, err = expandPolicyRules(context.Background(), nil, role, roleRuleScope("test-ns"), rules, ConnectorOpts{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"service_account:*:impersonate"}, grantEntitlementIDs(grants))
+}
+
+// TestExpandPolicyRules_WildcardEntitlementsExist tests that the grants of rules covering every object of a type
+// land on entitlements the syncer of the type emits for its wildcard resource, as the grants are otherwise dropped.
+func TestExpandPolicyRules_WildcardEntitlementsExist(t *testing.T) {
+	ctx := context.Background()
+	k := newTestKubernetes(fake.NewSimpleClientset(), ConnectorOpts{})
+	syncers := make(map[string]connectorbuilder.ResourceSyncer)
+	for _, syncer := range k.ResourceSyncers(ctx) {
+		syncers[syncer.ResourceType(ctx).Id] = syncer
+	}
+
+	principal := GenerateResourceForGrant("admin", ResourceTypeClusterRole.Id)
+	rules := []rbacv1.PolicyRule{{Verbs: []string{"*"}, APIGroups: []string{"*"}, Resources: []string{"*"}}}
+	grants, err := expandPolicyRules(ctx, nil, principal, clusterRoleRuleScope(nil), rules, ConnectorOpts{})
+	require.NoError(t, err)
+
+	byType := make(map[string][]string)
+	for _, g := range grants {
+		require.Equal(t, "*", g.Entitlement.Resource.Id.Resource, g.Entitlement.Id)
+		resourceType := g.Entitlement.Resource.Id.ResourceType
+		byType[resourceType] = append(byType[resourceType], g.Entitlement.Id)
+	}
+	require.Len(t, byType, len(ruleTargets))
+
+	for resourceType, grantedIDs := range byType {
+		syncer, ok := syncers[resourceType]
+		require.True(t, ok, resourceType)
+		wildcard, err := generateWildcardResource(syncer.ResourceType(ctx), "")
+		require.NoError(t, err)
+		entitlements, _, _, err := syncer.Entitlements(ctx, wildcard, &pagination.Token{})
+		require.NoError(t, err)
+
+		var entitlementIDs []string
+		for _, ent := range entitlements {
+			entitlementIDs = append(entitlementIDs, ent.Id)
+		}
+		assert.Subset(t, entitlementIDs, grantedIDs, resourceType)
+	}
+}
+
+// TestExpandPolicyRules_ResourceNamesSkipWildcard tests that rules limited to named objects never grant the
+// wildcard resource, for Roles and ClusterRoles alike.
+func TestExpandPolicyRules_ResourceNamesSkipWildcard(t *testing.T) {
+	ctx := context.Background()
+	rules := []rbacv1.PolicyRule{
+		{Verbs: []string{"get", "list"}, APIGroups: []string{""}, Resources: []string{"secrets"}, ResourceNames: []string{"db-password"}},
+		{Verbs: []string{"*"}, APIGroups: []string{"*"}, Resources: []string{"*"}, ResourceNames: []string{"web"}},
+	}
+
+	for _, tc := range []struct {
+		principal *v2.Resource
+		scope     ruleScope
+	}{
+		{GenerateResourceForGrant("payments/reader", ResourceTypeRole.Id), roleRuleScope("payments")},
+		{GenerateResourceForGrant("reader", ResourceTypeClusterRole.Id), clusterRoleRuleScope([]string{"payments", "staging"})},
+	} {
+		grants, err := expandPolicyRules(ctx, nil, tc.principal, tc.scope, rules, ConnectorOpts{})
+		require.NoError(t, err)
+		require.NotEmpty(t, grants)
+		ids := grantEntitlementIDs(grants)
+		assert.Contains(t, ids, "secret:payments/db-password:list")
+		for _, g := range grants {
+			assert.NotEqual(t, "*", g.Entitlement.Resource.Id.Resource, g.Entitlement.Id)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package connector
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -29,9 +30,13 @@ var standardResourceVerbs = []string{
 	"delete",
 }
 
-// secretBuilder syncs Kubernetes Secrets as Baton resources.
+// secretBuilder syncs Kubernetes Secrets as Baton resources. It's safe for concurrent use, the pods and
+// references it links secrets to coming from the connector's locked caches.
 type secretBuilder struct {
-	client kubernetes.Interface
+	client            kubernetes.Interface
+	podProvider       PodProvider
+	referenceProvider SecretReferenceProvider
+	opts              ConnectorOpts
 }
 
 // ResourceType returns the resource type for Secret.
@@ -43,41 +48,46 @@ func (s *secretBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
 func (s *secretBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
 	l := ctxzap.Extract(ctx)
 
-	// Initialize empty resource slice
-	var rv []*v2.Resource
-
 	// Parse pagination token
 	bag, err := ParsePageToken(pToken.Token)
 	if err != nil {
 		return nil, "", nil, fmt.Errorf("failed to parse page token: %w", err)
 	}
 
-	// Add wildcard resource first, but only on the first page (when page token is empty)
-	if bag.PageToken() == "" {
-		wildcardResource, err := generateWildcardResource(ResourceTypeSecret)
-		if err != nil {
-			l.Error("failed to create wildcard resource for secrets", zap.Error(err))
-		} else {
-			rv = append(rv, wildcardResource)
-		}
+	// List the namespace the SDK lists the children of, or all namespaces, after the wildcards
+	namespace, rv, done, err := startNamespacedList(ctx, s.client, s.opts, ResourceTypeSecret, parentResourceID, bag.PageToken(), nil)
+	if err != nil || done {
+		return rv, "", nil, err
 	}
 
 	// Set up list options with pagination
 	opts := metav1.ListOptions{
-		Limit:    ResourcesPageSize,
+		Limit:    s.opts.pageSize(ResourceTypeSecret.Id),
 		Continue: bag.PageToken(),
 	}
 
-	// Fetch secrets from the Kubernetes API across all namespaces
+	// Fetch secrets from the Kubernetes API in the namespace, or across all namespaces
 	l.Debug("fetching secrets", zap.String("continue_token", opts.Continue))
-	resp, err := s.client.CoreV1().Secrets("").List(ctx, opts)
+	resp, err := listWithRetry(ctx, s.opts, func(ctx context.Context) (*corev1.SecretList, error) {
+		return s.client.CoreV1().Secrets(namespace).List(ctx, opts)
+	})
 	if err != nil {
 		return nil, "", nil, fmt.Errorf("failed to list secrets: %w", err)
 	}
 
 	// Process each secret into a Baton resource
 	for _, secret := range resp.Items {
-		resource, err := secretResource(&secret)
+		var sensitivity *secretSensitivityProfile
+		if s.opts.SecretSensitivity && s.referenceProvider != nil {
+			references, err := s.referenceProvider.GetSecretReferences(ctx, secret.Namespace)
+			if err != nil {
+				return nil, "", nil, fmt.Errorf("failed to get secret references: %w", err)
+			}
+			tier, reasons := secretSensitivity(&secret, references)
+			sensitivity = &secretSensitivityProfile{tier: tier, reasons: reasons}
+		}
+
+		resource, err := secretResource(&secret, sensitivity, s.opts)
 		if err != nil {
 			l.Error("failed to create secret resource",
 				zap.String("namespace", secret.Namespace),
@@ -97,10 +107,16 @@ func (s *secretBuilder) List(ctx context.Context, parentResourceID *v2.ResourceI
 	return rv, nextPageToken, nil, nil
 }
 
-// secretResource creates a Baton resource from a Kubernetes Secret.
-func secretResource(secret *corev1.Secret) (*v2.Resource, error) {
+// secretSensitivityProfile is the computed sensitivity of a secret recorded in its profile.
+type secretSensitivityProfile struct {
+	tier    string
+	reasons []string
+}
+
+// secretResource creates a Baton resource from a Kubernetes Secret, with its sensitivity if computed.
+func secretResource(secret *corev1.Secret, sensitivity *secretSensitivityProfile, opts ConnectorOpts) (*v2.Resource, error) {
 	// Create resource ID for the secret
-	resourceID := secret.Namespace + "/" + secret.Name
+	resourceID := namespacedName(secret.Namespace, secret.Name)
 
 	// Get parent namespace resource ID
 	parentID, err := NamespaceResourceID(secret.Namespace)
@@ -115,9 +131,22 @@ func secretResource(secret *corev1.Secret) (*v2.Resource, error) {
 		"uid":               string(secret.UID),
 		"creationTimestamp": secret.CreationTimestamp.String(),
 		"labels":            StringMapToAnyMap(secret.Labels),
-		"annotations":       StringMapToAnyMap(secret.Annotations),
+		"annotations":       profileAnnotations(secret.Annotations, opts, true),
 		"type":              string(secret.Type),
 	}
+	if secret.Type == corev1.SecretTypeServiceAccountToken {
+		profile["serviceAccountName"] = secret.Annotations[corev1.ServiceAccountNameKey]
+	}
+	if sensitivity != nil {
+		reasons := make([]any, 0, len(sensitivity.reasons))
+		for _, reason := range sensitivity.reasons {
+			reasons = append(reasons, reason)
+		}
+		profile["sensitivityTier"] = sensitivity.tier
+		profile["sensitivityReasons"] = reasons
+	}
+	addLabelTags(profile, secret.Labels, opts)
+	repairProfile(profile)
 
 	// Secret trait options
 	secretOptions := []rs.SecretTraitOption{
@@ -164,32 +193,94 @@ func secretResource(secret *corev1.Secret) (*v2.Resource, error) {
 func (s *secretBuilder) Entitlements(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
 	var entitlements []*v2.Entitlement
 
+	grantableTo := []*v2.ResourceType{ResourceTypeRole, ResourceTypeClusterRole}
+	secretType, _ := secretProfileType(resource)
+	serviceAccountToken := secretType == corev1.SecretTypeServiceAccountToken
+
 	// Add standard verb entitlements
-	for _, verb := range standardResourceVerbs {
+	for _, verb := range s.opts.resourceVerbs(ResourceTypeSecret.Id) {
+		// Pods mounting the secret read it as their service account, and a token secret is read by its service account
+		verbGrantableTo := grantableTo
+		if (s.opts.MountGrants || serviceAccountToken) && verb == mountGrantVerb {
+			verbGrantableTo = append(verbGrantableTo[:len(verbGrantableTo):len(verbGrantableTo)], ResourceTypeServiceAccount)
+		}
 		ent := entitlement.NewPermissionEntitlement(
 			resource,
 			verb,
 			entitlement.WithDisplayName(fmt.Sprintf("%s %s", verb, resource.DisplayName)),
 			entitlement.WithDescription(fmt.Sprintf("Grants %s permission on the %s secret", verb, resource.DisplayName)),
-			entitlement.WithGrantableTo(
-				ResourceTypeRole,
-				ResourceTypeClusterRole,
-			),
+			entitlement.WithGrantableTo(verbGrantableTo...),
 		)
 		entitlements = append(entitlements, ent)
 	}
 
+	// Add 'owns' entitlement, granted to the service account of a token secret
+	if serviceAccountToken {
+		ownsEnt := entitlement.NewAssignmentEntitlement(
+			resource,
+			SecretOwnsEntitlement,
+			entitlement.WithDisplayName(fmt.Sprintf("Owns %s", resource.DisplayName)),
+			entitlement.WithDescription(fmt.Sprintf("The service account whose long-lived token the %s secret holds", resource.DisplayName)),
+			entitlement.WithGrantableTo(ResourceTypeServiceAccount),
+		)
+		entitlements = append(entitlements, ownsEnt)
+	}
+
+	// Add 'pull_with' entitlement, granted to the service accounts pulling images with the secret
+	if isImagePullSecretType(secretType) {
+		pullWithEnt := entitlement.NewAssignmentEntitlement(
+			resource,
+			SecretPullWithEntitlement,
+			entitlement.WithDisplayName(fmt.Sprintf("Pull with %s", resource.DisplayName)),
+			entitlement.WithDescription(fmt.Sprintf("Service accounts whose pods pull images with the %s secret", resource.DisplayName)),
+			entitlement.WithGrantableTo(ResourceTypeServiceAccount),
+		)
+		entitlements = append(entitlements, pullWithEnt)
+	}
+
 	return entitlements, "", nil, nil
 }
 
-// Grants returns no grants for Secret resources.
+// Grants returns the get and owns grants of a service account token secret to its service account and, when
+// mount grants are enabled, get grants to the service accounts of the pods mounting the secret, which can read
+// it regardless of RBAC. Grants from roles are emitted by the role syncers.
 func (s *secretBuilder) Grants(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
-	return nil, "", nil, nil
+	if isWildcardResourceID(resource.Id.Resource) {
+		return nil, "", nil, nil
+	}
+
+	namespace, name, ok := strings.Cut(resource.Id.Resource, "/")
+	if !ok {
+		return nil, "", nil, fmt.Errorf("invalid secret resource ID format: %s", resource.Id.Resource)
+	}
+
+	var rv []*v2.Grant
+	if s.opts.MountGrants && s.podProvider != nil {
+		pods, err := s.podProvider.GetPodsInNamespace(ctx, namespace)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("failed to get pods: %w", err)
+		}
+		rv = append(rv, mountGrants(resource, namespace, pods, func(pod *corev1.Pod) bool {
+			return podMountRefs(pod).secrets[name]
+		})...)
+	}
+
+	// The get grant of the mounting pods records the pods, so it is kept over the token's
+	tokenGrants, err := serviceAccountTokenGrants(ctx, s.client, resource, namespace, name)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	rv = append(rv, tokenGrants...)
+
+	return s.opts.filterVerbGrants(resource, uniqueGrants(rv)), "", nil, nil
 }
 
 // newSecretBuilder creates a new secret builder.
-func newSecretBuilder(client kubernetes.Interface) *secretBuilder {
+func newSecretBuilder(client kubernetes.Interface, podProvider PodProvider, referenceProvider SecretReferenceProvider, opts ConnectorOpts) *secretBuilder {
 	return &secretBuilder{
-		client: client,
+		client:            client,
+		podProvider:       podProvider,
+		referenceProvider: referenceProvider,
+		opts:              opts,
 	}
 }
@@ -0,0 +1,200 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Sensitivity tiers of secrets, computed from how they are referenced.
+const (
+	SecretSensitivityCritical = "critical"
+	SecretSensitivityHigh     = "high"
+	SecretSensitivityNormal   = "normal"
+)
+
+// References raising the sensitivity of a secret, recorded in its profile.
+const (
+	// SecretReferenceServiceAccountToken is a long-lived service account token, which authenticates as the
+	// service account.
+	SecretReferenceServiceAccountToken = "serviceAccountToken"
+	// SecretReferenceWebhookCA is a secret cert-manager injects the CA bundle of an admission webhook from,
+	// which can be used to impersonate the webhook.
+	SecretReferenceWebhookCA = "webhookCA"
+	// SecretReferenceIngressTLS is the TLS certificate and key of an Ingress.
+	SecretReferenceIngressTLS = "ingressTLS"
+	// SecretReferenceWorkload is a secret pods mount or read into environment variables.
+	SecretReferenceWorkload = "workload"
+)
+
+// certManagerInjectCAFromSecretAnnotation names the <namespace>/<name> secret cert-manager injects the CA bundle
+// of a webhook configuration from.
+const certManagerInjectCAFromSecretAnnotation = "cert-manager.io/inject-ca-from-secret"
+
+// secretReferenceTiers maps each reference to the tier it puts a secret in.
+var secretReferenceTiers = map[string]string{
+	SecretReferenceServiceAccountToken: SecretSensitivityCritical,
+	SecretReferenceWebhookCA:           SecretSensitivityCritical,
+	SecretReferenceIngressTLS:          SecretSensitivityHigh,
+	SecretReferenceWorkload:            SecretSensitivityHigh,
+}
+
+// secretSensitivity returns the sensitivity tier of a secret and the references it derives from, given the
+// references to the secrets of its namespace keyed by name. Unreferenced secrets are normal.
+func secretSensitivity(secret *corev1.Secret, references map[string][]string) (string, []string) {
+	reasons := append([]string(nil), references[secret.Name]...)
+	if secret.Type == corev1.SecretTypeServiceAccountToken {
+		reasons = append(reasons, SecretReferenceServiceAccountToken)
+	}
+	reasons = uniqueSorted(reasons)
+
+	tier := SecretSensitivityNormal
+	for _, reason := range reasons {
+		switch secretReferenceTiers[reason] {
+		case SecretSensitivityCritical:
+			tier = SecretSensitivityCritical
+		case SecretSensitivityHigh:
+			if tier == SecretSensitivityNormal {
+				tier = SecretSensitivityHigh
+			}
+		}
+	}
+	return tier, reasons
+}
+
+// GetSecretReferences returns the references to the secrets of a namespace from Ingress TLS blocks, the pods of
+// the namespace and webhook configurations, keyed by secret name. They are loaded once per namespace.
+func (k *Kubernetes) GetSecretReferences(ctx context.Context, namespace string) (map[string][]string, error) {
+	k.secretRefsMutex.Lock()
+	loaded, ok := k.secretRefsCache[namespace]
+	k.secretRefsMutex.Unlock()
+	if ok {
+		return loaded, nil
+	}
+
+	ctxzap.Extract(ctx).Debug("loading secret references", zap.String("namespace", namespace))
+
+	references := make(map[string][]string)
+	add := func(name, reason string) {
+		if name != "" {
+			references[name] = append(references[name], reason)
+		}
+	}
+
+	webhookCAs, err := k.webhookCASecrets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range webhookCAs[namespace] {
+		add(name, SecretReferenceWebhookCA)
+	}
+
+	continueToken := ""
+	for {
+		resp, err := k.client.NetworkingV1().Ingresses(namespace).List(ctx, metav1.ListOptions{
+			Limit:    ResourcesPageSize,
+			Continue: continueToken,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("listing ingresses in namespace %s: %w", namespace, err)
+		}
+		for _, ingress := range resp.Items {
+			for _, tls := range ingress.Spec.TLS {
+				add(tls.SecretName, SecretReferenceIngressTLS)
+			}
+		}
+		if resp.Continue == "" {
+			break
+		}
+		continueToken = resp.Continue
+	}
+
+	pods, err := k.GetPodsInNamespace(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	for i := range pods {
+		for name := range podMountRefs(&pods[i]).secrets {
+			add(name, SecretReferenceWorkload)
+		}
+	}
+
+	for name, reasons := range references {
+		references[name] = uniqueSorted(reasons)
+	}
+
+	k.secretRefsMutex.Lock()
+	defer k.secretRefsMutex.Unlock()
+	if k.secretRefsCache == nil {
+		k.secretRefsCache = make(map[string]map[string][]string)
+	}
+	k.secretRefsCache[namespace] = references
+	return references, nil
+}
+
+// webhookCASecrets returns the secrets cert-manager injects the CA bundles of the validating and mutating
+// webhook configurations from, as secret names keyed by namespace. They are loaded once.
+func (k *Kubernetes) webhookCASecrets(ctx context.Context) (map[string][]string, error) {
+	k.secretRefsMutex.Lock()
+	defer k.secretRefsMutex.Unlock()
+	if k.webhookCASecretsCache != nil {
+		return k.webhookCASecretsCache, nil
+	}
+
+	rv := make(map[string][]string)
+	add := func(annotations map[string]string) {
+		namespace, name, ok := strings.Cut(annotations[certManagerInjectCAFromSecretAnnotation], "/")
+		if ok && namespace != "" && name != "" {
+			rv[namespace] = append(rv[namespace], name)
+		}
+	}
+
+	continueToken := ""
+	for {
+		resp, err := k.client.AdmissionregistrationV1().ValidatingWebhookConfigurations().List(ctx, metav1.ListOptions{
+			Limit:    ResourcesPageSize,
+			Continue: continueToken,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("listing validating webhook configurations: %w", err)
+		}
+		for _, config := range resp.Items {
+			add(config.Annotations)
+		}
+		if resp.Continue == "" {
+			break
+		}
+		continueToken = resp.Continue
+	}
+
+	continueToken = ""
+	for {
+		resp, err := k.client.AdmissionregistrationV1().MutatingWebhookConfigurations().List(ctx, metav1.ListOptions{
+			Limit:    ResourcesPageSize,
+			Continue: continueToken,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("listing mutating webhook configurations: %w", err)
+		}
+		for _, config := range resp.Items {
+			add(config.Annotations)
+		}
+		if resp.Continue == "" {
+			break
+		}
+		continueToken = resp.Continue
+	}
+
+	for namespace, names := range rv {
+		sort.Strings(names)
+		rv[namespace] = names
+	}
+	k.webhookCASecretsCache = rv
+	return rv, nil
+}
@@ -0,0 +1,136 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// sensitivityClient returns a client with a secret for each reference source and an unreferenced one.
+func sensitivityClient() *fake.Clientset {
+	secret := func(name string, secretType corev1.SecretType) *corev1.Secret {
+		return &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "web", Name: name}, Type: secretType}
+	}
+	return fake.NewSimpleClientset(
+		secret("site-tls", corev1.SecretTypeTLS),
+		secret("db-creds", corev1.SecretTypeOpaque),
+		secret("webhook-ca", corev1.SecretTypeTLS),
+		secret("injector-ca", corev1.SecretTypeTLS),
+		secret("deployer-token", corev1.SecretTypeServiceAccountToken),
+		secret("unused", corev1.SecretTypeOpaque),
+		&networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "web", Name: "site"},
+			Spec:       networkingv1.IngressSpec{TLS: []networkingv1.IngressTLS{{SecretName: "site-tls"}}},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "web", Name: "api"},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{
+				Name: "api",
+				Env: []corev1.EnvVar{{Name: "DB_PASSWORD", ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "db-creds"}, Key: "password"},
+				}}},
+			}}},
+		},
+		&admissionregistrationv1.ValidatingWebhookConfiguration{ObjectMeta: metav1.ObjectMeta{
+			Name:        "policy",
+			Annotations: map[string]string{certManagerInjectCAFromSecretAnnotation: "web/webhook-ca"},
+		}},
+		&admissionregistrationv1.MutatingWebhookConfiguration{ObjectMeta: metav1.ObjectMeta{
+			Name:        "injector",
+			Annotations: map[string]string{certManagerInjectCAFromSecretAnnotation: "web/injector-ca"},
+		}},
+	)
+}
+
+// syncedSensitivity returns the sensitivity tier and reasons in the profiles of the synced secrets.
+func syncedSensitivity(ctx context.Context, t *testing.T, builder *secretBuilder) (map[string]string, map[string][]any) {
+	tiers := make(map[string]string)
+	reasons := make(map[string][]any)
+	for _, resource := range listResources(ctx, t, builder, "web") {
+		if resource.Id.Resource == "*" {
+			continue
+		}
+		trait := &v2.SecretTrait{}
+		annos := annotations.Annotations(resource.Annotations)
+		ok, err := annos.Pick(trait)
+		require.NoError(t, err)
+		require.True(t, ok)
+		fields := trait.GetProfile().GetFields()
+		if tier, ok := fields["sensitivityTier"]; ok {
+			tiers[resource.Id.Resource] = tier.GetStringValue()
+			reasons[resource.Id.Resource] = fields["sensitivityReasons"].GetListValue().AsSlice()
+		}
+	}
+	return tiers, reasons
+}
+
+func TestSecretSensitivity(t *testing.T) {
+	ctx := context.Background()
+	client := sensitivityClient()
+	ingressLists := 0
+	client.PrependReactor("list", "ingresses", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		ingressLists++
+		return false, nil, nil
+	})
+
+	opts := ConnectorOpts{SecretSensitivity: true}
+	k := newTestKubernetes(client, opts)
+	tiers, reasons := syncedSensitivity(ctx, t, newSecretBuilder(client, k, k, opts))
+
+	assert.Equal(t, map[string]string{
+		"web/site-tls":       SecretSensitivityHigh,
+		"web/db-creds":       SecretSensitivityHigh,
+		"web/webhook-ca":     SecretSensitivityCritical,
+		"web/injector-ca":    SecretSensitivityCritical,
+		"web/deployer-token": SecretSensitivityCritical,
+		"web/unused":         SecretSensitivityNormal,
+	}, tiers)
+	assert.Equal(t, map[string][]any{
+		"web/site-tls":       {SecretReferenceIngressTLS},
+		"web/db-creds":       {SecretReferenceWorkload},
+		"web/webhook-ca":     {SecretReferenceWebhookCA},
+		"web/injector-ca":    {SecretReferenceWebhookCA},
+		"web/deployer-token": {SecretReferenceServiceAccountToken},
+		"web/unused":         {},
+	}, reasons)
+
+	// The references are loaded once per namespace
+	assert.Equal(t, 1, ingressLists)
+
+	// Without the option no tier is computed
+	tiers, _ = syncedSensitivity(ctx, t, newSecretBuilder(client, k, k, ConnectorOpts{}))
+	assert.Empty(t, tiers)
+}
+
+func TestSecretSensitivityTier(t *testing.T) {
+	tests := []struct {
+		name       string
+		secretType corev1.SecretType
+		references []string
+		want       string
+	}{
+		{"unreferenced", corev1.SecretTypeOpaque, nil, SecretSensitivityNormal},
+		{"workload", corev1.SecretTypeOpaque, []string{SecretReferenceWorkload}, SecretSensitivityHigh},
+		{"highest reference wins", corev1.SecretTypeTLS, []string{SecretReferenceIngressTLS, SecretReferenceWebhookCA}, SecretSensitivityCritical},
+		{"mounted token", corev1.SecretTypeServiceAccountToken, []string{SecretReferenceWorkload}, SecretSensitivityCritical},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "s"}, Type: tt.secretType}
+			tier, _ := secretSensitivity(secret, map[string][]string{"s": tt.references})
+			assert.Equal(t, tt.want, tier)
+		})
+	}
+}
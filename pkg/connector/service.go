@@ -0,0 +1,259 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// serviceBuilder syncs Kubernetes Services as Baton resources. It's stateless and safe for concurrent use.
+type serviceBuilder struct {
+	client kubernetes.Interface
+	opts   ConnectorOpts
+}
+
+// ResourceType returns the resource type for Service.
+func (s *serviceBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
+	return ResourceTypeService
+}
+
+// List fetches all Services from the Kubernetes API, resolving the backends of selector-less Services.
+func (s *serviceBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+
+	// Parse pagination token
+	bag, err := ParsePageToken(pToken.Token)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to parse page token: %w", err)
+	}
+
+	// List the namespace the SDK lists the children of, or all namespaces, after the wildcards
+	namespace, rv, done, err := startNamespacedList(ctx, s.client, s.opts, ResourceTypeService, parentResourceID, bag.PageToken(), nil)
+	if err != nil || done {
+		return rv, "", nil, err
+	}
+
+	// Set up list options with pagination
+	opts := metav1.ListOptions{
+		Limit:    s.opts.pageSize(ResourceTypeService.Id),
+		Continue: bag.PageToken(),
+	}
+
+	// Fetch services from the Kubernetes API in the namespace, or across all namespaces
+	l.Debug("fetching services", zap.String("continue_token", opts.Continue))
+	resp, err := listWithRetry(ctx, s.opts, func(ctx context.Context) (*corev1.ServiceList, error) {
+		return s.client.CoreV1().Services(namespace).List(ctx, opts)
+	})
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to list services: %w", err)
+	}
+
+	// Process each service into a Baton resource
+	for _, svc := range resp.Items {
+		var backends []string
+		if isExternalBackendService(&svc) {
+			backends, err = s.externalBackends(ctx, &svc)
+			if err != nil {
+				return nil, "", nil, err
+			}
+		}
+
+		resource, err := serviceResource(&svc, backends, s.opts)
+		if err != nil {
+			l.Error("failed to create service resource",
+				zap.String("namespace", svc.Namespace),
+				zap.String("name", svc.Name),
+				zap.Error(err))
+			continue
+		}
+		rv = append(rv, resource)
+	}
+
+	// Calculate next page token
+	nextPageToken, err := HandleKubePagination(&resp.ListMeta, bag)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to handle pagination: %w", err)
+	}
+
+	return rv, nextPageToken, nil, nil
+}
+
+// isExternalBackendService reports whether the backends of a Service are managed by hand rather than
+// selected from pods, so whoever can edit it can redirect its traffic to arbitrary addresses.
+func isExternalBackendService(svc *corev1.Service) bool {
+	if svc.Spec.Type == corev1.ServiceTypeExternalName {
+		return true
+	}
+	return len(svc.Spec.Selector) == 0
+}
+
+// externalBackends returns the sorted backend addresses of a selector-less Service, taken from its
+// EndpointSlices, or the external name of an ExternalName Service.
+func (s *serviceBuilder) externalBackends(ctx context.Context, svc *corev1.Service) ([]string, error) {
+	if svc.Spec.Type == corev1.ServiceTypeExternalName {
+		return []string{svc.Spec.ExternalName}, nil
+	}
+
+	seen := make(map[string]bool)
+	var backends []string
+
+	opts := metav1.ListOptions{
+		LabelSelector: discoveryv1.LabelServiceName + "=" + svc.Name,
+		Limit:         ResourcesPageSize,
+	}
+	for {
+		resp, err := s.client.DiscoveryV1().EndpointSlices(svc.Namespace).List(ctx, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list endpoint slices for service %s/%s: %w", svc.Namespace, svc.Name, err)
+		}
+
+		for _, slice := range resp.Items {
+			for _, backend := range endpointSliceBackends(&slice) {
+				if !seen[backend] {
+					seen[backend] = true
+					backends = append(backends, backend)
+				}
+			}
+		}
+
+		if resp.Continue == "" {
+			break
+		}
+		opts.Continue = resp.Continue
+	}
+
+	sort.Strings(backends)
+	return backends, nil
+}
+
+// endpointSliceBackends returns the "address:port" backends of an EndpointSlice, or the bare addresses if
+// it has no ports.
+func endpointSliceBackends(slice *discoveryv1.EndpointSlice) []string {
+	var rv []string
+	for _, endpoint := range slice.Endpoints {
+		for _, address := range endpoint.Addresses {
+			if len(slice.Ports) == 0 {
+				rv = append(rv, address)
+				continue
+			}
+			for _, port := range slice.Ports {
+				if port.Port == nil {
+					rv = append(rv, address)
+					continue
+				}
+				rv = append(rv, net.JoinHostPort(address, strconv.Itoa(int(*port.Port))))
+			}
+		}
+	}
+	return rv
+}
+
+// serviceResource creates a Baton resource from a Kubernetes Service and the backends of a Service without
+// a selector.
+func serviceResource(svc *corev1.Service, backends []string, opts ConnectorOpts) (*v2.Resource, error) {
+	// Get parent namespace resource ID
+	parentID, err := NamespaceResourceID(svc.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create parent resource ID: %w", err)
+	}
+
+	// Create resource options with simplified description
+	options := []rs.ResourceOption{
+		rs.WithParentResourceID(parentID),
+		rs.WithDescription(fmt.Sprintf("Service in namespace %s", svc.Namespace)),
+	}
+
+	// Add external ID if available
+	if len(svc.UID) > 0 {
+		options = append(options, rs.WithExternalID(&v2.ExternalId{Id: string(svc.UID)}))
+	}
+
+	// Services don't have a trait, so the profile is attached as a struct annotation
+	externalBackend := isExternalBackendService(svc)
+	profile := map[string]any{
+		"type":            string(svc.Spec.Type),
+		"externalBackend": externalBackend,
+	}
+	if externalBackend {
+		backendValues := make([]any, 0, len(backends))
+		for _, backend := range backends {
+			backendValues = append(backendValues, backend)
+		}
+		profile["backends"] = backendValues
+	}
+	addLabelTags(profile, svc.Labels, opts)
+	repairProfile(profile)
+
+	profileStruct, err := structpb.NewStruct(profile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create service profile: %w", err)
+	}
+	options = append(options, rs.WithAnnotation(profileStruct))
+
+	// Create the raw ID as namespace/name
+	rawID := namespacedName(svc.Namespace, svc.Name)
+
+	// Create resource
+	resource, err := rs.NewResource(
+		svc.Name,
+		ResourceTypeService,
+		rawID, // Pass the raw ID directly
+		options...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create service resource: %w", err)
+	}
+
+	return resource, nil
+}
+
+// Entitlements returns standard verb entitlements for Service resources.
+func (s *serviceBuilder) Entitlements(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	var entitlements []*v2.Entitlement
+
+	// Add standard verb entitlements
+	for _, verb := range s.opts.resourceVerbs(ResourceTypeService.Id) {
+		ent := entitlement.NewPermissionEntitlement(
+			resource,
+			verb,
+			entitlement.WithDisplayName(fmt.Sprintf("%s %s", verb, resource.DisplayName)),
+			entitlement.WithDescription(fmt.Sprintf("Grants %s permission on the %s service", verb, resource.DisplayName)),
+			entitlement.WithGrantableTo(
+				ResourceTypeRole,
+				ResourceTypeClusterRole,
+			),
+		)
+		entitlements = append(entitlements, ent)
+	}
+
+	return entitlements, "", nil, nil
+}
+
+// Grants returns no grants for Service resources.
+func (s *serviceBuilder) Grants(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+// newServiceBuilder creates a new service builder.
+func newServiceBuilder(client kubernetes.Interface, opts ConnectorOpts) *serviceBuilder {
+	return &serviceBuilder{
+		client: client,
+		opts:   opts,
+	}
+}
@@ -0,0 +1,79 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
+)
+
+// TestServiceBuilderList_ExternalBackends tests that selector-less Services are flagged with the backends
+// of their manually managed endpoints, while Services selecting pods are not.
+func TestServiceBuilderList_ExternalBackends(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "postgres", Namespace: "payments"},
+			Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeClusterIP},
+		},
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "payments"},
+			Spec: corev1.ServiceSpec{
+				Type:     corev1.ServiceTypeClusterIP,
+				Selector: map[string]string{"app": "api"},
+			},
+		},
+		&discoveryv1.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "postgres-1",
+				Namespace: "payments",
+				Labels:    map[string]string{discoveryv1.LabelServiceName: "postgres"},
+			},
+			AddressType: discoveryv1.AddressTypeIPv4,
+			Endpoints:   []discoveryv1.Endpoint{{Addresses: []string{"10.20.0.7"}}, {Addresses: []string{"10.20.0.5"}}},
+			Ports:       []discoveryv1.EndpointPort{{Port: ptr.To(int32(5432))}},
+		},
+		&discoveryv1.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "api-1",
+				Namespace: "payments",
+				Labels:    map[string]string{discoveryv1.LabelServiceName: "api"},
+			},
+			AddressType: discoveryv1.AddressTypeIPv4,
+			Endpoints:   []discoveryv1.Endpoint{{Addresses: []string{"10.0.0.9"}}},
+		},
+	)
+
+	builder := newServiceBuilder(client, ConnectorOpts{})
+	resources, _, _, err := builder.List(ctx, namespaceID("payments"), &pagination.Token{})
+	require.NoError(t, err)
+
+	profiles := make(map[string]map[string]any)
+	for _, resource := range resources {
+		if resource.Id.Resource == "*" {
+			continue
+		}
+		profile := &structpb.Struct{}
+		annos := annotations.Annotations(resource.Annotations)
+		ok, err := annos.Pick(profile)
+		require.NoError(t, err)
+		require.True(t, ok)
+		profiles[resource.Id.Resource] = profile.AsMap()
+	}
+	require.Len(t, profiles, 2)
+
+	assert.Equal(t, true, profiles["payments/postgres"]["externalBackend"])
+	assert.Equal(t, []any{"10.20.0.5:5432", "10.20.0.7:5432"}, profiles["payments/postgres"]["backends"])
+
+	assert.Equal(t, false, profiles["payments/api"]["externalBackend"])
+	assert.NotContains(t, profiles["payments/api"], "backends")
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 
@@ -17,9 +18,14 @@ import (
 	"go.uber.org/zap"
 )
 
-// serviceAccountBuilder syncs Kubernetes ServiceAccounts as Baton users.
+// serviceAccountBuilder syncs Kubernetes ServiceAccounts as Baton users. It's stateless and safe for
+// concurrent use, including the Lists of different namespaces.
 type serviceAccountBuilder struct {
 	client kubernetes.Interface
+	opts   ConnectorOpts
+	// bindings is set to skip the default service accounts no binding names
+	bindings ServiceAccountBindingChecker
+	stats    *syncStats
 }
 
 // ResourceType returns the resource type for ServiceAccount.
@@ -43,8 +49,8 @@ func (s *serviceAccountBuilder) List(ctx context.Context, parentResourceID *v2.R
 	}
 
 	// Add wildcard resource first, but only on the first page (when page token is empty)
-	if bag.PageToken() == "" {
-		wildcardResource, err := generateWildcardResource(ResourceTypeServiceAccount)
+	if bag.PageToken() == "" && !s.opts.DisableWildcardResources {
+		wildcardResource, err := generateWildcardResource(ResourceTypeServiceAccount, "")
 		if err != nil {
 			l.Error("failed to create wildcard resource for service accounts", zap.Error(err))
 		} else {
@@ -52,23 +58,44 @@ func (s *serviceAccountBuilder) List(ctx context.Context, parentResourceID *v2.R
 		}
 	}
 
+	// Service accounts are listed per namespace, so add the wildcard of the parent namespace only
+	if bag.PageToken() == "" && s.opts.namespaceWildcards() {
+		namespaceWildcard, err := generateWildcardResource(ResourceTypeServiceAccount, parentResourceID.Resource)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("failed to create wildcard resource for namespace %s: %w", parentResourceID.Resource, err)
+		}
+		rv = append(rv, namespaceWildcard)
+	}
+
 	// Set up list options with pagination
 	opts := metav1.ListOptions{
-		Limit:    ResourcesPageSize,
+		Limit:    s.opts.pageSize(ResourceTypeServiceAccount.Id),
 		Continue: bag.PageToken(),
 	}
 
 	// Fetch service accounts from the Kubernetes API for the parent namespace
 	l.Debug("fetching service accounts", zap.String("continue_token", opts.Continue))
 	parentNamespace := parentResourceID.Resource
-	resp, err := s.client.CoreV1().ServiceAccounts(parentNamespace).List(ctx, opts)
+	resp, err := listWithRetry(ctx, s.opts, func(ctx context.Context) (*corev1.ServiceAccountList, error) {
+		return s.client.CoreV1().ServiceAccounts(parentNamespace).List(ctx, opts)
+	})
 	if err != nil {
 		return nil, "", nil, fmt.Errorf("failed to list service accounts: %w", err)
 	}
 
 	// Process each service account into a Baton resource
 	for _, sa := range resp.Items {
-		resource, err := serviceAccountResource(&sa)
+		skip, err := s.skipDefaultServiceAccount(ctx, &sa)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		if skip {
+			l.Debug("skipping unused default service account", zap.String("namespace", sa.Namespace))
+			s.stats.Inc(StatDefaultServiceAccountsSkipped)
+			continue
+		}
+
+		resource, err := serviceAccountResource(&sa, s.opts)
 		if err != nil {
 			l.Error("failed to create service account resource",
 				zap.String("namespace", sa.Namespace),
@@ -88,8 +115,21 @@ func (s *serviceAccountBuilder) List(ctx context.Context, parentResourceID *v2.R
 	return rv, nextPageToken, nil, nil
 }
 
+// skipDefaultServiceAccount reports whether a service account is a default one to leave out of the sync: it has
+// no secrets or image pull secrets, and no binding names it, so that bound ones are still synced.
+func (s *serviceAccountBuilder) skipDefaultServiceAccount(ctx context.Context, sa *corev1.ServiceAccount) (bool, error) {
+	if s.bindings == nil || sa.Name != defaultServiceAccountName || len(sa.Secrets) > 0 || len(sa.ImagePullSecrets) > 0 {
+		return false, nil
+	}
+	bound, err := s.bindings.IsServiceAccountBound(ctx, sa.Namespace, sa.Name)
+	if err != nil {
+		return false, fmt.Errorf("failed to check the bindings of service account %s: %w", namespacedName(sa.Namespace, sa.Name), err)
+	}
+	return !bound, nil
+}
+
 // serviceAccountResource creates a Baton resource from a Kubernetes ServiceAccount.
-func serviceAccountResource(serviceAccount *corev1.ServiceAccount) (*v2.Resource, error) {
+func serviceAccountResource(serviceAccount *corev1.ServiceAccount, opts ConnectorOpts) (*v2.Resource, error) {
 	// Prepare profile with standard metadata
 	profile := map[string]interface{}{
 		"name":              serviceAccount.Name,
@@ -97,12 +137,12 @@ func serviceAccountResource(serviceAccount *corev1.ServiceAccount) (*v2.Resource
 		"uid":               string(serviceAccount.UID),
 		"creationTimestamp": serviceAccount.CreationTimestamp.String(),
 		"labels":            StringMapToAnyMap(serviceAccount.Labels),
-		"annotations":       StringMapToAnyMap(serviceAccount.Annotations),
+		"annotations":       profileAnnotations(serviceAccount.Annotations, opts, false),
 	}
 
-	// Add secrets if present
+	// Add secrets if present. Profile lists must be []any to convert to a protobuf struct
 	if len(serviceAccount.Secrets) > 0 {
-		secretNames := make([]string, 0, len(serviceAccount.Secrets))
+		secretNames := make([]any, 0, len(serviceAccount.Secrets))
 		for _, secret := range serviceAccount.Secrets {
 			secretNames = append(secretNames, secret.Name)
 		}
@@ -111,12 +151,14 @@ func serviceAccountResource(serviceAccount *corev1.ServiceAccount) (*v2.Resource
 
 	// Add image pull secrets if present
 	if len(serviceAccount.ImagePullSecrets) > 0 {
-		secretNames := make([]string, 0, len(serviceAccount.ImagePullSecrets))
+		secretNames := make([]any, 0, len(serviceAccount.ImagePullSecrets))
 		for _, secret := range serviceAccount.ImagePullSecrets {
 			secretNames = append(secretNames, secret.Name)
 		}
 		profile["imagePullSecrets"] = secretNames
 	}
+	addLabelTags(profile, serviceAccount.Labels, opts)
+	repairProfile(profile)
 
 	// Get parent namespace resource ID
 	parentID, err := NamespaceResourceID(serviceAccount.Namespace)
@@ -125,7 +167,13 @@ func serviceAccountResource(serviceAccount *corev1.ServiceAccount) (*v2.Resource
 	}
 
 	// Unique ID is namespace/name
-	rawID := serviceAccount.Namespace + "/" + serviceAccount.Name
+	rawID := namespacedName(serviceAccount.Namespace, serviceAccount.Name)
+
+	// Correlate the service account across renames by its UID
+	resourceOpts := []rs.ResourceOption{rs.WithParentResourceID(parentID)}
+	if len(serviceAccount.UID) > 0 {
+		resourceOpts = append(resourceOpts, rs.WithExternalID(&v2.ExternalId{Id: string(serviceAccount.UID)}))
+	}
 
 	// Create resource with parent namespace
 	resource, err := rs.NewUserResource(
@@ -136,7 +184,7 @@ func serviceAccountResource(serviceAccount *corev1.ServiceAccount) (*v2.Resource
 			rs.WithUserProfile(profile),
 			rs.WithAccountType(v2.UserTrait_ACCOUNT_TYPE_SERVICE),
 		},
-		rs.WithParentResourceID(parentID),
+		resourceOpts...,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create service account resource: %w", err)
@@ -159,17 +207,67 @@ func (s *serviceAccountBuilder) Entitlements(_ context.Context, resource *v2.Res
 		),
 	)
 
-	return []*v2.Entitlement{impersonateEnt}, "", nil, nil
+	if isWildcardResourceID(resource.Id.Resource) {
+		return []*v2.Entitlement{impersonateEnt}, "", nil, nil
+	}
+
+	// Add 'runs_as' entitlement, granted to the pods and workloads running as the service account
+	runsAsEnt := entitlement.NewAssignmentEntitlement(
+		resource,
+		ServiceAccountRunsAsEntitlement,
+		entitlement.WithDisplayName(fmt.Sprintf("Runs as %s", resource.DisplayName)),
+		entitlement.WithDescription(fmt.Sprintf("Pods and workloads whose pods run as the %s service account", resource.DisplayName)),
+		entitlement.WithGrantableTo(workloadResourceTypes...),
+	)
+
+	return []*v2.Entitlement{impersonateEnt, runsAsEnt}, "", nil, nil
+}
+
+// Grants returns the pull_with grants of the image pull secrets of the service account to it.
+func (s *serviceAccountBuilder) Grants(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	rv, err := imagePullSecretGrants(ctx, s.client, resource)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return rv, "", nil, nil
 }
 
-// Grants returns no grants for ServiceAccount resources.
-func (s *serviceAccountBuilder) Grants(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
-	return nil, "", nil, nil
+// IsServiceAccountBound reports whether a RoleBinding or ClusterRoleBinding names the service account as a
+// subject, directly or by its user name.
+func (k *Kubernetes) IsServiceAccountBound(ctx context.Context, namespace, name string) (bool, error) {
+	if err := k.loadBindingsCaches(ctx); err != nil {
+		return false, fmt.Errorf("failed to load bindings cache: %w", err)
+	}
+
+	k.bindingsMutex.RLock()
+	defer k.bindingsMutex.RUnlock()
+
+	sa := rbacv1.Subject{Kind: SubjectKindServiceAccount, Namespace: namespace, Name: name}
+	for _, rb := range k.roleBindingsCache {
+		for _, subject := range rb.Subjects {
+			// Subjects of RoleBindings default to the namespace of the binding
+			if subject.Kind == SubjectKindServiceAccount && subject.Namespace == "" {
+				subject.Namespace = rb.Namespace
+			}
+			if sameSubject(subject, sa) {
+				return true, nil
+			}
+		}
+	}
+	for _, crb := range k.clusterRoleBindingsCache {
+		for _, subject := range crb.Subjects {
+			if sameSubject(subject, sa) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
 }
 
 // newServiceAccountBuilder creates a new service account builder.
-func newServiceAccountBuilder(client kubernetes.Interface) *serviceAccountBuilder {
+func newServiceAccountBuilder(client kubernetes.Interface, opts ConnectorOpts) *serviceAccountBuilder {
 	return &serviceAccountBuilder{
 		client: client,
+		opts:   opts,
 	}
 }
@@ -0,0 +1,90 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// TestServiceAccountBuilderList_SkipDefault tests that unused default service accounts are left out, while those
+// with secrets or named by a binding are still synced.
+func TestServiceAccountBuilderList_SkipDefault(t *testing.T) {
+	ctx := context.Background()
+	defaultSA := func(namespace string) *corev1.ServiceAccount {
+		return &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: defaultServiceAccountName}}
+	}
+	withPullSecret := defaultSA("registry")
+	withPullSecret.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "registry-credentials"}}
+
+	client := fake.NewSimpleClientset(
+		defaultSA("unused"),
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: "unused", Name: "deployer"}},
+		withPullSecret,
+		defaultSA("bound"),
+		defaultSA("bound-by-username"),
+		defaultSA("cluster-bound"),
+		// A subject without a namespace is in the namespace of the RoleBinding
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "bound", Name: "default-view"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindClusterRole, Name: "view"},
+			Subjects:   []rbacv1.Subject{{Kind: SubjectKindServiceAccount, Name: defaultServiceAccountName}},
+		},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "bound-by-username", Name: "default-view"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindClusterRole, Name: "view"},
+			Subjects:   []rbacv1.Subject{{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "system:serviceaccount:bound-by-username:default"}},
+		},
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "default-view"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindClusterRole, Name: "view"},
+			Subjects:   []rbacv1.Subject{{Kind: SubjectKindServiceAccount, Namespace: "cluster-bound", Name: defaultServiceAccountName}},
+		},
+	)
+	opts := ConnectorOpts{DisableWildcardResources: true, SkipDefaultServiceAccounts: true}
+	k := newTestKubernetes(client, opts)
+	builder := newServiceAccountBuilder(client, opts)
+	builder.bindings = k
+	builder.stats = k.stats
+
+	var synced []string
+	for _, namespace := range []string{"unused", "registry", "bound", "bound-by-username", "cluster-bound"} {
+		resources, _, _, err := builder.List(ctx, &v2.ResourceId{ResourceType: ResourceTypeNamespace.Id, Resource: namespace}, &pagination.Token{})
+		require.NoError(t, err)
+		for _, resource := range resources {
+			synced = append(synced, resource.Id.Resource)
+		}
+	}
+
+	assert.Equal(t, []string{
+		"unused/deployer",
+		"registry/default",
+		"bound/default",
+		"bound-by-username/default",
+		"cluster-bound/default",
+	}, synced)
+	assert.Equal(t, int64(1), k.SyncStats()[StatDefaultServiceAccountsSkipped])
+}
+
+// TestServiceAccountBuilderList_KeepDefault tests that default service accounts are synced unless the option
+// is enabled.
+func TestServiceAccountBuilderList_KeepDefault(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: "unused", Name: defaultServiceAccountName, UID: "sa-uid"}},
+	)
+	builder := newServiceAccountBuilder(client, ConnectorOpts{DisableWildcardResources: true})
+
+	resources, _, _, err := builder.List(ctx, &v2.ResourceId{ResourceType: ResourceTypeNamespace.Id, Resource: "unused"}, &pagination.Token{})
+	require.NoError(t, err)
+	require.Len(t, resources, 1)
+	assert.Equal(t, "unused/default", resources[0].Id.Resource)
+	assert.Equal(t, "sa-uid", resources[0].ExternalId.GetId())
+}
@@ -0,0 +1,130 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// ServiceAccountsGroup is the group every service account belongs to.
+	ServiceAccountsGroup = "system:serviceaccounts"
+	// serviceAccountsGroupPrefix prefixes the groups of the service accounts of a namespace.
+	serviceAccountsGroupPrefix = ServiceAccountsGroup + ":"
+)
+
+// namespaceCacheTTL is how long the service accounts of the namespaces are cached for group expansion.
+const namespaceCacheTTL = 5 * time.Minute
+
+// serviceAccountGroupNamespace returns the namespace whose service accounts are the members of a well-known
+// service account group, or "" for all namespaces. It reports false if the group isn't one.
+func serviceAccountGroupNamespace(group string) (string, bool) {
+	if group == ServiceAccountsGroup {
+		return "", true
+	}
+	namespace, ok := strings.CutPrefix(group, serviceAccountsGroupPrefix)
+	if !ok || namespace == "" {
+		return "", false
+	}
+	return namespace, true
+}
+
+// serviceAccountGroupExpander turns grants to the system:serviceaccounts groups into grants to the service
+// accounts in them, caching the service accounts of each namespace. A nil *serviceAccountGroupExpander is
+// valid and expands nothing.
+type serviceAccountGroupExpander struct {
+	client kubernetes.Interface
+
+	mu          sync.Mutex
+	members     map[string][]string // namespace ("" for all) -> service account IDs
+	cacheExpiry time.Time
+}
+
+// newServiceAccountGroupExpander creates an expander if the connector is configured to expand service
+// account groups, and returns nil otherwise.
+func newServiceAccountGroupExpander(client kubernetes.Interface, opts ConnectorOpts) *serviceAccountGroupExpander {
+	if !opts.ExpandServiceAccountGroups {
+		return nil
+	}
+	return &serviceAccountGroupExpander{
+		client:  client,
+		members: make(map[string][]string),
+	}
+}
+
+// expand returns grants of the named entitlement to every service account in a service account group
+// subject, recording the binding and the group in the grant metadata. Other subjects yield no grants.
+func (e *serviceAccountGroupExpander) expand(
+	ctx context.Context,
+	subject rbacv1.Subject,
+	resource *v2.Resource,
+	entName string,
+	bindingKind string,
+	bindingMeta metav1.ObjectMeta,
+) ([]*v2.Grant, error) {
+	if e == nil || subject.Kind != SubjectKindGroup {
+		return nil, nil
+	}
+	namespace, ok := serviceAccountGroupNamespace(subject.Name)
+	if !ok {
+		return nil, nil
+	}
+
+	members, err := e.serviceAccounts(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := bindingGrantMetadata(bindingKind, bindingMeta)
+	metadata[GrantMetadataViaGroup] = subject.Name
+	metadata[GrantMetadataSubjectKind] = SubjectKindServiceAccount
+
+	rv := make([]*v2.Grant, 0, len(members))
+	for _, id := range members {
+		saResource := GenerateResourceForGrant(id, ResourceTypeServiceAccount.Id)
+		rv = append(rv, grant.NewGrant(resource, entName, saResource, grant.WithGrantMetadata(metadata)))
+	}
+	return rv, nil
+}
+
+// serviceAccounts returns the IDs of the service accounts in the namespace, or in all namespaces for "".
+func (e *serviceAccountGroupExpander) serviceAccounts(ctx context.Context, namespace string) ([]string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := time.Now()
+	if now.After(e.cacheExpiry) {
+		e.members = make(map[string][]string)
+		e.cacheExpiry = now.Add(namespaceCacheTTL)
+	}
+	if ids, ok := e.members[namespace]; ok {
+		return ids, nil
+	}
+
+	ids := make([]string, 0)
+	opts := metav1.ListOptions{Limit: ResourcesPageSize}
+	for {
+		resp, err := e.client.CoreV1().ServiceAccounts(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list service accounts for group expansion: %w", err)
+		}
+		for _, sa := range resp.Items {
+			ids = append(ids, sa.Namespace+"/"+sa.Name)
+		}
+		if resp.Continue == "" {
+			break
+		}
+		opts.Continue = resp.Continue
+	}
+
+	e.members[namespace] = ids
+	return ids, nil
+}
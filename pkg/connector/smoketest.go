@@ -0,0 +1,220 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Steps of the smoke test, in the order they run.
+const (
+	SmokeTestStepValidate            = "validate"
+	SmokeTestStepNamespaces          = "namespaces"
+	SmokeTestStepRoles               = "roles"
+	SmokeTestStepRoleBindings        = "role_bindings"
+	SmokeTestStepClusterRoleBindings = "cluster_role_bindings"
+	SmokeTestStepGrants              = "grants"
+)
+
+// Failures of smoke test steps, telling missing credentials from missing permissions and other errors.
+const (
+	SmokeTestFailureUnauthorized = "unauthorized"
+	SmokeTestFailureForbidden    = "forbidden"
+	SmokeTestFailureError        = "error"
+)
+
+// smokeTestPageSize is the page size of the listings of the smoke test. It's small, so that most clusters
+// return a continue token and the second page exercises pagination.
+const smokeTestPageSize = 10
+
+// SmokeTestStep is the outcome of a step of the smoke test.
+type SmokeTestStep struct {
+	Name string `json:"name"`
+	// DurationMS is how long the step took, in milliseconds.
+	DurationMS float64 `json:"durationMs"`
+	// Pages and Items are the number of pages and objects the step listed.
+	Pages int `json:"pages,omitempty"`
+	Items int `json:"items,omitempty"`
+	// Detail describes what the step checked, e.g. the role whose grants were computed.
+	Detail  string `json:"detail,omitempty"`
+	Skipped bool   `json:"skipped,omitempty"`
+	// Failure is one of the SmokeTestFailure values if the step failed, and Error the error it failed with.
+	Failure string `json:"failure,omitempty"`
+	Error   string `json:"error,omitempty"`
+
+	err error
+}
+
+// SmokeTestReport is the outcome of the steps of the smoke test.
+type SmokeTestReport struct {
+	OK    bool            `json:"ok"`
+	Steps []SmokeTestStep `json:"steps"`
+}
+
+// Err returns the error of the first failed step, or nil if every step passed.
+func (r *SmokeTestReport) Err() error {
+	for _, step := range r.Steps {
+		if step.err != nil {
+			return fmt.Errorf("smoke test step %s failed: %w", step.Name, step.err)
+		}
+	}
+	return nil
+}
+
+// Write prints the report in a human-readable form.
+func (r *SmokeTestReport) Write(w io.Writer) error {
+	var b strings.Builder
+	for _, step := range r.Steps {
+		status := "ok"
+		switch {
+		case step.Skipped:
+			status = "skipped"
+		case step.Failure != "":
+			status = "FAILED (" + step.Failure + ")"
+		}
+		fmt.Fprintf(&b, "%-22s %-22s %8.1fms", step.Name, status, step.DurationMS)
+		if step.Pages > 0 {
+			fmt.Fprintf(&b, "  %d items in %d pages", step.Items, step.Pages)
+		}
+		if step.Detail != "" {
+			fmt.Fprintf(&b, "  %s", step.Detail)
+		}
+		b.WriteString("\n")
+		if step.Error != "" {
+			fmt.Fprintf(&b, "  %s\n", step.Error)
+		}
+	}
+	if r.OK {
+		b.WriteString("Smoke test passed\n")
+	} else {
+		b.WriteString("Smoke test failed\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// SmokeTest runs a minimal end-to-end check against the cluster, with the connector's client and options:
+// it validates the connector, lists up to two pages of namespaces, roles and bindings, and computes the grants
+// of one role, timing each step. Steps run even when earlier ones failed, so that the report tells
+// authentication, permission, pagination and grant failures apart.
+func (k *Kubernetes) SmokeTest(ctx context.Context) *SmokeTestReport {
+	report := &SmokeTestReport{}
+	run := func(name string, fn func(step *SmokeTestStep) error) {
+		step := SmokeTestStep{Name: name}
+		start := time.Now()
+		err := fn(&step)
+		step.DurationMS = float64(time.Since(start)) / float64(time.Millisecond)
+		if err != nil {
+			step.err = classifyKubeError(err)
+			step.Error = err.Error()
+			step.Failure = smokeTestFailure(step.err)
+		}
+		report.Steps = append(report.Steps, step)
+	}
+
+	run(SmokeTestStepValidate, func(step *SmokeTestStep) error {
+		_, err := k.Validate(ctx)
+		return err
+	})
+	run(SmokeTestStepNamespaces, func(step *SmokeTestStep) error {
+		return smokeTestList(step, func(opts metav1.ListOptions) (int, string, error) {
+			resp, err := k.client.CoreV1().Namespaces().List(ctx, opts)
+			if err != nil {
+				return 0, "", fmt.Errorf("failed to list namespaces: %w", err)
+			}
+			return len(resp.Items), resp.Continue, nil
+		})
+	})
+
+	var role *v2.Resource
+	run(SmokeTestStepRoles, func(step *SmokeTestStep) error {
+		return smokeTestList(step, func(opts metav1.ListOptions) (int, string, error) {
+			resp, err := k.client.RbacV1().Roles("").List(ctx, opts)
+			if err != nil {
+				return 0, "", fmt.Errorf("failed to list roles: %w", err)
+			}
+			if role == nil && len(resp.Items) > 0 {
+				if role, err = roleResource(&resp.Items[0], k.opts, nil); err != nil {
+					return 0, "", err
+				}
+			}
+			return len(resp.Items), resp.Continue, nil
+		})
+	})
+	run(SmokeTestStepRoleBindings, func(step *SmokeTestStep) error {
+		return smokeTestList(step, func(opts metav1.ListOptions) (int, string, error) {
+			resp, err := k.client.RbacV1().RoleBindings("").List(ctx, opts)
+			if err != nil {
+				return 0, "", fmt.Errorf("failed to list role bindings: %w", err)
+			}
+			return len(resp.Items), resp.Continue, nil
+		})
+	})
+	run(SmokeTestStepClusterRoleBindings, func(step *SmokeTestStep) error {
+		return smokeTestList(step, func(opts metav1.ListOptions) (int, string, error) {
+			resp, err := k.client.RbacV1().ClusterRoleBindings().List(ctx, opts)
+			if err != nil {
+				return 0, "", fmt.Errorf("failed to list cluster role bindings: %w", err)
+			}
+			return len(resp.Items), resp.Continue, nil
+		})
+	})
+
+	run(SmokeTestStepGrants, func(step *SmokeTestStep) error {
+		if role == nil {
+			step.Skipped = true
+			step.Detail = "no role to compute the grants of"
+			return nil
+		}
+		step.Detail = "role " + role.Id.Resource
+		var syncer connectorbuilder.ResourceSyncer = newRoleBuilder(k.client, k, k.opts, k.stats)
+		grants, err := listAllGrants(ctx, syncer, role)
+		if err != nil {
+			return err
+		}
+		step.Pages = 1
+		step.Items = len(grants)
+		return nil
+	})
+
+	report.OK = report.Err() == nil
+	return report
+}
+
+// smokeTestList lists the first page of a smoke test step, and the second if there's one, recording how many
+// objects and pages were listed.
+func smokeTestList(step *SmokeTestStep, list func(opts metav1.ListOptions) (int, string, error)) error {
+	opts := metav1.ListOptions{Limit: smokeTestPageSize}
+	for step.Pages < 2 {
+		items, next, err := list(opts)
+		if err != nil {
+			return err
+		}
+		step.Pages++
+		step.Items += items
+		if next == "" {
+			break
+		}
+		opts.Continue = next
+	}
+	return nil
+}
+
+// smokeTestFailure returns the failure of a smoke test step from its error.
+func smokeTestFailure(err error) string {
+	switch {
+	case errors.Is(err, ErrUnauthorized):
+		return SmokeTestFailureUnauthorized
+	case errors.Is(err, ErrForbidden):
+		return SmokeTestFailureForbidden
+	default:
+		return SmokeTestFailureError
+	}
+}
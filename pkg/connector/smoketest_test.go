@@ -0,0 +1,141 @@
+package connector
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// smokeTestClient returns a cluster with enough namespaces to span two smoke test pages, and a role bound to
+// a user.
+func smokeTestClient() *fake.Clientset {
+	objects := []runtime.Object{
+		&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "reader"}},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "reader"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindRole, Name: "reader"},
+			Subjects:   []rbacv1.Subject{{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "alice"}},
+		},
+	}
+	for i := 0; i < smokeTestPageSize+2; i++ {
+		objects = append(objects, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("ns-%02d", i)}})
+	}
+	client := fake.NewSimpleClientset(objects...)
+	reviewAccess(client, allowAccessExcept())
+	return client
+}
+
+// smokeTestStep returns the step of the report with the given name.
+func smokeTestStep(t *testing.T, report *SmokeTestReport, name string) SmokeTestStep {
+	t.Helper()
+	for _, step := range report.Steps {
+		if step.Name == name {
+			return step
+		}
+	}
+	require.Failf(t, "missing step", "step %s not in the report", name)
+	return SmokeTestStep{}
+}
+
+// TestSmokeTest tests that every step of the smoke test passes against a reachable cluster.
+func TestSmokeTest(t *testing.T) {
+	k := newTestKubernetes(smokeTestClient(), ConnectorOpts{})
+
+	report := k.SmokeTest(context.Background())
+	require.NoError(t, report.Err())
+	assert.True(t, report.OK)
+
+	var names []string
+	for _, step := range report.Steps {
+		names = append(names, step.Name)
+		assert.Empty(t, step.Failure, step.Name)
+		assert.GreaterOrEqual(t, step.DurationMS, float64(0))
+	}
+	assert.Equal(t, []string{
+		SmokeTestStepValidate, SmokeTestStepNamespaces, SmokeTestStepRoles,
+		SmokeTestStepRoleBindings, SmokeTestStepClusterRoleBindings, SmokeTestStepGrants,
+	}, names)
+
+	roles := smokeTestStep(t, report, SmokeTestStepRoles)
+	assert.Equal(t, 1, roles.Items)
+	grants := smokeTestStep(t, report, SmokeTestStepGrants)
+	assert.Equal(t, "role payments/reader", grants.Detail)
+	assert.Equal(t, 1, grants.Items)
+
+	var out bytes.Buffer
+	require.NoError(t, report.Write(&out))
+	assert.Contains(t, out.String(), "Smoke test passed")
+}
+
+// TestSmokeTest_Pagination tests that the smoke test follows the continue token of a list to its second page.
+func TestSmokeTest_Pagination(t *testing.T) {
+	client := smokeTestClient()
+	client.PrependReactor("list", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		list := action.(k8stesting.ListActionImpl)
+		obj, err := client.Tracker().List(corev1.SchemeGroupVersion.WithResource("namespaces"),
+			corev1.SchemeGroupVersion.WithKind("Namespace"), "")
+		if err != nil {
+			return true, nil, err
+		}
+		namespaces := obj.(*corev1.NamespaceList)
+		if list.GetListOptions().Continue == "" && len(namespaces.Items) > smokeTestPageSize {
+			namespaces.Items = namespaces.Items[:smokeTestPageSize]
+			namespaces.Continue = "page-2"
+		} else if list.GetListOptions().Continue != "" {
+			namespaces.Items = namespaces.Items[smokeTestPageSize:]
+		}
+		return true, namespaces, nil
+	})
+	k := newTestKubernetes(client, ConnectorOpts{})
+
+	report := k.SmokeTest(context.Background())
+	require.NoError(t, report.Err())
+	namespaces := smokeTestStep(t, report, SmokeTestStepNamespaces)
+	assert.Equal(t, 2, namespaces.Pages)
+	assert.Equal(t, smokeTestPageSize+2, namespaces.Items)
+}
+
+// TestSmokeTest_Forbidden tests that a step denied by RBAC fails as forbidden without stopping the later steps.
+func TestSmokeTest_Forbidden(t *testing.T) {
+	client := smokeTestClient()
+	client.PrependReactor("list", "rolebindings", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, k8serrors.NewForbidden(rbacv1.Resource("rolebindings"), "", errors.New("denied"))
+	})
+	k := newTestKubernetes(client, ConnectorOpts{})
+
+	report := k.SmokeTest(context.Background())
+	assert.False(t, report.OK)
+	require.ErrorIs(t, report.Err(), ErrForbidden)
+
+	bindings := smokeTestStep(t, report, SmokeTestStepRoleBindings)
+	assert.Equal(t, SmokeTestFailureForbidden, bindings.Failure)
+	assert.Contains(t, bindings.Error, "denied")
+	assert.Empty(t, smokeTestStep(t, report, SmokeTestStepClusterRoleBindings).Failure)
+	assert.Equal(t, SmokeTestFailureForbidden, smokeTestStep(t, report, SmokeTestStepGrants).Failure)
+
+	var out bytes.Buffer
+	require.NoError(t, report.Write(&out))
+	assert.Contains(t, out.String(), "FAILED (forbidden)")
+	assert.Contains(t, out.String(), "Smoke test failed")
+}
+
+// TestSmokeTest_NoRoles tests that the grants step is skipped when the cluster has no role.
+func TestSmokeTest_NoRoles(t *testing.T) {
+	k := newTestKubernetes(newValidatedClient(), ConnectorOpts{})
+
+	report := k.SmokeTest(context.Background())
+	require.NoError(t, report.Err())
+	assert.True(t, smokeTestStep(t, report, SmokeTestStepGrants).Skipped)
+}
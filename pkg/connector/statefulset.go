@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 
@@ -17,9 +18,11 @@ import (
 	"go.uber.org/zap"
 )
 
-// statefulSetBuilder syncs Kubernetes StatefulSets as Baton resources.
+// statefulSetBuilder syncs Kubernetes StatefulSets as Baton resources. It's stateless and safe for concurrent
+// use.
 type statefulSetBuilder struct {
 	client kubernetes.Interface
+	opts   ConnectorOpts
 }
 
 // ResourceType returns the resource type for StatefulSet.
@@ -31,41 +34,36 @@ func (s *statefulSetBuilder) ResourceType(ctx context.Context) *v2.ResourceType
 func (s *statefulSetBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
 	l := ctxzap.Extract(ctx)
 
-	// Initialize empty resource slice
-	var rv []*v2.Resource
-
 	// Parse pagination token
 	bag, err := ParsePageToken(pToken.Token)
 	if err != nil {
 		return nil, "", nil, fmt.Errorf("failed to parse page token: %w", err)
 	}
 
-	// Add wildcard resource first, but only on the first page (when page token is empty)
-	if bag.PageToken() == "" {
-		wildcardResource, err := generateWildcardResource(ResourceTypeStatefulSet)
-		if err != nil {
-			l.Error("failed to create wildcard resource for statefulsets", zap.Error(err))
-		} else {
-			rv = append(rv, wildcardResource)
-		}
+	// List the namespace the SDK lists the children of, or all namespaces, after the wildcards
+	namespace, rv, done, err := startNamespacedList(ctx, s.client, s.opts, ResourceTypeStatefulSet, parentResourceID, bag.PageToken(), nil)
+	if err != nil || done {
+		return rv, "", nil, err
 	}
 
 	// Set up list options with pagination
 	opts := metav1.ListOptions{
-		Limit:    ResourcesPageSize,
+		Limit:    s.opts.pageSize(ResourceTypeStatefulSet.Id),
 		Continue: bag.PageToken(),
 	}
 
-	// Fetch statefulsets from the Kubernetes API across all namespaces
+	// Fetch statefulsets from the Kubernetes API in the namespace, or across all namespaces
 	l.Debug("fetching statefulsets", zap.String("continue_token", opts.Continue))
-	resp, err := s.client.AppsV1().StatefulSets("").List(ctx, opts)
+	resp, err := listWithRetry(ctx, s.opts, func(ctx context.Context) (*appsv1.StatefulSetList, error) {
+		return s.client.AppsV1().StatefulSets(namespace).List(ctx, opts)
+	})
 	if err != nil {
 		return nil, "", nil, fmt.Errorf("failed to list statefulsets: %w", err)
 	}
 
 	// Process each statefulset into a Baton resource
 	for _, statefulset := range resp.Items {
-		resource, err := statefulSetResource(&statefulset)
+		resource, err := statefulSetResource(&statefulset, s.opts)
 		if err != nil {
 			l.Error("failed to create statefulset resource",
 				zap.String("namespace", statefulset.Namespace),
@@ -86,7 +84,7 @@ func (s *statefulSetBuilder) List(ctx context.Context, parentResourceID *v2.Reso
 }
 
 // statefulSetResource creates a Baton resource from a Kubernetes StatefulSet.
-func statefulSetResource(statefulset *appsv1.StatefulSet) (*v2.Resource, error) {
+func statefulSetResource(statefulset *appsv1.StatefulSet, opts ConnectorOpts) (*v2.Resource, error) {
 	// Get parent namespace resource ID
 	parentID, err := NamespaceResourceID(statefulset.Namespace)
 	if err != nil {
@@ -104,8 +102,15 @@ func statefulSetResource(statefulset *appsv1.StatefulSet) (*v2.Resource, error)
 		options = append(options, rs.WithExternalID(&v2.ExternalId{Id: string(statefulset.UID)}))
 	}
 
+	// Add configured label tags
+	tagOptions, err := labelTagOptions(statefulset.Labels, opts)
+	if err != nil {
+		return nil, err
+	}
+	options = append(options, tagOptions...)
+
 	// Create the raw ID as namespace/name
-	rawID := statefulset.Namespace + "/" + statefulset.Name
+	rawID := namespacedName(statefulset.Namespace, statefulset.Name)
 
 	// Create resource
 	resource, err := rs.NewResource(
@@ -126,7 +131,7 @@ func (s *statefulSetBuilder) Entitlements(ctx context.Context, resource *v2.Reso
 	var entitlements []*v2.Entitlement
 
 	// Add standard verb entitlements
-	for _, verb := range standardResourceVerbs {
+	for _, verb := range s.opts.resourceVerbs(ResourceTypeStatefulSet.Id) {
 		ent := entitlement.NewPermissionEntitlement(
 			resource,
 			verb,
@@ -162,14 +167,25 @@ func (s *statefulSetBuilder) Entitlements(ctx context.Context, resource *v2.Reso
 	return entitlements, "", nil, nil
 }
 
-// Grants returns no grants for StatefulSet resources.
+// Grants returns the runs_as grant of the service account the StatefulSet runs as.
 func (s *statefulSetBuilder) Grants(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
-	return nil, "", nil, nil
+	grants, err := workloadRunsAsGrants(ctx, resource, func(namespace, name string) (*corev1.PodSpec, error) {
+		workload, err := s.client.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return &workload.Spec.Template.Spec, nil
+	})
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return grants, "", nil, nil
 }
 
 // newStatefulSetBuilder creates a new statefulset builder.
-func newStatefulSetBuilder(client kubernetes.Interface) *statefulSetBuilder {
+func newStatefulSetBuilder(client kubernetes.Interface, opts ConnectorOpts) *statefulSetBuilder {
 	return &statefulSetBuilder{
 		client: client,
+		opts:   opts,
 	}
 }
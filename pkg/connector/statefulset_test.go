@@ -49,7 +49,7 @@ func TestStatefulSetResource(t *testing.T) {
 	}
 
 	// Call the statefulSetResource function
-	resource, err := statefulSetResource(testStatefulSet)
+	resource, err := statefulSetResource(testStatefulSet, ConnectorOpts{})
 
 	// Assertions
 	require.NoError(t, err)
@@ -104,6 +104,13 @@ func TestStatefulSetBuilderList(t *testing.T) {
 	ctx := context.Background()
 	pToken := &pagination.Token{}
 	resources, nextPageToken, ann, err := builder.List(ctx, nil, pToken)
+	require.NoError(t, err)
+	require.Nil(t, ann)
+	assert.Empty(t, nextPageToken)
+
+	// The StatefulSets are listed as the children of their namespace
+	children, nextPageToken, ann, err := builder.List(ctx, namespaceID("test-namespace"), pToken)
+	resources = append(resources, children...)
 
 	// Assertions
 	require.NoError(t, err)
@@ -0,0 +1,90 @@
+package connector
+
+import (
+	"sync"
+)
+
+// Sync statistics counter names.
+const (
+	// StatGrantsObjectNotFound counts Grants calls for objects deleted between List and Grants.
+	StatGrantsObjectNotFound = "grants_object_not_found"
+	// StatCoverageNamespacesChecked counts the synced namespaces whose coverage was verified.
+	StatCoverageNamespacesChecked = "coverage_namespaces_checked"
+	// StatCoverageGapNamespaces counts the synced namespaces the connector couldn't fully read.
+	StatCoverageGapNamespaces = "coverage_gap_namespaces"
+	// StatDanglingRoleRefs counts the bindings referencing a Role or ClusterRole that doesn't exist.
+	StatDanglingRoleRefs = "dangling_role_refs"
+	// StatBindingsSkippedAPIGroup counts the bindings left out because their RoleRef apiGroup isn't RBAC's.
+	StatBindingsSkippedAPIGroup = "bindings_skipped_role_ref_api_group"
+	// StatBindingsSkippedKind counts the bindings left out because their RoleRef kind isn't one they can
+	// reference.
+	StatBindingsSkippedKind = "bindings_skipped_role_ref_kind"
+	// StatProfilesRepaired counts the resources whose profile had invalid UTF-8 replaced to be synced.
+	StatProfilesRepaired = "profiles_repaired"
+	// StatDefaultServiceAccountsSkipped counts the unused default service accounts left out of the sync.
+	StatDefaultServiceAccountsSkipped = "default_service_accounts_skipped"
+	// StatResourcesListedPrefix prefixes the counters of the resources listed of each resource type.
+	StatResourcesListedPrefix = "resources_listed."
+)
+
+// syncStats collects named counters describing a sync. A nil *syncStats is valid and discards all updates.
+type syncStats struct {
+	mu       sync.Mutex
+	counters map[string]int64
+}
+
+// newSyncStats creates an empty set of sync statistics.
+func newSyncStats() *syncStats {
+	return &syncStats{
+		counters: make(map[string]int64),
+	}
+}
+
+// Add adds delta to the named counter.
+func (s *syncStats) Add(name string, delta int64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counters[name] += delta
+}
+
+// Inc increments the named counter by one.
+func (s *syncStats) Inc(name string) {
+	s.Add(name, 1)
+}
+
+// Get returns the current value of the named counter.
+func (s *syncStats) Get(name string) int64 {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.counters[name]
+}
+
+// Reset zeroes all counters.
+func (s *syncStats) Reset() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counters = make(map[string]int64)
+}
+
+// Snapshot returns a copy of all counters.
+func (s *syncStats) Snapshot() map[string]int64 {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rv := make(map[string]int64, len(s.counters))
+	for k, v := range s.counters {
+		rv[k] = v
+	}
+	return rv
+}
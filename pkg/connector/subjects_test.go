@@ -0,0 +1,288 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// sameNamedSubjectsClient returns a cluster in which a user, a group, a service account and its namespace, a
+// role and a cluster role are all named "deployers", and the service account is bound both directly and
+// through its service account group.
+func sameNamedSubjectsClient() *fake.Clientset {
+	const name = "deployers"
+	subjects := []rbacv1.Subject{
+		{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: name},
+		{Kind: SubjectKindGroup, APIGroup: RBACAPIGroup, Name: name},
+		{Kind: SubjectKindServiceAccount, Name: name, Namespace: name},
+		{Kind: SubjectKindGroup, APIGroup: RBACAPIGroup, Name: serviceAccountsGroupPrefix + name},
+	}
+	rules := []rbacv1.PolicyRule{{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"secrets"}}}
+
+	return fake.NewSimpleClientset([]runtime.Object{
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}},
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: name}},
+		&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: name}, Rules: rules},
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: name}, Rules: rules},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: name},
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindRole, Name: name},
+			Subjects:   subjects,
+		},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: name + "-cluster-role", Namespace: name},
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindClusterRole, Name: name},
+			Subjects:   subjects,
+		},
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindClusterRole, Name: name},
+			Subjects:   subjects,
+		},
+	}...)
+}
+
+// subjectKindResourceTypes maps binding subject kinds to the resource types of their principals.
+var subjectKindResourceTypes = map[string]string{
+	SubjectKindUser:           ResourceTypeKubeUser.Id,
+	SubjectKindGroup:          ResourceTypeKubeGroup.Id,
+	SubjectKindServiceAccount: ResourceTypeServiceAccount.Id,
+}
+
+// TestSameNamedSubjects_UniqueIDs tests that same-named subjects of every kind yield unique resource, entitlement
+// and grant IDs, and that every grant resolves to the principal of the right type, under every combination of
+// the options affecting IDs.
+func TestSameNamedSubjects_UniqueIDs(t *testing.T) {
+	redactModes := map[string]*RedactOptions{
+		"plain":           nil,
+		"redacted":        {Key: []byte("demo")},
+		"redacted-prefix": {Key: []byte("demo"), PreservePrefixes: []string{"deploy"}},
+	}
+
+	for redactMode, redact := range redactModes {
+		for _, includeSystem := range []bool{false, true} {
+			for _, expandGroups := range []bool{false, true} {
+				name := fmt.Sprintf("%s/system=%t/expand=%t", redactMode, includeSystem, expandGroups)
+				t.Run(name, func(t *testing.T) {
+					opts := ConnectorOpts{
+						AllowEmptySync:             true,
+						IncludeSystemSubjects:      includeSystem,
+						ExpandServiceAccountGroups: expandGroups,
+						Redact:                     redact,
+					}
+					assertUniqueSubjectIDs(t, opts)
+				})
+			}
+		}
+	}
+}
+
+// assertUniqueSubjectIDs syncs the same-named subjects cluster with the given options and checks the IDs.
+func assertUniqueSubjectIDs(t *testing.T, opts ConnectorOpts) {
+	ctx := context.Background()
+	client := sameNamedSubjectsClient()
+	k := newTestKubernetes(client, opts)
+	syncers := k.wrapSyncers(ctx, []connectorbuilder.ResourceSyncer{
+		newKubeUserBuilder(client, nil, opts),
+		newKubeGroupBuilder(client, nil, opts),
+		newServiceAccountBuilder(client, opts),
+		newRoleBuilder(client, k, opts, k.stats),
+		newClusterRoleBuilder(client, k, opts, k.stats),
+	})
+
+	resourceIDs := make(map[string]bool)
+	entitlementIDs := make(map[string]bool)
+	grantIDs := make(map[string]bool)
+	granted := make(map[string]int) // principal type -> membership grants
+	for _, syncer := range syncers {
+		for _, resource := range listResources(ctx, t, syncer, "deployers") {
+			id := resource.Id.ResourceType + ":" + resource.Id.Resource
+			require.False(t, resourceIDs[id], "duplicate resource %s", id)
+			resourceIDs[id] = true
+
+			entitlements, _, _, err := syncer.Entitlements(ctx, resource, &pagination.Token{})
+			require.NoError(t, err)
+			for _, ent := range entitlements {
+				require.False(t, entitlementIDs[ent.Id], "duplicate entitlement %s", ent.Id)
+				entitlementIDs[ent.Id] = true
+			}
+
+			grants, _, _, err := syncer.Grants(ctx, resource, &pagination.Token{})
+			require.NoError(t, err)
+			for _, g := range grants {
+				require.False(t, grantIDs[g.Id], "duplicate grant %s", g.Id)
+				grantIDs[g.Id] = true
+				if principalType := assertTypedPrincipal(t, k, g); principalType != "" {
+					granted[principalType]++
+				}
+			}
+		}
+	}
+
+	// The user, the group and the service account are each granted the role, and the cluster role both in the
+	// namespace and cluster-wide
+	for _, resourceType := range subjectKindResourceTypes {
+		assert.GreaterOrEqual(t, granted[resourceType], 3, "grants to %s", resourceType)
+	}
+}
+
+// listResources lists every page of a syncer, then of the children of each namespace for the types listed as
+// the children of the namespaces.
+func listResources(ctx context.Context, t *testing.T, syncer connectorbuilder.ResourceSyncer, namespaces ...string) []*v2.Resource {
+	rv := listChildResources(ctx, t, syncer, nil)
+	if !slices.ContainsFunc(namespaceChildResourceTypes, func(rt *v2.ResourceType) bool { return rt.Id == syncer.ResourceType(ctx).Id }) {
+		return rv
+	}
+	for _, namespace := range namespaces {
+		rv = append(rv, listChildResources(ctx, t, syncer, namespaceID(namespace))...)
+	}
+	return rv
+}
+
+// listChildResources lists every page of the children of the parent, or of the top level if it's nil.
+func listChildResources(ctx context.Context, t *testing.T, syncer connectorbuilder.ResourceSyncer, parent *v2.ResourceId) []*v2.Resource {
+	var rv []*v2.Resource
+	token := &pagination.Token{}
+	for {
+		resources, next, _, err := syncer.List(ctx, parent, token)
+		require.NoError(t, err)
+		rv = append(rv, resources...)
+		if next == "" {
+			return rv
+		}
+		token = &pagination.Token{Token: next}
+	}
+}
+
+// namespaceID returns the ID of a namespace, the parent of the namespaced resources listed in it.
+func namespaceID(namespace string) *v2.ResourceId {
+	return &v2.ResourceId{ResourceType: ResourceTypeNamespace.Id, Resource: namespace}
+}
+
+// assertTypedPrincipal checks that a membership grant's principal has the type of the subject kind recorded in
+// its metadata, and restores to the original subject name. It returns the principal type of membership grants.
+func assertTypedPrincipal(t *testing.T, k *Kubernetes, g *v2.Grant) string {
+	metadata := &v2.GrantMetadata{}
+	annos := annotations.Annotations(g.Annotations)
+	ok, err := annos.Pick(metadata)
+	require.NoError(t, err)
+	if !ok {
+		// Permission grants from rules have roles as principals and no metadata
+		assert.Contains(t, []string{ResourceTypeRole.Id, ResourceTypeClusterRole.Id}, g.Principal.Id.ResourceType)
+		return ""
+	}
+
+	kind := metadata.Metadata.GetFields()[GrantMetadataSubjectKind].GetStringValue()
+	require.NotEmpty(t, kind, "grant %s has no subject kind", g.Id)
+	assert.Equal(t, subjectKindResourceTypes[kind], g.Principal.Id.ResourceType, "grant %s", g.Id)
+
+	principalID := g.Principal.Id
+	if k.redactor != nil {
+		principalID = k.redactor.inboundResourceID(principalID)
+	}
+	switch principalID.ResourceType {
+	case ResourceTypeServiceAccount.Id:
+		assert.Equal(t, "deployers/deployers", principalID.Resource)
+	case ResourceTypeKubeGroup.Id:
+		assert.Contains(t, []string{"deployers", serviceAccountsGroupPrefix + "deployers"}, principalID.Resource)
+	default:
+		assert.Equal(t, "deployers", principalID.Resource)
+	}
+	return principalID.ResourceType
+}
+
+// TestUniqueGrants tests that duplicate grants are dropped, keeping direct grants over inherited ones.
+func TestUniqueGrants(t *testing.T) {
+	role := GenerateResourceForGrant("deployers/deployers", ResourceTypeRole.Id)
+	sa := GenerateResourceForGrant("deployers/deployers", ResourceTypeServiceAccount.Id)
+	inherited := bindingGrantMetadata(BindingKindRoleBinding, metav1.ObjectMeta{Name: "group-binding", Namespace: "deployers"})
+	inherited[GrantMetadataViaGroup] = serviceAccountsGroupPrefix + "deployers"
+
+	grants := uniqueGrants([]*v2.Grant{
+		grant.NewGrant(role, "member", sa, grant.WithGrantMetadata(inherited)),
+		grant.NewGrant(role, "member", sa, bindingGrantOption(BindingKindRoleBinding, metav1.ObjectMeta{Name: "direct", Namespace: "deployers"})),
+		grant.NewGrant(role, "member", sa, bindingGrantOption(BindingKindRoleBinding, metav1.ObjectMeta{Name: "other", Namespace: "deployers"})),
+	})
+	require.Len(t, grants, 1)
+	ref, ok, err := bindingRefFromGrant(grants[0])
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "direct", ref.name)
+	assert.Empty(t, ref.viaGroup)
+}
+
+// TestServiceAccountUserSubjects tests that a service account bound both as a ServiceAccount subject and as a
+// User subject with its system:serviceaccount: user name is granted the role once, as the service account.
+func TestServiceAccountUserSubjects(t *testing.T) {
+	ctx := context.Background()
+	roleRef := rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindRole, Name: "deployer"}
+	client := fake.NewSimpleClientset(
+		&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: "deployer"}},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: "by-kind"},
+			RoleRef:    roleRef,
+			Subjects:   []rbacv1.Subject{{Kind: SubjectKindServiceAccount, Namespace: "ci", Name: "builder"}},
+		},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: "by-username"},
+			RoleRef:    roleRef,
+			Subjects: []rbacv1.Subject{
+				{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "system:serviceaccount:ci:builder"},
+				// Malformed service account user names stay users
+				{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "system:serviceaccount:ci"},
+			},
+		},
+	)
+
+	for _, includeSystem := range []bool{false, true} {
+		t.Run(fmt.Sprintf("includeSystemSubjects=%t", includeSystem), func(t *testing.T) {
+			opts := ConnectorOpts{IncludeSystemSubjects: includeSystem}
+			k := newTestKubernetes(client, opts)
+			role := GenerateResourceForGrant("ci/deployer", ResourceTypeRole.Id)
+			grants, _, _, err := newRoleBuilder(client, k, opts, k.stats).Grants(ctx, role, &pagination.Token{})
+			require.NoError(t, err)
+
+			var principals []string
+			for _, g := range grants {
+				if g.Entitlement.Id == "role:ci/deployer:member" {
+					principals = append(principals, g.Principal.Id.ResourceType+":"+g.Principal.Id.Resource)
+				}
+			}
+			want := []string{"service_account:ci/builder"}
+			if includeSystem {
+				want = append(want, "kube_user:system:serviceaccount:ci")
+			}
+			assert.ElementsMatch(t, want, principals)
+		})
+	}
+
+	// The user name isn't synced as a kube_user
+	var users []string
+	for _, resource := range listResources(ctx, t, newKubeUserBuilder(client, nil, ConnectorOpts{})) {
+		users = append(users, resource.Id.Resource)
+	}
+	assert.ElementsMatch(t, []string{"*", "system:serviceaccount:ci"}, users)
+
+	// Revoking the service account removes both forms
+	subjects := []rbacv1.Subject{
+		{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "system:serviceaccount:ci:builder"},
+		{Kind: SubjectKindServiceAccount, Namespace: "ci", Name: "builder"},
+		{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "alice"},
+	}
+	remaining := removeSubject(subjects, rbacv1.Subject{Kind: SubjectKindServiceAccount, Namespace: "ci", Name: "builder"})
+	assert.Equal(t, []rbacv1.Subject{{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "alice"}}, remaining)
+}
@@ -0,0 +1,52 @@
+package connector
+
+import (
+	"context"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+)
+
+// ResetCaches drops the bindings caches, along with the role grantors, pods, workload and secret references
+// caches and the namespaces snapshot and ClusterRoles cache of the ClusterRoles, so that they're loaded again from
+// the cluster when next needed. Connectors running as a service reset them at the start of each sync unless
+// they're kept across syncs.
+func (k *Kubernetes) ResetCaches() {
+	k.bindingsMutex.Lock()
+	k.roleBindingsCache = nil
+	k.clusterRoleBindingsCache = nil
+	k.danglingBindings = nil
+	k.bindingsLoaded = false
+	k.bindingsMutex.Unlock()
+
+	k.grantorsMutex.Lock()
+	k.grantors = nil
+	k.grantorsMutex.Unlock()
+
+	k.podsMutex.Lock()
+	k.podsCache = nil
+	k.podsMutex.Unlock()
+
+	k.workloadsMutex.Lock()
+	k.workloadsCache = nil
+	k.workloadsMutex.Unlock()
+
+	k.secretRefsMutex.Lock()
+	k.secretRefsCache = nil
+	k.webhookCASecretsCache = nil
+	k.secretRefsMutex.Unlock()
+
+	k.namespaces.reset()
+	k.clusterRoles.reset()
+}
+
+// startSync resets the sync stats at the start of a sync, and the caches unless they're kept across syncs. The
+// SDK validates the connector before each sync, so Validate calls it: the caches are never reset in the middle of
+// a sync, such as when the SDK retries the first page of a listing.
+func (k *Kubernetes) startSync(ctx context.Context) {
+	k.stats.Reset()
+	if k.opts.KeepCachesAcrossSyncs {
+		return
+	}
+	ctxzap.Extract(ctx).Debug("new sync started, resetting caches")
+	k.ResetCaches()
+}
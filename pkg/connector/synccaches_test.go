@@ -0,0 +1,128 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// TestSyncCacheReset tests that bindings created or deleted between two syncs of a long-running connector show
+// in the grants of the second sync, and only once the SDK validates the connector to start it, unless the caches
+// are kept across syncs.
+func TestSyncCacheReset(t *testing.T) {
+	ctx := context.Background()
+	binding := func(name, user string) *rbacv1.RoleBinding {
+		return &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "payments"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindRole, Name: "reader"},
+			Subjects:   []rbacv1.Subject{{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: user}},
+		}
+	}
+	role := &v2.Resource{Id: &v2.ResourceId{ResourceType: ResourceTypeRole.Id, Resource: "payments/reader"}, DisplayName: "reader"}
+
+	tests := []struct {
+		name          string
+		keep          bool
+		wantAfterSync []string
+	}{
+		{name: "reset", wantAfterSync: []string{"kube_user:bob"}},
+		{name: "kept across syncs", keep: true, wantAfterSync: []string{"kube_user:alice"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset(
+				&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: "reader", Namespace: "payments"}},
+				binding("alice", "alice"),
+			)
+			reviewAccess(client, allowAccessExcept())
+			k := newKubernetes(client, nil, ConnectorOpts{AllowEmptySync: true, KeepCachesAcrossSyncs: tt.keep})
+			syncer := k.wrapSyncers(ctx, []connectorbuilder.ResourceSyncer{newRoleBuilder(client, k, k.opts, k.stats)})[0]
+
+			members := func() []string {
+				grants, _, _, err := syncer.Grants(ctx, role, &pagination.Token{})
+				require.NoError(t, err)
+				var rv []string
+				for _, g := range grants {
+					if g.Entitlement.Id == "role:payments/reader:member" {
+						rv = append(rv, resourceIDKey(g.Principal.Id))
+					}
+				}
+				return rv
+			}
+
+			_, err := k.Validate(ctx)
+			require.NoError(t, err)
+			require.NoError(t, listAll(ctx, syncer))
+			assert.Equal(t, []string{"kube_user:alice"}, members())
+
+			// The grants of the current sync are computed from the bindings it loaded, even when the SDK lists
+			// the first page again, as it does when retrying it
+			require.NoError(t, client.RbacV1().RoleBindings("payments").Delete(ctx, "alice", metav1.DeleteOptions{}))
+			_, err = client.RbacV1().RoleBindings("payments").Create(ctx, binding("bob", "bob"), metav1.CreateOptions{})
+			require.NoError(t, err)
+			require.NoError(t, listAll(ctx, syncer))
+			assert.Equal(t, []string{"kube_user:alice"}, members())
+
+			// Validating the connector starts the next sync
+			_, err = k.Validate(ctx)
+			require.NoError(t, err)
+			require.NoError(t, listAll(ctx, syncer))
+			assert.Equal(t, tt.wantAfterSync, members())
+		})
+	}
+}
+
+// TestSyncCacheReset_ClusterRoles tests that the ClusterRoles aggregation rules are resolved against are listed
+// again when the next sync starts, however recently they were listed.
+func TestSyncCacheReset_ClusterRoles(t *testing.T) {
+	ctx := context.Background()
+	client := newValidatedClient()
+	_, err := client.RbacV1().ClusterRoles().Create(ctx, &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "view"}}, metav1.CreateOptions{})
+	require.NoError(t, err)
+	k := newKubernetes(client, nil, ConnectorOpts{AllowEmptySync: true})
+	builder := newClusterRoleBuilder(client, k, k.opts, k.stats)
+	builder.clusterRoles = k.clusterRoles
+
+	_, err = k.Validate(ctx)
+	require.NoError(t, err)
+	clusterRoles, err := builder.cacheClusterRoles(ctx)
+	require.NoError(t, err)
+	require.Len(t, clusterRoles, 1)
+
+	_, err = client.RbacV1().ClusterRoles().Create(ctx, &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "edit"}}, metav1.CreateOptions{})
+	require.NoError(t, err)
+	clusterRoles, err = builder.cacheClusterRoles(ctx)
+	require.NoError(t, err)
+	assert.Len(t, clusterRoles, 1)
+
+	_, err = k.Validate(ctx)
+	require.NoError(t, err)
+	clusterRoles, err = builder.cacheClusterRoles(ctx)
+	require.NoError(t, err)
+	assert.Len(t, clusterRoles, 2)
+}
+
+// TestSyncStatsReset tests that the counters of a sync start from zero when the next sync starts, whether or not
+// the caches are kept across syncs.
+func TestSyncStatsReset(t *testing.T) {
+	ctx := context.Background()
+	for _, keep := range []bool{false, true} {
+		k := newKubernetes(newValidatedClient(), nil, ConnectorOpts{AllowEmptySync: true, KeepCachesAcrossSyncs: keep})
+		_, err := k.Validate(ctx)
+		require.NoError(t, err)
+		k.stats.Inc(StatGrantsObjectNotFound)
+		assert.Equal(t, int64(1), k.SyncStats()[StatGrantsObjectNotFound])
+
+		_, err = k.Validate(ctx)
+		require.NoError(t, err)
+		assert.NotContains(t, k.SyncStats(), StatGrantsObjectNotFound, "keep caches: %v", keep)
+	}
+}
@@ -0,0 +1,112 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// systemUserPrefix prefixes the names of the users Kubernetes components and nodes authenticate as.
+	systemUserPrefix = "system:"
+	// serviceAccountUserPrefix prefixes the user names of service accounts, system:serviceaccount:<ns>:<name>.
+	serviceAccountUserPrefix = "system:serviceaccount:"
+	// nodeUserPrefix prefixes the user names kubelets authenticate as, system:node:<node name>.
+	nodeUserPrefix = "system:node:"
+)
+
+// isSystemIdentity reports whether a user is a Kubernetes component or node identity, such as
+// system:kube-scheduler or system:node:<name>, rather than a person. Service account user names aren't
+// system identities, as they stand for the service account.
+func isSystemIdentity(username string) bool {
+	return strings.HasPrefix(username, systemUserPrefix) && !strings.HasPrefix(username, serviceAccountUserPrefix)
+}
+
+// serviceAccountForUsername returns the service account a system:serviceaccount:<namespace>:<name> user name
+// authenticates as, and reports whether the name has that form.
+func serviceAccountForUsername(username string) (rbacv1.Subject, bool) {
+	rest, ok := strings.CutPrefix(username, serviceAccountUserPrefix)
+	if !ok {
+		return rbacv1.Subject{}, false
+	}
+	namespace, name, ok := strings.Cut(rest, ":")
+	if !ok || namespace == "" || name == "" || strings.Contains(name, ":") {
+		return rbacv1.Subject{}, false
+	}
+	return rbacv1.Subject{Kind: SubjectKindServiceAccount, Namespace: namespace, Name: name}, true
+}
+
+// nodeNameForUsername returns the name of the node whose kubelet authenticates as a system:node:<name> user
+// name, and reports whether the name has that form. Node names are DNS subdomains, so they may contain dots
+// and dashes but no colons.
+func nodeNameForUsername(username string) (string, bool) {
+	name, ok := strings.CutPrefix(username, nodeUserPrefix)
+	if !ok || len(validation.IsDNS1123Subdomain(name)) > 0 {
+		return "", false
+	}
+	return name, true
+}
+
+// nodeUsername returns the user name the kubelet of a node authenticates as.
+func nodeUsername(nodeName string) string {
+	return nodeUserPrefix + nodeName
+}
+
+// listNodeUsernames returns the user names the kubelets of the nodes in the cluster authenticate as.
+func listNodeUsernames(ctx context.Context, client kubernetes.Interface, opts ConnectorOpts) ([]string, error) {
+	var rv []string
+	listOpts := metav1.ListOptions{Limit: opts.pageSize(ResourceTypeNode.Id)}
+	for {
+		resp, err := client.CoreV1().Nodes().List(ctx, listOpts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list nodes: %w", err)
+		}
+		for _, node := range resp.Items {
+			rv = append(rv, nodeUsername(node.Name))
+		}
+		if resp.Continue == "" {
+			return rv, nil
+		}
+		listOpts.Continue = resp.Continue
+	}
+}
+
+// normalizeSubject resolves User subjects naming a service account by its user name to the ServiceAccount
+// subject, so both forms map onto the same principal.
+func normalizeSubject(subject rbacv1.Subject) rbacv1.Subject {
+	if subject.Kind != SubjectKindUser {
+		return subject
+	}
+	if sa, ok := serviceAccountForUsername(subject.Name); ok {
+		return sa
+	}
+	return subject
+}
+
+// sameSubject reports whether two binding subjects refer to the same identity.
+func sameSubject(a, b rbacv1.Subject) bool {
+	a, b = normalizeSubject(a), normalizeSubject(b)
+	return a.Kind == b.Kind && a.Name == b.Name && a.Namespace == b.Namespace
+}
+
+// kubeUserResourceType returns the resource type a user is synced as.
+func (o ConnectorOpts) kubeUserResourceType(username string) *v2.ResourceType {
+	if o.SeparateSystemUsers && isSystemIdentity(username) {
+		return ResourceTypeKubeSystemUser
+	}
+	return ResourceTypeKubeUser
+}
+
+// memberGrantableTo returns the principal types role memberships can be granted to.
+func memberGrantableTo(opts ConnectorOpts) []*v2.ResourceType {
+	if opts.SeparateSystemUsers {
+		return []*v2.ResourceType{ResourceTypeKubeUser, ResourceTypeKubeSystemUser, ResourceTypeKubeGroup, ResourceTypeServiceAccount}
+	}
+	return []*v2.ResourceType{ResourceTypeKubeUser, ResourceTypeKubeGroup, ResourceTypeServiceAccount}
+}
@@ -0,0 +1,263 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestIsSystemIdentity(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		want     bool
+	}{
+		{"human", "alice@example.com", false},
+		{"scheduler", "system:kube-scheduler", true},
+		{"controller manager", "system:kube-controller-manager", true},
+		{"node", "system:node:worker-1", true},
+		{"anonymous", "system:anonymous", true},
+		{"service account", "system:serviceaccount:default:builder", false},
+		{"system in the middle", "oidc:system:alice", false},
+		{"prefix without colon", "systemd", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isSystemIdentity(tt.username))
+		})
+	}
+}
+
+func TestServiceAccountForUsername(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		want     rbacv1.Subject
+		wantOK   bool
+	}{
+		{"service account", "system:serviceaccount:prod:deployer", rbacv1.Subject{Kind: SubjectKindServiceAccount, Namespace: "prod", Name: "deployer"}, true},
+		{"missing name segment", "system:serviceaccount:prod", rbacv1.Subject{}, false},
+		{"empty namespace", "system:serviceaccount::deployer", rbacv1.Subject{}, false},
+		{"empty name", "system:serviceaccount:prod:", rbacv1.Subject{}, false},
+		{"extra segment", "system:serviceaccount:prod:deployer:extra", rbacv1.Subject{}, false},
+		{"service accounts group", "system:serviceaccounts:prod", rbacv1.Subject{}, false},
+		{"human", "alice", rbacv1.Subject{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := serviceAccountForUsername(tt.username)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestGrantRoleToSubject_ServiceAccountUsername(t *testing.T) {
+	role := GenerateResourceForGrant("prod/deployer", ResourceTypeRole.Id)
+
+	g, err := GrantRoleToSubject(rbacv1.Subject{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "system:serviceaccount:prod:deployer"}, role, "member")
+	require.NoError(t, err)
+	assert.Equal(t, ResourceTypeServiceAccount.Id, g.Principal.Id.ResourceType)
+	assert.Equal(t, "prod/deployer", g.Principal.Id.Resource)
+
+	// Malformed names are system users, as before
+	_, err = GrantRoleToSubject(rbacv1.Subject{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "system:serviceaccount:prod"}, role, "member")
+	require.Error(t, err)
+	g, err = grantRoleToSubject(rbacv1.Subject{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "system:serviceaccount:prod"}, role, "member",
+		ConnectorOpts{IncludeSystemSubjects: true})
+	require.NoError(t, err)
+	assert.Equal(t, ResourceTypeKubeUser.Id, g.Principal.Id.ResourceType)
+	assert.Equal(t, "system:serviceaccount:prod", g.Principal.Id.Resource)
+}
+
+// systemUsersClient returns a client with a RoleBinding of a human, component, node and service account user.
+// The service account user is synced as the service account.
+func systemUsersClient() *fake.Clientset {
+	var subjects []rbacv1.Subject
+	for _, name := range []string{"alice", "system:kube-scheduler", "system:node:worker-1", "system:serviceaccount:default:builder"} {
+		subjects = append(subjects, rbacv1.Subject{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: name})
+	}
+	return fake.NewSimpleClientset(
+		&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "reader"}},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "reader"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindRole, Name: "reader"},
+			Subjects:   subjects,
+		},
+	)
+}
+
+// syncedUsers returns the users synced by the builder, mapped to whether they are flagged as system identities.
+func syncedUsers(ctx context.Context, t *testing.T, builder *kubeUserBuilder) map[string]bool {
+	rv := make(map[string]bool)
+	for _, resource := range listResources(ctx, t, builder) {
+		if resource.Id.Resource == "*" {
+			continue
+		}
+		assert.Equal(t, builder.resourceType.Id, resource.Id.ResourceType)
+		trait, err := rs.GetUserTrait(resource)
+		require.NoError(t, err)
+		rv[resource.Id.Resource] = trait.GetProfile().GetFields()["systemIdentity"].GetBoolValue()
+	}
+	return rv
+}
+
+func TestKubeUserBuilder_SystemIdentity(t *testing.T) {
+	ctx := context.Background()
+	client := systemUsersClient()
+
+	assert.Equal(t, map[string]bool{
+		"alice":                 false,
+		"system:kube-scheduler": true,
+		"system:node:worker-1":  true,
+	}, syncedUsers(ctx, t, newKubeUserBuilder(client, nil, ConnectorOpts{})))
+}
+
+func TestKubeUserBuilder_SeparateSystemUsers(t *testing.T) {
+	ctx := context.Background()
+	client := systemUsersClient()
+	opts := ConnectorOpts{SeparateSystemUsers: true, IncludeSystemSubjects: true}
+
+	assert.Equal(t, map[string]bool{
+		"alice": false,
+	}, syncedUsers(ctx, t, newKubeUserBuilder(client, nil, opts)))
+	assert.Equal(t, map[string]bool{
+		"system:kube-scheduler": true,
+		"system:node:worker-1":  true,
+	}, syncedUsers(ctx, t, newKubeSystemUserBuilder(client, nil, opts)))
+
+	// Memberships are granted to the separate resources
+	k := newTestKubernetes(client, opts)
+	role := GenerateResourceForGrant("default/reader", ResourceTypeRole.Id)
+	grants, _, _, err := newRoleBuilder(client, k, opts, k.stats).Grants(ctx, role, &pagination.Token{})
+	require.NoError(t, err)
+	var principals []string
+	for _, g := range grants {
+		if g.Entitlement.Id == "role:default/reader:member" {
+			principals = append(principals, g.Principal.Id.ResourceType+":"+g.Principal.Id.Resource)
+		}
+	}
+	assert.ElementsMatch(t, []string{
+		"kube_user:alice",
+		"kube_system_user:system:kube-scheduler",
+		"kube_system_user:system:node:worker-1",
+		"service_account:default/builder",
+	}, principals)
+}
+
+func TestExpandPolicyRules_SeparateSystemUsers(t *testing.T) {
+	ctx := context.Background()
+	role := GenerateResourceForGrant("impersonator", ResourceTypeClusterRole.Id)
+	rules := []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"users"}, Verbs: []string{"impersonate"}},
+		{APIGroups: []string{""}, Resources: []string{"users"}, ResourceNames: []string{"bob", "system:kube-proxy"}, Verbs: []string{"impersonate"}},
+	}
+
+	grants, err := expandPolicyRules(ctx, fake.NewSimpleClientset(), role, clusterRoleRuleScope(nil), rules, ConnectorOpts{SeparateSystemUsers: true})
+	require.NoError(t, err)
+	var targets []string
+	for _, g := range grants {
+		targets = append(targets, g.Entitlement.Resource.Id.ResourceType+":"+g.Entitlement.Resource.Id.Resource)
+	}
+	assert.ElementsMatch(t, []string{
+		"kube_user:*",
+		"kube_system_user:*",
+		"kube_user:bob",
+		"kube_system_user:system:kube-proxy",
+	}, targets)
+}
+
+func TestNodeNameForUsername(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		want     string
+		wantOK   bool
+	}{
+		{"simple", "system:node:worker-1", "worker-1", true},
+		{"dots and dashes", "system:node:ip-10-0-1-5.ec2.internal", "ip-10-0-1-5.ec2.internal", true},
+		{"single character", "system:node:a", "a", true},
+		{"empty name", "system:node:", "", false},
+		{"colon in name", "system:node:worker:1", "", false},
+		{"uppercase", "system:node:Worker-1", "", false},
+		{"leading dash", "system:node:-worker", "", false},
+		{"trailing dot", "system:node:worker.", "", false},
+		{"nodes group", "system:nodes", "", false},
+		{"node proxier", "system:node-proxier", "", false},
+		{"human", "alice", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := nodeNameForUsername(tt.username)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNodeUsers(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "ip-10-0-1-5.ec2.internal"}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker-1"}},
+	)
+
+	tests := []struct {
+		name     string
+		opts     ConnectorOpts
+		userType string
+	}{
+		{"kube users", ConnectorOpts{IncludeSystemSubjects: true}, ResourceTypeKubeUser.Id},
+		{"separate system users", ConnectorOpts{IncludeSystemSubjects: true, SeparateSystemUsers: true}, ResourceTypeKubeSystemUser.Id},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The node users are synced with the node names in their profile
+			users := newKubeUserBuilder(client, nil, tt.opts)
+			if tt.opts.SeparateSystemUsers {
+				users = newKubeSystemUserBuilder(client, nil, tt.opts)
+			}
+			nodeNames := make(map[string]string)
+			for _, resource := range listResources(ctx, t, users) {
+				if resource.Id.Resource == "*" {
+					continue
+				}
+				trait, err := rs.GetUserTrait(resource)
+				require.NoError(t, err)
+				nodeNames[resource.Id.Resource] = trait.GetProfile().GetFields()["nodeName"].GetStringValue()
+			}
+			assert.Equal(t, map[string]string{
+				"system:node:ip-10-0-1-5.ec2.internal": "ip-10-0-1-5.ec2.internal",
+				"system:node:worker-1":                 "worker-1",
+			}, nodeNames)
+
+			// Each node is linked to its user
+			nodes := newNodeBuilder(client, tt.opts)
+			node := GenerateResourceForGrant("ip-10-0-1-5.ec2.internal", ResourceTypeNode.Id)
+			grants, _, _, err := nodes.Grants(ctx, node, &pagination.Token{})
+			require.NoError(t, err)
+			require.Len(t, grants, 1)
+			assert.Equal(t, "node:ip-10-0-1-5.ec2.internal:"+NodeOperatesEntitlement, grants[0].Entitlement.Id)
+			assert.Equal(t, tt.userType, grants[0].Principal.Id.ResourceType)
+			assert.Equal(t, "system:node:ip-10-0-1-5.ec2.internal", grants[0].Principal.Id.Resource)
+		})
+	}
+
+	// Node users are system subjects, left out by default
+	assert.Empty(t, syncedUsers(ctx, t, newKubeUserBuilder(client, nil, ConnectorOpts{})))
+	grants, _, _, err := newNodeBuilder(client, ConnectorOpts{}).Grants(ctx, GenerateResourceForGrant("worker-1", ResourceTypeNode.Id), &pagination.Token{})
+	require.NoError(t, err)
+	assert.Empty(t, grants)
+}
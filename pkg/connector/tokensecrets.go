@@ -0,0 +1,80 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// SecretOwnsEntitlement is the entitlement of a service account token secret granted to its service account.
+const SecretOwnsEntitlement = "owns"
+
+// secretProfileType returns the type of a secret recorded in the profile of its resource, and reports whether
+// the resource has one.
+func secretProfileType(resource *v2.Resource) (corev1.SecretType, bool) {
+	trait := &v2.SecretTrait{}
+	annos := annotations.Annotations(resource.GetAnnotations())
+	ok, err := annos.Pick(trait)
+	if err != nil || !ok {
+		return "", false
+	}
+	secretType, ok := rs.GetProfileStringValue(trait.GetProfile(), "type")
+	return corev1.SecretType(secretType), ok
+}
+
+// serviceAccountTokenGrants returns the get and owns grants of a service account token secret to the service
+// account its long-lived token authenticates as. Tokens of service accounts deleted, or deleted and recreated,
+// since the secret was created are invalid and have no grants.
+func serviceAccountTokenGrants(ctx context.Context, client kubernetes.Interface, resource *v2.Resource, namespace, name string) ([]*v2.Grant, error) {
+	l := ctxzap.Extract(ctx).With(zap.String("namespace", namespace), zap.String("name", name))
+
+	// Skip fetching secrets known not to be service account tokens
+	if secretType, ok := secretProfileType(resource); ok && secretType != corev1.SecretTypeServiceAccountToken {
+		return nil, nil
+	}
+
+	secret, err := client.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			l.Info("secret no longer exists, skipping service account token grants")
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get secret: %w", err)
+	}
+	if secret.Type != corev1.SecretTypeServiceAccountToken {
+		return nil, nil
+	}
+
+	saName := secret.Annotations[corev1.ServiceAccountNameKey]
+	if saName == "" {
+		return nil, nil
+	}
+	sa, err := client.CoreV1().ServiceAccounts(namespace).Get(ctx, saName, metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			l.Info("token secret references a service account that no longer exists", zap.String("serviceAccount", saName))
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get service account %s: %w", saName, err)
+	}
+	if uid := secret.Annotations[corev1.ServiceAccountUIDKey]; uid != "" && uid != string(sa.UID) {
+		l.Info("token secret references a deleted service account with the name of an existing one", zap.String("serviceAccount", saName))
+		return nil, nil
+	}
+
+	principal := &v2.ResourceId{ResourceType: ResourceTypeServiceAccount.Id, Resource: namespacedName(namespace, saName)}
+	return []*v2.Grant{
+		grant.NewGrant(resource, "get", principal),
+		grant.NewGrant(resource, SecretOwnsEntitlement, principal),
+	}, nil
+}
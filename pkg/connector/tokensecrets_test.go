@@ -0,0 +1,118 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// saTokenSecret returns a service account token secret for the named service account.
+func saTokenSecret(name, serviceAccount, uid string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ci",
+			Name:      name,
+			Annotations: map[string]string{
+				corev1.ServiceAccountNameKey: serviceAccount,
+				corev1.ServiceAccountUIDKey:  uid,
+			},
+		},
+		Type: corev1.SecretTypeServiceAccountToken,
+	}
+}
+
+func TestSecretBuilderGrants_ServiceAccountTokens(t *testing.T) {
+	ctx := context.Background()
+	secrets := map[string]*corev1.Secret{
+		"builder-token": saTokenSecret("builder-token", "builder", "builder-uid"),
+		"gone-token":    saTokenSecret("gone-token", "gone", "gone-uid"),
+		"old-token":     saTokenSecret("old-token", "deployer", "old-deployer-uid"),
+		"db-creds":      {ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: "db-creds"}, Type: corev1.SecretTypeOpaque},
+	}
+	objects := []runtime.Object{
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: "builder", UID: "builder-uid"}},
+		// Recreated since old-token was issued, which the token controller invalidates
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: "deployer", UID: "new-deployer-uid"}},
+	}
+	for _, secret := range secrets {
+		objects = append(objects, secret)
+	}
+	client := fake.NewSimpleClientset(objects...)
+	secretGets := 0
+	client.PrependReactor("get", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		secretGets++
+		return false, nil, nil
+	})
+	builder := newSecretBuilder(client, nil, nil, ConnectorOpts{})
+
+	grants := func(name string) []string {
+		resource, err := secretResource(secrets[name], nil, builder.opts)
+		require.NoError(t, err)
+		grants, _, _, err := builder.Grants(ctx, resource, &pagination.Token{})
+		require.NoError(t, err)
+		var rv []string
+		for _, g := range grants {
+			rv = append(rv, g.Entitlement.Id+" "+g.Principal.Id.ResourceType+":"+g.Principal.Id.Resource)
+		}
+		return rv
+	}
+
+	assert.Equal(t, []string{
+		"secret:ci/builder-token:get service_account:ci/builder",
+		"secret:ci/builder-token:owns service_account:ci/builder",
+	}, grants("builder-token"))
+	assert.Empty(t, grants("gone-token"))
+	assert.Empty(t, grants("old-token"))
+
+	// Secrets known not to be tokens aren't fetched
+	secretGets = 0
+	assert.Empty(t, grants("db-creds"))
+	assert.Equal(t, 0, secretGets)
+}
+
+func TestSecretBuilder_ServiceAccountTokenEntitlements(t *testing.T) {
+	ctx := context.Background()
+	builder := newSecretBuilder(fake.NewSimpleClientset(), nil, nil, ConnectorOpts{})
+
+	resource, err := secretResource(saTokenSecret("builder-token", "builder", "builder-uid"), nil, builder.opts)
+	require.NoError(t, err)
+
+	// The profile records the service account
+	trait := &v2.SecretTrait{}
+	annos := annotations.Annotations(resource.Annotations)
+	ok, err := annos.Pick(trait)
+	require.NoError(t, err)
+	require.True(t, ok)
+	saName, _ := rs.GetProfileStringValue(trait.Profile, "serviceAccountName")
+	assert.Equal(t, "builder", saName)
+
+	entitlements, _, _, err := builder.Entitlements(ctx, resource, &pagination.Token{})
+	require.NoError(t, err)
+	grantableToSA := make(map[string]bool)
+	for _, ent := range entitlements {
+		for _, rt := range ent.GrantableTo {
+			if rt.Id == ResourceTypeServiceAccount.Id {
+				grantableToSA[ent.Slug] = true
+			}
+		}
+	}
+	assert.Equal(t, map[string]bool{"get": true, SecretOwnsEntitlement: true}, grantableToSA)
+
+	// Other secrets have no owner
+	opaque, err := secretResource(&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "ci", Name: "db-creds"}, Type: corev1.SecretTypeOpaque}, nil, builder.opts)
+	require.NoError(t, err)
+	entitlements, _, _, err = builder.Entitlements(ctx, opaque, &pagination.Token{})
+	require.NoError(t, err)
+	assert.Len(t, entitlements, len(standardResourceVerbs))
+}
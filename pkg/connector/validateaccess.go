@@ -0,0 +1,110 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/structpb"
+	authorizationv1 "k8s.io/api/authorization/v1"
+)
+
+// bindingListChecks are the accesses loading the bindings caches needs. The grants of most resource types
+// are read from the bindings, so they're checked whatever the synced resource types.
+var bindingListChecks = []authorizationv1.ResourceAttributes{
+	{Verb: "list", Group: RBACAPIGroup, Resource: "rolebindings"},
+	{Verb: "list", Group: RBACAPIGroup, Resource: "clusterrolebindings"},
+}
+
+// syncListChecks are the cluster-wide list accesses the syncer of each resource type needs, by resource type
+// ID. Types that only read the bindings need nothing beyond bindingListChecks.
+var syncListChecks = map[string][]authorizationv1.ResourceAttributes{
+	ResourceTypeNamespace.Id:      {{Verb: "list", Group: "", Resource: "namespaces"}},
+	ResourceTypeServiceAccount.Id: {{Verb: "list", Group: "", Resource: "serviceaccounts"}},
+	ResourceTypeRole.Id:           {{Verb: "list", Group: RBACAPIGroup, Resource: "roles"}},
+	ResourceTypeClusterRole.Id:    {{Verb: "list", Group: RBACAPIGroup, Resource: "clusterroles"}},
+	ResourceTypeCluster.Id:        {{Verb: "list", Group: RBACAPIGroup, Resource: "clusterroles"}},
+	ResourceTypeSecret.Id:         {{Verb: "list", Group: "", Resource: "secrets"}},
+	ResourceTypeConfigMap.Id:      {{Verb: "list", Group: "", Resource: "configmaps"}},
+	ResourceTypeService.Id: {
+		{Verb: "list", Group: "", Resource: "services"},
+		{Verb: "list", Group: "discovery.k8s.io", Resource: "endpointslices"},
+	},
+	ResourceTypeNode.Id:        {{Verb: "list", Group: "", Resource: "nodes"}},
+	ResourceTypePod.Id:         {{Verb: "list", Group: "", Resource: "pods"}},
+	ResourceTypeDeployment.Id:  {{Verb: "list", Group: "apps", Resource: "deployments"}},
+	ResourceTypeStatefulSet.Id: {{Verb: "list", Group: "apps", Resource: "statefulsets"}},
+	ResourceTypeDaemonSet.Id:   {{Verb: "list", Group: "apps", Resource: "daemonsets"}},
+	ResourceTypeReplicaSet.Id:  {{Verb: "list", Group: "apps", Resource: "replicasets"}},
+}
+
+// missingListPermissions returns the "resource.group" names of the resources the connector needs to list to
+// sync the configured resource types but isn't allowed to, sorted by name.
+func (k *Kubernetes) missingListPermissions(ctx context.Context) ([]string, error) {
+	checks := slices.Clone(bindingListChecks)
+	for _, id := range slices.Sorted(maps.Keys(syncListChecks)) {
+		if !k.opts.syncsResourceType(id) {
+			continue
+		}
+		for _, check := range syncListChecks[id] {
+			if !slices.Contains(checks, check) {
+				checks = append(checks, check)
+			}
+		}
+	}
+
+	var missing []string
+	for _, check := range checks {
+		reviewCtx, cancel := k.opts.requestContext(ctx)
+		allowed, err := reviewSelfAccess(reviewCtx, k.client, check)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			missing = append(missing, coverageResourceName(check))
+		}
+	}
+	slices.Sort(missing)
+	return missing, nil
+}
+
+// checkListPermissions verifies the connector can list everything the configured resource types are synced
+// from. Missing permissions fail validation with ErrForbidden naming all of them, unless partial syncs are
+// allowed, in which case they're returned as a warning annotation.
+func (k *Kubernetes) checkListPermissions(ctx context.Context) (annotations.Annotations, error) {
+	missing, err := k.missingListPermissions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(missing) == 0 {
+		return nil, nil
+	}
+
+	if !k.opts.AllowPartialSync {
+		return nil, fmt.Errorf("%w: the connector isn't allowed to list %s", ErrForbidden, strings.Join(missing, ", "))
+	}
+
+	ctxzap.Extract(ctx).Warn("connector isn't allowed to list some of the synced resources, the sync will be incomplete",
+		zap.Strings("resources", missing))
+
+	resources := make([]any, 0, len(missing))
+	for _, name := range missing {
+		resources = append(resources, name)
+	}
+	warning, err := structpb.NewStruct(map[string]any{
+		"warning":                "the connector isn't allowed to list some resources, the sync will be incomplete",
+		"missingListPermissions": resources,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build the missing permissions warning: %w", err)
+	}
+	var annos annotations.Annotations
+	annos.Update(warning)
+	return annos, nil
+}
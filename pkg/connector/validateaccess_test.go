@@ -0,0 +1,81 @@
+package connector
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// allowAccessExcept allows every access but the list of the denied resources, by "resource.group" name.
+func allowAccessExcept(denied ...string) func(authorizationv1.ResourceAttributes) bool {
+	return func(attributes authorizationv1.ResourceAttributes) bool {
+		return !slices.Contains(denied, coverageResourceName(attributes))
+	}
+}
+
+// newValidatedClient returns a fake cluster allowing every access but the list of the denied resources.
+func newValidatedClient(denied ...string) *fake.Clientset {
+	client := fake.NewSimpleClientset()
+	reviewAccess(client, allowAccessExcept(denied...))
+	return client
+}
+
+// TestValidate_ListPermissions tests that validation fails naming every synced resource the connector can't
+// list.
+func TestValidate_ListPermissions(t *testing.T) {
+	ctx := context.Background()
+
+	k := newTestKubernetes(newValidatedClient(), ConnectorOpts{})
+	annos, err := k.Validate(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, annos)
+
+	k = newTestKubernetes(newValidatedClient("secrets", "rolebindings.rbac.authorization.k8s.io", "deployments.apps"), ConnectorOpts{})
+	_, err = k.Validate(ctx)
+	require.ErrorIs(t, err, ErrForbidden)
+	assert.Contains(t, err.Error(), "deployments.apps, rolebindings.rbac.authorization.k8s.io, secrets")
+}
+
+// TestValidate_ListPermissionsSyncResources tests that only the resources of the synced resource types, and
+// the bindings, are checked.
+func TestValidate_ListPermissionsSyncResources(t *testing.T) {
+	var reviewed []string
+	client := fake.NewSimpleClientset()
+	reviewAccess(client, func(attributes authorizationv1.ResourceAttributes) bool {
+		reviewed = append(reviewed, coverageResourceName(attributes))
+		return attributes.Resource != "secrets"
+	})
+	k := newTestKubernetes(client, ConnectorOpts{SyncResources: []string{ResourceTypeRole.Id, ResourceTypeService.Id}})
+
+	_, err := k.Validate(context.Background())
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		"rolebindings.rbac.authorization.k8s.io",
+		"clusterrolebindings.rbac.authorization.k8s.io",
+		"roles.rbac.authorization.k8s.io",
+		"services",
+		"endpointslices.discovery.k8s.io",
+	}, reviewed)
+}
+
+// TestValidate_ListPermissionsAllowPartialSync tests that missing permissions are returned as a warning
+// annotation when partial syncs are allowed.
+func TestValidate_ListPermissionsAllowPartialSync(t *testing.T) {
+	k := newTestKubernetes(newValidatedClient("secrets", "nodes"), ConnectorOpts{AllowPartialSync: true})
+
+	annos, err := k.Validate(context.Background())
+	require.NoError(t, err)
+
+	warning := &structpb.Struct{}
+	ok, err := annos.Pick(warning)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Contains(t, warning.Fields["warning"].GetStringValue(), "incomplete")
+	assert.Equal(t, []any{"nodes", "secrets"}, warning.Fields["missingListPermissions"].GetListValue().AsSlice())
+}
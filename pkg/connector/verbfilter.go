@@ -0,0 +1,106 @@
+package connector
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/types/entitlement"
+)
+
+// VerbFilterDefault is the key of the verb filter entry applying to the resource types without their own.
+const VerbFilterDefault = "*"
+
+// verbFilterResourceTypes are the resource types with the standard verb entitlements, which the verb filter
+// trims. The impersonate, bind and escalate entitlements of the principals and roles, and the entitlements of
+// subresources like exec or scale, aren't standard verbs and are always kept.
+var verbFilterResourceTypes = []*v2.ResourceType{
+	ResourceTypeNamespace,
+	ResourceTypeNode,
+	ResourceTypePod,
+	ResourceTypeSecret,
+	ResourceTypeConfigMap,
+	ResourceTypeService,
+	ResourceTypeDeployment,
+	ResourceTypeStatefulSet,
+	ResourceTypeDaemonSet,
+	ResourceTypeReplicaSet,
+}
+
+// WithVerbFilter limits the standard verb entitlements (get, list, watch, create, update, patch and delete)
+// of the resource types to the allowed verbs, by resource type ID, with the VerbFilterDefault entry applying to
+// the resource types without their own, e.g. {"*": {"get", "create", "update", "delete"}, "secret": {"get"}}.
+// Rules are expanded into grants of the allowed verbs only. Without an entry for a resource type, nor a
+// default one, its verbs aren't filtered.
+func WithVerbFilter(filter map[string][]string) ConnectorOption {
+	return func(opts *ConnectorOpts) error {
+		var ids []string
+		for _, rt := range verbFilterResourceTypes {
+			ids = append(ids, rt.Id)
+		}
+
+		rv := make(map[string][]string, len(filter))
+		for resourceTypeID, verbs := range filter {
+			if resourceTypeID != VerbFilterDefault && !slices.Contains(ids, resourceTypeID) {
+				return fmt.Errorf("invalid verb filter: unknown resource type %q, expected %s or one of %s",
+					resourceTypeID, VerbFilterDefault, strings.Join(ids, ", "))
+			}
+			for _, verb := range verbs {
+				if !slices.Contains(standardResourceVerbs, verb) {
+					return fmt.Errorf("invalid verb filter for %s: unknown verb %q, expected one of %s",
+						resourceTypeID, verb, strings.Join(standardResourceVerbs, ", "))
+				}
+			}
+			rv[resourceTypeID] = uniqueSorted(verbs)
+		}
+		opts.VerbFilter = rv
+		return nil
+	}
+}
+
+// verbAllowed reports whether the verb filter keeps the entitlement of the verb on the resource type. Verbs
+// other than the standard ones are always kept.
+func (o ConnectorOpts) verbAllowed(resourceTypeID, verb string) bool {
+	if !slices.Contains(standardResourceVerbs, verb) {
+		return true
+	}
+	allowed, ok := o.VerbFilter[resourceTypeID]
+	if !ok {
+		allowed, ok = o.VerbFilter[VerbFilterDefault]
+	}
+	return !ok || slices.Contains(allowed, verb)
+}
+
+// resourceVerbs returns the standard verb entitlements of the resource type the verb filter keeps, in the order
+// of standardResourceVerbs.
+func (o ConnectorOpts) resourceVerbs(resourceTypeID string) []string {
+	if len(o.VerbFilter) == 0 {
+		return standardResourceVerbs
+	}
+	var rv []string
+	for _, verb := range standardResourceVerbs {
+		if o.verbAllowed(resourceTypeID, verb) {
+			rv = append(rv, verb)
+		}
+	}
+	return rv
+}
+
+// filterVerbGrants drops the grants of the resource on the verb entitlements the verb filter trims, such as the
+// get grants of mounted secrets when get isn't kept.
+func (o ConnectorOpts) filterVerbGrants(resource *v2.Resource, grants []*v2.Grant) []*v2.Grant {
+	if len(o.VerbFilter) == 0 {
+		return grants
+	}
+	prefix := entitlement.NewEntitlementID(resource, "")
+	rv := make([]*v2.Grant, 0, len(grants))
+	for _, g := range grants {
+		verb, ok := strings.CutPrefix(g.GetEntitlement().GetId(), prefix)
+		if ok && !o.verbAllowed(resource.Id.ResourceType, verb) {
+			continue
+		}
+		rv = append(rv, g)
+	}
+	return rv
+}
@@ -0,0 +1,101 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+func TestWithVerbFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  map[string][]string
+		want    map[string][]string
+		wantErr string
+	}{
+		{
+			name:   "empty",
+			filter: nil,
+			want:   map[string][]string{},
+		},
+		{
+			name:   "default and override",
+			filter: map[string][]string{"*": {"update", "get", "get"}, "secret": {"get"}},
+			want:   map[string][]string{"*": {"get", "update"}, "secret": {"get"}},
+		},
+		{
+			name:    "unknown resource type",
+			filter:  map[string][]string{"role": {"get"}},
+			wantErr: `unknown resource type "role"`,
+		},
+		{
+			name:    "unknown verb",
+			filter:  map[string][]string{"pod": {"exec"}},
+			wantErr: `unknown verb "exec"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := applyOptions([]ConnectorOption{WithVerbFilter(tt.filter)})
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, opts.VerbFilter)
+		})
+	}
+}
+
+func TestConnectorOpts_ResourceVerbs(t *testing.T) {
+	assert.Equal(t, standardResourceVerbs, ConnectorOpts{}.resourceVerbs(ResourceTypePod.Id))
+
+	opts := ConnectorOpts{VerbFilter: map[string][]string{
+		VerbFilterDefault: {"get", "create", "update", "delete"},
+		"secret":          {"get"},
+	}}
+	assert.Equal(t, []string{"get", "create", "update", "delete"}, opts.resourceVerbs(ResourceTypePod.Id))
+	assert.Equal(t, []string{"get"}, opts.resourceVerbs(ResourceTypeSecret.Id))
+	assert.True(t, opts.verbAllowed(ResourceTypeKubeUser.Id, "impersonate"))
+
+	// Only the overridden type is filtered without a default
+	opts = ConnectorOpts{VerbFilter: map[string][]string{"secret": {"get"}}}
+	assert.Equal(t, standardResourceVerbs, opts.resourceVerbs(ResourceTypePod.Id))
+	assert.Equal(t, []string{"get"}, opts.resourceVerbs(ResourceTypeSecret.Id))
+}
+
+// TestVerbFilter_EntitlementsAndRules tests that the entitlements of a builder and the grants expanded from
+// rules both leave out the filtered verbs.
+func TestVerbFilter_EntitlementsAndRules(t *testing.T) {
+	ctx := context.Background()
+	opts := ConnectorOpts{VerbFilter: map[string][]string{
+		VerbFilterDefault: {"get", "create", "update", "delete"},
+		"secret":          {"get"},
+	}}
+
+	builder := &secretBuilder{opts: opts}
+	entitlements, _, _, err := builder.Entitlements(ctx, GenerateResourceForGrant("payments/*", ResourceTypeSecret.Id), &pagination.Token{})
+	require.NoError(t, err)
+	var slugs []string
+	for _, ent := range entitlements {
+		slugs = append(slugs, ent.Slug)
+	}
+	assert.Equal(t, []string{"get"}, slugs)
+
+	principal := GenerateResourceForGrant("payments/deployer", ResourceTypeRole.Id)
+	rules := []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"secrets", "pods"}, Verbs: []string{"get", "list", "watch"}},
+		{APIGroups: []string{""}, Resources: []string{"pods/exec"}, Verbs: []string{"create"}},
+	}
+	grants, err := expandPolicyRules(ctx, nil, principal, roleRuleScope("payments"), rules, opts)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		"secret:*:get",
+		"pod:*:get",
+		"pod:*:exec",
+	}, grantEntitlementIDs(grants))
+}
@@ -1,11 +1,18 @@
 package connector
 
 import (
+	"context"
 	"testing"
 
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 // TestWildcardResources verifies that wildcard resources can be created successfully.
@@ -58,12 +65,20 @@ func TestWildcardResources(t *testing.T) {
 			name:         "StatefulSet wildcard",
 			resourceType: ResourceTypeStatefulSet,
 		},
+		{
+			name:         "KubeUser wildcard",
+			resourceType: ResourceTypeKubeUser,
+		},
+		{
+			name:         "KubeGroup wildcard",
+			resourceType: ResourceTypeKubeGroup,
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			// Create wildcard resource - if the appropriate traits are missing, this would fail
-			resource, err := generateWildcardResource(tc.resourceType)
+			resource, err := generateWildcardResource(tc.resourceType, "")
 			require.NoError(t, err)
 			require.NotNil(t, resource)
 
@@ -74,3 +89,149 @@ func TestWildcardResources(t *testing.T) {
 		})
 	}
 }
+
+// TestNamespaceWildcardResources tests that namespaced builders list the wildcard of every namespace on their
+// first page when namespace wildcards are enabled, alongside the cluster-wide wildcard.
+func TestNamespaceWildcardResources(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "payments"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "billing"}},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "payments"}},
+	)
+
+	resources := listResources(ctx, t, newDeploymentBuilder(client, ConnectorOpts{}), "payments", "billing")
+	assert.Len(t, resources, 2)
+
+	resources = listResources(ctx, t, newDeploymentBuilder(client, ConnectorOpts{NamespaceWildcards: true}), "payments", "billing")
+	byID := make(map[string]*v2.Resource)
+	for _, resource := range resources {
+		byID[resource.Id.Resource] = resource
+	}
+	assert.Len(t, byID, 4)
+	require.Contains(t, byID, "*")
+	require.Contains(t, byID, "payments/api")
+	require.Contains(t, byID, "payments/*")
+	require.Contains(t, byID, "billing/*")
+
+	wildcard := byID["payments/*"]
+	assert.Equal(t, ResourceTypeNamespace.Id, wildcard.ParentResourceId.ResourceType)
+	assert.Equal(t, "payments", wildcard.ParentResourceId.Resource)
+	assert.Equal(t, "All Deployment in payments", wildcard.DisplayName)
+	assert.Nil(t, byID["*"].ParentResourceId)
+
+	// Service accounts are listed per namespace, with the wildcard of their namespace only
+	resources, _, _, err := newServiceAccountBuilder(client, ConnectorOpts{NamespaceWildcards: true}).List(ctx, namespaceID("billing"), &pagination.Token{})
+	require.NoError(t, err)
+	var ids []string
+	for _, resource := range resources {
+		ids = append(ids, resource.Id.Resource)
+	}
+	assert.Equal(t, []string{"*", "billing/*"}, ids)
+
+	// The namespace wildcards have the verb entitlements and no grants, like the cluster-wide one
+	entitlements, _, _, err := newDeploymentBuilder(client, ConnectorOpts{}).Entitlements(ctx, wildcard, &pagination.Token{})
+	require.NoError(t, err)
+	assert.NotEmpty(t, entitlements)
+	grants, _, _, err := newDeploymentBuilder(client, ConnectorOpts{}).Grants(ctx, wildcard, &pagination.Token{})
+	require.NoError(t, err)
+	assert.Empty(t, grants)
+}
+
+// TestExpandPolicyRules_NamespaceWildcards tests that with namespace wildcards the rules of Roles without
+// resourceNames are granted on the wildcard of their namespace, while ClusterRoles keep the cluster-wide one.
+func TestExpandPolicyRules_NamespaceWildcards(t *testing.T) {
+	ctx := context.Background()
+	opts := ConnectorOpts{NamespaceWildcards: true}
+	rules := []rbacv1.PolicyRule{
+		{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"secrets"}},
+		{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"configmaps"}, ResourceNames: []string{"settings"}},
+		{Verbs: []string{"bind"}, APIGroups: []string{RBACAPIGroup}, Resources: []string{"clusterroles"}},
+	}
+
+	role := &v2.Resource{Id: &v2.ResourceId{ResourceType: ResourceTypeRole.Id, Resource: "payments/reader"}}
+	grants, err := expandPolicyRules(ctx, nil, role, roleRuleScope("payments"), rules, opts)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		"secret:payments/*:get",
+		"configmap:payments/settings:get",
+		"cluster_role:*:bind",
+	}, grantEntitlementIDs(grants))
+
+	clusterRole := &v2.Resource{Id: &v2.ResourceId{ResourceType: ResourceTypeClusterRole.Id, Resource: "reader"}}
+	grants, err = expandPolicyRules(ctx, nil, clusterRole, clusterRoleRuleScope([]string{"payments"}), rules, opts)
+	require.NoError(t, err)
+	assert.Contains(t, grantEntitlementIDs(grants), "secret:*:get")
+	assert.NotContains(t, grantEntitlementIDs(grants), "secret:payments/*:get")
+}
+
+// TestWildcardResourcesDisabled tests that no builder lists a wildcard resource when they are disabled, even with
+// namespace wildcards enabled.
+func TestWildcardResourcesDisabled(t *testing.T) {
+	ctx := context.Background()
+	meta := metav1.ObjectMeta{Name: "api", Namespace: "payments"}
+	client := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "payments"}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}},
+		&corev1.ServiceAccount{ObjectMeta: meta},
+		&corev1.Secret{ObjectMeta: meta},
+		&corev1.ConfigMap{ObjectMeta: meta},
+		&corev1.Service{ObjectMeta: meta},
+		&corev1.Pod{ObjectMeta: meta},
+		&appsv1.Deployment{ObjectMeta: meta},
+		&appsv1.StatefulSet{ObjectMeta: meta},
+		&appsv1.DaemonSet{ObjectMeta: meta},
+		&appsv1.ReplicaSet{ObjectMeta: meta},
+		&rbacv1.Role{ObjectMeta: meta},
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "viewer"}},
+		&rbacv1.RoleBinding{
+			ObjectMeta: meta,
+			RoleRef:    rbacv1.RoleRef{APIGroup: RBACAPIGroup, Kind: RoleRefKindRole, Name: "api"},
+			Subjects: []rbacv1.Subject{
+				{Kind: SubjectKindUser, APIGroup: RBACAPIGroup, Name: "alice"},
+				{Kind: SubjectKindGroup, APIGroup: RBACAPIGroup, Name: "developers"},
+			},
+		},
+	)
+
+	opts := ConnectorOpts{DisableWildcardResources: true, NamespaceWildcards: true, AllowEmptySync: true}
+	k := newTestKubernetes(client, opts)
+	syncers := k.ResourceSyncers(ctx)
+	require.NotEmpty(t, syncers)
+
+	for _, syncer := range syncers {
+		resourceTypeID := syncer.ResourceType(ctx).Id
+		// Service accounts, and the namespaced types, are listed per namespace
+		var parentID *v2.ResourceId
+		if resourceTypeID == ResourceTypeServiceAccount.Id || opts.listedAsNamespaceChildren(resourceTypeID) {
+			parentID = &v2.ResourceId{ResourceType: ResourceTypeNamespace.Id, Resource: "payments"}
+		}
+		var ids []string
+		pageToken := ""
+		for {
+			resources, next, _, err := syncer.List(ctx, parentID, &pagination.Token{Token: pageToken})
+			require.NoError(t, err, resourceTypeID)
+			for _, resource := range resources {
+				ids = append(ids, resource.Id.Resource)
+				assert.False(t, isWildcardResourceID(resource.Id.Resource), "%s lists wildcard %s", resourceTypeID, resource.Id.Resource)
+			}
+			if next == "" {
+				break
+			}
+			pageToken = next
+		}
+		if resourceTypeID != ResourceTypeCluster.Id {
+			assert.NotEmpty(t, ids, "%s should still list its resources", resourceTypeID)
+		}
+	}
+
+	// Rules covering every resource of a type are granted on the wildcards, so they're skipped
+	role := &v2.Resource{Id: &v2.ResourceId{ResourceType: ResourceTypeRole.Id, Resource: "payments/api"}}
+	rules := []rbacv1.PolicyRule{
+		{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"secrets"}},
+		{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"configmaps"}, ResourceNames: []string{"settings"}},
+	}
+	grants, err := expandPolicyRules(ctx, nil, role, roleRuleScope("payments"), rules, opts)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"configmap:payments/settings:get"}, grantEntitlementIDs(grants))
+}
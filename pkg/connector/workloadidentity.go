@@ -0,0 +1,65 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// ServiceAccountRunsAsEntitlement is the entitlement of a service account granted to the workloads whose pods
+// run as it.
+const ServiceAccountRunsAsEntitlement = "runs_as"
+
+// workloadResourceTypes are the resource types of the workloads, and pods, linked to the service accounts they
+// run as.
+var workloadResourceTypes = []*v2.ResourceType{
+	ResourceTypePod,
+	ResourceTypeDeployment,
+	ResourceTypeStatefulSet,
+	ResourceTypeDaemonSet,
+	ResourceTypeReplicaSet,
+}
+
+// podSpecServiceAccount returns the name of the service account the pods of a spec run as.
+func podSpecServiceAccount(spec *corev1.PodSpec) string {
+	if spec.ServiceAccountName != "" {
+		return spec.ServiceAccountName
+	}
+	return defaultServiceAccountName
+}
+
+// workloadRunsAsGrants returns the grant of the runs_as entitlement of the service account the pod spec of a
+// workload or pod runs as to the workload or pod. getPodSpec fetches the live pod spec, as the listed resource
+// doesn't carry it; workloads and pods deleted since they were listed have no grants.
+func workloadRunsAsGrants(ctx context.Context, resource *v2.Resource, getPodSpec func(namespace, name string) (*corev1.PodSpec, error)) ([]*v2.Grant, error) {
+	if isWildcardResourceID(resource.Id.Resource) {
+		return nil, nil
+	}
+
+	namespace, name, ok := strings.Cut(resource.Id.Resource, "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid %s resource ID format: %s", resource.Id.ResourceType, resource.Id.Resource)
+	}
+
+	spec, err := getPodSpec(namespace, name)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			ctxzap.Extract(ctx).Info("workload no longer exists, skipping grants",
+				zap.String("resourceType", resource.Id.ResourceType),
+				zap.String("namespace", namespace),
+				zap.String("name", name))
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get %s: %w", resource.Id.ResourceType, err)
+	}
+
+	saResource := GenerateResourceForGrant(namespace+"/"+podSpecServiceAccount(spec), ResourceTypeServiceAccount.Id)
+	return []*v2.Grant{grant.NewGrant(saResource, ServiceAccountRunsAsEntitlement, resource.Id)}, nil
+}
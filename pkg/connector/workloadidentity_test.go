@@ -0,0 +1,114 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// podTemplate returns a pod template running as the named service account, or the default one if empty.
+func podTemplate(serviceAccount string) corev1.PodTemplateSpec {
+	return corev1.PodTemplateSpec{
+		Spec: corev1.PodSpec{
+			ServiceAccountName: serviceAccount,
+			Containers:         []corev1.Container{{Name: "app", Image: "app"}},
+		},
+	}
+}
+
+func TestWorkloadBuildersGrants_RunsAs(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "api-7d4f"},
+			Spec:       podTemplate("api").Spec,
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "debug"},
+			Spec:       podTemplate("").Spec,
+		},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "api"},
+			Spec:       appsv1.DeploymentSpec{Template: podTemplate("api")},
+		},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web"},
+			Spec:       appsv1.DeploymentSpec{Template: podTemplate("")},
+		},
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "db"},
+			Spec:       appsv1.StatefulSetSpec{Template: podTemplate("postgres")},
+		},
+		&appsv1.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "log-agent"},
+			Spec:       appsv1.DaemonSetSpec{Template: podTemplate("")},
+		},
+	)
+
+	tests := []struct {
+		name    string
+		builder connectorbuilder.ResourceSyncer
+		rType   *v2.ResourceType
+		id      string
+		wantSA  string
+	}{
+		{"pod with custom service account", newPodBuilder(client, ConnectorOpts{}), ResourceTypePod, "shop/api-7d4f", "shop/api"},
+		{"pod with default service account", newPodBuilder(client, ConnectorOpts{}), ResourceTypePod, "shop/debug", "shop/default"},
+		{"deployment with custom service account", newDeploymentBuilder(client, ConnectorOpts{}), ResourceTypeDeployment, "shop/api", "shop/api"},
+		{"deployment with default service account", newDeploymentBuilder(client, ConnectorOpts{}), ResourceTypeDeployment, "shop/web", "shop/default"},
+		{"statefulset", newStatefulSetBuilder(client, ConnectorOpts{}), ResourceTypeStatefulSet, "shop/db", "shop/postgres"},
+		{"daemonset", newDaemonSetBuilder(client, ConnectorOpts{}), ResourceTypeDaemonSet, "kube-system/log-agent", "kube-system/default"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resource := GenerateResourceForGrant(tt.id, tt.rType.Id)
+			grants, _, _, err := tt.builder.Grants(ctx, resource, &pagination.Token{})
+			require.NoError(t, err)
+			require.Len(t, grants, 1)
+
+			g := grants[0]
+			assert.Equal(t, "service_account:"+tt.wantSA+":"+ServiceAccountRunsAsEntitlement, g.Entitlement.Id)
+			assert.Equal(t, tt.rType.Id, g.Principal.Id.ResourceType)
+			assert.Equal(t, tt.id, g.Principal.Id.Resource)
+		})
+	}
+
+	// Deleted and wildcard workloads and pods have no grants
+	for _, id := range []string{"shop/gone", "*"} {
+		grants, _, _, err := newDeploymentBuilder(client, ConnectorOpts{}).Grants(ctx, GenerateResourceForGrant(id, ResourceTypeDeployment.Id), &pagination.Token{})
+		require.NoError(t, err)
+		assert.Empty(t, grants, id)
+
+		grants, _, _, err = newPodBuilder(client, ConnectorOpts{}).Grants(ctx, GenerateResourceForGrant(id, ResourceTypePod.Id), &pagination.Token{})
+		require.NoError(t, err)
+		assert.Empty(t, grants, id)
+	}
+}
+
+func TestServiceAccountBuilderEntitlements_RunsAs(t *testing.T) {
+	ctx := context.Background()
+	builder := newServiceAccountBuilder(fake.NewSimpleClientset(), ConnectorOpts{})
+
+	sa := &v2.Resource{Id: &v2.ResourceId{ResourceType: ResourceTypeServiceAccount.Id, Resource: "shop/api"}, DisplayName: "api"}
+	entitlements, _, _, err := builder.Entitlements(ctx, sa, &pagination.Token{})
+	require.NoError(t, err)
+	require.Len(t, entitlements, 2)
+	runsAs := entitlements[1]
+	assert.Equal(t, "service_account:shop/api:"+ServiceAccountRunsAsEntitlement, runsAs.Id)
+	assert.Equal(t, workloadResourceTypes, runsAs.GrantableTo)
+
+	wildcard := &v2.Resource{Id: &v2.ResourceId{ResourceType: ResourceTypeServiceAccount.Id, Resource: "*"}, DisplayName: "All Service Accounts"}
+	entitlements, _, _, err = builder.Entitlements(ctx, wildcard, &pagination.Token{})
+	require.NoError(t, err)
+	assert.Len(t, entitlements, 1)
+}
@@ -0,0 +1,128 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConfigMapMountedByEntitlement is the entitlement of a configmap granted to the workloads whose pod template
+// consumes it.
+const ConfigMapMountedByEntitlement = "mounted_by"
+
+// WorkloadPodTemplate is the pod template of a Deployment, StatefulSet or DaemonSet.
+type WorkloadPodTemplate struct {
+	// ResourceType is the resource type the workload is synced as.
+	ResourceType *v2.ResourceType
+	// Name is the name of the workload.
+	Name string
+	// Spec is the pod spec of the workload's template.
+	Spec corev1.PodSpec
+}
+
+// GetWorkloadPodTemplates returns the pod templates of the synced workloads in the namespace, listing them on
+// first use and caching them for the rest of the sync.
+func (k *Kubernetes) GetWorkloadPodTemplates(ctx context.Context, namespace string) ([]WorkloadPodTemplate, error) {
+	k.workloadsMutex.Lock()
+	defer k.workloadsMutex.Unlock()
+
+	if templates, ok := k.workloadsCache[namespace]; ok {
+		return templates, nil
+	}
+
+	l := ctxzap.Extract(ctx)
+	l.Debug("loading workload pod templates cache", zap.String("namespace", namespace))
+
+	var templates []WorkloadPodTemplate
+	if k.opts.syncsResourceType(ResourceTypeDeployment.Id) {
+		err := listPages(k.opts.pageSize(ResourceTypeDeployment.Id), func(opts metav1.ListOptions) (string, error) {
+			resp, err := k.client.AppsV1().Deployments(namespace).List(ctx, opts)
+			if err != nil {
+				return "", fmt.Errorf("listing deployments in namespace %s: %w", namespace, err)
+			}
+			for _, d := range resp.Items {
+				templates = append(templates, WorkloadPodTemplate{ResourceType: ResourceTypeDeployment, Name: d.Name, Spec: d.Spec.Template.Spec})
+			}
+			return resp.Continue, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if k.opts.syncsResourceType(ResourceTypeStatefulSet.Id) {
+		err := listPages(k.opts.pageSize(ResourceTypeStatefulSet.Id), func(opts metav1.ListOptions) (string, error) {
+			resp, err := k.client.AppsV1().StatefulSets(namespace).List(ctx, opts)
+			if err != nil {
+				return "", fmt.Errorf("listing statefulsets in namespace %s: %w", namespace, err)
+			}
+			for _, s := range resp.Items {
+				templates = append(templates, WorkloadPodTemplate{ResourceType: ResourceTypeStatefulSet, Name: s.Name, Spec: s.Spec.Template.Spec})
+			}
+			return resp.Continue, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if k.opts.syncsResourceType(ResourceTypeDaemonSet.Id) {
+		err := listPages(k.opts.pageSize(ResourceTypeDaemonSet.Id), func(opts metav1.ListOptions) (string, error) {
+			resp, err := k.client.AppsV1().DaemonSets(namespace).List(ctx, opts)
+			if err != nil {
+				return "", fmt.Errorf("listing daemonsets in namespace %s: %w", namespace, err)
+			}
+			for _, d := range resp.Items {
+				templates = append(templates, WorkloadPodTemplate{ResourceType: ResourceTypeDaemonSet, Name: d.Name, Spec: d.Spec.Template.Spec})
+			}
+			return resp.Continue, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if k.workloadsCache == nil {
+		k.workloadsCache = make(map[string][]WorkloadPodTemplate)
+	}
+	k.workloadsCache[namespace] = templates
+	return templates, nil
+}
+
+// listPages calls list with the continue token of each page until the last one.
+func listPages(pageSize int64, list func(opts metav1.ListOptions) (string, error)) error {
+	continueToken := ""
+	for {
+		next, err := list(metav1.ListOptions{Limit: pageSize, Continue: continueToken})
+		if err != nil {
+			return err
+		}
+		if next == "" {
+			return nil
+		}
+		continueToken = next
+	}
+}
+
+// workloadMountGrants returns the mounted_by grants of a configmap to the workloads whose pod template consumes
+// it. Unlike the grants from live pods, they don't depend on which pods are running.
+func workloadMountGrants(resource *v2.Resource, namespace string, templates []WorkloadPodTemplate, mounts func(*corev1.PodSpec) bool) []*v2.Grant {
+	var rv []*v2.Grant
+	for i := range templates {
+		template := &templates[i]
+		if !mounts(&template.Spec) {
+			continue
+		}
+		principal := &v2.ResourceId{ResourceType: template.ResourceType.Id, Resource: namespacedName(namespace, template.Name)}
+		rv = append(rv, grant.NewGrant(resource, ConfigMapMountedByEntitlement, principal))
+	}
+	sort.Slice(rv, func(i, j int) bool {
+		return rv[i].Id < rv[j].Id
+	})
+	return rv
+}
@@ -0,0 +1,186 @@
+package connector
+
+import (
+	"context"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+)
+
+// syncTransform rewrites what a wrapped syncer receives from the SDK and what it emits.
+type syncTransform interface {
+	// inboundResourceID and inboundResource map IDs and resources passed in by the SDK back to the
+	// form the wrapped syncer emitted them in.
+	inboundResourceID(id *v2.ResourceId) *v2.ResourceId
+	inboundResource(resource *v2.Resource) *v2.Resource
+
+	outboundResource(resource *v2.Resource) (*v2.Resource, error)
+	outboundEntitlement(ent *v2.Entitlement) (*v2.Entitlement, error)
+	outboundGrant(g *v2.Grant) (*v2.Grant, error)
+
+	// readOnly reports whether the transform prevents provisioning through the wrapped syncer.
+	readOnly() bool
+}
+
+// listObserver is implemented by transforms that need to know when the top-level listing of a resource type
+// starts over and when its last page has been listed. Listings of the children of a resource aren't observed.
+type listObserver interface {
+	listStarted(ctx context.Context, resourceTypeID string)
+	listCompleted(ctx context.Context, resourceTypeID string) error
+}
+
+// syncerWrapper decorates a ResourceSyncer with transforms applied to everything it receives and emits.
+type syncerWrapper struct {
+	syncer     connectorbuilder.ResourceSyncer
+	transforms []syncTransform
+}
+
+// provisionerWrapper is a syncerWrapper that also passes provisioning through to the wrapped syncer.
+type provisionerWrapper struct {
+	*syncerWrapper
+	provisioner connectorbuilder.ResourceProvisioner
+}
+
+// wrapSyncer applies the transforms to a syncer and classifies the Kubernetes API errors it returns.
+// Provisioning is kept when the syncer supports it and no transform is read-only.
+func wrapSyncer(syncer connectorbuilder.ResourceSyncer, transforms ...syncTransform) connectorbuilder.ResourceSyncer {
+	w := &syncerWrapper{
+		syncer:     syncer,
+		transforms: transforms,
+	}
+
+	provisioner, ok := syncer.(connectorbuilder.ResourceProvisioner)
+	if !ok {
+		return w
+	}
+	for _, t := range transforms {
+		if t.readOnly() {
+			return w
+		}
+	}
+
+	return &provisionerWrapper{
+		syncerWrapper: w,
+		provisioner:   provisioner,
+	}
+}
+
+// ResourceType returns the resource type of the wrapped syncer.
+func (w *syncerWrapper) ResourceType(ctx context.Context) *v2.ResourceType {
+	return w.syncer.ResourceType(ctx)
+}
+
+// List lists the resources of the wrapped syncer and transforms them.
+func (w *syncerWrapper) List(ctx context.Context, parentResourceID *v2.ResourceId, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	resourceTypeID := w.syncer.ResourceType(ctx).GetId()
+	if pToken == nil || pToken.Token == "" {
+		for _, t := range w.transforms {
+			if o, ok := t.(listObserver); ok && parentResourceID == nil {
+				o.listStarted(ctx, resourceTypeID)
+			}
+		}
+	}
+
+	resources, nextPageToken, annos, err := w.syncer.List(ctx, w.inboundResourceID(parentResourceID), pToken)
+	if err != nil {
+		return nil, "", nil, classifyKubeError(err)
+	}
+
+	for i, resource := range resources {
+		for _, t := range w.transforms {
+			resource, err = t.outboundResource(resource)
+			if err != nil {
+				return nil, "", nil, err
+			}
+		}
+		resources[i] = resource
+	}
+
+	if nextPageToken == "" && parentResourceID == nil {
+		for _, t := range w.transforms {
+			if o, ok := t.(listObserver); ok {
+				if err := o.listCompleted(ctx, resourceTypeID); err != nil {
+					return nil, "", nil, err
+				}
+			}
+		}
+	}
+
+	return resources, nextPageToken, annos, nil
+}
+
+// Entitlements lists the entitlements of the wrapped syncer and transforms them.
+func (w *syncerWrapper) Entitlements(ctx context.Context, resource *v2.Resource, pToken *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	entitlements, nextPageToken, annos, err := w.syncer.Entitlements(ctx, w.inboundResource(resource), pToken)
+	if err != nil {
+		return nil, "", nil, classifyKubeError(err)
+	}
+
+	for i, ent := range entitlements {
+		for _, t := range w.transforms {
+			ent, err = t.outboundEntitlement(ent)
+			if err != nil {
+				return nil, "", nil, err
+			}
+		}
+		entitlements[i] = ent
+	}
+
+	return entitlements, nextPageToken, annos, nil
+}
+
+// Grants lists the grants of the wrapped syncer and transforms them.
+func (w *syncerWrapper) Grants(ctx context.Context, resource *v2.Resource, pToken *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	grants, nextPageToken, annos, err := w.syncer.Grants(ctx, w.inboundResource(resource), pToken)
+	if err != nil {
+		return nil, "", nil, classifyKubeError(err)
+	}
+
+	for i, g := range grants {
+		for _, t := range w.transforms {
+			g, err = t.outboundGrant(g)
+			if err != nil {
+				return nil, "", nil, err
+			}
+		}
+		grants[i] = g
+	}
+
+	return grants, nextPageToken, annos, nil
+}
+
+// inboundResourceID applies the inbound transforms to a resource ID in reverse order.
+func (w *syncerWrapper) inboundResourceID(id *v2.ResourceId) *v2.ResourceId {
+	if id == nil {
+		return nil
+	}
+	for i := len(w.transforms) - 1; i >= 0; i-- {
+		id = w.transforms[i].inboundResourceID(id)
+	}
+	return id
+}
+
+// inboundResource applies the inbound transforms to a resource in reverse order.
+func (w *syncerWrapper) inboundResource(resource *v2.Resource) *v2.Resource {
+	if resource == nil {
+		return nil
+	}
+	for i := len(w.transforms) - 1; i >= 0; i-- {
+		resource = w.transforms[i].inboundResource(resource)
+	}
+	return resource
+}
+
+// Grant passes the grant through to the wrapped syncer.
+func (w *provisionerWrapper) Grant(ctx context.Context, principal *v2.Resource, ent *v2.Entitlement) (annotations.Annotations, error) {
+	annos, err := w.provisioner.Grant(ctx, principal, ent)
+	return annos, classifyKubeError(err)
+}
+
+// Revoke passes the revoke through to the wrapped syncer.
+func (w *provisionerWrapper) Revoke(ctx context.Context, g *v2.Grant) (annotations.Annotations, error) {
+	annos, err := w.provisioner.Revoke(ctx, g)
+	return annos, classifyKubeError(err)
+}
@@ -0,0 +1,222 @@
+// Package kubetest provides test support for exercising the connector against the fake clientset.
+package kubetest
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// Paginator makes the fake clientset honor Limit and Continue on list requests, which it otherwise ignores, by
+// serving the objects in its tracker in chunks. Objects are listed in namespace/name order.
+type Paginator struct {
+	client   *fake.Clientset
+	pageSize int
+
+	mu       sync.Mutex
+	expired  map[string]bool
+	failures map[string]error
+	requests map[string][]metav1.ListOptions
+}
+
+// PaginatorOption configures a Paginator.
+type PaginatorOption func(*Paginator)
+
+// WithPageSize caps the size of the pages below the Limit of the requests, so that tests don't need more
+// objects than the connector's page size to get several pages.
+func WithPageSize(size int) PaginatorOption {
+	return func(p *Paginator) {
+		p.pageSize = size
+	}
+}
+
+// Paginate installs a Paginator on the client for the given resources, e.g. "namespaces" or "rolebindings".
+func Paginate(client *fake.Clientset, resources []string, opts ...PaginatorOption) *Paginator {
+	p := &Paginator{
+		client:   client,
+		expired:  make(map[string]bool),
+		failures: make(map[string]error),
+		requests: make(map[string][]metav1.ListOptions),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	for _, resource := range resources {
+		client.PrependReactor("list", resource, p.react)
+	}
+	return p
+}
+
+// Expire makes the next list request of the resource resuming from a continue token fail with 410 Gone, as
+// the API server does when the token is older than the compaction window.
+func (p *Paginator) Expire(resource string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.expired[resource] = true
+}
+
+// FailContinue makes the next list request of the resource resuming from a continue token fail with err.
+func (p *Paginator) FailContinue(resource string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failures[resource] = err
+}
+
+// Requests returns the options of the list requests of the resource served so far.
+func (p *Paginator) Requests(resource string) []metav1.ListOptions {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]metav1.ListOptions(nil), p.requests[resource]...)
+}
+
+// Pages returns the number of list requests of the resource served so far.
+func (p *Paginator) Pages(resource string) int {
+	return len(p.Requests(resource))
+}
+
+// react serves a page of the list of the requested resource.
+func (p *Paginator) react(action k8stesting.Action) (bool, runtime.Object, error) {
+	list, ok := action.(k8stesting.ListActionImpl)
+	if !ok {
+		return false, nil, nil
+	}
+	resource := list.GetResource().Resource
+	opts := list.ListOptions
+
+	if err := p.record(resource, opts); err != nil {
+		return true, nil, err
+	}
+
+	offset := 0
+	if opts.Continue != "" {
+		var err error
+		offset, err = decodeContinue(resource, opts.Continue)
+		if err != nil {
+			return true, nil, k8serrors.NewBadRequest(err.Error())
+		}
+	}
+
+	obj, err := p.client.Tracker().List(list.GetResource(), list.GetKind(), list.GetNamespace())
+	if err != nil {
+		return true, nil, err
+	}
+	items, err := meta.ExtractList(obj)
+	if err != nil {
+		return true, nil, err
+	}
+	items, err = selectAndSort(items, list.GetListRestrictions().Labels)
+	if err != nil {
+		return true, nil, err
+	}
+
+	limit := len(items)
+	if opts.Limit > 0 && int(opts.Limit) < limit {
+		limit = int(opts.Limit)
+	}
+	if p.pageSize > 0 && p.pageSize < limit {
+		limit = p.pageSize
+	}
+	if offset > len(items) {
+		offset = len(items)
+	}
+	end := offset + limit
+	if end > len(items) {
+		end = len(items)
+	}
+
+	if err := meta.SetList(obj, items[offset:end]); err != nil {
+		return true, nil, err
+	}
+	listMeta, err := meta.ListAccessor(obj)
+	if err != nil {
+		return true, nil, err
+	}
+	listMeta.SetContinue("")
+	if end < len(items) {
+		listMeta.SetContinue(encodeContinue(resource, end))
+	}
+	return true, obj, nil
+}
+
+// record records a list request, returning the injected error of requests resuming from a continue token.
+func (p *Paginator) record(resource string, opts metav1.ListOptions) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requests[resource] = append(p.requests[resource], opts)
+
+	if opts.Continue == "" {
+		return nil
+	}
+	if p.expired[resource] {
+		delete(p.expired, resource)
+		return k8serrors.NewResourceExpired("The provided continue parameter is too old to display a consistent list result. " +
+			"You can start a new list without the continue parameter.")
+	}
+	if err, ok := p.failures[resource]; ok {
+		delete(p.failures, resource)
+		return err
+	}
+	return nil
+}
+
+// selectAndSort returns the items matching the selector in namespace/name order.
+func selectAndSort(items []runtime.Object, selector labels.Selector) ([]runtime.Object, error) {
+	type keyedItem struct {
+		key  string
+		item runtime.Object
+	}
+
+	keyed := make([]keyedItem, 0, len(items))
+	for _, item := range items {
+		accessor, err := meta.Accessor(item)
+		if err != nil {
+			return nil, err
+		}
+		if selector != nil && !selector.Matches(labels.Set(accessor.GetLabels())) {
+			continue
+		}
+		keyed = append(keyed, keyedItem{key: accessor.GetNamespace() + "/" + accessor.GetName(), item: item})
+	}
+	sort.Slice(keyed, func(i, j int) bool {
+		return keyed[i].key < keyed[j].key
+	})
+
+	rv := make([]runtime.Object, 0, len(keyed))
+	for _, k := range keyed {
+		rv = append(rv, k.item)
+	}
+	return rv, nil
+}
+
+// encodeContinue returns an opaque continue token resuming the list of the resource at the offset.
+func encodeContinue(resource string, offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(resource + "/" + strconv.Itoa(offset)))
+}
+
+// decodeContinue returns the offset a continue token of the resource resumes at.
+func decodeContinue(resource, token string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, fmt.Errorf("invalid continue token %q: %w", token, err)
+	}
+	tokenResource, offset, ok := strings.Cut(string(raw), "/")
+	if !ok || tokenResource != resource {
+		return 0, fmt.Errorf("continue token %q isn't for %s", token, resource)
+	}
+	rv, err := strconv.Atoi(offset)
+	if err != nil || rv < 0 {
+		return 0, fmt.Errorf("invalid continue token %q", token)
+	}
+	return rv, nil
+}
@@ -0,0 +1,153 @@
+package kubetest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// namespaces returns namespaces ns-0 to ns-<n-1>, the even ones labeled tier=prod.
+func namespaces(n int) []runtime.Object {
+	var rv []runtime.Object
+	for i := 0; i < n; i++ {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("ns-%d", i)}}
+		if i%2 == 0 {
+			ns.Labels = map[string]string{"tier": "prod"}
+		}
+		rv = append(rv, ns)
+	}
+	return rv
+}
+
+// listNames lists every page of namespaces, returning their names and the number of pages.
+func listNames(ctx context.Context, t *testing.T, client *fake.Clientset, opts metav1.ListOptions) ([]string, int) {
+	var names []string
+	pages := 0
+	for {
+		resp, err := client.CoreV1().Namespaces().List(ctx, opts)
+		require.NoError(t, err)
+		pages++
+		for _, ns := range resp.Items {
+			names = append(names, ns.Name)
+		}
+		if resp.Continue == "" {
+			return names, pages
+		}
+		opts.Continue = resp.Continue
+	}
+}
+
+func TestPaginator_Chunks(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(namespaces(7)...)
+	p := Paginate(client, []string{"namespaces"})
+
+	names, pages := listNames(ctx, t, client, metav1.ListOptions{Limit: 3})
+	assert.Equal(t, []string{"ns-0", "ns-1", "ns-2", "ns-3", "ns-4", "ns-5", "ns-6"}, names)
+	assert.Equal(t, 3, pages)
+	assert.Equal(t, 3, p.Pages("namespaces"))
+
+	// Without a limit everything is served at once
+	names, pages = listNames(ctx, t, client, metav1.ListOptions{})
+	assert.Len(t, names, 7)
+	assert.Equal(t, 1, pages)
+}
+
+func TestPaginator_PageSize(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(namespaces(7)...)
+	Paginate(client, []string{"namespaces"}, WithPageSize(2))
+
+	names, pages := listNames(ctx, t, client, metav1.ListOptions{Limit: 500})
+	assert.Len(t, names, 7)
+	assert.Equal(t, 4, pages)
+}
+
+func TestPaginator_LabelSelector(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(namespaces(7)...)
+	Paginate(client, []string{"namespaces"}, WithPageSize(2))
+
+	names, pages := listNames(ctx, t, client, metav1.ListOptions{Limit: 500, LabelSelector: "tier=prod"})
+	assert.Equal(t, []string{"ns-0", "ns-2", "ns-4", "ns-6"}, names)
+	assert.Equal(t, 2, pages)
+}
+
+func TestPaginator_Namespaced(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(
+		&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Namespace: "b", Name: "reader"}},
+		&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Namespace: "a", Name: "writer"}},
+		&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Namespace: "a", Name: "reader"}},
+	)
+	Paginate(client, []string{"roles"}, WithPageSize(1))
+
+	var keys []string
+	opts := metav1.ListOptions{Limit: 500}
+	for {
+		resp, err := client.RbacV1().Roles("").List(ctx, opts)
+		require.NoError(t, err)
+		for _, role := range resp.Items {
+			keys = append(keys, role.Namespace+"/"+role.Name)
+		}
+		if resp.Continue == "" {
+			break
+		}
+		opts.Continue = resp.Continue
+	}
+	assert.Equal(t, []string{"a/reader", "a/writer", "b/reader"}, keys)
+
+	resp, err := client.RbacV1().Roles("a").List(ctx, metav1.ListOptions{Limit: 500})
+	require.NoError(t, err)
+	require.Len(t, resp.Items, 1)
+	assert.Equal(t, "a/reader", resp.Items[0].Namespace+"/"+resp.Items[0].Name)
+	assert.NotEmpty(t, resp.Continue)
+}
+
+func TestPaginator_Expire(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(namespaces(4)...)
+	p := Paginate(client, []string{"namespaces"}, WithPageSize(2))
+
+	first, err := client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{Limit: 500})
+	require.NoError(t, err)
+	require.NotEmpty(t, first.Continue)
+
+	// Only the next request resuming from a continue token fails
+	p.Expire("namespaces")
+	_, err = client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{Limit: 500})
+	require.NoError(t, err)
+	_, err = client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{Limit: 500, Continue: first.Continue})
+	require.True(t, k8serrors.IsResourceExpired(err), err)
+	_, err = client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{Limit: 500, Continue: first.Continue})
+	require.NoError(t, err)
+
+	p.FailContinue("namespaces", k8serrors.NewServiceUnavailable("etcd is down"))
+	_, err = client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{Limit: 500, Continue: first.Continue})
+	require.True(t, k8serrors.IsServiceUnavailable(err), err)
+
+	assert.Equal(t, 5, p.Pages("namespaces"))
+	assert.Equal(t, first.Continue, p.Requests("namespaces")[2].Continue)
+}
+
+func TestPaginator_InvalidContinue(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(namespaces(4)...)
+	Paginate(client, []string{"namespaces", "roles"}, WithPageSize(2))
+
+	_, err := client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{Continue: "not-a-token"})
+	require.True(t, k8serrors.IsBadRequest(err), err)
+
+	// Tokens of another resource are rejected
+	_, err = client.RbacV1().Roles("").List(ctx, metav1.ListOptions{Continue: encodeContinue("namespaces", 2)})
+	require.True(t, k8serrors.IsBadRequest(err), err)
+}